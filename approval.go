@@ -0,0 +1,363 @@
+package main
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// ApprovalConfig gates invocations behind an external approval endpoint,
+// like an approvals bot
+type ApprovalConfig struct {
+	// URL receives the approval requests, and URL/<id> is polled for the
+	// decision
+	URL string `yaml:"url"`
+	// TokenEnv names an environment variable holding a bearer token for the
+	// endpoint
+	TokenEnv string `yaml:"token_env"`
+	// Require lists the actions needing approval per environment, like
+	// prod: [complete]
+	Require map[string][]Action `yaml:"require"`
+	// Timeout is how long to wait for a decision, like "15m"
+	Timeout string `yaml:"timeout"`
+}
+
+// Approval statuses of the endpoint, plus the outcomes the gate adds
+const (
+	approvalPending    = "pending"
+	approvalApproved   = "approved"
+	approvalDenied     = "denied"
+	approvalTimeout    = "timeout"
+	approvalBreakGlass = "break-glass"
+)
+
+// defaultApprovalTimeout is how long to wait for a decision by default
+const defaultApprovalTimeout = 15 * time.Minute
+
+// approvalPollInterval is how often a pending request is polled
+const approvalPollInterval = 5 * time.Second
+
+// The approval gate of the active context, empty when there is none
+var (
+	approvalURL      string
+	approvalTokenEnv string
+	approvalRequire  map[string][]Action
+	approvalWait     = defaultApprovalTimeout
+)
+
+// breakGlassReason skips the approval gate, set with --break-glass. The
+// reason is recorded with the invocation.
+var breakGlassReason string
+
+// applyApprovalConfig sets the approval gate from the approval section of
+// the config
+func applyApprovalConfig(cfg ApprovalConfig) error {
+	approvalURL, approvalTokenEnv, approvalRequire = cfg.URL, cfg.TokenEnv, cfg.Require
+	approvalWait = defaultApprovalTimeout
+	if len(cfg.Require) > 0 && cfg.URL == "" {
+		return configErrorf([]string{"approval", "url"}, "required actions need an approval endpoint")
+	}
+	if cfg.URL != "" && !strings.HasPrefix(cfg.URL, "https://") && !strings.HasPrefix(cfg.URL, "http://") {
+		return configErrorf([]string{"approval", "url"}, "expected an http(s) URL, got %q", cfg.URL)
+	}
+	for env, actions := range cfg.Require {
+		for _, a := range actions {
+			switch a {
+			case ActionProcess, ActionComplete, ActionStart, ActionRollback:
+			default:
+				return configErrorf([]string{"approval", "require", env}, "unknown action %q", a)
+			}
+		}
+	}
+	if cfg.Timeout != "" {
+		d, err := time.ParseDuration(cfg.Timeout)
+		if err != nil || d <= 0 {
+			return configErrorf([]string{"approval", "timeout"}, "expected a positive duration like 15m, got %q", cfg.Timeout)
+		}
+		approvalWait = d
+	}
+	return nil
+}
+
+// approvalRequired reports whether a payload has to be approved before it's
+// invoked in env. Dry runs change nothing and never need approval.
+func approvalRequired(env string, payload EventPayload) bool {
+	return mutates(payload) && slices.Contains(approvalRequire[env], payload.Action)
+}
+
+// approvalRequest is what the endpoint is sent
+type approvalRequest struct {
+	Env          string          `json:"env"`
+	Action       Action          `json:"action"`
+	FunctionName string          `json:"function_name"`
+	Payload      json.RawMessage `json:"payload"`
+	Operator     string          `json:"operator"`
+	// Summary says in plain words what the invocation will do, ready to
+	// post in a chat message
+	Summary     string    `json:"summary"`
+	Context     string    `json:"context,omitempty"`
+	RequestedAt time.Time `json:"requested_at"`
+}
+
+// approvalResponse is the endpoint's answer, to the request and to polls
+type approvalResponse struct {
+	ID       string `json:"id"`
+	Status   string `json:"status"`
+	Approver string `json:"approver"`
+	Reason   string `json:"reason"`
+}
+
+// approvalGrant is how an invocation got past the gate, recorded with it
+// in the history. It is zero when the gate doesn't apply.
+type approvalGrant struct {
+	ID         string
+	Status     string
+	Approver   string
+	Reason     string
+	Response   json.RawMessage
+	BreakGlass string
+}
+
+// describe is the outcome of the gate for display and for failure lines
+func (g approvalGrant) describe() string {
+	switch g.Status {
+	case approvalApproved:
+		return fmt.Sprintf("approved by %s (request %s)", cmp.Or(g.Approver, "the approver"), g.ID)
+	case approvalDenied:
+		s := fmt.Sprintf("denied by %s (request %s)", cmp.Or(g.Approver, "the approver"), g.ID)
+		if g.Reason != "" {
+			s += ": " + g.Reason
+		}
+		return s
+	case approvalTimeout:
+		return fmt.Sprintf("no decision on request %s within %s", g.ID, formatTimeout(approvalWait))
+	case approvalBreakGlass:
+		return "skipped with --break-glass: " + g.BreakGlass
+	}
+	return g.Status
+}
+
+// approvalCall sends a request to the approval endpoint and decodes its
+// answer, keeping the raw response for the history
+func approvalCall(ctx context.Context, method, url string, body []byte) (approvalResponse, []byte, error) {
+	var resp approvalResponse
+	req, err := http.NewRequestWithContext(ctx, method, url, bytes.NewReader(body))
+	if err != nil {
+		return resp, nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if approvalTokenEnv != "" {
+		if token := os.Getenv(approvalTokenEnv); token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+	}
+	client := http.Client{Timeout: 30 * time.Second}
+	res, err := client.Do(req)
+	if err != nil {
+		return resp, nil, fmt.Errorf("approval endpoint: %v", err)
+	}
+	defer res.Body.Close()
+	raw, err := io.ReadAll(res.Body)
+	if err != nil {
+		return resp, nil, fmt.Errorf("approval endpoint: %v", err)
+	}
+	if res.StatusCode >= 300 {
+		return resp, raw, fmt.Errorf("approval endpoint: %s: %s", res.Status, strings.TrimSpace(string(raw)))
+	}
+	if err := json.Unmarshal(raw, &resp); err != nil {
+		return resp, raw, fmt.Errorf("approval endpoint: the response isn't JSON: %v", err)
+	}
+	switch resp.Status {
+	case approvalPending, approvalApproved, approvalDenied:
+	default:
+		return resp, raw, fmt.Errorf("approval endpoint: unknown status %q", resp.Status)
+	}
+	return resp, raw, nil
+}
+
+// submitApproval asks the endpoint to approve a payload
+func submitApproval(ctx context.Context, env string, payload EventPayload) (approvalGrant, error) {
+	sent, err := encodePayload(env, payload)
+	if err != nil {
+		return approvalGrant{}, err
+	}
+	body, err := json.Marshal(approvalRequest{
+		Env:          env,
+		Action:       payload.Action,
+		FunctionName: sweepstakeFunction(env),
+		Payload:      sent,
+		Operator:     operatorName(),
+		Summary:      invocationSummary(env, payload),
+		Context:      activeContext,
+		RequestedAt:  time.Now().UTC(),
+	})
+	if err != nil {
+		return approvalGrant{}, err
+	}
+	resp, raw, err := approvalCall(ctx, http.MethodPost, approvalURL, body)
+	if err == nil && resp.ID == "" {
+		err = fmt.Errorf("approval endpoint: the response has no request id")
+	}
+	return grantOf(resp, raw), err
+}
+
+// pollApproval fetches the decision on a submitted request
+func pollApproval(ctx context.Context, id string) (approvalGrant, error) {
+	resp, raw, err := approvalCall(ctx, http.MethodGet, strings.TrimSuffix(approvalURL, "/")+"/"+id, nil)
+	if resp.ID == "" {
+		resp.ID = id
+	}
+	return grantOf(resp, raw), err
+}
+
+func grantOf(resp approvalResponse, raw []byte) approvalGrant {
+	g := approvalGrant{ID: resp.ID, Status: resp.Status, Approver: resp.Approver, Reason: resp.Reason}
+	if json.Valid(raw) {
+		g.Response = raw
+	}
+	return g
+}
+
+// awaitApproval submits a payload and polls until it's approved, denied or
+// the timeout passes, reporting the submitted request to onStatus
+func awaitApproval(ctx context.Context, env string, payload EventPayload, onStatus func(approvalGrant)) (approvalGrant, error) {
+	g, err := submitApproval(ctx, env, payload)
+	if err != nil {
+		return g, err
+	}
+	deadline := time.Now().Add(approvalWait)
+	onStatus(g)
+	for g.Status == approvalPending {
+		if time.Now().After(deadline) {
+			g.Status = approvalTimeout
+			return g, nil
+		}
+		select {
+		case <-ctx.Done():
+			return g, ctx.Err()
+		case <-time.After(approvalPollInterval):
+		}
+		if g, err = pollApproval(ctx, g.ID); err != nil {
+			return g, err
+		}
+	}
+	return g, nil
+}
+
+// recordApprovalRefusal writes a denied or timed out request to the history,
+// with the approver's response, as no invocation record will follow it
+func recordApprovalRefusal(env string, payload EventPayload, g approvalGrant) {
+	_ = appendHistory(historyRecord{
+		Time:             time.Now(),
+		Env:              env,
+		Action:           payload.Action,
+		QuestID:          payload.SweepstakeQuestID,
+		Operator:         operatorName(),
+		Error:            "approval " + g.describe(),
+		Approval:         g.Status,
+		ApprovalID:       g.ID,
+		ApprovalResponse: g.Response,
+	})
+}
+
+// breakGlassGrant lets an invocation skip the gate, noting why
+func breakGlassGrant() approvalGrant {
+	return approvalGrant{Status: approvalBreakGlass, BreakGlass: breakGlassReason}
+}
+
+// approvalMsg carries the state of the confirmation's approval request
+type approvalMsg struct {
+	gen   int
+	grant approvalGrant
+	err   error
+}
+
+func submitApprovalCmd(gen int, env string, payload EventPayload) tea.Cmd {
+	return func() tea.Msg {
+		g, err := submitApproval(context.Background(), env, payload)
+		return approvalMsg{gen: gen, grant: g, err: err}
+	}
+}
+
+func pollApprovalCmd(gen int, id string) tea.Cmd {
+	return tea.Tick(approvalPollInterval, func(time.Time) tea.Msg {
+		g, err := pollApproval(context.Background(), id)
+		return approvalMsg{gen: gen, grant: g, err: err}
+	})
+}
+
+// approvalState is the approval request of the confirmation screen
+type approvalState struct {
+	gen      int
+	waiting  bool
+	deadline time.Time
+	grant    approvalGrant
+	err      error
+}
+
+// startApproval submits the pending payload for approval instead of
+// invoking it
+func (m model) startApproval() (tea.Model, tea.Cmd) {
+	gen := m.approval.gen + 1
+	m.approval = approvalState{gen: gen, waiting: true, deadline: time.Now().Add(approvalWait)}
+	return m, tea.Batch(m.spinner.Tick, submitApprovalCmd(gen, m.selectedEnv, m.pendingPayload))
+}
+
+// updateApproval follows the approval request until it's decided, invoking
+// once it's approved
+func (m model) updateApproval(msg approvalMsg) (tea.Model, tea.Cmd) {
+	a := &m.approval
+	if msg.gen != a.gen || !a.waiting || m.currentScreen != ConfirmScreen {
+		return m, nil
+	}
+	a.grant, a.err = msg.grant, msg.err
+	switch {
+	case msg.err != nil:
+		a.waiting = false
+		return m, nil
+	case msg.grant.Status == approvalPending && time.Now().After(a.deadline):
+		a.grant.Status = approvalTimeout
+		fallthrough
+	case msg.grant.Status == approvalDenied:
+		a.waiting = false
+		recordApprovalRefusal(m.selectedEnv, m.pendingPayload, a.grant)
+		return m, nil
+	case msg.grant.Status == approvalApproved:
+		a.waiting = false
+		grant := a.grant
+		return m.startInvocationWith(m.pendingPayload, grant)
+	}
+	return m, pollApprovalCmd(a.gen, msg.grant.ID)
+}
+
+// approvalView renders the approval request under the confirmation
+func (m model) approvalView() string {
+	a := m.approval
+	switch {
+	case a.waiting && a.grant.ID == "":
+		return fmt.Sprintf("  %s %s\n\n", m.spinner.View(), tr(msgApprovalAsking, approvalURL))
+	case a.waiting:
+		return fmt.Sprintf("  %s %s\n\n", m.spinner.View(),
+			tr(msgApprovalWaiting, a.grant.ID, formatTimeout(time.Until(a.deadline).Round(time.Second)), keys.help(keyBack)))
+	case a.err != nil:
+		return fmt.Sprintf("  %s %s\n\n", glyphs.Cross, tr(msgApprovalFailed, a.err))
+	case a.grant.Status == approvalDenied || a.grant.Status == approvalTimeout:
+		return fmt.Sprintf("  %s %s\n\n", glyphs.Cross, tr(msgApprovalNotInvoked, a.grant.describe()))
+	case approvalRequired(m.selectedEnv, m.pendingPayload) && m.batchRows == nil:
+		if breakGlassReason != "" {
+			return fmt.Sprintf("  %s %s\n\n", glyphs.Warn, tr(msgApprovalBreakGlass, breakGlassReason))
+		}
+		return "  " + tr(msgApprovalRequired, keys.help(keyConfirm)) + "\n\n"
+	}
+	return ""
+}
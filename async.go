@@ -0,0 +1,265 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/lambda"
+	"github.com/revrost/playtools/pkg/playtools"
+)
+
+// Invocation modes. Async invokes return immediately and the outcome is
+// polled from the function's logs, so long runs don't depend on the client
+// holding a connection open.
+const (
+	modeSync  = "sync"
+	modeAsync = "async"
+)
+
+// asyncPollInterval is the wait between log fetches of an async invocation
+var asyncPollInterval = 10 * time.Second
+
+// asyncPollGrace is how long past the function timeout an async invocation
+// is polled for, allowing for queueing and log delivery
+const asyncPollGrace = 2 * time.Minute
+
+// modeDecision is the invocation mode picked for a payload and why
+type modeDecision struct {
+	Mode        string
+	Recommended string
+	Reason      string
+	// Switchable is set when the user may pick the other mode
+	Switchable bool
+}
+
+// why explains the mode, noting when the recommendation was overridden
+func (d modeDecision) why() string {
+	if d.Mode != d.Recommended {
+		return fmt.Sprintf("%s recommended but overridden, %s", d.Recommended, d.Reason)
+	}
+	return d.Reason
+}
+
+// asyncApplies reports whether an action can be invoked async. Only the long
+// running actions are, the others are quick and their response is the point.
+func asyncApplies(action Action) bool {
+	return (action == ActionProcess || action == ActionComplete) && toolRegistry[sweepstakeTool].Transport == transportLambda
+}
+
+// chooseMode picks async for the long running actions when the function
+// timeout exceeds the tool's threshold. A zero timeout means it is unknown.
+func chooseMode(action Action, timeout time.Duration) modeDecision {
+	if !asyncApplies(action) {
+		return modeDecision{Mode: modeSync, Recommended: modeSync}
+	}
+	threshold := toolRegistry[sweepstakeTool].AsyncThreshold
+	d := modeDecision{Mode: modeSync, Switchable: true}
+	switch {
+	case timeout == 0:
+		d.Reason = "function timeout unknown"
+	case timeout > threshold:
+		d.Mode = modeAsync
+		d.Reason = fmt.Sprintf("function timeout %s exceeds %s", formatTimeout(timeout), formatTimeout(threshold))
+	default:
+		d.Reason = fmt.Sprintf("function timeout %s is within %s", formatTimeout(timeout), formatTimeout(threshold))
+	}
+	d.Recommended = d.Mode
+	return d
+}
+
+// modeView shows the invocation mode on the confirmation screen
+func (m model) modeView() string {
+	d := m.invokeMode
+	if !d.Switchable {
+		return ""
+	}
+	other := modeAsync
+	if d.Mode == modeAsync {
+		other = modeSync
+	}
+	return "  " + tr(msgConfirmMode, d.Mode, d.why(), other) + "\n\n"
+}
+
+// functionTimeout is the timeout of the sweepstake function in env, from
+// the cached environment info when fresh, zero when it can't be found out
+func functionTimeout(env string) time.Duration {
+	state, _ := loadState()
+	info, ok := state.EnvInfo[env]
+	if !ok || info.stale() {
+		var err error
+		if info, err = fetchEnvInfo(context.Background(), env); err != nil {
+			return 0
+		}
+		saveEnvInfo(env, info)
+	}
+	return time.Duration(info.Timeout) * time.Second
+}
+
+// formatTimeout renders a timeout in whole minutes when it is one, like "15m"
+func formatTimeout(d time.Duration) string {
+	if d%time.Minute == 0 {
+		return fmt.Sprintf("%dm", int(d.Minutes()))
+	}
+	return d.String()
+}
+
+// invokeFunctionAsync queues an async invocation, returning its request ID
+func invokeFunctionAsync(ctx context.Context, client *lambda.Client, functionName string, payload []byte) (string, error) {
+	result, err := playtools.InvokeFunction(ctx, client, functionName, payload, playtools.WithAsync())
+	if err != nil {
+		return "", err
+	}
+	return result.RequestID, nil
+}
+
+// logSignal is what the logs tell about a pending async invocation
+type logSignal int
+
+const (
+	logsUnavailable logSignal = iota // the logs couldn't be fetched
+	logsEmpty                        // nothing logged for the request yet
+	logsStarted                      // the request logged lines but no REPORT yet
+)
+
+// metricSignal is what the function's Invocations and Errors metrics for the
+// minute of submission tell about a pending async invocation. They count
+// every invocation of the function, so they only hint at this one.
+type metricSignal int
+
+const (
+	metricsUnavailable metricSignal = iota // the metrics couldn't be queried
+	metricsNone                            // no invocation recorded
+	metricsInvoked                         // invocations recorded, no errors
+	metricsErrored                         // invocations recorded, some with errors
+)
+
+// pendingStatus combines the log and metric signals of a pending async
+// invocation into its status. Logs lag by up to a minute after the invoke,
+// so the metrics stand in until they show up.
+func pendingStatus(logs logSignal, metrics metricSignal) string {
+	if logs == logsStarted {
+		return "running, logs are coming in"
+	}
+	switch metrics {
+	case metricsInvoked:
+		return "function executed, awaiting logs"
+	case metricsErrored:
+		return "function executed with errors, awaiting logs"
+	case metricsNone:
+		return "no execution recorded yet"
+	}
+	if logs == logsUnavailable {
+		return "unknown, neither the logs nor the metrics could be fetched"
+	}
+	return "unknown, nothing logged yet"
+}
+
+// requestLogSignal reads the signal of the logs fetched for a request
+func requestLogSignal(logs string, err error) logSignal {
+	switch {
+	case err != nil:
+		return logsUnavailable
+	case strings.TrimSpace(logs) == "":
+		return logsEmpty
+	}
+	return logsStarted
+}
+
+// invocationMetrics queries the function's Invocations and Errors for the
+// minute the invocation was submitted in
+func invocationMetrics(profile, functionName string, startedAt time.Time) metricSignal {
+	ctx, cancel := context.WithTimeout(context.Background(), asyncPollInterval)
+	defer cancel()
+	start := startedAt.Truncate(time.Minute)
+	end := start.Add(time.Minute)
+	dims := map[string]string{"FunctionName": functionName}
+	invocations, err := queryMetricSum(ctx, profile, &metricCheck{Namespace: "AWS/Lambda", Name: "Invocations"}, dims, start, end)
+	if err != nil {
+		return metricsUnavailable
+	}
+	if invocations == 0 {
+		return metricsNone
+	}
+	if errs, err := queryMetricSum(ctx, profile, &metricCheck{Namespace: "AWS/Lambda", Name: "Errors"}, dims, start, end); err == nil && errs > 0 {
+		return metricsErrored
+	}
+	return metricsInvoked
+}
+
+// pollAsyncCompletion fetches the logs of an async invocation until its
+// REPORT line shows up, giving up once it can't be running anymore. While
+// it is pending, changes of its status are reported to onStatus (if set).
+func pollAsyncCompletion(profile, functionName, requestID string, startedAt time.Time, timeout time.Duration, onStatus func(status string)) (string, error) {
+	deadline := startedAt.Add(timeout + asyncPollGrace)
+	status := ""
+	for {
+		time.Sleep(asyncPollInterval)
+		logs, err := fetchRequestLogs(profile, functionName, requestID, startedAt)
+		if err == nil && strings.Contains(logs, "REPORT RequestId: "+requestID) {
+			return logs, nil
+		}
+		if onStatus != nil {
+			signal, metrics := requestLogSignal(logs, err), metricsUnavailable
+			if signal != logsStarted {
+				metrics = invocationMetrics(profile, functionName, startedAt)
+			}
+			if next := pendingStatus(signal, metrics); next != status {
+				status = next
+				onStatus(status)
+			}
+		}
+		if time.Now().After(deadline) {
+			if err != nil {
+				return logs, fmt.Errorf("no result for async request %s: %w", requestID, err)
+			}
+			return logs, fmt.Errorf("no result for async request %s after %s, it may still be queued or running", requestID, formatSpan(time.Since(startedAt)))
+		}
+	}
+}
+
+// invokeAsync queues an async invocation and polls its logs until it
+// finishes, filling in inv like invokeLambda and reporting its pending
+// status to onStatus
+func invokeAsync(ctx context.Context, client *lambda.Client, inv *invocation, payload []byte, onPhase func(phase, requestID string), onStatus func(status string)) error {
+	// Poll for as long as the function may run
+	timeout := 15 * time.Minute
+	fn, err := client.GetFunctionConfiguration(ctx, &lambda.GetFunctionConfigurationInput{
+		FunctionName: aws.String(inv.FunctionName),
+	})
+	if err == nil && fn.Timeout != nil {
+		timeout = time.Duration(*fn.Timeout) * time.Second
+	}
+
+	inv.StartedAt = time.Now()
+	requestID, err := invokeFunctionAsync(ctx, client, inv.FunctionName, payload)
+	if err != nil {
+		inv.Duration = time.Since(inv.StartedAt)
+		return fmt.Errorf("failed to invoke Lambda: %w", err)
+	}
+	inv.RequestID = requestID
+	// A resumed session finds the outcome in the logs, same as for sync invokes
+	onPhase(phaseCompleted, requestID)
+
+	inv.Logs, err = pollAsyncCompletion(profileMap[inv.Env], inv.FunctionName, requestID, inv.StartedAt, timeout, onStatus)
+	inv.Duration = time.Since(inv.StartedAt)
+	if err != nil {
+		return err
+	}
+	inv.FunctionError = asyncFunctionError(inv.Logs)
+	return nil
+}
+
+// asyncFunctionError reports how an async invocation failed according to its
+// logs, empty when it didn't
+func asyncFunctionError(logs string) string {
+	switch {
+	case strings.Contains(logs, "Task timed out"):
+		return "Task timed out"
+	case strings.Contains(logs, "Status: error"):
+		return "Unhandled"
+	}
+	return ""
+}
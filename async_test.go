@@ -0,0 +1,61 @@
+package main
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestPendingStatus(t *testing.T) {
+	const (
+		running        = "running, logs are coming in"
+		executed       = "function executed, awaiting logs"
+		executedErrors = "function executed with errors, awaiting logs"
+		notYet         = "no execution recorded yet"
+	)
+	tests := []struct {
+		logs    logSignal
+		metrics metricSignal
+		want    string
+	}{
+		// Logs showing up settle it, whatever the metrics say
+		{logsStarted, metricsUnavailable, running},
+		{logsStarted, metricsNone, running},
+		{logsStarted, metricsInvoked, running},
+		{logsStarted, metricsErrored, running},
+		// Until then the metrics stand in for the lagging logs
+		{logsEmpty, metricsNone, notYet},
+		{logsEmpty, metricsInvoked, executed},
+		{logsEmpty, metricsErrored, executedErrors},
+		{logsUnavailable, metricsNone, notYet},
+		{logsUnavailable, metricsInvoked, executed},
+		{logsUnavailable, metricsErrored, executedErrors},
+		// And with neither there's nothing to go on
+		{logsEmpty, metricsUnavailable, "unknown, nothing logged yet"},
+		{logsUnavailable, metricsUnavailable, "unknown, neither the logs nor the metrics could be fetched"},
+	}
+	for _, tt := range tests {
+		if got := pendingStatus(tt.logs, tt.metrics); got != tt.want {
+			t.Errorf("pendingStatus(%d, %d) = %q, want %q", tt.logs, tt.metrics, got, tt.want)
+		}
+	}
+}
+
+func TestRequestLogSignal(t *testing.T) {
+	tests := []struct {
+		logs string
+		err  error
+		want logSignal
+	}{
+		{"", errors.New("throttled"), logsUnavailable},
+		// Logs fetched before failing don't count
+		{"START RequestId: req-1\n", errors.New("throttled"), logsUnavailable},
+		{"", nil, logsEmpty},
+		{" \n\t\n", nil, logsEmpty},
+		{"START RequestId: req-1\n", nil, logsStarted},
+	}
+	for _, tt := range tests {
+		if got := requestLogSignal(tt.logs, tt.err); got != tt.want {
+			t.Errorf("requestLogSignal(%q, %v) = %d, want %d", tt.logs, tt.err, got, tt.want)
+		}
+	}
+}
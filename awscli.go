@@ -0,0 +1,106 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+)
+
+// printCLI prints the equivalent aws CLI command after an invocation, set
+// with --print-cli
+var printCLI bool
+
+// shellSafeRe matches words that need no quoting in a POSIX shell
+var shellSafeRe = regexp.MustCompile(`^[a-zA-Z0-9_@%+=:,./-]+$`)
+
+// shellQuote quotes a word for a POSIX shell
+func shellQuote(s string) string {
+	if shellSafeRe.MatchString(s) {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// awsCLICommand is the aws CLI command making the same call as inv, with
+// the payload as sent, after any transform. The payload is base64 as the
+// aws CLI v2 expects it, so no quoting can change it.
+func awsCLICommand(inv *invocation) (string, error) {
+	if inv.URL != "" {
+		return "", errors.New("the tool is invoked over http, not with lambda invoke")
+	}
+	payload := inv.SentPayload
+	if payload == nil {
+		var err error
+		if payload, err = json.Marshal(inv.Payload); err != nil {
+			return "", err
+		}
+	}
+	function := inv.FunctionName
+	if function == "" {
+		function = sweepstakeFunction(inv.Env)
+	}
+	args := []string{"aws", "lambda", "invoke",
+		"--function-name", function,
+		"--payload", base64.StdEncoding.EncodeToString(payload),
+	}
+	if inv.Mode == modeAsync {
+		args = append(args, "--invocation-type", "Event")
+	} else {
+		// A sync call lasts as long as the function runs, longer than the
+		// CLI's default read timeout
+		args = append(args, "--log-type", "Tail", "--cli-read-timeout", "0")
+	}
+	args = append(args, "--profile", profileMap[inv.Env])
+	if region := regionMap[inv.Env]; region != "" {
+		args = append(args, "--region", region)
+	}
+	args = append(args, "response.json")
+	for i, a := range args {
+		args[i] = shellQuote(a)
+	}
+	return strings.Join(args, " "), nil
+}
+
+// clipboardCommands are tried in order to copy text
+var clipboardCommands = [][]string{
+	{"pbcopy"},
+	{"wl-copy"},
+	{"xclip", "-selection", "clipboard"},
+	{"xsel", "--clipboard", "--input"},
+	{"clip.exe"},
+}
+
+// copyToClipboard copies text with the first clipboard command found, or
+// with an OSC 52 escape the terminal copies from, which works over SSH
+func copyToClipboard(text string) error {
+	for _, c := range clipboardCommands {
+		if _, err := exec.LookPath(c[0]); err != nil {
+			continue
+		}
+		cmd := exec.Command(c[0], c[1:]...)
+		cmd.Stdin = strings.NewReader(text)
+		if err := cmd.Run(); err == nil {
+			return nil
+		}
+	}
+	_, err := fmt.Fprintf(os.Stdout, "\x1b]52;c;%s\x07", base64.StdEncoding.EncodeToString([]byte(text)))
+	return err
+}
+
+// copyAWSCLI copies the last invocation's aws CLI command, returning the
+// output screen message
+func (m model) copyAWSCLI() string {
+	command, err := awsCLICommand(m.lastInvocation)
+	if err != nil {
+		return fmt.Sprintf("No aws CLI command: %v", err)
+	}
+	if err := copyToClipboard(command); err != nil {
+		return fmt.Sprintf("Copy failed (%v), the command is:\n%s", err, command)
+	}
+	return "Copied the aws CLI command:\n" + command
+}
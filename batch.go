@@ -0,0 +1,412 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/revrost/playtools/pkg/playtools"
+)
+
+// batchColumns are the columns of a batch file. Only quest_id is required.
+var batchColumns = []string{"quest_id", "batch_size", "dry_run"}
+
+// batchRow is one validated row of a batch file
+type batchRow struct {
+	Line      int
+	QuestID   int
+	BatchSize *int
+	DryRun    bool
+}
+
+func (r batchRow) payload() EventPayload {
+	payload := playtools.NewPayload(ActionProcess, r.QuestID)
+	payload.BatchSize = r.BatchSize
+	payload.DryRun = r.DryRun
+	return payload
+}
+
+// batchStatus is where a batch row is at
+type batchStatus string
+
+const (
+	batchPending   batchStatus = "pending"
+	batchRunning   batchStatus = "running"
+	batchSucceeded batchStatus = "succeeded"
+	batchFailed    batchStatus = "failed"
+	// batchCancelled rows were never attempted because the batch was stopped
+	batchCancelled batchStatus = "skipped-cancelled"
+)
+
+// batchStatuses is the order statuses are counted in
+var batchStatuses = []batchStatus{batchSucceeded, batchFailed, batchCancelled, batchRunning, batchPending}
+
+// batchResult is the outcome of invoking one batch row
+type batchResult struct {
+	Row       batchRow
+	Status    batchStatus
+	RequestID string
+	Duration  time.Duration
+	Err       string
+}
+
+// newBatchResults starts every row of a batch out as pending
+func newBatchResults(rows []batchRow) []batchResult {
+	results := make([]batchResult, len(rows))
+	for i, row := range rows {
+		results[i] = batchResult{Row: row, Status: batchPending}
+	}
+	return results
+}
+
+// cancelBatch marks the rows that haven't been attempted as skipped
+func cancelBatch(results []batchResult) {
+	for i := range results {
+		if results[i].Status == batchPending {
+			results[i].Status = batchCancelled
+		}
+	}
+}
+
+// readBatchFile reads and validates a batch file
+func readBatchFile(path string) ([]batchRow, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return parseBatch(f)
+}
+
+// parseBatch validates every row of a batch CSV up front, reporting all
+// problems with their line numbers rather than stopping at the first one
+func parseBatch(r io.Reader) ([]batchRow, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+
+	header, err := cr.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, errors.New("batch file is empty")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read batch file: %v", err)
+	}
+	index := map[string]int{}
+	for i, name := range header {
+		index[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	if _, ok := index["quest_id"]; !ok {
+		return nil, fmt.Errorf("line 1: missing quest_id column (expected %s)", strings.Join(batchColumns, ","))
+	}
+
+	var rows []batchRow
+	var problems []string
+	seen := map[int]int{}
+	for {
+		record, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			problems = append(problems, err.Error())
+			continue
+		}
+		line, _ := cr.FieldPos(0)
+		field := func(name string) string {
+			if i, ok := index[name]; ok && i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+
+		row := batchRow{Line: line}
+		var rowProblems []string
+		if n, err := strconv.Atoi(field("quest_id")); err != nil || n <= 0 {
+			rowProblems = append(rowProblems, fmt.Sprintf("quest_id %q is not a positive number", field("quest_id")))
+		} else if first, dup := seen[n]; dup {
+			rowProblems = append(rowProblems, fmt.Sprintf("quest_id %d is already on line %d", n, first))
+		} else {
+			row.QuestID = n
+			seen[n] = line
+		}
+		if s := field("batch_size"); s != "" {
+			if n, err := strconv.Atoi(s); err != nil || n <= 0 {
+				rowProblems = append(rowProblems, fmt.Sprintf("batch_size %q is not a positive number", s))
+			} else if limit := toolRegistry[sweepstakeTool].MaxBatchSize; n > limit {
+				rowProblems = append(rowProblems, fmt.Sprintf("batch_size %d is over the cap of %d", n, limit))
+			} else {
+				row.BatchSize = &n
+			}
+		}
+		if s := field("dry_run"); s != "" {
+			if b, err := strconv.ParseBool(s); err != nil {
+				rowProblems = append(rowProblems, fmt.Sprintf("dry_run %q is not true or false", s))
+			} else {
+				row.DryRun = b
+			}
+		}
+
+		for _, p := range rowProblems {
+			problems = append(problems, fmt.Sprintf("line %d: %s", line, p))
+		}
+		if len(rowProblems) == 0 {
+			rows = append(rows, row)
+		}
+	}
+
+	if len(problems) > 0 {
+		return nil, fmt.Errorf("batch file has %d problem(s):\n%s", len(problems), strings.Join(problems, "\n"))
+	}
+	if len(rows) == 0 {
+		return nil, errors.New("batch file has no rows")
+	}
+	return rows, nil
+}
+
+// maxQuestList caps how many quests a typed list or range runs, so a typo
+// like 100-1000 doesn't start a thousand invocations
+const maxQuestList = 200
+
+// isQuestList reports whether the quest ID prompt holds a list or range of
+// quest IDs rather than one
+func isQuestList(s string) bool {
+	return strings.ContainsAny(s, ",-")
+}
+
+// parseQuestList reads a list of quest IDs and ranges like "101,102,110-115",
+// reporting every entry that isn't one
+func parseQuestList(s string) ([]int, error) {
+	var ids []int
+	var problems []string
+	seen := map[int]bool{}
+	add := func(id int) {
+		if seen[id] {
+			problems = append(problems, fmt.Sprintf("quest %d is listed twice", id))
+			return
+		}
+		seen[id] = true
+		ids = append(ids, id)
+	}
+	for _, entry := range strings.Split(s, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		from, to, isRange := strings.Cut(entry, "-")
+		first, err := parsePositive(strings.TrimSpace(from))
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("%q is not a quest ID or a range like 110-115", entry))
+			continue
+		}
+		last := first
+		if isRange {
+			if last, err = parsePositive(strings.TrimSpace(to)); err != nil || last < first {
+				problems = append(problems, fmt.Sprintf("%q is not a range like 110-115", entry))
+				continue
+			}
+			if last-first >= maxQuestList {
+				problems = append(problems, fmt.Sprintf("%q is over %d quests", entry, maxQuestList))
+				continue
+			}
+		}
+		for id := first; id <= last; id++ {
+			add(id)
+		}
+	}
+	switch {
+	case len(problems) > 0:
+		return nil, errors.New(strings.Join(problems, "; "))
+	case len(ids) == 0:
+		return nil, errors.New("no quest IDs in the list")
+	case len(ids) > maxQuestList:
+		return nil, fmt.Errorf("%d quests is over the %d a list runs", len(ids), maxQuestList)
+	}
+	return ids, nil
+}
+
+// questListRows turns a list of quest IDs into batch rows, numbered by their
+// place in the list
+func questListRows(ids []int, batchSize *int, dryRun bool) []batchRow {
+	rows := make([]batchRow, len(ids))
+	for i, id := range ids {
+		rows[i] = batchRow{Line: i + 1, QuestID: id, BatchSize: batchSize, DryRun: dryRun}
+	}
+	return rows
+}
+
+// questListResultsPath is where the results of a typed list are written,
+// there being no batch file to put them next to
+func questListResultsPath(now time.Time) (string, error) {
+	dir, err := dataDir()
+	if err != nil {
+		return "", err
+	}
+	return batchResultsPath(filepath.Join(dir, "batches", "quests.csv"), now), nil
+}
+
+// runBatchRow invokes a single batch row, recording it in the history
+func runBatchRow(env string, row batchRow) batchResult {
+	inv := &invocation{Env: env, Payload: row.payload()}
+	// A batch only gets past the approval gate with --break-glass
+	if approvalRequired(env, inv.Payload) && breakGlassReason != "" {
+		inv.Approval = breakGlassGrant()
+	}
+	err := invokeLambda(context.Background(), inv, nil, nil)
+	finishInvocation(inv, err)
+
+	res := batchResult{Row: row, Status: batchSucceeded, RequestID: inv.RequestID, Duration: inv.Duration}
+	switch {
+	case err != nil:
+		res.Status, res.Err = batchFailed, err.Error()
+	case inv.FunctionError != "":
+		res.Status, res.Err = batchFailed, "function error: "+functionErrorMessage(inv)
+	}
+	return res
+}
+
+// batchRowMsg is sent when a batch row has been invoked
+type batchRowMsg struct {
+	index  int
+	result batchResult
+}
+
+func runBatchRowCmd(env string, row batchRow, index int) tea.Cmd {
+	return func() tea.Msg {
+		return batchRowMsg{index: index, result: runBatchRow(env, row)}
+	}
+}
+
+// batchResultsPath names the results file written next to a batch file
+func batchResultsPath(batchPath string, now time.Time) string {
+	base := strings.TrimSuffix(batchPath, filepath.Ext(batchPath))
+	return fmt.Sprintf("%s-results-%s.csv", base, now.UTC().Format("20060102T150405Z"))
+}
+
+// writeBatchResults writes the status of every row. It is called after each
+// row so an interrupted batch still leaves a record.
+func writeBatchResults(path string, results []batchResult) error {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	_ = w.Write([]string{"line", "quest_id", "batch_size", "dry_run", "status", "request_id", "duration_ms", "error"})
+	for _, r := range results {
+		batchSize := ""
+		if r.Row.BatchSize != nil {
+			batchSize = strconv.Itoa(*r.Row.BatchSize)
+		}
+		_ = w.Write([]string{
+			strconv.Itoa(r.Row.Line),
+			strconv.Itoa(r.Row.QuestID),
+			batchSize,
+			strconv.FormatBool(r.Row.DryRun),
+			string(r.Status),
+			r.RequestID,
+			strconv.FormatInt(r.Duration.Milliseconds(), 10),
+			r.Err,
+		})
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return err
+	}
+	return writeFileAtomic(path, buf.Bytes(), 0o644)
+}
+
+// batchCounts counts the rows of a batch by status
+func batchCounts(results []batchResult) map[batchStatus]int {
+	counts := map[batchStatus]int{}
+	for _, r := range results {
+		counts[r.Status]++
+	}
+	return counts
+}
+
+// batchIncomplete reports whether any row failed or wasn't attempted
+func batchIncomplete(results []batchResult) bool {
+	return batchCounts(results)[batchSucceeded] < len(results)
+}
+
+// batchSummary counts the rows of a batch by status, like
+// "12 quest(s): 4 succeeded, 1 failed, 7 skipped-cancelled"
+func batchSummary(results []batchResult) string {
+	counts := batchCounts(results)
+	var parts []string
+	for _, status := range batchStatuses {
+		if n := counts[status]; n > 0 || status == batchSucceeded || status == batchFailed {
+			parts = append(parts, fmt.Sprintf("%d %s", n, status))
+		}
+	}
+	return fmt.Sprintf("%d quest(s): %s", len(results), strings.Join(parts, ", "))
+}
+
+// previousBatchResults finds the latest results file of a batch file,
+// returning its path and the status of each quest in it
+func previousBatchResults(batchPath string) (string, map[int]batchStatus, error) {
+	base := strings.TrimSuffix(batchPath, filepath.Ext(batchPath))
+	entries, err := os.ReadDir(filepath.Dir(base))
+	if err != nil {
+		return "", nil, err
+	}
+	prefix := filepath.Base(base) + "-results-"
+	var latest string
+	for _, e := range entries {
+		// The timestamp in the name sorts chronologically
+		if name := e.Name(); strings.HasPrefix(name, prefix) && strings.HasSuffix(name, ".csv") && name > latest {
+			latest = name
+		}
+	}
+	if latest == "" {
+		return "", nil, nil
+	}
+	latest = filepath.Join(filepath.Dir(base), latest)
+
+	f, err := os.Open(latest)
+	if err != nil {
+		return "", nil, err
+	}
+	defer f.Close()
+	records, err := csv.NewReader(f).ReadAll()
+	if err != nil || len(records) == 0 {
+		return "", nil, fmt.Errorf("failed to read %s: %v", latest, err)
+	}
+	index := map[string]int{}
+	for i, name := range records[0] {
+		index[name] = i
+	}
+	statusCol, ok := index["status"]
+	questCol, hasQuest := index["quest_id"]
+	if !ok || !hasQuest {
+		return "", nil, fmt.Errorf("%s has no quest_id and status columns", latest)
+	}
+	statuses := map[int]batchStatus{}
+	for _, record := range records[1:] {
+		if len(record) <= max(statusCol, questCol) {
+			continue
+		}
+		if id, err := strconv.Atoi(record[questCol]); err == nil {
+			statuses[id] = batchStatus(record[statusCol])
+		}
+	}
+	return latest, statuses, nil
+}
+
+// batchRetrySubset picks the rows a previous run didn't get through, the
+// ones that failed or were never attempted
+func batchRetrySubset(rows []batchRow, previous map[int]batchStatus) []batchRow {
+	var subset []batchRow
+	for _, row := range rows {
+		if previous[row.QuestID] != batchSucceeded {
+			subset = append(subset, row)
+		}
+	}
+	return subset
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// prodDailyLimit is the soft cap on mutating prod invocations per UTC day, set
+// with budget.prod_daily_limit in the config file. Zero means no cap.
+var prodDailyLimit int
+
+// budgetAckPhrase has to be typed to invoke prod once the budget is used up
+const budgetAckPhrase = "over budget"
+
+// prodBudget is today's use of the prod invocation budget
+type prodBudget struct {
+	Limit int
+	Used  int
+}
+
+// mutates reports whether a payload changes anything when invoked
+func mutates(payload EventPayload) bool {
+	return !payload.DryRun && payload.Action != ActionStatus
+}
+
+// countsTowardsBudget reports whether a history record used up prod budget.
+// Invocations that failed before reaching the function don't count.
+func countsTowardsBudget(rec historyRecord) bool {
+	if !isProtected(rec.Env) || rec.DryRun || rec.Action == ActionStatus {
+		return false
+	}
+	return rec.RequestID != "" || !rec.failed()
+}
+
+// computeProdBudget counts today's mutating prod invocations in UTC. Records
+// dated after now come from a machine with a clock running ahead and count as
+// today, and records synced from several machines are counted once.
+func computeProdBudget(records []historyRecord, now time.Time) prodBudget {
+	budget := prodBudget{Limit: prodDailyLimit}
+	today := now.UTC().Format(time.DateOnly)
+	seen := map[string]bool{}
+	for _, rec := range records {
+		if !countsTowardsBudget(rec) {
+			continue
+		}
+		t := rec.Time
+		if t.After(now) {
+			t = now
+		}
+		if t.UTC().Format(time.DateOnly) != today {
+			continue
+		}
+		key := rec.RequestID
+		if key == "" {
+			key = fmt.Sprintf("%s|%s|%s|%d", rec.Time.UTC().Format(time.RFC3339Nano), rec.Operator, rec.Action, derefInt(rec.QuestID))
+		}
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		budget.Used++
+	}
+	return budget
+}
+
+func derefInt(p *int) int {
+	if p == nil {
+		return 0
+	}
+	return *p
+}
+
+// loadProdBudget counts today's prod invocations from the history file,
+// reading only the records dated today or later
+func loadProdBudget() prodBudget {
+	now := time.Now()
+	today := now.UTC().Truncate(24 * time.Hour)
+	records, _ := filteredHistory(historyFilter{since: today})
+	return computeProdBudget(records, now)
+}
+
+// budgetMsg carries a recount of the prod invocation budget
+type budgetMsg struct {
+	budget prodBudget
+}
+
+func loadProdBudgetCmd() tea.Cmd {
+	return func() tea.Msg {
+		return budgetMsg{budget: loadProdBudget()}
+	}
+}
+
+func (b prodBudget) enabled() bool {
+	return b.Limit > 0
+}
+
+// warn is true once 80% of the budget is used
+func (b prodBudget) warn() bool {
+	return b.enabled() && b.Used*5 >= b.Limit*4
+}
+
+// exceededBy reports whether n more invocations would go over the budget
+func (b prodBudget) exceededBy(n int) bool {
+	return b.enabled() && n > 0 && b.Used+n > b.Limit
+}
+
+func (b prodBudget) String() string {
+	return fmt.Sprintf("prod budget: %d/%d used today", b.Used, b.Limit)
+}
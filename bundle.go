@@ -0,0 +1,117 @@
+package main
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+// redactionRules are applied to every text file written into an exported bundle
+var redactionRules = []struct {
+	pattern     *regexp.Regexp
+	replacement string
+}{
+	// AWS access key IDs
+	{regexp.MustCompile(`\b(AKIA|ASIA)[A-Z0-9]{16}\b`), "[REDACTED_ACCESS_KEY]"},
+	// Secret keys and session tokens in key=value or "key": "value" form,
+	// keeping the scheme of an authorization header but not its credentials
+	{regexp.MustCompile(`(?i)((?:secret_?access_?key|session_?token|password|authorization)["']?\s*[:=]\s*["']?(?:(?:bearer|basic)\s+)?)[^\s"',]+`), "${1}[REDACTED]"},
+}
+
+func redact(s string) string {
+	for _, rule := range redactionRules {
+		s = rule.pattern.ReplaceAllString(s, rule.replacement)
+	}
+	return s
+}
+
+// bundleMetadata is written as metadata.json in an exported bundle
+type bundleMetadata struct {
+	Env           string    `json:"env"`
+	FunctionName  string    `json:"function_name"`
+	RequestID     string    `json:"request_id,omitempty"`
+	FunctionError string    `json:"function_error,omitempty"`
+	StartedAt     time.Time `json:"started_at"`
+	DurationMS    int64     `json:"duration_ms"`
+	Mode          string    `json:"mode,omitempty"`
+	ModeReason    string    `json:"mode_reason,omitempty"`
+	Identity      *envInfo  `json:"identity,omitempty"`
+	ExportedAt    time.Time `json:"exported_at"`
+	// Playtools is the build that made the invocation and the export
+	Playtools buildMetadata `json:"playtools"`
+}
+
+// bundleName names a bundle by env/action/quest/timestamp
+func bundleName(inv *invocation, now time.Time) string {
+	quest := "new"
+	if inv.Payload.SweepstakeQuestID != nil {
+		quest = strconv.Itoa(*inv.Payload.SweepstakeQuestID)
+	}
+	return fmt.Sprintf("playtools-%s-%s-%s-%s.zip", inv.Env, inv.Payload.Action, quest, now.UTC().Format("20060102T150405Z"))
+}
+
+// writeBundle writes a zip with everything about an invocation into dir,
+// returning the path of the written file
+func writeBundle(dir string, inv *invocation, logs string, identity *envInfo, now time.Time) (string, error) {
+	payload, err := json.MarshalIndent(inv.Payload, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	response := inv.Response
+	if formatted, err := indentJSON(inv.Response); err == nil {
+		response = formatted
+	}
+	metadata, err := json.MarshalIndent(bundleMetadata{
+		Env:           inv.Env,
+		FunctionName:  inv.FunctionName,
+		RequestID:     inv.RequestID,
+		FunctionError: inv.FunctionError,
+		StartedAt:     inv.StartedAt,
+		DurationMS:    inv.Duration.Milliseconds(),
+		Mode:          inv.Mode,
+		ModeReason:    inv.ModeReason,
+		Identity:      identity,
+		ExportedAt:    now,
+		Playtools:     readBuildMetadata(),
+	}, "", "  ")
+	if err != nil {
+		return "", err
+	}
+
+	path := filepath.Join(dir, bundleName(inv, now))
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+	if err != nil {
+		return "", fmt.Errorf("failed to create bundle: %v", err)
+	}
+	defer f.Close()
+
+	zw := zip.NewWriter(f)
+	files := []struct {
+		name    string
+		content []byte
+	}{
+		{"payload.json", payload},
+		{"response.json", response},
+		{"logs.txt", []byte(logs)},
+		{"metadata.json", metadata},
+	}
+	for _, file := range files {
+		// Use the invocation time so bundles of the same run are identical
+		w, err := zw.CreateHeader(&zip.FileHeader{Name: file.name, Method: zip.Deflate, Modified: inv.StartedAt})
+		if err != nil {
+			return "", fmt.Errorf("failed to write bundle: %v", err)
+		}
+		if _, err := w.Write([]byte(redact(string(file.content)))); err != nil {
+			return "", fmt.Errorf("failed to write bundle: %v", err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return "", fmt.Errorf("failed to write bundle: %v", err)
+	}
+	return path, f.Close()
+}
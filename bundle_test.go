@@ -0,0 +1,123 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/revrost/playtools/pkg/playtools"
+)
+
+func readFixture(t *testing.T, name string) []byte {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+// readBundle is the content of every file of a bundle, in zip order
+func readBundle(t *testing.T, path string) ([]string, map[string][]byte) {
+	t.Helper()
+	zr, err := zip.OpenReader(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer zr.Close()
+	var names []string
+	files := map[string][]byte{}
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, f.Name)
+		files[f.Name] = data
+	}
+	return names, files
+}
+
+func TestWriteBundle(t *testing.T) {
+	started := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	now := started.Add(time.Hour)
+	payload := playtools.NewPayload(ActionComplete, 42)
+	payload.IdempotencyKey = "5f0c2a52-8a7e-4d0b-9b3e-6c1f0a4d2e91"
+	inv := &invocation{
+		Env:          devEnv,
+		FunctionName: "sweepstake-dev",
+		Payload:      payload,
+		RequestID:    "req-42",
+		Response:     readFixture(t, "bundle/response.json"),
+		StartedAt:    started,
+		Duration:     1500 * time.Millisecond,
+		Mode:         modeSync,
+	}
+	identity := &envInfo{Account: "123456789012", Arn: "arn:aws:sts::123456789012:assumed-role/dev/ops"}
+	logs := string(readFixture(t, "bundle/logs.txt"))
+
+	dir := t.TempDir()
+	path, err := writeBundle(dir, inv, logs, identity, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := filepath.Join(dir, "playtools-dev-complete-42-20240501T110000Z.zip"); path != want {
+		t.Errorf("wrote %s, want %s", path, want)
+	}
+
+	names, files := readBundle(t, path)
+	if want := []string{"payload.json", "response.json", "logs.txt", "metadata.json"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("bundle has %v, want %v", names, want)
+	}
+	var sent EventPayload
+	if err := json.Unmarshal(files["payload.json"], &sent); err != nil || !reflect.DeepEqual(sent, payload) {
+		t.Errorf("payload.json is %s (%v)", files["payload.json"], err)
+	}
+	if want := bytes.TrimSpace(readFixture(t, "bundle/response.redacted.json")); !bytes.Equal(files["response.json"], want) {
+		t.Errorf("response.json is\n%s\nwant\n%s", files["response.json"], want)
+	}
+	if want := readFixture(t, "bundle/logs.redacted.txt"); !bytes.Equal(files["logs.txt"], want) {
+		t.Errorf("logs.txt is\n%s\nwant\n%s", files["logs.txt"], want)
+	}
+
+	var meta bundleMetadata
+	if err := json.Unmarshal(files["metadata.json"], &meta); err != nil {
+		t.Fatal(err)
+	}
+	if meta.Env != devEnv || meta.RequestID != "req-42" || meta.DurationMS != 1500 || !meta.StartedAt.Equal(started) ||
+		!meta.ExportedAt.Equal(now) || meta.Identity == nil || meta.Identity.Account != "123456789012" {
+		t.Errorf("unexpected metadata %+v", meta)
+	}
+
+	// The same run exports to the same bytes, and an export never overwrites
+	again, err := writeBundle(t.TempDir(), inv, logs, identity, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	first, _ := os.ReadFile(path)
+	second, _ := os.ReadFile(again)
+	if !bytes.Equal(first, second) {
+		t.Error("two exports of the same run differ")
+	}
+	if _, err := writeBundle(dir, inv, logs, identity, now); err == nil {
+		t.Error("overwrote an existing bundle")
+	}
+}
+
+func TestBundleNameWithoutQuest(t *testing.T) {
+	inv := &invocation{Env: prodEnv, Payload: playtools.NewPayload(ActionStart, 60)}
+	if got := bundleName(inv, time.Date(2024, 5, 1, 10, 0, 0, 0, time.FixedZone("CEST", 2*3600))); got != "playtools-prod-start-new-20240501T080000Z.zip" {
+		t.Errorf("named %s", got)
+	}
+}
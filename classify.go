@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/aws/smithy-go"
+	"github.com/revrost/playtools/pkg/playtools"
+)
+
+// errorClass is a classified invocation failure with a remediation hint
+type errorClass struct {
+	Title   string
+	Message string
+	Hint    string
+}
+
+// apiErrorHints are the remediation hints of the Lambda API errors the
+// playtools package classifies
+var apiErrorHints = map[playtools.ErrorKind]string{
+	playtools.KindFunctionNotFound:    "The function doesn't exist in this account/region. Check the environment and profile.",
+	playtools.KindAccessDenied:        "Your role isn't allowed to invoke this function. Check you're using the right profile.",
+	playtools.KindCredentialsRejected: "Your session expired or is invalid. Run `aws sso login` and retry.",
+	playtools.KindThrottled:           "The function is at its concurrency limit. Wait a moment and retry.",
+	playtools.KindInvalidPayload:      "Edit the payload and try again.",
+}
+
+// classifyError works out what kind of failure an invocation hit. A nil err
+// with a FunctionError on inv is classified as a function error.
+func classifyError(err error, inv *invocation) errorClass {
+	if err == nil && inv != nil && inv.FunctionError != "" {
+		return errorClass{
+			Title:   string(playtools.KindFunctionError),
+			Message: functionErrorMessage(inv),
+			Hint:    "The lambda ran but failed. Check the logs for the stack trace.",
+		}
+	}
+	if err == nil {
+		return errorClass{}
+	}
+
+	msg := err.Error()
+	class := errorClass{Title: "Invocation failed", Message: msg, Hint: "Run the doctor checks to verify your setup."}
+
+	var apiErr smithy.APIError
+	var statusErr *httpStatusError
+	skew, skewed := skewedClock()
+	switch {
+	case strings.Contains(msg, "AWS CLI not found"):
+		class.Title = "AWS CLI missing"
+		class.Hint = "Install the AWS CLI v2 and make sure `aws` is on your PATH."
+	case strings.Contains(msg, "SSO login failed"), strings.Contains(msg, "SSO session expired and login failed"):
+		class.Title = "SSO login failed"
+		class.Hint = "Run `aws sso login --profile <profile>` manually and check the profile in ~/.aws/config."
+	case strings.Contains(msg, "failed to load AWS config"):
+		class.Title = "AWS config error"
+		class.Hint = "Check that the profile exists in ~/.aws/config."
+	case skewed && signatureRejected(err):
+		class.Title = "Clock skew"
+		class.Message = fmt.Sprintf("AWS rejected the request's signature, and your clock is %s AWS according to the response's Date header. SigV4 signatures are only accepted within %s.", describeSkew(skew), formatTimeout(maxClockSkew))
+		class.Hint = clockSyncHint
+	case errors.Is(err, context.DeadlineExceeded):
+		class.Title = string(playtools.KindTimedOut)
+		class.Hint = "The lambda may still be running. Check the logs before retrying."
+	case errors.As(err, &apiErr):
+		class.Message = fmt.Sprintf("%s: %s", apiErr.ErrorCode(), apiErr.ErrorMessage())
+		kind := playtools.Classify(nil, err)
+		if hint, ok := apiErrorHints[kind]; ok {
+			class.Title, class.Hint = string(kind), hint
+		}
+	case errors.As(err, &statusErr):
+		switch code := statusErr.StatusCode; {
+		case code == 401 || code == 403:
+			class.Title = "Access denied"
+			class.Hint = "The endpoint rejected your credentials. Run `aws sso login` and check you're using the right profile."
+		case code == 404:
+			class.Title = "Endpoint not found"
+			class.Hint = "Check the tool's URL for this environment in the config file."
+		case code == 429:
+			class.Title = "Throttled"
+			class.Hint = "The endpoint is rate limiting. Wait a moment and retry."
+		default:
+			class.Title = "Error response"
+			class.Hint = "The endpoint returned an error status. Check the response body."
+		}
+	case playtools.Classify(nil, err) == playtools.KindErrorResponse:
+		class.Title = string(playtools.KindErrorResponse)
+		class.Hint = "The lambda returned an error status. Check the response body and logs."
+	}
+	return class
+}
+
+// exitCode is the command line exit code of a classified failure, telling
+// a pipeline a failing function from failing credentials and bad input
+func (c errorClass) exitCode() int {
+	switch c.Title {
+	case "":
+		return 0
+	case "Function error":
+		return exitFunctionError
+	case "SSO login failed", "AWS config error", "Credentials rejected", "Access denied", "Clock skew":
+		return exitAuth
+	case "Invalid payload":
+		return exitUsage
+	}
+	return exitFailure
+}
+
+// failureLine is the last line a failed headless run prints to stderr,
+// naming the class of the failure and the exit code on a single line
+func (c errorClass) failureLine() string {
+	return fmt.Sprintf("Error: %s: %s (exit %d)", c.Title, strings.Join(strings.Fields(c.Message), " "), c.exitCode())
+}
+
+// functionErrorMessage extracts errorType/errorMessage from a function error response
+func functionErrorMessage(inv *invocation) string {
+	return playtools.FunctionErrorMessage(inv.Body, inv.FunctionError)
+}
@@ -0,0 +1,1061 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/revrost/playtools/pkg/playtools"
+)
+
+const usageText = `Usage:
+  playtools [--pin-env env] [command]        restrict the session to one environment
+  playtools [--context name] [command]       use a context of the config file, a separate
+                                             deployment with its own history (Ctrl+O in the TUI)
+  playtools --serve :8099                    also serve the session as JSON and a read-only page
+  playtools --high-latency                   repaint less, for slow SSH links
+  playtools --debug                          write a debug log headed by the terminal size, colors,
+                                             TERM, OS and version, logging every resize
+  playtools                                  start the interactive TUI
+  playtools --plain                          prompt line by line instead of the TUI and stream the
+                                             progress, for flaky SSH sessions
+  playtools --env dev --action process       start the TUI on the action's prompt in the environment
+  playtools process  [quest-id] [flags]      process a sweepstake quest
+  playtools complete [quest-id] [flags]      complete a sweepstake quest
+  playtools start    [minutes]  [flags]      start a new sweepstake quest
+  playtools process --batch-file quests.csv  process every quest of a CSV (quest_id, batch_size, dry_run)
+  playtools run <name> [flags]               run a preset, or a bookmark in its environment, with the
+                                             action command's confirmations and flags
+  playtools invoke --action process --quest-id 42 [flags]
+  playtools invoke --payload-file payload.json
+                                             invoke without any prompt or confirmation, for scripts;
+                                             missing flags are a usage error, and changes to a
+                                             protected environment need --yes
+  playtools config init [--force]            ask for the environments, their AWS profiles and regions
+                                             and the function template, and write a commented config file
+  playtools config fmt [--check]             normalize the config file formatting, keeping comments
+  playtools config validate                  load the config and render its templates for every
+                                             environment, reporting every problem
+  playtools config show                      print each environment's profile, function and region,
+                                             marking built-in defaults and overrides
+  playtools version                          print the build version and check the config's min_version
+  playtools bugreport                        zip the debug log, latest crash report, redacted config
+                                             and environment info to attach to an issue
+  playtools history export [--since 7d] [--filter expr]
+                                             write the matching history records as JSONL, with
+                                             filters like the history screen's (H in the TUI)
+  playtools tool test <name> --set f=v ...   build a tool's payload from form fields without any AWS
+                                             calls, printing what would be sent
+  playtools doctor [--env name]              check the aws CLI, profiles, credentials and functions
+                                             of every environment, or just one
+  playtools tools [--env name] [--refresh]   list the tools discovered from Lambda function tags,
+                                             cached for discovery.ttl (T in the TUI)
+  playtools completion bash|zsh|fish         print a shell completion script, environments taken
+                                             from the config
+
+Flags:
+  --env string       environment to invoke: dev, nonprod or prod (default "dev")
+  --quest-id int     sweepstake quest ID for process/complete
+  --duration int     sweepstake duration in minutes for start
+  --action string    process, complete or start, for invoke
+  --output string    text or json, for invoke; json writes a single document with the env,
+                     function, payload sent, raw response, function error and logs (default "text")
+  --payload-file path  send a hand-written EventPayload JSON as is ("-" for stdin with invoke);
+                     unknown keys are rejected and the action must match the command
+  --dry-run          dry run process, or only validate start (default on for start in prod)
+  --batch-file path  CSV of quests to process one after another, writing a results CSV next to it
+  --fail-fast        stop a --batch-file at the first failed quest, skipping the rest
+  --mode string      auto, sync or async for process/complete; auto invokes async when the
+                     function timeout exceeds the async threshold (default "auto")
+  --idempotency-key  retry a complete with the key it was sent with, which the history
+                     records, instead of a fresh one
+  --override-lifecycle  process or complete a quest whose state the lifecycle config
+                     doesn't allow it in
+  --break-glass reason  skip the config's approval gate, recording the reason in the history
+  --print-cli        print the equivalent aws lambda invoke command after the result
+  --yes, --force     answer the confirmations yes, for automation; a check that needs a typed
+                     phrase refuses instead, and a complete in prod also needs
+                     --i-know-what-im-doing. The TUI never takes them
+  --print-payload    print the payload as it would be sent, with the function and profile,
+                     and exit without calling AWS at all
+
+Exit codes:
+  0 success, 1 other failure, 2 bad input, 3 function error, 4 SSO or credentials,
+  5 approval denied or timed out
+
+Environment:
+  PLAYTOOLS_PIN_ENV  same as --pin-env
+  PLAYTOOLS_CONTEXT  same as --context
+`
+
+// Exit codes of the command line, so a pipeline can tell why a run failed
+const (
+	exitFailure       = 1   // any other failure, or a declined confirmation
+	exitUsage         = 2   // bad flags, arguments or payload
+	exitFunctionError = 3   // the lambda ran and returned a function error
+	exitAuth          = 4   // SSO login, credentials or permissions
+	exitApproval      = 5   // the approval was denied, timed out or failed
+	exitInterrupted   = 130 // Ctrl+C cancelled the call, as a shell reports SIGINT
+)
+
+// pinEnvVar pins the session like --pin-env, handy for shell aliases
+const pinEnvVar = "PLAYTOOLS_PIN_ENV"
+
+// parseGlobalFlags handles flags that come before any command, setting the
+// environment pin and the context, and returns the remaining arguments
+func parseGlobalFlags(args []string) ([]string, error) {
+	fs := flag.NewFlagSet("playtools", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	pin := fs.String("pin-env", "", "")
+	fs.StringVar(&activeContext, "context", os.Getenv(contextEnvVar), "")
+	fs.StringVar(&serveAddr, "serve", "", "")
+	fs.BoolVar(&highLatency, "high-latency", false, "")
+	fs.BoolVar(&debugMode, "debug", false, "")
+	fs.BoolVar(&plainMode, "plain", false, "")
+	fs.StringVar(&breakGlassReason, "break-glass", "", "")
+	fs.StringVar(&startEnv, "env", "", "")
+	fs.StringVar(&startAction, "action", "", "")
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return []string{"help"}, nil
+		}
+		return nil, err
+	}
+
+	pinnedEnv = os.Getenv(pinEnvVar)
+	if isFlagSet(fs, "pin-env") {
+		if pinnedEnv != "" && *pin != pinnedEnv {
+			return nil, fmt.Errorf("--pin-env=%s conflicts with %s=%s", *pin, pinEnvVar, pinnedEnv)
+		}
+		if *pin == "" {
+			return nil, errors.New("--pin-env requires an environment")
+		}
+		pinnedEnv = *pin
+	}
+	if activeContext == defaultContext {
+		activeContext = ""
+	}
+	if serveAddr != "" && fs.NArg() > 0 {
+		return nil, errors.New("--serve only applies to the interactive TUI")
+	}
+	if plainMode && (fs.NArg() > 0 || serveAddr != "" || startEnv != "" || startAction != "") {
+		return nil, errors.New("--plain only replaces the interactive TUI, commands already print plain lines")
+	}
+	if (startEnv != "" || startAction != "") && fs.NArg() > 0 {
+		return nil, errors.New("--env and --action before a command only apply to the interactive TUI, pass them after the command")
+	}
+	// Starting elsewhere would be asking for what the pin is there to rule out
+	if pinnedEnv != "" && startEnv != "" && startEnv != pinnedEnv {
+		return nil, fmt.Errorf("--env %s conflicts with the pin, the session is pinned to %s", startEnv, pinnedEnv)
+	}
+	return fs.Args(), nil
+}
+
+// runCLI dispatches command line subcommands and returns the process exit code
+func runCLI(args []string) int {
+	switch args[0] {
+	case string(ActionProcess), string(ActionComplete), string(ActionStart):
+		return runActionCommand(Action(args[0]), args[1:], os.Stdin, os.Stdout)
+	case "invoke":
+		return runInvokeCommand(args[1:], os.Stdout)
+	case "config":
+		return runConfigCommand(args[1:])
+	case "tool":
+		return runToolCommand(args[1:], os.Stdout)
+	case "history":
+		return runHistoryCommand(args[1:], os.Stdout)
+	case "version":
+		return runVersionCommand(os.Stdout)
+	case "bugreport":
+		return runBugReportCommand(os.Stdout)
+	case "completion":
+		return runCompletionCommand(args[1:], os.Stdout)
+	case "doctor":
+		return runDoctorCommand(args[1:], os.Stdout)
+	case "tools":
+		return runToolsCommand(args[1:], os.Stdout)
+	case "run":
+		return runRunCommand(args[1:], os.Stdin, os.Stdout)
+	case "help", "-h", "--help":
+		fmt.Fprint(os.Stdout, usageText)
+		return 0
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n%s", args[0], usageText)
+		return exitUsage
+	}
+}
+
+// runActionCommand handles `playtools <action> [value]`, prompting for the
+// value when it is missing or ambiguous and for confirmation before invoking.
+func runActionCommand(action Action, args []string, stdin io.Reader, stdout io.Writer) int {
+	fs := flag.NewFlagSet(string(action), flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	env := fs.String("env", devEnv, "")
+	questID := fs.Int("quest-id", 0, "")
+	duration := fs.Int("duration", 0, "")
+	dryRun := fs.Bool("dry-run", false, "")
+	batchFile := fs.String("batch-file", "", "")
+	failFast := fs.Bool("fail-fast", false, "")
+	modeFlag := fs.String("mode", "auto", "")
+	keyFlag := fs.String("idempotency-key", "", "")
+	overrideLifecycle := fs.Bool("override-lifecycle", false, "")
+	payloadFile := fs.String("payload-file", "", "")
+	fs.StringVar(&breakGlassReason, "break-glass", breakGlassReason, "")
+	fs.BoolVar(&printCLI, "print-cli", printCLI, "")
+	fs.BoolVar(&printPayload, "print-payload", printPayload, "")
+	yesFlags(fs)
+
+	positionals, err := parseArgs(fs, args)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n\n%s", err, usageText)
+		return exitUsage
+	}
+
+	if err := setupConfig(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading config: %v\n", err)
+		return exitFailure
+	}
+
+	if pinnedEnv != "" {
+		if isFlagSet(fs, "env") && *env != pinnedEnv {
+			fmt.Fprintf(os.Stderr, "Error: --env=%s rejected, the session is pinned to %s\n", *env, pinnedEnv)
+			return exitUsage
+		}
+		*env = pinnedEnv
+	}
+
+	if _, ok := profileMap[*env]; !ok {
+		fmt.Fprintf(os.Stderr, "Error: unknown environment %q\n", *env)
+		return exitUsage
+	}
+
+	if isFlagSet(fs, "batch-file") {
+		if action != ActionProcess {
+			fmt.Fprintf(os.Stderr, "Error: --batch-file is not supported by %s\n", action)
+			return exitUsage
+		}
+		if len(positionals) > 0 || isFlagSet(fs, "quest-id") || isFlagSet(fs, "dry-run") {
+			fmt.Fprintln(os.Stderr, "Error: --batch-file takes quest IDs and dry_run from the file, not from arguments")
+			return exitUsage
+		}
+		if printPayload {
+			fmt.Fprintln(os.Stderr, "Error: --print-payload is not supported with --batch-file")
+			return exitUsage
+		}
+		return runBatchCommand(*env, *batchFile, *failFast, stdin, stdout)
+	}
+	if *failFast {
+		fmt.Fprintln(os.Stderr, "Error: --fail-fast only applies to --batch-file")
+		return exitUsage
+	}
+
+	if isFlagSet(fs, "payload-file") {
+		var conflict error
+		fs.Visit(func(f *flag.Flag) {
+			switch f.Name {
+			case "quest-id", "duration", "dry-run", "idempotency-key":
+				conflict = fmt.Errorf("--payload-file takes the whole payload, not --%s", f.Name)
+			}
+		})
+		switch {
+		case conflict != nil:
+			fmt.Fprintf(os.Stderr, "Error: %v\n", conflict)
+			return exitUsage
+		case len(positionals) > 0:
+			fmt.Fprintln(os.Stderr, "Error: --payload-file takes the whole payload, not a value argument")
+			return exitUsage
+		case *payloadFile == "-":
+			fmt.Fprintln(os.Stderr, "Error: --payload-file - reads stdin, which the confirmation needs; use playtools invoke --payload-file - instead")
+			return exitUsage
+		}
+		payload, err := readPayloadFile(*payloadFile, stdin)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %s: %v\n", *payloadFile, err)
+			return exitUsage
+		}
+		if payload.Action != action {
+			fmt.Fprintf(os.Stderr, "Error: %s holds a %s payload, not %s\n", *payloadFile, payload.Action, action)
+			return exitUsage
+		}
+		return runPayloadFileCommand(*env, *payloadFile, payload, *modeFlag, *overrideLifecycle, stdin, stdout)
+	}
+
+	valueFlag, question := "quest-id", "Sweepstake quest ID"
+	flagValue := *questID
+	if action == ActionStart {
+		valueFlag, question = "duration", "Sweepstake duration in minutes"
+		flagValue = *duration
+	}
+
+	// Reject flags that don't belong to this action instead of ignoring them
+	var flagErr error
+	fs.Visit(func(f *flag.Flag) {
+		if (f.Name == "quest-id" || f.Name == "duration") && f.Name != valueFlag {
+			flagErr = fmt.Errorf("--%s is not supported by %s", f.Name, action)
+		}
+	})
+	if flagErr != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", flagErr)
+		return exitUsage
+	}
+	if isFlagSet(fs, "idempotency-key") && action != ActionComplete {
+		fmt.Fprintf(os.Stderr, "Error: --idempotency-key is not supported by %s\n", action)
+		return exitUsage
+	}
+	if isFlagSet(fs, "idempotency-key") && strings.TrimSpace(*keyFlag) == "" {
+		fmt.Fprintln(os.Stderr, "Error: --idempotency-key needs the key of the complete to retry")
+		return exitUsage
+	}
+	if isFlagSet(fs, "dry-run") && !dryRunApplies(action) {
+		fmt.Fprintf(os.Stderr, "Error: --dry-run is not supported by %s\n", action)
+		return exitUsage
+	}
+	switch {
+	case *modeFlag != "auto" && *modeFlag != modeSync && *modeFlag != modeAsync:
+		fmt.Fprintf(os.Stderr, "Error: --mode must be auto, sync or async, got %q\n", *modeFlag)
+		return exitUsage
+	case *modeFlag == modeAsync && !asyncApplies(action):
+		fmt.Fprintf(os.Stderr, "Error: --mode=async is not supported by %s\n", action)
+		return exitUsage
+	}
+
+	value, known, err := resolveValue(positionals, valueFlag, flagValue, isFlagSet(fs, valueFlag), stdout)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitUsage
+	}
+
+	in := bufio.NewReader(stdin)
+	if !known && assumeYes {
+		fmt.Fprintf(os.Stderr, "Error: --yes doesn't prompt, pass the %s as an argument or with --%s\n", strings.ReplaceAll(valueFlag, "-", " "), valueFlag)
+		return exitUsage
+	}
+	if !known {
+		value, err = promptPositiveInt(in, stdout, question)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return exitUsage
+		}
+	}
+
+	payload := playtools.NewPayload(action, value)
+	payload.DryRun = *dryRun
+	if !isFlagSet(fs, "dry-run") && defaultDryRun(*env, action) {
+		payload.DryRun = true
+		fmt.Fprintf(stdout, "Defaulting to a dry run for %s in %s, pass --dry-run=false to run it for real.\n", action, *env)
+	}
+	payload.IdempotencyKey = strings.TrimSpace(*keyFlag)
+	if err := assignIdempotencyKey(&payload); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitFailure
+	}
+	if printPayload {
+		return printPayloadPreview(stdout, *env, payload, false)
+	}
+
+	mode := ""
+	if payload.DryRun {
+		mode = " as a dry run"
+	}
+	fmt.Fprintf(stdout, "About to %s with %s %d in %s%s (function %s, profile %s).\n",
+		action, strings.ReplaceAll(valueFlag, "-", " "), value, *env, mode, functionLabel(*env), profileLabel(*env))
+	if transform := toolRegistry[sweepstakeTool].PayloadTransform; transform != nil {
+		data, err := encodePayload(*env, payload)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return exitUsage
+		}
+		fmt.Fprintf(stdout, "Sent as (%s): %s\n", transform.Name, data)
+	}
+	if payload.IdempotencyKey != "" {
+		fmt.Fprintf(stdout, "Idempotency key: %s\n", payload.IdempotencyKey)
+	}
+	if problem := yesProblem(*env, payload); problem != "" {
+		fmt.Fprintf(os.Stderr, "Error: %s\n", problem)
+		return exitUsage
+	}
+	checks := cliCheckContext(stdout, *env, payload)
+	if !confirmChecksCLI(checksInput(in), stdout, checks, map[string]bool{"lifecycle": *overrideLifecycle}, lifecycleFlags) {
+		fmt.Fprintln(stdout, "Aborted.")
+		return exitFailure
+	}
+	ok, err := promptConfirm(in, stdout, "Continue?")
+	if err != nil || !ok {
+		fmt.Fprintln(stdout, "Aborted.")
+		return exitFailure
+	}
+	return runInvocation(*env, payload, *modeFlag, false, stdout)
+}
+
+// runInvocation invokes a confirmed payload, printing the response, the
+// decoded logs and the distribution check. With jsonOutput the result is a
+// single invocationReport on stdout and the progress goes to stderr.
+func runInvocation(env string, payload EventPayload, modeFlag string, jsonOutput bool, stdout io.Writer) int {
+	info := stdout
+	if jsonOutput {
+		info = os.Stderr
+	}
+	action := payload.Action
+	decision := chooseMode(action, functionTimeout(env))
+	if modeFlag != "auto" {
+		decision.Mode = modeFlag
+	}
+	if decision.Switchable {
+		fmt.Fprintf(info, "Invoking %s (%s).\n", decision.Mode, decision.why())
+	}
+
+	// Progress is streamed as it happens, and Ctrl+C cancels the call in flight
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	inv := &invocation{Env: env, Payload: payload, Mode: decision.Mode, ModeReason: decision.why(), FunctionName: sweepstakeFunction(env)}
+	if approvalRequired(env, payload) {
+		if breakGlassReason != "" {
+			fmt.Fprintf(os.Stderr, "Skipping the approval with --break-glass, the reason is recorded in the history: %s\n", breakGlassReason)
+			inv.Approval = breakGlassGrant()
+		} else {
+			g, err := awaitApproval(ctx, env, payload, func(g approvalGrant) {
+				fmt.Fprintf(info, "Waiting for approval of request %s, up to %s...\n", g.ID, formatTimeout(approvalWait))
+			})
+			switch {
+			case err != nil:
+				fmt.Fprintf(os.Stderr, "Error: Approval failed: %v (exit %d)\n", err, exitApproval)
+				return exitApproval
+			case g.Status != approvalApproved:
+				recordApprovalRefusal(env, payload, g)
+				fmt.Fprintf(os.Stderr, "Error: Not approved: %s (exit %d)\n", g.describe(), exitApproval)
+				return exitApproval
+			}
+			fmt.Fprintf(info, "Approval %s\n", g.describe())
+			inv.Approval = g
+		}
+	}
+	profile := profileMap[env]
+	fmt.Fprintf(info, "Checking the SSO session of profile %s...\n", profileLabel(env))
+	loggedIn, err := checkSSOSession(profile, func() {
+		fmt.Fprintln(info, ssoLoginLines[0])
+	})
+	if loggedIn {
+		fmt.Fprintln(info, ssoLoginLines[1])
+	}
+	if err == nil {
+		err = invokeLambda(ctx, inv, func(phase, _ string) {
+			if phase == phaseInvoking {
+				fmt.Fprintf(info, "Invoking %s...\n", inv.FunctionName)
+			}
+		}, func(status string) {
+			fmt.Fprintf(info, "Async invocation: %s\n", status)
+		})
+	}
+	finishInvocation(inv, err)
+	if ctx.Err() != nil {
+		fmt.Fprintln(os.Stderr, "Interrupted, the call was cancelled. A sync invoke that reached AWS may still run, check the logs.")
+		return exitInterrupted
+	}
+	if strings.TrimSpace(inv.Logs) == "" && inv.RequestID != "" {
+		inv.Logs = awaitLogDelivery(inv, info)
+	}
+
+	var v *verification
+	if verifyApplies(inv, err) {
+		check := toolRegistry[sweepstakeTool].VerifyMetric
+		fmt.Fprintf(info, "\nVerifying %s/%s (up to %s)...\n", check.Namespace, check.Name, formatTimeout(check.Timeout))
+		result := verifyDistribution(inv)
+		v = &result
+	}
+
+	if jsonOutput {
+		if encErr := writeInvocationReport(stdout, inv, err, v); encErr != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", encErr)
+			return exitFailure
+		}
+	} else {
+		for _, line := range append(append(dryRunSummary(inv), rollbackSummary(inv)...), invocationLines(inv, err)...) {
+			fmt.Fprintln(stdout, line)
+		}
+		if inv.Logs != "" {
+			fmt.Fprintf(stdout, "\n--- Lambda Logs ---\n\n%s\n", inv.Logs)
+		}
+		if v != nil {
+			for _, line := range v.lines() {
+				fmt.Fprintln(stdout, line)
+			}
+		}
+		if printCLI {
+			if command, cliErr := awsCLICommand(inv); cliErr != nil {
+				fmt.Fprintf(os.Stderr, "No aws CLI command: %v\n", cliErr)
+			} else {
+				fmt.Fprintf(stdout, "\naws CLI: %s\n", command)
+			}
+		}
+	}
+	// A function error comes back with a nil err but is still a failure
+	if class := classifyError(err, inv); class.Title != "" {
+		fmt.Fprintf(os.Stderr, "Hint: %s\n", class.Hint)
+		fmt.Fprintln(os.Stderr, class.failureLine())
+		return class.exitCode()
+	}
+	if v != nil && v.mismatch() {
+		fmt.Fprintf(os.Stderr, "Error: Distribution check failed: the metric doesn't match the response (exit %d)\n", exitFailure)
+		return exitFailure
+	}
+	return 0
+}
+
+// invocationReport is the --output json document of an invocation
+type invocationReport struct {
+	Env          string `json:"env"`
+	FunctionName string `json:"function_name"`
+	// Payload is the payload as sent, after any transform
+	Payload   json.RawMessage `json:"payload"`
+	RequestID string          `json:"request_id,omitempty"`
+	Mode      string          `json:"mode,omitempty"`
+	// Response is the raw response, a JSON string when it isn't JSON
+	Response      json.RawMessage `json:"response,omitempty"`
+	StatusCode    int             `json:"status_code,omitempty"`
+	FunctionError string          `json:"function_error,omitempty"`
+	Logs          string          `json:"logs,omitempty"`
+	DurationMS    int64           `json:"duration_ms"`
+	Verification  []string        `json:"verification,omitempty"`
+	Error         string          `json:"error,omitempty"`
+	// ErrorClass names the kind of failure, as on the last stderr line
+	ErrorClass string `json:"error_class,omitempty"`
+	ExitCode   int    `json:"exit_code"`
+	// PlaytoolsVersion is the build that made the invocation
+	PlaytoolsVersion string `json:"playtools_version"`
+	// AWSCLI is the aws CLI command making the same call, empty over http
+	AWSCLI string `json:"aws_cli,omitempty"`
+}
+
+// writeInvocationReport writes an invocation as an invocationReport
+func writeInvocationReport(w io.Writer, inv *invocation, err error, v *verification) error {
+	report := invocationReport{
+		Env:           inv.Env,
+		FunctionName:  inv.FunctionName,
+		Payload:       inv.SentPayload,
+		RequestID:     inv.RequestID,
+		Mode:          inv.Mode,
+		StatusCode:    inv.StatusCode,
+		FunctionError: inv.FunctionError,
+		Logs:          inv.Logs,
+		DurationMS:    inv.Duration.Milliseconds(),
+
+		PlaytoolsVersion: readBuildMetadata().String(),
+	}
+	if report.Payload == nil {
+		report.Payload, _ = json.Marshal(inv.Payload)
+	}
+	report.AWSCLI, _ = awsCLICommand(inv)
+	switch {
+	case inv.Response == nil:
+	case json.Valid(inv.Response):
+		report.Response = inv.Response
+	default:
+		report.Response, _ = json.Marshal(string(inv.Response))
+	}
+	if v != nil {
+		report.Verification = v.lines()
+	}
+	if err != nil {
+		report.Error = err.Error()
+	}
+	class := classifyError(err, inv)
+	report.ErrorClass, report.ExitCode = class.Title, class.exitCode()
+	if class.Title == "" && v != nil && v.mismatch() {
+		report.ExitCode = exitFailure
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(report)
+}
+
+// runInvokeCommand handles `playtools invoke`, the non-interactive form of
+// the action commands for scripts and runbooks. Everything comes from flags
+// and nothing is prompted for, so a missing value is a usage error and a
+// check that would ask for a typed confirmation refuses instead.
+func runInvokeCommand(args []string, stdout io.Writer) int {
+	fs := flag.NewFlagSet("invoke", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	env := fs.String("env", devEnv, "")
+	actionFlag := fs.String("action", "", "")
+	questID := fs.Int("quest-id", 0, "")
+	duration := fs.Int("duration", 0, "")
+	dryRun := fs.Bool("dry-run", false, "")
+	modeFlag := fs.String("mode", "auto", "")
+	overrideLifecycle := fs.Bool("override-lifecycle", false, "")
+	payloadFile := fs.String("payload-file", "", "")
+	outputFlag := fs.String("output", "text", "")
+	fs.StringVar(&breakGlassReason, "break-glass", breakGlassReason, "")
+	fs.BoolVar(&printCLI, "print-cli", printCLI, "")
+	fs.BoolVar(&printPayload, "print-payload", printPayload, "")
+	yesFlags(fs)
+	usageErr := func(format string, a ...interface{}) int {
+		fmt.Fprintf(os.Stderr, "Error: %s\n\n%s", fmt.Sprintf(format, a...), usageText)
+		return exitUsage
+	}
+
+	if err := fs.Parse(args); err != nil {
+		return usageErr("%v", err)
+	}
+	if fs.NArg() > 0 {
+		return usageErr("unexpected arguments %s, pass the value with --quest-id or --duration", strings.Join(fs.Args(), " "))
+	}
+	var payload EventPayload
+	if isFlagSet(fs, "payload-file") {
+		var conflict error
+		fs.Visit(func(f *flag.Flag) {
+			switch f.Name {
+			case "action", "quest-id", "duration", "dry-run":
+				conflict = fmt.Errorf("--payload-file takes the whole payload, not --%s", f.Name)
+			}
+		})
+		if conflict != nil {
+			return usageErr("%v", conflict)
+		}
+		var err error
+		if payload, err = readPayloadFile(*payloadFile, os.Stdin); err != nil {
+			source := *payloadFile
+			if source == "-" {
+				source = "stdin"
+			}
+			return usageErr("%s: %v", source, err)
+		}
+	} else {
+		action := Action(*actionFlag)
+		valueFlag, value := "quest-id", *questID
+		switch action {
+		case ActionProcess, ActionComplete:
+		case ActionStart:
+			valueFlag, value = "duration", *duration
+		case "":
+			return usageErr("--action or --payload-file is required")
+		default:
+			return usageErr("--action must be %s, %s or %s, got %q", ActionProcess, ActionComplete, ActionStart, *actionFlag)
+		}
+		var flagErr error
+		fs.Visit(func(f *flag.Flag) {
+			if (f.Name == "quest-id" || f.Name == "duration") && f.Name != valueFlag {
+				flagErr = fmt.Errorf("--%s is not supported by %s", f.Name, action)
+			}
+		})
+		switch {
+		case flagErr != nil:
+			return usageErr("%v", flagErr)
+		case !isFlagSet(fs, valueFlag):
+			return usageErr("--%s is required for %s", valueFlag, action)
+		case value <= 0:
+			return usageErr("--%s must be a positive number", valueFlag)
+		case isFlagSet(fs, "dry-run") && !dryRunApplies(action):
+			return usageErr("--dry-run is not supported by %s", action)
+		}
+		payload = playtools.NewPayload(action, value)
+		payload.DryRun = *dryRun
+	}
+	action := payload.Action
+	switch {
+	case *outputFlag != "text" && *outputFlag != "json":
+		return usageErr("--output must be text or json, got %q", *outputFlag)
+	case *modeFlag != "auto" && *modeFlag != modeSync && *modeFlag != modeAsync:
+		return usageErr("--mode must be auto, sync or async, got %q", *modeFlag)
+	case *modeFlag == modeAsync && !asyncApplies(action):
+		return usageErr("--mode=async is not supported by %s", action)
+	}
+
+	if err := setupConfig(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading config: %v\n", err)
+		return exitFailure
+	}
+	if pinnedEnv != "" {
+		if isFlagSet(fs, "env") && *env != pinnedEnv {
+			return usageErr("--env=%s rejected, the session is pinned to %s", *env, pinnedEnv)
+		}
+		*env = pinnedEnv
+	}
+	if _, ok := profileMap[*env]; !ok {
+		return usageErr("unknown environment %q", *env)
+	}
+
+	// With JSON output stdout only gets the report
+	jsonOutput := *outputFlag == "json"
+	info := stdout
+	if jsonOutput {
+		info = os.Stderr
+	}
+	if !isFlagSet(fs, "dry-run") && !isFlagSet(fs, "payload-file") && defaultDryRun(*env, action) {
+		payload.DryRun = true
+		fmt.Fprintf(info, "Defaulting to a dry run for %s in %s, pass --dry-run=false to run it for real.\n", action, *env)
+	}
+	if err := assignIdempotencyKey(&payload); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitFailure
+	}
+	if printPayload {
+		return printPayloadPreview(stdout, *env, payload, jsonOutput)
+	}
+	// Nothing is prompted for, so a change to a protected environment has to
+	// be confirmed up front the way --yes confirms the other commands
+	if isProtected(*env) && mutates(payload) && !assumeYes {
+		fmt.Fprintf(os.Stderr, "Error: invoke won't %s in %s without --yes, or run playtools %s to confirm it\n", action, *env, action)
+		return exitUsage
+	}
+	if problem := yesProblem(*env, payload); problem != "" {
+		fmt.Fprintf(os.Stderr, "Error: %s\n", problem)
+		return exitUsage
+	}
+	checks := cliCheckContext(info, *env, payload)
+	if !confirmChecksCLI(nil, info, checks, map[string]bool{"lifecycle": *overrideLifecycle}, lifecycleFlags) {
+		return exitFailure
+	}
+	return runInvocation(*env, payload, *modeFlag, jsonOutput, stdout)
+}
+
+// runPayloadFileCommand shows a payload read from a file as it was parsed
+// and invokes it once confirmed
+func runPayloadFileCommand(env, path string, payload EventPayload, modeFlag string, overrideLifecycle bool, stdin io.Reader, stdout io.Writer) int {
+	if err := assignIdempotencyKey(&payload); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitFailure
+	}
+	if printPayload {
+		return printPayloadPreview(stdout, env, payload, false)
+	}
+	data, err := json.MarshalIndent(payload, "", "  ")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitFailure
+	}
+	fmt.Fprintf(stdout, "About to invoke the payload of %s in %s (function %s, profile %s):\n%s\n",
+		path, env, functionLabel(env), profileLabel(env), data)
+	fmt.Fprintln(stdout, invocationSummary(env, payload))
+	printRewardPool(stdout, env, payload)
+	if problem := yesProblem(env, payload); problem != "" {
+		fmt.Fprintf(os.Stderr, "Error: %s\n", problem)
+		return exitUsage
+	}
+	in := bufio.NewReader(stdin)
+	checks := cliCheckContext(stdout, env, payload)
+	if !confirmChecksCLI(checksInput(in), stdout, checks, map[string]bool{"lifecycle": overrideLifecycle}, lifecycleFlags) {
+		fmt.Fprintln(stdout, "Aborted.")
+		return exitFailure
+	}
+	ok, err := promptConfirm(in, stdout, "Continue?")
+	if err != nil || !ok {
+		fmt.Fprintln(stdout, "Aborted.")
+		return exitFailure
+	}
+	return runInvocation(env, payload, modeFlag, false, stdout)
+}
+
+// runBatchCommand validates a batch file and processes its rows one after
+// another once confirmed. failFast stops at the first failed quest.
+func runBatchCommand(env, path string, failFast bool, stdin io.Reader, stdout io.Writer) int {
+	rows, err := readBatchFile(path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %s: %v\n", path, err)
+		return exitUsage
+	}
+
+	in := bufio.NewReader(stdin)
+	if previous, statuses, err := previousBatchResults(path); err == nil && previous != "" {
+		if subset := batchRetrySubset(rows, statuses); len(subset) > 0 && len(subset) < len(rows) {
+			question := fmt.Sprintf("%d of %d quest(s) failed or weren't attempted in %s. Run only those?", len(subset), len(rows), previous)
+			if ok, err := promptConfirm(in, stdout, question); err == nil && ok {
+				rows = subset
+			}
+		}
+	}
+
+	dryRuns := 0
+	for _, row := range rows {
+		if row.DryRun {
+			dryRuns++
+		}
+	}
+	fmt.Fprintf(stdout, "About to process %d quest(s) from %s in %s, %d as dry runs (function %s, profile %s).\n",
+		len(rows), path, env, dryRuns, functionLabel(env), profileLabel(env))
+	checks := checkContext{Env: env, Batch: true, Mutations: len(rows) - dryRuns}
+	if isProtected(env) {
+		checks.Budget = loadProdBudget()
+	}
+	if !confirmChecksCLI(checksInput(in), stdout, checks, nil, nil) {
+		fmt.Fprintln(stdout, "Aborted.")
+		return exitFailure
+	}
+	ok, err := promptConfirm(in, stdout, "Continue?")
+	if err != nil || !ok {
+		fmt.Fprintln(stdout, "Aborted.")
+		return exitFailure
+	}
+
+	// Ctrl+C stops the batch after the current quest, recording the rest as skipped
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	resultsPath := batchResultsPath(path, time.Now())
+	results := newBatchResults(rows)
+	for i, row := range rows {
+		if ctx.Err() != nil {
+			fmt.Fprintln(stdout, "Interrupted, skipping the remaining quests.")
+			cancelBatch(results)
+			break
+		}
+		results[i].Status = batchRunning
+		if err := writeBatchResults(resultsPath, results); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: failed to write results: %v\n", err)
+			return exitFailure
+		}
+		fmt.Fprintf(stdout, "[%d/%d] quest %d... ", i+1, len(rows), row.QuestID)
+		res := runBatchRow(env, row)
+		results[i] = res
+		if res.Err != "" {
+			fmt.Fprintf(stdout, "%s: %s\n", res.Status, res.Err)
+		} else {
+			fmt.Fprintf(stdout, "%s (%s, request %s)\n", res.Status, res.Duration.Round(time.Millisecond), res.RequestID)
+		}
+		if failFast && res.Status == batchFailed {
+			fmt.Fprintln(stdout, "Stopping at the first failure (--fail-fast), skipping the remaining quests.")
+			cancelBatch(results)
+			break
+		}
+	}
+	if err := writeBatchResults(resultsPath, results); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: failed to write results: %v\n", err)
+		return exitFailure
+	}
+
+	fmt.Fprintf(stdout, "%s, results written to %s\n", batchSummary(results), resultsPath)
+	if ctx.Err() != nil {
+		return exitInterrupted
+	}
+	if batchIncomplete(results) {
+		return exitFailure
+	}
+	return 0
+}
+
+// runConfigCommand handles the `playtools config` subcommands
+func runConfigCommand(args []string) int {
+	if len(args) > 0 && args[0] == "show" {
+		return runConfigShowCommand(args[1:], os.Stdout)
+	}
+	if len(args) > 0 && args[0] == "validate" {
+		return runConfigValidateCommand(args[1:], os.Stdout)
+	}
+	if len(args) > 0 && args[0] == "init" {
+		return runConfigInitCommand(args[1:], os.Stdin, os.Stdout)
+	}
+	if len(args) == 0 || args[0] != "fmt" {
+		fmt.Fprintf(os.Stderr, "Error: expected a config subcommand\n\n%s", usageText)
+		return exitUsage
+	}
+
+	fs := flag.NewFlagSet("config fmt", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	check := fs.Bool("check", false, "")
+	if err := fs.Parse(args[1:]); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n\n%s", err, usageText)
+		return exitUsage
+	}
+
+	path, err := configFilePath()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitFailure
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitFailure
+	}
+	formatted, err := formatConfig(path, data)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitFailure
+	}
+
+	switch {
+	case bytes.Equal(data, formatted):
+		fmt.Printf("%s is already formatted\n", path)
+	case *check:
+		fmt.Printf("%s is not formatted\n", path)
+		return exitFailure
+	default:
+		if err := os.WriteFile(path, formatted, 0o644); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return exitFailure
+		}
+		fmt.Printf("Formatted %s\n", path)
+	}
+	return 0
+}
+
+// runVersionCommand prints the build metadata and whether the config allows
+// it to run mutating actions
+func runVersionCommand(stdout io.Writer) int {
+	b := readBuildMetadata()
+	fmt.Fprintf(stdout, "playtools %s\n", b.Version)
+	if b.Modified {
+		fmt.Fprintf(stdout, "commit:     %s (modified)\n", b.Commit)
+	} else {
+		fmt.Fprintf(stdout, "commit:     %s\n", b.Commit)
+	}
+	fmt.Fprintf(stdout, "build date: %s\n", b.Date)
+	fmt.Fprintf(stdout, "go:         %s\n", b.Go)
+	fmt.Fprintf(stdout, "aws sdk:    %s\n", b.AWSSDK)
+	if err := setupConfig(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading config: %v\n", err)
+		return exitFailure
+	}
+	if reason := versionBlock(); reason != "" {
+		fmt.Fprintln(stdout, reason)
+		return exitFailure
+	}
+	if minVersion != "" {
+		fmt.Fprintf(stdout, "Satisfies the config's min_version %s\n", minVersion)
+	}
+	return 0
+}
+
+// runHistoryCommand handles `playtools history export`, writing the history
+// records the filter matches as JSONL, oldest first
+func runHistoryCommand(args []string, stdout io.Writer) int {
+	if len(args) == 0 || args[0] != "export" {
+		fmt.Fprintf(os.Stderr, "Error: expected a history subcommand\n\n%s", usageText)
+		return exitUsage
+	}
+	fs := flag.NewFlagSet("history export", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	since := fs.String("since", "", "")
+	expr := fs.String("filter", "", "")
+	if err := fs.Parse(args[1:]); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n\n%s", err, usageText)
+		return exitUsage
+	}
+	if *since != "" {
+		*expr += " since:" + *since
+	}
+	filter, err := parseHistoryFilter(*expr, time.Now())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: --filter: %v\n", err)
+		return exitUsage
+	}
+
+	path, err := historyFilePath()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitFailure
+	}
+	w := bufio.NewWriter(stdout)
+	skipped, err := eachHistoryLine(path, filter, func(_ int64, line []byte, _ historyRecord) error {
+		_, err := w.Write(append(line, '\n'))
+		return err
+	})
+	if err == nil {
+		err = w.Flush()
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitFailure
+	}
+	if skipped > 0 {
+		fmt.Fprintf(os.Stderr, "Skipped %d unreadable history lines\n", skipped)
+	}
+	return 0
+}
+
+// parseArgs parses flags that may appear before or after positional arguments,
+// returning the positionals in order.
+func parseArgs(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positionals []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		args = fs.Args()
+		if len(args) == 0 {
+			return positionals, nil
+		}
+		positionals = append(positionals, args[0])
+		args = args[1:]
+	}
+}
+
+func isFlagSet(fs *flag.FlagSet, name string) bool {
+	set := false
+	fs.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+	return set
+}
+
+// resolveValue maps the positional arguments onto the action's value field.
+// It reports known=false when the value has to be prompted for, and an error
+// when a positional conflicts with an explicit flag.
+func resolveValue(positionals []string, flagName string, flagValue int, flagSet bool, stdout io.Writer) (value int, known bool, err error) {
+	if flagSet {
+		if flagValue <= 0 {
+			return 0, false, fmt.Errorf("--%s must be a positive number", flagName)
+		}
+		for _, p := range positionals {
+			if n, err := strconv.Atoi(p); err != nil || n != flagValue {
+				return 0, false, fmt.Errorf("positional argument %q conflicts with --%s=%d", p, flagName, flagValue)
+			}
+		}
+		return flagValue, true, nil
+	}
+
+	switch len(positionals) {
+	case 0:
+		return 0, false, nil
+	case 1:
+		n, err := strconv.Atoi(positionals[0])
+		if err != nil || n <= 0 {
+			fmt.Fprintf(stdout, "Ignoring %q: expected a positive number\n", positionals[0])
+			return 0, false, nil
+		}
+		return n, true, nil
+	default:
+		fmt.Fprintf(stdout, "Ignoring ambiguous arguments %s\n", strings.Join(positionals, " "))
+		return 0, false, nil
+	}
+}
+
+func promptPositiveInt(in *bufio.Reader, out io.Writer, question string) (int, error) {
+	for {
+		fmt.Fprintf(out, "%s: ", question)
+		line, err := in.ReadString('\n')
+		if n, convErr := strconv.Atoi(strings.TrimSpace(line)); convErr == nil && n > 0 {
+			return n, nil
+		}
+		if err != nil {
+			return 0, errors.New("no value entered")
+		}
+		fmt.Fprintln(out, tr(msgInvalidPositive))
+	}
+}
+
+// promptConfirm asks a yes or no question, answered yes by --yes
+func promptConfirm(in *bufio.Reader, out io.Writer, question string) (bool, error) {
+	if assumeYes {
+		fmt.Fprintf(out, "%s yes (--yes)\n", question)
+		return true, nil
+	}
+	fmt.Fprintf(out, "%s [y/N]: ", question)
+	line, err := in.ReadString('\n')
+	if err != nil && line == "" {
+		return false, err
+	}
+	answer := strings.ToLower(strings.TrimSpace(line))
+	return answer == "y" || answer == "yes", nil
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// isExitLiteral reports whether e is an exit code spelled as a number, zero
+// aside
+func isExitLiteral(e ast.Expr) bool {
+	lit, ok := e.(*ast.BasicLit)
+	return ok && lit.Kind == token.INT && lit.Value != "0"
+}
+
+// The commands return the named exit codes, so the usage text, the README
+// and the code can't drift apart
+func TestExitCodesNamed(t *testing.T) {
+	fset := token.NewFileSet()
+	paths, err := filepath.Glob("*.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range paths {
+		if strings.HasSuffix(path, "_test.go") {
+			continue
+		}
+		file, err := parser.ParseFile(fset, path, nil, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, decl := range file.Decls {
+			fn, ok := decl.(*ast.FuncDecl)
+			if !ok || fn.Body == nil {
+				continue
+			}
+			results := fn.Type.Results
+			command := strings.HasPrefix(fn.Name.Name, "run") && results != nil && len(results.List) == 1
+			if command {
+				ident, ok := results.List[0].Type.(*ast.Ident)
+				command = ok && ident.Name == "int"
+			}
+			ast.Inspect(fn.Body, func(n ast.Node) bool {
+				switch n := n.(type) {
+				case *ast.FuncLit:
+					// A closure's returns aren't the command's
+					return false
+				case *ast.ReturnStmt:
+					if command && len(n.Results) == 1 && isExitLiteral(n.Results[0]) {
+						t.Errorf("%s: %s returns a bare exit code", fset.Position(n.Pos()), fn.Name.Name)
+					}
+				case *ast.CallExpr:
+					if sel, ok := n.Fun.(*ast.SelectorExpr); ok && sel.Sel.Name == "Exit" && len(n.Args) == 1 && isExitLiteral(n.Args[0]) {
+						t.Errorf("%s: %s exits with a bare code", fset.Position(n.Pos()), fn.Name.Name)
+					}
+				}
+				return true
+			})
+		}
+	}
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+// cliCommand describes a command for shell completion
+type cliCommand struct {
+	name        string
+	flags       []string
+	subcommands []string
+}
+
+// actionFlags are the flags of the action commands
+var actionFlags = []string{"--env", "--quest-id", "--duration", "--dry-run", "--batch-file", "--fail-fast", "--mode", "--idempotency-key", "--override-lifecycle", "--payload-file", "--break-glass", "--print-cli", "--print-payload", "--yes", "--force", "--i-know-what-im-doing"}
+
+// cliCommands are the commands and their flags, the global flags being
+// those of the unnamed command
+var cliCommands = []cliCommand{
+	{name: "", flags: []string{"--pin-env", "--context", "--serve", "--high-latency", "--debug", "--plain", "--env", "--action", "--break-glass"}},
+	{name: string(ActionProcess), flags: actionFlags},
+	{name: string(ActionComplete), flags: actionFlags},
+	{name: string(ActionStart), flags: actionFlags},
+	{name: "invoke", flags: []string{"--env", "--action", "--quest-id", "--duration", "--dry-run", "--mode", "--override-lifecycle", "--payload-file", "--output", "--break-glass", "--print-cli", "--print-payload", "--yes", "--force", "--i-know-what-im-doing"}},
+	{name: "config", flags: []string{"--check", "--force"}, subcommands: []string{"init", "fmt", "validate", "show"}},
+	{name: "tool", flags: []string{"--set"}, subcommands: []string{"test"}},
+	{name: "history", flags: []string{"--since", "--filter"}, subcommands: []string{"export"}},
+	{name: "version"},
+	{name: "bugreport"},
+	{name: "doctor", flags: []string{"--env"}},
+	{name: "tools", flags: []string{"--env", "--refresh"}},
+	{name: "run", flags: actionFlags},
+	{name: "completion", subcommands: []string{"bash", "zsh", "fish"}},
+	{name: "help"},
+}
+
+// boolFlags take no value
+var boolFlags = map[string]bool{"--dry-run": true, "--override-lifecycle": true, "--high-latency": true, "--debug": true, "--plain": true, "--check": true, "--print-cli": true, "--print-payload": true, "--yes": true, "--force": true, "--i-know-what-im-doing": true, "--refresh": true}
+
+// fileFlags take a file path
+var fileFlags = map[string]bool{"--payload-file": true, "--batch-file": true}
+
+// flagValues are the values offered for a flag, environments coming from
+// the config the completion is generated with
+func flagValues() map[string][]string {
+	envs := make([]string, 0, len(profileMap))
+	for env := range profileMap {
+		envs = append(envs, env)
+	}
+	sort.Strings(envs)
+	return map[string][]string{
+		"--env":     envs,
+		"--pin-env": envs,
+		"--context": contextNames,
+		"--action":  {string(ActionProcess), string(ActionComplete), string(ActionStart)},
+		"--mode":    {"auto", modeSync, modeAsync},
+		"--output":  {"text", "json"},
+	}
+}
+
+// commandWords are the words completed after a command: its subcommands,
+// then its flags
+func (c cliCommand) commandWords() []string {
+	words := append([]string{}, c.subcommands...)
+	if c.name == "" {
+		for _, cmd := range cliCommands[1:] {
+			words = append(words, cmd.name)
+		}
+	}
+	return append(words, c.flags...)
+}
+
+// valueFlags lists every flag taking a value, for skipping the values while
+// looking for the command
+func valueFlags() []string {
+	seen := map[string]bool{}
+	var flags []string
+	for _, c := range cliCommands {
+		for _, f := range c.flags {
+			if !boolFlags[f] && !seen[f] {
+				seen[f] = true
+				flags = append(flags, f)
+			}
+		}
+	}
+	return flags
+}
+
+// writeBashCompletion writes a bash completion script
+func writeBashCompletion(w io.Writer) {
+	values := flagValues()
+	fmt.Fprintln(w, "# playtools bash completion, regenerate after changing the config's environments")
+	fmt.Fprintln(w, "_playtools() {")
+	fmt.Fprintln(w, `	local cur="${COMP_WORDS[COMP_CWORD]}" prev="${COMP_WORDS[COMP_CWORD-1]}" cmd="" skip="" i`)
+	fmt.Fprintln(w, `	for ((i = 1; i < COMP_CWORD; i++)); do`)
+	fmt.Fprintln(w, `		if [[ -n $skip ]]; then skip=""; continue; fi`)
+	fmt.Fprintln(w, `		case "${COMP_WORDS[i]}" in`)
+	fmt.Fprintf(w, "\t\t%s) skip=1 ;;\n", strings.Join(valueFlags(), "|"))
+	fmt.Fprintln(w, `		-*) ;;`)
+	fmt.Fprintln(w, `		*) cmd="${COMP_WORDS[i]}"; break ;;`)
+	fmt.Fprintln(w, `		esac`)
+	fmt.Fprintln(w, `	done`)
+	fmt.Fprintln(w, `	case "$prev" in`)
+	for _, f := range sortedKeys(values) {
+		fmt.Fprintf(w, "\t%s) COMPREPLY=($(compgen -W %q -- \"$cur\")); return ;;\n", f, strings.Join(values[f], " "))
+	}
+	fmt.Fprintf(w, "\t%s) COMPREPLY=($(compgen -f -- \"$cur\")); return ;;\n", strings.Join(sortedKeys(fileFlags), "|"))
+	fmt.Fprintln(w, `	esac`)
+	fmt.Fprintln(w, `	local words`)
+	fmt.Fprintln(w, `	case "$cmd" in`)
+	for _, c := range cliCommands {
+		fmt.Fprintf(w, "\t%q) words=%q ;;\n", c.name, strings.Join(c.commandWords(), " "))
+	}
+	fmt.Fprintln(w, `	esac`)
+	fmt.Fprintln(w, `	COMPREPLY=($(compgen -W "$words" -- "$cur"))`)
+	fmt.Fprintln(w, "}")
+	fmt.Fprintln(w, "complete -o default -F _playtools playtools")
+}
+
+// writeZshCompletion writes a zsh completion script, the bash one run
+// through zsh's bash completion support
+func writeZshCompletion(w io.Writer) {
+	fmt.Fprintln(w, "# playtools zsh completion")
+	fmt.Fprintln(w, "autoload -U +X compinit && compinit")
+	fmt.Fprintln(w, "autoload -U +X bashcompinit && bashcompinit")
+	writeBashCompletion(w)
+}
+
+// writeFishCompletion writes a fish completion script
+func writeFishCompletion(w io.Writer) {
+	values := flagValues()
+	fmt.Fprintln(w, "# playtools fish completion, regenerate after changing the config's environments")
+	fmt.Fprintln(w, "complete -c playtools -f")
+	for _, c := range cliCommands {
+		cond := "__fish_use_subcommand"
+		if c.name != "" {
+			cond = "__fish_seen_subcommand_from " + c.name
+			fmt.Fprintf(w, "complete -c playtools -n __fish_use_subcommand -a %s\n", c.name)
+		}
+		if len(c.subcommands) > 0 {
+			fmt.Fprintf(w, "complete -c playtools -n '%s' -a '%s'\n", cond, strings.Join(c.subcommands, " "))
+		}
+		for _, f := range c.flags {
+			line := fmt.Sprintf("complete -c playtools -n '%s' -l %s", cond, strings.TrimPrefix(f, "--"))
+			switch {
+			case fileFlags[f]:
+				line += " -r -F"
+			case len(values[f]) > 0:
+				line += fmt.Sprintf(" -x -a '%s'", strings.Join(values[f], " "))
+			case !boolFlags[f]:
+				line += " -x"
+			}
+			fmt.Fprintln(w, line)
+		}
+	}
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// runCompletionCommand handles `playtools completion bash|zsh|fish`
+func runCompletionCommand(args []string, stdout io.Writer) int {
+	if len(args) != 1 {
+		fmt.Fprintf(os.Stderr, "Error: expected playtools completion bash, zsh or fish\n")
+		return exitUsage
+	}
+	if err := setupConfig(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading config: %v\n", err)
+		return exitFailure
+	}
+	switch args[0] {
+	case "bash":
+		writeBashCompletion(stdout)
+	case "zsh":
+		writeZshCompletion(stdout)
+	case "fish":
+		writeFishCompletion(stdout)
+	default:
+		fmt.Fprintf(os.Stderr, "Error: unknown shell %q, expected bash, zsh or fish\n", args[0])
+		return exitUsage
+	}
+	return 0
+}
@@ -0,0 +1,631 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/revrost/playtools/pkg/playtools"
+	"gopkg.in/yaml.v3"
+)
+
+// Config is the optional user configuration loaded from config.yaml.
+// YAML is used so the file can carry comments and commented-out environments.
+type Config struct {
+	// The top level is the default context
+	ContextConfig `yaml:",inline"`
+	// Contexts are separate deployments, each with its own environments,
+	// tools and policies, selected with --context
+	Contexts map[string]ContextConfig `yaml:"contexts"`
+	// MinVersion is the oldest build allowed to run mutating actions, so
+	// policies older builds don't know about can't be skipped
+	MinVersion string `yaml:"min_version"`
+	// Locale sets the number format of summaries, "en" by default
+	Locale  string        `yaml:"locale"`
+	Display DisplayConfig `yaml:"display"`
+	// Keymap binds the logical keys, like quit or back, to other keys
+	Keymap map[string][]string `yaml:"keymap"`
+	Theme  ThemeConfig         `yaml:"theme"`
+	Logs   LogsConfig          `yaml:"logs"`
+}
+
+// ContextConfig is everything about one deployment
+type ContextConfig struct {
+	Environments map[string]EnvironmentConfig `yaml:"environments"`
+	// FunctionTemplate names the function of every environment, its %s
+	// being the environment
+	FunctionTemplate string                `yaml:"function_template"`
+	Tools            map[string]ToolConfig `yaml:"tools"`
+	Budget           BudgetConfig          `yaml:"budget"`
+	// Presets are named form values, managed from the TUI
+	Presets []Preset `yaml:"presets"`
+	// Roles maps role names, or SSO permission set names, to the capability
+	// labels they grant
+	Roles map[string][]string `yaml:"roles"`
+	// Session sets the idle lock of unattended sessions
+	Session SessionConfig `yaml:"session"`
+	// Approval gates actions behind an external approval endpoint
+	Approval ApprovalConfig `yaml:"approval"`
+	Cache    CacheConfig    `yaml:"cache"`
+	// Checks turns off optional invocation checks and hooks per environment
+	Checks map[string]ChecksConfig `yaml:"checks"`
+	// Discovery finds tools from Lambda function tags
+	Discovery DiscoveryConfig `yaml:"discovery"`
+}
+
+// ChecksConfig lists the checks and hooks an environment skips
+type ChecksConfig struct {
+	Disabled []string `yaml:"disabled"`
+}
+
+// CacheConfig sets how long fetched quest data is reused
+type CacheConfig struct {
+	// QuestTTL is a duration like "5m", zero to always fetch
+	QuestTTL string `yaml:"quest_ttl"`
+}
+
+// DisplayConfig overrides what the terminal is detected to support
+type DisplayConfig struct {
+	// Colors is auto, truecolor, 256, 16 or none
+	Colors string `yaml:"colors"`
+	// Unicode is auto, on or off; off draws ASCII symbols
+	Unicode string `yaml:"unicode"`
+}
+
+// ThemeConfig adjusts the colors and layout of the TUI
+type ThemeConfig struct {
+	// Accent and Error are an ANSI color number like "205" or hex like
+	// "#ff5f87". A color that isn't one falls back to the default.
+	Accent string `yaml:"accent"`
+	Error  string `yaml:"error"`
+	// Margin is the space around every screen, lipgloss style: one value
+	// for all sides, or vertical then horizontal
+	Margin []int `yaml:"margin"`
+	// ProdAccent accents the protected environments with the error color
+	ProdAccent bool `yaml:"prod_accent"`
+}
+
+// LogsConfig bounds the CloudWatch logs fetched with 'L' after an invoke
+type LogsConfig struct {
+	// Since is how far back the logs go, a duration like "10m"
+	Since string `yaml:"since"`
+	// MaxLines caps the lines fetched from that window
+	MaxLines int `yaml:"max_lines"`
+}
+
+// EnvironmentConfig configures a single environment
+type EnvironmentConfig struct {
+	Profile string `yaml:"profile"`
+	// Region replaces the region of the profile, like "us-east-2"
+	Region string `yaml:"region"`
+	// Function is the full name of the environment's function, instead of
+	// the one function_template renders
+	Function string `yaml:"function"`
+	// Description is shown under the environment on the first screen
+	Description string `yaml:"description"`
+	// Protected gives the environment prod's safety behaviors, on by
+	// default for prod only
+	Protected *bool `yaml:"protected"`
+	// DefaultDryRun is whether process and start begin as dry runs, instead
+	// of only start in the protected environments
+	DefaultDryRun *bool `yaml:"default_dry_run"`
+}
+
+// BudgetConfig sets soft limits on manual activity
+type BudgetConfig struct {
+	// ProdDailyLimit caps mutating prod invocations per UTC day, zero for no cap
+	ProdDailyLimit int `yaml:"prod_daily_limit"`
+}
+
+// ToolConfig configures a single tool, keyed by tool name such as "sweepstake"
+type ToolConfig struct {
+	// ProgressPattern matches progress log lines, with done and total groups
+	ProgressPattern string `yaml:"progress_pattern"`
+	// Transport is "lambda" (default) or "http" to POST the payload to the
+	// environment's URL with SigV4 auth
+	Transport string            `yaml:"transport"`
+	URLs      map[string]string `yaml:"urls"`
+	// AsyncThreshold is a duration like "5m". Process and complete run async
+	// when the function timeout is longer.
+	AsyncThreshold   string                 `yaml:"async_threshold"`
+	PayloadTransform PayloadTransformConfig `yaml:"payload_transform"`
+	VerifyMetric     *VerifyMetricConfig    `yaml:"verify_metric"`
+	// Requires lists capability labels per action, like complete: [distribute]
+	Requires map[string][]string `yaml:"requires"`
+	// SnapshotOverrides is a Go template with .QuestID and .SnapshotID, and a
+	// json function, building the overrides of a snapshot investigation
+	SnapshotOverrides string `yaml:"snapshot_overrides"`
+	// Promotion names the status fields that must match between an
+	// environment and prod, and the ones expected to differ
+	Promotion *PromotionConfig `yaml:"promotion"`
+	// Lifecycle maps the quest states of the status response to the actions
+	// allowed in them
+	Lifecycle *LifecycleConfig `yaml:"lifecycle"`
+	// Fields gives the prompt fields help text and examples, keyed by payload
+	// field like sweepstake_quest_id
+	Fields map[string]FieldHelpConfig `yaml:"fields"`
+	// Schema is a JSON Schema file or http(s) URL of the payload, whose
+	// property descriptions and examples are the fields' help
+	Schema string `yaml:"schema"`
+	// RewardPool adds up the prize tiers of the overrides on the confirmation
+	RewardPool *RewardPoolConfig `yaml:"reward_pool"`
+	// MaxBatchSize caps the batch size the prompts and batch files take
+	MaxBatchSize int `yaml:"max_batch_size"`
+	// FunctionTemplate and Actions define a tool of its own next to the
+	// sweepstake calculator, its function named with the environment as %s
+	FunctionTemplate string             `yaml:"function_template"`
+	Description      string             `yaml:"description"`
+	Actions          []ToolActionConfig `yaml:"actions"`
+}
+
+// ToolActionConfig is an action of a configured tool, invoked with
+// {"action": name} and a field per prompt
+type ToolActionConfig struct {
+	Name        string             `yaml:"name"`
+	Description string             `yaml:"description"`
+	Prompts     []ToolPromptConfig `yaml:"prompts"`
+}
+
+// ToolPromptConfig is a field of an action's payload asked for on its form
+type ToolPromptConfig struct {
+	Field string `yaml:"field"`
+	Label string `yaml:"label"`
+	// Type is string (default), int or bool
+	Type     string `yaml:"type"`
+	Required bool   `yaml:"required"`
+}
+
+// RewardPoolConfig names the overrides paths of the prize tiers, dotted like
+// the promotion ones, and the totals to warn above
+type RewardPoolConfig struct {
+	// Tiers is the path of the tiers, Count and Amount paths within a tier
+	Tiers  string `yaml:"tiers"`
+	Count  string `yaml:"count"`
+	Amount string `yaml:"amount"`
+	// Unit labels the amounts, like IMX
+	Unit string `yaml:"unit"`
+	// Ceilings are whole amounts per environment, like prod: 100000
+	Ceilings map[string]string `yaml:"ceilings"`
+}
+
+// FieldHelpConfig is the help panel of a prompt field
+type FieldHelpConfig struct {
+	Help     string   `yaml:"help"`
+	Examples []string `yaml:"examples"`
+}
+
+// LifecycleConfig maps quest states to the actions allowed in them, like
+// processed: [process, complete]. States not listed allow every action.
+type LifecycleConfig struct {
+	// StateField is the status response path of the state, "status" by default
+	StateField string `yaml:"state_field"`
+	// SinceField is when the quest entered the state, "updated_at" by default
+	SinceField string              `yaml:"since_field"`
+	Allowed    map[string][]string `yaml:"allowed"`
+}
+
+// PromotionConfig names status response paths, dotted like prize_tiers.0.amount
+type PromotionConfig struct {
+	// MustMatch defaults to every top-level field but MayDiffer
+	MustMatch []string `yaml:"must_match"`
+	MayDiffer []string `yaml:"may_differ"`
+}
+
+// VerifyMetricConfig cross-checks completes against a CloudWatch metric the
+// function emits, comparing its sum with the winners in the response
+type VerifyMetricConfig struct {
+	Namespace string `yaml:"namespace"`
+	Name      string `yaml:"name"`
+	// Dimensions are Go templates with .QuestID and .Env, like "{{.QuestID}}"
+	Dimensions map[string]string `yaml:"dimensions"`
+	// WinnersField is the response field with the winners, "winners" by default
+	WinnersField string  `yaml:"winners_field"`
+	Tolerance    float64 `yaml:"tolerance"`
+	// Window and Timeout are durations, "10m" and "2m" by default
+	Window  string `yaml:"window"`
+	Timeout string `yaml:"timeout"`
+}
+
+// PayloadTransformConfig wraps the payload before it is sent, with either a
+// built-in wrapper or a Go template
+type PayloadTransformConfig struct {
+	// Wrapper names a built-in wrapper such as "detail"
+	Wrapper string `yaml:"wrapper"`
+	// Source is the source field of the built-in wrappers, "playtools" by default
+	Source string `yaml:"source"`
+	// Template is executed with .Payload, .JSON (the marshalled payload),
+	// .Env and .Source, and a json function to marshal values
+	Template string `yaml:"template"`
+}
+
+// configError is a config parse error with its position in the file
+type configError struct {
+	Path    string
+	Line    int
+	Column  int
+	Msg     string
+	Snippet string
+}
+
+func (e *configError) Error() string {
+	var sb strings.Builder
+	if e.Line > 0 {
+		sb.WriteString(fmt.Sprintf("%s:%d:%d: %s", e.Path, e.Line, e.Column, e.Msg))
+	} else {
+		sb.WriteString(fmt.Sprintf("%s: %s", e.Path, e.Msg))
+	}
+	if e.Snippet != "" {
+		sb.WriteString("\n" + e.Snippet)
+	}
+	return sb.String()
+}
+
+func configFilePath() (string, error) {
+	return playtools.DefaultConfigPath()
+}
+
+// loadConfig reads the config file, returning an empty config when it doesn't exist
+func loadConfig() (Config, error) {
+	var cfg Config
+	path, err := configFilePath()
+	if err != nil {
+		return cfg, err
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return cfg, nil
+	}
+	if err != nil {
+		return cfg, err
+	}
+	return parseConfig(path, data)
+}
+
+// parseConfig decodes a config file. A file that isn't YAML fails on its
+// first error; otherwise every value of the wrong type and every unknown key
+// is reported as configProblems, along with the config decoded from the rest
+// of the file.
+func parseConfig(path string, data []byte) (Config, error) {
+	var cfg Config
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return cfg, newConfigError(path, data, &root, err)
+	}
+	var problems configProblems
+	var typeErr *yaml.TypeError
+	if err := root.Decode(&cfg); errors.As(err, &typeErr) {
+		for _, e := range typeErr.Errors {
+			problems = append(problems, newConfigError(path, data, &root, errors.New(e)))
+		}
+	} else if err != nil {
+		return cfg, newConfigError(path, data, &root, err)
+	}
+	problems = append(problems, unknownConfigKeys(path, &root, reflect.TypeOf(cfg), "")...)
+	if len(problems) > 0 {
+		return cfg, problems
+	}
+	return cfg, nil
+}
+
+// setupConfig loads the config file and applies it to the built-in defaults,
+// reporting every problem it has
+func setupConfig() error {
+	problems := configFileProblems(loadConfig())
+	if _, ok := profileMap[pinnedEnv]; pinnedEnv != "" && !ok {
+		problems = append(problems, fmt.Errorf("cannot pin unknown environment %q", pinnedEnv))
+	}
+	if len(problems) > 0 {
+		return problems
+	}
+	return nil
+}
+
+// setupBuiltInConfig applies the built-in defaults alone, to carry on past a
+// config file with problems
+func setupBuiltInConfig() error {
+	activeContext = ""
+	if err := applyConfig(Config{}); err != nil {
+		return err
+	}
+	// Dropping the pin would open every environment up, prod included
+	if _, ok := profileMap[pinnedEnv]; pinnedEnv != "" && !ok {
+		return fmt.Errorf("the session is pinned to %s, which only the config file defines; fix the config to start", pinnedEnv)
+	}
+	return nil
+}
+
+// applyConfig overrides the built-in environment profiles and tool settings
+// with the ones of the active context
+func applyConfig(cfg Config) error {
+	contextNames = make([]string, 0, len(cfg.Contexts))
+	for name := range cfg.Contexts {
+		contextNames = append(contextNames, name)
+	}
+	sort.Strings(contextNames)
+	ctx, err := activeContextConfig(cfg)
+	if err != nil {
+		return err
+	}
+	if err := applyContext(ctx); err != nil {
+		if activeContext != "" {
+			return underConfigPath(err, "contexts", activeContext)
+		}
+		return err
+	}
+	if cfg.MinVersion != "" {
+		if _, err := parseSemver(cfg.MinVersion); err != nil {
+			return configErrorf([]string{"min_version"}, "%v", err)
+		}
+	}
+	minVersion = cfg.MinVersion
+	if cfg.Locale != "" {
+		if _, ok := numberFormats[cfg.Locale]; !ok {
+			return configErrorf([]string{"locale"}, "%q is not supported", cfg.Locale)
+		}
+		displayLocale = cfg.Locale
+	}
+	if err := setupKeymap(cfg.Keymap); err != nil {
+		return err
+	}
+	if err := setupLogs(cfg.Logs); err != nil {
+		return err
+	}
+	return setupTheme(cfg.Display, cfg.Theme)
+}
+
+// applyContext applies a context's settings over the built-in defaults
+func applyContext(cfg ContextConfig) error {
+	resetDefaults()
+	for env, envCfg := range cfg.Environments {
+		if envCfg.Profile == "" {
+			if _, ok := profileMap[env]; !ok {
+				return configErrorf([]string{"environments", env, "profile"}, "a new environment needs a profile")
+			}
+		} else {
+			profileMap[env] = envCfg.Profile
+		}
+		if envCfg.Region != "" {
+			if !regionPattern.MatchString(envCfg.Region) {
+				return configErrorf([]string{"environments", env, "region"}, "expected a region like us-east-2, got %q", envCfg.Region)
+			}
+			regionMap[env] = envCfg.Region
+		}
+		if envCfg.Function != "" {
+			if problem := functionNameProblem(envCfg.Function); problem != "" {
+				return configErrorf([]string{"environments", env, "function"}, "%q: %s", envCfg.Function, problem)
+			}
+			functionMap[env] = envCfg.Function
+		}
+		if envCfg.Protected != nil {
+			protectedEnvs[env] = *envCfg.Protected
+		}
+		if envCfg.Description != "" {
+			envDescriptions[env] = envCfg.Description
+		}
+		if envCfg.DefaultDryRun != nil {
+			dryRunDefaults[env] = *envCfg.DefaultDryRun
+		}
+	}
+	if cfg.FunctionTemplate != "" {
+		if err := checkFunctionTemplate(cfg.FunctionTemplate); err != nil {
+			return configErrorf([]string{"function_template"}, "%v", err)
+		}
+		sweepstakeFunctionName = cfg.FunctionTemplate
+	}
+	for tool, toolCfg := range cfg.Tools {
+		spec, ok := toolRegistry[tool]
+		if !ok {
+			if toolCfg.FunctionTemplate == "" {
+				return configErrorf([]string{"tools", tool}, "unknown tool, a tool of its own needs a function_template")
+			}
+			spec = &toolSpec{Transport: transportLambda}
+			toolRegistry[tool] = spec
+		}
+		if err := applyToolActions(tool, spec, toolCfg); err != nil {
+			return err
+		}
+		if toolCfg.ProgressPattern != "" {
+			re, err := compileProgressPattern(toolCfg.ProgressPattern)
+			if err != nil {
+				return configErrorf([]string{"tools", tool, "progress_pattern"}, "%v", err)
+			}
+			spec.ProgressPattern = re
+		}
+		switch toolCfg.Transport {
+		case "":
+		case transportLambda, transportHTTP:
+			spec.Transport = toolCfg.Transport
+		default:
+			return configErrorf([]string{"tools", tool, "transport"}, "expected %s or %s, got %q", transportLambda, transportHTTP, toolCfg.Transport)
+		}
+		if spec.Transport == transportHTTP && len(toolCfg.URLs) == 0 {
+			return configErrorf([]string{"tools", tool, "urls"}, "the http transport needs a URL per environment")
+		}
+		spec.URLs = toolCfg.URLs
+		if toolCfg.AsyncThreshold != "" {
+			d, err := time.ParseDuration(toolCfg.AsyncThreshold)
+			if err != nil || d <= 0 {
+				return configErrorf([]string{"tools", tool, "async_threshold"}, "expected a positive duration like 5m, got %q", toolCfg.AsyncThreshold)
+			}
+			spec.AsyncThreshold = d
+		}
+		transform, err := newPayloadTransform(toolCfg.PayloadTransform.Wrapper, toolCfg.PayloadTransform.Template, toolCfg.PayloadTransform.Source)
+		if err != nil {
+			return configErrorf([]string{"tools", tool, "payload_transform"}, "%v", err)
+		}
+		spec.PayloadTransform = transform
+		if toolCfg.VerifyMetric != nil {
+			check, err := newMetricCheck(*toolCfg.VerifyMetric)
+			if err != nil {
+				return configErrorf([]string{"tools", tool, "verify_metric"}, "%v", err)
+			}
+			spec.VerifyMetric = check
+		}
+		spec.Requires = map[Action][]string{}
+		for action, caps := range toolCfg.Requires {
+			switch Action(action) {
+			case ActionProcess, ActionComplete, ActionStart, ActionRollback:
+				spec.Requires[Action(action)] = caps
+			default:
+				return configErrorf([]string{"tools", tool, "requires"}, "unknown action %q", action)
+			}
+		}
+		if toolCfg.SnapshotOverrides != "" {
+			tmpl, err := newSnapshotTemplate(toolCfg.SnapshotOverrides)
+			if err != nil {
+				return configErrorf([]string{"tools", tool, "snapshot_overrides"}, "%v", err)
+			}
+			spec.SnapshotOverrides = tmpl
+		}
+		if toolCfg.Promotion != nil {
+			spec.Promotion = promotionFields{MustMatch: toolCfg.Promotion.MustMatch, MayDiffer: toolCfg.Promotion.MayDiffer}
+		}
+		if toolCfg.Lifecycle != nil {
+			lifecycle, err := newQuestLifecycle(*toolCfg.Lifecycle)
+			if err != nil {
+				return configErrorf([]string{"tools", tool, "lifecycle"}, "%v", err)
+			}
+			spec.Lifecycle = lifecycle
+		}
+		spec.Fields = map[string]fieldHelp{}
+		for name, f := range toolCfg.Fields {
+			spec.Fields[name] = fieldHelp{Help: f.Help, Examples: f.Examples}
+		}
+		spec.Schema = toolCfg.Schema
+		if toolCfg.RewardPool != nil {
+			pool, err := newRewardPool(*toolCfg.RewardPool)
+			if err != nil {
+				return configErrorf([]string{"tools", tool, "reward_pool"}, "%v", err)
+			}
+			spec.RewardPool = pool
+		}
+		if toolCfg.MaxBatchSize < 0 {
+			return configErrorf([]string{"tools", tool, "max_batch_size"}, "expected a positive number, got %d", toolCfg.MaxBatchSize)
+		}
+		if toolCfg.MaxBatchSize > 0 {
+			spec.MaxBatchSize = toolCfg.MaxBatchSize
+		}
+	}
+	if cfg.Budget.ProdDailyLimit < 0 {
+		return configErrorf([]string{"budget", "prod_daily_limit"}, "must not be negative")
+	}
+	prodDailyLimit = cfg.Budget.ProdDailyLimit
+	roleCapabilities = cfg.Roles
+	if err := applySessionConfig(cfg.Session); err != nil {
+		return err
+	}
+	if err := applyApprovalConfig(cfg.Approval); err != nil {
+		return err
+	}
+	if err := applyCacheConfig(cfg.Cache); err != nil {
+		return err
+	}
+	if err := applyDiscoveryConfig(cfg.Discovery); err != nil {
+		return err
+	}
+	if err := applyChecksConfig(cfg.Checks); err != nil {
+		return err
+	}
+	for _, p := range cfg.Presets {
+		switch p.Action {
+		case ActionProcess, ActionComplete, ActionStart:
+		default:
+			return configErrorf([]string{"presets"}, "%q has an unsupported action %q", p.Name, p.Action)
+		}
+	}
+	if err := applyEnvOverrides(); err != nil {
+		return err
+	}
+	applyDiscoveredTools()
+	return validateTemplates()
+}
+
+var yamlLineRe = regexp.MustCompile(`line (\d+): (.*)`)
+
+// newConfigError converts a yaml error into a configError pointing at the
+// offending line, with the column taken from the parsed node where possible
+func newConfigError(path string, data []byte, root *yaml.Node, err error) error {
+	msg := strings.TrimPrefix(err.Error(), "yaml: ")
+	m := yamlLineRe.FindStringSubmatch(msg)
+	if m == nil {
+		return &configError{Path: path, Msg: msg}
+	}
+
+	line, _ := strconv.Atoi(m[1])
+	column := 1
+	if n := findNodeAtLine(root, line); n != nil {
+		column = n.Column
+	}
+	return &configError{
+		Path:    path,
+		Line:    line,
+		Column:  column,
+		Msg:     m[2],
+		Snippet: configSnippet(data, line, column),
+	}
+}
+
+func findNodeAtLine(n *yaml.Node, line int) *yaml.Node {
+	if n == nil {
+		return nil
+	}
+	// Prefer the value over the key on a "key: value" line
+	var found *yaml.Node
+	if n.Line == line && n.Kind == yaml.ScalarNode {
+		found = n
+	}
+	for _, c := range n.Content {
+		if f := findNodeAtLine(c, line); f != nil {
+			found = f
+		}
+	}
+	return found
+}
+
+// configSnippet renders the offending line with its neighbours and a caret
+// under the error column
+func configSnippet(data []byte, line, column int) string {
+	lines := strings.Split(string(data), "\n")
+	if line < 1 || line > len(lines) {
+		return ""
+	}
+
+	var sb strings.Builder
+	for i := max(line-2, 1); i <= min(line+1, len(lines)); i++ {
+		sb.WriteString(fmt.Sprintf("%4d | %s\n", i, lines[i-1]))
+		if i == line {
+			sb.WriteString(fmt.Sprintf("     | %s^\n", strings.Repeat(" ", max(column-1, 0))))
+		}
+	}
+	return strings.TrimRight(sb.String(), "\n")
+}
+
+// formatConfig normalizes the indentation and layout of a config file while
+// keeping its comments
+func formatConfig(path string, data []byte) ([]byte, error) {
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return nil, newConfigError(path, data, &root, err)
+	}
+	if root.Kind == 0 {
+		return data, nil
+	}
+
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(&root); err != nil {
+		return nil, err
+	}
+	if err := enc.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// configErrorModel is the screen the TUI starts on when the config file
+// can't be loaded, instead of any environment. It lists every problem found,
+// and can carry on with the built-in defaults instead.
+type configErrorModel struct {
+	err    error
+	width  int
+	styles Styles
+	// useDefaults is set when leaving to carry on with the built-in defaults
+	useDefaults bool
+}
+
+func (m configErrorModel) Init() tea.Cmd { return nil }
+
+func (m configErrorModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.width = msg.Width
+	case tea.KeyMsg:
+		switch msg.String() {
+		case "q", "ctrl+c", "esc", "enter":
+			return m, tea.Quit
+		case "c":
+			m.useDefaults = true
+			return m, tea.Quit
+		}
+	}
+	return m, nil
+}
+
+func (m configErrorModel) View() string {
+	var sb strings.Builder
+	problems := problemList(m.err)
+	title := tr(msgConfigErrorTitle)
+	if len(problems) > 1 {
+		title = tr(msgConfigErrorProblems, len(problems))
+	}
+	sb.WriteString("\n\n  " + errorStyle.Render(title) + "\n\n")
+	if path, err := configFilePath(); err == nil {
+		sb.WriteString("  " + path + "\n\n")
+	}
+	for _, p := range problems {
+		msg := p.Error()
+		// The path is shown once above, a position is all each one needs
+		if ce, ok := p.(*configError); ok && ce.Line > 0 {
+			msg = fmt.Sprintf("line %d, column %d: %s", ce.Line, ce.Column, ce.Msg)
+			if ce.Snippet != "" {
+				msg += "\n" + ce.Snippet
+			}
+		} else if ok {
+			msg = ce.Msg
+		}
+		if m.width > 10 {
+			msg = wrapText(msg, m.width-10)
+		}
+		sb.WriteString(fmt.Sprintf("  %s %s\n", errorStyle.Render(glyphs.Cross), strings.ReplaceAll(msg, "\n", "\n    ")))
+	}
+	sb.WriteString("\n  " + strings.ReplaceAll(tr(msgConfigErrorFix), "\n", "\n  ") + "\n\n")
+	sb.WriteString("  " + dimStyle.Render(tr(msgConfigErrorHelp)) + "\n")
+	return m.styles.Doc.Render(sb.String())
+}
+
+// runConfigErrorScreen shows why the config couldn't be loaded, reporting
+// whether to carry on with the built-in defaults
+func runConfigErrorScreen(err error) bool {
+	final, runErr := tea.NewProgram(configErrorModel{err: err, styles: themeStyles}, tea.WithAltScreen()).Run()
+	if runErr != nil {
+		fmt.Println("Error loading config:", err)
+		return false
+	}
+	m, _ := final.(configErrorModel)
+	return m.useDefaults
+}
@@ -0,0 +1,292 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"gopkg.in/yaml.v3"
+)
+
+// envNamePattern keeps the environment names config init takes simple
+var envNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_-]*$`)
+
+// initEnv is an environment as answered in config init
+type initEnv struct {
+	Name, Profile, Region string
+}
+
+// awsConfigFile is where the aws CLI reads its profiles from
+func awsConfigFile() (string, error) {
+	if path := os.Getenv("AWS_CONFIG_FILE"); path != "" {
+		return path, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".aws", "config"), nil
+}
+
+// awsConfigProfiles lists the profiles of the AWS config file, sorted, none
+// when there is no file
+func awsConfigProfiles() []string {
+	path, err := awsConfigFile()
+	if err != nil {
+		return nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	var profiles []string
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if !strings.HasPrefix(line, "[") || !strings.HasSuffix(line, "]") {
+			continue
+		}
+		section := strings.TrimSpace(line[1 : len(line)-1])
+		if section == "default" {
+			profiles = append(profiles, section)
+		} else if name, ok := strings.CutPrefix(section, "profile "); ok {
+			profiles = append(profiles, strings.TrimSpace(name))
+		}
+	}
+	sort.Strings(profiles)
+	return slices.Compact(profiles)
+}
+
+// promptDefault asks for a line of text, answered with def when left blank
+func promptDefault(in *bufio.Reader, out io.Writer, question, def string) (string, error) {
+	if def != "" {
+		fmt.Fprintf(out, "%s [%s]: ", question, def)
+	} else {
+		fmt.Fprintf(out, "%s: ", question)
+	}
+	line, err := in.ReadString('\n')
+	if err != nil && line == "" {
+		return "", errors.New("no value entered")
+	}
+	if answer := strings.TrimSpace(line); answer != "" {
+		return answer, nil
+	}
+	return def, nil
+}
+
+// runConfigInitCommand handles `playtools config init`, asking for the
+// environments, their profiles and regions and the function template, then
+// writing them as a commented config file
+func runConfigInitCommand(args []string, stdin io.Reader, stdout io.Writer) int {
+	fs := flag.NewFlagSet("config init", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	force := fs.Bool("force", false, "")
+	if err := fs.Parse(args); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n\n%s", err, usageText)
+		return exitUsage
+	}
+	path, err := configFilePath()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitFailure
+	}
+	if _, err := os.Stat(path); err == nil && !*force {
+		fmt.Fprintf(os.Stderr, "Error: %s already exists, --force replaces it\n", path)
+		return exitUsage
+	}
+
+	in := bufio.NewReader(stdin)
+	envs, template, err := askInitConfig(in, stdout)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitUsage
+	}
+	data := renderInitConfig(envs, template)
+	// What was written is checked as it will be loaded, so a mistake shows
+	// here rather than on the next start
+	if problems := configFileProblems(parseConfig(path, data)); len(problems) > 0 {
+		fmt.Fprintf(os.Stderr, "Error: the answers don't make a valid config:\n%v\n", problems)
+		return exitFailure
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitFailure
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitFailure
+	}
+	fmt.Fprintf(stdout, "\nWrote %s, `playtools config validate` checks it after editing.\n", path)
+	return 0
+}
+
+// askInitConfig asks config init's questions, offering the profiles of the
+// AWS config file and the built-in values as defaults
+func askInitConfig(in *bufio.Reader, out io.Writer) ([]initEnv, string, error) {
+	builtin := make([]string, 0, len(builtinProfiles))
+	for env := range builtinProfiles {
+		builtin = append(builtin, env)
+	}
+	sort.Strings(builtin)
+
+	var names []string
+	for names == nil {
+		answer, err := promptDefault(in, out, "Environments, separated by commas", strings.Join(builtin, ", "))
+		if err != nil {
+			return nil, "", err
+		}
+		names = []string{}
+		for _, name := range strings.Split(answer, ",") {
+			name = strings.TrimSpace(name)
+			if !envNamePattern.MatchString(name) {
+				fmt.Fprintf(out, "%q isn't an environment name, use letters, digits, '-' and '_'\n", name)
+				names = nil
+				break
+			}
+			if !slices.Contains(names, name) {
+				names = append(names, name)
+			}
+		}
+	}
+
+	profiles := awsConfigProfiles()
+	if len(profiles) > 0 {
+		fmt.Fprintln(out, "\nProfiles in your AWS config:")
+		for i, p := range profiles {
+			fmt.Fprintf(out, "  %d) %s\n", i+1, p)
+		}
+	}
+	var envs []initEnv
+	for _, name := range names {
+		fmt.Fprintln(out)
+		env := initEnv{Name: name}
+		for env.Profile == "" {
+			answer, err := promptDefault(in, out, fmt.Sprintf("Profile for %s", name), builtinProfiles[name])
+			if err != nil {
+				return nil, "", err
+			}
+			if n, convErr := strconv.Atoi(answer); convErr == nil && n >= 1 && n <= len(profiles) {
+				answer = profiles[n-1]
+			}
+			if answer != "" && len(profiles) > 0 && !slices.Contains(profiles, answer) {
+				fmt.Fprintf(out, "%s isn't in your AWS config, add it before using %s\n", answer, name)
+			}
+			env.Profile = answer
+		}
+		for {
+			answer, err := promptDefault(in, out, fmt.Sprintf("Region for %s, blank for the profile's", name), "")
+			if err != nil {
+				return nil, "", err
+			}
+			if answer == "" || regionPattern.MatchString(answer) {
+				env.Region = answer
+				break
+			}
+			fmt.Fprintf(out, "Expected a region like us-east-2, got %q\n", answer)
+		}
+		envs = append(envs, env)
+	}
+
+	fmt.Fprintln(out)
+	for {
+		template, err := promptDefault(in, out, "Function template, %s being the environment", builtinFunctionTemplate)
+		if err != nil {
+			return nil, "", err
+		}
+		if err := checkFunctionTemplate(template); err != nil {
+			fmt.Fprintln(out, err)
+			continue
+		}
+		return envs, template, nil
+	}
+}
+
+// renderInitConfig writes config init's answers as a config file, with
+// comments on what each setting does
+func renderInitConfig(envs []initEnv, template string) []byte {
+	var sb strings.Builder
+	sb.WriteString("# playtools config, written by `playtools config init`. The README lists\n")
+	sb.WriteString("# every other setting, and `playtools config validate` checks the file.\n\n")
+	sb.WriteString("# The function invoked in each environment, %s being the environment\n")
+	sb.WriteString("function_template: " + yamlScalar(template) + "\n\n")
+	sb.WriteString("# Each environment's AWS profile, and its region when it isn't the one of\n")
+	sb.WriteString("# the profile. A protected environment asks to type a confirmation before\n")
+	sb.WriteString("# it invokes, prod being protected unless it says otherwise.\n")
+	sb.WriteString("environments:\n")
+	for _, env := range envs {
+		sb.WriteString(fmt.Sprintf("  %s:\n", yamlScalar(env.Name)))
+		sb.WriteString(fmt.Sprintf("    profile: %s\n", yamlScalar(env.Profile)))
+		if env.Region != "" {
+			sb.WriteString(fmt.Sprintf("    region: %s\n", env.Region))
+		} else {
+			sb.WriteString("    # region: us-east-2\n")
+		}
+		if env.Name != prodEnv {
+			sb.WriteString("    # protected: true\n")
+		}
+	}
+	return []byte(sb.String())
+}
+
+// yamlScalar renders s as a YAML value, quoted when it has to be
+func yamlScalar(s string) string {
+	data, err := yaml.Marshal(s)
+	if err != nil {
+		return strconv.Quote(s)
+	}
+	return strings.TrimSuffix(string(data), "\n")
+}
+
+// configFileExists reports whether there is a config file to load
+func configFileExists() bool {
+	path, err := configFilePath()
+	if err != nil {
+		return false
+	}
+	_, err = os.Stat(path)
+	return err == nil
+}
+
+// configInitMsg is sent when the config init wizard the TUI ran exits
+type configInitMsg struct {
+	err error
+}
+
+// runConfigInit suspends the TUI to run `playtools config init` in the
+// terminal
+func runConfigInit() tea.Cmd {
+	exe, err := os.Executable()
+	if err != nil {
+		return func() tea.Msg { return configInitMsg{err: err} }
+	}
+	return tea.ExecProcess(exec.Command(exe, "config", "init"), func(err error) tea.Msg {
+		return configInitMsg{err: err}
+	})
+}
+
+// finishConfigInit loads the config the wizard wrote, starting over on the
+// environments it set up
+func (m model) finishConfigInit(msg configInitMsg) (tea.Model, tea.Cmd) {
+	if msg.err == nil && !configFileExists() {
+		msg.err = errors.New("no config file was written")
+	}
+	if msg.err == nil {
+		msg.err = setupConfig()
+	}
+	if msg.err != nil {
+		return m, m.envList.NewStatusMessage(errorStyle.Render(fmt.Sprintf("Config init: %v", problemList(msg.err)[0])))
+	}
+	next := initialModel()
+	next.width, next.height = m.width, m.height
+	return next, func() tea.Msg { return tea.WindowSizeMsg{Width: m.width, Height: m.height} }
+}
@@ -0,0 +1,222 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// configProblems is every problem found in the config file, rather than the
+// first one
+type configProblems []error
+
+func (p configProblems) Error() string {
+	msgs := make([]string, len(p))
+	for i, err := range p {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "\n")
+}
+
+// configPathError is a problem with the setting at Path, the keys leading to
+// it in the config file. The keys are kept apart, so an environment named
+// qa.eu is a single key.
+type configPathError struct {
+	Path []string
+	Msg  string
+}
+
+func (e *configPathError) Error() string {
+	return strings.Join(e.Path, ".") + ": " + e.Msg
+}
+
+// configErrorf is a problem with the setting at path
+func configErrorf(path []string, format string, args ...any) error {
+	return &configPathError{Path: path, Msg: fmt.Sprintf(format, args...)}
+}
+
+// underConfigPath moves the problems of err under prefix, like the problems
+// of a context under contexts.<name>
+func underConfigPath(err error, prefix ...string) error {
+	var problems configProblems
+	if errors.As(err, &problems) {
+		moved := make(configProblems, len(problems))
+		for i, p := range problems {
+			moved[i] = underConfigPath(p, prefix...)
+		}
+		return moved
+	}
+	var pathErr *configPathError
+	if errors.As(err, &pathErr) {
+		return &configPathError{Path: append(slices.Clone(prefix), pathErr.Path...), Msg: pathErr.Msg}
+	}
+	return configErrorf(prefix, "%v", err)
+}
+
+// problemList splits an error into the problems it holds
+func problemList(err error) []error {
+	var problems configProblems
+	if errors.As(err, &problems) {
+		return problems
+	}
+	return []error{err}
+}
+
+// unknownConfigKeys reports the keys of node that no field of t decodes,
+// each with the path of the key and its position in the file
+func unknownConfigKeys(path string, node *yaml.Node, t reflect.Type, prefix string) []error {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	var problems []error
+	switch {
+	case node.Kind == yaml.DocumentNode:
+		for _, c := range node.Content {
+			problems = append(problems, unknownConfigKeys(path, c, t, prefix)...)
+		}
+	case node.Kind == yaml.MappingNode && t.Kind() == reflect.Struct:
+		fields := map[string]reflect.Type{}
+		yamlFields(t, fields)
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			ft, ok := fields[key.Value]
+			if !ok {
+				problems = append(problems, &configError{Path: path, Line: key.Line, Column: key.Column, Msg: fmt.Sprintf("%s: unknown key", joinConfigPath(prefix, key.Value))})
+				continue
+			}
+			problems = append(problems, unknownConfigKeys(path, value, ft, joinConfigPath(prefix, key.Value))...)
+		}
+	case node.Kind == yaml.MappingNode && t.Kind() == reflect.Map:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			problems = append(problems, unknownConfigKeys(path, node.Content[i+1], t.Elem(), joinConfigPath(prefix, node.Content[i].Value))...)
+		}
+	case node.Kind == yaml.SequenceNode && t.Kind() == reflect.Slice:
+		for i, c := range node.Content {
+			problems = append(problems, unknownConfigKeys(path, c, t.Elem(), fmt.Sprintf("%s[%d]", prefix, i))...)
+		}
+	}
+	return problems
+}
+
+// yamlFields maps the keys a struct decodes to their types, with the fields
+// of inlined structs as its own
+func yamlFields(t reflect.Type, fields map[string]reflect.Type) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		name, opts, _ := strings.Cut(f.Tag.Get("yaml"), ",")
+		switch {
+		case name == "-":
+		case opts == "inline":
+			yamlFields(f.Type, fields)
+		case name == "":
+			fields[strings.ToLower(f.Name)] = f.Type
+		default:
+			fields[name] = f.Type
+		}
+	}
+}
+
+func joinConfigPath(prefix, key string) string {
+	if prefix == "" {
+		return key
+	}
+	return prefix + "." + key
+}
+
+// configFileProblems is every problem of a config file as parseConfig
+// returns it: the keys and values that don't decode along with the settings
+// of the rest that don't apply. A file that isn't YAML has only its syntax
+// error.
+func configFileProblems(cfg Config, err error) configProblems {
+	var problems configProblems
+	if err != nil && !errors.As(err, &problems) {
+		return configProblems{err}
+	}
+	return append(slices.Clone(problems), collectConfigProblems(cfg)...)
+}
+
+// collectConfigProblems applies cfg, and after each problem leaves out the
+// part of the config its path names and applies the rest again, so every
+// part with a problem is reported. What's left of cfg stays applied.
+func collectConfigProblems(cfg Config) configProblems {
+	var problems configProblems
+	seen := map[string]bool{}
+	for {
+		err := applyConfig(cfg)
+		if err == nil {
+			return problems
+		}
+		pruned := false
+		for _, p := range problemList(err) {
+			if !seen[p.Error()] {
+				seen[p.Error()] = true
+				problems = append(problems, p)
+			}
+			var pathErr *configPathError
+			if errors.As(p, &pathErr) && pruneConfig(&cfg, pathErr.Path) {
+				pruned = true
+			}
+		}
+		if !pruned {
+			return problems
+		}
+	}
+}
+
+// pruneConfig leaves out the part of cfg at path: the environment or tool
+// of tools.sweepstake.transport, or the section of budget.prod_daily_limit,
+// reporting whether anything was left out
+func pruneConfig(cfg *Config, path []string) bool {
+	return pruneStruct(reflect.ValueOf(cfg).Elem(), path)
+}
+
+func pruneStruct(v reflect.Value, path []string) bool {
+	if len(path) == 0 {
+		return false
+	}
+	for i := 0; i < v.NumField(); i++ {
+		f := v.Type().Field(i)
+		tag, opts, _ := strings.Cut(f.Tag.Get("yaml"), ",")
+		if opts == "inline" {
+			if pruneStruct(v.Field(i), path) {
+				return true
+			}
+			continue
+		}
+		if tag != path[0] {
+			continue
+		}
+		field := v.Field(i)
+		if field.IsZero() {
+			return false
+		}
+		if field.Kind() == reflect.Map && len(path) > 1 {
+			k := reflect.ValueOf(path[1]).Convert(field.Type().Key())
+			if entry := field.MapIndex(k); entry.IsValid() {
+				// The map is the caller's too, so a copy goes without the entry
+				pruned := reflect.MakeMapWithSize(field.Type(), field.Len())
+				for iter := field.MapRange(); iter.Next(); {
+					if iter.Key().Interface() != k.Interface() {
+						pruned.SetMapIndex(iter.Key(), iter.Value())
+					}
+				}
+				// A context keeps what doesn't have the problem
+				if ctx, ok := entry.Interface().(ContextConfig); ok && pruneStruct(reflect.ValueOf(&ctx).Elem(), path[2:]) {
+					pruned.SetMapIndex(k, reflect.ValueOf(ctx))
+				}
+				field.Set(pruned)
+				return true
+			}
+		}
+		field.Set(reflect.Zero(field.Type()))
+		return true
+	}
+	return false
+}
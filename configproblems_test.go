@@ -0,0 +1,135 @@
+package main
+
+import (
+	"maps"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// fileProblems parses data as the config file and applies it, returning the
+// first line of every problem. The defaults are back after the test.
+func fileProblems(t *testing.T, data string) []string {
+	t.Helper()
+	t.Cleanup(func() {
+		activeContext = ""
+		if err := applyConfig(Config{}); err != nil {
+			t.Fatal(err)
+		}
+	})
+	var lines []string
+	for _, p := range configFileProblems(parseConfig("config.yaml", []byte(data))) {
+		first, _, _ := strings.Cut(p.Error(), "\n")
+		lines = append(lines, first)
+	}
+	return lines
+}
+
+func TestConfigEmptyFiles(t *testing.T) {
+	for name, data := range map[string]string{
+		"empty":         "",
+		"blank lines":   "\n\n  \n",
+		"comments only": "# environments:\n#   qa:\n#     profile: qa\n",
+		"document mark": "---\n",
+		"null":          "~\n",
+		"empty mapping": "{}\n",
+		"empty section": "environments:\ntools:\n",
+	} {
+		t.Run(name, func(t *testing.T) {
+			if problems := fileProblems(t, data); problems != nil {
+				t.Errorf("problems %q", problems)
+			}
+			if !maps.Equal(profileMap, builtinProfiles) {
+				t.Errorf("profiles %v, want the built-in ones", profileMap)
+			}
+		})
+	}
+}
+
+func TestConfigUnknownKeys(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want []string
+	}{
+		{"top level", "enviroments:\n  qa:\n    profile: qa\n",
+			[]string{"config.yaml:1:1: enviroments: unknown key"}},
+		{"environment", "environments:\n  qa:\n    profile: qa\n    regoin: eu-west-1\n",
+			[]string{"config.yaml:4:5: environments.qa.regoin: unknown key"}},
+		// The typo and the problem it causes are both reported
+		{"with a problem of the rest", "environments:\n  qa:\n    profle: qa\n",
+			[]string{
+				"config.yaml:3:5: environments.qa.profle: unknown key",
+				"environments.qa.profile: a new environment needs a profile",
+			}},
+		{"tool", "tools:\n  sweepstake:\n    transprt: http\n",
+			[]string{"config.yaml:3:5: tools.sweepstake.transprt: unknown key"}},
+		{"list item", "tools:\n  rewards:\n    function_template: imx-rewards-%s\n    actions:\n      - name: grant\n        prompt: []\n",
+			[]string{"config.yaml:6:9: tools.rewards.actions[0].prompt: unknown key"}},
+		{"context", "contexts:\n  rewards:\n    budget:\n      prod_limit: 3\n",
+			[]string{"config.yaml:4:7: contexts.rewards.budget.prod_limit: unknown key"}},
+		{"several", "locales: fr\nenvironments:\n  dev:\n    protect: false\n",
+			[]string{
+				"config.yaml:1:1: locales: unknown key",
+				"config.yaml:4:5: environments.dev.protect: unknown key",
+			}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := fileProblems(t, tt.data); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("problems\n%q\nwant\n%q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConfigPartial(t *testing.T) {
+	tests := []struct {
+		name     string
+		data     string
+		want     []string
+		profiles map[string]string
+	}{
+		{"one environment", "environments:\n  qa:\n    profile: qa-engineer\n",
+			nil, map[string]string{"qa": "qa-engineer"}},
+		// A name with dots is one key, left out whole
+		{"dotted environment", "environments:\n  qa.eu:\n    profile: qa-eu\n    region: europe\n  load:\n    profile: load-tester\n",
+			[]string{`environments.qa.eu.region: expected a region like us-east-2, got "europe"`},
+			map[string]string{"load": "load-tester"}},
+		{"a value of the wrong type", "environments:\n  qa:\n    profile: qa-engineer\n    protected: maybe\n  load:\n    profile: [a, b]\n",
+			[]string{
+				"config.yaml:4:16: cannot unmarshal !!str `maybe` into bool",
+				"config.yaml:6:18: cannot unmarshal !!seq into string",
+				"environments.load.profile: a new environment needs a profile",
+			},
+			map[string]string{"qa": "qa-engineer"}},
+		{"problems in several sections", "environments:\n  qa:\n    profile: qa-engineer\nbudget:\n  prod_daily_limit: -1\nlocale: xx\ntools:\n  sweepstake:\n    transport: ftp\n",
+			[]string{
+				"budget.prod_daily_limit: must not be negative",
+				`locale: "xx" is not supported`,
+				`tools.sweepstake.transport: expected lambda or http, got "ftp"`,
+			},
+			map[string]string{"qa": "qa-engineer"}},
+		{"checks of an unknown environment", "environments:\n  qa:\n    profile: qa-engineer\nchecks:\n  staging:\n    disabled: [budget]\n  qa:\n    disabled: [version]\n",
+			[]string{"checks.qa.disabled: version can't be disabled", "checks.staging: unknown environment"},
+			map[string]string{"qa": "qa-engineer"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Map order decides which of two problems in one section is
+			// found first
+			got := fileProblems(t, tt.data)
+			slices.Sort(got)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("problems\n%q\nwant\n%q", got, tt.want)
+			}
+			// What has no problem stays applied
+			want := maps.Clone(builtinProfiles)
+			maps.Copy(want, tt.profiles)
+			if !maps.Equal(profileMap, want) {
+				t.Errorf("profiles %v, want %v", profileMap, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/config"
+)
+
+// overrideEnvVars are the environment variables that change what playtools
+// hits, with what each one does
+var overrideEnvVars = []struct {
+	name, effect string
+}{
+	{contextEnvVar, "selects the context"},
+	{pinEnvVar, "pins the environment"},
+	{functionTemplateEnvVar, "sets the function name template"},
+	{"XDG_CONFIG_HOME", "moves the config file and state"},
+	{"AWS_REGION", "sets the region of every profile"},
+	{"AWS_DEFAULT_REGION", "sets the region of every profile unless AWS_REGION does"},
+	{"AWS_CONFIG_FILE", "moves the AWS config file the profiles come from"},
+	{"AWS_SHARED_CREDENTIALS_FILE", "moves the AWS credentials file"},
+}
+
+// profileRegion is the region a profile resolves to, as the SDK resolves
+// it for an invocation
+func profileRegion(profile string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	cfg, err := config.LoadDefaultConfig(ctx, config.WithSharedConfigProfile(profile))
+	if err != nil {
+		return "", err
+	}
+	if cfg.Region == "" {
+		return "", errors.New("no region set")
+	}
+	return cfg.Region, nil
+}
+
+// runConfigShowCommand handles `playtools config show`, printing where each
+// environment's invocations go and which values are built-in defaults
+func runConfigShowCommand(args []string, stdout io.Writer) int {
+	if len(args) > 0 {
+		fmt.Fprintf(os.Stderr, "Error: config show takes no arguments\n")
+		return exitUsage
+	}
+	path, err := configFilePath()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitFailure
+	}
+	cfg, err := loadConfig()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading config: %v\n", err)
+		return exitFailure
+	}
+	if err := applyConfig(cfg); err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading config: %v\n", err)
+		return exitFailure
+	}
+	ctxCfg, _ := activeContextConfig(cfg)
+
+	if _, err := os.Stat(path); err != nil {
+		fmt.Fprintf(stdout, "Config file: %s (not found, built-in defaults only)\n", path)
+	} else {
+		fmt.Fprintf(stdout, "Config file: %s\n", path)
+	}
+	switch {
+	case activeContext == "":
+		fmt.Fprintf(stdout, "Context:     %s\n", defaultContext)
+	case os.Getenv(contextEnvVar) == activeContext:
+		fmt.Fprintf(stdout, "Context:     %s (from %s)\n", activeContext, contextEnvVar)
+	default:
+		fmt.Fprintf(stdout, "Context:     %s (from --context)\n", activeContext)
+	}
+	switch {
+	case pinnedEnv == "":
+	case os.Getenv(pinEnvVar) == pinnedEnv:
+		fmt.Fprintf(stdout, "Pinned:      %s (from %s)\n", pinnedEnv, pinEnvVar)
+	default:
+		fmt.Fprintf(stdout, "Pinned:      %s (from --pin-env)\n", pinnedEnv)
+	}
+
+	envs := make([]string, 0, len(profileMap))
+	for env := range profileMap {
+		envs = append(envs, env)
+	}
+	sort.Strings(envs)
+	tool := toolRegistry[sweepstakeTool]
+	for _, env := range envs {
+		profile := profileMap[env]
+		fmt.Fprintf(stdout, "\n%s\n", env)
+		source := "built-in"
+		switch {
+		case profileOverride(env) != "":
+			source = profileOverride(env)
+		case ctxCfg.Environments[env].Profile != "":
+			source = "config"
+		}
+		fmt.Fprintf(stdout, "  profile:  %s (%s)\n", profile, source)
+		if tool.Transport == transportHTTP {
+			fmt.Fprintf(stdout, "  url:      %s (config, http transport)\n", tool.URLs[env])
+		} else {
+			source := "built-in"
+			switch {
+			case functionMap[env] != "":
+				source = fmt.Sprintf("config, environments.%s.function", env)
+			case readEnvOverrides().functionTemplate != "":
+				source = functionTemplateEnvVar
+			case ctxCfg.FunctionTemplate != "":
+				source = "config, function_template"
+			}
+			fmt.Fprintf(stdout, "  function: %s (%s)\n", sweepstakeFunction(env), source)
+		}
+		region, err := profileRegion(profile)
+		switch {
+		case regionMap[env] != "":
+			fmt.Fprintf(stdout, "  region:   %s (config)\n", regionMap[env])
+		case err != nil:
+			fmt.Fprintf(stdout, "  region:   unresolved, %v\n", err)
+		case os.Getenv("AWS_REGION") != "":
+			fmt.Fprintf(stdout, "  region:   %s (AWS_REGION)\n", region)
+		case os.Getenv("AWS_DEFAULT_REGION") != "":
+			fmt.Fprintf(stdout, "  region:   %s (AWS_DEFAULT_REGION)\n", region)
+		default:
+			fmt.Fprintf(stdout, "  region:   %s (AWS config)\n", region)
+		}
+	}
+
+	fmt.Fprintln(stdout, "\nOverrides:")
+	found := false
+	for _, v := range overrideEnvVars {
+		if value := os.Getenv(v.name); value != "" {
+			fmt.Fprintf(stdout, "  %s=%s %s\n", v.name, value, v.effect)
+			found = true
+		}
+	}
+	var profileVars []string
+	for name := range readEnvOverrides().profiles {
+		profileVars = append(profileVars, name)
+	}
+	sort.Strings(profileVars)
+	for _, name := range profileVars {
+		fmt.Fprintf(stdout, "  %s=%s sets a profile\n", name, os.Getenv(name))
+		found = true
+	}
+	if !found {
+		fmt.Fprintln(stdout, "  none from the environment")
+	}
+	return 0
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"fmt"
+	"maps"
+	"path/filepath"
+
+	"github.com/charmbracelet/bubbles/list"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// contextEnvVar selects the context like --context
+const contextEnvVar = "PLAYTOOLS_CONTEXT"
+
+// defaultContext names the context of the top level of the config file
+const defaultContext = "default"
+
+// activeContext is the context in use, empty for the default one, and
+// contextNames lists the named contexts of the config file
+var (
+	activeContext string
+	contextNames  []string
+)
+
+// The built-in environments and tools, which every context starts from
+var (
+	builtinProfiles = maps.Clone(profileMap)
+	builtinTools    = cloneTools(toolRegistry)
+)
+
+func cloneTools(tools map[string]*toolSpec) map[string]*toolSpec {
+	clone := make(map[string]*toolSpec, len(tools))
+	for name, spec := range tools {
+		s := *spec
+		clone[name] = &s
+	}
+	return clone
+}
+
+// resetDefaults undoes the settings of a previously applied context
+func resetDefaults() {
+	profileMap = maps.Clone(builtinProfiles)
+	regionMap = map[string]string{}
+	toolRegistry = cloneTools(builtinTools)
+	sweepstakeFunctionName = builtinFunctionTemplate
+	functionMap = map[string]string{}
+	protectedEnvs = map[string]bool{prodEnv: true}
+	envDescriptions = map[string]string{}
+	dryRunDefaults = map[string]bool{}
+}
+
+// activeContextConfig picks the active context out of the config
+func activeContextConfig(cfg Config) (ContextConfig, error) {
+	if activeContext == "" {
+		return cfg.ContextConfig, nil
+	}
+	ctx, ok := cfg.Contexts[activeContext]
+	if !ok {
+		return ctx, fmt.Errorf("unknown context %q", activeContext)
+	}
+	return ctx, nil
+}
+
+// contextLabel is the active context's name for display
+func contextLabel() string {
+	if activeContext == "" {
+		return defaultContext
+	}
+	return activeContext
+}
+
+// dataDir holds the history and state of the active context, so quest IDs
+// of one deployment never show up in another's. The default context keeps
+// them in the config directory itself.
+func dataDir() (string, error) {
+	dir, err := configDir()
+	if err != nil || activeContext == "" {
+		return dir, err
+	}
+	return filepath.Join(dir, "contexts", activeContext), nil
+}
+
+// contextItem is an entry of the context switcher
+type contextItem struct {
+	name   string
+	active bool
+}
+
+func (i contextItem) Title() string {
+	if i.active {
+		return i.name + " (active)"
+	}
+	return i.name
+}
+
+func (i contextItem) Description() string {
+	if i.name == defaultContext {
+		return "The top level of the config file"
+	}
+	return "contexts." + i.name + " of the config file"
+}
+
+func (i contextItem) FilterValue() string { return i.name }
+
+// openContexts shows the context switcher over the current screen
+func (m model) openContexts() (tea.Model, tea.Cmd) {
+	items := []list.Item{contextItem{name: defaultContext, active: activeContext == ""}}
+	selected := 0
+	for i, name := range contextNames {
+		items = append(items, contextItem{name: name, active: name == activeContext})
+		if name == activeContext {
+			selected = i + 1
+		}
+	}
+	m.contextList.SetItems(items)
+	m.contextList.Select(selected)
+	m.contextReturn = m.currentScreen
+	m.currentScreen = ContextScreen
+	return m, nil
+}
+
+// updateContexts handles the keys of the context switcher
+func (m model) updateContexts(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	if !m.contextList.SettingFilter() {
+		switch keys.name(msg) {
+		case "b", "esc":
+			m.currentScreen = m.contextReturn
+			return m, nil
+		case "ctrl+c", "q":
+			return m, tea.Quit
+		case "enter":
+			if i, ok := m.contextList.SelectedItem().(contextItem); ok {
+				return m.switchContext(i.name)
+			}
+			return m, nil
+		}
+	}
+	var cmd tea.Cmd
+	m.contextList, cmd = m.contextList.Update(msg)
+	return m, cmd
+}
+
+// switchContext reloads the config for another context and starts over in
+// it with its own history and state
+func (m model) switchContext(name string) (tea.Model, tea.Cmd) {
+	if name == defaultContext {
+		name = ""
+	}
+	if name == activeContext {
+		m.currentScreen = m.contextReturn
+		return m, nil
+	}
+	previous := activeContext
+	activeContext = name
+	if err := setupConfig(); err != nil {
+		activeContext = previous
+		setupConfig()
+		return m, m.contextList.NewStatusMessage(fmt.Sprintf("Can't switch: %v", err))
+	}
+	m.setLogs("")
+	recordSessionEvent(sessionEvent{Event: "switch_context"})
+
+	next := initialModel()
+	next.width, next.height = m.width, m.height
+	cmds := []tea.Cmd{func() tea.Msg { return tea.WindowSizeMsg{Width: m.width, Height: m.height} }}
+	// Init isn't used as the idle checks are already ticking
+	if next.currentScreen == ActionScreen {
+		cmds = append(cmds, next.refreshEnvInfo())
+	}
+	return next, tea.Batch(cmds...)
+}
@@ -0,0 +1,516 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/lambda"
+	"github.com/charmbracelet/bubbles/list"
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// toolAction is an action of a configured tool
+type toolAction struct {
+	Name        string
+	Description string
+	Prompts     []toolPrompt
+}
+
+// toolPrompt is a payload field asked for on an action's form
+type toolPrompt struct {
+	Field, Label, Type string
+	Required           bool
+}
+
+// applyToolActions validates and applies the function template and actions
+// that make a configured tool of its own
+func applyToolActions(name string, spec *toolSpec, cfg ToolConfig) error {
+	if name == sweepstakeTool {
+		switch {
+		case cfg.FunctionTemplate != "":
+			return configErrorf([]string{"tools", name, "function_template"}, "the sweepstake function is named by the top level function_template")
+		case len(cfg.Actions) > 0:
+			return configErrorf([]string{"tools", name, "actions"}, "the sweepstake actions are built in")
+		}
+		return nil
+	}
+	if err := checkFunctionTemplate(cfg.FunctionTemplate); err != nil {
+		return configErrorf([]string{"tools", name, "function_template"}, "%v", err)
+	}
+	if cfg.Transport == transportHTTP {
+		return configErrorf([]string{"tools", name, "transport"}, "a configured tool is invoked over lambda")
+	}
+	if len(cfg.Actions) == 0 {
+		return configErrorf([]string{"tools", name, "actions"}, "a tool needs at least one action")
+	}
+	spec.FunctionTemplate = cfg.FunctionTemplate
+	spec.Description = cfg.Description
+	spec.Actions = nil
+	seen := map[string]bool{}
+	for i, a := range cfg.Actions {
+		if a.Name == "" || seen[a.Name] {
+			return configErrorf([]string{"tools", name, fmt.Sprintf("actions[%d]", i), "name"}, "expected a unique name, got %q", a.Name)
+		}
+		seen[a.Name] = true
+		action := toolAction{Name: a.Name, Description: a.Description}
+		fields := map[string]bool{"action": true}
+		for j, p := range a.Prompts {
+			if p.Field == "" || fields[p.Field] {
+				return configErrorf([]string{"tools", name, fmt.Sprintf("actions[%d]", i), fmt.Sprintf("prompts[%d]", j), "field"}, "expected a unique field other than action, got %q", p.Field)
+			}
+			fields[p.Field] = true
+			switch p.Type {
+			case "", "string", "int", "bool":
+			default:
+				return configErrorf([]string{"tools", name, fmt.Sprintf("actions[%d]", i), fmt.Sprintf("prompts[%d]", j), "type"}, "expected string, int or bool, got %q", p.Type)
+			}
+			action.Prompts = append(action.Prompts, toolPrompt{Field: p.Field, Label: cmpOr(p.Label, p.Field), Type: cmpOr(p.Type, "string"), Required: p.Required})
+		}
+		spec.Actions = append(spec.Actions, action)
+	}
+	return nil
+}
+
+func cmpOr(s, fallback string) string {
+	if s == "" {
+		return fallback
+	}
+	return s
+}
+
+// function is the name of a configured tool's function in env
+func (t *toolSpec) function(env string) string {
+	return fmt.Sprintf(t.FunctionTemplate, env)
+}
+
+// configuredTools are the names of the tools of their own, sorted
+func configuredTools() []string {
+	var names []string
+	for name, spec := range toolRegistry {
+		if spec.FunctionTemplate != "" {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// payload builds the action's payload from its form values, one per prompt
+func (a toolAction) payload(values []string) ([]byte, error) {
+	payload := map[string]interface{}{"action": a.Name}
+	for i, p := range a.Prompts {
+		v := strings.TrimSpace(values[i])
+		if v == "" {
+			if p.Required {
+				return nil, fmt.Errorf("%s is required", p.Label)
+			}
+			continue
+		}
+		switch p.Type {
+		case "int":
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				return nil, fmt.Errorf("%s: enter a whole number", p.Label)
+			}
+			payload[p.Field] = n
+		case "bool":
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				return nil, fmt.Errorf("%s: enter true or false", p.Label)
+			}
+			payload[p.Field] = b
+		default:
+			payload[p.Field] = v
+		}
+	}
+	return json.Marshal(payload)
+}
+
+// invokeTool invokes a configured tool's function with a built payload,
+// synchronously and with the same SSO and function checks as the sweepstake
+// calculator
+func invokeTool(ctx context.Context, inv *invocation) error {
+	profile := profileMap[inv.Env]
+	var err error
+	if inv.SSOLogin, err = checkSSOSession(profile, nil); err != nil {
+		return err
+	}
+	cfg, err := config.LoadDefaultConfig(ctx, config.WithSharedConfigProfile(profile), envRegion(inv.Env), withSkewRecording())
+	if err != nil {
+		return fmt.Errorf("failed to load AWS config: %w", err)
+	}
+	client := lambda.NewFromConfig(cfg)
+	if err := checkFunctionExists(ctx, client, inv.Env, inv.FunctionName); err != nil {
+		return err
+	}
+	inv.StartedAt = time.Now()
+	result, err := invokeFunction(ctx, client, inv.FunctionName, inv.SentPayload)
+	inv.Duration = time.Since(inv.StartedAt)
+	if err != nil {
+		return fmt.Errorf("failed to invoke Lambda: %w", err)
+	}
+	inv.RequestID = result.RequestID
+	inv.setResponse(result.Payload, result.StatusCode)
+	inv.FunctionError, inv.Logs = result.FunctionError, result.Logs
+	if result.LogsErr != nil {
+		inv.LogsErr = result.LogsErr.Error()
+	}
+	return nil
+}
+
+// toolResultMsg is the outcome of a configured tool's invocation
+type toolResultMsg struct {
+	gen int
+	inv *invocation
+	err error
+}
+
+func invokeToolCmd(gen int, inv *invocation) tea.Cmd {
+	return func() tea.Msg {
+		err := invokeTool(context.Background(), inv)
+		_ = appendHistory(newHistoryRecord(inv, err))
+		return toolResultMsg{gen: gen, inv: inv, err: err}
+	}
+}
+
+// toolStage is where the tool screen is in running an action
+type toolStage int
+
+const (
+	toolActions toolStage = iota
+	toolForm
+	toolConfirm
+	toolRunning
+	toolOutput
+)
+
+// toolScreen runs the actions of a configured tool: pick an action, fill in
+// its prompts, confirm the payload and read the response
+type toolScreen struct {
+	tool    string
+	stage   toolStage
+	actions list.Model
+	action  toolAction
+	form    form
+	payload []byte
+	// acking is set while typing the phrase confirming an invocation in a
+	// protected environment
+	acking  bool
+	ack     textinput.Model
+	message string
+	inv     *invocation
+	err     error
+}
+
+// toolItem is an entry of the tool selection screen
+type toolItem struct {
+	name, desc string
+}
+
+func (i toolItem) Title() string       { return i.name }
+func (i toolItem) Description() string { return i.desc }
+func (i toolItem) FilterValue() string { return i.name }
+
+// openToolSelect goes back to picking a tool
+func (m model) openToolSelect() (tea.Model, tea.Cmd) {
+	m.refreshToolList()
+	m.currentScreen = ToolSelectScreen
+	return m, nil
+}
+
+// refreshToolList lists the sweepstake calculator and the configured tools,
+// with the functions of the selected environment
+func (m *model) refreshToolList() {
+	items := []list.Item{toolItem{name: sweepstakeTool, desc: "Start, process and complete sweepstakes"}}
+	for _, name := range configuredTools() {
+		spec := toolRegistry[name]
+		desc := spec.Description
+		if desc == "" {
+			desc = fmt.Sprintf("%d action(s), function %s", len(spec.Actions), spec.function(m.selectedEnv))
+		}
+		items = append(items, toolItem{name: name, desc: desc})
+	}
+	m.toolList.SetItems(items)
+}
+
+// updateToolSelect handles the keys of the tool selection screen
+func (m model) updateToolSelect(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch keys.name(msg) {
+	case "ctrl+c", "q":
+		return m, tea.Quit
+	case "b", "esc":
+		if pinnedEnv == "" {
+			m.currentScreen = EnvironmentScreen
+		}
+		return m, nil
+	case "enter":
+		i, ok := m.toolList.SelectedItem().(toolItem)
+		if !ok {
+			return m, nil
+		}
+		if i.name == sweepstakeTool {
+			m.currentScreen = ActionScreen
+			return m, nil
+		}
+		return m.openTool(i.name)
+	}
+	var cmd tea.Cmd
+	m.toolList, cmd = m.toolList.Update(msg)
+	return m, cmd
+}
+
+// openTool lists the actions of a configured tool
+func (m model) openTool(name string) (tea.Model, tea.Cmd) {
+	spec := toolRegistry[name]
+	items := make([]list.Item, 0, len(spec.Actions))
+	for _, a := range spec.Actions {
+		items = append(items, item{title: a.Name, desc: a.Description, action: a.Name})
+	}
+	h, v := m.styles.Doc.GetFrameSize()
+	l := list.New(items, newListDelegate(), m.width-h, max(1, m.height-v-statusBarHeight))
+	l.Title = fmt.Sprintf("%s in %s", name, m.selectedEnv)
+	l.SetFilteringEnabled(false)
+	applyListKeys(&l)
+	applyListGlyphs(&l)
+	ack := textinput.New()
+	ack.CharLimit = 64
+	m.tool = toolScreen{tool: name, actions: l, ack: ack}
+	m.currentScreen = ToolScreen
+	return m, nil
+}
+
+// toolAckPhrase is what has to be typed to invoke in a protected environment
+func (t toolScreen) toolAckPhrase(env string) string {
+	return fmt.Sprintf("%s in %s", t.action.Name, env)
+}
+
+// updateTool handles the keys of a configured tool's screen
+func (m model) updateTool(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	t := &m.tool
+	if keys.name(msg) == "ctrl+c" {
+		return m, tea.Quit
+	}
+	switch t.stage {
+	case toolActions:
+		switch keys.name(msg) {
+		case "q":
+			return m, tea.Quit
+		case "b", "esc":
+			return m.openToolSelect()
+		case "enter":
+			i, ok := t.actions.SelectedItem().(item)
+			if !ok {
+				return m, nil
+			}
+			for _, a := range toolRegistry[t.tool].Actions {
+				if a.Name == i.action {
+					t.action = a
+				}
+			}
+			t.message = ""
+			if len(t.action.Prompts) == 0 {
+				return m.confirmTool(nil)
+			}
+			labels := make([]string, len(t.action.Prompts))
+			for i, p := range t.action.Prompts {
+				labels[i] = p.Label
+			}
+			t.form = newForm(tr(msgFormSubmit), labels...)
+			for i, p := range t.action.Prompts {
+				t.form.SetName(i, p.Field)
+				t.form.Input(i).Placeholder = p.Type
+				t.form.Input(i).Width = 40
+			}
+			t.stage = toolForm
+			return m, tea.Batch(t.form.Focus(0), textinput.Blink)
+		}
+		var cmd tea.Cmd
+		t.actions, cmd = t.actions.Update(msg)
+		return m, cmd
+
+	case toolForm:
+		var cmd tea.Cmd
+		var action formAction
+		t.form, cmd, action = t.form.Update(msg)
+		switch action {
+		case formCancel:
+			t.stage = toolActions
+			return m, nil
+		case formSubmit:
+			values := make([]string, len(t.action.Prompts))
+			for i := range values {
+				values[i] = t.form.Value(i)
+			}
+			return m.confirmTool(values)
+		}
+		return m, cmd
+
+	case toolConfirm:
+		if t.acking {
+			switch msg.Type {
+			case tea.KeyEnter:
+				if phrase := t.toolAckPhrase(m.selectedEnv); strings.TrimSpace(t.ack.Value()) != phrase {
+					t.message = tr(msgAckMismatch, phrase)
+					return m, nil
+				}
+				return m.runTool()
+			case tea.KeyEsc:
+				t.acking, t.message = false, ""
+				return m, nil
+			}
+			var cmd tea.Cmd
+			t.ack, cmd = t.ack.Update(msg)
+			return m, cmd
+		}
+		switch keys.name(msg) {
+		case "q":
+			return m, tea.Quit
+		case "b", "esc":
+			if len(t.action.Prompts) == 0 {
+				t.stage = toolActions
+				return m, nil
+			}
+			t.stage = toolForm
+			return m, t.form.Focus(0)
+		case "enter":
+			if isProtected(m.selectedEnv) {
+				t.acking, t.message = true, ""
+				t.ack.SetValue("")
+				t.ack.Placeholder = t.toolAckPhrase(m.selectedEnv)
+				return m, t.ack.Focus()
+			}
+			return m.runTool()
+		}
+
+	case toolRunning:
+		if msg.Type == tea.KeyEsc {
+			// Like abandonInvocation, the result is dropped when it comes in
+			m.resultGen++
+			m.awaitingResult = false
+			t.stage = toolActions
+			return m, t.actions.NewStatusMessage(tr(msgLoadingAbandon))
+		}
+
+	case toolOutput:
+		switch keys.name(msg) {
+		case "q":
+			return m, tea.Quit
+		case "b", "esc":
+			t.stage = toolActions
+			return m, nil
+		case "r":
+			t.stage = toolConfirm
+			return m, nil
+		}
+	}
+	return m, nil
+}
+
+// confirmTool builds the payload from the form and shows it for confirmation
+func (m model) confirmTool(values []string) (tea.Model, tea.Cmd) {
+	t := &m.tool
+	payload, err := t.action.payload(values)
+	if err != nil {
+		t.message = err.Error()
+		return m, t.form.Focus(0)
+	}
+	t.payload, t.message, t.acking = payload, "", false
+	t.stage = toolConfirm
+	return m, nil
+}
+
+// runTool invokes the confirmed payload
+func (m model) runTool() (tea.Model, tea.Cmd) {
+	t := &m.tool
+	t.acking, t.message = false, ""
+	t.stage = toolRunning
+	inv := &invocation{
+		Env:          m.selectedEnv,
+		Tool:         t.tool,
+		FunctionName: toolRegistry[t.tool].function(m.selectedEnv),
+		Payload:      EventPayload{Action: Action(t.action.Name)},
+		SentPayload:  t.payload,
+		Mode:         modeSync,
+	}
+	return m, tea.Batch(m.spinner.Tick, invokeToolCmd(m.awaitResult(), inv))
+}
+
+// finishTool shows the outcome of an invocation
+func (m *model) finishTool(msg toolResultMsg) {
+	if msg.gen != m.resultGen {
+		return
+	}
+	m.awaitingResult = false
+	m.tool.inv, m.tool.err = msg.inv, msg.err
+	m.tool.stage = toolOutput
+}
+
+// toolView renders a configured tool's screen
+func (m model) toolView() string {
+	t := m.tool
+	if t.stage == toolActions {
+		return t.actions.View()
+	}
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("\n\n  %s %s in %s\n\n", t.tool, t.action.Name, m.selectedEnv))
+	switch t.stage {
+	case toolForm:
+		sb.WriteString("  " + strings.ReplaceAll(t.form.View(), "\n", "\n  ") + "\n\n")
+		if t.message != "" {
+			sb.WriteString("  " + errorStyle.Render(t.message) + "\n\n")
+		}
+		sb.WriteString("  " + t.form.Help() + "\n")
+
+	case toolConfirm:
+		sb.WriteString(fmt.Sprintf("  Function: %s\n", toolRegistry[t.tool].function(m.selectedEnv)))
+		sb.WriteString(fmt.Sprintf("  Profile:  %s\n\n", profileLabel(m.selectedEnv)))
+		indented, _ := indentJSON(t.payload)
+		sb.WriteString("  " + strings.ReplaceAll(string(indented), "\n", "\n  ") + "\n\n")
+		if t.acking {
+			sb.WriteString(fmt.Sprintf("  Type %q to invoke:\n\n", t.toolAckPhrase(m.selectedEnv)))
+			sb.WriteString("  " + t.ack.View() + "\n\n")
+			if t.message != "" {
+				sb.WriteString("  " + t.message + "\n\n")
+			}
+			break
+		}
+		sb.WriteString("  " + tr(msgToolConfirmHelp, keys.help(keyConfirm), keys.help(keyBack), keys.help(keyQuit)) + "\n")
+
+	case toolRunning:
+		sb.WriteString(fmt.Sprintf("  %s Invoking %s...\n\n", m.spinner.View(), toolRegistry[t.tool].function(m.selectedEnv)))
+		sb.WriteString("  " + tr(msgLoadingStop) + "\n")
+
+	case toolOutput:
+		inv := t.inv
+		switch {
+		case t.err != nil:
+			sb.WriteString(errorStyle.Render(fmt.Sprintf("  %s Error: %v", glyphs.Cross, t.err)) + "\n\n")
+		case inv.FunctionError != "":
+			sb.WriteString(errorStyle.Render(fmt.Sprintf("  %s Function error: %s", glyphs.Cross, inv.FunctionError)) + "\n\n")
+		default:
+			sb.WriteString(fmt.Sprintf("  %s Invoked %s in %s\n\n", glyphs.Check, inv.FunctionName, inv.Duration.Round(time.Millisecond)))
+		}
+		if inv.RequestID != "" {
+			sb.WriteString(fmt.Sprintf("  Request ID: %s\n\n", inv.RequestID))
+		}
+		if inv.Body != nil {
+			body := inv.Body
+			if indented, err := indentJSON(body); err == nil {
+				body = indented
+			}
+			sb.WriteString("  Response:\n  " + strings.ReplaceAll(string(body), "\n", "\n  ") + "\n\n")
+		}
+		if logs := strings.TrimSpace(inv.Logs); logs != "" {
+			sb.WriteString("  Logs:\n  " + strings.ReplaceAll(logs, "\n", "\n  ") + "\n\n")
+		}
+		sb.WriteString("  " + tr(msgToolOutputHelp, keys.help(keyBack), keys.help(keyQuit)) + "\n")
+	}
+	return sb.String()
+}
@@ -0,0 +1,270 @@
+package main
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"sort"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/term"
+	"github.com/muesli/termenv"
+	"gopkg.in/yaml.v3"
+)
+
+// debugMode writes a debug log of the TUI session, set with --debug
+var debugMode bool
+
+// termSize is the terminal size the TUI last rendered at, for crash reports
+var termSize struct{ width, height int }
+
+// crashPath is the crash report written by a panic in the TUI, printed once
+// the terminal is restored
+var crashPath string
+
+func debugLogPath() (string, error) {
+	dir, err := dataDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "debug.log"), nil
+}
+
+func crashDirPath() (string, error) {
+	dir, err := dataDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "crashes"), nil
+}
+
+// profileName names a color profile the way display.colors does
+func profileName(p termenv.Profile) string {
+	switch p {
+	case termenv.TrueColor:
+		return "truecolor"
+	case termenv.ANSI256:
+		return "256"
+	case termenv.ANSI:
+		return "16"
+	}
+	return "none"
+}
+
+// environmentReport describes the terminal and the build, which rendering
+// bugs almost always depend on
+func environmentReport() string {
+	width, height := termSize.width, termSize.height
+	if width == 0 {
+		width, height, _ = term.GetSize(os.Stdout.Fd())
+	}
+	glyphSet := "unicode"
+	if glyphs == asciiGlyphs {
+		glyphSet = "ascii"
+	}
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "playtools %s (%s, %s/%s)\n", buildVersion(), runtime.Version(), runtime.GOOS, runtime.GOARCH)
+	fmt.Fprintf(&sb, "terminal: %dx%d, colors %s, glyphs %s\n", width, height, profileName(lipgloss.ColorProfile()), glyphSet)
+	for _, name := range []string{"TERM", "COLORTERM", "TERM_PROGRAM", "LANG", "LC_ALL"} {
+		fmt.Fprintf(&sb, "%s=%s\n", name, os.Getenv(name))
+	}
+	if activeContext != "" {
+		fmt.Fprintf(&sb, "context: %s\n", activeContext)
+	}
+	return sb.String()
+}
+
+// startDebugLog sends the standard logger to the debug log, headed by the
+// environment report, and returns a func closing it
+func startDebugLog() (func(), error) {
+	path, err := debugLogPath()
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	fmt.Fprintf(f, "%s\n", environmentReport())
+	log.SetOutput(f)
+	log.SetFlags(log.LstdFlags | log.Lmicroseconds)
+	return func() { f.Close() }, nil
+}
+
+// debugf writes a line to the debug log when --debug is on
+func debugf(format string, args ...interface{}) {
+	if debugMode {
+		log.Printf(format, args...)
+	}
+}
+
+// noteResize keeps the terminal size for crash reports and logs the change
+func noteResize(width, height int) {
+	if width == termSize.width && height == termSize.height {
+		return
+	}
+	termSize.width, termSize.height = width, height
+	debugf("resize %dx%d", width, height)
+}
+
+// writeCrashReport writes a panic, its stack and the environment report to
+// the crash directory, returning the path written
+func writeCrashReport(r interface{}, stack []byte, now time.Time) (string, error) {
+	dir, err := crashDirPath()
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", err
+	}
+	path := filepath.Join(dir, "crash-"+now.UTC().Format("20060102T150405Z")+".txt")
+	report := fmt.Sprintf("panic: %v\ntime: %s\n\n%s\n%s", r, now.UTC().Format(time.RFC3339), environmentReport(), stack)
+	return path, os.WriteFile(path, []byte(report), 0o600)
+}
+
+// crashGuard writes a crash report when the model panics, then lets the
+// panic through so Bubble Tea restores the terminal
+type crashGuard struct {
+	tea.Model
+}
+
+func (g crashGuard) recordPanic() {
+	if r := recover(); r != nil {
+		if path, err := writeCrashReport(r, debug.Stack(), time.Now()); err == nil {
+			crashPath = path
+		}
+		debugf("panic: %v", r)
+		panic(r)
+	}
+}
+
+func (g crashGuard) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	defer g.recordPanic()
+	m, cmd := g.Model.Update(msg)
+	return crashGuard{m}, cmd
+}
+
+func (g crashGuard) View() string {
+	defer g.recordPanic()
+	return g.Model.View()
+}
+
+// latestFile is the most recently modified file of dir whose name starts
+// with prefix, empty when there's none
+func latestFile(dir, prefix string) string {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return ""
+	}
+	var latest string
+	var latestMod time.Time
+	for _, e := range entries {
+		info, err := e.Info()
+		if err != nil || e.IsDir() || !strings.HasPrefix(e.Name(), prefix) {
+			continue
+		}
+		if info.ModTime().After(latestMod) {
+			latest, latestMod = filepath.Join(dir, e.Name()), info.ModTime()
+		}
+	}
+	return latest
+}
+
+// bugReportConfig is the effective config for a bug report: the config file
+// as parsed and the environments it resolves to
+func bugReportConfig() string {
+	var sb strings.Builder
+	cfg, err := loadConfig()
+	if err != nil {
+		fmt.Fprintf(&sb, "# the config doesn't load: %v\n", err)
+	} else if err := applyConfig(cfg); err != nil {
+		fmt.Fprintf(&sb, "# the config doesn't apply: %v\n", err)
+	}
+	if data, err := yaml.Marshal(cfg); err == nil {
+		sb.Write(data)
+	}
+	envs := make([]string, 0, len(profileMap))
+	for env := range profileMap {
+		envs = append(envs, env)
+	}
+	sort.Strings(envs)
+	sb.WriteString("\n# resolved environments\n")
+	for _, env := range envs {
+		fmt.Fprintf(&sb, "# %s: profile %s, function %s\n", env, profileMap[env], sweepstakeFunction(env))
+	}
+	return sb.String()
+}
+
+// reportFile is a file of a bug report
+type reportFile struct {
+	name    string
+	content []byte
+}
+
+// writeBugReport bundles the environment report, the effective config, the
+// debug log and the latest crash report into a zip in dir, redacted like
+// invocation bundles, returning the path written and the files included
+func writeBugReport(dir string, now time.Time) (string, []string, error) {
+	files := []reportFile{
+		{"environment.txt", []byte(environmentReport())},
+		{"config.yaml", []byte(bugReportConfig())},
+	}
+	if path, err := debugLogPath(); err == nil {
+		if data, err := os.ReadFile(path); err == nil {
+			files = append(files, reportFile{"debug.log", data})
+		}
+	}
+	if crashes, err := crashDirPath(); err == nil {
+		if path := latestFile(crashes, "crash-"); path != "" {
+			if data, err := os.ReadFile(path); err == nil {
+				files = append(files, reportFile{filepath.Base(path), data})
+			}
+		}
+	}
+
+	path := filepath.Join(dir, "playtools-bugreport-"+now.UTC().Format("20060102T150405Z")+".zip")
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to create bug report: %v", err)
+	}
+	defer f.Close()
+	zw := zip.NewWriter(f)
+	var names []string
+	for _, file := range files {
+		w, err := zw.CreateHeader(&zip.FileHeader{Name: file.name, Method: zip.Deflate, Modified: now})
+		if err != nil {
+			return "", nil, fmt.Errorf("failed to write bug report: %v", err)
+		}
+		if _, err := io.WriteString(w, redact(string(file.content))); err != nil {
+			return "", nil, fmt.Errorf("failed to write bug report: %v", err)
+		}
+		names = append(names, file.name)
+	}
+	if err := zw.Close(); err != nil {
+		return "", nil, fmt.Errorf("failed to write bug report: %v", err)
+	}
+	return path, names, f.Close()
+}
+
+// runBugReportCommand handles `playtools bugreport`
+func runBugReportCommand(stdout io.Writer) int {
+	path, names, err := writeBugReport(".", time.Now())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitFailure
+	}
+	fmt.Fprintf(stdout, "Wrote %s with %s.\n", path, strings.Join(names, ", "))
+	fmt.Fprintln(stdout, "Secrets are redacted, but look it over before attaching it to an issue.")
+	return 0
+}
@@ -0,0 +1,297 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/lambda"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// DiscoveryConfig finds tools from the tags of the environments' Lambda
+// functions, on top of the tools section
+type DiscoveryConfig struct {
+	// Tag selects the functions, like "playtools:enabled=true"
+	Tag string `yaml:"tag"`
+	// TTL is how long a discovery is reused, like "1h"
+	TTL string `yaml:"ttl"`
+}
+
+// The tags a discovered function describes itself with. Without a schema
+// tag the schema is read from the SSM parameter /playtools/<function>/schema.
+const (
+	toolNameTag        = "playtools:name"
+	toolDescriptionTag = "playtools:description"
+	toolSchemaTag      = "playtools:schema"
+)
+
+// defaultDiscoveryTTL is how long a discovery is reused by default
+const defaultDiscoveryTTL = time.Hour
+
+// The discovery of the active context, discoveryTag empty when it's off
+var (
+	discoveryTag string
+	discoveryTTL = defaultDiscoveryTTL
+)
+
+// discoveredTool is a function found by its tags
+type discoveredTool struct {
+	Name        string `json:"name"`
+	Function    string `json:"function"`
+	Description string `json:"description,omitempty"`
+	// Schema is the schema source of the schema tag, SchemaJSON the schema
+	// read from SSM
+	Schema     string          `json:"schema,omitempty"`
+	SchemaJSON json.RawMessage `json:"schema_json,omitempty"`
+}
+
+// discovery is the tools found in an environment, kept in the state file
+type discovery struct {
+	Tools     []discoveredTool `json:"tools"`
+	FetchedAt time.Time        `json:"fetched_at"`
+}
+
+// applyDiscoveryConfig validates and applies the discovery section of the
+// config
+func applyDiscoveryConfig(cfg DiscoveryConfig) error {
+	discoveryTag, discoveryTTL = "", defaultDiscoveryTTL
+	if cfg.Tag == "" {
+		if cfg.TTL != "" {
+			return configErrorf([]string{"discovery", "ttl"}, "set without a discovery.tag")
+		}
+		return nil
+	}
+	if key, _, _ := strings.Cut(cfg.Tag, "="); key == "" {
+		return configErrorf([]string{"discovery", "tag"}, "expected key=value or key, got %q", cfg.Tag)
+	}
+	if cfg.TTL != "" {
+		d, err := time.ParseDuration(cfg.TTL)
+		if err != nil || d <= 0 {
+			return configErrorf([]string{"discovery", "ttl"}, "expected a positive duration like 1h, got %q", cfg.TTL)
+		}
+		discoveryTTL = d
+	}
+	discoveryTag = cfg.Tag
+	return nil
+}
+
+// staticTool reports whether a tool comes from the built-in registry or the
+// config, which wins over a discovered tool of the same name
+func staticTool(name string) bool {
+	spec, ok := toolRegistry[name]
+	return ok && spec.Discovered == nil
+}
+
+// applyDiscoveredTools adds the cached discoveries of every environment to
+// the registry. It never calls AWS, a stale discovery is kept until it's
+// refreshed.
+func applyDiscoveredTools() {
+	if discoveryTag == "" {
+		return
+	}
+	state, err := loadState()
+	if err != nil {
+		return
+	}
+	for env, d := range state.Discovered {
+		if _, ok := profileMap[env]; ok {
+			registerDiscovered(env, d.Tools)
+		}
+	}
+}
+
+// registerDiscovered materializes an environment's discovered tools
+func registerDiscovered(env string, tools []discoveredTool) {
+	for _, t := range tools {
+		if staticTool(t.Name) {
+			continue
+		}
+		spec, ok := toolRegistry[t.Name]
+		if !ok {
+			spec = &toolSpec{Transport: transportLambda, Discovered: map[string]string{}}
+			toolRegistry[t.Name] = spec
+		}
+		spec.Discovered[env] = t.Function
+		spec.Description = t.Description
+		spec.Schema = t.Schema
+		if t.SchemaJSON != nil {
+			if fields, err := parseSchemaHelp(t.SchemaJSON); err == nil {
+				spec.Fields = fields
+			}
+		}
+	}
+}
+
+// matchesDiscoveryTag reports whether a function's tags select it
+func matchesDiscoveryTag(tags map[string]string) bool {
+	key, value, hasValue := strings.Cut(discoveryTag, "=")
+	v, ok := tags[key]
+	return ok && (!hasValue || v == value)
+}
+
+// discoverTools lists env's functions page by page, keeping the ones with
+// the discovery tag
+func discoverTools(ctx context.Context, env string) ([]discoveredTool, error) {
+	ctx, cancel := context.WithTimeout(ctx, time.Minute)
+	defer cancel()
+	profile := profileMap[env]
+	cfg, err := config.LoadDefaultConfig(ctx, config.WithSharedConfigProfile(profile), envRegion(env), withSkewRecording())
+	if err != nil {
+		return nil, fmt.Errorf("failed to load AWS config: %v", err)
+	}
+	client := lambda.NewFromConfig(cfg)
+	var tools []discoveredTool
+	pages := lambda.NewListFunctionsPaginator(client, &lambda.ListFunctionsInput{})
+	for pages.HasMorePages() {
+		page, err := pages.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list functions: %v", err)
+		}
+		for _, fn := range page.Functions {
+			out, err := client.ListTags(ctx, &lambda.ListTagsInput{Resource: fn.FunctionArn})
+			if err != nil {
+				return nil, fmt.Errorf("failed to list the tags of %s: %v", aws.ToString(fn.FunctionName), err)
+			}
+			if !matchesDiscoveryTag(out.Tags) {
+				continue
+			}
+			name := aws.ToString(fn.FunctionName)
+			t := discoveredTool{
+				Name:        name,
+				Function:    name,
+				Description: cmp.Or(out.Tags[toolDescriptionTag], aws.ToString(fn.Description)),
+				Schema:      out.Tags[toolSchemaTag],
+			}
+			if n := out.Tags[toolNameTag]; n != "" {
+				t.Name = n
+			}
+			if t.Schema == "" {
+				t.SchemaJSON = ssmSchema(profile, name)
+			}
+			tools = append(tools, t)
+		}
+	}
+	sort.Slice(tools, func(i, j int) bool { return tools[i].Name < tools[j].Name })
+	return tools, nil
+}
+
+// ssmSchema reads a function's schema from its convention-named SSM
+// parameter with the aws CLI, nil when there's none
+func ssmSchema(profile, function string) json.RawMessage {
+	args := append([]string{"ssm", "get-parameter", "--name", "/playtools/" + function + "/schema",
+		"--query", "Parameter.Value", "--output", "text", "--profile", profile}, regionArgs(profile)...)
+	data, err := exec.Command("aws", args...).Output()
+	if err != nil || !json.Valid(data) {
+		return nil
+	}
+	return json.RawMessage(strings.TrimSpace(string(data)))
+}
+
+// discoveredTools is env's discovery, from the state file unless it's older
+// than the TTL or refresh is set
+func discoveredTools(ctx context.Context, env string, refresh bool) (discovery, error) {
+	if !refresh {
+		if state, err := loadState(); err == nil {
+			if d, ok := state.Discovered[env]; ok && time.Since(d.FetchedAt) < discoveryTTL {
+				return d, nil
+			}
+		}
+	}
+	tools, err := discoverTools(ctx, env)
+	if err != nil {
+		return discovery{}, err
+	}
+	d := discovery{Tools: tools, FetchedAt: time.Now()}
+	_ = updateState(func(state *appState) {
+		if state.Discovered == nil {
+			state.Discovered = map[string]discovery{}
+		}
+		state.Discovered[env] = d
+	})
+	return d, nil
+}
+
+// discoveryLines lists a discovery, marking the tools a static tool shadows
+func discoveryLines(d discovery) []string {
+	lines := []string{fmt.Sprintf("%d tool(s) tagged %s, discovered %s ago", len(d.Tools), discoveryTag, formatAge(time.Since(d.FetchedAt)))}
+	for _, t := range d.Tools {
+		line := fmt.Sprintf("  %-24s %s", t.Name, t.Function)
+		if t.Description != "" {
+			line += "  " + t.Description
+		}
+		if staticTool(t.Name) {
+			line += "  (the configured tool of that name wins)"
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// discoveryMsg carries a discovery for the tools screen
+type discoveryMsg struct {
+	env string
+	d   discovery
+	err error
+}
+
+func discoverToolsCmd(env string, refresh bool) tea.Cmd {
+	return func() tea.Msg {
+		d, err := discoveredTools(context.Background(), env, refresh)
+		return discoveryMsg{env: env, d: d, err: err}
+	}
+}
+
+// openTools shows the tools discovered in the selected environment
+func (m model) openTools(refresh bool) (tea.Model, tea.Cmd) {
+	m.currentScreen = ToolsScreen
+	m.toolsLoading = true
+	m.tools = fmt.Sprintf("  %s Discovering tools in %s...\n", m.spinner.View(), m.selectedEnv)
+	return m, tea.Batch(m.spinner.Tick, discoverToolsCmd(m.selectedEnv, refresh))
+}
+
+// runToolsCommand handles `playtools tools`, listing the discovered tools
+func runToolsCommand(args []string, stdout io.Writer) int {
+	fs := flag.NewFlagSet("tools", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	env := fs.String("env", devEnv, "")
+	refresh := fs.Bool("refresh", false, "")
+	if err := fs.Parse(args); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n\n%s", err, usageText)
+		return exitUsage
+	}
+	if err := setupConfig(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading config: %v\n", err)
+		return exitFailure
+	}
+	if pinnedEnv != "" {
+		*env = pinnedEnv
+	}
+	if _, ok := profileMap[*env]; !ok {
+		fmt.Fprintf(os.Stderr, "Error: unknown environment %q\n", *env)
+		return exitUsage
+	}
+	if discoveryTag == "" {
+		fmt.Fprintln(os.Stderr, "Error: discovery is off, set discovery.tag in the config")
+		return exitFailure
+	}
+	d, err := discoveredTools(context.Background(), *env, *refresh)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitFailure
+	}
+	for _, line := range discoveryLines(d) {
+		fmt.Fprintln(stdout, line)
+	}
+	return 0
+}
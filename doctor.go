@@ -0,0 +1,151 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"sort"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/lambda"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// doctorCheck is the result of a single prerequisite check
+type doctorCheck struct {
+	Name string
+	Err  error
+	Hint string
+}
+
+type doctorMsg struct {
+	checks []doctorCheck
+}
+
+func doctorChecksCmd(env string) tea.Cmd {
+	return func() tea.Msg {
+		return doctorMsg{checks: runDoctorChecks(context.Background(), env)}
+	}
+}
+
+// runDoctorChecks verifies the prerequisites for invoking the lambda in an
+// environment. Checks after a failed one that depend on it are skipped.
+func runDoctorChecks(ctx context.Context, env string) []doctorCheck {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	return append([]doctorCheck{awsCLICheck()}, envDoctorChecks(ctx, env)...)
+}
+
+// awsCLICheck checks for the aws binary, which SSO logins need
+func awsCLICheck() doctorCheck {
+	_, err := exec.LookPath("aws")
+	return doctorCheck{
+		Name: "aws CLI on PATH",
+		Err:  err,
+		Hint: "Install the AWS CLI v2: https://docs.aws.amazon.com/cli/latest/userguide/getting-started-install.html",
+	}
+}
+
+// envDoctorChecks checks an environment's profile, credentials and function
+func envDoctorChecks(ctx context.Context, env string) []doctorCheck {
+	profile := profileMap[env]
+	functionName := sweepstakeFunction(env)
+	var checks []doctorCheck
+
+	cfg, err := config.LoadDefaultConfig(ctx, config.WithSharedConfigProfile(profile), envRegion(env), withSkewRecording())
+	checks = append(checks, doctorCheck{
+		Name: fmt.Sprintf("profile %s resolvable", profile),
+		Err:  err,
+		Hint: fmt.Sprintf("Add [profile %s] to ~/.aws/config", profile),
+	})
+	if err != nil {
+		return checks
+	}
+
+	_, err = sts.NewFromConfig(cfg).GetCallerIdentity(ctx, &sts.GetCallerIdentityInput{})
+	// A skewed clock is why credentials get rejected, so it goes first
+	checks = append(checks, clockCheck()...)
+	checks = append(checks, doctorCheck{
+		Name: fmt.Sprintf("credentials for %s", profile),
+		Err:  err,
+		Hint: fmt.Sprintf("Run: aws sso login --profile %s", profile),
+	})
+	if err != nil {
+		return checks
+	}
+
+	_, err = lambda.NewFromConfig(cfg).GetFunction(ctx, &lambda.GetFunctionInput{
+		FunctionName: aws.String(functionName),
+	})
+	checks = append(checks, doctorCheck{
+		Name: fmt.Sprintf("function %s exists", functionName),
+		Err:  err,
+		Hint: "Check the environment's account/region and the function name",
+	})
+	return checks
+}
+
+// runDoctorCommand handles `playtools doctor [--env name]`, checking every
+// environment unless one is given and printing a pass/fail table
+func runDoctorCommand(args []string, stdout io.Writer) int {
+	fs := flag.NewFlagSet("doctor", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	env := fs.String("env", "", "")
+	if err := fs.Parse(args); err != nil || fs.NArg() > 0 {
+		fmt.Fprintln(os.Stderr, "Error: expected playtools doctor [--env name]")
+		return exitUsage
+	}
+	if err := setupConfig(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading config: %v\n", err)
+		return exitFailure
+	}
+	envs := []string{*env}
+	if *env == "" {
+		envs = make([]string, 0, len(profileMap))
+		for e := range profileMap {
+			envs = append(envs, e)
+		}
+		sort.Strings(envs)
+	} else if _, ok := profileMap[*env]; !ok {
+		fmt.Fprintf(os.Stderr, "Error: unknown environment %q\n", *env)
+		return exitUsage
+	}
+
+	type row struct {
+		env   string
+		check doctorCheck
+	}
+	rows := []row{{"", awsCLICheck()}}
+	for _, e := range envs {
+		for _, c := range envDoctorChecks(context.Background(), e) {
+			rows = append(rows, row{e, c})
+		}
+	}
+
+	failed := 0
+	fmt.Fprintf(stdout, "%-6s %-10s %s\n", "RESULT", "ENV", "CHECK")
+	for _, r := range rows {
+		result := "pass"
+		if r.check.Err != nil {
+			result = "FAIL"
+			failed++
+		}
+		fmt.Fprintf(stdout, "%-6s %-10s %s\n", result, cmp.Or(r.env, "-"), r.check.Name)
+		if r.check.Err != nil {
+			fmt.Fprintf(stdout, "%-17s %v\n%-17s %s\n", "", r.check.Err, "", r.check.Hint)
+		}
+	}
+	if failed > 0 {
+		fmt.Fprintf(stdout, "\n%d check(s) failed\n", failed)
+		return exitFailure
+	}
+	return 0
+}
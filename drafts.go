@@ -0,0 +1,243 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// draft is a prompt's unsubmitted input, saved in the state file when it's
+// left so the next prompt of the same action picks it up
+type draft struct {
+	Env    string `json:"env"`
+	Action string `json:"action"`
+	// RollbackOf is the request a rollback draft justifies, so a draft for
+	// one complete isn't offered for another
+	RollbackOf string `json:"rollback_of,omitempty"`
+	// Fields maps a field's payload name to its value
+	Fields map[string]string `json:"fields"`
+	// Overrides and Extra are the text of the sweepstake overrides and
+	// extra fields editors
+	Overrides string    `json:"overrides,omitempty"`
+	Extra     string    `json:"extra,omitempty"`
+	SavedAt   time.Time `json:"saved_at"`
+}
+
+func (d draft) matches(env, action, rollbackOf string) bool {
+	return d.Env == env && d.Action == action && d.RollbackOf == rollbackOf
+}
+
+// draftKeyOf is what identifies the draft of the current prompt
+func (m model) draftKeyOf() (env, action, rollbackOf string) {
+	if m.selectedAction == string(ActionRollback) && m.rollbackOf != nil {
+		rollbackOf = m.rollbackOf.RequestID
+	}
+	return m.selectedEnv, m.selectedAction, rollbackOf
+}
+
+// saveDraft keeps d, replacing the draft of the same prompt
+func saveDraft(d draft) error {
+	return updateState(func(state *appState) {
+		state.Drafts = removeDraft(state.Drafts, d.Env, d.Action, d.RollbackOf)
+		state.Drafts = append(state.Drafts, d)
+	})
+}
+
+// takeDraft removes and returns the draft of a prompt. A restored draft is
+// back in the form, and leaving it again offers to save it again.
+func takeDraft(env, action, rollbackOf string) (draft, bool) {
+	var found draft
+	ok := false
+	_ = updateState(func(state *appState) {
+		for _, d := range state.Drafts {
+			if d.matches(env, action, rollbackOf) {
+				found, ok = d, true
+			}
+		}
+		if ok {
+			state.Drafts = removeDraft(state.Drafts, env, action, rollbackOf)
+		}
+	})
+	return found, ok
+}
+
+func removeDraft(drafts []draft, env, action, rollbackOf string) []draft {
+	kept := drafts[:0]
+	for _, d := range drafts {
+		if !d.matches(env, action, rollbackOf) {
+			kept = append(kept, d)
+		}
+	}
+	return kept
+}
+
+// restoreDraft fills the prompt from its saved draft, if there's one
+func (m *model) restoreDraft() {
+	d, ok := takeDraft(m.draftKeyOf())
+	if !ok {
+		return
+	}
+	for i, field := range m.promptForm.fields {
+		if v, ok := d.Fields[field.name]; ok {
+			m.promptForm.SetValue(i, v)
+		}
+	}
+	m.overrides.restored, m.extraFields.restored = d.Overrides, d.Extra
+	m.promptMessage = tr(msgDraftRestored, formatAge(time.Since(d.SavedAt)))
+}
+
+// leavePrompt leaves a prompt or one of the editors after it, asking first
+// when it would discard edited input. quit leaves the application rather
+// than going back.
+func (m model) leavePrompt(quit bool) (tea.Model, tea.Cmd) {
+	dirty := m.promptForm.Dirty()
+	switch m.currentScreen {
+	case OverridesScreen:
+		// Going back to the prompt keeps its input
+		dirty = m.overrides.Dirty() || quit && dirty
+	case ExtraFieldsScreen:
+		// The prompt was already sent on to the confirmation
+		dirty = m.extraFields.Dirty()
+	}
+	if dirty {
+		m.discarding, m.discardQuits = true, quit
+		return m, nil
+	}
+	return m.leave(quit)
+}
+
+// leave quits, or goes back a screen dropping the editor's input
+func (m model) leave(quit bool) (tea.Model, tea.Cmd) {
+	if quit {
+		return m, tea.Quit
+	}
+	switch m.currentScreen {
+	case OverridesScreen:
+		m.overrides.revert()
+		m.currentScreen = PromptScreen
+		return m, m.promptForm.Focus(0)
+	case ExtraFieldsScreen:
+		m.extraFields.revert()
+		m.currentScreen = ConfirmScreen
+		return m, nil
+	}
+	m.currentScreen = ActionScreen
+	return m, nil
+}
+
+// updateDiscard handles the keys of the discard question, which defaults to
+// keeping the input. A second Ctrl+C quits anyway.
+func (m model) updateDiscard(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch keys.name(msg) {
+	case "y", "Y", "ctrl+c":
+	case "s", "S":
+		env, action, rollbackOf := m.draftKeyOf()
+		d := draft{Env: env, Action: action, RollbackOf: rollbackOf, Fields: m.promptForm.Values(), SavedAt: time.Now()}
+		switch m.currentScreen {
+		case OverridesScreen:
+			d.Overrides = m.overrides.area.Value()
+		case ExtraFieldsScreen:
+			d.Extra = m.extraFields.area.Value()
+			if o := m.pendingPayload.SweepstakeOverrides; o != nil {
+				d.Overrides = indentedJSON(*o)
+			}
+		}
+		if err := saveDraft(d); err != nil {
+			m.promptMessage = tr(msgDraftSaveErr, err)
+			m.discarding = false
+			return m, nil
+		}
+	default:
+		m.discarding = false
+		return m, nil
+	}
+	m.discarding = false
+	return m.leave(m.discardQuits)
+}
+
+// draftsScreen lists the saved drafts, newest first
+type draftsScreen struct {
+	list   []draft
+	cursor int
+	back   Screen
+	err    error
+}
+
+// openDrafts shows the saved drafts
+func (m model) openDrafts() (tea.Model, tea.Cmd) {
+	state, err := loadState()
+	m.drafts = draftsScreen{list: state.Drafts, back: m.currentScreen, err: err}
+	sort.SliceStable(m.drafts.list, func(i, j int) bool { return m.drafts.list[i].SavedAt.After(m.drafts.list[j].SavedAt) })
+	m.currentScreen = DraftsScreen
+	return m, nil
+}
+
+// updateDrafts handles the keys of the drafts screen
+func (m model) updateDrafts(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	ds := &m.drafts
+	switch keys.name(msg) {
+	case "ctrl+c", "q":
+		return m, tea.Quit
+	case "esc", "b":
+		m.currentScreen = ds.back
+	case "up", "k":
+		ds.cursor = max(0, ds.cursor-1)
+	case "down", "j":
+		ds.cursor = max(0, min(ds.cursor+1, len(ds.list)-1))
+	case "x", "delete":
+		if len(ds.list) == 0 {
+			return m, nil
+		}
+		d := ds.list[ds.cursor]
+		if ds.err = updateState(func(state *appState) {
+			state.Drafts = removeDraft(state.Drafts, d.Env, d.Action, d.RollbackOf)
+		}); ds.err == nil {
+			ds.list = append(ds.list[:ds.cursor:ds.cursor], ds.list[ds.cursor+1:]...)
+			ds.cursor = max(0, min(ds.cursor, len(ds.list)-1))
+		}
+	}
+	return m, nil
+}
+
+// draftsView renders the drafts screen
+func (m model) draftsView() string {
+	ds := m.drafts
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("\n\n  %s\n\n", tr(msgDraftsTitle)))
+	if ds.err != nil {
+		sb.WriteString(errorStyle.Render(fmt.Sprintf("  Error: %v", ds.err)) + "\n\n")
+	}
+	if len(ds.list) == 0 {
+		sb.WriteString("  " + tr(msgDraftsEmpty) + "\n")
+	}
+	for i, d := range ds.list {
+		names := make([]string, 0, len(d.Fields))
+		for name := range d.Fields {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		values := make([]string, 0, len(names))
+		for _, name := range names {
+			if v := strings.TrimSpace(d.Fields[name]); v != "" {
+				values = append(values, fmt.Sprintf("%s: %s", name, truncateCell(v, 40)))
+			}
+		}
+		for _, editor := range [][2]string{{"overrides", d.Overrides}, {"extra", d.Extra}} {
+			if editor[1] != "" {
+				values = append(values, editor[0]+": "+truncateCell(strings.Join(strings.Fields(editor[1]), " "), 40))
+			}
+		}
+		line := fmt.Sprintf("%-8s %-12s %s  %s", d.Env, d.Action, strings.Join(values, ", "),
+			dimStyle.Render(fmt.Sprintf("(saved %s ago)", formatAge(time.Since(d.SavedAt)))))
+		if i == ds.cursor {
+			sb.WriteString(formFocusStyle.Render(glyphs.Pointer+" ") + line + "\n")
+		} else {
+			sb.WriteString("  " + line + "\n")
+		}
+	}
+	sb.WriteString("\n  " + dimStyle.Render(tr(msgDraftsHelp, keys.help(keyBack), keys.help(keyQuit))) + "\n")
+	return sb.String()
+}
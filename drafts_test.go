@@ -0,0 +1,167 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// update sends msg and returns the command it gave back
+func (h *harness) update(msg tea.Msg) tea.Cmd {
+	h.t.Helper()
+	next, cmd := h.m.Update(msg)
+	h.m = next.(model)
+	return cmd
+}
+
+// openOverridesEditor submits a 60 minute start prompt, which goes on to
+// the overrides editor
+func openOverridesEditor(h *harness) {
+	h.t.Helper()
+	h.m.openPrompt(string(ActionStart))
+	h.m.promptForm.SetValue(0, "60")
+	h.press(tea.KeyEnter)
+	h.wantScreen(OverridesScreen)
+}
+
+func TestOverridesLeave(t *testing.T) {
+	const overrides = `{"max_winners":3}`
+	h := newHarness(t)
+
+	// Nothing typed, nothing to ask about
+	openOverridesEditor(h)
+	h.press(tea.KeyEsc)
+	h.wantScreen(PromptScreen)
+	if h.m.discarding {
+		t.Error("asked about an untouched editor")
+	}
+
+	// Esc asks, anything but y keeps editing
+	h.press(tea.KeyEnter)
+	h.wantScreen(OverridesScreen)
+	h.typeText(overrides)
+	h.press(tea.KeyEsc)
+	h.wantScreen(OverridesScreen)
+	if view := h.m.View(); !strings.Contains(view, "Discard your start draft?") {
+		t.Fatalf("Esc didn't ask\n%s", view)
+	}
+	h.typeText("n")
+	if h.m.discarding || h.m.overrides.area.Value() != overrides {
+		t.Fatalf("n left discarding %v with %q", h.m.discarding, h.m.overrides.area.Value())
+	}
+
+	// Ctrl+C asks too, and y goes on to quit
+	if cmd := h.update(tea.KeyMsg{Type: tea.KeyCtrlC}); isQuit(cmd) || !h.m.discarding || !h.m.discardQuits {
+		t.Fatal("Ctrl+C quit without asking")
+	}
+	if cmd := h.update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'y'}}); !isQuit(cmd) {
+		t.Fatal("y after Ctrl+C didn't quit")
+	}
+
+	// y after Esc drops the overrides on the way back to the prompt
+	h.m.discarding = false
+	h.press(tea.KeyEsc)
+	h.typeText("y")
+	h.wantScreen(PromptScreen)
+	if v := h.m.overrides.area.Value(); v != "" {
+		t.Errorf("the discarded overrides are still there: %q", v)
+	}
+
+	// s saves them with the duration for the next start
+	h.press(tea.KeyEnter)
+	h.typeText(overrides)
+	h.update(tea.KeyMsg{Type: tea.KeyCtrlC})
+	if cmd := h.update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'s'}}); !isQuit(cmd) {
+		t.Fatal("s after Ctrl+C didn't quit")
+	}
+	state, err := loadState()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(state.Drafts) != 1 || state.Drafts[0].Overrides != overrides || state.Drafts[0].Fields["duration_minutes"] != "60" {
+		t.Fatalf("saved %+v", state.Drafts)
+	}
+
+	h.m.overrides.area.SetValue("")
+	h.m.currentScreen = ActionScreen
+	h.m.openPrompt(string(ActionStart))
+	h.press(tea.KeyEnter)
+	h.wantScreen(OverridesScreen)
+	if v := h.m.overrides.area.Value(); v != overrides {
+		t.Errorf("the draft restored %q, want %q", v, overrides)
+	}
+	// A restored draft is still input leaving would lose
+	h.press(tea.KeyEsc)
+	if !h.m.discarding {
+		t.Error("Esc left restored overrides without asking")
+	}
+}
+
+func TestExtraFieldsLeave(t *testing.T) {
+	const extra = `{"campaign":"spring"}`
+	h := newHarness(t)
+	h.m.openPrompt(string(ActionProcess))
+	h.m.promptForm.SetValue(0, "42")
+	// Past the batch size to the confirmation
+	h.press(tea.KeyEnter, tea.KeyEnter)
+	h.wantScreen(ConfirmScreen)
+	openExtra := func() {
+		t.Helper()
+		h.typeText("x")
+		h.wantScreen(ExtraFieldsScreen)
+	}
+
+	// Nothing typed, nothing to ask about
+	openExtra()
+	h.press(tea.KeyEsc)
+	h.wantScreen(ConfirmScreen)
+
+	// Esc and Ctrl+C ask, anything but y keeps editing
+	openExtra()
+	h.typeText(extra)
+	h.press(tea.KeyEsc)
+	h.wantScreen(ExtraFieldsScreen)
+	if view := h.m.View(); !strings.Contains(view, "Discard your process draft?") {
+		t.Fatalf("Esc didn't ask\n%s", view)
+	}
+	h.typeText("n")
+	if cmd := h.update(tea.KeyMsg{Type: tea.KeyCtrlC}); isQuit(cmd) || !h.m.discarding {
+		t.Fatal("Ctrl+C quit without asking")
+	}
+	h.typeText("n")
+	if h.m.extraFields.area.Value() != extra {
+		t.Fatalf("kept %q", h.m.extraFields.area.Value())
+	}
+
+	// y after Esc goes back to the confirmation without them
+	h.press(tea.KeyEsc)
+	h.typeText("y")
+	h.wantScreen(ConfirmScreen)
+	if h.m.pendingPayload.Extra != nil || h.m.extraFields.area.Value() != "" {
+		t.Errorf("the discarded fields were kept: %v %q", h.m.pendingPayload.Extra, h.m.extraFields.area.Value())
+	}
+
+	// s saves them with the quest for the next process
+	openExtra()
+	h.typeText(extra)
+	h.update(tea.KeyMsg{Type: tea.KeyCtrlC})
+	if cmd := h.update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'s'}}); !isQuit(cmd) {
+		t.Fatal("s after Ctrl+C didn't quit")
+	}
+	state, err := loadState()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(state.Drafts) != 1 || state.Drafts[0].Extra != extra || state.Drafts[0].Fields["sweepstake_quest_id"] != "42" {
+		t.Fatalf("saved %+v", state.Drafts)
+	}
+
+	h.m.currentScreen = ActionScreen
+	h.m.openPrompt(string(ActionProcess))
+	h.press(tea.KeyEnter, tea.KeyEnter)
+	openExtra()
+	if v := h.m.extraFields.area.Value(); v != extra {
+		t.Errorf("the draft restored %q, want %q", v, extra)
+	}
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/revrost/playtools/pkg/playtools"
+)
+
+func TestEnvelopeFixtures(t *testing.T) {
+	tests := []struct {
+		fixture   string
+		enveloped bool
+		status    int
+		body      string
+		exit      int
+	}{
+		{"plain.json", false, 200, `{"processed":3,"winners":2}`, 0},
+		{"enveloped.json", true, 200, `{"processed":3,"winners":2}`, 0},
+		{"enveloped_error.json", true, 422, `{"errors":["prize tier 2 has no amount"]}`, exitFailure},
+		{"empty_body.json", true, 204, ``, 0},
+		// Not envelopes: the body has to be a string and the status a number
+		{"object_body.json", false, 200, `{"statusCode":200,"body":{"processed":3}}`, 0},
+		{"string_status.json", false, 200, `{"statusCode":"200","body":"ok"}`, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.fixture, func(t *testing.T) {
+			response := readFixture(t, filepath.Join("envelopes", tt.fixture))
+			inv := &invocation{Env: devEnv, Payload: playtools.NewPayload(ActionProcess, 42), RequestID: "req-1"}
+			inv.setResponse(response, 200)
+
+			if inv.StatusCode != tt.status || string(bytes.TrimSpace(inv.Body)) != tt.body {
+				t.Errorf("status %d, body %s; want %d, %s", inv.StatusCode, inv.Body, tt.status, tt.body)
+			}
+			if !bytes.Equal(inv.Response, response) {
+				t.Error("the raw response wasn't kept")
+			}
+
+			lines := strings.Join(invocationLines(inv, nil), "\n")
+			if got := strings.Contains(lines, "Raw envelope: "+string(response)); got != tt.enveloped {
+				t.Errorf("raw envelope shown: %v, want %v\n%s", got, tt.enveloped, lines)
+			}
+			if tt.enveloped && !strings.Contains(lines, fmt.Sprintf("Status code: %d", tt.status)) {
+				t.Errorf("no status code line\n%s", lines)
+			}
+			if strings.HasPrefix(tt.body, "{") {
+				formatted, _ := indentJSON([]byte(tt.body))
+				if !strings.Contains(lines, "Response: "+string(formatted)) {
+					t.Errorf("the body isn't rendered as the response\n%s", lines)
+				}
+			}
+
+			var err error
+			if inv.StatusCode >= 400 {
+				err = &playtools.StatusError{StatusCode: inv.StatusCode}
+			}
+			if code := classifyError(err, inv).exitCode(); code != tt.exit {
+				t.Errorf("exit code %d, want %d", code, tt.exit)
+			}
+		})
+	}
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/lambda"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// envInfoTTL is how long fetched identity/function info is considered fresh
+const envInfoTTL = 5 * time.Minute
+
+// envInfo is the caller identity and function configuration of an environment
+type envInfo struct {
+	Account      string    `json:"account"`
+	Arn          string    `json:"arn"`
+	FunctionName string    `json:"function_name"`
+	Runtime      string    `json:"runtime"`
+	LastModified string    `json:"last_modified"`
+	Timeout      int32     `json:"timeout"`
+	FetchedAt    time.Time `json:"fetched_at"`
+}
+
+// envInfoMsg is sent when a background refresh of an environment finishes
+type envInfoMsg struct {
+	env  string
+	info envInfo
+	err  error
+}
+
+func (i envInfo) stale() bool {
+	return time.Since(i.FetchedAt) > envInfoTTL
+}
+
+// fetchEnvInfoCmd refreshes the environment info in the background. It never
+// triggers an SSO login, an expired session just reports an error.
+func fetchEnvInfoCmd(env string) tea.Cmd {
+	return func() tea.Msg {
+		info, err := fetchEnvInfo(context.Background(), env)
+		return envInfoMsg{env: env, info: info, err: err}
+	}
+}
+
+func fetchEnvInfo(ctx context.Context, env string) (envInfo, error) {
+	ctx, cancel := context.WithTimeout(ctx, 15*time.Second)
+	defer cancel()
+
+	info := envInfo{FunctionName: sweepstakeFunction(env)}
+	cfg, err := config.LoadDefaultConfig(ctx, config.WithSharedConfigProfile(profileMap[env]), envRegion(env), withSkewRecording())
+	if err != nil {
+		return info, fmt.Errorf("failed to load AWS config: %v", err)
+	}
+
+	identity, err := sts.NewFromConfig(cfg).GetCallerIdentity(ctx, &sts.GetCallerIdentityInput{})
+	if err != nil {
+		return info, fmt.Errorf("failed to get caller identity: %v", err)
+	}
+	info.Account = aws.ToString(identity.Account)
+	info.Arn = aws.ToString(identity.Arn)
+
+	fn, err := lambda.NewFromConfig(cfg).GetFunctionConfiguration(ctx, &lambda.GetFunctionConfigurationInput{
+		FunctionName: aws.String(info.FunctionName),
+	})
+	if err != nil {
+		return info, fmt.Errorf("failed to get function configuration: %v", err)
+	}
+	info.Runtime = string(fn.Runtime)
+	info.LastModified = aws.ToString(fn.LastModified)
+	info.Timeout = aws.ToInt32(fn.Timeout)
+	info.FetchedAt = time.Now()
+
+	return info, nil
+}
+
+// saveEnvInfo persists fetched info so it can be shown instantly next run
+func saveEnvInfo(env string, info envInfo) {
+	_ = updateState(func(state *appState) {
+		if state.EnvInfo == nil {
+			state.EnvInfo = map[string]envInfo{}
+		}
+		state.EnvInfo[env] = info
+	})
+}
+
+// formatAge renders a duration as a short "3m" style age
+func formatAge(d time.Duration) string {
+	switch {
+	case d < time.Minute:
+		return fmt.Sprintf("%ds", int(d.Seconds()))
+	case d < time.Hour:
+		return fmt.Sprintf("%dm", int(d.Minutes()))
+	default:
+		return fmt.Sprintf("%dh", int(d.Hours()))
+	}
+}
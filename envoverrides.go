@@ -0,0 +1,98 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/revrost/playtools/pkg/playtools"
+)
+
+// functionTemplateEnvVar replaces the function name template, with one %s
+// for the environment
+const functionTemplateEnvVar = "PLAYTOOLS_FUNCTION_TEMPLATE"
+
+// profileEnvVar is the variable replacing an environment's profile, like
+// PLAYTOOLS_PROD_PROFILE
+func profileEnvVar(env string) string {
+	return "PLAYTOOLS_" + strings.ToUpper(strings.ReplaceAll(env, "-", "_")) + "_PROFILE"
+}
+
+// envOverrides are the profile and function name overrides of the process
+// environment. They win over the config file, so a team with its own SSO
+// profile names needs no config at all.
+type envOverrides struct {
+	// profiles maps a profile variable to its value
+	profiles         map[string]string
+	functionTemplate string
+	err              error
+}
+
+// readEnvOverrides resolves the overrides once, at startup
+var readEnvOverrides = sync.OnceValue(func() envOverrides {
+	o := envOverrides{profiles: map[string]string{}}
+	for _, kv := range os.Environ() {
+		name, value, _ := strings.Cut(kv, "=")
+		if strings.HasPrefix(name, "PLAYTOOLS_") && strings.HasSuffix(name, "_PROFILE") && value != "" {
+			o.profiles[name] = value
+		}
+	}
+	if t := os.Getenv(functionTemplateEnvVar); t != "" {
+		if err := checkFunctionTemplate(t); err != nil {
+			o.err = fmt.Errorf("%s: %v", functionTemplateEnvVar, err)
+		}
+		o.functionTemplate = t
+	}
+	return o
+})
+
+// checkFunctionTemplate makes sure a function name template takes exactly
+// the environment
+func checkFunctionTemplate(t string) error {
+	return playtools.CheckFunctionTemplate(t)
+}
+
+// applyEnvOverrides applies the overrides on top of the config
+func applyEnvOverrides() error {
+	o := readEnvOverrides()
+	if o.err != nil {
+		return o.err
+	}
+	for env := range profileMap {
+		if p, ok := o.profiles[profileEnvVar(env)]; ok {
+			profileMap[env] = p
+		}
+	}
+	if o.functionTemplate != "" {
+		sweepstakeFunctionName = o.functionTemplate
+	}
+	return nil
+}
+
+// profileOverride names the variable env's profile came from, empty when
+// it's from the config or built in
+func profileOverride(env string) string {
+	if _, ok := readEnvOverrides().profiles[profileEnvVar(env)]; ok {
+		return profileEnvVar(env)
+	}
+	return ""
+}
+
+// profileLabel is env's profile for display, marked when a variable set it
+func profileLabel(env string) string {
+	if v := profileOverride(env); v != "" {
+		return fmt.Sprintf("%s from %s", profileMap[env], v)
+	}
+	return profileMap[env]
+}
+
+// functionLabel is env's function name for display, marked when a variable
+// set the template
+func functionLabel(env string) string {
+	name := sweepstakeFunction(env)
+	if functionMap[env] == "" && readEnvOverrides().functionTemplate != "" {
+		return fmt.Sprintf("%s from %s", name, functionTemplateEnvVar)
+	}
+	return name
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// switchAckPhrase has to be typed to switch into a protected environment
+// with Ctrl+E
+func switchAckPhrase(env string) string {
+	return "switch to " + env
+}
+
+// envSwitcher is the Ctrl+E overlay picking another environment without
+// going back to the first screen
+type envSwitcher struct {
+	cursor  int
+	acking  bool
+	input   textinput.Model
+	message string
+	// target is the protected environment being acknowledged
+	target string
+	// returnTo is the screen the switcher was opened over
+	returnTo Screen
+}
+
+func newEnvSwitcher() envSwitcher {
+	ti := textinput.New()
+	ti.CharLimit = 32
+	return envSwitcher{input: ti}
+}
+
+// openEnvSwitch shows the environment switcher over the current screen,
+// leaving the screen's state, like a half filled form, as it is
+func (m model) openEnvSwitch() (tea.Model, tea.Cmd) {
+	s := &m.envSwitch
+	s.returnTo = m.currentScreen
+	s.acking, s.message = false, ""
+	s.input.SetValue("")
+	s.input.Blur()
+	s.cursor = 0
+	for i, li := range m.envList.Items() {
+		if it, ok := li.(item); ok && it.action == m.selectedEnv {
+			s.cursor = i
+		}
+	}
+	m.currentScreen = EnvSwitchScreen
+	return m, nil
+}
+
+// updateEnvSwitch handles the keys of the environment switcher
+func (m model) updateEnvSwitch(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	s := &m.envSwitch
+	items := m.envList.Items()
+	if s.acking {
+		switch msg.Type {
+		case tea.KeyEnter:
+			if phrase := switchAckPhrase(s.target); strings.TrimSpace(s.input.Value()) != phrase {
+				s.message = tr(msgAckMismatch, phrase)
+				return m, nil
+			}
+			return m.switchEnv(s.target)
+		case tea.KeyEsc:
+			s.acking, s.message = false, ""
+			s.input.Blur()
+			return m, nil
+		}
+		var cmd tea.Cmd
+		s.input, cmd = s.input.Update(msg)
+		return m, cmd
+	}
+	switch keys.name(msg) {
+	case "esc", "b", "ctrl+e":
+		m.currentScreen = s.returnTo
+		return m, nil
+	case "ctrl+c":
+		return m, tea.Quit
+	case "up", "k":
+		s.cursor = max(0, s.cursor-1)
+	case "down", "j":
+		s.cursor = min(len(items)-1, s.cursor+1)
+	case "enter":
+		it, ok := items[s.cursor].(item)
+		if !ok {
+			return m, nil
+		}
+		if it.action == m.selectedEnv {
+			m.currentScreen = s.returnTo
+			return m, nil
+		}
+		if isProtected(it.action) {
+			s.acking, s.message, s.target = true, "", it.action
+			s.input.SetValue("")
+			s.input.Placeholder = switchAckPhrase(it.action)
+			return m, s.input.Focus()
+		}
+		return m.switchEnv(it.action)
+	}
+	return m, nil
+}
+
+// envSwitchReturn is where a switch lands: the prompt keeps its values but
+// is submitted again so every check runs for the new environment, screens
+// showing the old environment's results go to the action list, and the
+// rest stay as they were
+func envSwitchReturn(from Screen) Screen {
+	switch from {
+	case PromptScreen, ConfirmScreen:
+		return PromptScreen
+	case HistoryScreen, StatsScreen, PresetsScreen:
+		return from
+	}
+	return ActionScreen
+}
+
+// switchEnv selects another environment, dropping what was cached for the
+// previous one and confirming the identity in the new one before its
+// actions can run
+func (m model) switchEnv(env string) (tea.Model, tea.Cmd) {
+	m.selectedEnv = env
+	for i, li := range m.envList.Items() {
+		if it, ok := li.(item); ok && it.action == env {
+			m.envList.Select(i)
+		}
+	}
+
+	m.pendingPayload, m.batchRows = EventPayload{}, nil
+	m.acking = false
+	m.questState, m.questStateLoading, m.questStateErr = nil, false, nil
+	m.recentErrors, m.recentErrorsOpen, m.recentErrorsLoading = nil, false, false
+	m.shadow, m.shadowRunning = nil, false
+	delete(m.envInfo, env)
+	delete(m.envInfoErr, env)
+	m.lockedEnv, m.lockNote = env, ""
+	m.applyAvailability()
+	m.applyRestrictions()
+	recordSessionEvent(sessionEvent{Event: "switch_env", Env: env})
+
+	m.currentScreen = envSwitchReturn(m.envSwitch.returnTo)
+	// A rollback's complete belongs to the previous environment
+	if m.currentScreen == PromptScreen && m.selectedAction == string(ActionRollback) {
+		m.currentScreen = ActionScreen
+	}
+	cmds := []tea.Cmd{m.refreshBudget()}
+	if m.currentScreen == PromptScreen {
+		cmds = append(cmds, m.promptForm.Focus(0))
+	}
+	m.envInfoRefreshing[env] = true
+	return m, tea.Batch(append(cmds, fetchEnvInfoCmd(env))...)
+}
+
+// envSwitchView renders the switcher as a small box in the middle of the screen
+func (m model) envSwitchView() string {
+	s := m.envSwitch
+	var sb strings.Builder
+	sb.WriteString(tr(msgEnvSwitchTitle) + "\n\n")
+	for i, li := range m.envList.Items() {
+		it, ok := li.(item)
+		if !ok {
+			continue
+		}
+		line := "  " + it.title
+		if i == s.cursor {
+			line = glyphs.Pointer + " " + it.title
+		}
+		if it.action == m.selectedEnv {
+			line += dimStyle.Render(" " + tr(msgEnvSwitchActive))
+		}
+		sb.WriteString(line + "\n")
+	}
+	sb.WriteString("\n")
+	switch {
+	case s.acking:
+		sb.WriteString(tr(msgEnvSwitchAck, switchAckPhrase(s.target)) + "\n" + s.input.View() + "\n")
+		if s.message != "" {
+			sb.WriteString(errorStyle.Render(s.message) + "\n")
+		}
+	case pinnedEnv != "":
+		sb.WriteString(dimStyle.Render(tr(msgEnvSwitchPinned, pinnedEnv)) + "\n")
+	default:
+		sb.WriteString(dimStyle.Render(tr(msgEnvSwitchHelp)) + "\n")
+	}
+	box := overlayStyle.Render(strings.TrimSuffix(sb.String(), "\n"))
+	return lipgloss.Place(m.width, max(0, m.height-statusBarHeight), lipgloss.Center, lipgloss.Center, box)
+}
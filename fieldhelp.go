@@ -0,0 +1,245 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// fieldHelp is the help text and example values of a prompt field, keyed by
+// the payload field the prompt fills
+type fieldHelp struct {
+	Help     string
+	Examples []string
+}
+
+// defaultFieldHelp is the help of the sweepstake prompts, used when neither
+// the config nor the tool's schema describes a field
+var defaultFieldHelp = map[string]fieldHelp{
+	"sweepstake_quest_id": {
+		Help:     "The numeric ID of the sweepstake quest, as shown in the quest admin.",
+		Examples: []string{"3907"},
+	},
+	"duration_minutes": {
+		Help:     "How long the sweepstake runs once started, in minutes or as a duration like 48h, 2h30m or 7d.",
+		Examples: []string{"60", "48h", "7d"},
+	},
+	"batch_file": {
+		Help:     "A CSV file with a quest_id column and optional batch_size and dry_run columns, one quest per row.",
+		Examples: []string{"quests.csv"},
+	},
+	"justification": {
+		Help: "Why the quest needs a rollback. It's recorded with the rollback in the history.",
+	},
+	"batch_size": {
+		Help:     "How many entries the lambda calculates per batch. Leave it blank for the lambda's default, and lower it when a big sweepstake times out.",
+		Examples: []string{"500", "1000"},
+	},
+	"snapshot_id": {
+		Help:     "The historical snapshot the process dry run replays the quest against.",
+		Examples: []string{"snap-7"},
+	},
+}
+
+// numericFields take digits only, so a typed "?" can toggle the help panel
+// instead of going into the field
+var numericFields = map[string]bool{"sweepstake_quest_id": true, "duration_minutes": true, "batch_size": true}
+
+// fieldHelpFor describes a payload field, taking the help and the examples
+// each from the config, the tool's schema or the defaults, in that order
+func (m model) fieldHelpFor(name string) fieldHelp {
+	var h fieldHelp
+	for _, source := range []map[string]fieldHelp{toolRegistry[sweepstakeTool].Fields, m.schemaHelp, defaultFieldHelp} {
+		f := source[name]
+		if h.Help == "" {
+			h.Help = f.Help
+		}
+		if len(h.Examples) == 0 {
+			h.Examples = f.Examples
+		}
+	}
+	return h
+}
+
+// schemaHelpMsg carries the field help read from the tool's schema, and the
+// schema of its sweepstake_overrides when it has one
+type schemaHelpMsg struct {
+	fields    map[string]fieldHelp
+	overrides *jsonSchema
+	err       error
+}
+
+func loadSchemaHelpCmd(source string) tea.Cmd {
+	return func() tea.Msg {
+		body, err := readSchema(source)
+		if err != nil {
+			return schemaHelpMsg{err: err}
+		}
+		fields, err := parseSchemaHelp(body)
+		if err != nil {
+			return schemaHelpMsg{err: err}
+		}
+		overrides, err := parseOverridesSchema(body)
+		return schemaHelpMsg{fields: fields, overrides: overrides, err: err}
+	}
+}
+
+// readSchema reads a JSON Schema from a file or an http(s) URL
+func readSchema(source string) ([]byte, error) {
+	var body []byte
+	if strings.HasPrefix(source, "https://") || strings.HasPrefix(source, "http://") {
+		client := http.Client{Timeout: 10 * time.Second}
+		resp, err := client.Get(source)
+		if err != nil {
+			return nil, err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("fetching %s: %s", source, resp.Status)
+		}
+		if body, err = io.ReadAll(resp.Body); err != nil {
+			return nil, err
+		}
+	} else {
+		var err error
+		if body, err = os.ReadFile(source); err != nil {
+			return nil, err
+		}
+	}
+	return body, nil
+}
+
+// parseSchemaHelp reads the field help of a JSON Schema. Examples that
+// aren't strings are kept as compact JSON, which is how they'd be typed.
+func parseSchemaHelp(body []byte) (map[string]fieldHelp, error) {
+	var schema struct {
+		Properties map[string]struct {
+			Description string            `json:"description"`
+			Examples    []json.RawMessage `json:"examples"`
+			Example     json.RawMessage   `json:"example"`
+		} `json:"properties"`
+	}
+	if err := json.Unmarshal(body, &schema); err != nil {
+		return nil, fmt.Errorf("the schema isn't JSON: %v", err)
+	}
+	fields := map[string]fieldHelp{}
+	for name, prop := range schema.Properties {
+		h := fieldHelp{Help: strings.TrimSpace(prop.Description)}
+		examples := prop.Examples
+		if len(examples) == 0 && len(prop.Example) > 0 {
+			examples = []json.RawMessage{prop.Example}
+		}
+		for _, raw := range examples {
+			var s string
+			if json.Unmarshal(raw, &s) != nil {
+				s = string(raw)
+			}
+			h.Examples = append(h.Examples, s)
+		}
+		fields[name] = h
+	}
+	return fields, nil
+}
+
+// loadSchemaHelpOnce starts reading the tool's schema the first time a
+// prompt opens
+func (m *model) loadSchemaHelpOnce() tea.Cmd {
+	source := toolRegistry[sweepstakeTool].Schema
+	if source == "" || m.schemaHelpRequested {
+		return nil
+	}
+	m.schemaHelpRequested = true
+	return loadSchemaHelpCmd(source)
+}
+
+// updateFieldHelp handles the help panel keys of the prompt: F1, or "?" on
+// the button and numeric fields, toggles the panel, and Ctrl+Y puts the
+// focused field's next example in it. It reports whether it took the key.
+func (m *model) updateFieldHelp(msg tea.KeyMsg) bool {
+	name := m.promptForm.FocusedName()
+	switch msg.String() {
+	case "f1":
+		m.fieldHelpOpen = !m.fieldHelpOpen
+		return true
+	case "?":
+		if m.promptForm.OnSubmit() || numericFields[name] {
+			m.fieldHelpOpen = !m.fieldHelpOpen
+			return true
+		}
+	case "ctrl+y":
+		examples := m.fieldHelpFor(name).Examples
+		if m.promptForm.OnSubmit() || len(examples) == 0 {
+			return true
+		}
+		if m.exampleField != name {
+			m.exampleField, m.exampleNext = name, 0
+		}
+		m.promptForm.SetValue(m.promptForm.focus, examples[m.exampleNext%len(examples)])
+		m.promptForm.Input(m.promptForm.focus).CursorEnd()
+		m.exampleNext++
+		return true
+	}
+	return false
+}
+
+// fieldHelpKeys describes the help panel keys for the prompt's help line
+func (m model) fieldHelpKeys() string {
+	if m.promptForm.OnSubmit() {
+		return ""
+	}
+	keys := ", " + tr(msgFieldHelpKey)
+	if len(m.fieldHelpFor(m.promptForm.FocusedName()).Examples) > 0 {
+		keys += ", " + tr(msgFieldExampleKey)
+	}
+	return keys
+}
+
+// fieldHelpMinWidth is the narrowest the panel gets beside the form before
+// it moves below it
+const fieldHelpMinWidth = 30
+
+// promptBody lays out the prompt form with the focused field's help panel
+// beside it, or below it when the terminal is too narrow. With ASCII glyphs
+// the panel is plain indented text under the form, without a border.
+func (m model) promptBody(formView string) string {
+	if !m.fieldHelpOpen || m.promptForm.OnSubmit() {
+		return formView
+	}
+	name := m.promptForm.FocusedName()
+	h := m.fieldHelpFor(name)
+	text := h.Help
+	if text == "" {
+		text = tr(msgFieldHelpNone)
+	}
+	if len(h.Examples) > 0 {
+		text += "\n\n" + tr(msgFieldHelpExamples) + "\n  " + strings.Join(h.Examples, "\n  ")
+	}
+	if m.schemaHelpErr != nil {
+		text += "\n\n" + glyphs.Warn + " " + tr(msgFieldHelpSchema, m.schemaHelpErr)
+	}
+
+	frame, _ := m.styles.Doc.GetFrameSize()
+	available := m.width - frame - 2
+	if glyphs == asciiGlyphs {
+		wrapped := lipgloss.NewStyle().Width(max(fieldHelpMinWidth, available-2)).Render(text)
+		return formView + "\n\n" + dimStyle.Render("  "+strings.ReplaceAll(wrapped, "\n", "\n  "))
+	}
+	boxFrame := overlayStyle.GetHorizontalFrameSize()
+	formWidth := lipgloss.Width(formView)
+	beside := available - formWidth - 4 - boxFrame
+	if beside >= fieldHelpMinWidth {
+		box := overlayStyle.Width(min(beside, 60) + overlayStyle.GetHorizontalPadding()).Render(text)
+		// Line the panel up with the focused field, three lines per field
+		box = strings.Repeat("\n", m.promptForm.focus*3) + box
+		return lipgloss.JoinHorizontal(lipgloss.Top, formView, "    ", box)
+	}
+	width := max(fieldHelpMinWidth, available-boxFrame)
+	return formView + "\n\n" + overlayStyle.Width(min(width, 60)+overlayStyle.GetHorizontalPadding()).Render(text)
+}
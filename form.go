@@ -0,0 +1,179 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// formAction is what a key did to a form
+type formAction int
+
+const (
+	formNone formAction = iota
+	formSubmit
+	formCancel
+)
+
+type formField struct {
+	label string
+	// name is the payload field the input fills, for its help
+	name  string
+	input textinput.Model
+}
+
+// form manages the keyboard focus of a set of inputs followed by a submit
+// button. Tab and Shift+Tab (or down and up) cycle through them in order,
+// Enter moves to the next field and submits from the last field or the
+// button, Esc cancels.
+type form struct {
+	fields []formField
+	submit string
+	// focus is the focused field, len(fields) when the button has it
+	focus int
+	// clean holds the values of the last MarkClean, to tell edits apart
+	clean []string
+}
+
+func newForm(submit string, labels ...string) form {
+	f := form{submit: submit}
+	for _, label := range labels {
+		ti := textinput.New()
+		ti.Prompt = ""
+		f.fields = append(f.fields, formField{label: label, input: ti})
+	}
+	return f
+}
+
+// Input gives access to a field's input to configure it
+func (f *form) Input(i int) *textinput.Model {
+	return &f.fields[i].input
+}
+
+func (f form) Value(i int) string {
+	return f.fields[i].input.Value()
+}
+
+func (f *form) SetValue(i int, value string) {
+	f.fields[i].input.SetValue(value)
+}
+
+func (f *form) SetLabel(i int, label string) {
+	f.fields[i].label = label
+}
+
+func (f *form) SetName(i int, name string) {
+	f.fields[i].name = name
+}
+
+// MarkClean takes the current values as unedited
+func (f *form) MarkClean() {
+	f.clean = make([]string, len(f.fields))
+	for i := range f.fields {
+		f.clean[i] = f.Value(i)
+	}
+}
+
+// Dirty reports whether a field was edited to something other than blank
+// since MarkClean, the input leaving would lose
+func (f form) Dirty() bool {
+	for i := range f.fields {
+		v := strings.TrimSpace(f.Value(i))
+		if v != "" && (i >= len(f.clean) || v != strings.TrimSpace(f.clean[i])) {
+			return true
+		}
+	}
+	return false
+}
+
+// Values are the field values by payload field name
+func (f form) Values() map[string]string {
+	values := make(map[string]string, len(f.fields))
+	for _, field := range f.fields {
+		values[field.name] = field.input.Value()
+	}
+	return values
+}
+
+// FocusedName is the payload field of the focused input, empty on the button
+func (f form) FocusedName() string {
+	if f.OnSubmit() {
+		return ""
+	}
+	return f.fields[f.focus].name
+}
+
+// Focus moves the focus to field i, or to the button for len(fields)
+func (f *form) Focus(i int) tea.Cmd {
+	f.focus = i
+	var cmd tea.Cmd
+	for j := range f.fields {
+		if j == i {
+			cmd = f.fields[j].input.Focus()
+		} else {
+			f.fields[j].input.Blur()
+		}
+	}
+	return cmd
+}
+
+// OnSubmit reports whether the button has the focus
+func (f form) OnSubmit() bool {
+	return f.focus == len(f.fields)
+}
+
+func (f form) Update(msg tea.Msg) (form, tea.Cmd, formAction) {
+	if key, ok := msg.(tea.KeyMsg); ok {
+		stops := len(f.fields) + 1
+		switch key.String() {
+		case "tab", "down":
+			return f, f.Focus((f.focus + 1) % stops), formNone
+		case "shift+tab", "up":
+			return f, f.Focus((f.focus + stops - 1) % stops), formNone
+		case "esc":
+			return f, nil, formCancel
+		case "enter":
+			if f.focus < len(f.fields)-1 {
+				return f, f.Focus(f.focus + 1), formNone
+			}
+			return f, nil, formSubmit
+		}
+	}
+	if f.OnSubmit() {
+		return f, nil, formNone
+	}
+	var cmd tea.Cmd
+	f.fields[f.focus].input, cmd = f.fields[f.focus].input.Update(msg)
+	return f, cmd, formNone
+}
+
+func (f form) View() string {
+	var sb strings.Builder
+	for i, field := range f.fields {
+		if i == f.focus {
+			sb.WriteString(formFocusStyle.Render(glyphs.Pointer+" "+field.label) + "\n")
+		} else {
+			sb.WriteString(dimStyle.Render("  "+field.label) + "\n")
+		}
+		sb.WriteString("  " + field.input.View() + "\n\n")
+	}
+	if f.OnSubmit() {
+		sb.WriteString(formButtonFocusStyle.Render(glyphs.Pointer + " " + f.submit + " "))
+	} else {
+		sb.WriteString(formButtonStyle.Render("  " + f.submit + " "))
+	}
+	return sb.String()
+}
+
+// Help describes the keys for the current focus
+func (f form) Help() string {
+	if f.OnSubmit() {
+		return tr(msgFormHelpButton, strings.ToLower(f.submit))
+	}
+	next := tr(msgFormContinue)
+	if f.focus < len(f.fields)-1 {
+		next = tr(msgFormNextField)
+	}
+	return tr(msgFormHelpField, next)
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// wantFocus checks that only the focused field has a focused, visible
+// cursor, and that the view and help agree on where the focus is
+func wantFocus(t *testing.T, f form, focus int) {
+	t.Helper()
+	if f.focus != focus {
+		t.Fatalf("focus on %d, want %d", f.focus, focus)
+	}
+	for i, field := range f.fields {
+		focused := i == focus
+		if field.input.Focused() != focused || field.input.Cursor.Blink == focused {
+			t.Errorf("field %d: focused %v, cursor hidden %v", i, field.input.Focused(), field.input.Cursor.Blink)
+		}
+	}
+	view := f.View()
+	if f.OnSubmit() {
+		if !strings.Contains(view, glyphs.Pointer+" Send") || f.Help() != tr(msgFormHelpButton, "send") {
+			t.Errorf("button focused, view\n%s\nhelp %q", view, f.Help())
+		}
+		return
+	}
+	if !strings.Contains(view, glyphs.Pointer+" "+f.fields[focus].label) {
+		t.Errorf("field %d focused, view\n%s", focus, view)
+	}
+	next := tr(msgFormNextField)
+	if focus == len(f.fields)-1 {
+		next = tr(msgFormContinue)
+	}
+	if f.Help() != tr(msgFormHelpField, next) {
+		t.Errorf("field %d focused, help %q", focus, f.Help())
+	}
+}
+
+func TestFormTabOrder(t *testing.T) {
+	f := newForm("Send", "Quest ID", "Batch size", "Note")
+	if f.Focus(0) == nil {
+		t.Error("focusing a field didn't start its cursor blinking")
+	}
+	wantFocus(t, f, 0)
+
+	steps := []struct {
+		key   tea.KeyType
+		focus int
+	}{
+		{tea.KeyTab, 1},
+		{tea.KeyTab, 2},
+		{tea.KeyTab, 3},
+		// Past the button back to the first field
+		{tea.KeyTab, 0},
+		{tea.KeyShiftTab, 3},
+		{tea.KeyShiftTab, 2},
+		{tea.KeyUp, 1},
+		{tea.KeyDown, 2},
+	}
+	for _, step := range steps {
+		var cmd tea.Cmd
+		var action formAction
+		f, cmd, action = f.Update(tea.KeyMsg{Type: step.key})
+		if action != formNone {
+			t.Fatalf("%s did %d", step.key, action)
+		}
+		if onField := step.focus < 3; (cmd != nil) != onField {
+			t.Errorf("%s to %d: blink command %v", step.key, step.focus, cmd != nil)
+		}
+		wantFocus(t, f, step.focus)
+	}
+}
+
+func TestFormEnterAndEsc(t *testing.T) {
+	f := newForm("Send", "Quest ID", "Batch size", "Note")
+	f.Focus(0)
+
+	// Typing fills the focused field only
+	f, _, _ = f.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("42")})
+	f, _, _ = f.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	wantFocus(t, f, 1)
+	f, _, _ = f.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("10")})
+	if f.Value(0) != "42" || f.Value(1) != "10" || f.Value(2) != "" {
+		t.Errorf("values %q %q %q", f.Value(0), f.Value(1), f.Value(2))
+	}
+	f, _, _ = f.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	wantFocus(t, f, 2)
+
+	// Enter submits from the last field and from the button
+	if _, _, action := f.Update(tea.KeyMsg{Type: tea.KeyEnter}); action != formSubmit {
+		t.Errorf("enter on the last field did %d", action)
+	}
+	f, _, _ = f.Update(tea.KeyMsg{Type: tea.KeyTab})
+	wantFocus(t, f, 3)
+	f, _, _ = f.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("x")})
+	if f.Value(2) != "" {
+		t.Errorf("typing on the button went to a field: %q", f.Value(2))
+	}
+	if _, _, action := f.Update(tea.KeyMsg{Type: tea.KeyEnter}); action != formSubmit {
+		t.Errorf("enter on the button did %d", action)
+	}
+	if _, _, action := f.Update(tea.KeyMsg{Type: tea.KeyEsc}); action != formCancel {
+		t.Errorf("esc did %d", action)
+	}
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// displayLocale picks the number format of summaries, set with locale in the
+// config file
+var displayLocale = "en"
+
+// numberFormat is how a locale groups thousands and marks decimals
+type numberFormat struct {
+	group, decimal string
+}
+
+var numberFormats = map[string]numberFormat{
+	"en": {",", "."},
+	"de": {".", ","},
+	"es": {".", ","},
+	"it": {".", ","},
+	"nl": {".", ","},
+	"pt": {".", ","},
+	"fr": {" ", ","},
+	"sv": {" ", ","},
+	"ja": {",", "."},
+}
+
+// formatNumber formats an integer with the locale's thousands separators
+func formatNumber(n int64, locale string) string {
+	return groupDigits(strconv.FormatInt(n, 10), locale)
+}
+
+// groupDigits adds the locale's thousands separators to a formatted integer
+func groupDigits(digits, locale string) string {
+	nf := numberFormats[locale]
+	if nf.group == "" {
+		nf = numberFormats["en"]
+	}
+	sign := ""
+	if strings.HasPrefix(digits, "-") {
+		sign, digits = "-", digits[1:]
+	}
+	var sb strings.Builder
+	for i, d := range digits {
+		if i > 0 && (len(digits)-i)%3 == 0 {
+			sb.WriteString(nf.group)
+		}
+		sb.WriteRune(d)
+	}
+	return sign + sb.String()
+}
+
+// formatDecimal formats a number, keeping up to two decimals when it isn't whole
+func formatDecimal(f float64, locale string) string {
+	f = math.Round(f*100) / 100
+	whole, frac := math.Modf(math.Abs(f))
+	s := formatNumber(int64(whole), locale)
+	if f < 0 {
+		s = "-" + s
+	}
+	if frac == 0 {
+		return s
+	}
+	nf := numberFormats[locale]
+	if nf.decimal == "" {
+		nf = numberFormats["en"]
+	}
+	decimals := strings.TrimRight(strconv.FormatFloat(frac, 'f', 2, 64)[2:], "0")
+	if decimals == "" {
+		return s
+	}
+	return s + nf.decimal + decimals
+}
+
+// formatSize renders a file size with the largest unit under 1024, like
+// "3.2 MB"
+func formatSize(n int64) string {
+	if n < 1024 {
+		return fmt.Sprintf("%d B", n)
+	}
+	size, unit := float64(n)/1024, 0
+	for size >= 1024 && unit < 3 {
+		size, unit = size/1024, unit+1
+	}
+	return fmt.Sprintf("%.1f %s", size, []string{"KB", "MB", "GB", "TB"}[unit])
+}
+
+// formatTime renders a time in UTC with how far it is from now, like
+// "2024-06-01 10:30 UTC (in 2h 15m)"
+func formatTime(t, now time.Time) string {
+	d := t.Sub(now)
+	rel := "now"
+	switch {
+	case d >= time.Minute:
+		rel = "in " + formatSpan(d)
+	case d <= -time.Minute:
+		rel = formatSpan(-d) + " ago"
+	}
+	return fmt.Sprintf("%s UTC (%s)", t.UTC().Format("2006-01-02 15:04"), rel)
+}
+
+// formatSpan renders a duration with its two largest units, like "2h 15m"
+func formatSpan(d time.Duration) string {
+	days := int(d.Hours()) / 24
+	hours := int(d.Hours()) % 24
+	minutes := int(d.Minutes()) % 60
+	switch {
+	case days > 0 && hours > 0:
+		return fmt.Sprintf("%dd %dh", days, hours)
+	case days > 0:
+		return fmt.Sprintf("%dd", days)
+	case hours > 0 && minutes > 0:
+		return fmt.Sprintf("%dh %dm", hours, minutes)
+	case hours > 0:
+		return fmt.Sprintf("%dh", hours)
+	default:
+		return fmt.Sprintf("%dm", minutes)
+	}
+}
+
+// indentJSON pretty-prints JSON keeping the key order of the original, so
+// the responses of two runs can be diffed
+func indentJSON(body []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := json.Indent(&buf, bytes.TrimSpace(body), "", "  "); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// objectKeys returns the top-level keys of a JSON object in their original order
+func objectKeys(body []byte) ([]string, error) {
+	dec := json.NewDecoder(bytes.NewReader(body))
+	if t, err := dec.Token(); err != nil || t != json.Delim('{') {
+		return nil, fmt.Errorf("not a JSON object")
+	}
+	var keys []string
+	for dec.More() {
+		t, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, t.(string))
+		var skip json.RawMessage
+		if err := dec.Decode(&skip); err != nil {
+			return nil, err
+		}
+	}
+	return keys, nil
+}
+
+// summaryFieldOrder is the order the known response fields are summarized
+// in. Other fields follow in the order of the response.
+var summaryFieldOrder = []string{
+	"status",
+	"sweepstake_quest_id",
+	"duration_minutes",
+	"entries",
+	"winners",
+	"distributed",
+	"reversed_count",
+	"started_at",
+	"ends_at",
+	"completed_at",
+}
+
+// responseSummary lists the top-level numbers and timestamps of a response
+// in readable form, or totals and lists the results of an array response. The raw response is still shown as is below it.
+func responseSummary(body []byte, now time.Time) []string {
+	if elems, ok := responseElements(body); ok {
+		return resultsSummary(elems)
+	}
+	var resp map[string]interface{}
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return nil
+	}
+	order, err := objectKeys(body)
+	if err != nil {
+		return nil
+	}
+
+	var lines []string
+	for _, k := range summaryKeys(order) {
+		switch v := resp[k].(type) {
+		case float64:
+			lines = append(lines, fmt.Sprintf("  %s: %s", k, formatDecimal(v, displayLocale)))
+		case string:
+			if t, err := time.Parse(time.RFC3339, v); err == nil {
+				lines = append(lines, fmt.Sprintf("  %s: %s", k, formatTime(t, now)))
+			}
+		}
+	}
+	if len(lines) == 0 {
+		return nil
+	}
+	return append([]string{"Summary:"}, lines...)
+}
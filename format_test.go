@@ -0,0 +1,162 @@
+package main
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/revrost/playtools/pkg/playtools"
+)
+
+func TestFormatNumber(t *testing.T) {
+	for _, tt := range []struct {
+		n      int64
+		locale string
+		want   string
+	}{
+		{0, "en", "0"},
+		{999, "en", "999"},
+		{1000, "en", "1,000"},
+		{1234567, "en", "1,234,567"},
+		{-1234567, "en", "-1,234,567"},
+		{1234567, "de", "1.234.567"},
+		{1234567, "fr", "1\u202f234\u202f567"},
+		{-100000, "sv", "-100\u00a0000"},
+		{1234567, "ja", "1,234,567"},
+		// An unknown locale falls back to English
+		{1234567, "xx", "1,234,567"},
+		{-9223372036854775808, "en", "-9,223,372,036,854,775,808"},
+	} {
+		if got := formatNumber(tt.n, tt.locale); got != tt.want {
+			t.Errorf("formatNumber(%d, %s) = %q, want %q", tt.n, tt.locale, got, tt.want)
+		}
+	}
+}
+
+func TestFormatDecimal(t *testing.T) {
+	for _, tt := range []struct {
+		f      float64
+		locale string
+		want   string
+	}{
+		{0, "en", "0"},
+		{1500, "en", "1,500"},
+		{1234.5, "en", "1,234.5"},
+		{1234.56, "de", "1.234,56"},
+		{1234.567, "en", "1,234.57"},
+		{0.999, "en", "1"},
+		{0.05, "fr", "0,05"},
+		{12345.5, "fr", "12\u202f345,5"},
+		{-0.5, "en", "-0.5"},
+		{-1234.25, "de", "-1.234,25"},
+		{12.3, "xx", "12.3"},
+	} {
+		if got := formatDecimal(tt.f, tt.locale); got != tt.want {
+			t.Errorf("formatDecimal(%v, %s) = %q, want %q", tt.f, tt.locale, got, tt.want)
+		}
+	}
+}
+
+func TestFormatSize(t *testing.T) {
+	for _, tt := range []struct {
+		n    int64
+		want string
+	}{
+		{0, "0 B"},
+		{1023, "1023 B"},
+		{1024, "1.0 KB"},
+		{1536, "1.5 KB"},
+		{3355443, "3.2 MB"},
+		{5 << 30, "5.0 GB"},
+		{3 << 40, "3.0 TB"},
+		// There's no unit past TB
+		{2048 << 40, "2048.0 TB"},
+	} {
+		if got := formatSize(tt.n); got != tt.want {
+			t.Errorf("formatSize(%d) = %q, want %q", tt.n, got, tt.want)
+		}
+	}
+}
+
+func TestFormatTime(t *testing.T) {
+	now := time.Date(2024, 6, 1, 8, 15, 0, 0, time.UTC)
+	for _, tt := range []struct {
+		t    time.Time
+		want string
+	}{
+		{now, "2024-06-01 08:15 UTC (now)"},
+		{now.Add(30 * time.Second), "2024-06-01 08:15 UTC (now)"},
+		{now.Add(-59 * time.Second), "2024-06-01 08:14 UTC (now)"},
+		{now.Add(time.Minute), "2024-06-01 08:16 UTC (in 1m)"},
+		{now.Add(2*time.Hour + 15*time.Minute), "2024-06-01 10:30 UTC (in 2h 15m)"},
+		{now.Add(3 * time.Hour), "2024-06-01 11:15 UTC (in 3h)"},
+		{now.Add(-50 * time.Hour), "2024-05-30 06:15 UTC (2d 2h ago)"},
+		{now.Add(-7 * 24 * time.Hour), "2024-05-25 08:15 UTC (7d ago)"},
+		// Times in other zones are shown in UTC
+		{time.Date(2024, 6, 1, 12, 15, 0, 0, time.FixedZone("UTC+4", 4*3600)), "2024-06-01 08:15 UTC (now)"},
+	} {
+		if got := formatTime(tt.t, now); got != tt.want {
+			t.Errorf("formatTime(%s) = %q, want %q", tt.t, got, tt.want)
+		}
+	}
+}
+
+func TestIndentJSONKeepsOrder(t *testing.T) {
+	response := readFixture(t, "order/response.json")
+	want := bytes.TrimSpace(readFixture(t, "order/response.golden"))
+	first, err := indentJSON(response)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(first, want) {
+		t.Errorf("indented\n%s\nwant\n%s", first, want)
+	}
+	// The same response, however it's spaced, renders to the same bytes
+	respaced, err := indentJSON(want)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(respaced, first) {
+		t.Errorf("respaced response renders as\n%s", respaced)
+	}
+	for range 20 {
+		if again, _ := indentJSON(response); !bytes.Equal(again, first) {
+			t.Fatalf("rendered differently\n%s", again)
+		}
+	}
+}
+
+func TestResponseSummaryOrder(t *testing.T) {
+	now := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	response := readFixture(t, "order/response.json")
+	want := []string{
+		"Summary:",
+		"  sweepstake_quest_id: 42",
+		"  entries: 1,500",
+		"  winners: 12",
+		"  zeta: 1",
+		"  ratio: 0.25",
+	}
+	for range 20 {
+		if got := responseSummary(response, now); !reflect.DeepEqual(got, want) {
+			t.Fatalf("summary\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+		}
+	}
+}
+
+// The output screen renders the same response to the same bytes every time
+func TestInvocationLinesStable(t *testing.T) {
+	inv := &invocation{Env: devEnv, Payload: playtools.NewPayload(ActionComplete, 42), RequestID: "req-1"}
+	inv.setResponse(readFixture(t, "order/response.json"), 200)
+	first := strings.Join(invocationLines(inv, nil), "\n")
+	if !strings.Contains(first, string(bytes.TrimSpace(readFixture(t, "order/response.golden")))) {
+		t.Errorf("the response isn't rendered in its order\n%s", first)
+	}
+	for range 20 {
+		if again := strings.Join(invocationLines(inv, nil), "\n"); again != first {
+			t.Fatalf("rendered\n%s\nthen\n%s", first, again)
+		}
+	}
+}
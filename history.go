@@ -0,0 +1,169 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/user"
+	"path/filepath"
+	"time"
+)
+
+// historyRecord is one line of the append-only history file. Fields are
+// added over time, so readers must cope with older records missing them.
+type historyRecord struct {
+	Time       time.Time `json:"time"`
+	Env        string    `json:"env"`
+	Action     Action    `json:"action"`
+	QuestID    *int      `json:"quest_id,omitempty"`
+	DryRun     bool      `json:"dry_run,omitempty"`
+	BatchSize  *int      `json:"batch_size,omitempty"`
+	Operator   string    `json:"operator,omitempty"`
+	RequestID  string    `json:"request_id,omitempty"`
+	DurationMS int64     `json:"duration_ms,omitempty"`
+	Error      string    `json:"error,omitempty"`
+	// RollbackOf links a rollback to the request ID of the complete it reverses
+	RollbackOf    string `json:"rollback_of,omitempty"`
+	Justification string `json:"justification,omitempty"`
+	// Mode is how the function was invoked, sync or async
+	Mode string `json:"mode,omitempty"`
+	// SentPayload is the payload as sent when the tool transforms it
+	SentPayload json.RawMessage `json:"sent_payload,omitempty"`
+	// IdempotencyKey is the key a complete was sent with, and
+	// IdempotencyOverride marks one replaced to distribute again
+	IdempotencyKey      string `json:"idempotency_key,omitempty"`
+	IdempotencyOverride bool   `json:"idempotency_override,omitempty"`
+	// Shadow marks a dry run of a prod payload made from its confirmation
+	Shadow bool `json:"shadow,omitempty"`
+	// Approval is how the approval gate decided, with the approver's last
+	// response, and BreakGlass the reason given for skipping it
+	Approval         string          `json:"approval,omitempty"`
+	ApprovalID       string          `json:"approval_id,omitempty"`
+	ApprovalResponse json.RawMessage `json:"approval_response,omitempty"`
+	BreakGlass       string          `json:"break_glass,omitempty"`
+	// Version is the playtools build that made the invocation
+	Version string `json:"version,omitempty"`
+	// Tool is the configured tool invoked, empty for the sweepstake calculator
+	Tool string `json:"tool,omitempty"`
+}
+
+func (r historyRecord) failed() bool {
+	return r.Error != ""
+}
+
+func historyFilePath() (string, error) {
+	dir, err := dataDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "history.jsonl"), nil
+}
+
+// operatorName is the local user running the tool
+func operatorName() string {
+	if u, err := user.Current(); err == nil && u.Username != "" {
+		return u.Username
+	}
+	if name := os.Getenv("USER"); name != "" {
+		return name
+	}
+	return "unknown"
+}
+
+// newHistoryRecord records the outcome of an invocation
+func newHistoryRecord(inv *invocation, err error) historyRecord {
+	rec := historyRecord{
+		Time:       inv.StartedAt,
+		Env:        inv.Env,
+		Action:     inv.Payload.Action,
+		QuestID:    inv.Payload.SweepstakeQuestID,
+		DryRun:     inv.Payload.DryRun,
+		BatchSize:  inv.Payload.BatchSize,
+		Operator:   operatorName(),
+		RequestID:  inv.RequestID,
+		DurationMS: inv.Duration.Milliseconds(),
+
+		RollbackOf:    inv.Payload.OriginalRequestID,
+		Justification: inv.Payload.Justification,
+		Mode:          inv.Mode,
+		SentPayload:   inv.SentPayload,
+
+		IdempotencyKey:      inv.Payload.IdempotencyKey,
+		IdempotencyOverride: inv.Payload.IdempotencyOverride,
+		Shadow:              inv.Shadow,
+
+		Approval:         inv.Approval.Status,
+		ApprovalID:       inv.Approval.ID,
+		ApprovalResponse: inv.Approval.Response,
+		BreakGlass:       inv.Approval.BreakGlass,
+
+		Version: readBuildMetadata().String(),
+		Tool:    inv.Tool,
+	}
+	if rec.Time.IsZero() {
+		rec.Time = time.Now()
+	}
+	switch {
+	case err != nil:
+		rec.Error = err.Error()
+	case inv.FunctionError != "":
+		rec.Error = "function error: " + inv.FunctionError
+	}
+	return rec
+}
+
+func appendHistory(rec historyRecord) error {
+	sessionDashboard.record(rec)
+	path, err := historyFilePath()
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	return appendLine(path, data)
+}
+
+// loadHistory reads every history record. Lines that can't be parsed, like a
+// trailing line truncated by a crash mid-write, are skipped with a warning.
+func loadHistory() ([]historyRecord, []string, error) {
+	path, err := historyFilePath()
+	if err != nil {
+		return nil, nil, err
+	}
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil, nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	defer f.Close()
+	return readHistory(f)
+}
+
+func readHistory(r io.Reader) ([]historyRecord, []string, error) {
+	var records []historyRecord
+	var warnings []string
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	line := 0
+	for scanner.Scan() {
+		line++
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		var rec historyRecord
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			warnings = append(warnings, fmt.Sprintf("skipped unreadable history line %d: %v", line, err))
+			continue
+		}
+		records = append(records, rec)
+	}
+	return records, warnings, scanner.Err()
+}
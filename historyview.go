@@ -0,0 +1,531 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/revrost/playtools/pkg/playtools"
+)
+
+// historyPageSize is how many records the history screen reads at a time
+const historyPageSize = 200
+
+// Outcomes a history record can be filtered by
+const (
+	outcomeOK     = "ok"
+	outcomeFailed = "failed"
+	outcomeDryRun = "dry-run"
+	outcomeShadow = "shadow"
+)
+
+// recordOutcome classifies a history record for filtering and totals
+func recordOutcome(rec historyRecord) string {
+	switch {
+	case rec.failed():
+		return outcomeFailed
+	case rec.Shadow:
+		return outcomeShadow
+	case rec.DryRun:
+		return outcomeDryRun
+	}
+	return outcomeOK
+}
+
+// historyFilter selects history records. Every term has to match, and a
+// term matches any of its comma separated values.
+type historyFilter struct {
+	envs     []string
+	actions  []string
+	quests   []int
+	outcomes []string
+	since    time.Time
+	until    time.Time
+}
+
+// parseHistoryFilter reads a filter expression like
+// "env:prod action:complete,process quest:42 outcome:failed since:7d until:2024-05-31".
+// A bare number is a quest ID.
+func parseHistoryFilter(expr string, now time.Time) (historyFilter, error) {
+	var f historyFilter
+	for _, term := range strings.Fields(expr) {
+		key, value, ok := strings.Cut(term, ":")
+		if !ok {
+			key, value = "quest", term
+		}
+		if value == "" {
+			return f, fmt.Errorf("%s: expected a value", key)
+		}
+		values := strings.Split(value, ",")
+		switch strings.ToLower(key) {
+		case "env":
+			f.envs = append(f.envs, values...)
+		case "action":
+			f.actions = append(f.actions, values...)
+		case "quest":
+			for _, v := range values {
+				id, err := strconv.Atoi(v)
+				if err != nil {
+					return f, fmt.Errorf("quest: expected a quest ID, got %q", v)
+				}
+				f.quests = append(f.quests, id)
+			}
+		case "outcome":
+			for _, v := range values {
+				if v != outcomeOK && v != outcomeFailed && v != outcomeDryRun && v != outcomeShadow {
+					return f, fmt.Errorf("outcome: expected %s, %s, %s or %s, got %q", outcomeOK, outcomeFailed, outcomeDryRun, outcomeShadow, v)
+				}
+			}
+			f.outcomes = append(f.outcomes, values...)
+		case "since":
+			t, err := parseFilterTime(value, now, false)
+			if err != nil {
+				return f, fmt.Errorf("since: %v", err)
+			}
+			f.since = t
+		case "until":
+			t, err := parseFilterTime(value, now, true)
+			if err != nil {
+				return f, fmt.Errorf("until: %v", err)
+			}
+			f.until = t
+		default:
+			return f, fmt.Errorf("unknown filter %q, expected env, action, quest, outcome, since or until", key)
+		}
+	}
+	return f, nil
+}
+
+// parseFilterTime reads a date like 2024-05-30, a time like
+// 2024-05-30T10:00:00Z, or an age like 7d or 12h before now. A date as the
+// end of a range includes the whole day.
+func parseFilterTime(value string, now time.Time, end bool) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	if t, err := time.ParseInLocation(time.DateOnly, value, time.Local); err == nil {
+		if end {
+			t = t.AddDate(0, 0, 1)
+		}
+		return t, nil
+	}
+	if days, ok := strings.CutSuffix(value, "d"); ok {
+		if n, err := strconv.Atoi(days); err == nil && n >= 0 {
+			return now.AddDate(0, 0, -n), nil
+		}
+	}
+	if d, err := time.ParseDuration(value); err == nil && d >= 0 {
+		return now.Add(-d), nil
+	}
+	return time.Time{}, fmt.Errorf("expected a date like 2024-05-30 or an age like 7d, got %q", value)
+}
+
+// match reports whether the record passes every term of the filter
+func (f historyFilter) match(rec historyRecord) bool {
+	switch {
+	case len(f.envs) > 0 && !slices.Contains(f.envs, rec.Env):
+		return false
+	case len(f.actions) > 0 && !slices.Contains(f.actions, string(rec.Action)):
+		return false
+	case len(f.quests) > 0 && (rec.QuestID == nil || !slices.Contains(f.quests, *rec.QuestID)):
+		return false
+	case len(f.outcomes) > 0 && !slices.Contains(f.outcomes, recordOutcome(rec)):
+		return false
+	case !f.since.IsZero() && rec.Time.Before(f.since):
+		return false
+	case !f.until.IsZero() && !rec.Time.Before(f.until):
+		return false
+	}
+	return true
+}
+
+// historyTotals sums up the records a filter matched
+type historyTotals struct {
+	Records, OK, Failed, DryRuns int
+	Duration                     time.Duration
+}
+
+func (t *historyTotals) add(rec historyRecord) {
+	t.Records++
+	switch recordOutcome(rec) {
+	case outcomeFailed:
+		t.Failed++
+	case outcomeDryRun, outcomeShadow:
+		t.DryRuns++
+	default:
+		t.OK++
+	}
+	t.Duration += time.Duration(rec.DurationMS) * time.Millisecond
+}
+
+func (t historyTotals) String() string {
+	n := func(v int) string { return formatNumber(int64(v), displayLocale) }
+	return fmt.Sprintf("%s records: %s ok, %s failed, %s dry runs, %s invoking",
+		n(t.Records), n(t.OK), n(t.Failed), n(t.DryRuns), t.Duration.Round(time.Second))
+}
+
+// eachHistoryLine reads the history file one line at a time, calling fn with
+// the offset, raw line and record of each line the filter matches. It
+// returns how many lines couldn't be parsed.
+func eachHistoryLine(path string, f historyFilter, fn func(offset int64, line []byte, rec historyRecord) error) (int, error) {
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+	r := bufio.NewReader(file)
+	var offset int64
+	skipped := 0
+	for {
+		line, err := r.ReadBytes('\n')
+		start := offset
+		offset += int64(len(line))
+		if trimmed := bytes.TrimSpace(line); len(trimmed) > 0 {
+			var rec historyRecord
+			if json.Unmarshal(trimmed, &rec) != nil {
+				skipped++
+			} else if f.match(rec) {
+				if err := fn(start, trimmed, rec); err != nil {
+					return skipped, err
+				}
+			}
+		}
+		if err == io.EOF {
+			return skipped, nil
+		}
+		if err != nil {
+			return skipped, err
+		}
+	}
+}
+
+// historyScan indexes the records a filter matched by their offset in the
+// history file, newest first, so pages of them are read on demand
+type historyScan struct {
+	offsets []int64
+	totals  historyTotals
+	skipped int
+}
+
+func scanHistory(path string, f historyFilter) (historyScan, error) {
+	var scan historyScan
+	skipped, err := eachHistoryLine(path, f, func(offset int64, _ []byte, rec historyRecord) error {
+		scan.offsets = append(scan.offsets, offset)
+		scan.totals.add(rec)
+		return nil
+	})
+	scan.skipped = skipped
+	slices.Reverse(scan.offsets)
+	return scan, err
+}
+
+// filteredHistory reads the history records f matches a line at a time,
+// so the rest are never held in memory
+func filteredHistory(f historyFilter) ([]historyRecord, error) {
+	path, err := historyFilePath()
+	if err != nil {
+		return nil, err
+	}
+	var records []historyRecord
+	_, err = eachHistoryLine(path, f, func(_ int64, _ []byte, rec historyRecord) error {
+		records = append(records, rec)
+		return nil
+	})
+	return records, err
+}
+
+// readHistoryRecords reads the records at the given offsets of the history file
+func readHistoryRecords(path string, offsets []int64) ([]historyRecord, error) {
+	if len(offsets) == 0 {
+		return nil, nil
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	records := make([]historyRecord, 0, len(offsets))
+	for _, offset := range offsets {
+		line, err := bufio.NewReader(io.NewSectionReader(file, offset, math.MaxInt64-offset)).ReadBytes('\n')
+		if err != nil && err != io.EOF {
+			return records, err
+		}
+		var rec historyRecord
+		if err := json.Unmarshal(line, &rec); err != nil {
+			return records, fmt.Errorf("history changed while reading it: %v", err)
+		}
+		records = append(records, rec)
+	}
+	return records, nil
+}
+
+// historyScreen pages through the history, newest first
+type historyScreen struct {
+	input     textinput.Model
+	filtering bool
+	expr      string
+	scan      historyScan
+	records   []historyRecord
+	cursor    int
+	top       int
+	height    int
+	loading   bool
+	// gen tells a stale scan's pages apart from the current one's
+	gen     int
+	message string
+}
+
+// historyScanMsg carries a filtered scan of the history and its first page
+type historyScanMsg struct {
+	gen     int
+	scan    historyScan
+	records []historyRecord
+	err     error
+}
+
+// historyPageMsg carries the next page of a scan
+type historyPageMsg struct {
+	gen     int
+	records []historyRecord
+	err     error
+}
+
+func scanHistoryCmd(gen int, f historyFilter) tea.Cmd {
+	return func() tea.Msg {
+		msg := historyScanMsg{gen: gen}
+		path, err := historyFilePath()
+		if err != nil {
+			msg.err = err
+			return msg
+		}
+		if msg.scan, msg.err = scanHistory(path, f); msg.err != nil {
+			return msg
+		}
+		msg.records, msg.err = readHistoryRecords(path, msg.scan.offsets[:min(historyPageSize, len(msg.scan.offsets))])
+		return msg
+	}
+}
+
+func loadHistoryPageCmd(gen int, offsets []int64) tea.Cmd {
+	return func() tea.Msg {
+		path, err := historyFilePath()
+		if err != nil {
+			return historyPageMsg{gen: gen, err: err}
+		}
+		records, err := readHistoryRecords(path, offsets)
+		return historyPageMsg{gen: gen, records: records, err: err}
+	}
+}
+
+func newHistoryInput() textinput.Model {
+	ti := textinput.New()
+	ti.Prompt = "Filter: "
+	ti.Placeholder = "env:prod action:complete quest:42 outcome:failed since:7d until:2024-05-31"
+	return ti
+}
+
+// openHistory shows the history screen with the filter last used
+func (m model) openHistory() (tea.Model, tea.Cmd) {
+	m.historyReturn = m.currentScreen
+	m.currentScreen = HistoryScreen
+	return m.applyHistoryFilter(m.history.expr)
+}
+
+// applyHistoryFilter rescans the history with a filter expression
+func (m model) applyHistoryFilter(expr string) (tea.Model, tea.Cmd) {
+	h := &m.history
+	f, err := parseHistoryFilter(expr, time.Now())
+	if err != nil {
+		h.message = err.Error()
+		return m, nil
+	}
+	h.expr, h.message = expr, ""
+	h.filtering = false
+	h.input.Blur()
+	h.input.SetValue(expr)
+	h.gen++
+	h.loading = true
+	h.records, h.cursor, h.top = nil, 0, 0
+	return m, tea.Batch(m.spinner.Tick, scanHistoryCmd(h.gen, f))
+}
+
+// nextHistoryPage loads the page after the loaded records once the cursor
+// reaches the last of them
+func (m *model) nextHistoryPage() tea.Cmd {
+	h := &m.history
+	loaded := len(h.records)
+	if h.loading || h.cursor < loaded-1 || loaded >= len(h.scan.offsets) {
+		return nil
+	}
+	h.loading = true
+	return loadHistoryPageCmd(h.gen, h.scan.offsets[loaded:min(loaded+historyPageSize, len(h.scan.offsets))])
+}
+
+// updateHistory handles the keys of the history screen
+func (m model) updateHistory(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	h := &m.history
+	if h.filtering {
+		switch msg.Type {
+		case tea.KeyEnter:
+			return m.applyHistoryFilter(strings.TrimSpace(h.input.Value()))
+		case tea.KeyEsc:
+			h.filtering = false
+			h.input.Blur()
+			h.input.SetValue(h.expr)
+			h.message = ""
+			return m, nil
+		}
+		var cmd tea.Cmd
+		h.input, cmd = h.input.Update(msg)
+		return m, cmd
+	}
+	switch keys.name(msg) {
+	case "b", "esc":
+		m.currentScreen = m.historyReturn
+		return m, nil
+	case "ctrl+c", "q":
+		return m, tea.Quit
+	case "/", "f":
+		h.filtering = true
+		return m, h.input.Focus()
+	case "r":
+		return m.retryRecord()
+	case "up", "k":
+		h.cursor--
+	case "down", "j":
+		h.cursor++
+	case "pgup":
+		h.cursor -= h.rows()
+	case "pgdown", " ":
+		h.cursor += h.rows()
+	case "home", "g":
+		h.cursor = 0
+	case "end", "G":
+		h.cursor = len(h.records) - 1
+	default:
+		return m, nil
+	}
+	h.cursor = max(0, min(h.cursor, len(h.records)-1))
+	h.scrollToCursor()
+	return m, m.nextHistoryPage()
+}
+
+// retryRecord retries the selected complete with the idempotency key it was
+// sent with, through the confirmation
+func (m model) retryRecord() (tea.Model, tea.Cmd) {
+	h := &m.history
+	if h.cursor >= len(h.records) {
+		return m, nil
+	}
+	rec := h.records[h.cursor]
+	switch {
+	case rec.Action != ActionComplete || rec.QuestID == nil || rec.Tool != "":
+		h.message = tr(msgHistoryRetryComplete)
+		return m, nil
+	case rec.IdempotencyKey == "":
+		h.message = tr(msgHistoryRetryNoKey)
+		return m, nil
+	case rec.Env != m.selectedEnv:
+		h.message = tr(msgHistoryRetryEnv, rec.Env)
+		return m, nil
+	}
+	payload := playtools.NewPayload(ActionComplete, *rec.QuestID)
+	payload.DryRun = rec.DryRun
+	payload.BatchSize = rec.BatchSize
+	payload.IdempotencyKey = rec.IdempotencyKey
+	m.selectedAction = string(ActionComplete)
+	m.openPrompt(string(ActionComplete))
+	m.promptForm.SetValue(0, payloadValue(payload))
+	h.message = ""
+	return m, m.retry(payload)
+}
+
+// rows is how many records fit on the screen
+func (h historyScreen) rows() int {
+	return max(1, h.height-historyChrome)
+}
+
+func (h *historyScreen) scrollToCursor() {
+	if h.cursor < h.top {
+		h.top = h.cursor
+	}
+	if h.cursor >= h.top+h.rows() {
+		h.top = h.cursor - h.rows() + 1
+	}
+}
+
+// historyRow renders a record as a line of the history screen
+func historyRow(rec historyRecord) string {
+	quest := "-"
+	if rec.QuestID != nil {
+		quest = strconv.Itoa(*rec.QuestID)
+	}
+	outcome := recordOutcome(rec)
+	line := fmt.Sprintf("%s  %-8s %-9s %-8s %-8s %8s  %s",
+		rec.Time.Local().Format("2006-01-02 15:04:05"), rec.Env, rec.Action, quest, outcome,
+		(time.Duration(rec.DurationMS) * time.Millisecond).Round(100*time.Millisecond), rec.Operator)
+	if rec.Error != "" {
+		line += "  " + rec.Error
+	}
+	return line
+}
+
+// historyView renders the history screen, the totals of the filtered set
+// staying on top while the records scroll
+func (m model) historyView() string {
+	h := m.history
+	var sb strings.Builder
+	sb.WriteString("\n  " + tr(msgHistoryTitle) + "\n\n")
+	sb.WriteString("  " + h.input.View() + "\n")
+	switch {
+	case h.message != "":
+		sb.WriteString("  " + errorStyle.Render(h.message) + "\n")
+	case h.loading && h.records == nil:
+		sb.WriteString(fmt.Sprintf("  %s %s\n", m.spinner.View(), tr(msgHistoryReading)))
+	default:
+		totals := h.scan.totals.String()
+		if h.scan.skipped > 0 {
+			totals += " " + tr(msgHistorySkipped, h.scan.skipped)
+		}
+		sb.WriteString("  " + dimStyle.Render(totals) + "\n")
+	}
+	sb.WriteString("\n")
+	if !h.loading && len(h.records) == 0 && h.message == "" {
+		sb.WriteString("  " + tr(msgHistoryEmpty) + "\n")
+	}
+	end := min(h.top+h.rows(), len(h.records))
+	for i := h.top; i < end; i++ {
+		row := historyRow(h.records[i])
+		if i == h.cursor {
+			sb.WriteString("> " + row + "\n")
+			continue
+		}
+		if recordOutcome(h.records[i]) == outcomeFailed {
+			row = errorStyle.Render(row)
+		}
+		sb.WriteString("  " + row + "\n")
+	}
+	footer := tr(msgHistoryHelp, len(h.records), len(h.scan.offsets), keys.help(keyBack))
+	if h.loading && h.records != nil {
+		footer = m.spinner.View() + " " + tr(msgHistoryLoadingMore) + " " + footer
+	}
+	sb.WriteString("\n  " + dimStyle.Render(footer))
+	return sb.String()
+}
+
+// historyChrome is the number of lines the history screen adds around its records
+const historyChrome = 8
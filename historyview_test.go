@@ -0,0 +1,283 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// historyBase is the time of the first generated record, each following
+// one ten minutes later
+var historyBase = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// generatedRecord is the i-th record of a generated history. Every thirteenth
+// is a start, which has no quest yet.
+func generatedRecord(i int) historyRecord {
+	rec := historyRecord{
+		Time:       historyBase.Add(time.Duration(i) * 10 * time.Minute),
+		Env:        []string{devEnv, nonProdEnv, prodEnv}[i%3],
+		Action:     []Action{ActionProcess, ActionComplete}[i%2],
+		DurationMS: int64(i % 5000),
+		Operator:   "ana",
+	}
+	switch {
+	case i%10 == 0:
+		rec.Error = "throttled"
+	case i%7 == 0:
+		rec.DryRun = true
+	case i%11 == 0:
+		rec.DryRun, rec.Shadow = true, true
+	}
+	if i%13 == 0 {
+		rec.Action = ActionStart
+	} else {
+		quest := i % 50
+		rec.QuestID = &quest
+	}
+	return rec
+}
+
+// writeGeneratedHistory writes n generated records to the history file, with
+// an unreadable line every 2500 of them
+func writeGeneratedHistory(t *testing.T, n int) string {
+	t.Helper()
+	path, err := historyFilePath()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	for i := range n {
+		line, err := json.Marshal(generatedRecord(i))
+		if err != nil {
+			t.Fatal(err)
+		}
+		buf.Write(append(line, '\n'))
+		if i%2500 == 1249 {
+			buf.WriteString(`{"time":"2024-`)
+			buf.WriteString("\n")
+		}
+	}
+	if err := os.WriteFile(path, buf.Bytes(), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+const generatedRecords = 10000
+
+func TestHistoryFilterGenerated(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	path := writeGeneratedHistory(t, generatedRecords)
+	now := historyBase.Add(70 * 24 * time.Hour)
+
+	tests := []struct {
+		expr string
+		want func(i int, rec historyRecord) bool
+	}{
+		{"", func(int, historyRecord) bool { return true }},
+		{"env:prod", func(i int, _ historyRecord) bool { return i%3 == 2 }},
+		{"env:prod,nonprod action:complete", func(i int, _ historyRecord) bool { return i%3 != 0 && i%2 == 1 && i%13 != 0 }},
+		{"quest:7", func(i int, _ historyRecord) bool { return i%13 != 0 && i%50 == 7 }},
+		{"7 13", func(i int, _ historyRecord) bool { return i%13 != 0 && (i%50 == 7 || i%50 == 13) }},
+		{"action:start", func(i int, _ historyRecord) bool { return i%13 == 0 }},
+		{"outcome:failed", func(i int, _ historyRecord) bool { return i%10 == 0 }},
+		{"outcome:dry-run,shadow", func(i int, _ historyRecord) bool { return i%10 != 0 && (i%7 == 0 || i%11 == 0) }},
+		{"outcome:ok", func(i int, _ historyRecord) bool { return i%10 != 0 && i%7 != 0 && i%11 != 0 }},
+		{"since:2024-02-01T00:00:00Z until:2024-02-08T00:00:00Z", func(_ int, rec historyRecord) bool {
+			return !rec.Time.Before(time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)) && rec.Time.Before(time.Date(2024, 2, 8, 0, 0, 0, 0, time.UTC))
+		}},
+		{"since:7d", func(_ int, rec historyRecord) bool { return !rec.Time.Before(now.AddDate(0, 0, -7)) }},
+		{"env:prod outcome:failed since:30d", func(i int, rec historyRecord) bool {
+			return i%3 == 2 && i%10 == 0 && !rec.Time.Before(now.AddDate(0, 0, -30))
+		}},
+		{"env:qa", func(int, historyRecord) bool { return false }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			f, err := parseHistoryFilter(tt.expr, now)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var want []int
+			var totals historyTotals
+			for i := range generatedRecords {
+				if rec := generatedRecord(i); tt.want(i, rec) {
+					want = append(want, i)
+					totals.add(rec)
+				}
+			}
+
+			scan, err := scanHistory(path, f)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if scan.skipped != generatedRecords/2500 {
+				t.Errorf("skipped %d lines, want %d", scan.skipped, generatedRecords/2500)
+			}
+			if len(scan.offsets) != len(want) || scan.totals != totals {
+				t.Fatalf("matched %d with %+v, want %d with %+v", len(scan.offsets), scan.totals, len(want), totals)
+			}
+			if len(want) == 0 {
+				return
+			}
+
+			// Newest first, the first and last pages read back the right records
+			for _, start := range []int{0, max(0, len(want)-historyPageSize)} {
+				offsets := scan.offsets[start:min(start+historyPageSize, len(want))]
+				records, err := readHistoryRecords(path, offsets)
+				if err != nil {
+					t.Fatal(err)
+				}
+				for j, rec := range records {
+					i := want[len(want)-1-start-j]
+					if !rec.Time.Equal(generatedRecord(i).Time) {
+						t.Fatalf("record %d is from %s, want record %d", start+j, rec.Time, i)
+					}
+				}
+			}
+		})
+	}
+}
+
+// The export command filters with the same engine, oldest first
+func TestHistoryExportGenerated(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	writeGeneratedHistory(t, generatedRecords)
+
+	var out bytes.Buffer
+	if code := runHistoryCommand([]string{"export", "--filter", "env:prod outcome:failed", "--since", "2024-02-01T00:00:00Z"}, &out); code != 0 {
+		t.Fatalf("exited %d", code)
+	}
+	var want []int
+	for i := range generatedRecords {
+		if rec := generatedRecord(i); i%30 == 20 && !rec.Time.Before(time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)) {
+			want = append(want, i)
+		}
+	}
+	sc := bufio.NewScanner(&out)
+	n := 0
+	for ; sc.Scan(); n++ {
+		var rec historyRecord
+		if err := json.Unmarshal(sc.Bytes(), &rec); err != nil {
+			t.Fatal(err)
+		}
+		if n >= len(want) || !rec.Time.Equal(generatedRecord(want[n]).Time) {
+			t.Fatalf("line %d is from %s", n, rec.Time)
+		}
+	}
+	if n != len(want) {
+		t.Errorf("exported %d records, want %d", n, len(want))
+	}
+}
+
+// Scrolling to the last loaded record loads the next page
+func TestHistoryScreenPages(t *testing.T) {
+	h := newHarness(t)
+	writeGeneratedHistory(t, generatedRecords)
+
+	next, _ := h.m.openHistory()
+	h.m = next.(model)
+	f, _ := parseHistoryFilter("env:prod", time.Now())
+	h.send(scanHistoryCmd(h.m.history.gen, f)())
+	if got := len(h.m.history.records); got != historyPageSize {
+		t.Fatalf("loaded %d records, want a page", got)
+	}
+	wantTotal := (generatedRecords + 1) / 3
+	if !strings.Contains(h.m.View(), fmt.Sprintf("%d of %d loaded", historyPageSize, wantTotal)) {
+		t.Errorf("view\n%s", h.m.View())
+	}
+
+	for loaded := historyPageSize; loaded < wantTotal; loaded += historyPageSize {
+		next, cmd := h.m.Update(tea.KeyMsg{Type: tea.KeyEnd})
+		h.m = next.(model)
+		if cmd == nil {
+			t.Fatalf("the end of %d records didn't load more", loaded)
+		}
+		h.send(cmd())
+		if got := len(h.m.history.records); got != min(loaded+historyPageSize, wantTotal) {
+			t.Fatalf("loaded %d records after %d", got, loaded)
+		}
+	}
+	if _, cmd := h.m.Update(tea.KeyMsg{Type: tea.KeyEnd}); cmd != nil {
+		t.Error("loading past the last record")
+	}
+	// Newest first, down to the oldest prod record
+	records := h.m.history.records
+	if !records[0].Time.Equal(generatedRecord(9998).Time) || !records[len(records)-1].Time.Equal(generatedRecord(2).Time) {
+		t.Errorf("records run from %s to %s", records[0].Time, records[len(records)-1].Time)
+	}
+}
+
+// The screens reading the whole history do it in a command, not in Update
+func TestHistoryReadsInBackground(t *testing.T) {
+	h := newHarness(t)
+	saved := prodDailyLimit
+	t.Cleanup(func() { prodDailyLimit = saved })
+	prodDailyLimit = 5
+
+	now := time.Now()
+	quest := 7
+	path, err := historyFilePath()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	for _, rec := range []historyRecord{
+		{Time: now.AddDate(0, 0, -2), Env: prodEnv, Action: ActionComplete, QuestID: &quest, RequestID: "req-old"},
+		{Time: now.Add(-time.Hour), Env: devEnv, Action: ActionComplete, QuestID: &quest, RequestID: "req-dev"},
+		{Time: now, Env: prodEnv, Action: ActionComplete, QuestID: &quest, RequestID: "req-today"},
+	} {
+		line, _ := json.Marshal(rec)
+		buf.Write(append(line, '\n'))
+	}
+	if err := os.WriteFile(path, buf.Bytes(), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	h.m.selectedEnv = prodEnv
+	cmd := h.m.showConfirm()
+	if cmd == nil || h.m.prodBudget.Used != 0 {
+		t.Fatalf("the confirmation counted %d in Update", h.m.prodBudget.Used)
+	}
+	h.send(cmd())
+	if want := (prodBudget{Limit: 5, Used: 1}); h.m.prodBudget != want {
+		t.Errorf("budget %+v, want %+v", h.m.prodBudget, want)
+	}
+
+	h.m.selectedEnv, h.m.currentScreen = devEnv, ActionScreen
+	next, cmd := h.m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'s'}})
+	h.m = next.(model)
+	h.wantScreen(StatsScreen)
+	if !strings.Contains(h.m.View(), tr(msgLoading)) {
+		t.Errorf("stats before the history is read\n%s", h.m.View())
+	}
+	h.send(cmd())
+	if view := h.m.View(); strings.Contains(view, tr(msgLoading)) || !strings.Contains(view, "Last 7 days") {
+		t.Errorf("stats\n%s", view)
+	}
+
+	next, cmd = h.m.openRollback()
+	h.m = next.(model)
+	if len(h.m.rollbackList.Items()) != 0 || !strings.Contains(h.m.View(), tr(msgLoading)) {
+		t.Errorf("rollback before the history is read\n%s", h.m.View())
+	}
+	h.send(cmd())
+	items := h.m.rollbackList.Items()
+	if len(items) != 1 || items[0].(rollbackItem).rec.RequestID != "req-dev" {
+		t.Errorf("rollback candidates %v", items)
+	}
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+)
+
+// httpSigningService is the SigV4 service name of API Gateway endpoints
+const httpSigningService = "execute-api"
+
+// httpRetryDelays are the waits before retrying a throttled or unavailable
+// endpoint, matching the SDK's three attempts for lambda invokes
+var httpRetryDelays = []time.Duration{time.Second, 2 * time.Second}
+
+// httpStatusError is an error status returned by an http transport endpoint
+type httpStatusError struct {
+	StatusCode int
+}
+
+func (e *httpStatusError) Error() string {
+	return fmt.Sprintf("endpoint responded with status code %d", e.StatusCode)
+}
+
+// replayable reports whether a payload is safe to send twice: it changes
+// nothing, or its idempotency key makes the backend ignore the second one
+func replayable(payload EventPayload) bool {
+	return !mutates(payload) || payload.IdempotencyKey != ""
+}
+
+// retryableHTTP reports whether an attempt may be sent again. Throttling,
+// an unavailable endpoint and a failed connect never reached the function
+// and are always retried. A gateway error or a connection lost after the
+// body was sent may have run it, so those are only retried when replayable.
+func retryableHTTP(resp *http.Response, err error, replayable bool) bool {
+	if err != nil {
+		if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+			return false
+		}
+		var opErr *net.OpError
+		if errors.As(err, &opErr) && opErr.Op == "dial" {
+			return true
+		}
+		return replayable
+	}
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests, http.StatusServiceUnavailable:
+		return true
+	case http.StatusInternalServerError, http.StatusBadGateway, http.StatusGatewayTimeout:
+		return replayable
+	}
+	return false
+}
+
+// invokeHTTP POSTs the payload to url, signed with the credentials of cfg,
+// retrying like the lambda client does as far as replayable allows
+func invokeHTTP(ctx context.Context, cfg aws.Config, url string, payload []byte, replayable bool) (invokeResponse, error) {
+	// The SDK's own client, so connect and TLS timeouts match lambda invokes
+	client := awshttp.NewBuildableClient()
+	signer := v4.NewSigner()
+	sum := sha256.Sum256(payload)
+	payloadHash := hex.EncodeToString(sum[:])
+
+	for attempt := 0; ; attempt++ {
+		creds, err := cfg.Credentials.Retrieve(ctx)
+		if err != nil {
+			return invokeResponse{}, fmt.Errorf("failed to retrieve credentials: %w", err)
+		}
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(payload))
+		if err != nil {
+			return invokeResponse{}, err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		if err := signer.SignHTTP(ctx, creds, req, payloadHash, httpSigningService, cfg.Region, time.Now()); err != nil {
+			return invokeResponse{}, fmt.Errorf("failed to sign request: %w", err)
+		}
+
+		resp, err := client.Do(req)
+		if retryableHTTP(resp, err, replayable) && attempt < len(httpRetryDelays) {
+			if err == nil {
+				resp.Body.Close()
+			}
+			select {
+			case <-time.After(httpRetryDelays[attempt]):
+				continue
+			case <-ctx.Done():
+				return invokeResponse{}, ctx.Err()
+			}
+		}
+		if err != nil {
+			return invokeResponse{}, err
+		}
+		defer resp.Body.Close()
+		recordServerDate(resp.Header.Get("Date"), time.Now())
+
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return invokeResponse{}, fmt.Errorf("failed to read response: %w", err)
+		}
+		requestID := resp.Header.Get("X-Amzn-Requestid")
+		if requestID == "" {
+			requestID = resp.Header.Get("X-Amz-Request-Id")
+		}
+		return invokeResponse{Payload: body, StatusCode: resp.StatusCode, RequestID: requestID}, nil
+	}
+}
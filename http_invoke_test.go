@@ -0,0 +1,216 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/revrost/playtools/pkg/playtools"
+)
+
+func testAWSConfig() aws.Config {
+	return aws.Config{
+		Region: "eu-west-1",
+		Credentials: aws.CredentialsProviderFunc(func(context.Context) (aws.Credentials, error) {
+			return aws.Credentials{AccessKeyID: "AKIDEXAMPLE", SecretAccessKey: "secret"}, nil
+		}),
+	}
+}
+
+// withoutRetryDelays makes the retries of the test immediate
+func withoutRetryDelays(t *testing.T) {
+	saved := httpRetryDelays
+	httpRetryDelays = []time.Duration{0, 0}
+	t.Cleanup(func() { httpRetryDelays = saved })
+}
+
+func TestInvokeHTTPSigned(t *testing.T) {
+	payload := `{"action":"process","quest_id":42}`
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth := r.Header.Get("Authorization")
+		if !strings.HasPrefix(auth, "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/") ||
+			!strings.Contains(auth, "/eu-west-1/execute-api/aws4_request") || r.Header.Get("X-Amz-Date") == "" {
+			t.Errorf("not signed for execute-api: %q", auth)
+		}
+		if r.Method != http.MethodPost || r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("%s with content type %q", r.Method, r.Header.Get("Content-Type"))
+		}
+		if body, _ := io.ReadAll(r.Body); string(body) != payload {
+			t.Errorf("sent %s, want %s", body, payload)
+		}
+		w.Header().Set("X-Amzn-Requestid", "req-http")
+		w.Write([]byte(`{"processed":3}`))
+	}))
+	defer srv.Close()
+
+	resp, err := invokeHTTP(context.Background(), testAWSConfig(), srv.URL, []byte(payload), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != 200 || string(resp.Payload) != `{"processed":3}` || resp.RequestID != "req-http" {
+		t.Errorf("unexpected response %+v", resp)
+	}
+}
+
+func TestInvokeHTTPRetries(t *testing.T) {
+	withoutRetryDelays(t)
+	tests := []struct {
+		name       string
+		statuses   []int
+		replayable bool
+		status     int
+		calls      int
+	}{
+		{"recovers", []int{503, 429, 200}, true, 200, 3},
+		{"gives up after three attempts", []int{502, 502, 502, 200}, true, 502, 3},
+		{"client errors aren't retried", []int{403, 200}, true, 403, 1},
+		{"not found isn't retried", []int{404, 200}, true, 404, 1},
+		// The function may have run behind a gateway error
+		{"not replayable recovers from throttling", []int{429, 503, 200}, false, 200, 3},
+		{"not replayable gateway timeout", []int{504, 200}, false, 504, 1},
+		{"not replayable bad gateway", []int{502, 200}, false, 502, 1},
+		{"not replayable server error", []int{500, 200}, false, 500, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls atomic.Int32
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				n := calls.Add(1)
+				// Each attempt is signed and sent with the whole payload
+				if body, _ := io.ReadAll(r.Body); string(body) != `{}` || r.Header.Get("Authorization") == "" {
+					t.Errorf("attempt %d sent %s unsigned", n, body)
+				}
+				w.Header().Set("X-Amz-Request-Id", "req-alt")
+				w.WriteHeader(tt.statuses[n-1])
+			}))
+			defer srv.Close()
+
+			resp, err := invokeHTTP(context.Background(), testAWSConfig(), srv.URL, []byte(`{}`), tt.replayable)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.StatusCode != tt.status || int(calls.Load()) != tt.calls || resp.RequestID != "req-alt" {
+				t.Errorf("status %d after %d calls, want %d after %d", resp.StatusCode, calls.Load(), tt.status, tt.calls)
+			}
+		})
+	}
+}
+
+// A complete the gateway timed out on may have distributed the rewards, so
+// it isn't sent again unless its idempotency key makes that safe
+func TestInvokeHTTPCompleteNotResent(t *testing.T) {
+	withoutRetryDelays(t)
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusGatewayTimeout)
+	}))
+	defer srv.Close()
+
+	complete := playtools.NewPayload(ActionComplete, 42)
+	keyed := complete
+	keyed.IdempotencyKey = "5f0c2a52-8a7e-4d0b-9b3e-6c1f0a4d2e91"
+	dryRun := playtools.NewPayload(ActionProcess, 42)
+	dryRun.DryRun = true
+	for _, tt := range []struct {
+		name    string
+		payload EventPayload
+		calls   int32
+	}{
+		{"complete", complete, 1},
+		{"start", playtools.NewPayload(ActionStart, 60), 1},
+		{"process", playtools.NewPayload(ActionProcess, 42), 1},
+		{"complete with a key", keyed, 3},
+		{"dry run", dryRun, 3},
+		{"status", playtools.NewPayload(ActionStatus, 42), 3},
+	} {
+		calls.Store(0)
+		resp, err := invokeHTTP(context.Background(), testAWSConfig(), srv.URL, []byte(`{}`), replayable(tt.payload))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != http.StatusGatewayTimeout || calls.Load() != tt.calls {
+			t.Errorf("%s: status %d after %d calls, want %d calls", tt.name, resp.StatusCode, calls.Load(), tt.calls)
+		}
+	}
+}
+
+func TestRetryableHTTPErrors(t *testing.T) {
+	dial := &net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED}
+	read := &url.Error{Op: "Post", URL: "https://admin.example.com", Err: &net.OpError{Op: "read", Net: "tcp", Err: syscall.ECONNRESET}}
+	for _, tt := range []struct {
+		name       string
+		err        error
+		replayable bool
+		want       bool
+	}{
+		{"connect refused", &url.Error{Op: "Post", Err: dial}, false, true},
+		{"connection reset after sending", read, false, false},
+		{"connection reset, replayable", read, true, true},
+		{"unexpected EOF", io.ErrUnexpectedEOF, false, false},
+		{"deadline", context.DeadlineExceeded, true, false},
+		{"cancelled", context.Canceled, true, false},
+	} {
+		if got := retryableHTTP(nil, tt.err, tt.replayable); got != tt.want {
+			t.Errorf("%s: retryable %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestInvokeHTTPTimeout(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err := invokeHTTP(ctx, testAWSConfig(), srv.URL, []byte(`{}`), true)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("got %v, want the deadline", err)
+	}
+	if class := classifyError(err, nil); class.Title != string(playtools.KindTimedOut) {
+		t.Errorf("classified as %q", class.Title)
+	}
+}
+
+func TestInvokeHTTPCredentialsError(t *testing.T) {
+	cfg := testAWSConfig()
+	cfg.Credentials = aws.CredentialsProviderFunc(func(context.Context) (aws.Credentials, error) {
+		return aws.Credentials{}, errors.New("token expired")
+	})
+	if _, err := invokeHTTP(context.Background(), cfg, "http://127.0.0.1:1", []byte(`{}`), true); err == nil || !strings.Contains(err.Error(), "token expired") {
+		t.Errorf("got %v", err)
+	}
+}
+
+func TestClassifyHTTPStatus(t *testing.T) {
+	for _, tt := range []struct {
+		code  int
+		title string
+		exit  int
+	}{
+		{401, "Access denied", exitAuth},
+		{403, "Access denied", exitAuth},
+		{404, "Endpoint not found", exitFailure},
+		{429, "Throttled", exitFailure},
+		{500, "Error response", exitFailure},
+	} {
+		class := classifyError(&httpStatusError{StatusCode: tt.code}, nil)
+		if class.Title != tt.title || class.exitCode() != tt.exit {
+			t.Errorf("%d: %q exiting %d, want %q exiting %d", tt.code, class.Title, class.exitCode(), tt.title, tt.exit)
+		}
+	}
+}
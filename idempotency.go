@@ -0,0 +1,88 @@
+package main
+
+import (
+	"crypto/rand"
+	"fmt"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// Completes carry an idempotency key so a retried complete can't distribute
+// the rewards twice. Every new complete gets a fresh key, and a retry from the
+// error screen, the output screen or the history reuses the key it was sent
+// with.
+
+// newIdempotencyKey is a random UUID for a new complete
+func newIdempotencyKey() (string, error) {
+	b := make([]byte, 16)
+	// Zero bytes would give every complete the same key
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("can't generate an idempotency key: %v", err)
+	}
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:]), nil
+}
+
+// assignIdempotencyKey gives a complete without a key a fresh one
+func assignIdempotencyKey(payload *EventPayload) error {
+	if payload.Action != ActionComplete || payload.IdempotencyKey != "" {
+		return nil
+	}
+	key, err := newIdempotencyKey()
+	if err != nil {
+		return err
+	}
+	payload.IdempotencyKey = key
+	return nil
+}
+
+// overrideIdempotencyKey replaces the key a retried complete was sent with by
+// a fresh one, for a deliberate re-distribution the backend must not
+// deduplicate
+func overrideIdempotencyKey(payload *EventPayload) error {
+	key, err := newIdempotencyKey()
+	if err != nil {
+		return err
+	}
+	payload.IdempotencyKey = key
+	payload.IdempotencyOverride = true
+	return nil
+}
+
+// idempotencyView shows the key of a complete on the confirmation screen
+func (m model) idempotencyView() string {
+	payload := m.pendingPayload
+	if payload.Action != ActionComplete || payload.IdempotencyKey == "" {
+		return ""
+	}
+	switch {
+	case payload.IdempotencyOverride:
+		return fmt.Sprintf("  %s %s\n\n", glyphs.Warn, tr(msgIdempotencyFresh, payload.IdempotencyKey, m.retriedKey))
+	case m.retrying():
+		return "  " + tr(msgIdempotencyRetried, payload.IdempotencyKey) + "\n\n"
+	}
+	return "  " + tr(msgIdempotencyKey, payload.IdempotencyKey) + "\n\n"
+}
+
+// retrying reports whether the confirmation shows a complete being retried
+// with the key it was sent with
+func (m model) retrying() bool {
+	return m.retriedKey != "" && m.pendingPayload.IdempotencyKey == m.retriedKey
+}
+
+// retry shows the confirmation again for a payload that was sent before,
+// keeping its idempotency key so the backend deduplicates it if the earlier
+// attempt got through
+func (m *model) retry(payload EventPayload) tea.Cmd {
+	payload.IdempotencyOverride = false
+	m.pendingPayload = payload
+	m.retriedKey = payload.IdempotencyKey
+	return tea.Batch(m.showConfirm(), m.checkQuestState())
+}
+
+// redistributeAckPhrase has to be typed to send a complete with an
+// overridden idempotency key
+func redistributeAckPhrase(payload EventPayload) string {
+	return "redistribute " + payloadValue(payload)
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// SessionConfig sets when an unattended session is locked
+type SessionConfig struct {
+	// IdleLock is how long without key input locks a session with prod
+	// selected, like "10m", or "off"
+	IdleLock string `yaml:"idle_lock"`
+	// IdleLockDev locks sessions with dev selected too
+	IdleLockDev bool `yaml:"idle_lock_dev"`
+}
+
+// defaultIdleLock is how long a prod session may sit without key input
+const defaultIdleLock = 10 * time.Minute
+
+// idleLockAfter is the configured idle lock, zero when turned off, and
+// idleLockDev applies it to dev as well
+var (
+	idleLockAfter = defaultIdleLock
+	idleLockDev   bool
+)
+
+// applySessionConfig sets the idle lock from the session section of the config
+func applySessionConfig(cfg SessionConfig) error {
+	idleLockDev = cfg.IdleLockDev
+	switch cfg.IdleLock {
+	case "":
+		idleLockAfter = defaultIdleLock
+	case "off":
+		idleLockAfter = 0
+	default:
+		d, err := time.ParseDuration(cfg.IdleLock)
+		if err != nil || d <= 0 {
+			return configErrorf([]string{"session", "idle_lock"}, "expected a positive duration like 10m or off, got %q", cfg.IdleLock)
+		}
+		idleLockAfter = d
+	}
+	return nil
+}
+
+// idleCheckInterval is how often the session checks for being left idle
+const idleCheckInterval = 15 * time.Second
+
+// idleCheckMsg is a periodic check for an idle session
+type idleCheckMsg time.Time
+
+func idleCheckCmd() tea.Cmd {
+	return tea.Tick(idleCheckInterval, func(t time.Time) tea.Msg { return idleCheckMsg(t) })
+}
+
+// idleLockApplies reports whether the selected environment locks when idle
+func (m model) idleLockApplies() bool {
+	return isProtected(m.selectedEnv) || (idleLockDev && m.selectedEnv == devEnv)
+}
+
+// checkIdle locks the session when it was left idle with a locking
+// environment selected. Invocations in flight keep running and the session
+// is checked again once they're done.
+func (m model) checkIdle(now time.Time) (tea.Model, tea.Cmd) {
+	idle := now.Sub(m.lastInput)
+	if idleLockAfter == 0 || !m.idleLockApplies() || idle < idleLockAfter ||
+		m.currentScreen == LoadingScreen || m.currentScreen == EnvironmentScreen {
+		return m, idleCheckCmd()
+	}
+	env := m.selectedEnv
+	recordSessionEvent(sessionEvent{Event: "idle_lock", Env: env, IdleMS: idle.Milliseconds()})
+	m.lockedEnv = env
+	m.lockNote = fmt.Sprintf("%s Locked after %s idle in %s, select it again to confirm your identity",
+		glyphs.Warn, formatTimeout(idleLockAfter), env)
+	m.acking = false
+	m.pendingPayload = EventPayload{}
+	m.selectedEnv = pinnedEnv
+	m.currentScreen = EnvironmentScreen
+	return m, idleCheckCmd()
+}
+
+// reconfirmIdentity fetches the identity of an environment selected again
+// after an idle lock, bypassing the cache. Its actions stay blocked until
+// the fetch succeeds.
+func (m model) reconfirmIdentity() tea.Cmd {
+	env := m.selectedEnv
+	if env != m.lockedEnv {
+		return m.refreshEnvInfo()
+	}
+	if m.envInfoRefreshing[env] {
+		return nil
+	}
+	m.envInfoRefreshing[env] = true
+	return fetchEnvInfoCmd(env)
+}
+
+// identityPending reports whether the selected environment was locked and
+// its identity isn't confirmed yet
+func (m model) identityPending() bool {
+	return m.lockedEnv != "" && m.selectedEnv == m.lockedEnv
+}
+
+// sessionEvent is a line of the session transcript
+type sessionEvent struct {
+	Time     time.Time `json:"time"`
+	Event    string    `json:"event"`
+	Operator string    `json:"operator"`
+	Env      string    `json:"env,omitempty"`
+	// Context is the context in use, the one switched to on a switch
+	Context string `json:"context"`
+	// IdleMS is how long the session sat idle before an idle lock
+	IdleMS int64 `json:"idle_ms,omitempty"`
+	// DurationMS is how long the session lasted, on its end event
+	DurationMS int64 `json:"duration_ms,omitempty"`
+}
+
+func sessionsFilePath() (string, error) {
+	dir, err := configDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "sessions.jsonl"), nil
+}
+
+// recordSessionEvent appends an event to the session transcript, shared by
+// every context. The transcript is best effort and never stops the tool.
+func recordSessionEvent(e sessionEvent) {
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	e.Operator = operatorName()
+	e.Context = contextLabel()
+	path, err := sessionsFilePath()
+	if err != nil {
+		return
+	}
+	data, err := json.Marshal(e)
+	if err != nil {
+		return
+	}
+	appendLine(path, data)
+}
+
+// recordSession notes the start of a terminal session in the transcript and
+// returns a func noting its end and duration
+func recordSession() func() {
+	start := time.Now()
+	recordSessionEvent(sessionEvent{Time: start, Event: "start"})
+	return func() {
+		recordSessionEvent(sessionEvent{Event: "end", DurationMS: time.Since(start).Milliseconds()})
+	}
+}
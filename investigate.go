@@ -0,0 +1,197 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"text/template"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/revrost/playtools/pkg/playtools"
+)
+
+// investigateAction is the action list entry that re-runs a process against
+// a historical snapshot of a quest, for dispute investigations
+const investigateAction = "investigate"
+
+// defaultSnapshotOverrides is the overrides template of a snapshot
+// investigation, executed with .QuestID and .SnapshotID
+const defaultSnapshotOverrides = `{"snapshot_id": {{json .SnapshotID}}}`
+
+// snapshotData is what the snapshot overrides template is executed with
+type snapshotData struct {
+	QuestID    int
+	SnapshotID string
+}
+
+// newSnapshotTemplate parses a snapshot overrides template
+func newSnapshotTemplate(text string) (*template.Template, error) {
+	return template.New("snapshot_overrides").Funcs(transformFuncs).Option("missingkey=error").Parse(text)
+}
+
+// buildSnapshotPayload builds the process payload of a snapshot
+// investigation. It is always a dry run.
+func buildSnapshotPayload(questID int, snapshotID string) (EventPayload, error) {
+	payload := playtools.NewPayload(ActionProcess, questID)
+	payload.DryRun = true
+	if snapshotID == "" {
+		return payload, errors.New(tr(msgSnapshotRequired))
+	}
+	var buf bytes.Buffer
+	if err := toolRegistry[sweepstakeTool].SnapshotOverrides.Execute(&buf, snapshotData{QuestID: questID, SnapshotID: snapshotID}); err != nil {
+		return payload, fmt.Errorf("snapshot overrides template failed: %v", err)
+	}
+	if !json.Valid(buf.Bytes()) {
+		return payload, fmt.Errorf("snapshot overrides template produced invalid JSON: %s", buf.String())
+	}
+	overrides := json.RawMessage(buf.Bytes())
+	payload.SweepstakeOverrides = &overrides
+	return payload, nil
+}
+
+// confirmInvestigation validates the quest and snapshot of the prompt and
+// shows the confirmation of the investigation
+func (m model) confirmInvestigation() (tea.Model, tea.Cmd) {
+	idStr := strings.TrimSpace(m.promptForm.Value(0))
+	id, err := parsePositive(idStr)
+	if err != nil {
+		m.promptMessage = err.Error()
+		return m, m.promptForm.Focus(0)
+	}
+	payload, err := buildSnapshotPayload(id, strings.TrimSpace(m.promptForm.Value(1)))
+	if err != nil {
+		m.promptMessage = err.Error()
+		return m, m.promptForm.Focus(1)
+	}
+	m.lastValues[investigateAction] = idStr
+	m.pendingPayload = payload
+	return m, m.showConfirm()
+}
+
+// investigationConfirmView is the confirmation screen of a snapshot
+// investigation, which has no dry run toggle
+func (m model) investigationConfirmView() string {
+	var sb strings.Builder
+	jsonPayload, _ := json.MarshalIndent(m.pendingPayload, "  ", "  ")
+	sb.WriteString("\n\n  " + tr(msgInvestigateTitle) + "\n\n")
+	sb.WriteString("  " + tr(msgConfirmEnv, m.selectedEnv, profileMap[m.selectedEnv]) + "\n")
+	sb.WriteString(fmt.Sprintf("  Function: %s\n", sweepstakeFunction(m.selectedEnv)))
+	sb.WriteString(fmt.Sprintf("  Payload:\n  %s\n\n", jsonPayload))
+	sb.WriteString(m.transformView())
+	sb.WriteString(m.restrictionView())
+	sb.WriteString(m.recentErrorsView())
+	sb.WriteString("  " + tr(msgInvestigateDryRun) + "\n")
+	sb.WriteString("  " + tr(msgInvestigateCompare) + "\n\n")
+	sb.WriteString("  " + tr(msgInvestigateHelp, keys.help(keyConfirm), keys.help(keyBack)) + "\n")
+	return sb.String()
+}
+
+// startInvestigation runs the confirmed snapshot investigation. The dry run
+// is forced again here so nothing on the way can have turned it off.
+func (m model) startInvestigation() (tea.Model, tea.Cmd) {
+	payload := m.pendingPayload
+	payload.DryRun = true
+	m.currentScreen = LoadingScreen
+	m.progress = nil
+	return m, tea.Batch(m.spinner.Tick, investigateSnapshotCmd(m.awaitResult(), m.selectedEnv, payload))
+}
+
+// investigateSnapshotCmd invokes the snapshot dry run and then the status of
+// the quest, adding a comparison of the two calculations to the output
+func investigateSnapshotCmd(gen int, env string, payload EventPayload) tea.Cmd {
+	return func() tea.Msg {
+		inv := &invocation{Env: env, Payload: payload, Mode: modeSync}
+		err := invokeLambda(context.Background(), inv, nil, nil)
+		finishInvocation(inv, err)
+		output := invocationLines(inv, err)
+		if err == nil && inv.FunctionError == "" {
+			output = append(output, "")
+			output = append(output, liveComparison(env, payload, inv.Body)...)
+		}
+		return lambdaResult{output: output, logs: inv.Logs, err: err, inv: inv, gen: gen}
+	}
+}
+
+// liveComparison fetches the live calculation of the quest with the status
+// action and compares the snapshot calculation with it
+func liveComparison(env string, payload EventPayload, snapshot []byte) []string {
+	live, err := fetchStatus(env, *payload.SweepstakeQuestID)
+	if err != nil {
+		return []string{fmt.Sprintf("%s Could not fetch the live calculation: %v", glyphs.Warn, err)}
+	}
+	return compareCalculations(snapshot, live.Body)
+}
+
+// compareCalculations lines up the top-level fields of the snapshot and live
+// calculations, marking the ones that differ
+func compareCalculations(snapshot, live []byte) []string {
+	var snap, cur map[string]json.RawMessage
+	if json.Unmarshal(snapshot, &snap) != nil || json.Unmarshal(live, &cur) != nil {
+		return []string{glyphs.Warn + " The calculations aren't JSON objects and can't be compared"}
+	}
+	keys, _ := objectKeys(snapshot)
+	liveKeys, _ := objectKeys(live)
+	for _, k := range liveKeys {
+		if _, ok := snap[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+
+	rows := [][3]string{{"Field", "Snapshot", "Live"}}
+	differ := make([]bool, 1, len(keys)+1)
+	n := 0
+	for _, k := range keys {
+		a, b := comparisonValue(snap[k]), comparisonValue(cur[k])
+		rows = append(rows, [3]string{k, a, b})
+		differ = append(differ, a != b)
+		if a != b {
+			n++
+		}
+	}
+	var widths [2]int
+	for _, row := range rows {
+		widths[0] = max(widths[0], len(row[0]))
+		widths[1] = max(widths[1], len(row[1]))
+	}
+
+	lines := []string{"Snapshot calculation (dry run) compared with the live one:"}
+	for i, row := range rows {
+		mark := "  "
+		if differ[i] {
+			mark = glyphs.Warn + " "
+		}
+		lines = append(lines, fmt.Sprintf("%s%-*s  %-*s  %s", mark, widths[0], row[0], widths[1], row[1], row[2]))
+	}
+	if n == 0 {
+		return append(lines, glyphs.Check+" The snapshot matches the live calculation")
+	}
+	return append(lines, fmt.Sprintf("%d field(s) differ", n))
+}
+
+// comparisonValue renders a field of a calculation on one line, "-" when it
+// is missing
+func comparisonValue(raw json.RawMessage) string {
+	if raw == nil {
+		return "-"
+	}
+	var v interface{}
+	if err := json.Unmarshal(raw, &v); err != nil {
+		return string(raw)
+	}
+	switch v := v.(type) {
+	case float64:
+		return formatDecimal(v, displayLocale)
+	case string:
+		return v
+	}
+	var buf bytes.Buffer
+	_ = json.Compact(&buf, raw)
+	s := buf.String()
+	if len(s) > 40 {
+		s = strings.TrimRight(s[:39], " ") + glyphs.Ellipsis
+	}
+	return s
+}
@@ -0,0 +1,371 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+)
+
+// checkVerdict is what an invocation check decides
+type checkVerdict int
+
+const (
+	checkPass checkVerdict = iota
+	// checkWarn needs the check's phrase typed to go ahead
+	checkWarn
+	// checkBlock refuses the invocation
+	checkBlock
+)
+
+// checkResult is the outcome of one check. A passing check may still have
+// a note, like a budget close to its limit.
+type checkResult struct {
+	Name    string
+	Verdict checkVerdict
+	Reason  string
+	// Ack is the phrase a warning needs typed
+	Ack string
+}
+
+// checkContext is what the checks of an invocation see. QuestState and
+// Promotion are what the confirmation fetched, nil when nothing was.
+type checkContext struct {
+	Env     string
+	Payload EventPayload
+	// Batch marks the confirmation of a batch, whose rows make up Mutations
+	Batch     bool
+	Mutations int
+	Budget    prodBudget
+	// IdentityPending marks an environment whose identity is confirmed
+	// again after an idle lock
+	IdentityPending bool
+	QuestState      *questState
+	Promotion       *promotionChecklist
+	Approval        approvalGrant
+}
+
+// invocationCheck is one step of a check chain
+type invocationCheck struct {
+	Name string
+	// Required checks can't be disabled in the config
+	Required bool
+	Run      func(c checkContext) checkResult
+}
+
+// confirmChecks run in order before an invocation is confirmed, on the
+// confirmation screen and on the command line
+var confirmChecks = []invocationCheck{
+	{Name: "version", Required: true, Run: checkVersion},
+	{Name: "identity", Required: true, Run: checkIdentity},
+	{Name: "redistribution", Run: checkRedistribution},
+	{Name: "lifecycle", Run: checkQuestLifecycle},
+	{Name: "promotion", Run: checkPromotion},
+	{Name: "budget", Run: checkBudget},
+}
+
+// invokeChecks run in order right before the lambda is invoked, whoever
+// invokes it. Only their blocks count, there's nobody to confirm a warning.
+var invokeChecks = []invocationCheck{
+	{Name: "version", Required: true, Run: checkVersion},
+	{Name: "approval", Required: true, Run: checkApproval},
+}
+
+// invokeHook runs after an invocation, failed or not
+type invokeHook struct {
+	Name     string
+	Required bool
+	Run      func(inv *invocation, err error)
+}
+
+// postInvokeHooks run in order after every invocation
+var postInvokeHooks = []invokeHook{
+	{Name: "history", Required: true, Run: recordHistoryHook},
+	{Name: "quest_cache", Run: invalidateQuestHook},
+}
+
+// disabledChecks lists the checks and hooks the config turns off per
+// environment, set with the checks section
+var disabledChecks map[string][]string
+
+// applyChecksConfig validates and applies the checks section of the config
+func applyChecksConfig(cfg map[string]ChecksConfig) error {
+	disabledChecks = map[string][]string{}
+	for env, c := range cfg {
+		if _, ok := profileMap[env]; !ok {
+			return configErrorf([]string{"checks", env}, "unknown environment")
+		}
+		for _, name := range c.Disabled {
+			required, known := checkRequired(name)
+			switch {
+			case !known:
+				return configErrorf([]string{"checks", env, "disabled"}, "unknown check %q", name)
+			case required:
+				return configErrorf([]string{"checks", env, "disabled"}, "%s can't be disabled", name)
+			}
+		}
+		disabledChecks[env] = c.Disabled
+	}
+	return nil
+}
+
+// checkRequired looks a check or hook up by name
+func checkRequired(name string) (required, known bool) {
+	for _, chain := range [][]invocationCheck{confirmChecks, invokeChecks} {
+		for _, c := range chain {
+			if c.Name == name {
+				return c.Required, true
+			}
+		}
+	}
+	for _, h := range postInvokeHooks {
+		if h.Name == name {
+			return h.Required, true
+		}
+	}
+	return false, false
+}
+
+func checkDisabled(env, name string) bool {
+	return slices.Contains(disabledChecks[env], name)
+}
+
+// newCheckContext is the context of a single payload
+func newCheckContext(env string, payload EventPayload) checkContext {
+	c := checkContext{Env: env, Payload: payload}
+	if mutates(payload) {
+		c.Mutations = 1
+	}
+	return c
+}
+
+// runChecks runs a chain, leaving out the passes without a note
+func runChecks(chain []invocationCheck, c checkContext) []checkResult {
+	var results []checkResult
+	for _, check := range chain {
+		if checkDisabled(c.Env, check.Name) {
+			continue
+		}
+		r := check.Run(c)
+		if r.Verdict == checkPass && r.Reason == "" {
+			continue
+		}
+		r.Name = check.Name
+		results = append(results, r)
+	}
+	return results
+}
+
+// blockReason is the reason of the first block among results, empty when
+// nothing blocks
+func blockReason(results []checkResult) string {
+	for _, r := range results {
+		if r.Verdict == checkBlock {
+			return r.Reason
+		}
+	}
+	return ""
+}
+
+// warning is the result of a check that warned, if it did
+func warning(results []checkResult, name string) (checkResult, bool) {
+	for _, r := range results {
+		if r.Name == name && r.Verdict == checkWarn {
+			return r, true
+		}
+	}
+	return checkResult{}, false
+}
+
+// finishInvocation runs the post-invoke hooks
+func finishInvocation(inv *invocation, err error) {
+	for _, h := range postInvokeHooks {
+		if !checkDisabled(inv.Env, h.Name) {
+			h.Run(inv, err)
+		}
+	}
+}
+
+func checkVersion(c checkContext) checkResult {
+	if reason := versionBlock(); reason != "" && c.Mutations > 0 {
+		return checkResult{Verdict: checkBlock, Reason: reason}
+	}
+	return checkResult{}
+}
+
+func checkIdentity(c checkContext) checkResult {
+	if c.IdentityPending {
+		return checkResult{Verdict: checkBlock, Reason: fmt.Sprintf("your identity in %s is being confirmed again after the idle lock", c.Env)}
+	}
+	return checkResult{}
+}
+
+func checkApproval(c checkContext) checkResult {
+	if approvalRequired(c.Env, c.Payload) && c.Approval.Status != approvalApproved && c.Approval.Status != approvalBreakGlass {
+		return checkResult{Verdict: checkBlock, Reason: fmt.Sprintf("%s in %s needs an approval first", c.Payload.Action, c.Env)}
+	}
+	return checkResult{}
+}
+
+func checkRedistribution(c checkContext) checkResult {
+	if c.Batch || !c.Payload.IdempotencyOverride {
+		return checkResult{}
+	}
+	return checkResult{
+		Verdict: checkWarn,
+		Reason:  fmt.Sprintf("the idempotency key is overridden with %s, so quest %s's rewards can be distributed again", c.Payload.IdempotencyKey, payloadValue(c.Payload)),
+		Ack:     redistributeAckPhrase(c.Payload),
+	}
+}
+
+// checkQuestLifecycle warns about an action the quest's state doesn't
+// allow. Dry runs change nothing and are never held back.
+func checkQuestLifecycle(c checkContext) checkResult {
+	l, st := toolRegistry[sweepstakeTool].Lifecycle, c.QuestState
+	if l == nil || st == nil || c.Batch || c.Payload.DryRun {
+		return checkResult{}
+	}
+	if reason := l.availability(*st, c.Payload.Action); reason != "" {
+		return checkResult{
+			Verdict: checkWarn,
+			Reason:  fmt.Sprintf("%s unavailable for quest %d: %s", c.Payload.Action, st.Quest, reason),
+			Ack:     lifecycleAckPhrase,
+		}
+	}
+	return checkResult{}
+}
+
+// checkPromotion warns about a prod start after a failed comparison in the
+// session
+func checkPromotion(c checkContext) checkResult {
+	p := c.Promotion
+	if p == nil || len(p.failed()) == 0 || c.Env != p.To || c.Payload.Action != ActionStart || c.Payload.DryRun {
+		return checkResult{}
+	}
+	return checkResult{
+		Verdict: checkWarn,
+		Reason:  fmt.Sprintf("%d promotion check(s) against %s failed", len(p.failed()), p.From),
+		Ack:     promotionAckPhrase,
+	}
+}
+
+func checkBudget(c checkContext) checkResult {
+	if !isProtected(c.Env) || c.Mutations == 0 {
+		return checkResult{}
+	}
+	switch {
+	case c.Budget.exceededBy(c.Mutations):
+		return checkResult{
+			Verdict: checkWarn,
+			Reason:  fmt.Sprintf("the daily prod budget is used up (%d of %d today)", c.Budget.Used, c.Budget.Limit),
+			Ack:     budgetAckPhrase,
+		}
+	case c.Budget.warn():
+		return checkResult{Reason: c.Budget.String()}
+	}
+	return checkResult{}
+}
+
+// recordHistoryHook appends the invocation to the history
+func recordHistoryHook(inv *invocation, err error) {
+	_ = appendHistory(newHistoryRecord(inv, err))
+}
+
+// invalidateQuestHook drops the cached status of a quest a mutating action
+// was sent to. Even a failed invocation may have moved the quest on.
+func invalidateQuestHook(inv *invocation, err error) {
+	if mutates(inv.Payload) && inv.Payload.SweepstakeQuestID != nil {
+		invalidateQuest(inv.Env, *inv.Payload.SweepstakeQuestID)
+	}
+}
+
+// confirmChecksCLI runs the confirmation checks on the command line. A
+// warning is confirmed by typing its phrase, or with the flag overrides
+// names for it. Without in nothing can be typed, so warnings refuse.
+func confirmChecksCLI(in *bufio.Reader, out io.Writer, c checkContext, overrides map[string]bool, flags map[string]string) bool {
+	for _, r := range runChecks(confirmChecks, c) {
+		switch {
+		case r.Verdict == checkBlock:
+			fmt.Fprintf(os.Stderr, "Error: %s\n", r.Reason)
+			return false
+		case r.Verdict == checkPass:
+			fmt.Fprintf(out, "Note: %s.\n", r.Reason)
+		case overrides[r.Name]:
+			fmt.Fprintf(out, "%s, overridden with %s.\n", capitalize(r.Reason), flags[r.Name])
+		case in == nil:
+			hint := fmt.Sprintf("run playtools %s to confirm it", c.Payload.Action)
+			if flag := flags[r.Name]; flag != "" {
+				hint = "pass " + flag + " to invoke anyway"
+			}
+			fmt.Fprintf(os.Stderr, "Error: %s, %s\n", r.Reason, hint)
+			return false
+		default:
+			fmt.Fprintf(out, "%s.\n", capitalize(r.Reason))
+			if flag := flags[r.Name]; flag != "" {
+				fmt.Fprintf(out, "Type %q to invoke anyway (or pass %s): ", r.Ack, flag)
+			} else {
+				fmt.Fprintf(out, "Type %q to invoke anyway: ", r.Ack)
+			}
+			line, _ := in.ReadString('\n')
+			if strings.TrimSpace(line) != r.Ack {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func capitalize(s string) string {
+	if s == "" {
+		return s
+	}
+	return strings.ToUpper(s[:1]) + s[1:]
+}
+
+// lifecycleFlags names the command line flag confirming a lifecycle warning
+var lifecycleFlags = map[string]string{"lifecycle": "--override-lifecycle"}
+
+// cliCheckContext is the check context of a command line invocation
+func cliCheckContext(out io.Writer, env string, payload EventPayload) checkContext {
+	c := newCheckContext(env, payload)
+	if isProtected(env) && c.Mutations > 0 {
+		c.Budget = loadProdBudget()
+	}
+	c.QuestState = cliQuestState(out, env, payload)
+	return c
+}
+
+// checkContext is the check context of the confirmation screen
+func (m model) checkContext() checkContext {
+	c := newCheckContext(m.selectedEnv, m.pendingPayload)
+	c.Batch = m.batchRows != nil
+	c.Mutations = m.pendingMutations()
+	c.Budget = m.prodBudget
+	c.IdentityPending = m.identityPending()
+	c.QuestState = m.pendingQuestState()
+	c.Promotion = m.promotion
+	return c
+}
+
+// checkResults runs the confirmation checks on the pending invocation
+func (m model) checkResults() []checkResult {
+	return runChecks(confirmChecks, m.checkContext())
+}
+
+// checksBlock reports whether a check refuses the pending invocation
+func (m model) checksBlock() bool {
+	return blockReason(m.checkResults()) != ""
+}
+
+// blockView explains on the confirmation screen why it won't invoke
+func (m model) blockView() string {
+	var sb strings.Builder
+	for _, r := range m.checkResults() {
+		if r.Verdict == checkBlock {
+			sb.WriteString(fmt.Sprintf("  %s %s\n\n", glyphs.Cross, r.Reason))
+		}
+	}
+	return sb.String()
+}
@@ -0,0 +1,334 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/revrost/playtools/pkg/playtools"
+)
+
+// withChecksState sets the build version, the sweepstake lifecycle and the
+// approval requirements for the test, and clears the disabled checks after it
+func withChecksState(t *testing.T, buildVer string, lifecycle *questLifecycle, require map[string][]Action) {
+	t.Helper()
+	spec := toolRegistry[sweepstakeTool]
+	savedVersion, savedMin := version, minVersion
+	savedLifecycle, savedRequire, savedDisabled := spec.Lifecycle, approvalRequire, disabledChecks
+	version, minVersion = buildVer, "v1.4.0"
+	spec.Lifecycle, approvalRequire, disabledChecks = lifecycle, require, nil
+	t.Cleanup(func() {
+		version, minVersion = savedVersion, savedMin
+		spec.Lifecycle, approvalRequire, disabledChecks = savedLifecycle, savedRequire, savedDisabled
+	})
+}
+
+func testLifecycle(t *testing.T) *questLifecycle {
+	t.Helper()
+	l, err := newQuestLifecycle(LifecycleConfig{Allowed: map[string][]string{
+		"open":      {"process"},
+		"closed":    {"complete"},
+		"completed": {},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return l
+}
+
+func resultNames(results []checkResult) []string {
+	var names []string
+	for _, r := range results {
+		names = append(names, r.Name)
+	}
+	return names
+}
+
+func TestChecks(t *testing.T) {
+	withChecksState(t, "v1.4.0", testLifecycle(t), map[string][]Action{prodEnv: {ActionComplete}})
+
+	overridden := playtools.NewPayload(ActionComplete, 42)
+	overridden.IdempotencyKey, overridden.IdempotencyOverride = "retry-2", true
+	dryRun := playtools.NewPayload(ActionComplete, 42)
+	dryRun.DryRun = true
+	completed := &questState{Env: prodEnv, Quest: 42, State: "completed", Since: "2024-05-31"}
+	failed := &promotionChecklist{From: devEnv, To: prodEnv, Quest: 42, Items: []checkItem{
+		{Path: "prizes", Pass: false},
+		{Path: "updated_at", Expected: true},
+	}}
+
+	tests := []struct {
+		name    string
+		check   func(checkContext) checkResult
+		c       checkContext
+		verdict checkVerdict
+		reason  string
+		ack     string
+	}{
+		{"identity confirmed", checkIdentity, checkContext{Env: prodEnv}, checkPass, "", ""},
+		{"identity pending", checkIdentity, checkContext{Env: prodEnv, IdentityPending: true},
+			checkBlock, "your identity in prod is being confirmed again after the idle lock", ""},
+
+		{"approval not required", checkApproval, newCheckContext(devEnv, playtools.NewPayload(ActionComplete, 42)), checkPass, "", ""},
+		{"approval dry run", checkApproval, newCheckContext(prodEnv, dryRun), checkPass, "", ""},
+		{"approval missing", checkApproval, newCheckContext(prodEnv, playtools.NewPayload(ActionComplete, 42)),
+			checkBlock, "complete in prod needs an approval first", ""},
+		{"approval pending", checkApproval,
+			checkContext{Env: prodEnv, Payload: playtools.NewPayload(ActionComplete, 42), Approval: approvalGrant{Status: "pending"}},
+			checkBlock, "complete in prod needs an approval first", ""},
+		{"approved", checkApproval,
+			checkContext{Env: prodEnv, Payload: playtools.NewPayload(ActionComplete, 42), Approval: approvalGrant{Status: approvalApproved}},
+			checkPass, "", ""},
+		{"break glass", checkApproval,
+			checkContext{Env: prodEnv, Payload: playtools.NewPayload(ActionComplete, 42), Approval: approvalGrant{Status: approvalBreakGlass}},
+			checkPass, "", ""},
+
+		{"key kept", checkRedistribution, newCheckContext(prodEnv, playtools.NewPayload(ActionComplete, 42)), checkPass, "", ""},
+		{"key overridden", checkRedistribution, newCheckContext(prodEnv, overridden),
+			checkWarn, "the idempotency key is overridden with retry-2, so quest 42's rewards can be distributed again", "redistribute 42"},
+		{"key overridden in a batch", checkRedistribution, checkContext{Env: prodEnv, Payload: overridden, Batch: true}, checkPass, "", ""},
+
+		{"state unknown", checkQuestLifecycle, newCheckContext(prodEnv, playtools.NewPayload(ActionComplete, 42)), checkPass, "", ""},
+		{"state allows it", checkQuestLifecycle,
+			checkContext{Env: prodEnv, Payload: playtools.NewPayload(ActionComplete, 42), QuestState: &questState{Quest: 42, State: "closed"}},
+			checkPass, "", ""},
+		{"state doesn't allow it", checkQuestLifecycle,
+			checkContext{Env: prodEnv, Payload: playtools.NewPayload(ActionComplete, 42), QuestState: completed},
+			checkWarn, "complete unavailable for quest 42: already completed on 2024-05-31, complete needs closed", lifecycleAckPhrase},
+		{"state doesn't allow a dry run", checkQuestLifecycle,
+			checkContext{Env: prodEnv, Payload: dryRun, QuestState: completed}, checkPass, "", ""},
+		{"state in a batch", checkQuestLifecycle,
+			checkContext{Env: prodEnv, Payload: playtools.NewPayload(ActionComplete, 42), QuestState: completed, Batch: true},
+			checkPass, "", ""},
+
+		{"no comparison", checkPromotion, newCheckContext(prodEnv, playtools.NewPayload(ActionStart, 60)), checkPass, "", ""},
+		{"comparison failed", checkPromotion,
+			checkContext{Env: prodEnv, Payload: playtools.NewPayload(ActionStart, 60), Promotion: failed},
+			checkWarn, "1 promotion check(s) against dev failed", promotionAckPhrase},
+		{"comparison failed, other env", checkPromotion,
+			checkContext{Env: nonProdEnv, Payload: playtools.NewPayload(ActionStart, 60), Promotion: failed}, checkPass, "", ""},
+		{"comparison failed, not a start", checkPromotion,
+			checkContext{Env: prodEnv, Payload: playtools.NewPayload(ActionComplete, 42), Promotion: failed}, checkPass, "", ""},
+		{"comparison passed", checkPromotion,
+			checkContext{Env: prodEnv, Payload: playtools.NewPayload(ActionStart, 60), Promotion: &promotionChecklist{From: devEnv, To: prodEnv}},
+			checkPass, "", ""},
+
+		{"budget unprotected", checkBudget, checkContext{Env: devEnv, Mutations: 1, Budget: prodBudget{Limit: 10, Used: 10}}, checkPass, "", ""},
+		{"budget read-only", checkBudget, checkContext{Env: prodEnv, Budget: prodBudget{Limit: 10, Used: 10}}, checkPass, "", ""},
+		{"budget disabled", checkBudget, checkContext{Env: prodEnv, Mutations: 1}, checkPass, "", ""},
+		{"budget plenty", checkBudget, checkContext{Env: prodEnv, Mutations: 1, Budget: prodBudget{Limit: 10, Used: 7}}, checkPass, "", ""},
+		{"budget close", checkBudget, checkContext{Env: prodEnv, Mutations: 1, Budget: prodBudget{Limit: 10, Used: 8}},
+			checkPass, "prod budget: 8/10 used today", ""},
+		{"budget used up", checkBudget, checkContext{Env: prodEnv, Mutations: 1, Budget: prodBudget{Limit: 10, Used: 10}},
+			checkWarn, "the daily prod budget is used up (10 of 10 today)", budgetAckPhrase},
+		{"budget used up by a batch", checkBudget, checkContext{Env: prodEnv, Mutations: 3, Budget: prodBudget{Limit: 10, Used: 8}},
+			checkWarn, "the daily prod budget is used up (8 of 10 today)", budgetAckPhrase},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := tt.check(tt.c)
+			if r.Verdict != tt.verdict || r.Reason != tt.reason || r.Ack != tt.ack {
+				t.Errorf("got %+v, want verdict %d, %q, ack %q", r, tt.verdict, tt.reason, tt.ack)
+			}
+		})
+	}
+}
+
+func TestCheckVersion(t *testing.T) {
+	withChecksState(t, "v1.3.0", nil, nil)
+	if r := checkVersion(newCheckContext(prodEnv, playtools.NewPayload(ActionComplete, 42))); r.Verdict != checkBlock || !strings.Contains(r.Reason, "min_version v1.4.0") {
+		t.Errorf("an outdated build completes: %+v", r)
+	}
+	for _, payload := range []EventPayload{playtools.NewPayload(ActionStatus, 42), {Action: ActionComplete, DryRun: true}} {
+		if r := checkVersion(newCheckContext(prodEnv, payload)); r.Verdict != checkPass {
+			t.Errorf("an outdated build can't send %+v: %+v", payload, r)
+		}
+	}
+	version = "v1.4.0"
+	if r := checkVersion(newCheckContext(prodEnv, playtools.NewPayload(ActionComplete, 42))); r.Verdict != checkPass {
+		t.Errorf("an up to date build is blocked: %+v", r)
+	}
+}
+
+func TestRunChecks(t *testing.T) {
+	withChecksState(t, "v1.3.0", testLifecycle(t), nil)
+	payload := playtools.NewPayload(ActionComplete, 42)
+	payload.IdempotencyKey, payload.IdempotencyOverride = "retry-2", true
+	c := newCheckContext(prodEnv, payload)
+	c.IdentityPending = true
+	c.QuestState = &questState{Quest: 42, State: "completed"}
+	c.Budget = prodBudget{Limit: 10, Used: 8}
+
+	// Passes without a note are left out, the rest keep the chain's order
+	results := runChecks(confirmChecks, c)
+	if got, want := resultNames(results), []string{"version", "identity", "redistribution", "lifecycle", "budget"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("results %v, want %v", got, want)
+	}
+	if reason := blockReason(results); !strings.Contains(reason, "min_version") {
+		t.Errorf("the first block is %q, want the version's", reason)
+	}
+	if r, ok := warning(results, "lifecycle"); !ok || r.Ack != lifecycleAckPhrase {
+		t.Errorf("lifecycle warning %+v, %v", r, ok)
+	}
+	if _, ok := warning(results, "budget"); ok {
+		t.Error("a budget note counts as a warning")
+	}
+	if _, ok := warning(results, "identity"); ok {
+		t.Error("a block counts as a warning")
+	}
+
+	version, c.IdentityPending = "v1.4.0", false
+	results = runChecks(confirmChecks, c)
+	if reason := blockReason(results); reason != "" {
+		t.Errorf("blocked by %q", reason)
+	}
+
+	if err := applyChecksConfig(map[string]ChecksConfig{prodEnv: {Disabled: []string{"lifecycle", "budget"}}}); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := resultNames(runChecks(confirmChecks, c)), []string{"redistribution"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("with lifecycle and budget disabled in prod, results %v, want %v", got, want)
+	}
+	c.Env = devEnv
+	if got, want := resultNames(runChecks(confirmChecks, c)), []string{"redistribution", "lifecycle"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("dev results %v, want %v", got, want)
+	}
+	if results := runChecks(nil, c); results != nil {
+		t.Errorf("an empty chain gave %v", results)
+	}
+}
+
+func TestApplyChecksConfig(t *testing.T) {
+	withChecksState(t, "v1.4.0", nil, nil)
+	if err := applyChecksConfig(map[string]ChecksConfig{
+		devEnv:  {Disabled: []string{"budget", "promotion", "quest_cache"}},
+		prodEnv: {},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if !checkDisabled(devEnv, "quest_cache") || checkDisabled(prodEnv, "budget") || checkDisabled(nonProdEnv, "budget") {
+		t.Errorf("disabled %v", disabledChecks)
+	}
+
+	for _, tt := range []struct {
+		cfg map[string]ChecksConfig
+		err string
+	}{
+		{map[string]ChecksConfig{"staging": {}}, "checks.staging: unknown environment"},
+		{map[string]ChecksConfig{devEnv: {Disabled: []string{"budgets"}}}, `checks.dev.disabled: unknown check "budgets"`},
+		{map[string]ChecksConfig{prodEnv: {Disabled: []string{"version"}}}, "checks.prod.disabled: version can't be disabled"},
+		{map[string]ChecksConfig{prodEnv: {Disabled: []string{"identity"}}}, "checks.prod.disabled: identity can't be disabled"},
+		{map[string]ChecksConfig{prodEnv: {Disabled: []string{"approval"}}}, "checks.prod.disabled: approval can't be disabled"},
+		{map[string]ChecksConfig{prodEnv: {Disabled: []string{"history"}}}, "checks.prod.disabled: history can't be disabled"},
+	} {
+		if err := applyChecksConfig(tt.cfg); err == nil || err.Error() != tt.err {
+			t.Errorf("%v: err %v, want %s", tt.cfg, err, tt.err)
+		}
+	}
+}
+
+func TestFinishInvocation(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	withChecksState(t, "v1.4.0", nil, nil)
+	status := cachedQuest{Status: []byte(`{"status":"open"}`), FetchedAt: time.Now()}
+	saveQuestStatus(devEnv, 42, status)
+	saveQuestStatus(prodEnv, 42, status)
+
+	// A status check leaves the cache alone, a failed complete still
+	// invalidates it
+	finishInvocation(&invocation{Env: devEnv, Payload: playtools.NewPayload(ActionStatus, 42), RequestID: "status"}, nil)
+	if _, ok := cachedQuestStatus(devEnv, 42); !ok {
+		t.Error("a status check invalidated the cache")
+	}
+	finishInvocation(&invocation{Env: devEnv, Payload: playtools.NewPayload(ActionComplete, 42), RequestID: "complete"}, &playtools.StatusError{StatusCode: 500})
+	if _, ok := cachedQuestStatus(devEnv, 42); ok {
+		t.Error("a complete left the quest cached")
+	}
+
+	if err := applyChecksConfig(map[string]ChecksConfig{prodEnv: {Disabled: []string{"quest_cache"}}}); err != nil {
+		t.Fatal(err)
+	}
+	finishInvocation(&invocation{Env: prodEnv, Payload: playtools.NewPayload(ActionComplete, 42), RequestID: "prod"}, nil)
+	if _, ok := cachedQuestStatus(prodEnv, 42); !ok {
+		t.Error("the disabled quest_cache hook ran")
+	}
+
+	records, _, err := loadHistory()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []string
+	for _, rec := range records {
+		ids = append(ids, rec.RequestID)
+	}
+	if want := []string{"status", "complete", "prod"}; !reflect.DeepEqual(ids, want) {
+		t.Errorf("history %v, want %v", ids, want)
+	}
+	if records[1].Error == "" {
+		t.Error("the failed complete was recorded without its error")
+	}
+}
+
+func TestConfirmChecksCLI(t *testing.T) {
+	withChecksState(t, "v1.4.0", testLifecycle(t), nil)
+	payload := playtools.NewPayload(ActionComplete, 42)
+	payload.IdempotencyKey, payload.IdempotencyOverride = "retry-2", true
+	c := newCheckContext(prodEnv, payload)
+	c.QuestState = &questState{Quest: 42, State: "completed"}
+	c.Budget = prodBudget{Limit: 10, Used: 8}
+
+	tests := []struct {
+		name      string
+		input     *string
+		overrides map[string]bool
+		ok        bool
+		out       []string
+	}{
+		{"both typed", ptr("redistribute 42\noverride lifecycle\n"), nil, true, []string{
+			"Type \"redistribute 42\" to invoke anyway: ",
+			"Type \"override lifecycle\" to invoke anyway (or pass --override-lifecycle): ",
+			"Note: prod budget: 8/10 used today.",
+		}},
+		{"second mistyped", ptr("redistribute 42\noverride\n"), nil, false, []string{"Type \"override lifecycle\""}},
+		{"first mistyped", ptr("redistribute\n"), nil, false, []string{"Type \"redistribute 42\""}},
+		{"input ends", ptr("redistribute 42\n"), nil, false, []string{"Type \"override lifecycle\""}},
+		{"overridden", ptr("redistribute 42\n"), map[string]bool{"lifecycle": true}, true, []string{
+			"Complete unavailable for quest 42: already completed, complete needs closed, overridden with --override-lifecycle.",
+		}},
+		{"nothing to type", nil, nil, false, nil},
+		{"nothing to type, all overridden", nil, map[string]bool{"redistribution": true, "lifecycle": true}, true, []string{
+			"overridden with --override-lifecycle.",
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var in *bufio.Reader
+			if tt.input != nil {
+				in = bufio.NewReader(strings.NewReader(*tt.input))
+			}
+			var out bytes.Buffer
+			if ok := confirmChecksCLI(in, &out, c, tt.overrides, lifecycleFlags); ok != tt.ok {
+				t.Errorf("confirmed %v, want %v\n%s", ok, tt.ok, out.String())
+			}
+			for _, want := range tt.out {
+				if !strings.Contains(out.String(), want) {
+					t.Errorf("output has no %q\n%s", want, out.String())
+				}
+			}
+		})
+	}
+
+	// A block refuses before any warning is asked about
+	c.IdentityPending = true
+	var out bytes.Buffer
+	if confirmChecksCLI(bufio.NewReader(strings.NewReader("redistribute 42\noverride lifecycle\n")), &out, c, nil, lifecycleFlags) || out.Len() != 0 {
+		t.Errorf("confirmed a blocked invocation\n%s", out.String())
+	}
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
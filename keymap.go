@@ -0,0 +1,123 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/list"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// The logical keys the keymap section of the config can rebind
+const (
+	keyQuit    = "quit"
+	keyBack    = "back"
+	keyConfirm = "confirm"
+	keyCopy    = "copy"
+	keyLogs    = "logs-toggle"
+)
+
+// keyDefaults are the logical keys in order, with the key each screen
+// matches it by
+var keyDefaults = []struct{ name, key, help string }{
+	{keyQuit, "q", "quit"},
+	{keyBack, "b", "back"},
+	{keyConfirm, "enter", "confirm"},
+	{keyCopy, "c", "copy"},
+	{keyLogs, "v", "logs"},
+}
+
+// keyMap holds the active binding of each logical key
+type keyMap map[string]key.Binding
+
+var keys = defaultKeyMap()
+
+func defaultKeyMap() keyMap {
+	km := keyMap{}
+	for _, d := range keyDefaults {
+		km[d.name] = key.NewBinding(key.WithKeys(d.key), key.WithHelp(d.key, d.help))
+	}
+	return km
+}
+
+// reservedKeys are global bindings a logical key can't take over
+var reservedKeys = map[string]string{
+	"ctrl+e": "switching environments",
+	"ctrl+o": "switching contexts",
+}
+
+// setupKeymap binds the logical keys of the config, keeping the defaults of
+// the ones it leaves out. A key bound to two logical keys is rejected.
+func setupKeymap(cfg map[string][]string) error {
+	km := defaultKeyMap()
+	for name, bound := range cfg {
+		d, ok := km[name]
+		if !ok {
+			return configErrorf([]string{"keymap", name}, "unknown key, expected one of %s", strings.Join(keyNames(), ", "))
+		}
+		if len(bound) == 0 {
+			return configErrorf([]string{"keymap", name}, "expected at least one key")
+		}
+		for _, k := range bound {
+			if what, ok := reservedKeys[k]; ok {
+				return configErrorf([]string{"keymap", name}, "%q is for %s", k, what)
+			}
+			if k == "ctrl+c" && name != keyQuit {
+				return configErrorf([]string{"keymap", name}, "%q always quits", k)
+			}
+		}
+		km[name] = key.NewBinding(key.WithKeys(bound...), key.WithHelp(bound[0], d.Help().Desc))
+	}
+	boundTo := map[string]string{}
+	for _, n := range keyNames() {
+		for _, k := range km[n].Keys() {
+			if other, ok := boundTo[k]; ok {
+				return configErrorf([]string{"keymap"}, "%q is bound to both %s and %s", k, other, n)
+			}
+			boundTo[k] = n
+		}
+	}
+	keys = km
+	return nil
+}
+
+func keyNames() []string {
+	names := make([]string, len(keyDefaults))
+	for i, d := range keyDefaults {
+		names[i] = d.name
+	}
+	return names
+}
+
+// name is the key a screen matches msg by: the default key of the logical
+// key it's bound to, nothing for a default key that was rebound away, and
+// otherwise the key itself
+func (km keyMap) name(msg tea.KeyMsg) string {
+	for _, d := range keyDefaults {
+		if key.Matches(msg, km[d.name]) {
+			return d.key
+		}
+	}
+	pressed := msg.String()
+	for _, d := range keyDefaults {
+		if pressed == d.key {
+			return ""
+		}
+	}
+	return pressed
+}
+
+// help is how the help texts name the key of a logical key, like 'b' or Enter
+func (km keyMap) help(name string) string {
+	k := km[name].Help().Key
+	if len(k) == 1 {
+		return "'" + k + "'"
+	}
+	return strings.ToUpper(k[:1]) + k[1:]
+}
+
+// applyListKeys makes a list quit with the quit key, and Esc as before
+func applyListKeys(l *list.Model) {
+	quit := keys[keyQuit]
+	l.KeyMap.Quit = key.NewBinding(key.WithKeys(append(quit.Keys(), "esc")...), key.WithHelp(quit.Help().Key, "quit"))
+}
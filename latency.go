@@ -0,0 +1,52 @@
+package main
+
+import (
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// highLatency is set by --high-latency. The spinner then animates slower and
+// the screen is repainted less often, as every repaint crosses the link.
+// Nothing guesses it: an SSH client's address says little about the link's
+// round trip, and measuring one means sending something over it.
+var highLatency bool
+
+// Repaint rates in high latency mode
+const (
+	highLatencyFPS        = 10
+	highLatencySpinnerFPS = time.Second / 2
+)
+
+// wrapKey identifies a wrapped render
+type wrapKey struct {
+	width int
+	text  string
+}
+
+// maxWrapCache bounds the wrap cache, which is dropped whole when full
+const maxWrapCache = 512
+
+var (
+	wrapCacheMu sync.Mutex
+	wrapCache   = map[wrapKey]string{}
+)
+
+// wrapText wraps text to width with lipgloss, caching the result. The output
+// and logs are wrapped on every render while they rarely change, and long
+// logs are slow to wrap.
+func wrapText(text string, width int) string {
+	key := wrapKey{width, text}
+	wrapCacheMu.Lock()
+	defer wrapCacheMu.Unlock()
+	if s, ok := wrapCache[key]; ok {
+		return s
+	}
+	if len(wrapCache) >= maxWrapCache {
+		wrapCache = map[wrapKey]string{}
+	}
+	s := lipgloss.NewStyle().Width(width).Render(text)
+	wrapCache[key] = s
+	return s
+}
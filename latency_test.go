@@ -0,0 +1,75 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/revrost/playtools/pkg/playtools"
+)
+
+// BenchmarkRender renders the busiest screens on every spinner tick, in the
+// normal mode and with --high-latency. Next to how many renders a second a
+// screen manages, it reports how many repaints a second the mode sends over
+// the link, the spinner's ticks capped by the program's frame rate.
+func BenchmarkRender(b *testing.B) {
+	b.Setenv("XDG_CONFIG_HOME", b.TempDir())
+	if err := setupConfig(); err != nil {
+		b.Fatal(err)
+	}
+	var logs strings.Builder
+	for i := 0; i < 2000; i++ {
+		fmt.Fprintf(&logs, "2024-05-01T10:00:%02dZ INFO processed entry %d of quest 42\n", i%60, i)
+	}
+	screens := map[string]func(m *model){
+		"loading": func(m *model) {
+			m.currentScreen = LoadingScreen
+			m.awaitingResult = true
+			m.progress = &progressMsg{done: 3, total: 10, unit: "quests"}
+		},
+		"output": func(m *model) {
+			inv := &invocation{Env: devEnv, Payload: playtools.NewPayload(ActionProcess, 42), RequestID: "req-1", Body: []byte(`{"processed":2000}`)}
+			m.currentScreen = OutputScreen
+			m.lastInvocation = inv
+			m.lambdaOutput = []string{"Request ID: req-1", `{"processed":2000}`}
+			m.lambdaLogs = logs.String()
+		},
+	}
+	modes := []struct {
+		name string
+		high bool
+		fps  int
+	}{
+		// bubbletea's default frame rate
+		{"normal", false, 60},
+		{"high-latency", true, highLatencyFPS},
+	}
+	saved := highLatency
+	b.Cleanup(func() { highLatency = saved })
+	for _, mode := range modes {
+		for name, setup := range screens {
+			b.Run(mode.name+"/"+name, func(b *testing.B) {
+				highLatency = mode.high
+				m := initialModel()
+				m.selectedEnv = devEnv
+				next, _ := m.Update(tea.WindowSizeMsg{Width: 120, Height: 40})
+				m = next.(model)
+				setup(&m)
+				repaints := 0.0
+				if m.spinning() {
+					repaints = min(float64(time.Second/m.spinner.Spinner.FPS), float64(mode.fps))
+				}
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					next, _ = m.Update(m.spinner.Tick())
+					m = next.(model)
+					m.View()
+				}
+				b.ReportMetric(float64(b.N)/b.Elapsed().Seconds(), "renders/s")
+				b.ReportMetric(repaints, "repaints/s")
+			})
+		}
+	}
+}
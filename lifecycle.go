@@ -0,0 +1,243 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// lifecycleAckPhrase has to be typed to invoke an action the quest's state
+// doesn't allow
+const lifecycleAckPhrase = "override lifecycle"
+
+// questLifecycle maps the quest states of the status response to the
+// actions allowed in them. Quest lifecycles differ per game, so it comes
+// from the config and nothing is checked without it.
+type questLifecycle struct {
+	// StateField and SinceField are status response paths, dotted like the
+	// promotion ones
+	StateField string
+	SinceField string
+	// Allowed lists the actions of each state. States not listed allow
+	// every action.
+	Allowed map[string][]Action
+}
+
+// questState is a quest's state as its status call reported it
+type questState struct {
+	Env   string
+	Quest int
+	State string
+	// Since is when the quest entered the state, empty when not reported
+	Since string
+	// FetchedAt is when the status was fetched, and Cached marks a status
+	// reused from the quest cache
+	FetchedAt time.Time
+	Cached    bool
+}
+
+// lifecycleApplies reports whether an action is checked against the quest's
+// state. Start creates the quest, so there's no state to check yet.
+func lifecycleApplies(action Action) bool {
+	return action == ActionProcess || action == ActionComplete
+}
+
+// newQuestLifecycle validates a lifecycle config
+func newQuestLifecycle(cfg LifecycleConfig) (*questLifecycle, error) {
+	l := &questLifecycle{StateField: cfg.StateField, SinceField: cfg.SinceField, Allowed: map[string][]Action{}}
+	if l.StateField == "" {
+		l.StateField = "status"
+	}
+	if l.SinceField == "" {
+		l.SinceField = "updated_at"
+	}
+	if len(cfg.Allowed) == 0 {
+		return nil, fmt.Errorf("allowed needs the actions of at least one state")
+	}
+	for state, actions := range cfg.Allowed {
+		allowed := []Action{}
+		for _, a := range actions {
+			if !lifecycleApplies(Action(a)) {
+				return nil, fmt.Errorf("allowed.%s: expected %s or %s, got %q", state, ActionProcess, ActionComplete, a)
+			}
+			allowed = append(allowed, Action(a))
+		}
+		l.Allowed[state] = allowed
+	}
+	return l, nil
+}
+
+// parseQuestState reads the state of a status response
+func (l *questLifecycle) parseQuestState(env string, quest int, body []byte) (questState, error) {
+	st := questState{Env: env, Quest: quest}
+	var resp interface{}
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return st, fmt.Errorf("the status response isn't JSON")
+	}
+	v, ok := lookupPath(resp, l.StateField)
+	state, isString := v.(string)
+	if !ok || !isString || state == "" {
+		return st, fmt.Errorf("the status response has no %s", l.StateField)
+	}
+	st.State = state
+	if v, ok := lookupPath(resp, l.SinceField); ok {
+		if since, ok := v.(string); ok {
+			st.Since = since
+			if t, err := time.Parse(time.RFC3339, since); err == nil {
+				st.Since = t.UTC().Format(time.DateOnly)
+			}
+		}
+	}
+	return st, nil
+}
+
+// availability explains why the quest's state doesn't allow the action,
+// empty when it does
+func (l *questLifecycle) availability(st questState, action Action) string {
+	allowed, listed := l.Allowed[st.State]
+	if !lifecycleApplies(action) || !listed || slices.Contains(allowed, action) {
+		return ""
+	}
+	reason := "already " + st.State
+	if st.Since != "" {
+		reason += " on " + st.Since
+	}
+	var states []string
+	for state, actions := range l.Allowed {
+		if slices.Contains(actions, action) {
+			states = append(states, state)
+		}
+	}
+	if len(states) == 0 {
+		return fmt.Sprintf("%s, no state allows %s", reason, action)
+	}
+	sort.Strings(states)
+	return fmt.Sprintf("%s, %s needs %s", reason, action, strings.Join(states, " or "))
+}
+
+// questStateMsg carries the state of the quest being confirmed
+type questStateMsg struct {
+	state questState
+	err   error
+}
+
+func fetchQuestStateCmd(env string, quest int, refresh bool) tea.Cmd {
+	return func() tea.Msg {
+		st, err := fetchQuestState(env, quest, refresh)
+		return questStateMsg{state: st, err: err}
+	}
+}
+
+// fetchQuestState reads a quest's state from its status, cached unless
+// refresh is set
+func fetchQuestState(env string, quest int, refresh bool) (questState, error) {
+	c, cached, err := fetchQuestStatus(env, quest, refresh)
+	if err != nil {
+		return questState{Env: env, Quest: quest}, err
+	}
+	st, err := toolRegistry[sweepstakeTool].Lifecycle.parseQuestState(env, quest, c.Status)
+	st.FetchedAt, st.Cached = c.FetchedAt, cached
+	return st, err
+}
+
+// checkQuestState fetches the state of the pending payload's quest when the
+// tool has a lifecycle. The confirmation waits for it.
+func (m *model) checkQuestState() tea.Cmd {
+	return m.loadQuestState(false)
+}
+
+// loadQuestState is checkQuestState, bypassing the quest cache with refresh
+func (m *model) loadQuestState(refresh bool) tea.Cmd {
+	m.questStateErr = nil
+	p := m.pendingPayload
+	if toolRegistry[sweepstakeTool].Lifecycle == nil || !lifecycleApplies(p.Action) || p.SweepstakeQuestID == nil || checkDisabled(m.selectedEnv, "lifecycle") {
+		m.questStateLoading = false
+		return nil
+	}
+	m.questStateLoading = true
+	return fetchQuestStateCmd(m.selectedEnv, *p.SweepstakeQuestID, refresh)
+}
+
+// pendingQuestState is the fetched state of the pending payload's quest,
+// nil when there's none
+func (m model) pendingQuestState() *questState {
+	st, p := m.questState, m.pendingPayload
+	if st == nil || st.Env != m.selectedEnv || p.SweepstakeQuestID == nil || *p.SweepstakeQuestID != st.Quest {
+		return nil
+	}
+	return st
+}
+
+// lifecycleBlocks reports whether the quest's state doesn't allow the
+// pending invocation
+func (m model) lifecycleBlocks() bool {
+	_, ok := warning(m.checkResults(), "lifecycle")
+	return ok
+}
+
+// lifecycleView shows the quest's state on the confirmation screen
+func (m model) lifecycleView() string {
+	if m.batchRows != nil {
+		return ""
+	}
+	switch {
+	case m.questStateLoading:
+		return "  " + tr(msgLifecycleChecking, payloadValue(m.pendingPayload)) + "\n\n"
+	case m.questStateErr != nil:
+		return fmt.Sprintf("  %s %s\n\n", glyphs.Warn, tr(msgLifecycleCheckErr, payloadValue(m.pendingPayload), m.questStateErr))
+	case m.lifecycleBlocks():
+		reason := toolRegistry[sweepstakeTool].Lifecycle.availability(*m.pendingQuestState(), m.pendingPayload.Action)
+		return fmt.Sprintf("  %s %s\n\n", glyphs.Warn,
+			tr(msgLifecycleUnavailable, m.pendingPayload.Action, payloadValue(m.pendingPayload), reason, lifecycleAckPhrase))
+	}
+	if st := m.pendingQuestState(); st != nil {
+		if st.Cached {
+			return "  " + tr(msgLifecycleStateCached, st.State, formatAge(time.Since(st.FetchedAt))) + "\n\n"
+		}
+		return "  " + tr(msgLifecycleState, st.State) + "\n\n"
+	}
+	return ""
+}
+
+// applyAvailability marks the action list entries the last checked quest's
+// state doesn't allow
+func (m *model) applyAvailability() {
+	l, st := toolRegistry[sweepstakeTool].Lifecycle, m.questState
+	for i, li := range m.actionList.Items() {
+		it, ok := li.(item)
+		if !ok {
+			continue
+		}
+		note := ""
+		if l != nil && st != nil && st.Env == m.selectedEnv {
+			if reason := l.availability(*st, Action(it.action)); reason != "" {
+				note = fmt.Sprintf("unavailable for quest %d, %s", st.Quest, reason)
+			}
+		}
+		if note != it.unavailable {
+			it.unavailable = note
+			m.actionList.SetItem(i, it)
+		}
+	}
+}
+
+// cliQuestState fetches the state of a command line invocation's quest for
+// the lifecycle check, nil when there's nothing to check or it failed
+func cliQuestState(out io.Writer, env string, payload EventPayload) *questState {
+	l := toolRegistry[sweepstakeTool].Lifecycle
+	if l == nil || !lifecycleApplies(payload.Action) || payload.SweepstakeQuestID == nil || payload.DryRun || checkDisabled(env, "lifecycle") {
+		return nil
+	}
+	st, err := fetchQuestState(env, *payload.SweepstakeQuestID, true)
+	if err != nil {
+		fmt.Fprintf(out, "Couldn't check quest %d's state: %v\n", st.Quest, err)
+		return nil
+	}
+	return &st
+}
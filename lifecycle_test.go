@@ -0,0 +1,109 @@
+package main
+
+import "testing"
+
+func TestNewQuestLifecycle(t *testing.T) {
+	l, err := newQuestLifecycle(LifecycleConfig{Allowed: map[string][]string{"completed": {}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l.StateField != "status" || l.SinceField != "updated_at" {
+		t.Errorf("default fields %q and %q", l.StateField, l.SinceField)
+	}
+	for name, cfg := range map[string]LifecycleConfig{
+		"no states":    {},
+		"start":        {Allowed: map[string][]string{"open": {"start"}}},
+		"rollback":     {Allowed: map[string][]string{"completed": {"rollback"}}},
+		"a typo":       {Allowed: map[string][]string{"open": {"process", "compelte"}}},
+		"empty action": {Allowed: map[string][]string{"open": {""}}},
+	} {
+		if _, err := newQuestLifecycle(cfg); err == nil {
+			t.Errorf("%s: accepted %+v", name, cfg)
+		}
+	}
+}
+
+func TestParseQuestState(t *testing.T) {
+	l, err := newQuestLifecycle(LifecycleConfig{
+		StateField: "quest.phase",
+		SinceField: "quest.phase_changed_at",
+		Allowed:    map[string][]string{"closed": {"complete"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name  string
+		body  string
+		state string
+		since string
+		fails bool
+	}{
+		{"timestamp", `{"quest":{"phase":"closed","phase_changed_at":"2024-05-30T23:30:00-02:00"}}`, "closed", "2024-05-31", false},
+		{"other since", `{"quest":{"phase":"closed","phase_changed_at":"last week"}}`, "closed", "last week", false},
+		{"no since", `{"quest":{"phase":"open"}}`, "open", "", false},
+		{"numeric since", `{"quest":{"phase":"open","phase_changed_at":1717000000}}`, "open", "", false},
+		{"no state", `{"quest":{"phase_changed_at":"2024-05-30T10:00:00Z"}}`, "", "", true},
+		{"empty state", `{"quest":{"phase":""}}`, "", "", true},
+		{"numeric state", `{"quest":{"phase":3}}`, "", "", true},
+		{"not JSON", `502 Bad Gateway`, "", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			st, err := l.parseQuestState(devEnv, 42, []byte(tt.body))
+			if (err != nil) != tt.fails {
+				t.Fatalf("err %v", err)
+			}
+			if st.Env != devEnv || st.Quest != 42 || st.State != tt.state || st.Since != tt.since {
+				t.Errorf("state %+v, want %q since %q", st, tt.state, tt.since)
+			}
+		})
+	}
+}
+
+func TestQuestAvailability(t *testing.T) {
+	// A sweepstake processes entries while open and completes once closed,
+	// a tournament processes rounds until it's completed
+	sweepstake := LifecycleConfig{Allowed: map[string][]string{
+		"open":      {"process"},
+		"closed":    {"complete"},
+		"completed": {},
+	}}
+	tournament := LifecycleConfig{Allowed: map[string][]string{
+		"running":   {"process", "complete"},
+		"completed": {},
+	}}
+	tests := []struct {
+		name   string
+		cfg    LifecycleConfig
+		state  string
+		since  string
+		action Action
+		want   string
+	}{
+		{"process while open", sweepstake, "open", "", ActionProcess, ""},
+		{"complete while open", sweepstake, "open", "2024-05-01", ActionComplete, "already open on 2024-05-01, complete needs closed"},
+		{"complete once closed", sweepstake, "closed", "", ActionComplete, ""},
+		{"process once closed", sweepstake, "closed", "", ActionProcess, "already closed, process needs open"},
+		{"complete twice", sweepstake, "completed", "2024-05-30", ActionComplete, "already completed on 2024-05-30, complete needs closed"},
+		{"unlisted state", sweepstake, "paused", "", ActionComplete, ""},
+		{"start isn't checked", sweepstake, "completed", "", ActionStart, ""},
+		{"rollback isn't checked", sweepstake, "open", "", ActionRollback, ""},
+		{"both while running", tournament, "running", "", ActionComplete, ""},
+		{"process once completed", tournament, "completed", "", ActionProcess, "already completed, process needs running"},
+		{"no state allows it", LifecycleConfig{Allowed: map[string][]string{"open": {"process"}, "archived": {}}}, "archived", "", ActionComplete, "already archived, no state allows complete"},
+		{"several states allow it", LifecycleConfig{Allowed: map[string][]string{"open": {"process"}, "paused": {"process"}, "closed": {}}}, "closed", "", ActionProcess, "already closed, process needs open or paused"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l, err := newQuestLifecycle(tt.cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			st := questState{Env: prodEnv, Quest: 42, State: tt.state, Since: tt.since}
+			if got := l.availability(st, tt.action); got != tt.want {
+				t.Errorf("availability = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
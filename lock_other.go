@@ -0,0 +1,15 @@
+//go:build !unix
+
+package main
+
+import "os"
+
+// Advisory locking isn't available, writes are still serialized in-process
+
+func lockFile(*os.File) error {
+	return nil
+}
+
+func unlockFile(*os.File) error {
+	return nil
+}
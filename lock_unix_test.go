@@ -0,0 +1,147 @@
+//go:build unix
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// Each stress writer process runs stressGoroutines goroutines writing
+// stressWrites records each. History records are a few KB apiece so no
+// append is atomic by luck.
+const (
+	stressProcesses  = 4
+	stressGoroutines = 8
+	stressWrites     = 25
+)
+
+// writerEnv makes the test binary a stress writer instead of running tests
+const writerEnv = "PLAYTOOLS_STRESS_WRITER"
+
+// TestStressWriter is the body of a writer process started by the stress
+// tests; it does nothing in a normal test run
+func TestStressWriter(t *testing.T) {
+	kind, proc := os.Getenv(writerEnv), os.Getenv(writerEnv+"_ID")
+	if kind == "" {
+		t.Skip("only runs as a stress writer process")
+	}
+	padding := strings.Repeat("x", 4096)
+	var wg sync.WaitGroup
+	errs := make(chan error, stressGoroutines*stressWrites)
+	for g := 0; g < stressGoroutines; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for k := 0; k < stressWrites; k++ {
+				id := fmt.Sprintf("p%s-g%d-w%d", proc, g, k)
+				switch kind {
+				case "history":
+					errs <- appendHistory(historyRecord{Env: devEnv, Action: ActionProcess, RequestID: id, Justification: padding})
+				case "state":
+					errs <- updateState(func(s *appState) {
+						if s.EnvInfo == nil {
+							s.EnvInfo = map[string]envInfo{}
+						}
+						s.EnvInfo[id] = envInfo{Account: proc}
+					})
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// runWriters runs the stress writer processes against one config dir
+func runWriters(t *testing.T, kind string) {
+	t.Helper()
+	if testing.Short() {
+		t.Skip("spawns writer processes")
+	}
+	dir := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", dir)
+	var wg sync.WaitGroup
+	for p := 0; p < stressProcesses; p++ {
+		wg.Add(1)
+		go func(p int) {
+			defer wg.Done()
+			cmd := exec.Command(os.Args[0], "-test.run=^TestStressWriter$", "-test.count=1")
+			cmd.Env = append(os.Environ(), "XDG_CONFIG_HOME="+dir, writerEnv+"="+kind, writerEnv+"_ID="+strconv.Itoa(p))
+			if out, err := cmd.CombinedOutput(); err != nil {
+				t.Errorf("writer %d: %v\n%s", p, err, out)
+			}
+		}(p)
+	}
+	wg.Wait()
+}
+
+// wantAllWrites checks every writer's every write is there exactly once
+func wantAllWrites(t *testing.T, ids []string) {
+	t.Helper()
+	seen := map[string]int{}
+	for _, id := range ids {
+		seen[id]++
+	}
+	for p := 0; p < stressProcesses; p++ {
+		for g := 0; g < stressGoroutines; g++ {
+			for k := 0; k < stressWrites; k++ {
+				if id := fmt.Sprintf("p%d-g%d-w%d", p, g, k); seen[id] != 1 {
+					t.Errorf("%s written %d times", id, seen[id])
+				}
+			}
+		}
+	}
+	if want := stressProcesses * stressGoroutines * stressWrites; len(ids) != want {
+		t.Errorf("%d writes, want %d", len(ids), want)
+	}
+}
+
+func TestConcurrentHistoryWriters(t *testing.T) {
+	runWriters(t, "history")
+	records, warnings, err := loadHistory()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(warnings) > 0 {
+		t.Errorf("interleaved lines: %v", warnings)
+	}
+	ids := make([]string, 0, len(records))
+	for _, rec := range records {
+		if len(rec.Justification) != 4096 {
+			t.Errorf("%s came back with a %d byte justification", rec.RequestID, len(rec.Justification))
+		}
+		ids = append(ids, rec.RequestID)
+	}
+	wantAllWrites(t, ids)
+}
+
+func TestConcurrentStateWriters(t *testing.T) {
+	runWriters(t, "state")
+	state, err := loadState()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := make([]string, 0, len(state.EnvInfo))
+	for id := range state.EnvInfo {
+		ids = append(ids, id)
+	}
+	wantAllWrites(t, ids)
+
+	path, _ := stateFilePath()
+	leftovers, _ := filepath.Glob(path + ".tmp-*")
+	if len(leftovers) > 0 {
+		t.Errorf("temp files left behind: %v", leftovers)
+	}
+}
@@ -0,0 +1,230 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// logGroup is a logical event of a log tail: a single line, or a JSON
+// object pretty-printed over several lines
+type logGroup struct {
+	lines []string
+	// structured marks a multi-line JSON block, and truncated one that the
+	// tail cut off at either end
+	structured bool
+	truncated  bool
+}
+
+// platformLinePrefixes start the lines the Lambda runtime writes around each
+// invocation, which are never part of an application's JSON
+var platformLinePrefixes = []string{"START RequestId:", "END RequestId:", "REPORT RequestId:", "INIT_START ", "XRAY TraceId:"}
+
+func isPlatformLine(line string) bool {
+	for _, p := range platformLinePrefixes {
+		if strings.HasPrefix(line, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// braceDelta is how much a line opens or closes JSON objects and arrays,
+// ignoring brackets inside strings. JSON strings can't span lines, so each
+// line is scanned on its own, starting inside a string only for a line the
+// tail cut in the middle of one.
+func braceDelta(line string, inString bool) int {
+	delta, escaped := 0, false
+	for _, r := range line {
+		switch {
+		case escaped:
+			escaped = false
+		case inString && r == '\\':
+			escaped = true
+		case r == '"':
+			inString = !inString
+		case inString:
+		case r == '{' || r == '[':
+			delta++
+		case r == '}' || r == ']':
+			delta--
+		}
+	}
+	return delta
+}
+
+// oddQuotes reports whether a line has an odd number of unescaped quotes,
+// which a whole line of JSON never has. The 4KB tail starting in the middle
+// of a string leaves its first line with one.
+func oddQuotes(line string) bool {
+	odd, escaped := false, false
+	for _, r := range line {
+		switch {
+		case escaped:
+			escaped = false
+		case r == '\\':
+			escaped = true
+		case r == '"':
+			odd = !odd
+		}
+	}
+	return odd
+}
+
+// cutBlockEnd is the index of the line closing the block the 4KB tail starts
+// inside of, -1 when it starts outside one. That's the closing line where
+// the braces, closed without having been opened, fall lowest before the
+// first platform line, so a tail starting inside a nested object takes in
+// the rest of the outer one.
+func cutBlockEnd(lines []string, deltas []int) int {
+	end, depth, lowest := -1, 0, 0
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if isPlatformLine(trimmed) {
+			break
+		}
+		depth += deltas[i]
+		// Pretty-printed JSON closes on a line of its own, unlike a message
+		// that happens to have a closing brace
+		if depth < lowest && (strings.HasPrefix(trimmed, "}") || strings.HasPrefix(trimmed, "]")) {
+			end, lowest = i, depth
+		}
+	}
+	return end
+}
+
+// groupLogLines groups the lines of multi-line JSON blocks, found by
+// balancing their braces, into single events. A block still open at a
+// platform line or at the end, or closed without having been opened because
+// the 4KB tail starts inside it, is kept as a truncated event.
+func groupLogLines(lines []string) []logGroup {
+	deltas := make([]int, len(lines))
+	for i, line := range lines {
+		deltas[i] = braceDelta(line, i == 0 && oddQuotes(line))
+	}
+	var groups []logGroup
+	if end := cutBlockEnd(lines, deltas); end >= 0 {
+		groups = append(groups, logGroup{lines: lines[:end+1], structured: true, truncated: true})
+		lines, deltas = lines[end+1:], deltas[end+1:]
+	}
+	var cur *logGroup
+	depth := 0
+	flush := func() {
+		if cur != nil {
+			groups = append(groups, *cur)
+			cur = nil
+		}
+	}
+	for i, line := range lines {
+		if isPlatformLine(strings.TrimSpace(line)) {
+			if cur != nil {
+				cur.truncated = true
+			}
+			flush()
+			groups = append(groups, logGroup{lines: []string{line}})
+			continue
+		}
+		d := deltas[i]
+		if cur != nil {
+			cur.lines = append(cur.lines, line)
+			if depth += d; depth <= 0 {
+				flush()
+			}
+			continue
+		}
+		if d > 0 {
+			cur, depth = &logGroup{lines: []string{line}, structured: true}, d
+			continue
+		}
+		groups = append(groups, logGroup{lines: []string{line}})
+	}
+	if cur != nil {
+		cur.truncated = true
+	}
+	flush()
+	return groups
+}
+
+// summary renders a JSON event on one line as its timestamp, level and
+// message, taken from the object or from the text format prefix before it
+func (g logGroup) summary() string {
+	if !g.structured {
+		return g.lines[0]
+	}
+	text := strings.Join(g.lines, "\n")
+	prefix, object := "", text
+	if i := strings.IndexAny(text, "{["); i >= 0 && braceDelta(g.lines[0], false) > 0 {
+		prefix, object = strings.TrimSpace(text[:i]), text[i:]
+	}
+	// A key before it means the tail started inside the object
+	if strings.HasSuffix(prefix, ":") {
+		prefix = ""
+	}
+
+	var fields map[string]interface{}
+	if g.truncated || json.Unmarshal([]byte(object), &fields) != nil {
+		fields = map[string]interface{}{}
+		// The lines of a cut off block still parse one by one
+		for _, line := range g.lines {
+			k, v, ok := strings.Cut(strings.TrimSuffix(strings.TrimSpace(line), ","), ":")
+			var key string
+			var value interface{}
+			if ok && json.Unmarshal([]byte(k), &key) == nil && json.Unmarshal([]byte(v), &value) == nil {
+				fields[key] = value
+			}
+		}
+	}
+	pick := func(keys ...string) string {
+		for _, k := range keys {
+			if s, ok := fields[k].(string); ok && s != "" {
+				return s
+			}
+		}
+		return ""
+	}
+	// The text format prefixes a timestamp, the request ID and the level
+	parts := strings.Split(prefix, "\t")
+	ts := pick("timestamp", "time")
+	if ts == "" && len(parts) >= 3 {
+		ts = parts[0]
+	}
+	level := pick("level", "severity")
+	if level == "" && len(parts) >= 3 {
+		level = parts[2]
+	}
+	msg := pick("message", "msg")
+	if msg == "" && prefix != "" && len(parts) < 3 {
+		msg = prefix
+	}
+
+	var cols []string
+	for _, c := range []string{ts, level, msg} {
+		if c != "" {
+			cols = append(cols, c)
+		}
+	}
+	note := fmt.Sprintf("{%d lines}", len(g.lines))
+	if g.truncated {
+		note = fmt.Sprintf("{truncated, %d lines}", len(g.lines))
+	}
+	return strings.Join(append(cols, note), "  ")
+}
+
+// groupJSONEvents renders a log tail with each multi-line JSON event either
+// collapsed to its summary line or expanded to the whole object. Other lines,
+// START/END/REPORT included, are kept as they are.
+func groupJSONEvents(logs string, expanded bool) string {
+	if expanded {
+		return logs
+	}
+	trailing := strings.HasSuffix(logs, "\n")
+	var out []string
+	for _, g := range groupLogLines(strings.Split(strings.TrimRight(logs, "\n"), "\n")) {
+		out = append(out, g.summary())
+	}
+	s := strings.Join(out, "\n")
+	if trailing {
+		s += "\n"
+	}
+	return s
+}
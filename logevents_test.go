@@ -0,0 +1,162 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// groupShapes describes groups as their kind and length, like "P1 S5 T3":
+// a plain line, a JSON block and a truncated one
+func groupShapes(groups []logGroup) string {
+	var shapes []string
+	for _, g := range groups {
+		kind := "P"
+		switch {
+		case g.truncated:
+			kind = "T"
+		case g.structured:
+			kind = "S"
+		}
+		shapes = append(shapes, fmt.Sprintf("%s%d", kind, len(g.lines)))
+	}
+	return strings.Join(shapes, " ")
+}
+
+func TestGroupLogLines(t *testing.T) {
+	tests := []struct {
+		name   string
+		logs   string
+		shapes string
+		// summary is the summary of the first JSON block
+		summary string
+	}{
+		{
+			name: "whole block",
+			logs: "START RequestId: r1 Version: $LATEST\n" +
+				"2024-05-01T10:00:00.000Z\tr1\tINFO\t{\n" +
+				"  \"message\": \"processed batch\",\n" +
+				"  \"entries\": [1, 2],\n" +
+				"  \"meta\": {\"note\": \"}\"}\n" +
+				"}\n" +
+				"END RequestId: r1\n" +
+				"REPORT RequestId: r1\tDuration: 2.00 ms",
+			shapes:  "P1 S5 P1 P1",
+			summary: "2024-05-01T10:00:00.000Z  INFO  processed batch  {5 lines}",
+		},
+		{
+			name: "cut at the start",
+			logs: "  \"level\": \"ERROR\",\n" +
+				"  \"message\": \"boom\"\n" +
+				"}\n" +
+				"REPORT RequestId: r1\tDuration: 2.00 ms",
+			shapes:  "T3 P1",
+			summary: "ERROR  boom  {truncated, 3 lines}",
+		},
+		{
+			name: "cut inside a nested object",
+			logs: "    \"id\": 7,\n" +
+				"    \"prize\": \"gold\"\n" +
+				"  },\n" +
+				"  \"level\": \"WARN\",\n" +
+				"  \"message\": \"late winner\"\n" +
+				"}\n" +
+				"2024-05-01T10:00:01.000Z\tr1\tINFO\tdone\n" +
+				"END RequestId: r1",
+			shapes:  "T6 P1 P1",
+			summary: "WARN  late winner  {truncated, 6 lines}",
+		},
+		{
+			name: "cut inside a string",
+			logs: "nus {round 2\",\n" +
+				"  \"message\": \"bonus paid\"\n" +
+				"}\n" +
+				"REPORT RequestId: r1\tDuration: 2.00 ms",
+			shapes:  "T3 P1",
+			summary: "bonus paid  {truncated, 3 lines}",
+		},
+		{
+			name: "cut inside a string with an escaped quote",
+			logs: "d \\\"quoted\\\" [x\",\n" +
+				"  \"message\": \"escaped\"\n" +
+				"}",
+			shapes:  "T3",
+			summary: "escaped  {truncated, 3 lines}",
+		},
+		{
+			name: "cut at the end",
+			logs: "START RequestId: r1 Version: $LATEST\n" +
+				"2024-05-01T10:00:00.000Z\tr1\tINFO\t{\n" +
+				"  \"message\": \"halfway\",\n" +
+				"  \"entr",
+			shapes:  "P1 T3",
+			summary: "2024-05-01T10:00:00.000Z  INFO  halfway  {truncated, 3 lines}",
+		},
+		{
+			name: "cut by the end of the invocation",
+			logs: "{\n" +
+				"  \"level\": \"INFO\",\n" +
+				"  \"message\": \"still going\",\n" +
+				"END RequestId: r1\n" +
+				"REPORT RequestId: r1\tDuration: 900000.00 ms",
+			shapes:  "T3 P1 P1",
+			summary: "INFO  still going  {truncated, 3 lines}",
+		},
+		{
+			name: "cut at both ends",
+			logs: "  \"items\": [\n" +
+				"    1,",
+			shapes:  "T2",
+			summary: "{truncated, 2 lines}",
+		},
+		{
+			name: "single line JSON",
+			logs: "2024-05-01T10:00:00.000Z\tr1\tINFO\t{\"message\":\"one line\"}\n" +
+				"plain text }\n" +
+				"END RequestId: r1",
+			shapes: "P1 P1 P1",
+		},
+		{
+			name: "text before a block",
+			logs: "warming up\n" +
+				"{\n" +
+				"  \"msg\": \"ready\"\n" +
+				"}",
+			shapes:  "P1 S3",
+			summary: "ready  {3 lines}",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			groups := groupLogLines(strings.Split(tt.logs, "\n"))
+			if got := groupShapes(groups); got != tt.shapes {
+				t.Errorf("grouped as %s, want %s", got, tt.shapes)
+			}
+			var lines []string
+			summary := ""
+			for _, g := range groups {
+				lines = append(lines, g.lines...)
+				if g.structured && summary == "" {
+					summary = g.summary()
+				}
+			}
+			if strings.Join(lines, "\n") != tt.logs {
+				t.Errorf("lines lost or reordered:\n%s", strings.Join(lines, "\n"))
+			}
+			if summary != tt.summary {
+				t.Errorf("summary %q, want %q", summary, tt.summary)
+			}
+		})
+	}
+}
+
+func TestGroupJSONEvents(t *testing.T) {
+	logs := "START RequestId: r1 Version: $LATEST\n{\n  \"message\": \"hi\"\n}\nEND RequestId: r1\n"
+	if got := groupJSONEvents(logs, true); got != logs {
+		t.Errorf("expanded to\n%s", got)
+	}
+	want := "START RequestId: r1 Version: $LATEST\nhi  {3 lines}\nEND RequestId: r1\n"
+	if got := groupJSONEvents(logs, false); got != want {
+		t.Errorf("collapsed to %q, want %q", got, want)
+	}
+}
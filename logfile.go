@@ -0,0 +1,315 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// Full stream logs can run to tens of megabytes, so they are written to a
+// temp file and the log view only holds a window of their lines
+const (
+	// logWindowLines bounds the lines of a file-backed log view in memory
+	logWindowLines = 5000
+	// logChunkLines is how many lines the window moves when scrolled past
+	// its edges, and how often the file index records an offset
+	logChunkLines = 1000
+	// logTailLines is how many of the last lines the output screen shows
+	logTailLines = 500
+	// logSearchBatch is how many bytes of the file a search scans before
+	// sending back what it found
+	logSearchBatch = 4 << 20
+	// logMaxMatches bounds the matches a file search keeps
+	logMaxMatches = 10000
+)
+
+var (
+	logFilesMu sync.Mutex
+	logFiles   []string
+)
+
+// createLogFile creates a temp file for fetched logs, removed on exit by
+// removeLogFiles
+func createLogFile() (*os.File, error) {
+	f, err := os.CreateTemp("", "playtools-logs-*.log")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create a temp file for the logs: %v", err)
+	}
+	logFilesMu.Lock()
+	logFiles = append(logFiles, f.Name())
+	logFilesMu.Unlock()
+	return f, nil
+}
+
+// removeLogFile deletes a fetched log file before the session ends
+func removeLogFile(path string) {
+	if path == "" {
+		return
+	}
+	os.Remove(path)
+	logFilesMu.Lock()
+	defer logFilesMu.Unlock()
+	for i, p := range logFiles {
+		if p == path {
+			logFiles = append(logFiles[:i], logFiles[i+1:]...)
+			break
+		}
+	}
+}
+
+// removeLogFiles deletes every log file fetched in the session
+func removeLogFiles() {
+	logFilesMu.Lock()
+	defer logFilesMu.Unlock()
+	for _, p := range logFiles {
+		os.Remove(p)
+	}
+	logFiles = nil
+}
+
+// tailBuffer keeps the last lines written to it
+type tailBuffer struct {
+	lines []string
+	next  int
+	full  bool
+}
+
+func (t *tailBuffer) add(line string) {
+	if len(t.lines) < logTailLines {
+		t.lines = append(t.lines, line)
+		return
+	}
+	t.lines[t.next] = line
+	t.next = (t.next + 1) % logTailLines
+	t.full = true
+}
+
+func (t *tailBuffer) String() string {
+	lines := t.lines
+	if t.full {
+		lines = append(append([]string{}, t.lines[t.next:]...), t.lines[:t.next]...)
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// streamLogEvents writes every event of a log stream to w one page at a
+// time, so only a page is ever decoded in memory. It returns the number of
+// lines written and the last of them.
+func streamLogEvents(profile, functionName, stream string, w io.Writer) (int, string, error) {
+	var tail tailBuffer
+	lines := 0
+	token := ""
+	for {
+		args := []string{"logs", "get-log-events",
+			"--log-group-name", logGroupName(functionName),
+			"--log-stream-name", stream,
+			"--start-from-head",
+			"--profile", profile, "--output", "json",
+		}
+		args = append(args, regionArgs(profile)...)
+		if token != "" {
+			args = append(args, "--next-token", token)
+		}
+		out, err := exec.Command("aws", args...).Output()
+		if err != nil {
+			return lines, tail.String(), fmt.Errorf("failed to fetch logs: %v", err)
+		}
+		var page struct {
+			Events           []logEvent `json:"events"`
+			NextForwardToken string     `json:"nextForwardToken"`
+		}
+		if err := json.Unmarshal(out, &page); err != nil {
+			return lines, tail.String(), fmt.Errorf("failed to parse logs: %v", err)
+		}
+		for _, e := range page.Events {
+			for _, line := range strings.Split(strings.TrimRight(e.Message, "\n"), "\n") {
+				if _, err := io.WriteString(w, line+"\n"); err != nil {
+					return lines, tail.String(), fmt.Errorf("failed to write logs: %v", err)
+				}
+				tail.add(line)
+				lines++
+			}
+		}
+		// The forward token comes back unchanged at the end of the stream
+		if page.NextForwardToken == "" || page.NextForwardToken == token {
+			return lines, tail.String(), nil
+		}
+		token = page.NextForwardToken
+	}
+}
+
+// fetchLambdaLogs writes the events of every stream of the function's log
+// group since start to w, a page at a time, stopping after maxLines lines.
+// It returns the number of lines written, the last of them, and whether the
+// window ran out before the cap.
+func fetchLambdaLogs(profile, functionName string, start time.Time, maxLines int, w io.Writer) (int, string, bool, error) {
+	var tail tailBuffer
+	lines := 0
+	token := ""
+	for {
+		args := []string{"filter-log-events",
+			"--log-group-name", logGroupName(functionName),
+			"--start-time", strconv.FormatInt(start.UnixMilli(), 10),
+			"--no-paginate",
+		}
+		if token != "" {
+			args = append(args, "--next-token", token)
+		}
+		var page struct {
+			Events    []logEvent `json:"events"`
+			NextToken string     `json:"nextToken"`
+		}
+		if err := awsLogs(profile, &page, args...); err != nil {
+			return lines, tail.String(), false, err
+		}
+		for _, e := range page.Events {
+			for _, line := range strings.Split(strings.TrimRight(e.Message, "\n"), "\n") {
+				if lines == maxLines {
+					return lines, tail.String(), false, nil
+				}
+				if _, err := io.WriteString(w, line+"\n"); err != nil {
+					return lines, tail.String(), false, fmt.Errorf("failed to write logs: %v", err)
+				}
+				tail.add(line)
+				lines++
+			}
+		}
+		if page.NextToken == "" {
+			return lines, tail.String(), true, nil
+		}
+		token = page.NextToken
+	}
+}
+
+// logFile indexes an on-disk log by chunk, so any line can be read without
+// holding the file in memory
+type logFile struct {
+	path string
+	// offsets is the byte offset of every logChunkLines-th line
+	offsets []int64
+	total   int
+}
+
+func indexLogFile(path string) (*logFile, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open the logs: %v", err)
+	}
+	defer f.Close()
+	lf := &logFile{path: path}
+	r := bufio.NewReader(f)
+	var offset int64
+	for {
+		line, err := r.ReadSlice('\n')
+		for errors.Is(err, bufio.ErrBufferFull) {
+			var more []byte
+			more, err = r.ReadSlice('\n')
+			offset += int64(len(line))
+			line = more
+		}
+		if len(line) > 0 {
+			if lf.total%logChunkLines == 0 {
+				lf.offsets = append(lf.offsets, offset)
+			}
+			lf.total++
+			offset += int64(len(line))
+		}
+		if err == io.EOF {
+			return lf, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read the logs: %v", err)
+		}
+	}
+}
+
+// readLines reads up to n lines starting at the 0-based line from
+func (lf *logFile) readLines(from, n int) ([]string, error) {
+	if from < 0 || from >= lf.total {
+		return nil, nil
+	}
+	f, err := os.Open(lf.path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open the logs: %v", err)
+	}
+	defer f.Close()
+	chunk := from / logChunkLines
+	if _, err := f.Seek(lf.offsets[chunk], io.SeekStart); err != nil {
+		return nil, fmt.Errorf("failed to read the logs: %v", err)
+	}
+	r := bufio.NewReader(f)
+	var lines []string
+	for i := chunk * logChunkLines; len(lines) < n; i++ {
+		line, err := r.ReadString('\n')
+		if line == "" && err != nil {
+			if err == io.EOF {
+				break
+			}
+			return nil, fmt.Errorf("failed to read the logs: %v", err)
+		}
+		if i >= from {
+			lines = append(lines, strings.ReplaceAll(strings.TrimRight(line, "\n"), "\t", "    "))
+		}
+	}
+	return lines, nil
+}
+
+// logSearchMsg carries the matches a batch of a file search found. gen
+// tells a stale search's batches apart from the current one's.
+type logSearchMsg struct {
+	gen     int
+	matches []int
+	offset  int64
+	line    int
+	done    bool
+	err     error
+}
+
+// searchLogFileCmd scans the file from offset, whose first line is line,
+// for lines containing needle, stopping after logSearchBatch bytes so the
+// matches come back while the rest of the file is still searched
+func searchLogFileCmd(path, needle string, gen int, offset int64, line int) tea.Cmd {
+	return func() tea.Msg {
+		msg := logSearchMsg{gen: gen, offset: offset, line: line}
+		f, err := os.Open(path)
+		if err != nil {
+			msg.err, msg.done = err, true
+			return msg
+		}
+		defer f.Close()
+		if _, err := f.Seek(offset, io.SeekStart); err != nil {
+			msg.err, msg.done = err, true
+			return msg
+		}
+		r := bufio.NewReader(f)
+		for msg.offset-offset < logSearchBatch {
+			text, err := r.ReadString('\n')
+			if text != "" {
+				if strings.Contains(strings.ToLower(text), needle) {
+					msg.matches = append(msg.matches, msg.line)
+				}
+				msg.offset += int64(len(text))
+				msg.line++
+			}
+			if err == io.EOF {
+				msg.done = true
+				return msg
+			}
+			if err != nil {
+				msg.err, msg.done = err, true
+				return msg
+			}
+		}
+		return msg
+	}
+}
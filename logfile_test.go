@@ -0,0 +1,92 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// writeLog writes a log of about size bytes, with an ERROR line every
+// thousand lines, returning its number of lines
+func writeLog(t *testing.T, path string, size int) int {
+	t.Helper()
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := bufio.NewWriter(f)
+	lines, written := 0, 0
+	for written < size {
+		level := "INFO"
+		if lines%1000 == 999 {
+			level = "ERROR"
+		}
+		n, _ := fmt.Fprintf(w, "2024-05-01T10:%02d:%02dZ %-5s processed entry %09d of quest 42\tbatch %d\n", lines/60%60, lines%60, level, lines, lines/500)
+		written += n
+		lines++
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return lines
+}
+
+func heapInUse() uint64 {
+	runtime.GC()
+	var ms runtime.MemStats
+	runtime.ReadMemStats(&ms)
+	return ms.HeapAlloc
+}
+
+// A log on disk is viewed, scrolled and searched end to end while only a
+// window of it is ever held in memory
+func TestLogFileBoundedMemory(t *testing.T) {
+	if testing.Short() {
+		t.Skip("writes a 100MB log")
+	}
+	path := filepath.Join(t.TempDir(), "stream.log")
+	total := writeLog(t, path, 100<<20)
+	before := heapInUse()
+
+	v := newLogView()
+	v.SetSize(120, 40)
+	if err := v.SetFile(path); err != nil {
+		t.Fatal(err)
+	}
+	if v.total() != total {
+		t.Fatalf("indexed %d lines, want %d", v.total(), total)
+	}
+
+	for _, line := range []int{total / 2, total - 1, 0, total - logWindowLines/3} {
+		v.showLine(line)
+		if !v.inWindow(line) || len(v.lines) > logWindowLines {
+			t.Fatalf("line %d: window %d+%d", line, v.first, len(v.lines))
+		}
+	}
+	want := fmt.Sprintf("processed entry %09d ", total-1)
+	v.showLine(total - 1)
+	if got := v.lines[total-1-v.first]; !strings.Contains(got, want) {
+		t.Errorf("last line %q, want it to have %q", got, want)
+	}
+
+	cmd := v.search("error")
+	for cmd != nil {
+		cmd = v.searchBatch(cmd().(logSearchMsg))
+	}
+	if len(v.matches) != total/1000 {
+		t.Errorf("found %d matches, want %d", len(v.matches), total/1000)
+	}
+
+	// The index, the window and the matches; nowhere near the log's size
+	if grown := int64(heapInUse()) - int64(before); grown > 16<<20 {
+		t.Errorf("the view holds %d MB of a 100 MB log", grown>>20)
+	}
+	runtime.KeepAlive(v)
+}
@@ -0,0 +1,379 @@
+package main
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// logEvent is a CloudWatch log event as returned by the aws CLI
+type logEvent struct {
+	LogStreamName string `json:"logStreamName"`
+	Timestamp     int64  `json:"timestamp"`
+	Message       string `json:"message"`
+}
+
+// logStream is a candidate log stream of the function's log group
+type logStream struct {
+	Name         string
+	FirstEvent   time.Time
+	LastEvent    time.Time
+	HasRequestID bool
+}
+
+type logStreamItem struct {
+	stream logStream
+}
+
+func (i logStreamItem) Title() string {
+	if i.stream.HasRequestID {
+		return i.stream.Name + " (contains this invocation)"
+	}
+	return i.stream.Name
+}
+
+func (i logStreamItem) Description() string {
+	return fmt.Sprintf("first event %s, last event %s",
+		i.stream.FirstEvent.Local().Format(time.DateTime),
+		i.stream.LastEvent.Local().Format(time.DateTime))
+}
+
+func (i logStreamItem) FilterValue() string { return i.stream.Name }
+
+// logStreamsMsg carries the candidate log streams for the stream picker
+type logStreamsMsg struct {
+	streams []logStream
+	err     error
+}
+
+// logStreamEventsMsg carries the full logs of the picked stream, written to
+// the file at path, and their last lines
+type logStreamEventsMsg struct {
+	stream string
+	path   string
+	tail   string
+	lines  int
+	err    error
+}
+
+func logGroupName(functionName string) string {
+	return fmt.Sprintf("/aws/lambda/%s", functionName)
+}
+
+// awsLogs runs an `aws logs` subcommand and decodes its JSON output into out
+func awsLogs(profile string, out interface{}, args ...string) error {
+	args = append([]string{"logs"}, args...)
+	args = append(args, "--profile", profile, "--output", "json")
+	args = append(args, regionArgs(profile)...)
+	data, err := exec.Command("aws", args...).Output()
+	if err != nil {
+		return fmt.Errorf("failed to fetch logs: %v", err)
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("failed to parse logs: %v", err)
+	}
+	return nil
+}
+
+func joinLogEvents(events []logEvent) string {
+	var sb strings.Builder
+	for _, e := range events {
+		sb.WriteString(strings.TrimRight(e.Message, "\n"))
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
+
+// fetchLogStreamsCmd lists the most recently written log streams of the
+// function, marking the one containing requestID if a quick filter finds it
+func fetchLogStreamsCmd(env, functionName, requestID string, since time.Time) tea.Cmd {
+	return func() tea.Msg {
+		streams, err := fetchLogStreams(profileMap[env], functionName, requestID, since)
+		return logStreamsMsg{streams: streams, err: err}
+	}
+}
+
+func fetchLogStreams(profile, functionName, requestID string, since time.Time) ([]logStream, error) {
+	var resp struct {
+		LogStreams []struct {
+			LogStreamName       string `json:"logStreamName"`
+			FirstEventTimestamp int64  `json:"firstEventTimestamp"`
+			LastEventTimestamp  int64  `json:"lastEventTimestamp"`
+		} `json:"logStreams"`
+	}
+	err := awsLogs(profile, &resp, "describe-log-streams",
+		"--log-group-name", logGroupName(functionName),
+		"--order-by", "LastEventTime",
+		"--descending",
+		"--max-items", "20",
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	requestStream := ""
+	if requestID != "" {
+		var found struct {
+			Events []logEvent `json:"events"`
+		}
+		// Not finding the stream is fine, the user can still pick one
+		if awsLogs(profile, &found, "filter-log-events",
+			"--log-group-name", logGroupName(functionName),
+			"--start-time", strconv.FormatInt(since.Add(-time.Minute).UnixMilli(), 10),
+			"--filter-pattern", fmt.Sprintf("%q", requestID),
+			"--max-items", "1",
+		) == nil && len(found.Events) > 0 {
+			requestStream = found.Events[0].LogStreamName
+		}
+	}
+
+	streams := make([]logStream, 0, len(resp.LogStreams))
+	for _, s := range resp.LogStreams {
+		streams = append(streams, logStream{
+			Name:         s.LogStreamName,
+			FirstEvent:   time.UnixMilli(s.FirstEventTimestamp),
+			LastEvent:    time.UnixMilli(s.LastEventTimestamp),
+			HasRequestID: s.LogStreamName == requestStream,
+		})
+	}
+	return streams, nil
+}
+
+// fetchLogStreamEventsCmd fetches every event of a log stream into a temp
+// file
+func fetchLogStreamEventsCmd(env, functionName, stream string) tea.Cmd {
+	return func() tea.Msg {
+		msg := logStreamEventsMsg{stream: stream}
+		f, err := createLogFile()
+		if err != nil {
+			msg.err = err
+			return msg
+		}
+		msg.lines, msg.tail, msg.err = streamLogEvents(profileMap[env], functionName, stream, f)
+		if err := f.Close(); err != nil && msg.err == nil {
+			msg.err = fmt.Errorf("failed to write logs: %v", err)
+		}
+		if msg.err != nil {
+			removeLogFile(f.Name())
+			return msg
+		}
+		msg.path = f.Name()
+		return msg
+	}
+}
+
+// logsSince and logsMaxLines bound the full logs fetched after an invoke,
+// set by the logs section of the config
+var (
+	logsSince    = 10 * time.Minute
+	logsMaxLines = logWindowLines
+)
+
+func setupLogs(cfg LogsConfig) error {
+	logsSince, logsMaxLines = 10*time.Minute, logWindowLines
+	if cfg.Since != "" {
+		d, err := time.ParseDuration(cfg.Since)
+		if err != nil || d <= 0 {
+			return configErrorf([]string{"logs", "since"}, "expected a positive duration like 10m, got %q", cfg.Since)
+		}
+		logsSince = d
+	}
+	if cfg.MaxLines < 0 {
+		return configErrorf([]string{"logs", "max_lines"}, "expected a positive number, got %d", cfg.MaxLines)
+	}
+	if cfg.MaxLines > 0 {
+		logsMaxLines = cfg.MaxLines
+	}
+	return nil
+}
+
+// lambdaLogsMsg carries the logs of the function's whole log group over the
+// configured window, written to the file at path, and their last lines.
+// exhausted is set when every line of the window was fetched, rather than
+// stopping at the line cap.
+type lambdaLogsMsg struct {
+	path      string
+	tail      string
+	lines     int
+	exhausted bool
+	err       error
+}
+
+// note describes how much of the window was fetched
+func (msg lambdaLogsMsg) note() string {
+	if msg.exhausted {
+		return fmt.Sprintf("%d lines from the last %s, the whole window", msg.lines, logsSince)
+	}
+	return fmt.Sprintf("%d lines from the last %s, stopped at the %d line cap", msg.lines, logsSince, logsMaxLines)
+}
+
+// fetchLambdaLogsCmd fetches the function's logs over the configured window
+// into a temp file
+func fetchLambdaLogsCmd(env, functionName string) tea.Cmd {
+	return func() tea.Msg {
+		var msg lambdaLogsMsg
+		f, err := createLogFile()
+		if err != nil {
+			msg.err = err
+			return msg
+		}
+		msg.lines, msg.tail, msg.exhausted, msg.err = fetchLambdaLogs(profileMap[env], functionName, time.Now().Add(-logsSince), logsMaxLines, f)
+		if err := f.Close(); err != nil && msg.err == nil {
+			msg.err = fmt.Errorf("failed to write logs: %v", err)
+		}
+		if msg.err != nil {
+			removeLogFile(f.Name())
+			return msg
+		}
+		msg.path = f.Name()
+		return msg
+	}
+}
+
+// recentErrorsWindow and recentErrorsLimit bound the error preview shown
+// before invoking
+const (
+	recentErrorsWindow = 30 * time.Minute
+	recentErrorsLimit  = 10
+)
+
+// recentErrorsMsg carries the latest ERROR lines of a function
+type recentErrorsMsg struct {
+	env    string
+	events []logEvent
+	err    error
+}
+
+// fetchRecentErrorsCmd looks for ERROR lines logged by the function recently,
+// keeping the last few
+func fetchRecentErrorsCmd(env, functionName string) tea.Cmd {
+	return func() tea.Msg {
+		var resp struct {
+			Events []logEvent `json:"events"`
+		}
+		err := awsLogs(profileMap[env], &resp, "filter-log-events",
+			"--log-group-name", logGroupName(functionName),
+			"--start-time", strconv.FormatInt(time.Now().Add(-recentErrorsWindow).UnixMilli(), 10),
+			"--filter-pattern", "ERROR",
+		)
+		events := resp.Events
+		if len(events) > recentErrorsLimit {
+			events = events[len(events)-recentErrorsLimit:]
+		}
+		return recentErrorsMsg{env: env, events: events, err: err}
+	}
+}
+
+// formatLogEvent renders the first line of an event prefixed by its local time
+func formatLogEvent(e logEvent) string {
+	msg, _, _ := strings.Cut(strings.TrimSpace(e.Message), "\n")
+	return time.UnixMilli(e.Timestamp).Local().Format(time.TimeOnly) + "  " + msg
+}
+
+// jsonLogLine covers both application and platform lines of a function using
+// the JSON log format
+type jsonLogLine struct {
+	Timestamp string          `json:"timestamp"`
+	Time      string          `json:"time"`
+	Level     string          `json:"level"`
+	Type      string          `json:"type"`
+	Message   json.RawMessage `json:"message"`
+	Record    json.RawMessage `json:"record"`
+}
+
+// formatJSONLogTail renders a JSON log format tail as level, timestamp and
+// message columns. Lines that aren't JSON objects are kept as they are.
+func formatJSONLogTail(tail string) string {
+	var sb strings.Builder
+	for _, line := range strings.Split(strings.TrimRight(tail, "\n"), "\n") {
+		var l jsonLogLine
+		if err := json.Unmarshal([]byte(line), &l); err != nil || !strings.HasPrefix(strings.TrimSpace(line), "{") {
+			sb.WriteString(line + "\n")
+			continue
+		}
+		level, ts, msg := cmp.Or(l.Level, l.Type), cmp.Or(l.Timestamp, l.Time), l.Message
+		if len(msg) == 0 {
+			msg = l.Record
+		}
+		// Messages are usually strings, anything else is shown as compact JSON
+		var text string
+		if json.Unmarshal(msg, &text) != nil {
+			text = string(msg)
+		}
+		sb.WriteString(fmt.Sprintf("%-16s %-24s %s\n", level, ts, text))
+	}
+	return sb.String()
+}
+
+// fetchRequestLogs fetches the log events of requestID in the window after
+// startedAt, or every event in the window when the ID is unknown
+func fetchRequestLogs(profile, functionName, requestID string, startedAt time.Time) (string, error) {
+	start := startedAt.Add(-time.Minute)
+	args := []string{"filter-log-events",
+		"--log-group-name", logGroupName(functionName),
+		"--start-time", strconv.FormatInt(start.UnixMilli(), 10),
+		"--end-time", strconv.FormatInt(start.Add(20*time.Minute).UnixMilli(), 10),
+	}
+	if requestID != "" {
+		args = append(args, "--filter-pattern", fmt.Sprintf("%q", requestID))
+	}
+
+	var resp struct {
+		Events []logEvent `json:"events"`
+	}
+	if err := awsLogs(profile, &resp, args...); err != nil {
+		return "", err
+	}
+	return joinLogEvents(resp.Events), nil
+}
+
+// logRetryDelays are the waits before each CloudWatch fetch of logs missing
+// from an invocation's tail, which can lag behind on delivery
+var logRetryDelays = []time.Duration{2 * time.Second, 5 * time.Second}
+
+// logRetryMsg is sent when it is time for the given fetch attempt
+type logRetryMsg struct {
+	gen, attempt int
+}
+
+// logRetryResultMsg carries the outcome of a fetch attempt
+type logRetryResultMsg struct {
+	gen, attempt int
+	logs         string
+	err          error
+}
+
+// scheduleLogRetry waits before a fetch attempt. gen ties the attempt to the
+// invocation it is for, so attempts outliving it can be dropped.
+func scheduleLogRetry(gen, attempt int) tea.Cmd {
+	return tea.Tick(logRetryDelays[attempt], func(time.Time) tea.Msg {
+		return logRetryMsg{gen: gen, attempt: attempt}
+	})
+}
+
+func fetchRequestLogsCmd(gen, attempt int, inv *invocation) tea.Cmd {
+	return func() tea.Msg {
+		logs, err := fetchRequestLogs(profileMap[inv.Env], inv.FunctionName, inv.RequestID, inv.StartedAt)
+		return logRetryResultMsg{gen: gen, attempt: attempt, logs: logs, err: err}
+	}
+}
+
+// awaitLogDelivery fetches the logs of an invocation whose tail came back
+// empty, retrying after each delay until some arrive
+func awaitLogDelivery(inv *invocation, out io.Writer) string {
+	for _, delay := range logRetryDelays {
+		fmt.Fprintln(out, "Waiting for log delivery...")
+		time.Sleep(delay)
+		if logs, err := fetchRequestLogs(profileMap[inv.Env], inv.FunctionName, inv.RequestID, inv.StartedAt); err == nil && strings.TrimSpace(logs) != "" {
+			return logs
+		}
+	}
+	return ""
+}
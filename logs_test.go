@@ -0,0 +1,19 @@
+package main
+
+import "testing"
+
+func TestFormatJSONLogTail(t *testing.T) {
+	tail := string(readFixture(t, "logtail/json.log"))
+	want := string(readFixture(t, "logtail/json.golden"))
+	if got := formatJSONLogTail(tail); got != want {
+		t.Errorf("rendered\n%s\nwant\n%s", got, want)
+	}
+}
+
+// A text format tail has no JSON objects to render, so it comes back as is
+func TestFormatJSONLogTailText(t *testing.T) {
+	tail := string(readFixture(t, "logtail/text.log"))
+	if got := formatJSONLogTail(tail); got != tail {
+		t.Errorf("rendered\n%s\nwant it unchanged", got)
+	}
+}
@@ -0,0 +1,464 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/textinput"
+	"github.com/charmbracelet/bubbles/viewport"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// logViewChrome is the number of lines the log view uses around its viewport
+const logViewChrome = 2
+
+// logView is a scrollable view of invocation logs. The logical lines are the
+// content model; numbering, wrapping and search all refer to them, while the
+// rendered rows only exist for the viewport. Logs on disk are viewed through
+// a window of their lines, moved as the viewport scrolls past its edges.
+type logView struct {
+	lines []string
+	// file backs the lines when set, and first is the 0-based line of the
+	// file lines starts at
+	file    *logFile
+	first   int
+	numbers bool
+	wrap    bool
+	width   int
+	// raw is the invocation tail SetContent was given, shown with its
+	// multi-line JSON events collapsed unless expandJSON is set
+	raw        string
+	expandJSON bool
+
+	// rowOf is the first rendered row of each logical line
+	rowOf    []int
+	viewport viewport.Model
+
+	// input is the search or goto prompt, active while inputMode is set
+	input     textinput.Model
+	inputMode string
+	// matches are 0-based lines of the whole logs
+	matches []int
+	match   int
+	status  string
+	// searchGen identifies the running file search, searching is set while
+	// its batches are still coming back and jumped once it showed a match
+	searchGen int
+	searching bool
+	jumped    bool
+	query     string
+	// note says how much of the log window was fetched, when it was
+	note string
+}
+
+func newLogView() logView {
+	ti := textinput.New()
+	ti.CharLimit = 100
+	ti.Width = 40
+	return logView{wrap: true, viewport: viewport.New(0, 0), input: ti}
+}
+
+// SetContent replaces the logs, keeping the display options
+func (v *logView) SetContent(logs string) {
+	v.raw = logs
+	v.lines = strings.Split(strings.TrimRight(strings.ReplaceAll(groupJSONEvents(logs, v.expandJSON), "\t", "    "), "\n"), "\n")
+	v.file, v.first = nil, 0
+	v.matches, v.match, v.status = nil, 0, ""
+	v.searchGen++
+	v.searching = false
+	v.render()
+	v.viewport.GotoTop()
+}
+
+// SetFile views logs on disk, holding only a window of their lines
+func (v *logView) SetFile(path string) error {
+	lf, err := indexLogFile(path)
+	if err != nil {
+		return err
+	}
+	lines, err := lf.readLines(0, logWindowLines)
+	if err != nil {
+		return err
+	}
+	v.lines, v.file, v.first = lines, lf, 0
+	if len(v.lines) == 0 {
+		v.lines = []string{""}
+	}
+	v.matches, v.match, v.status = nil, 0, ""
+	v.searchGen++
+	v.searching = false
+	v.render()
+	v.viewport.GotoTop()
+	return nil
+}
+
+// total is the number of lines of the whole logs
+func (v logView) total() int {
+	if v.file != nil {
+		return v.file.total
+	}
+	return len(v.lines)
+}
+
+// loadWindow reads the window of the file starting near line first,
+// reporting whether it moved
+func (v *logView) loadWindow(first int) bool {
+	first = max(0, min(first, v.file.total-logWindowLines))
+	if first == v.first {
+		return false
+	}
+	lines, err := v.file.readLines(first, logWindowLines)
+	if err != nil {
+		v.status = err.Error()
+		return false
+	}
+	v.lines, v.first = lines, first
+	v.render()
+	return true
+}
+
+// moveWindow loads the window of the file starting near line first, keeping
+// the line at the top of the viewport in place
+func (v *logView) moveWindow(first int) {
+	top := v.first + v.topLine()
+	if v.loadWindow(first) {
+		v.scrollTo(top - v.first)
+	}
+}
+
+// inWindow reports whether a 0-based line of the whole logs is held in memory
+func (v logView) inWindow(line int) bool {
+	return line >= v.first && line < v.first+len(v.lines)
+}
+
+// showLine scrolls a 0-based line of the whole logs to the top of the
+// viewport, loading its window first when needed
+func (v *logView) showLine(line int) {
+	if !v.inWindow(line) {
+		v.loadWindow(line - logWindowLines/2)
+	}
+	v.scrollTo(line - v.first)
+}
+
+func (v *logView) SetSize(width, height int) {
+	v.width = width
+	v.viewport.Width = width
+	v.viewport.Height = max(1, height-logViewChrome)
+	v.render()
+}
+
+// inputting reports whether the search or goto prompt has the keyboard
+func (v logView) inputting() bool {
+	return v.inputMode != ""
+}
+
+// render lays the logical lines out as rows for the viewport, keeping the
+// row of each logical line so positions survive numbering and wrap changes
+func (v *logView) render() {
+	if v.width <= 0 {
+		return
+	}
+	gutter := 0
+	if v.numbers {
+		gutter = len(strconv.Itoa(v.total())) + 3
+	}
+	textWidth := max(1, v.width-gutter)
+
+	current := -1
+	if len(v.matches) > 0 {
+		current = v.matches[v.match]
+	}
+
+	var rows []string
+	v.rowOf = make([]int, len(v.lines))
+	for i, line := range v.lines {
+		v.rowOf[i] = len(rows)
+		for j, part := range splitRow(line, textWidth, v.wrap) {
+			if v.first+i == current {
+				part = logMatchStyle.Render(part)
+			}
+			if v.numbers {
+				number := ""
+				if j == 0 {
+					number = strconv.Itoa(v.first + i + 1)
+				}
+				part = logGutterStyle.Render(fmt.Sprintf("%*s %s ", gutter-3, number, glyphs.Rule)) + part
+			}
+			rows = append(rows, part)
+		}
+	}
+
+	offset := v.viewport.YOffset
+	v.viewport.SetContent(strings.Join(rows, "\n"))
+	v.viewport.SetYOffset(offset)
+}
+
+// splitRow wraps a line to width, or cuts it off when not wrapping
+func splitRow(line string, width int, wrap bool) []string {
+	runes := []rune(line)
+	if len(runes) <= width {
+		return []string{line}
+	}
+	if !wrap {
+		return []string{string(runes[:width-1]) + glyphs.Ellipsis}
+	}
+	var parts []string
+	for len(runes) > width {
+		parts = append(parts, string(runes[:width]))
+		runes = runes[width:]
+	}
+	return append(parts, string(runes))
+}
+
+// topLine is the logical line of the window shown at the top of the viewport
+func (v logView) topLine() int {
+	for i := len(v.rowOf) - 1; i >= 0; i-- {
+		if v.rowOf[i] <= v.viewport.YOffset {
+			return i
+		}
+	}
+	return 0
+}
+
+// scrollTo puts a 0-based logical line of the window at the top of the viewport
+func (v *logView) scrollTo(line int) {
+	if line >= 0 && line < len(v.rowOf) {
+		v.viewport.SetYOffset(v.rowOf[line])
+	}
+}
+
+// gotoLine scrolls a 1-based logical line to the top of the viewport
+func (v *logView) gotoLine(n int) {
+	if n < 1 || n > v.total() {
+		v.status = fmt.Sprintf("No line %d, the logs have %d lines", n, v.total())
+		return
+	}
+	v.showLine(n - 1)
+	v.status = fmt.Sprintf("Line %d", n)
+}
+
+// search finds the lines containing query, case insensitively, and jumps to
+// the first match from the top of the viewport. Logs on disk are searched in
+// batches, their matches shown as they come back.
+func (v *logView) search(query string) tea.Cmd {
+	v.matches, v.match = nil, 0
+	v.query = query
+	needle := strings.ToLower(query)
+	v.searchGen++
+	if v.file != nil {
+		v.searching, v.jumped = true, false
+		v.status = fmt.Sprintf("Searching for %q...", query)
+		v.render()
+		return searchLogFileCmd(v.file.path, needle, v.searchGen, 0, 0)
+	}
+	v.searching = false
+	for i, line := range v.lines {
+		if strings.Contains(strings.ToLower(line), needle) {
+			v.matches = append(v.matches, i)
+		}
+	}
+	if len(v.matches) == 0 {
+		v.status = fmt.Sprintf("No matches for %q", query)
+		v.render()
+		return nil
+	}
+	top := v.topLine()
+	for i, line := range v.matches {
+		if line >= top {
+			v.match = i
+			break
+		}
+	}
+	v.showMatch()
+	return nil
+}
+
+// searchBatch adds the matches of a batch of a file search, jumping to the
+// first match from the top of the viewport once one is found
+func (v *logView) searchBatch(msg logSearchMsg) tea.Cmd {
+	if msg.gen != v.searchGen || !v.searching {
+		return nil
+	}
+	if msg.err != nil {
+		v.searching = false
+		v.status = fmt.Sprintf("Search failed: %v", msg.err)
+		return nil
+	}
+	room := logMaxMatches - len(v.matches)
+	v.matches = append(v.matches, msg.matches[:min(room, len(msg.matches))]...)
+	capped := len(v.matches) >= logMaxMatches
+	v.searching = !msg.done && !capped
+
+	if !v.jumped && len(v.matches) > 0 {
+		top := v.first + v.topLine()
+		jump := -1
+		for i, line := range v.matches {
+			if line >= top {
+				jump = i
+				break
+			}
+		}
+		// Matches above the top only count once the whole file is searched
+		if jump < 0 && !v.searching {
+			jump = 0
+		}
+		if jump >= 0 {
+			v.match, v.jumped = jump, true
+			v.showMatch()
+		}
+	}
+	switch {
+	case len(v.matches) == 0 && !v.searching:
+		v.status = fmt.Sprintf("No matches for %q", v.query)
+	case v.jumped:
+		v.matchStatus()
+		if capped {
+			v.status += fmt.Sprintf(", stopped at %d matches", logMaxMatches)
+		}
+	default:
+		v.status = fmt.Sprintf("Searching for %q, line %d of %d...", v.query, msg.line, v.total())
+	}
+	if !v.searching {
+		return nil
+	}
+	return searchLogFileCmd(v.file.path, strings.ToLower(v.query), v.searchGen, msg.offset, msg.line)
+}
+
+// showMatch scrolls to the current match
+func (v *logView) showMatch() {
+	line := v.matches[v.match]
+	if !v.inWindow(line) {
+		v.loadWindow(line - logWindowLines/2)
+	}
+	v.render()
+	v.scrollTo(line - v.first)
+	v.matchStatus()
+}
+
+// matchStatus reports the current match and where the matches are
+func (v *logView) matchStatus() {
+	line := v.matches[v.match]
+	const listed = 8
+	numbers := make([]string, 0, listed)
+	for _, l := range v.matches[:min(listed, len(v.matches))] {
+		numbers = append(numbers, strconv.Itoa(l+1))
+	}
+	more := ""
+	if len(v.matches) > listed || v.searching {
+		more = ", ..."
+	}
+	v.status = fmt.Sprintf("Match %d/%d on line %d (lines %s%s)",
+		v.match+1, len(v.matches), line+1, strings.Join(numbers, ", "), more)
+}
+
+func (v logView) Update(msg tea.Msg) (logView, tea.Cmd) {
+	if msg, ok := msg.(logSearchMsg); ok {
+		return v, v.searchBatch(msg)
+	}
+	keyMsg, isKey := msg.(tea.KeyMsg)
+	if !isKey {
+		var cmd tea.Cmd
+		v.viewport, cmd = v.viewport.Update(msg)
+		return v, cmd
+	}
+
+	if v.inputting() {
+		switch keyMsg.Type {
+		case tea.KeyEnter:
+			value := strings.TrimSpace(v.input.Value())
+			mode := v.inputMode
+			v.inputMode = ""
+			v.input.Blur()
+			if value == "" {
+				return v, nil
+			}
+			if mode == ":" {
+				n, err := strconv.Atoi(value)
+				if err != nil {
+					v.status = fmt.Sprintf("%q is not a line number", value)
+					return v, nil
+				}
+				v.gotoLine(n)
+				return v, nil
+			}
+			return v, v.search(value)
+		case tea.KeyEsc:
+			v.inputMode = ""
+			v.input.Blur()
+			return v, nil
+		}
+		var cmd tea.Cmd
+		v.input, cmd = v.input.Update(msg)
+		return v, cmd
+	}
+
+	switch keyMsg.String() {
+	case "#":
+		top := v.topLine()
+		v.numbers = !v.numbers
+		v.render()
+		v.scrollTo(top)
+		return v, nil
+	case "w":
+		top := v.topLine()
+		v.wrap = !v.wrap
+		v.render()
+		v.scrollTo(top)
+		return v, nil
+	case "J":
+		// Logs on disk are whole streams, only the tail is grouped
+		if v.file != nil {
+			v.status = tr(msgLogViewJSONTail)
+			return v, nil
+		}
+		v.expandJSON = !v.expandJSON
+		v.SetContent(v.raw)
+		return v, nil
+	case ":", "/":
+		v.inputMode = keyMsg.String()
+		v.input.Prompt = keyMsg.String()
+		v.input.SetValue("")
+		return v, v.input.Focus()
+	case "n", "N":
+		if len(v.matches) == 0 {
+			return v, nil
+		}
+		step := 1
+		if keyMsg.String() == "N" {
+			step = len(v.matches) - 1
+		}
+		v.match = (v.match + step) % len(v.matches)
+		v.showMatch()
+		return v, nil
+	}
+
+	// Scrolling past the edges of a file's window moves it a chunk along
+	if v.file != nil {
+		keys := v.viewport.KeyMap
+		switch {
+		case v.viewport.AtTop() && key.Matches(keyMsg, keys.Up, keys.PageUp, keys.HalfPageUp):
+			v.moveWindow(v.first - logChunkLines)
+		case v.viewport.AtBottom() && key.Matches(keyMsg, keys.Down, keys.PageDown, keys.HalfPageDown):
+			v.moveWindow(v.first + logChunkLines)
+		}
+	}
+
+	var cmd tea.Cmd
+	v.viewport, cmd = v.viewport.Update(msg)
+	return v, cmd
+}
+
+func (v logView) View() string {
+	footer := v.status
+	if v.inputting() {
+		footer = v.input.View()
+	}
+	position := fmt.Sprintf("line %d/%d", min(v.first+v.topLine()+1, v.total()), v.total())
+	if v.note != "" {
+		position += ", " + v.note
+	}
+	help := tr(msgLogViewHelp, keys.help(keyBack))
+	return v.viewport.View() + "\n" + logGutterStyle.Render(position) + "  " + footer + "\n" + logGutterStyle.Render(help)
+}
@@ -2,11 +2,12 @@ package main
 
 import (
 	"context"
-	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"os/exec"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -14,11 +15,14 @@ import (
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/lambda"
+	"github.com/aws/aws-sdk-go-v2/service/lambda/types"
+	"github.com/charmbracelet/bubbles/key"
 	"github.com/charmbracelet/bubbles/list"
 	"github.com/charmbracelet/bubbles/spinner"
 	"github.com/charmbracelet/bubbles/textinput"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
+	"github.com/revrost/playtools/pkg/playtools"
 )
 
 // Constants
@@ -28,44 +32,235 @@ const (
 	prodEnv    = "prod"
 )
 
-// Profile mapping
-var profileMap = map[string]string{
-	devEnv:     "platform-dev-engineer",
-	nonProdEnv: "platform-nonprod-engineer",
-	prodEnv:    "platform-prod-engineer", // Adjust this if your prod profile is different
+// Profile mapping, starting from the built-in profiles of the playtools
+// package
+var profileMap = playtools.DefaultProfiles()
+
+// protectedEnvs are the environments with prod's safety behaviors: the
+// daily budget, default dry runs, typed acknowledgments, shadow runs and the
+// idle lock. The config turns them on or off per environment.
+var protectedEnvs = map[string]bool{prodEnv: true}
+
+// envDescriptions are the config's descriptions of the environments
+var envDescriptions = map[string]string{}
+
+// isProtected reports whether env has prod's safety behaviors
+func isProtected(env string) bool {
+	return protectedEnvs[env]
 }
 
+// pinnedEnv restricts the whole session to a single environment when set,
+// via --pin-env or PLAYTOOLS_PIN_ENV
+var pinnedEnv string
+
 var apiHostmap = map[string]string{
 	devEnv:     "https://api.dev.immutable.com/",
 	nonProdEnv: "https://api.sandbox.immutable.com",
 	prodEnv:    "https://api.prod.immutable.com",
 }
 
-const sweepstakeFunctionName = "imx-rewards-%s-sweepstake-rewards-calculator"
+const builtinFunctionTemplate = playtools.DefaultFunctionTemplate
+
+// sweepstakeFunctionName is the function name template, the environment
+// filling its %s. PLAYTOOLS_FUNCTION_TEMPLATE replaces it.
+var sweepstakeFunctionName = builtinFunctionTemplate
+
+// functionMap holds the full function names the config sets per
+// environment, which win over the template
+var functionMap = map[string]string{}
+
+// sweepstakeFunction is the name of env's sweepstake function
+func sweepstakeFunction(env string) string {
+	if name := functionMap[env]; name != "" {
+		return name
+	}
+	return fmt.Sprintf(sweepstakeFunctionName, env)
+}
 
-type Action string
+// Action and EventPayload are the payload types of the playtools package
+type (
+	Action       = playtools.Action
+	EventPayload = playtools.EventPayload
+)
 
 const (
-	// ActionProcess processes the calculation sweepstake quest but not the distribution of rewards
-	ActionProcess Action = "process"
-	// ActionComplete completes a sweepstake quest and distributes rewards
-	ActionComplete Action = "complete"
-	// ActionStart starts a new sweepstake quest
-	ActionStart Action = "start"
+	ActionProcess  = playtools.ActionProcess
+	ActionComplete = playtools.ActionComplete
+	ActionStart    = playtools.ActionStart
+	ActionRollback = playtools.ActionRollback
+	ActionStatus   = playtools.ActionStatus
 )
 
-// EventPayload is the payload request for the lambda function
-type EventPayload struct {
-	Action Action `json:"action"`
+// batchAction is the action list entry that processes the quests of a CSV
+// file instead of invoking a single lambda action
+const batchAction = "batch"
+
+// repeatAction is the action list entry, pinned to the top once something has
+// been invoked, that confirms the last run's payload again
+const repeatAction = "repeat"
+
+// payloadValue is the value playtools.NewPayload was given for a payload
+func payloadValue(payload EventPayload) string {
+	switch {
+	case payload.DurationMinutes != nil:
+		return strconv.Itoa(*payload.DurationMinutes)
+	case payload.SweepstakeQuestID != nil:
+		return strconv.Itoa(*payload.SweepstakeQuestID)
+	}
+	return ""
+}
+
+// dryRunApplies reports whether the DryRun field is meaningful for an action
+func dryRunApplies(action Action) bool {
+	return action == ActionProcess || action == ActionStart
+}
+
+// dryRunDefaults are the environments whose config sets whether the actions
+// dry run applies to start out as dry runs
+var dryRunDefaults = map[string]bool{}
+
+// defaultDryRun is whether an action starts out as a dry run in an environment.
+// Starting a sweepstake in prod is validated first unless the config says
+// otherwise for the environment.
+func defaultDryRun(env string, action Action) bool {
+	if !dryRunApplies(action) {
+		return false
+	}
+	if on, ok := dryRunDefaults[env]; ok {
+		return on
+	}
+	return isProtected(env) && action == ActionStart
+}
+
+// promptDryRun is whether an action entered in the TUI starts out as a dry
+// run. A process does unless the config says otherwise for the environment,
+// so calculation state is only written when the toggle is turned off.
+func promptDryRun(env string, action Action) bool {
+	if _, ok := dryRunDefaults[env]; !ok && action == ActionProcess {
+		return true
+	}
+	return defaultDryRun(env, action)
+}
+
+// dryRunNote says what a payload's dry run setting does, nothing for the
+// actions it doesn't apply to
+func dryRunNote(payload EventPayload) string {
+	switch {
+	case !dryRunApplies(payload.Action):
+		return ""
+	case payload.DryRun && payload.Action == ActionStart:
+		return "validation only " + glyphs.Dash + " no sweepstake created"
+	case payload.DryRun:
+		return "no calculation state is written"
+	case payload.Action == ActionStart:
+		return "the sweepstake is created"
+	default:
+		return "calculation state is written"
+	}
+}
+
+// dryRunToggle renders the dry run setting as a yes/no toggle
+func dryRunToggle(on bool) string {
+	yes, no := formButtonStyle.Render(" yes "), formButtonFocusStyle.Render(" no ")
+	if on {
+		yes, no = formButtonFocusStyle.Render(" yes "), formButtonStyle.Render(" no ")
+	}
+	return yes + " " + no
+}
+
+// dryRunBadge calls out a payload's dry run setting on the loading and
+// output screens
+func dryRunBadge(payload EventPayload) string {
+	if !dryRunApplies(payload.Action) {
+		return ""
+	}
+	if payload.DryRun {
+		return pinnedStyle.Render("DRY RUN") + " " + dryRunNote(payload)
+	}
+	return budgetWarnStyle.Render("LIVE RUN") + " " + dryRunNote(payload)
+}
+
+// parsePositive validates a prompt value that must be a positive number,
+// with the message the prompt shows when it isn't
+func parsePositive(s string) (int, error) {
+	n, err := strconv.Atoi(s)
+	if err != nil || n <= 0 {
+		return 0, errors.New(tr(msgInvalidPositive))
+	}
+	return n, nil
+}
+
+// parseBatchSize reads an optional batch size, nil when left blank so the
+// lambda's default applies
+func parseBatchSize(s string) (*int, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return nil, nil
+	}
+	n, err := parsePositive(s)
+	if err != nil {
+		return nil, fmt.Errorf("batch size: %v", err)
+	}
+	if limit := toolRegistry[sweepstakeTool].MaxBatchSize; n > limit {
+		return nil, fmt.Errorf("batch size %d is over the cap of %d, tools.%s.max_batch_size", n, limit, sweepstakeTool)
+	}
+	return &n, nil
+}
 
-	// DryRun is only applicable for process action
-	// DryRun            bool `json:"dry_run"`
-	SweepstakeQuestID *int `json:"sweepstake_quest_id"`
-	// BatchSize         *int `json:"batch_size,omitempty"`
+// maxDurationDays caps the duration of a start, well past any real
+// sweepstake and far from overflowing a time.Duration
+const maxDurationDays = 365
+
+// parseDurationMinutes reads the duration of a start in minutes, as a plain
+// number of minutes or a duration like 48h, 2h30m or 7d
+func parseDurationMinutes(s string) (int, error) {
+	s = strings.TrimSpace(s)
+	const maxDuration = maxDurationDays * 24 * time.Hour
+	tooLong := errors.New(tr(msgDurationTooLong, maxDurationDays))
+	if _, err := strconv.Atoi(s); err == nil {
+		n, err := parsePositive(s)
+		if err == nil && n > int(maxDuration/time.Minute) {
+			return 0, tooLong
+		}
+		return n, err
+	}
+	// time.ParseDuration stops at hours, so days are counted first, capped
+	// before they are multiplied
+	var d time.Duration
+	if days, rest, ok := strings.Cut(s, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil || n < 0 {
+			return 0, errors.New(tr(msgInvalidDuration))
+		}
+		if n > maxDurationDays {
+			return 0, tooLong
+		}
+		d, s = time.Duration(n)*24*time.Hour, rest
+	}
+	if s != "" {
+		rest, err := time.ParseDuration(s)
+		if err != nil || rest < 0 {
+			return 0, errors.New(tr(msgInvalidDuration))
+		}
+		if rest > maxDuration-d {
+			return 0, tooLong
+		}
+		d += rest
+	}
+	if d <= 0 {
+		return 0, errors.New(tr(msgInvalidDuration))
+	}
+	if d%time.Minute != 0 {
+		return 0, errors.New(tr(msgDurationMinutes, d))
+	}
+	return int(d / time.Minute), nil
+}
 
-	// DurationMinutes Optional fields for action = start
-	// DurationMinutes     *int             `json:"duration_minutes,omitempty"`
-	// SweepstakeOverrides *json.RawMessage `json:"sweepstake_overrides,omitempty"`
+// durationEcho spells out the duration of a start and when it would end if
+// started at now, in local time
+func durationEcho(minutes int, now time.Time) string {
+	d := time.Duration(minutes) * time.Minute
+	return fmt.Sprintf("%s (%d minutes), ending %s if started now", formatSpan(d), minutes, now.Add(d).Local().Format("Mon 2 Jan 15:04 MST"))
 }
 
 // Screen types to track the current state
@@ -77,23 +272,84 @@ const (
 	LoadingScreen
 	OutputScreen
 	PromptScreen
+	ResumeScreen
+	ConfirmScreen
+	LogStreamScreen
+	ErrorScreen
+	DoctorScreen
+	StatsScreen
+	RollbackScreen
+	PresetsScreen
+	LogViewScreen
+	TimelineScreen
+	ContextScreen
+	HistoryScreen
+	EnvSwitchScreen
+	WinnersScreen
+	ToolsScreen
+	DraftsScreen
+	TemplatesScreen
+	OverridesScreen
+	ExtraFieldsScreen
+	ToolSelectScreen
+	ToolScreen
 )
 
+// invocation records the details of a single lambda invocation
+type invocation struct {
+	Env           string
+	FunctionName  string
+	Payload       EventPayload
+	RequestID     string
+	Response      []byte // raw response payload
+	Body          []byte // response with any API Gateway style envelope unwrapped
+	StatusCode    int    // HTTP or envelope status code, 0 for a plain lambda response
+	FunctionError string
+	URL           string // endpoint called when the tool uses the http transport
+	Mode          string // modeSync or modeAsync
+	SentPayload   []byte // payload as sent when the tool transforms it
+	ModeReason    string // why the mode was picked
+	StartedAt     time.Time
+	Duration      time.Duration
+	Logs          string // decoded log tail, or the polled logs of an async invocation
+	LogsErr       string // why the log tail couldn't be decoded
+	SSOLogin      bool   // an expired SSO session was logged in again first
+	Shadow        bool   // a dry run of a prod payload from its confirmation
+	Approval      approvalGrant
+	Tool          string // configured tool invoked, empty for the sweepstake calculator
+}
+
 // Messages
 type lambdaResult struct {
 	output []string
 	logs   string
 	err    error
+	inv    *invocation
+	// gen is the result generation the invocation was started with
+	gen int
 }
 
 type tickMsg time.Time
 
 type item struct {
 	title, desc, action string
+	// requires notes the roles needed when the caller's likely lacks them
+	requires string
+	// unavailable notes why the last checked quest's state doesn't allow it
+	unavailable string
 }
 
-func (i item) Title() string       { return i.title }
-func (i item) Description() string { return i.desc }
+func (i item) Title() string { return i.title }
+func (i item) Description() string {
+	desc := i.desc
+	if i.unavailable != "" {
+		desc = i.unavailable + " " + glyphs.Dash + " " + desc
+	}
+	if i.requires != "" {
+		desc = i.requires + " " + glyphs.Dash + " " + desc
+	}
+	return desc
+}
 func (i item) FilterValue() string { return i.title }
 
 // Model struct that holds all application state
@@ -101,352 +357,2677 @@ type model struct {
 	currentScreen  Screen
 	promptMessage  string
 	promptQuestion string
-	promptInput    textinput.Model
+	promptForm     form
 	envList        list.Model
 	actionList     list.Model
+	logStreamList  list.Model
+	rollbackList   list.Model
+	// rollbackLoading is set while the history is searched for completes
+	rollbackLoading bool
+	presets         presetsScreen
+	logView         logView
+	timeline        timelineScreen
+	// timelineReturn is the screen the timeline was opened from
+	timelineReturn Screen
+	history        historyScreen
+	winners        winnersScreen
+	historyReturn  Screen
+	envSwitch      envSwitcher
 	spinner        spinner.Model
+	styles         Styles
 	selectedEnv    string
 	selectedAction string
+	pendingPayload EventPayload
 	lambdaOutput   []string
-	lambdaLogs     string
+	// resultsExpanded shows the full JSON of an array response
+	resultsExpanded bool
+	lambdaLogs      string
+	// lambdaLogsFile holds the full logs when lambdaLogs is only their tail
+	lambdaLogsFile string
+	// lambdaLogsNote says how much of the log window 'L' fetched
+	lambdaLogsNote string
+	// lastInput is the time of the latest key press, for the idle lock.
+	// lockedEnv is the environment an idle lock left or a Ctrl+E switch
+	// entered, whose actions wait for its identity to be confirmed again.
+	lastInput time.Time
+	lockedEnv string
+	lockNote  string
+	// promotion is the latest environment comparison of the session, a
+	// failed one holding back prod starts
+	promotion *promotionChecklist
+	// questState is the last quest state checked for a confirmation, which
+	// the action list is annotated with
+	questState        *questState
+	questStateLoading bool
+	questStateErr     error
+	// fieldHelpOpen shows the prompt's help panel, and exampleNext is the
+	// next example Ctrl+Y puts in exampleField
+	fieldHelpOpen bool
+	exampleField  string
+	exampleNext   int
+	// schemaHelp is the field help read from the tool's schema, loaded the
+	// first time a prompt opens
+	schemaHelp          map[string]fieldHelp
+	schemaHelpErr       error
+	schemaHelpRequested bool
+	// overridesSchema checks the overrides typed in the editor, nil when the
+	// schema doesn't describe them
+	overridesSchema *jsonSchema
+	// contextList is the context switcher, opened over contextReturn
+	contextList    list.Model
+	contextReturn  Screen
 	lambdaErr      error
-	width, height  int
+	checkpoint     *invocationCheckpoint
+	lastInvocation *invocation
+	outputMessage  string
+	doctorChecks   []doctorCheck
+	stats          string
+	// tools is the tools screen's text, toolsLoading set while discovering
+	tools        string
+	toolsLoading bool
+	// discarding is set while asking whether to discard an edited prompt,
+	// discardQuits when leaving it quits rather than going back
+	discarding   bool
+	discardQuits bool
+	drafts       draftsScreen
+	templates    templatesScreen
+	overrides    jsonEditor
+	extraFields  jsonEditor
+	// loadedTemplate is the template the prompt was filled in from, its
+	// other fields applied to the payload the prompt builds
+	loadedTemplate *payloadTemplate
+	// confirmNote reports saving the confirmed payload as a template, or a
+	// key that couldn't be generated
+	confirmNote string
+	// retriedKey is the idempotency key of the attempt a retry was started
+	// from, which 'k' on the confirmation can swap for a fresh one
+	retriedKey string
+	// toolList picks between the sweepstake calculator and the tools of
+	// their own from the config, and tool runs one of those
+	toolList    list.Model
+	tool        toolScreen
+	statsReturn Screen
+	// Latest progress logged by the running invocation, nil until one is seen
+	progress   *progressMsg
+	progressCh chan progressMsg
+	// resultGen tells the result of an abandoned invocation apart from the
+	// current one's, awaitingResult is set while the loading screen waits
+	// for a lambdaResult
+	resultGen      int
+	awaitingResult bool
+	// asyncStatus is the status of a pending async invocation
+	asyncStatus string
+	// Today's prod budget use, and the typed acknowledgment the confirmation
+	// screen asks for when going over it or rolling back in prod
+	prodBudget prodBudget
+	ackInput   textinput.Model
+	acking     bool
+	ackMessage string
+	// Follow-up fetch of logs missing from the invocation's tail
+	logRetryGen int
+	logsPending bool
+	// Complete being rolled back
+	rollbackOf *historyRecord
+	// Last value entered per action, offered again the next time it is picked
+	lastValues map[string]string
+
+	// verification is the metric check of the last complete, pending while
+	// verifying
+	verification *verification
+	verifying    bool
+
+	// invokeMode is the sync or async mode the confirmed payload is invoked in
+	invokeMode modeDecision
+
+	// themeNote explains a simplified theme until the first keypress
+	themeNote string
+	// startNote explains why --env or --action weren't applied, until the
+	// first keypress
+	startNote string
+	// Batch file being confirmed or run, with the results so far. batchIDs
+	// is the list of quest IDs typed instead, when the batch is one.
+	batchPath string
+	batchIDs  string
+	batchRows []batchRow
+	// batchFailFast stops the batch at the first failed quest, and
+	// batchFailedFast reports it did
+	batchFailFast   bool
+	batchFailedFast bool
+	// batchAll and batchSubset are the rows of the file and the rows a
+	// previous run in batchRetryOf didn't get through, when there is one
+	batchAll         []batchRow
+	batchSubset      []batchRow
+	batchRetryOf     string
+	batchCancelling  bool
+	batchResults     []batchResult
+	batchResultsPath string
+	// Recent ERROR lines of the target function, fetched on demand from the
+	// confirmation screen
+	recentErrorsOpen    bool
+	recentErrorsLoading bool
+	recentErrors        *recentErrorsMsg
+	// The latest shadow run of the pending prod payload, a forced dry run
+	// shown under the confirmation
+	shadowRunning bool
+	shadow        *shadowRunMsg
+	// approval is the request gating the pending payload, when the config
+	// requires one
+	approval approvalState
+	// Cached identity/function info per environment, refreshed in the background
+	envInfo           map[string]envInfo
+	envInfoErr        map[string]error
+	envInfoRefreshing map[string]bool
+	width, height     int
 }
 
 func initialModel() model {
 	// Environment selection items
 	envItems := []list.Item{
-		item{title: "Development", desc: "Use development environment", action: devEnv},
-		item{title: "Non-Production", desc: "Use non-production environment", action: nonProdEnv},
-		item{title: "Production", desc: "Use production environment", action: prodEnv},
+		item{title: tr(msgEnvDevTitle), desc: tr(msgEnvDevDesc), action: devEnv},
+		item{title: tr(msgEnvNonProdTitle), desc: tr(msgEnvNonProdDesc), action: nonProdEnv},
+		item{title: tr(msgEnvProdTitle), desc: tr(msgEnvProdDesc), action: prodEnv},
+	}
+	// The config's own environments follow the built-in ones
+	var configured []string
+	for env := range profileMap {
+		if _, ok := envTitles[env]; !ok {
+			configured = append(configured, env)
+		}
+	}
+	sort.Strings(configured)
+	for _, env := range configured {
+		envItems = append(envItems, item{title: env, desc: tr(msgEnvConfiguredDesc, env, profileMap[env]), action: env})
+	}
+	for i, li := range envItems {
+		it := li.(item)
+		if d := envDescriptions[it.action]; d != "" {
+			it.desc = d
+		}
+		if isProtected(it.action) && it.action != prodEnv {
+			it.desc += " " + tr(msgEnvProtectedSuffix)
+		}
+		envItems[i] = it
+	}
+	if pinnedEnv != "" {
+		// Other environments, prod in particular, never appear in a pinned session
+		pinned := envItems[:0]
+		for _, i := range envItems {
+			if i.(item).action == pinnedEnv {
+				pinned = append(pinned, i)
+			}
+		}
+		if len(pinned) == 0 {
+			pinned = append(pinned, item{title: pinnedEnv, desc: tr(msgEnvPinnedDesc), action: pinnedEnv})
+		}
+		envItems = pinned
 	}
 
-	// Action selection itemsc
+	// Action selection items
 	actionItems := []list.Item{
-		item{title: "Create Sweepstake", desc: "Create new sweepstake, overriding existing ones", action: string(ActionStart)},
-		item{title: "Process Sweepstake", desc: "Process sweepstake calculation without distributing rewards", action: string(ActionProcess)},
-		item{title: "Complete Sweepstake", desc: "Complete sweepstake calculation and distribute rewards", action: string(ActionComplete)},
+		item{title: tr(msgActionStartTitle), desc: tr(msgActionStartDesc), action: string(ActionStart)},
+		item{title: tr(msgActionProcessTitle), desc: tr(msgActionProcessDesc), action: string(ActionProcess)},
+		item{title: tr(msgActionCompleteTitle), desc: tr(msgActionCompleteDesc), action: string(ActionComplete)},
+		item{title: tr(msgActionBatchTitle), desc: tr(msgActionBatchDesc), action: batchAction},
+		item{title: tr(msgActionInvestigateTitle), desc: tr(msgActionInvestigateDesc), action: investigateAction},
+		item{title: tr(msgActionCompareTitle), desc: tr(msgActionCompareDesc), action: compareAction},
+		item{title: tr(msgActionTimelineTitle), desc: tr(msgActionTimelineDesc), action: timelineAction},
+		item{title: tr(msgActionRollbackTitle), desc: tr(msgActionRollbackDesc), action: string(ActionRollback)},
+		item{title: tr(msgActionPresetsTitle), desc: tr(msgActionPresetsDesc), action: presetsAction},
+		item{title: tr(msgActionTemplatesTitle), desc: tr(msgActionTemplatesDesc), action: templatesAction},
+	}
+
+	envList := list.New(envItems, newListDelegate(), 0, 0)
+	envList.Title = tr(msgEnvListTitle)
+
+	actionList := list.New(actionItems, newActionDelegate(), 0, 0)
+	actionList.Title = tr(msgActionListTitle)
+
+	statsKey := key.NewBinding(key.WithKeys("s"), key.WithHelp("s", "stats"))
+	historyKey := key.NewBinding(key.WithKeys("H"), key.WithHelp("H", "history"))
+	draftsKey := key.NewBinding(key.WithKeys("D"), key.WithHelp("D", "drafts"))
+	envList.AdditionalShortHelpKeys = func() []key.Binding { return []key.Binding{statsKey, historyKey, draftsKey} }
+	if !configFileExists() {
+		initKey := key.NewBinding(key.WithKeys("i"), key.WithHelp("i", "create a config"))
+		envList.AdditionalShortHelpKeys = func() []key.Binding { return []key.Binding{initKey, statsKey, historyKey, draftsKey} }
 	}
+	actionList.AdditionalShortHelpKeys = func() []key.Binding { return []key.Binding{statsKey, historyKey, draftsKey} }
+
+	logStreamList := list.New(nil, newListDelegate(), 0, 0)
+	logStreamList.Title = tr(msgLogStreamListTitle)
 
-	envList := list.New(envItems, list.NewDefaultDelegate(), 0, 0)
-	envList.Title = "Select Environment"
+	rollbackList := list.New(nil, newListDelegate(), 0, 0)
+	rollbackList.Title = tr(msgRollbackListTitle)
 
-	actionList := list.New(actionItems, list.NewDefaultDelegate(), 0, 0)
-	actionList.Title = "Rewards Tools"
+	contextList := list.New(nil, newListDelegate(), 0, 0)
+	contextList.Title = tr(msgContextListTitle)
+
+	toolList := list.New(nil, newListDelegate(), 0, 0)
+	toolList.Title = tr(msgToolListTitle)
+
+	for _, l := range []*list.Model{&envList, &actionList, &logStreamList, &rollbackList, &contextList, &toolList} {
+		applyListKeys(l)
+		applyListGlyphs(l)
+	}
 
 	s := spinner.New()
 	s.Spinner = spinner.Dot
-	s.Style = lipgloss.NewStyle().Foreground(lipgloss.Color("205"))
+	if glyphs != unicodeGlyphs {
+		s.Spinner = spinner.Line
+	}
+	if highLatency {
+		s.Spinner.FPS = highLatencySpinnerFPS
+	}
+	s.Style = lipgloss.NewStyle().Foreground(themeStyles.Accent)
 
-	// Initialize text input for prompt
-	ti := textinput.New()
-	ti.Placeholder = "Enter answer"
-	ti.Focus()
-	ti.CharLimit = 10
-	ti.Width = 20
+	ack := textinput.New()
+	ack.CharLimit = 40
+	ack.Width = 30
 
-	return model{
-		currentScreen: EnvironmentScreen,
+	m := model{
+		currentScreen: homeScreen(),
+		selectedEnv:   pinnedEnv,
 		envList:       envList,
 		actionList:    actionList,
-		promptInput:   ti,
+		logStreamList: logStreamList,
+		rollbackList:  rollbackList,
+		contextList:   contextList,
+		toolList:      toolList,
+		styles:        themeStyles,
+		presets:       newPresetsScreen(),
+		logView:       newLogView(),
+		timeline:      timelineScreen{view: newLogView()},
+		history:       historyScreen{input: newHistoryInput()},
+		envSwitch:     newEnvSwitcher(),
+		promptForm:    newPromptForm(tr(msgLabelValue)),
+		overrides:     newJSONEditor(tr(msgOverridesPlaceholder)),
+		extraFields:   newJSONEditor(tr(msgExtraFieldsPlaceholder)),
+		ackInput:      ack,
 		spinner:       s,
 		lambdaOutput:  []string{},
+		lastValues:    map[string]string{},
+		themeNote:     unseenThemeNote(),
+		lastInput:     time.Now(),
+
+		envInfo:           map[string]envInfo{},
+		envInfoErr:        map[string]error{},
+		envInfoRefreshing: map[string]bool{},
+	}
+
+	if state, err := loadState(); err == nil {
+		for env, info := range state.EnvInfo {
+			m.envInfo[env] = info
+		}
+		// Offer to resume an invocation that was interrupted last time, unless
+		// it belongs to an environment outside the pin
+		m.applyRestrictions()
+		if cp := state.resumableCheckpoint(); cp != nil {
+			m.checkpoint = cp
+			m.currentScreen = ResumeScreen
+		}
+	}
+	if m.currentScreen != ResumeScreen {
+		m.startNote = m.preselect(startEnv, startAction)
+	}
+
+	return m
+}
+
+// startEnv and startAction are the --env and --action the TUI starts with
+var startEnv, startAction string
+
+// preselect selects the environment and action given on the command line as
+// if they were picked from the lists, opening the action's prompt. What
+// can't be selected is left to the lists, explained by the returned note.
+func (m *model) preselect(env, action string) string {
+	if env == "" {
+		if action != "" && pinnedEnv == "" {
+			return fmt.Sprintf("--action %s needs --env too, pick the environment first", action)
+		}
+		env = pinnedEnv
+		if env == "" {
+			return ""
+		}
+	}
+	envIndex := -1
+	for i, li := range m.envList.Items() {
+		if it, ok := li.(item); ok && it.action == env {
+			envIndex = i
+		}
+	}
+	if envIndex < 0 {
+		return fmt.Sprintf("--env %s is not a known environment, pick one from the list", env)
+	}
+	m.envList.Select(envIndex)
+	m.selectedEnv = env
+	m.applyAvailability()
+	m.applyRestrictions()
+	m.currentScreen = ActionScreen
+	if action == "" {
+		return ""
+	}
+
+	switch action {
+	case string(ActionRollback), presetsAction, templatesAction, repeatAction:
+		return fmt.Sprintf("--action %s doesn't start with a prompt, pick it from the list", action)
 	}
+	for i, li := range m.actionList.Items() {
+		if it, ok := li.(item); ok && it.action == action {
+			m.actionList.Select(i)
+			m.openPrompt(action)
+			return ""
+		}
+	}
+	return fmt.Sprintf("--action %s is not a known action, pick one from the list", action)
+}
+
+// homeScreen is the first screen of a session, skipping the environment
+// choice when the session is pinned
+func homeScreen() Screen {
+	if pinnedEnv != "" {
+		return ActionScreen
+	}
+	return EnvironmentScreen
 }
 
 func (m model) Init() tea.Cmd {
-	return nil
+	switch m.currentScreen {
+	case ActionScreen:
+		return tea.Batch(m.refreshEnvInfo(), m.refreshBudget(), idleCheckCmd())
+	case PromptScreen:
+		// Started on a prompt with --env and --action
+		return tea.Batch(m.refreshEnvInfo(), m.refreshBudget(), idleCheckCmd(), textinput.Blink)
+	}
+	return idleCheckCmd()
 }
 
 func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	switch msg := msg.(type) {
 	case tea.KeyMsg:
-		// Don't handle keyboard input during loading
+		m.themeNote, m.startNote = "", ""
+		m.lastInput = time.Now()
+
+		// Don't handle keyboard input during loading, other than stopping a
+		// batch after the current quest or no longer waiting for a result
 		if m.currentScreen == LoadingScreen {
+			switch {
+			case msg.Type != tea.KeyEsc:
+			case m.batchRows != nil:
+				m.batchCancelling = true
+			case m.awaitingResult:
+				return m.abandonInvocation()
+			}
 			return m, nil
 		}
 
-		switch msg.String() {
-		case "ctrl+c", "q":
-			return m, tea.Quit
-
-		case "b":
-			if m.currentScreen == OutputScreen {
-				m.currentScreen = ActionScreen
-				return m, nil
-			}
+		if m.discarding {
+			return m.updateDiscard(msg)
+		}
 
-		case "enter":
-			switch m.currentScreen {
-			case EnvironmentScreen:
-				if i, ok := m.envList.SelectedItem().(item); ok {
-					m.selectedEnv = i.action
-					m.currentScreen = ActionScreen
-				}
-				return m, nil
+		if m.currentScreen == EnvSwitchScreen {
+			return m.updateEnvSwitch(msg)
+		}
 
-			case ActionScreen:
-				if i, ok := m.actionList.SelectedItem().(item); ok {
-					m.selectedAction = i.action
-				} else {
-					return m, nil
-				}
-				m.currentScreen = PromptScreen
-				m.promptMessage = ""
-				// TODO Default to current sweepstake quest ID
-				// hit sweepstake api
-				m.promptInput.SetValue("3907")
-				if m.selectedAction == string(ActionProcess) || m.selectedAction == string(ActionComplete) {
-					m.promptQuestion = "Please enter sweepstake quest ID"
-				} else {
-					m.promptQuestion = "Please enter sweepstake duration in minutes"
-				}
-				// m.promptInput.SetValue("")
-				return m, textinput.Blink
+		if msg.String() == "ctrl+e" {
+			return m.openEnvSwitch()
+		}
 
-			case PromptScreen:
-				// Validate input is a number
-				idStr := m.promptInput.Value()
-				id, err := strconv.Atoi(idStr)
-				if err != nil || id <= 0 {
-					m.promptMessage = "Please enter a valid positive number"
-					return m, nil
-				}
+		if m.currentScreen == OverridesScreen {
+			return m.updateOverrides(msg)
+		}
 
-				var payload EventPayload
-				if m.selectedAction == string(ActionComplete) || m.selectedAction == string(ActionProcess) {
+		if m.currentScreen == ExtraFieldsScreen {
+			return m.updateExtraFields(msg)
+		}
 
-					payload = EventPayload{
-						Action:            Action(m.selectedAction),
-						SweepstakeQuestID: &id,
-					}
-				} else {
-					// Start sweepstake action, with duration minutes
-					payload = EventPayload{
-						Action: Action(m.selectedAction),
-						// DurationMinutes: &id,
-					}
-				}
+		if m.currentScreen == PresetsScreen {
+			return m.updatePresets(msg)
+		}
 
-				m.currentScreen = LoadingScreen
-				return m, tea.Batch(
-					m.spinner.Tick,
-					invokeLambdaCmd(m.selectedEnv, payload),
-				)
-			}
+		if m.currentScreen == TimelineScreen {
+			return m.updateTimeline(msg)
 		}
 
-	case tickMsg:
-		return m, nil
+		if m.currentScreen == HistoryScreen {
+			return m.updateHistory(msg)
+		}
 
-	case lambdaResult:
-		m.lambdaOutput = msg.output
-		m.lambdaLogs = msg.logs
-		m.lambdaErr = msg.err
-		m.currentScreen = OutputScreen
-		return m, nil
+		if m.currentScreen == WinnersScreen {
+			return m.updateWinners(msg)
+		}
 
-	case tea.WindowSizeMsg:
-		m.width = msg.Width
-		m.height = msg.Height
-		h, v := docStyle.GetFrameSize()
-		m.envList.SetSize(msg.Width-h, msg.Height-v)
-		m.actionList.SetSize(msg.Width-h, msg.Height-v)
+		if m.currentScreen == DraftsScreen {
+			return m.updateDrafts(msg)
+		}
 
-	case spinner.TickMsg:
-		var cmd tea.Cmd
-		m.spinner, cmd = m.spinner.Update(msg)
-		return m, cmd
-	}
+		if m.currentScreen == TemplatesScreen {
+			return m.updateTemplates(msg)
+		}
 
-	// Update the appropriate list based on current screen
-	var cmd tea.Cmd
-	switch m.currentScreen {
-	case EnvironmentScreen:
-		m.envList, cmd = m.envList.Update(msg)
-	case ActionScreen:
-		m.actionList, cmd = m.actionList.Update(msg)
-	case PromptScreen:
-		m.promptInput, cmd = m.promptInput.Update(msg)
-	}
+		if m.currentScreen == ToolSelectScreen && !m.toolList.SettingFilter() {
+			return m.updateToolSelect(msg)
+		}
 
-	return m, cmd
-}
+		if m.currentScreen == ToolScreen {
+			return m.updateTool(msg)
+		}
 
-func (m model) View() string {
-	switch m.currentScreen {
-	case EnvironmentScreen:
-		return docStyle.Render(m.envList.View())
+		if m.currentScreen == ContextScreen {
+			return m.updateContexts(msg)
+		}
 
-	case ActionScreen:
-		return docStyle.Render(m.actionList.View())
+		if msg.String() == "ctrl+o" {
+			return m.openContexts()
+		}
 
-	case PromptScreen:
-		var sb strings.Builder
-		sb.WriteString(fmt.Sprintf("\n\n  %s %s:\n\n", m.promptQuestion, m.selectedAction))
-		sb.WriteString("  " + m.promptInput.View() + "\n\n")
+		if m.currentScreen == PromptScreen && msg.Type == tea.KeyCtrlC {
+			return m.leavePrompt(true)
+		}
 
-		if m.promptMessage != "" {
-			sb.WriteString("  " + m.promptMessage + "\n\n")
+		// pressed names the key by its default binding, so the screens below
+		// follow the keymap of the config
+		pressed := keys.name(msg)
+
+		// Inputs being typed into get first pick of the keys. Only ctrl+c and
+		// a submitted prompt form fall through to the global bindings.
+		if m.capturesInput() && msg.Type != tea.KeyCtrlC {
+			if m.currentScreen == ConfirmScreen {
+				switch msg.Type {
+				case tea.KeyEnter:
+					if phrase := m.requiredAck(); strings.TrimSpace(m.ackInput.Value()) != phrase {
+						m.ackMessage = tr(msgAckMismatch, phrase)
+						return m, nil
+					}
+					m.acking = false
+					return m.confirmInvocation()
+				case tea.KeyEsc:
+					m.acking = false
+					return m, nil
+				default:
+					return m.updateFocused(msg)
+				}
+			}
+			if m.currentScreen != PromptScreen {
+				return m.updateFocused(msg)
+			}
+			if m.updateFieldHelp(msg) {
+				return m, nil
+			}
+			var cmd tea.Cmd
+			var action formAction
+			m.promptForm, cmd, action = m.promptForm.Update(msg)
+			switch action {
+			case formCancel:
+				return m.leavePrompt(false)
+			case formNone:
+				return m, cmd
+			}
+			// A submitted form falls through to the enter binding
+			pressed = "enter"
 		}
 
-		sb.WriteString("  Press Enter to continue or 'b' to go back\n")
-		return docStyle.Render(sb.String())
+		if m.currentScreen == ErrorScreen {
+			switch pressed {
+			case "r":
+				// A retry goes through the confirmation again, so the budget,
+				// lifecycle and identity checks see it like the first attempt
+				if m.lastInvocation != nil {
+					return m, m.retry(m.lastInvocation.Payload)
+				}
+			case "e":
+				if m.lastInvocation != nil {
+					m.currentScreen = PromptScreen
+					return m, m.promptForm.Focus(0)
+				}
+			case "l":
+				m.currentScreen = OutputScreen
+				return m, nil
+			case "d":
+				m.currentScreen = DoctorScreen
+				m.doctorChecks = nil
+				return m, tea.Batch(m.spinner.Tick, doctorChecksCmd(m.selectedEnv))
+			case "b":
+				m.currentScreen = ActionScreen
+				return m, m.refreshEnvInfo()
+			}
+		}
 
-	case LoadingScreen:
-		return docStyle.Render(fmt.Sprintf("\n\n  %s Invoking Lambda in %s environment with action %s...\n\n  Please wait, this may take a few moments...",
-			m.spinner.View(),
-			m.selectedEnv,
-			m.selectedAction))
+		if m.currentScreen == StatsScreen && pressed == "b" {
+			m.currentScreen = m.statsReturn
+			return m, nil
+		}
 
-	case OutputScreen:
-		var output string
-		if m.lambdaErr != nil {
-			output = fmt.Sprintf("Error: %v\n\n", m.lambdaErr)
-		} else {
-			output = "Lambda Execution Summary:\n\n"
+		if pressed == "H" && (m.currentScreen == EnvironmentScreen || m.currentScreen == ActionScreen) {
+			return m.openHistory()
 		}
 
-		for _, line := range m.lambdaOutput {
-			// Wrap long output lines
-			output += lipgloss.NewStyle().Width(m.width-4).Render(line) + "\n"
+		if pressed == "D" && (m.currentScreen == EnvironmentScreen || m.currentScreen == ActionScreen) {
+			return m.openDrafts()
 		}
 
-		if m.lambdaLogs != "" {
-			output += "\n--- Lambda Logs ---\n\n"
-			// Wrap the logs with appropriate width
-			output += lipgloss.NewStyle().Width(m.width - 4).Render(m.lambdaLogs)
+		if pressed == "i" && m.currentScreen == EnvironmentScreen && !configFileExists() {
+			return m, runConfigInit()
 		}
 
-		return docStyle.Render(fmt.Sprintf("%s\n\nPress 'b' to go back or 'q' to quit", output))
-	}
+		if pressed == "T" && m.currentScreen == ActionScreen && discoveryTag != "" {
+			return m.openTools(false)
+		}
 
-	return "Loading..."
-}
+		if m.currentScreen == ToolsScreen && !m.toolsLoading {
+			switch pressed {
+			case "r":
+				return m.openTools(true)
+			case "b":
+				m.currentScreen = ActionScreen
+				return m, nil
+			}
+		}
 
-var docStyle = lipgloss.NewStyle().Margin(1, 2)
+		if pressed == "s" && (m.currentScreen == EnvironmentScreen || m.currentScreen == ActionScreen) {
+			m.statsReturn = m.currentScreen
+			m.currentScreen = StatsScreen
+			m.stats = "  " + tr(msgLoading) + "\n"
+			return m, loadStatsCmd()
+		}
 
-func invokeLambdaCmd(env string, payload EventPayload) tea.Cmd {
-	return func() tea.Msg {
-		output := []string{}
-		var logs string
-		err := invokeLambda(env, payload, &output, &logs)
-		return lambdaResult{output: output, logs: logs, err: err}
-	}
-}
+		if m.currentScreen == DoctorScreen && pressed == "b" {
+			m.currentScreen = ErrorScreen
+			return m, nil
+		}
 
-func invokeLambda(env string, payload EventPayload, output *[]string, logs *string) error {
-	profile := profileMap[env]
-	functionName := fmt.Sprintf(sweepstakeFunctionName, env)
+		if m.currentScreen == ResumeScreen {
+			switch pressed {
+			case "y":
+				cp := *m.checkpoint
+				m.selectedEnv = cp.Env
+				m.selectedAction = string(cp.Payload.Action)
+				m.currentScreen = LoadingScreen
+				return m, tea.Batch(m.spinner.Tick, resumeCheckpointCmd(m.awaitResult(), cp))
+			case "n":
+				clearCheckpoint(*m.checkpoint)
+				m.checkpoint = nil
+				m.currentScreen = homeScreen()
+				return m, m.Init()
+			}
+		}
 
-	*output = append(*output, fmt.Sprintf("Environment: %s", env))
-	jsonPayload, _ := json.MarshalIndent(payload, "", "  ")
-	*output = append(*output, fmt.Sprintf("Payload: %s", jsonPayload))
+		switch pressed {
+		case "ctrl+c", "q":
+			return m, tea.Quit
 
-	// AWS SSO session check
-	if err := checkSSOSession(profile, output); err != nil {
-		return err
-	}
+		case "b":
+			if m.currentScreen == OutputScreen {
+				m.currentScreen = ActionScreen
+				return m, m.refreshEnvInfo()
+			}
+			if m.currentScreen == ActionScreen && len(configuredTools()) > 0 {
+				return m.openToolSelect()
+			}
 
-	cfg, err := config.LoadDefaultConfig(context.Background(),
-		config.WithSharedConfigProfile(profile),
-	)
-	if err != nil {
-		return fmt.Errorf("failed to load AWS config: %v", err)
-	}
+		case "x":
+			if m.currentScreen == OutputScreen && m.lastInvocation != nil {
+				m.outputMessage = m.exportBundle()
+				return m, nil
+			}
+			if m.currentScreen == ConfirmScreen && m.canEditExtraFields() {
+				return m.openExtraFields()
+			}
 
-	// Create Lambda client
-	client := lambda.NewFromConfig(cfg)
+		case "c":
+			if m.currentScreen == OutputScreen && m.lastInvocation != nil {
+				m.outputMessage = m.copyAWSCLI()
+				return m, nil
+			}
 
-	payloadBytes, err := json.Marshal(payload)
-	if err != nil {
-		return fmt.Errorf("failed to marshal payload: %v", err)
-	}
+		case "S":
+			if m.currentScreen == OutputScreen && m.lastInvocation != nil {
+				inv := m.lastInvocation
+				m.outputMessage = tr(msgLogsFetchingStreams)
+				return m, fetchLogStreamsCmd(inv.Env, inv.FunctionName, inv.RequestID, inv.StartedAt)
+			}
 
-	// Invoke Lambda with logs enabled
-	result, err := client.Invoke(context.Background(), &lambda.InvokeInput{
-		FunctionName: aws.String(functionName),
-		Payload:      payloadBytes,
-		LogType:      "Tail", // This will return the last 4KB of logs
-	})
-	if err != nil {
-		return fmt.Errorf("failed to invoke Lambda: %v", err)
-	}
+		case "L":
+			if m.currentScreen == OutputScreen && m.lastInvocation != nil {
+				inv := m.lastInvocation
+				m.outputMessage = tr(msgLogsFetchingRecent, logsSince)
+				return m, fetchLambdaLogsCmd(inv.Env, inv.FunctionName)
+			}
 
-	*output = append(*output, "Lambda invocation successful!")
+		case "enter":
+			switch m.currentScreen {
+			case EnvironmentScreen:
+				if i, ok := m.envList.SelectedItem().(item); ok {
+					m.selectedEnv = i.action
+					m.applyAvailability()
+					m.applyRestrictions()
+					m.currentScreen = ActionScreen
+					if len(configuredTools()) > 0 {
+						m.currentScreen = ToolSelectScreen
+						m.refreshToolList()
+					}
+					return m, tea.Batch(m.reconfirmIdentity(), m.refreshBudget())
+				}
+				return m, nil
+
+			case ActionScreen:
+				i, ok := m.actionList.SelectedItem().(item)
+				if !ok || m.identityPending() {
+					return m, nil
+				}
+				if i.action == repeatAction && m.lastInvocation != nil {
+					// Go through the prompt so 'b' on the confirmation edits the repeated value
+					payload := m.lastInvocation.Payload
+					// A repeat is a new run, not a retry, so it gets a key of its own
+					payload.IdempotencyKey, payload.IdempotencyOverride = "", false
+					if err := assignIdempotencyKey(&payload); err != nil {
+						return m, m.actionList.NewStatusMessage(errorStyle.Render(err.Error()))
+					}
+					m.openPrompt(string(payload.Action))
+					m.promptForm.SetValue(0, payloadValue(payload))
+					m.pendingPayload = payload
+					return m, tea.Batch(m.showConfirm(), m.checkQuestState())
+				}
+				if i.action == string(ActionRollback) {
+					return m.openRollback()
+				}
+				if i.action == presetsAction {
+					m.openPresets()
+					return m, nil
+				}
+				if i.action == templatesAction {
+					return m.openTemplates(false)
+				}
+				m.openPrompt(i.action)
+				return m, tea.Batch(textinput.Blink, m.loadSchemaHelpOnce())
+
+			case RollbackScreen:
+				i, ok := m.rollbackList.SelectedItem().(rollbackItem)
+				if !ok {
+					return m, nil
+				}
+				m.rollbackOf = &i.rec
+				m.openPrompt(string(ActionRollback))
+				return m, tea.Batch(textinput.Blink, m.loadSchemaHelpOnce())
+
+			case PromptScreen:
+				if m.selectedAction == batchAction {
+					m.batchPath, m.batchIDs = strings.TrimSpace(m.promptForm.Value(0)), ""
+					rows, err := readBatchFile(m.batchPath)
+					if err != nil {
+						m.promptMessage = err.Error()
+						return m, m.promptForm.Focus(0)
+					}
+					m.batchAll, m.batchRows, m.batchSubset, m.batchRetryOf = rows, rows, nil, ""
+					// Pre-select what a previous run didn't get through
+					if previous, statuses, err := previousBatchResults(m.batchPath); err == nil && previous != "" {
+						if subset := batchRetrySubset(rows, statuses); len(subset) > 0 && len(subset) < len(rows) {
+							m.batchRows, m.batchSubset, m.batchRetryOf = subset, subset, previous
+						}
+					}
+					return m, m.showConfirm()
+				}
+
+				if m.selectedAction == investigateAction {
+					return m.confirmInvestigation()
+				}
+
+				if m.selectedAction == timelineAction {
+					idStr := strings.TrimSpace(m.promptForm.Value(0))
+					id, err := parsePositive(idStr)
+					if err != nil {
+						m.promptMessage = err.Error()
+						return m, m.promptForm.Focus(0)
+					}
+					m.lastValues[timelineAction] = idStr
+					return m.openTimeline(id)
+				}
+
+				if m.selectedAction == compareAction {
+					idStr := strings.TrimSpace(m.promptForm.Value(0))
+					id, err := parsePositive(idStr)
+					if err != nil {
+						m.promptMessage = err.Error()
+						return m, m.promptForm.Focus(0)
+					}
+					m.lastValues[compareAction] = idStr
+					return m.startComparison(id)
+				}
+
+				if m.selectedAction == string(ActionRollback) {
+					justification := strings.TrimSpace(m.promptForm.Value(0))
+					if err := validateJustification(justification); err != nil {
+						m.promptMessage = err.Error()
+						return m, m.promptForm.Focus(0)
+					}
+					m.pendingPayload = buildRollbackPayload(*m.rollbackOf, justification)
+					return m, m.showConfirm()
+				}
+
+				idStr := m.promptForm.Value(0)
+				if m.selectedAction == string(ActionProcess) && isQuestList(idStr) {
+					return m.confirmQuestList(idStr)
+				}
+
+				// Validate input is a number, or a duration for start
+				parse := parsePositive
+				if m.selectedAction == string(ActionStart) {
+					parse = parseDurationMinutes
+				}
+				id, err := parse(idStr)
+				if err != nil {
+					m.promptMessage = err.Error()
+					return m, m.promptForm.Focus(0)
+				}
+
+				// The batch size is the second field of process and complete, and
+				// blank leaves it to the lambda
+				var batchSize *int
+				if len(m.promptForm.fields) > 1 {
+					if batchSize, err = parseBatchSize(m.promptForm.Value(1)); err != nil {
+						m.promptMessage = err.Error()
+						return m, m.promptForm.Focus(1)
+					}
+				}
+
+				m.lastValues[m.selectedAction] = idStr
+				m.batchRows, m.batchIDs = nil, ""
+				m.pendingPayload = playtools.NewPayload(Action(m.selectedAction), id)
+				m.pendingPayload.DryRun = promptDryRun(m.selectedEnv, m.pendingPayload.Action)
+				m.applyTemplate(&m.pendingPayload)
+				if len(m.promptForm.fields) > 1 {
+					m.pendingPayload.BatchSize = batchSize
+				}
+				if err := assignIdempotencyKey(&m.pendingPayload); err != nil {
+					m.promptMessage = err.Error()
+					return m, nil
+				}
+				if m.pendingPayload.Action == ActionStart {
+					return m.openOverrides()
+				}
+				return m, tea.Batch(m.showConfirm(), m.checkQuestState())
+
+			case LogStreamScreen:
+				if i, ok := m.logStreamList.SelectedItem().(logStreamItem); ok {
+					inv := m.lastInvocation
+					m.currentScreen = OutputScreen
+					m.outputMessage = tr(msgLogsFetchingStream, i.stream.Name)
+					return m, fetchLogStreamEventsCmd(inv.Env, inv.FunctionName, i.stream.Name)
+				}
+				return m, nil
+
+			case ConfirmScreen:
+				// The quest's state or a switch's identity check may hold the
+				// invocation back
+				if m.questStateLoading || m.checksBlock() || m.approval.waiting {
+					return m, nil
+				}
+				if phrase := m.requiredAck(); phrase != "" {
+					m.acking = true
+					m.ackMessage = ""
+					m.ackInput.SetValue("")
+					m.ackInput.Placeholder = phrase
+					return m, m.ackInput.Focus()
+				}
+				return m.confirmInvocation()
+			}
+
+		case "d":
+			if m.currentScreen == ConfirmScreen && m.batchRows == nil && dryRunApplies(m.pendingPayload.Action) && m.selectedAction != investigateAction {
+				m.pendingPayload.DryRun = !m.pendingPayload.DryRun
+				return m, nil
+			}
+			// The quests of a typed list share the prompt's dry run, unlike
+			// the rows of a file
+			if m.currentScreen == ConfirmScreen && m.batchIDs != "" && len(m.batchRows) > 0 {
+				dryRun := !m.batchRows[0].DryRun
+				for i := range m.batchRows {
+					m.batchRows[i].DryRun = dryRun
+				}
+				return m, nil
+			}
+
+		case "k":
+			if m.currentScreen == ConfirmScreen && m.batchRows == nil && m.pendingPayload.Action == ActionComplete {
+				if m.pendingPayload.IdempotencyOverride {
+					m.pendingPayload.IdempotencyKey, m.pendingPayload.IdempotencyOverride = m.retriedKey, false
+					return m, nil
+				}
+				if m.retrying() {
+					if err := overrideIdempotencyKey(&m.pendingPayload); err != nil {
+						m.confirmNote = errorStyle.Render(err.Error())
+					}
+					return m, nil
+				}
+			}
+
+		case "r":
+			if m.currentScreen == OutputScreen && m.lastInvocation != nil {
+				return m, m.retry(m.lastInvocation.Payload)
+			}
+			if m.currentScreen == ConfirmScreen && m.batchRetryOf != "" {
+				if len(m.batchRows) == len(m.batchAll) {
+					m.batchRows = m.batchSubset
+				} else {
+					m.batchRows = m.batchAll
+				}
+				return m, nil
+			}
+			if m.currentScreen == ConfirmScreen && m.batchRows == nil && !m.questStateLoading && m.pendingQuestState() != nil {
+				return m, m.loadQuestState(true)
+			}
+
+		case "f":
+			if m.currentScreen == ConfirmScreen && m.batchRows != nil {
+				m.batchFailFast = !m.batchFailFast
+				return m, nil
+			}
+
+		case "a":
+			if m.currentScreen == ConfirmScreen && m.batchRows == nil && m.invokeMode.Switchable {
+				if m.invokeMode.Mode == modeAsync {
+					m.invokeMode.Mode = modeSync
+				} else {
+					m.invokeMode.Mode = modeAsync
+				}
+				return m, nil
+			}
+
+		case "s":
+			if m.currentScreen == ConfirmScreen && m.canShadowRun() && !m.shadowRunning {
+				return m, m.startShadowRun()
+			}
+
+		case "e":
+			if m.currentScreen == OutputScreen {
+				m.resultsExpanded = !m.resultsExpanded
+				return m, nil
+			}
+			if m.currentScreen == ConfirmScreen {
+				m.recentErrorsOpen = !m.recentErrorsOpen
+				if !m.recentErrorsOpen || m.recentErrors != nil || m.recentErrorsLoading {
+					return m, nil
+				}
+				m.recentErrorsLoading = true
+				return m, fetchRecentErrorsCmd(m.selectedEnv, sweepstakeFunction(m.selectedEnv))
+			}
+		}
+
+		if m.currentScreen == ConfirmScreen && pressed == "P" && m.batchRows == nil && m.selectedAction != investigateAction {
+			if p, err := newPayloadPreview(m.selectedEnv, m.pendingPayload); err == nil {
+				exitOutput = p.String()
+				return m, tea.Quit
+			}
+		}
+
+		if m.currentScreen == ConfirmScreen && (pressed == "p" || pressed == "m") && m.canSavePreset() {
+			m.openPresets()
+			if pressed == "m" {
+				return m, m.startNaming("bookmark", "")
+			}
+			return m, m.startNaming("preset", "")
+		}
+
+		if m.currentScreen == ConfirmScreen && pressed == "n" && m.canSaveTemplate() {
+			return m.openTemplates(true)
+		}
+
+		if m.currentScreen == ConfirmScreen && pressed == "b" {
+			if m.pendingPayload.Action == ActionStart && m.selectedAction == string(ActionStart) {
+				return m.openOverrides()
+			}
+			m.currentScreen = PromptScreen
+			return m, m.promptForm.Focus(0)
+		}
+
+		if m.currentScreen == LogViewScreen && (pressed == "b" || pressed == "v" || pressed == "esc") {
+			m.currentScreen = OutputScreen
+			return m, nil
+		}
+
+		if m.currentScreen == OutputScreen && pressed == "w" && m.winners.table != nil {
+			return m.openWinners()
+		}
+
+		if m.currentScreen == OutputScreen && pressed == "t" && m.lastInvocation != nil && m.lastInvocation.Payload.SweepstakeQuestID != nil {
+			return m.openTimeline(*m.lastInvocation.Payload.SweepstakeQuestID)
+		}
+
+		if m.currentScreen == OutputScreen && pressed == "v" && m.lambdaLogs != "" {
+			if m.lambdaLogsFile != "" {
+				if err := m.logView.SetFile(m.lambdaLogsFile); err != nil {
+					m.outputMessage = err.Error()
+					return m, nil
+				}
+			} else {
+				m.logView.SetContent(m.lambdaLogs)
+			}
+			m.logView.note = m.lambdaLogsNote
+			m.currentScreen = LogViewScreen
+			return m, nil
+		}
+
+		if m.currentScreen == RollbackScreen && pressed == "b" {
+			m.currentScreen = ActionScreen
+			return m, nil
+		}
+
+		if m.currentScreen == LogStreamScreen && pressed == "b" {
+			m.currentScreen = OutputScreen
+			m.outputMessage = ""
+			return m, nil
+		}
+
+	case tickMsg:
+		return m, nil
+
+	case idleCheckMsg:
+		return m.checkIdle(time.Time(msg))
+
+	case toolResultMsg:
+		m.finishTool(msg)
+		return m, nil
+
+	case lambdaResult:
+		if msg.gen != m.resultGen {
+			return m, nil
+		}
+		m.awaitingResult = false
+		sessionDashboard.publish(msg)
+		m.lambdaOutput = msg.output
+		m.setLogs(msg.logs)
+		m.lambdaErr = msg.err
+		m.lastInvocation = msg.inv
+		m.resultsExpanded = false
+		m.outputMessage = ""
+		m.currentScreen = OutputScreen
+		m.winners.table = nil
+		if msg.inv != nil {
+			m.winners.table = responseWinners(msg.inv.Body, winnersField())
+			m.setRepeatItem(msg.inv)
+			// The invocation may have moved the quest on to another state
+			if st := m.questState; st != nil && st.Env == msg.inv.Env && mutates(msg.inv.Payload) && derefInt(msg.inv.Payload.SweepstakeQuestID) == st.Quest {
+				m.questState = nil
+				m.applyAvailability()
+			}
+		}
+		m.logRetryGen++
+		m.logsPending = false
+		m.verification = nil
+		m.verifying = false
+		cmds := []tea.Cmd{m.refreshBudget()}
+		if strings.TrimSpace(msg.logs) == "" && msg.inv != nil && msg.inv.RequestID != "" {
+			m.logsPending = true
+			cmds = append(cmds, scheduleLogRetry(m.logRetryGen, 0))
+		}
+		if verifyApplies(msg.inv, msg.err) {
+			m.verifying = true
+			cmds = append(cmds, verifyDistributionCmd(m.logRetryGen, msg.inv))
+		}
+		if msg.err != nil || (msg.inv != nil && msg.inv.FunctionError != "") {
+			m.currentScreen = ErrorScreen
+		}
+		// The outcome is on screen now, the checkpoint is resolved
+		if m.checkpoint != nil {
+			clearCheckpoint(*m.checkpoint)
+			m.checkpoint = nil
+		}
+		return m, tea.Batch(cmds...)
+
+	case budgetMsg:
+		m.prodBudget = msg.budget
+		return m, nil
+
+	case statsMsg:
+		m.stats = msg.text
+		return m, nil
+
+	case rollbackCandidatesMsg:
+		if msg.env != m.selectedEnv {
+			return m, nil
+		}
+		m.rollbackLoading = false
+		items := make([]list.Item, 0, len(msg.items))
+		for _, c := range msg.items {
+			items = append(items, c)
+		}
+		m.rollbackList.SetItems(items)
+		m.rollbackList.Select(0)
+		if msg.err != nil {
+			return m, m.rollbackList.NewStatusMessage(tr(msgHistoryReadErr, msg.err))
+		}
+		return m, nil
+
+	case timelineMsg:
+		if msg.env != m.timeline.env || msg.quest != m.timeline.quest {
+			return m, nil
+		}
+		m.timeline.loading = false
+		m.timeline.entries = msg.entries
+		m.timeline.warning = msg.warning
+		if msg.err != nil {
+			m.timeline.warning = msg.err.Error()
+		}
+		m.timeline.view.SetContent(timelineText(msg.entries))
+		return m, nil
+
+	case historyScanMsg:
+		h := &m.history
+		if msg.gen != h.gen {
+			return m, nil
+		}
+		h.loading = false
+		h.scan, h.records = msg.scan, msg.records
+		if msg.err != nil {
+			h.message = tr(msgHistoryReadErr, msg.err)
+		}
+		return m, nil
+
+	case historyPageMsg:
+		h := &m.history
+		if msg.gen != h.gen {
+			return m, nil
+		}
+		h.loading = false
+		h.records = append(h.records, msg.records...)
+		if msg.err != nil {
+			h.message = tr(msgHistoryReadErr, msg.err)
+		}
+		return m, nil
+
+	case questStateMsg:
+		if msg.err == nil {
+			m.questState = &msg.state
+			m.applyAvailability()
+		}
+		// Drop the state of a quest that is no longer being confirmed
+		if p := m.pendingPayload; msg.state.Env == m.selectedEnv && p.SweepstakeQuestID != nil && *p.SweepstakeQuestID == msg.state.Quest {
+			m.questStateLoading = false
+			m.questStateErr = msg.err
+		}
+		return m, nil
+
+	case schemaHelpMsg:
+		m.schemaHelp, m.overridesSchema, m.schemaHelpErr = msg.fields, msg.overrides, msg.err
+		return m, nil
+
+	case promotionMsg:
+		m.lambdaOutput = msg.output
+		m.lambdaErr = msg.err
+		m.lastInvocation = nil
+		m.setLogs("")
+		m.outputMessage = ""
+		if msg.checklist != nil {
+			m.promotion = msg.checklist
+		}
+		m.currentScreen = OutputScreen
+		if msg.err != nil {
+			m.currentScreen = ErrorScreen
+		}
+		return m, nil
+
+	case verificationMsg:
+		if msg.gen == m.logRetryGen {
+			m.verifying = false
+			m.verification = &msg.verification
+		}
+		return m, nil
+
+	case logRetryMsg:
+		// Stop once a newer invocation ran or the user left its screens
+		if msg.gen != m.logRetryGen || (m.currentScreen != OutputScreen && m.currentScreen != ErrorScreen) {
+			m.logsPending = false
+			return m, nil
+		}
+		return m, fetchRequestLogsCmd(msg.gen, msg.attempt, m.lastInvocation)
+
+	case logRetryResultMsg:
+		if msg.gen != m.logRetryGen {
+			return m, nil
+		}
+		if msg.err == nil && strings.TrimSpace(msg.logs) != "" {
+			m.setLogs(msg.logs)
+			m.logsPending = false
+			return m, nil
+		}
+		if next := msg.attempt + 1; next < len(logRetryDelays) {
+			return m, scheduleLogRetry(msg.gen, next)
+		}
+		m.logsPending = false
+		return m, nil
+
+	case batchRowMsg:
+		m.batchResults[msg.index] = msg.result
+		if m.batchFailFast && msg.result.Status == batchFailed {
+			m.batchCancelling, m.batchFailedFast = true, true
+		}
+		next := msg.index + 1
+		m.progress = &progressMsg{done: next, total: len(m.batchRows), unit: "quests"}
+		if m.batchCancelling {
+			cancelBatch(m.batchResults)
+		} else if next < len(m.batchRows) {
+			m.batchResults[next].Status = batchRunning
+		}
+		if err := writeBatchResults(m.batchResultsPath, m.batchResults); err != nil {
+			m.outputMessage = tr(msgBatchWriteErr, err)
+		}
+		if !m.batchCancelling && next < len(m.batchRows) {
+			return m, runBatchRowCmd(m.selectedEnv, m.batchRows[next], next)
+		}
+		return m, m.finishBatch()
+
+	case winnersProgressMsg:
+		if w := &m.winners; msg.gen == w.gen && w.export != nil {
+			w.export = &msg
+		}
+		return m, waitForWinnersProgress(m.winners.exportCh)
+
+	case discoveryMsg:
+		m.toolsLoading = false
+		if msg.err != nil {
+			m.tools = "  " + errorStyle.Render(msg.err.Error()) + "\n"
+			return m, nil
+		}
+		registerDiscovered(msg.env, msg.d.Tools)
+		m.tools = "  " + strings.Join(discoveryLines(msg.d), "\n  ") + "\n"
+		return m, nil
+
+	case winnersExportMsg:
+		m.finishWinnersExport(msg)
+		return m, nil
+
+	case progressMsg:
+		if msg.gen != m.resultGen {
+			return m, nil
+		}
+		if msg.status != "" {
+			m.asyncStatus = msg.status
+		} else {
+			m.progress = &msg
+		}
+		return m, waitForProgress(m.resultGen, m.progressCh)
+
+	case approvalMsg:
+		return m.updateApproval(msg)
+
+	case shadowRunMsg:
+		// Drop a run for an environment that is no longer being confirmed
+		if msg.env == m.selectedEnv && m.shadowRunning {
+			m.shadowRunning = false
+			m.shadow = &msg
+		}
+		return m, nil
+
+	case recentErrorsMsg:
+		// Drop results for an environment that is no longer being confirmed
+		if msg.env == m.selectedEnv && m.recentErrorsLoading {
+			m.recentErrorsLoading = false
+			m.recentErrors = &msg
+		}
+		return m, nil
+
+	case logStreamsMsg:
+		if msg.err != nil {
+			m.outputMessage = tr(msgLogsStreamsErr, msg.err)
+			return m, nil
+		}
+		if len(msg.streams) == 0 {
+			m.outputMessage = "No log streams found"
+			return m, nil
+		}
+		items := make([]list.Item, len(msg.streams))
+		selected := 0
+		for i, stream := range msg.streams {
+			items[i] = logStreamItem{stream: stream}
+			if stream.HasRequestID {
+				selected = i
+			}
+		}
+		m.logStreamList.SetItems(items)
+		m.logStreamList.Select(selected)
+		m.currentScreen = LogStreamScreen
+		return m, nil
+
+	case configInitMsg:
+		return m.finishConfigInit(msg)
+
+	case logStreamEventsMsg:
+		if msg.err != nil {
+			m.outputMessage = tr(msgLogsStreamErr, msg.stream, msg.err)
+			return m, nil
+		}
+		m.setLogs(msg.tail)
+		m.lambdaLogsFile = msg.path
+		m.outputMessage = tr(msgLogsStreamFull, msg.stream)
+		if msg.lines > logTailLines {
+			m.outputMessage = tr(msgLogsStreamTail, logTailLines, msg.lines, msg.stream, keys.help(keyLogs))
+		}
+		return m, nil
+
+	case lambdaLogsMsg:
+		if msg.err != nil {
+			m.outputMessage = tr(msgLogsFetchErr, msg.err)
+			return m, nil
+		}
+		m.setLogs(msg.tail)
+		m.lambdaLogsFile, m.lambdaLogsNote = msg.path, msg.note()
+		m.outputMessage = tr(msgLogsFetched, m.lambdaLogsNote)
+		if msg.lines > logTailLines {
+			m.outputMessage = tr(msgLogsFetchedTail, m.lambdaLogsNote, logTailLines, keys.help(keyLogs))
+		}
+		return m, nil
+
+	case doctorMsg:
+		m.doctorChecks = msg.checks
+		return m, nil
+
+	case envInfoMsg:
+		m.envInfoRefreshing[msg.env] = false
+		if msg.err != nil {
+			// Keep showing the stale info, with a warning
+			m.envInfoErr[msg.env] = msg.err
+			return m, nil
+		}
+		delete(m.envInfoErr, msg.env)
+		if msg.env == m.lockedEnv && msg.env == m.selectedEnv {
+			m.lockedEnv, m.lockNote = "", ""
+		}
+		m.envInfo[msg.env] = msg.info
+		saveEnvInfo(msg.env, msg.info)
+		m.applyRestrictions()
+		return m, nil
+
+	case tea.WindowSizeMsg:
+		m.width = msg.Width
+		m.height = msg.Height
+		noteResize(msg.Width, msg.Height)
+		h, v := m.styles.Doc.GetFrameSize()
+		v += statusBarHeight
+		m.envList.SetSize(msg.Width-h, msg.Height-v)
+		m.actionList.SetSize(msg.Width-h, msg.Height-v-envInfoHeight)
+		m.logStreamList.SetSize(msg.Width-h, msg.Height-v)
+		m.rollbackList.SetSize(msg.Width-h, msg.Height-v)
+		m.presets.list.SetSize(msg.Width-h, msg.Height-v-presetsFooterHeight)
+		m.contextList.SetSize(msg.Width-h, msg.Height-v)
+		m.toolList.SetSize(msg.Width-h, msg.Height-v)
+		if m.currentScreen == ToolScreen {
+			// The actions list is made when a tool is opened, sized then
+			m.tool.actions.SetSize(msg.Width-h, msg.Height-v)
+		}
+		m.logView.SetSize(msg.Width-h, msg.Height-v)
+		m.timeline.view.SetSize(msg.Width-h, msg.Height-v-timelineChrome)
+		m.history.height = msg.Height - v
+		m.winners.height = msg.Height - v
+		m.history.input.Width = msg.Width - h - len(m.history.input.Prompt) - 3
+		m.overrides.SetSize(msg.Width-h, msg.Height-v)
+		m.extraFields.SetSize(msg.Width-h, msg.Height-v)
+
+	case spinner.TickMsg:
+		// Let the spinner stop on screens that don't show it, rather than
+		// ticking them into repaints
+		if !m.spinning() {
+			return m, nil
+		}
+		var cmd tea.Cmd
+		m.spinner, cmd = m.spinner.Update(msg)
+		return m, cmd
+	}
+
+	return m.updateFocused(msg)
+}
+
+// updateFocused passes a message to the list or input of the current screen
+func (m model) updateFocused(msg tea.Msg) (tea.Model, tea.Cmd) {
+	var cmd tea.Cmd
+	switch m.currentScreen {
+	case EnvironmentScreen:
+		m.envList, cmd = m.envList.Update(msg)
+	case ActionScreen:
+		m.actionList, cmd = m.actionList.Update(msg)
+	case LogStreamScreen:
+		m.logStreamList, cmd = m.logStreamList.Update(msg)
+	case RollbackScreen:
+		m.rollbackList, cmd = m.rollbackList.Update(msg)
+	case LogViewScreen:
+		m.logView, cmd = m.logView.Update(msg)
+	case ContextScreen:
+		m.contextList, cmd = m.contextList.Update(msg)
+	case ToolSelectScreen:
+		m.toolList, cmd = m.toolList.Update(msg)
+	case PromptScreen:
+		m.promptForm, cmd, _ = m.promptForm.Update(msg)
+	case OverridesScreen:
+		m.overrides.area, cmd = m.overrides.area.Update(msg)
+	case ExtraFieldsScreen:
+		m.extraFields.area, cmd = m.extraFields.area.Update(msg)
+	case ConfirmScreen:
+		if m.acking {
+			m.ackInput, cmd = m.ackInput.Update(msg)
+		}
+	}
+
+	return m, cmd
+}
+
+// spinning reports whether the current screen shows the spinner
+func (m model) spinning() bool {
+	switch m.currentScreen {
+	case LoadingScreen:
+		return true
+	case DoctorScreen:
+		return m.doctorChecks == nil
+	case OutputScreen:
+		return m.verifying
+	case ConfirmScreen:
+		return m.shadowRunning || m.approval.waiting
+	case TimelineScreen:
+		return m.timeline.loading
+	case HistoryScreen:
+		return m.history.loading
+	case ToolsScreen:
+		return m.toolsLoading
+	case ToolScreen:
+		return m.tool.stage == toolRunning
+	}
+	return false
+}
+
+// capturesInput reports whether the current screen has a list filter or text
+// input being typed into, which must receive printable keys before any
+// global binding
+func (m model) capturesInput() bool {
+	switch m.currentScreen {
+	case EnvironmentScreen:
+		return m.envList.SettingFilter()
+	case ActionScreen:
+		return m.actionList.SettingFilter()
+	case LogStreamScreen:
+		return m.logStreamList.SettingFilter()
+	case RollbackScreen:
+		return m.rollbackList.SettingFilter()
+	case LogViewScreen:
+		return m.logView.inputting()
+	case HistoryScreen:
+		return m.history.filtering
+	case EnvSwitchScreen:
+		return m.envSwitch.acking
+	case ToolSelectScreen:
+		return m.toolList.SettingFilter()
+	case PromptScreen, OverridesScreen, ExtraFieldsScreen:
+		return true
+	case ConfirmScreen:
+		return m.acking
+	}
+	return false
+}
+
+func (m model) View() string {
+	if accent := m.styles.accent(m.selectedEnv); accent != m.styles.Accent {
+		// The protected environments' accent, for this render only
+		m.spinner.Style = m.spinner.Style.Foreground(accent)
+		m.actionList.Styles.Title = m.actionList.Styles.Title.Background(accent)
+	}
+	return m.screenView() + "\n" + m.statusBar()
+}
+
+// statusBarHeight is the number of lines reserved below every screen for the status bar
+const statusBarHeight = 1
+
+// statusBar shows session-wide state such as the environment pin and the
+// remaining prod budget
+func (m model) statusBar() string {
+	if m.startNote != "" {
+		return budgetWarnStyle.MaxWidth(m.width).MaxHeight(statusBarHeight).Render(m.startNote)
+	}
+	if m.themeNote != "" {
+		return dimStyle.Width(m.width).MaxHeight(statusBarHeight).Render(m.themeNote)
+	}
+	var parts []string
+	if activeContext != "" || len(contextNames) > 0 {
+		parts = append(parts, pinnedStyle.Render(tr(msgStatusContext, contextLabel())))
+	}
+	if pinnedEnv != "" {
+		parts = append(parts, pinnedStyle.Render(tr(msgStatusPinned, pinnedEnv)))
+	}
+	if highLatency {
+		parts = append(parts, dimStyle.Render(tr(msgStatusHighLatency)))
+	}
+	if sessionDashboard != nil {
+		if err := sessionDashboard.stopped(); err != nil {
+			parts = append(parts, budgetWarnStyle.Render(tr(msgStatusServeStopped, err)))
+		} else {
+			parts = append(parts, dimStyle.Render(tr(msgStatusServing, sessionDashboard.url)))
+		}
+	}
+	if m.lockNote != "" {
+		parts = append(parts, budgetWarnStyle.Render(m.lockNote))
+	}
+	if versionBlock() != "" {
+		parts = append(parts, budgetExceededStyle.Render(tr(msgStatusOutdated)))
+	}
+	if isProtected(m.selectedEnv) && m.prodBudget.enabled() {
+		style := budgetStyle
+		switch {
+		case m.prodBudget.exceededBy(1):
+			style = budgetExceededStyle
+		case m.prodBudget.warn():
+			style = budgetWarnStyle
+		}
+		parts = append(parts, style.Render(m.prodBudget.String()))
+	}
+	parts = append(parts, dimStyle.Render(tr(msgStatusVersion, readBuildMetadata().String())))
+	return strings.Join(parts, " ")
+}
+
+func (m model) screenView() string {
+	switch m.currentScreen {
+	case EnvironmentScreen:
+		return m.styles.Doc.Render(m.envList.View())
+
+	case ActionScreen:
+		return m.styles.Doc.Render(m.envInfoView() + "\n" + m.actionList.View())
+
+	case LogStreamScreen:
+		return m.styles.Doc.Render(m.logStreamList.View())
+
+	case RollbackScreen:
+		if m.rollbackLoading {
+			return m.styles.Doc.Render(fmt.Sprintf("\n\n  %s\n\n  %s\n", tr(msgRollbackListTitle), tr(msgLoading)))
+		}
+		return m.styles.Doc.Render(m.rollbackList.View())
+
+	case PresetsScreen:
+		return m.styles.Doc.Render(m.presetsView())
+
+	case LogViewScreen:
+		return m.styles.Doc.Render(m.logView.View())
+
+	case TimelineScreen:
+		return m.styles.Doc.Render(m.timelineView())
+
+	case HistoryScreen:
+		return m.styles.Doc.Render(m.historyView())
+
+	case WinnersScreen:
+		return m.styles.Doc.Render(m.winnersView())
+
+	case EnvSwitchScreen:
+		return m.envSwitchView()
+
+	case ContextScreen:
+		return m.styles.Doc.Render(m.contextList.View())
+
+	case ResumeScreen:
+		cp := m.checkpoint
+		return m.styles.Doc.Render(fmt.Sprintf("\n\n  %s\n\n  %s\n  %s\n  %s\n  %s\n\n  %s\n",
+			tr(msgResumeTitle),
+			tr(msgResumeAction, cp.Payload.Action),
+			tr(msgResumeEnv, cp.Env),
+			tr(msgResumeStarted, formatTime(cp.StartedAt, time.Now())),
+			tr(msgResumePhase, cp.Phase),
+			tr(msgResumeHelp)))
+
+	case PromptScreen:
+		var sb strings.Builder
+		sb.WriteString(fmt.Sprintf("\n\n  %s\n\n", m.promptQuestion))
+		sb.WriteString("  " + strings.ReplaceAll(m.promptBody(m.promptForm.View()), "\n", "\n  ") + "\n\n")
+
+		if m.promptMessage != "" {
+			sb.WriteString("  " + strings.ReplaceAll(m.promptMessage, "\n", "\n  ") + "\n\n")
+		}
+
+		if m.discarding {
+			sb.WriteString("  " + budgetWarnStyle.Render(tr(msgDiscardDraft, m.selectedAction)) + "\n")
+			return m.styles.Doc.Render(sb.String())
+		}
+		sb.WriteString("  " + m.promptForm.Help() + m.fieldHelpKeys() + "\n")
+		return m.styles.Doc.Render(sb.String())
+
+	case ConfirmScreen:
+		if m.batchRows != nil {
+			return m.styles.Doc.Render(m.batchConfirmView())
+		}
+		if m.selectedAction == investigateAction {
+			return m.styles.Doc.Render(m.investigationConfirmView())
+		}
+		var sb strings.Builder
+		payload := m.pendingPayload
+		jsonPayload, _ := json.MarshalIndent(payload, "  ", "  ")
+		sb.WriteString("\n\n  " + tr(msgConfirmTitle) + "\n\n")
+		summary := wrapText(invocationSummary(m.selectedEnv, payload), m.width-6)
+		sb.WriteString("  " + strings.ReplaceAll(summary, "\n", "\n  ") + "\n\n")
+		sb.WriteString("  " + tr(msgConfirmEnv, m.selectedEnv, profileLabel(m.selectedEnv)) + "\n")
+		sb.WriteString("  " + tr(msgConfirmFunction, functionLabel(m.selectedEnv)) + "\n")
+		if payload.Action == ActionStart && payload.DurationMinutes != nil {
+			sb.WriteString("  " + tr(msgConfirmDuration, durationEcho(*payload.DurationMinutes, time.Now())) + "\n")
+		}
+		sb.WriteString("  " + tr(msgConfirmPayload) + "\n  " + string(jsonPayload) + "\n\n")
+		sb.WriteString(m.transformView())
+		sb.WriteString(m.idempotencyView())
+		sb.WriteString(m.rewardPoolView())
+
+		sb.WriteString(m.modeView())
+		sb.WriteString(m.restrictionView())
+		sb.WriteString(m.recentErrorsView())
+		sb.WriteString(m.shadowView())
+		sb.WriteString(m.approvalView())
+		sb.WriteString(m.promotionView())
+		sb.WriteString(m.lifecycleView())
+		sb.WriteString(m.blockView())
+		sb.WriteString(m.budgetView())
+		sb.WriteString(m.ackView())
+
+		var extraKeys string
+		if dryRunApplies(payload.Action) {
+			sb.WriteString("  " + tr(msgConfirmDryRun, dryRunToggle(payload.DryRun), dryRunNote(payload)) + "\n\n")
+			extraKeys += tr(msgConfirmHelpDryRun)
+		}
+		if m.canShadowRun() {
+			extraKeys += tr(msgConfirmHelpShadow)
+		}
+
+		sb.WriteString("  " + tr(msgConfirmHelp, keys.help(keyConfirm), extraKeys, keys.help(keyBack)) + "\n")
+		if m.canSavePreset() {
+			sb.WriteString("  " + tr(msgConfirmSavePreset) + "\n")
+		}
+		if m.canSaveTemplate() {
+			sb.WriteString("  " + tr(msgConfirmSaveTemplate) + "\n")
+		}
+		if m.canEditExtraFields() {
+			sb.WriteString("  " + tr(msgConfirmExtraFields) + "\n")
+		}
+		if m.confirmNote != "" {
+			sb.WriteString("  " + m.confirmNote + "\n")
+		}
+		sb.WriteString("  " + tr(msgConfirmPrint) + "\n")
+		return m.styles.Doc.Render(sb.String())
+
+	case ErrorScreen:
+		var sb strings.Builder
+		class := classifyError(m.lambdaErr, m.lastInvocation)
+		wrap := lipgloss.NewStyle().Width(m.width - 6)
+		sb.WriteString("\n\n  " + errorStyle.Render(glyphs.Cross+" "+class.Title) + "\n\n")
+		sb.WriteString("  " + wrap.Render(class.Message) + "\n\n")
+		sb.WriteString("  " + tr(msgErrorHint) + " " + wrap.Render(class.Hint) + "\n\n")
+
+		if len(m.lambdaOutput) > 0 {
+			sb.WriteString("  " + tr(msgErrorPartialOutput) + "\n\n")
+			for _, line := range m.lambdaOutput {
+				sb.WriteString("  " + wrap.Render(line) + "\n")
+			}
+			sb.WriteString("\n")
+		}
+		if m.lambdaLogs != "" {
+			sb.WriteString("  " + tr(msgErrorPartialLogs) + "\n\n")
+		} else if m.logsPending {
+			sb.WriteString("  " + tr(msgLogsPending) + "\n\n")
+		}
+
+		help := tr(msgErrorHelp, keys.help(keyBack), keys.help(keyQuit))
+		if m.lastInvocation != nil {
+			help = tr(msgErrorHelpRetry, keys.help(keyBack), keys.help(keyQuit))
+		}
+		sb.WriteString("  " + strings.ReplaceAll(help, "\n", "\n  ") + "\n")
+		return m.styles.Doc.Render(sb.String())
+
+	case StatsScreen:
+		return m.styles.Doc.Render(fmt.Sprintf("\n\n  %s\n\n%s\n  %s\n", tr(msgStatsTitle), m.stats, tr(msgHelpBackQuit, keys.help(keyBack), keys.help(keyQuit))))
+
+	case DraftsScreen:
+		return m.styles.Doc.Render(m.draftsView())
+
+	case TemplatesScreen:
+		return m.styles.Doc.Render(m.templatesView())
+
+	case OverridesScreen:
+		return m.styles.Doc.Render(m.overridesView())
+
+	case ExtraFieldsScreen:
+		return m.styles.Doc.Render(m.extraFieldsView())
+
+	case ToolSelectScreen:
+		return m.styles.Doc.Render(m.toolList.View())
+
+	case ToolScreen:
+		return m.styles.Doc.Render(m.toolView())
+
+	case ToolsScreen:
+		return m.styles.Doc.Render(fmt.Sprintf("\n\n  %s\n\n%s\n  %s\n", tr(msgToolsTitle, m.selectedEnv), m.tools, tr(msgToolsHelp, keys.help(keyBack), keys.help(keyQuit))))
+
+	case DoctorScreen:
+		var sb strings.Builder
+		sb.WriteString("\n\n  " + tr(msgDoctorTitle, m.selectedEnv) + "\n\n")
+		if m.doctorChecks == nil {
+			sb.WriteString(fmt.Sprintf("  %s %s\n", m.spinner.View(), tr(msgDoctorRunning)))
+		}
+		for _, c := range m.doctorChecks {
+			if c.Err == nil {
+				sb.WriteString(fmt.Sprintf("  %s %s\n", glyphs.Check, c.Name))
+				continue
+			}
+			sb.WriteString(errorStyle.Render(fmt.Sprintf("  %s %s: %v", glyphs.Cross, c.Name, c.Err)) + "\n")
+			sb.WriteString(fmt.Sprintf("      %s\n", c.Hint))
+		}
+		sb.WriteString("\n  " + tr(msgHelpBackQuit, keys.help(keyBack), keys.help(keyQuit)) + "\n")
+		return m.styles.Doc.Render(sb.String())
+
+	case LoadingScreen:
+		status := tr(msgLoadingWait)
+		if m.progress != nil {
+			status = progressBar(*m.progress, 30)
+		}
+		if m.asyncStatus != "" {
+			status += "\n\n  " + tr(msgLoadingAsync, m.asyncStatus)
+		}
+		if m.awaitingResult {
+			status += "\n\n  " + tr(msgLoadingStop)
+		}
+		if m.batchRows != nil {
+			status += "\n\n  " + tr(msgLoadingBatchEsc)
+			if m.batchCancelling {
+				status += "\n\n  " + tr(msgLoadingStopping)
+			}
+		}
+		if badge := dryRunBadge(m.pendingPayload); badge != "" && m.batchRows == nil {
+			status = badge + "\n\n  " + status
+		}
+		return m.styles.Doc.Render(fmt.Sprintf("\n\n  %s %s\n\n  %s",
+			m.spinner.View(),
+			tr(msgLoadingTitle, m.selectedEnv, m.selectedAction),
+			status))
+
+	case OutputScreen:
+		var output string
+		if m.lambdaErr != nil {
+			output = glyphs.Cross + " " + tr(msgOutputError, m.lambdaErr) + "\n\n"
+		} else {
+			output = glyphs.Check + " " + tr(msgOutputSummary) + "\n\n"
+		}
+		if inv := m.lastInvocation; inv != nil && (profileOverride(inv.Env) != "" || readEnvOverrides().functionTemplate != "") {
+			output += tr(msgOutputInvokedWith, functionLabel(inv.Env), profileLabel(inv.Env)) + "\n\n"
+		}
+
+		switch {
+		case m.verifying:
+			check := toolRegistry[sweepstakeTool].VerifyMetric
+			output += m.spinner.View() + " " + tr(msgOutputVerifying, check.Namespace, check.Name, formatTimeout(check.Timeout)) + "\n\n"
+		case m.verification != nil:
+			output += strings.Join(m.verification.lines(), "\n") + "\n\n"
+		}
+
+		if m.lastInvocation != nil {
+			if badge := dryRunBadge(m.lastInvocation.Payload); badge != "" {
+				output += badge + "\n\n"
+			}
+			for _, line := range append(dryRunSummary(m.lastInvocation), rollbackSummary(m.lastInvocation)...) {
+				output += wrapText(line, m.width-4) + "\n"
+			}
+		}
+
+		if len(m.lambdaOutput) > 0 {
+			// Wrap long output lines
+			output += wrapText(strings.Join(m.visibleOutput(), "\n"), m.width-4) + "\n"
+		}
+
+		if m.lambdaLogs != "" {
+			output += "\n" + tr(msgOutputLogs) + "\n\n"
+			// Wrap the logs with appropriate width
+			output += wrapText(groupJSONEvents(m.lambdaLogs, false), m.width-4)
+		} else if m.logsPending {
+			output += "\n" + tr(msgOutputLogs) + "\n\n" + tr(msgLogsPending)
+		}
+
+		if m.outputMessage != "" {
+			output += "\n\n" + m.outputMessage
+		}
+
+		help := tr(msgHelpBackQuit, keys.help(keyBack), keys.help(keyQuit))
+		if m.lastInvocation != nil {
+			help = tr(msgOutputHelp, keys.help(keyCopy), logsSince, keys.help(keyBack), keys.help(keyQuit))
+		}
+		if m.lambdaLogs != "" {
+			help = tr(msgOutputHelpLogs, keys.help(keyLogs)) + " " + help
+		}
+		if m.winners.table != nil {
+			help = tr(msgOutputHelpWinners) + " " + help
+		}
+		if m.lastInvocation != nil {
+			if _, ok := responseElements(m.lastInvocation.Body); ok {
+				help = tr(msgOutputHelpExpand) + " " + help
+			}
+		}
+		return m.styles.Doc.Render(fmt.Sprintf("%s\n\n%s", output, help))
+	}
+
+	return tr(msgLoading)
+}
+
+// startInvocation checkpoints the payload and invokes the lambda in the
+// selected environment
+func (m model) startInvocation(payload EventPayload) (tea.Model, tea.Cmd) {
+	if approvalRequired(m.selectedEnv, payload) {
+		if breakGlassReason != "" {
+			return m.startInvocationWith(payload, breakGlassGrant())
+		}
+		// Retries and repeats go through the approval again
+		var budget tea.Cmd
+		if m.currentScreen != ConfirmScreen || !samePayload(m.pendingPayload, payload) {
+			m.pendingPayload = payload
+			budget = m.showConfirm()
+		}
+		next, cmd := m.startApproval()
+		return next, tea.Batch(budget, cmd)
+	}
+	return m.startInvocationWith(payload, approvalGrant{})
+}
+
+// startInvocationWith invokes a payload that got past the approval gate
+func (m model) startInvocationWith(payload EventPayload, grant approvalGrant) (tea.Model, tea.Cmd) {
+	cp := invocationCheckpoint{
+		Env:          m.selectedEnv,
+		FunctionName: sweepstakeFunction(m.selectedEnv),
+		Payload:      payload,
+		Phase:        phasePending,
+		Owner:        checkpointOwner,
+	}
+	saveCheckpoint(cp)
+	m.checkpoint = &cp
+
+	m.currentScreen = LoadingScreen
+	m.progress = nil
+	m.asyncStatus = ""
+	m.progressCh = make(chan progressMsg)
+	gen := m.awaitResult()
+	return m, tea.Batch(
+		m.spinner.Tick,
+		invokeLambdaCmd(gen, m.selectedEnv, payload, m.invokeMode, grant, m.progressCh),
+		waitForProgress(gen, m.progressCh),
+	)
+}
+
+// awaitResult starts waiting for the result of a new invocation, returning
+// the generation its lambdaResult has to carry
+func (m *model) awaitResult() int {
+	m.resultGen++
+	m.awaitingResult = true
+	return m.resultGen
+}
+
+// abandonInvocation stops waiting for the running invocation. A function
+// can't be called back, so it runs to the end and its outcome still reaches
+// the history and the checkpoint, but its result and progress are dropped
+// when they come in rather than replacing whatever is on screen by then.
+func (m model) abandonInvocation() (tea.Model, tea.Cmd) {
+	m.resultGen++
+	m.awaitingResult = false
+	if ch := m.progressCh; ch != nil {
+		// Nothing reads the progress anymore, the invocation mustn't block on it
+		go func() {
+			for range ch {
+			}
+		}()
+		m.progressCh = nil
+	}
+	m.progress = nil
+	m.asyncStatus = ""
+	m.currentScreen = ActionScreen
+	return m, tea.Batch(m.refreshEnvInfo(), m.actionList.NewStatusMessage(tr(msgLoadingAbandon)))
+}
+
+// openRollback lists the recent completes of the selected environment that
+// can be rolled back
+func (m model) openRollback() (tea.Model, tea.Cmd) {
+	m.rollbackList.SetItems(nil)
+	m.rollbackLoading = true
+	m.selectedAction = string(ActionRollback)
+	m.currentScreen = RollbackScreen
+	return m, loadRollbackCandidatesCmd(m.selectedEnv)
+}
 
-	// Process response
-	var responseObj map[string]interface{}
-	if err := json.Unmarshal(result.Payload, &responseObj); err != nil {
-		*output = append(*output, fmt.Sprintf("Raw response: %s", string(result.Payload)))
+// openPrompt shows the value prompt for an action list entry, prefilled with
+// the value last entered for it
+func (m *model) openPrompt(action string) {
+	m.preparePrompt(action)
+	m.restoreDraft()
+}
+
+// preparePrompt opens the prompt of an action with its default value
+func (m *model) preparePrompt(action string) {
+	m.selectedAction = action
+	m.loadedTemplate = nil
+	m.currentScreen = PromptScreen
+	m.promptMessage = ""
+	m.batchRows = nil
+	m.promptForm = newPromptForm(tr(msgLabelValue))
+	m.exampleField = ""
+	input := m.promptForm.Input(0)
+	// TODO Default to current sweepstake quest ID
+	// hit sweepstake api
+	value, ok := m.lastValues[action]
+	if !ok {
+		value = "3907"
+	}
+	input.SetValue(value)
+	if action == batchAction {
+		input.CharLimit = 0
+		input.SetValue(m.batchPath)
+		m.promptQuestion = tr(msgPromptBatch)
+		m.promptForm.SetLabel(0, tr(msgLabelBatchFile))
+		m.promptForm.SetName(0, "batch_file")
+	} else if action == string(ActionRollback) {
+		input.CharLimit = 200
+		input.SetValue("")
+		m.promptQuestion = tr(msgPromptRollback, derefInt(m.rollbackOf.QuestID))
+		m.promptForm.SetLabel(0, tr(msgLabelJustification))
+		m.promptForm.SetName(0, "justification")
+	} else if action == timelineAction {
+		m.promptQuestion = tr(msgPromptTimeline)
+		m.promptForm.SetLabel(0, tr(msgLabelQuestID))
+		m.promptForm.SetName(0, "sweepstake_quest_id")
+	} else if action == compareAction {
+		m.promptQuestion = tr(msgPromptCompare, prodEnv)
+		m.promptForm.SetLabel(0, tr(msgLabelQuestID))
+		m.promptForm.SetName(0, "sweepstake_quest_id")
+	} else if action == investigateAction {
+		m.promptQuestion = tr(msgPromptInvestigate)
+		m.promptForm = newPromptForm(tr(msgLabelQuestID), tr(msgLabelSnapshotID))
+		m.promptForm.SetName(0, "sweepstake_quest_id")
+		m.promptForm.SetName(1, "snapshot_id")
+		m.promptForm.SetValue(0, value)
+		snapshot := m.promptForm.Input(1)
+		snapshot.CharLimit = 100
+		snapshot.Width = 40
+	} else if action == string(ActionProcess) || action == string(ActionComplete) {
+		m.promptQuestion = tr(msgPromptQuest, action)
+		m.promptForm = newPromptForm(tr(msgLabelQuestID), tr(msgLabelBatchSize))
+		m.promptForm.SetName(0, "sweepstake_quest_id")
+		m.promptForm.SetName(1, "batch_size")
+		if action == string(ActionProcess) {
+			// Process takes a list of quest IDs too, run as a batch
+			m.promptQuestion = tr(msgPromptQuestList)
+			m.promptForm.Input(0).CharLimit = 200
+			m.promptForm.Input(0).Width = 40
+		}
+		m.promptForm.SetValue(0, value)
 	} else {
-		formattedResponse, _ := json.MarshalIndent(responseObj, "", "  ")
-		*output = append(*output, fmt.Sprintf("Response: %s", string(formattedResponse)))
+		m.promptQuestion = tr(msgPromptDuration, action)
+		m.promptForm.SetLabel(0, tr(msgLabelDuration))
+		m.promptForm.SetName(0, "duration_minutes")
+	}
+	for i := range m.promptForm.fields {
+		m.promptForm.Input(i).Placeholder = m.promptForm.fields[i].label
+	}
+	m.promptForm.MarkClean()
+	m.overrides.restored, m.extraFields.restored = "", ""
+	m.discarding = false
+}
+
+// newPromptForm builds the prompt's form with a field per label
+func newPromptForm(labels ...string) form {
+	f := newForm(tr(msgFormSubmit), labels...)
+	for i, label := range labels {
+		f.Input(i).Placeholder = label
+		f.Input(i).CharLimit = 10
+		f.Input(i).Width = 20
+	}
+	f.Focus(0)
+	return f
+}
+
+// showConfirm shows the confirmation screen with the error preview collapsed,
+// returning the recount of the prod budget it shows
+func (m *model) showConfirm() tea.Cmd {
+	m.currentScreen = ConfirmScreen
+	m.confirmNote = ""
+	m.recentErrorsOpen = false
+	m.recentErrorsLoading = false
+	m.recentErrors = nil
+	m.shadow, m.shadowRunning = nil, false
+	m.approval = approvalState{gen: m.approval.gen + 1}
+	m.acking = false
+	timeout := time.Duration(m.envInfo[m.selectedEnv].Timeout) * time.Second
+	m.invokeMode = chooseMode(m.pendingPayload.Action, timeout)
+	if m.selectedAction == investigateAction {
+		// The comparison needs the response, which async invokes don't return
+		m.invokeMode = modeDecision{Mode: modeSync, Recommended: modeSync}
+	}
+	return m.refreshBudget()
+}
+
+// confirmInvocation runs whatever the confirmation screen is showing
+func (m model) confirmInvocation() (tea.Model, tea.Cmd) {
+	if m.batchRows != nil {
+		for _, row := range m.batchRows {
+			if approvalRequired(m.selectedEnv, row.payload()) && breakGlassReason == "" {
+				m.approval.err = fmt.Errorf("%s in %s needs approval one invocation at a time, not from a batch", row.payload().Action, m.selectedEnv)
+				return m, nil
+			}
+		}
+		return m.startBatch()
+	}
+	if m.selectedAction == investigateAction {
+		return m.startInvestigation()
+	}
+	// The transform error is on the confirmation screen already
+	if _, err := encodePayload(m.selectedEnv, m.pendingPayload); err != nil {
+		return m, nil
+	}
+	return m.startInvocation(m.pendingPayload)
+}
+
+// pendingMutations is how many mutating invocations the confirmation would run
+func (m model) pendingMutations() int {
+	if m.batchRows == nil {
+		if mutates(m.pendingPayload) {
+			return 1
+		}
+		return 0
+	}
+	n := 0
+	for _, row := range m.batchRows {
+		if mutates(row.payload()) {
+			n++
+		}
+	}
+	return n
+}
+
+// refreshBudget recounts today's prod invocations from the history in the
+// background, nil when the selected environment has no budget
+func (m model) refreshBudget() tea.Cmd {
+	if !isProtected(m.selectedEnv) || prodDailyLimit <= 0 {
+		return nil
+	}
+	return loadProdBudgetCmd()
+}
+
+// requiredAck is the phrase that has to be typed to confirm, or "" when
+// pressing Enter is enough: the phrases of the checks that warned. A prod
+// rollback's phrase covers them all.
+func (m model) requiredAck() string {
+	if isProtected(m.selectedEnv) && m.batchRows == nil && m.pendingPayload.Action == ActionRollback {
+		return rollbackAckPhrase(m.pendingPayload)
+	}
+	var phrases []string
+	for _, r := range m.checkResults() {
+		if r.Verdict == checkWarn {
+			phrases = append(phrases, r.Ack)
+		}
+	}
+	return strings.Join(phrases, ", ")
+}
+
+// ackView renders the typed acknowledgment of the confirmation screen
+func (m model) ackView() string {
+	if !m.acking {
+		return ""
+	}
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("  Type %q to invoke:\n\n", m.requiredAck()))
+	sb.WriteString("  " + m.ackInput.View() + "\n\n")
+	if m.ackMessage != "" {
+		sb.WriteString("  " + m.ackMessage + "\n\n")
+	}
+	return sb.String()
+}
+
+// budgetView renders the budget warning of the confirmation screen
+func (m model) budgetView() string {
+	n := m.pendingMutations()
+	if !isProtected(m.selectedEnv) || n == 0 || checkDisabled(m.selectedEnv, "budget") {
+		return ""
+	}
+	switch {
+	case m.prodBudget.exceededBy(n):
+		return "  " + errorStyle.Render(glyphs.Warn+" "+tr(msgBudgetExceeded, m.prodBudget.Used, m.prodBudget.Limit)) + "\n\n"
+	case m.prodBudget.warn():
+		return fmt.Sprintf("  %s %s\n\n", glyphs.Warn, m.prodBudget)
+	}
+	return ""
+}
+
+// setLogs replaces the logs shown on the output screen, dropping the file of
+// full logs fetched for the previous ones
+func (m *model) setLogs(logs string) {
+	removeLogFile(m.lambdaLogsFile)
+	m.lambdaLogsFile, m.lambdaLogsNote = "", ""
+	m.lambdaLogs = logs
+}
+
+// setRepeatItem pins a "repeat last run" entry for inv to the top of the
+// action list, keeping the cursor on the entry it was on
+func (m *model) setRepeatItem(inv *invocation) {
+	// A rollback is never repeated without picking the complete again
+	if inv.Payload.Action == ActionRollback {
+		return
+	}
+	// Nor is a snapshot investigation, whose dry run can't be turned off
+	if inv.Payload.Action == ActionProcess && inv.Payload.SweepstakeOverrides != nil {
+		return
 	}
+	desc := tr(msgActionRepeatDesc, inv.Payload.Action, payloadValue(inv.Payload), inv.Env)
+	if inv.Payload.DryRun {
+		desc += " " + tr(msgActionRepeatDryRun)
+	}
+	repeat := item{title: tr(msgActionRepeatTitle), desc: desc, action: repeatAction}
 
-	// Check for function errors
-	if result.FunctionError != nil {
-		*output = append(*output, fmt.Sprintf("Function error: %s", *result.FunctionError))
+	if first, ok := m.actionList.Items()[0].(item); ok && first.action == repeatAction {
+		m.actionList.SetItem(0, repeat)
+		return
 	}
+	cursor := m.actionList.Index()
+	m.actionList.InsertItem(0, repeat)
+	m.actionList.Select(cursor + 1)
+}
 
-	// Decode and add logs if available
-	if result.LogResult != nil {
-		decodedLogs, err := decodeBase64(*result.LogResult)
+// startBatch runs the confirmed batch rows one after another, tracking them
+// with the loading screen's progress bar
+func (m model) startBatch() (tea.Model, tea.Cmd) {
+	m.currentScreen = LoadingScreen
+	m.batchResults = newBatchResults(m.batchRows)
+	m.batchResults[0].Status = batchRunning
+	m.batchResultsPath = batchResultsPath(m.batchPath, time.Now())
+	if m.batchIDs != "" {
+		path, err := questListResultsPath(time.Now())
 		if err != nil {
-			*output = append(*output, fmt.Sprintf("Error decoding logs: %v", err))
+			m.currentScreen = ConfirmScreen
+			m.approval.err = fmt.Errorf("no place for the results: %v", err)
+			return m, nil
+		}
+		m.batchResultsPath = path
+	}
+	m.batchCancelling, m.batchFailedFast = false, false
+	m.outputMessage = ""
+	if err := writeBatchResults(m.batchResultsPath, m.batchResults); err != nil {
+		m.outputMessage = tr(msgBatchWriteErr, err)
+	}
+	m.progress = &progressMsg{total: len(m.batchRows), unit: "quests"}
+	return m, tea.Batch(m.spinner.Tick, runBatchRowCmd(m.selectedEnv, m.batchRows[0], 0))
+}
+
+// confirmQuestList confirms processing a typed list of quest IDs as a batch,
+// each with the prompt's batch size
+func (m model) confirmQuestList(list string) (tea.Model, tea.Cmd) {
+	ids, err := parseQuestList(list)
+	if err != nil {
+		m.promptMessage = err.Error()
+		return m, m.promptForm.Focus(0)
+	}
+	batchSize, err := parseBatchSize(m.promptForm.Value(1))
+	if err != nil {
+		m.promptMessage = err.Error()
+		return m, m.promptForm.Focus(1)
+	}
+	m.lastValues[m.selectedAction] = list
+	m.batchPath, m.batchIDs = "", strings.TrimSpace(list)
+	m.batchRows = questListRows(ids, batchSize, promptDryRun(m.selectedEnv, ActionProcess))
+	m.batchAll, m.batchSubset, m.batchRetryOf = m.batchRows, nil, ""
+	return m, m.showConfirm()
+}
+
+// batchRowLabel heads the column numbering the rows, lines of a file or
+// places in a typed list
+func (m model) batchRowLabel() string {
+	if m.batchIDs != "" {
+		return "#"
+	}
+	return "LINE"
+}
+
+// finishBatch shows the status of every batch row on the output screen,
+// returning the recount of the prod budget the batch used
+func (m *model) finishBatch() tea.Cmd {
+	m.lambdaOutput = []string{batchSummary(m.batchResults), "", fmt.Sprintf("%-6s %-10s %-18s %s", m.batchRowLabel(), "QUEST", "STATUS", "DETAILS")}
+	for _, r := range m.batchResults {
+		details := r.Err
+		if r.Status == batchSucceeded {
+			details = fmt.Sprintf("%s, request %s", r.Duration.Round(time.Millisecond), r.RequestID)
+		}
+		m.lambdaOutput = append(m.lambdaOutput, fmt.Sprintf("%-6d %-10d %-18s %s", r.Row.Line, r.Row.QuestID, r.Status, details))
+	}
+	m.lambdaOutput = append(m.lambdaOutput, "", fmt.Sprintf("Results written to %s", m.batchResultsPath))
+	if m.batchFailedFast {
+		m.lambdaOutput = append(m.lambdaOutput, "Stopped at the first failed quest, as fail-fast was on")
+	}
+	if batchIncomplete(m.batchResults) && m.batchIDs == "" {
+		m.lambdaOutput = append(m.lambdaOutput, "Processing the same file again offers to run only the failed and skipped quests")
+	}
+	m.setLogs("")
+	m.lambdaErr = nil
+	if batchIncomplete(m.batchResults) {
+		m.lambdaErr = errors.New(batchSummary(m.batchResults))
+	}
+	m.batchCancelling, m.batchFailedFast = false, false
+	m.lastInvocation = nil
+	m.batchRows = nil
+	m.currentScreen = OutputScreen
+	return m.refreshBudget()
+}
+
+// envInfoHeight is the number of lines the env info header takes above the action list
+const envInfoHeight = 3
+
+// refreshEnvInfo starts a background refresh of the selected environment's
+// info unless the cached copy is still fresh
+func (m model) refreshEnvInfo() tea.Cmd {
+	env := m.selectedEnv
+	if info, ok := m.envInfo[env]; ok && !info.stale() && m.envInfoErr[env] == nil {
+		return nil
+	}
+	if m.envInfoRefreshing[env] {
+		return nil
+	}
+	m.envInfoRefreshing[env] = true
+	return fetchEnvInfoCmd(env)
+}
+
+// exportBundle writes the last invocation bundle to the working directory
+// and returns a status message for the output screen
+func (m model) exportBundle() string {
+	var identity *envInfo
+	if info, ok := m.envInfo[m.lastInvocation.Env]; ok {
+		identity = &info
+	}
+	path, err := writeBundle(".", m.lastInvocation, m.lambdaLogs, identity, time.Now())
+	if err != nil {
+		return fmt.Sprintf("Export failed: %v", err)
+	}
+	return fmt.Sprintf("Exported bundle to %s", path)
+}
+
+// envInfoView renders the cached identity and function info for the selected environment
+func (m model) envInfoView() string {
+	env := m.selectedEnv
+	info, ok := m.envInfo[env]
+
+	var status string
+	switch {
+	case m.identityPending() && m.envInfoErr[env] != nil && !m.envInfoRefreshing[env]:
+		status = glyphs.Warn + " " + tr(msgEnvInfoConfirmErr, m.envInfoErr[env])
+	case m.identityPending():
+		status = tr(msgEnvInfoConfirming)
+	case m.envInfoErr[env] != nil && ok:
+		status = glyphs.Warn + " " + tr(msgEnvInfoRefreshErr, formatAge(time.Since(info.FetchedAt)), m.envInfoErr[env])
+	case m.envInfoErr[env] != nil:
+		status = glyphs.Warn + " " + tr(msgEnvInfoFetchErr, m.envInfoErr[env])
+	case m.envInfoRefreshing[env] && ok:
+		status = tr(msgEnvInfoRefreshing, formatAge(time.Since(info.FetchedAt)))
+	case m.envInfoRefreshing[env]:
+		status = tr(msgEnvInfoFetching)
+	case ok:
+		status = tr(msgEnvInfoCached, formatAge(time.Since(info.FetchedAt)))
+	}
+
+	identity, function := tr(msgEnvInfoIdentityUnknown), tr(msgEnvInfoFunctionUnknown)
+	if ok {
+		identity = tr(msgEnvInfoIdentity, info.Arn, info.Account)
+		function = tr(msgEnvInfoFunction, info.FunctionName, info.Runtime, info.Timeout, info.LastModified)
+	}
+
+	style := dimStyle.Width(m.width - 4).MaxHeight(1)
+	return style.Render(identity) + "\n" + style.Render(function) + "\n" + style.Render(status)
+}
+
+// batchConfirmMaxRows limits how many rows the batch confirmation lists
+const batchConfirmMaxRows = 15
+
+// batchConfirmView renders the confirmation of a batch file run
+func (m model) batchConfirmView() string {
+	var sb strings.Builder
+	sb.WriteString("\n\n  " + tr(msgBatchTitle) + "\n\n")
+	sb.WriteString("  " + tr(msgConfirmEnv, m.selectedEnv, profileLabel(m.selectedEnv)) + "\n")
+	sb.WriteString("  " + tr(msgConfirmFunction, functionLabel(m.selectedEnv)) + "\n")
+	if m.batchIDs != "" {
+		sb.WriteString("  " + tr(msgBatchQuests, m.batchIDs, len(m.batchRows)) + "\n\n")
+	} else {
+		sb.WriteString("  " + tr(msgBatchFile, m.batchPath, len(m.batchRows)) + "\n\n")
+	}
+	sb.WriteString(m.restrictionView())
+	if m.batchRetryOf != "" {
+		if len(m.batchRows) == len(m.batchAll) {
+			sb.WriteString("  " + tr(msgBatchRunningAll, len(m.batchAll), len(m.batchSubset), m.batchRetryOf) + "\n\n")
 		} else {
-			*logs = decodedLogs
+			sb.WriteString("  " + tr(msgBatchRunningFailed, len(m.batchSubset), len(m.batchAll), m.batchRetryOf) + "\n\n")
+		}
+	}
+	failFast := tr(msgBatchFailFastOff)
+	if m.batchFailFast {
+		failFast = tr(msgBatchFailFastOn)
+	}
+	sb.WriteString(fmt.Sprintf("  %s  %s\n\n", tr(msgBatchFailFast, dryRunToggle(m.batchFailFast)), dimStyle.Render(failFast)))
+	sb.WriteString(fmt.Sprintf("  %-6s %-10s %-10s %s\n", m.batchRowLabel(), "QUEST", "BATCH", "DRY RUN"))
+	for i, row := range m.batchRows {
+		if i == batchConfirmMaxRows {
+			sb.WriteString("  " + tr(msgBatchMore, len(m.batchRows)-i) + "\n")
+			break
+		}
+		batchSize := "-"
+		if row.BatchSize != nil {
+			batchSize = strconv.Itoa(*row.BatchSize)
+		}
+		sb.WriteString(fmt.Sprintf("  %-6d %-10d %-10s %t\n", row.Line, row.QuestID, batchSize, row.DryRun))
+	}
+	sb.WriteString("\n")
+	sb.WriteString(m.recentErrorsView())
+	sb.WriteString(m.blockView())
+	sb.WriteString(m.budgetView())
+	sb.WriteString(m.approvalView())
+	sb.WriteString(m.ackView())
+	help := msgBatchHelp
+	if m.batchIDs != "" {
+		help = msgBatchHelpDryRun
+	}
+	sb.WriteString("  " + tr(help, keys.help(keyConfirm), keys.help(keyBack)) + "\n")
+	return sb.String()
+}
+
+// recentErrorsView renders the recent error panel of the confirmation screen
+func (m model) recentErrorsView() string {
+	if !m.recentErrorsOpen {
+		return ""
+	}
+	style := dimStyle.Width(m.width - 6).MaxHeight(1)
+	note := func(s string) string { return "  " + style.Render(s) + "\n\n" }
+	switch r := m.recentErrors; {
+	case m.recentErrorsLoading:
+		return note(tr(msgRecentErrorsFetching))
+	case r == nil:
+		return ""
+	case r.err != nil:
+		return note(glyphs.Warn + " " + tr(msgRecentErrorsFetchErr, r.err))
+	case len(r.events) == 0:
+		return note(tr(msgRecentErrorsNone, int(recentErrorsWindow.Minutes())))
+	}
+
+	var sb strings.Builder
+	sb.WriteString("  " + tr(msgRecentErrorsTitle, int(recentErrorsWindow.Minutes())) + "\n")
+	for _, e := range m.recentErrors.events {
+		sb.WriteString("  " + style.Render(formatLogEvent(e)) + "\n")
+	}
+	return sb.String() + "\n"
+}
+
+// invokeLambdaCmd invokes the lambda while tailing its logs for progress
+// lines, which are sent on progress until the invocation returns
+func invokeLambdaCmd(gen int, env string, payload EventPayload, mode modeDecision, grant approvalGrant, progress chan progressMsg) tea.Cmd {
+	return func() tea.Msg {
+		inv := &invocation{Env: env, Payload: payload, Mode: mode.Mode, ModeReason: mode.why(), Approval: grant}
+
+		// The tail closes progress itself so a slow log fetch can't delay the result
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go func() {
+			defer close(progress)
+			tailProgress(ctx, env, sweepstakeFunction(env), time.Now(), toolRegistry[sweepstakeTool].ProgressPattern, progress)
+		}()
+
+		err := invokeLambda(context.Background(), inv, func(phase, requestID string) {
+			saveCheckpoint(invocationCheckpoint{
+				Env:          env,
+				FunctionName: inv.FunctionName,
+				Payload:      payload,
+				Phase:        phase,
+				RequestID:    requestID,
+			})
+		}, func(status string) {
+			progress <- progressMsg{status: status}
+		})
+		finishInvocation(inv, err)
+		return lambdaResult{output: invocationLines(inv, err), logs: inv.Logs, err: err, inv: inv, gen: gen}
+	}
+}
+
+// invokeLambda invokes the sweepstake lambda for inv.Env and inv.Payload,
+// filling in the rest of inv, which invocationLines or invocationJSON
+// render. The invocation phase is reported to onPhase (if set) so callers
+// can checkpoint it, and the status of a pending async invocation to
+// onStatus (if set).
+func invokeLambda(ctx context.Context, inv *invocation, onPhase func(phase, requestID string), onStatus func(status string)) error {
+	if onPhase == nil {
+		onPhase = func(string, string) {}
+	}
+
+	env, payload := inv.Env, inv.Payload
+	profile := profileMap[env]
+	functionName := sweepstakeFunction(env)
+	inv.FunctionName = functionName
+
+	checks := newCheckContext(env, payload)
+	checks.Approval = inv.Approval
+	if reason := blockReason(runChecks(invokeChecks, checks)); reason != "" {
+		return fmt.Errorf("%s", reason)
+	}
+
+	payloadBytes, err := encodePayload(env, payload)
+	if err != nil {
+		return err
+	}
+	if toolRegistry[sweepstakeTool].PayloadTransform != nil {
+		inv.SentPayload = payloadBytes
+	}
+
+	// AWS SSO session check
+	if inv.SSOLogin, err = checkSSOSession(profile, nil); err != nil {
+		return err
+	}
+
+	cfg, err := config.LoadDefaultConfig(ctx,
+		config.WithSharedConfigProfile(profile),
+		envRegion(env),
+		withSkewRecording(),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to load AWS config: %w", err)
+	}
+
+	// Create Lambda client
+	client := lambda.NewFromConfig(cfg)
+
+	tool := toolRegistry[sweepstakeTool]
+	if tool.Transport == transportHTTP {
+		url, ok := tool.URLs[env]
+		if !ok {
+			return fmt.Errorf("no URL configured for %s in %s", sweepstakeTool, env)
+		}
+		inv.URL = url
+	}
+
+	if tool.Transport == transportLambda {
+		if err := checkFunctionExists(ctx, client, env, functionName); err != nil {
+			return err
+		}
+	}
+
+	onPhase(phaseInvoking, "")
+
+	if inv.Mode == modeAsync && tool.Transport == transportLambda {
+		return invokeAsync(ctx, client, inv, payloadBytes, onPhase, onStatus)
+	}
+	inv.Mode = modeSync
+
+	inv.StartedAt = time.Now()
+	var result invokeResponse
+	if tool.Transport == transportHTTP {
+		result, err = invokeHTTP(ctx, cfg, inv.URL, payloadBytes, replayable(inv.Payload))
+	} else {
+		result, err = invokeFunction(ctx, client, functionName, payloadBytes)
+	}
+	inv.Duration = time.Since(inv.StartedAt)
+	if err != nil && tool.Transport == transportHTTP {
+		return fmt.Errorf("failed to call %s: %w", inv.URL, err)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to invoke Lambda: %w", err)
+	}
+
+	requestID := result.RequestID
+	inv.RequestID = requestID
+	inv.setResponse(result.Payload, result.StatusCode)
+	onPhase(phaseCompleted, requestID)
+	inv.FunctionError = result.FunctionError
+
+	// The log tail if available
+	switch {
+	case result.LogsErr != nil:
+		inv.LogsErr = result.LogsErr.Error()
+	case result.Logs != "":
+		inv.Logs = result.Logs
+		// JSON format functions log one object per line, render them as columns
+		fn, err := client.GetFunctionConfiguration(context.Background(), &lambda.GetFunctionConfigurationInput{
+			FunctionName: aws.String(functionName),
+		})
+		if err == nil && fn.LoggingConfig != nil && fn.LoggingConfig.LogFormat == types.LogFormatJson {
+			inv.Logs = formatJSONLogTail(result.Logs)
 		}
 	}
 
+	if inv.StatusCode >= 400 && tool.Transport == transportHTTP {
+		return &httpStatusError{StatusCode: inv.StatusCode}
+	}
+	if inv.StatusCode >= 400 {
+		return &playtools.StatusError{StatusCode: inv.StatusCode}
+	}
 	return nil
 }
 
-func decodeBase64(encoded string) (string, error) {
-	// AWS Go SDK already decodes the base64 for us in LogResult
-	decoded, err := base64.StdEncoding.DecodeString(encoded)
+// setResponse records the response of a call with its HTTP status code,
+// unwrapping a {"statusCode": 200, "body": "..."} style response into the
+// body and status code it carries
+func (inv *invocation) setResponse(payload []byte, statusCode int) {
+	inv.Response, inv.Body, inv.StatusCode = payload, payload, statusCode
+	if body, code, ok := playtools.UnwrapEnvelope(payload); ok {
+		inv.Body, inv.StatusCode = body, code
+	}
+}
+
+// ssoLoginLines note an expired SSO session logged in again
+var ssoLoginLines = []string{"SSO session expired. Logging in...", "SSO login successful"}
+
+// invocationLines renders an invocation, as far as it got before err, for
+// the output screen and the command line
+func invocationLines(inv *invocation, err error) []string {
+	lines := []string{fmt.Sprintf("Environment: %s", inv.Env)}
+	jsonPayload, _ := json.MarshalIndent(inv.Payload, "", "  ")
+	lines = append(lines, fmt.Sprintf("Payload: %s", jsonPayload))
+	if inv.SentPayload != nil {
+		lines = append(lines, fmt.Sprintf("Sent as: %s", inv.SentPayload))
+	}
+	if inv.SSOLogin {
+		lines = append(lines, ssoLoginLines...)
+	}
+	if inv.URL != "" {
+		lines = append(lines, fmt.Sprintf("Endpoint: %s", inv.URL))
+	}
+	if inv.RequestID == "" && inv.Response == nil {
+		return lines
+	}
+
+	if inv.Mode == modeAsync {
+		lines = append(lines,
+			fmt.Sprintf("Lambda invoked asynchronously (%s)", inv.ModeReason),
+			"Polling the logs until it finishes...",
+			fmt.Sprintf("Request ID: %s", inv.RequestID))
+		switch {
+		case inv.FunctionError != "":
+			lines = append(lines, fmt.Sprintf("Function error: %s", inv.FunctionError))
+		case err == nil:
+			lines = append(lines, "Async invocation finished, the response is only available in the logs")
+		}
+		return lines
+	}
+
+	_, _, enveloped := playtools.UnwrapEnvelope(inv.Response)
+	switch {
+	case inv.URL != "" && !enveloped:
+		lines = append(lines, fmt.Sprintf("HTTP request completed with status %d", inv.StatusCode))
+	case inv.URL != "":
+		lines = append(lines, "HTTP request completed")
+	default:
+		lines = append(lines, "Lambda invocation successful!")
+	}
+	if inv.RequestID != "" {
+		lines = append(lines, fmt.Sprintf("Request ID: %s", inv.RequestID))
+	}
+	if enveloped {
+		lines = append(lines, fmt.Sprintf("Raw envelope: %s", string(inv.Response)))
+		lines = append(lines, fmt.Sprintf("Status code: %d", inv.StatusCode))
+	}
+	if _, err := indentJSON(inv.Body); err == nil {
+		lines = append(lines, responseSummary(inv.Body, time.Now())...)
+	}
+	lines = append(lines, responseLine(inv.Body))
+	if inv.FunctionError != "" {
+		lines = append(lines, fmt.Sprintf("Function error: %s", inv.FunctionError))
+	}
+	if inv.LogsErr != "" {
+		lines = append(lines, fmt.Sprintf("Error decoding logs: %s", inv.LogsErr))
+	}
+	return lines
+}
+
+// invokeResponse is the outcome of a call over any transport
+type invokeResponse struct {
+	Payload       []byte
+	StatusCode    int
+	RequestID     string
+	FunctionError string
+	// Logs is the decoded log tail of a lambda, and LogsErr why it couldn't
+	// be decoded
+	Logs    string
+	LogsErr error
+}
+
+// invokeFunction invokes the lambda directly with the tail of its logs
+func invokeFunction(ctx context.Context, client *lambda.Client, functionName string, payload []byte) (invokeResponse, error) {
+	result, err := playtools.InvokeFunction(ctx, client, functionName, payload)
 	if err != nil {
-		return "", fmt.Errorf("failed to decode base64: %v", err)
+		return invokeResponse{}, err
+	}
+	return invokeResponse{
+		Payload:       result.Response,
+		RequestID:     result.RequestID,
+		FunctionError: result.FunctionError,
+		Logs:          result.Logs,
+		LogsErr:       result.LogsErr,
+	}, nil
+}
+
+// dryRunSummary checks off the overrides of a start dry run, or lists the
+// validation errors the lambda returned for them, under its dry run badge
+func dryRunSummary(inv *invocation) []string {
+	if inv.Payload.Action != ActionStart || !inv.Payload.DryRun {
+		return nil
+	}
+	var lines []string
+	errs := validationErrors(inv.Body)
+	if len(errs) == 0 && inv.Body != nil && inv.FunctionError == "" && inv.StatusCode < 400 {
+		lines = append(lines, "  "+glyphs.Check+" overrides are valid")
+	}
+	for _, e := range errs {
+		lines = append(lines, "  "+glyphs.Cross+" "+e)
+	}
+	if len(lines) == 0 {
+		return nil
 	}
-	return string(decoded), nil
+	return append(lines, "")
 }
 
-func checkSSOSession(profile string, output *[]string) error {
+// validationErrors extracts validation errors from a lambda response. Errors
+// may be plain strings or objects, which are rendered as compact JSON.
+func validationErrors(response []byte) []string {
+	var resp map[string]json.RawMessage
+	if err := json.Unmarshal(response, &resp); err != nil {
+		return nil
+	}
+
+	var errs []string
+	for _, key := range []string{"validation_errors", "override_errors", "errors"} {
+		var items []json.RawMessage
+		if err := json.Unmarshal(resp[key], &items); err != nil {
+			continue
+		}
+		for _, item := range items {
+			var str string
+			if err := json.Unmarshal(item, &str); err == nil {
+				errs = append(errs, str)
+			} else {
+				errs = append(errs, string(item))
+			}
+		}
+	}
+	return errs
+}
+
+// checkSSOSession logs in again when the profile's SSO session expired,
+// reporting whether it had to. onLogin (if set) is called before logging in.
+func checkSSOSession(profile string, onLogin func()) (bool, error) {
 	if _, err := exec.LookPath("aws"); err != nil {
-		return fmt.Errorf("AWS CLI not found")
+		return false, fmt.Errorf("AWS CLI not found")
 	}
 
 	cmd := exec.Command("aws", "sts", "get-caller-identity", "--profile", profile)
 	if err := cmd.Run(); err != nil {
-		*output = append(*output, "SSO session expired. Logging in...")
+		if onLogin != nil {
+			onLogin()
+		}
 		loginCmd := exec.Command("aws", "sso", "login", "--profile", profile)
 		out, err := loginCmd.CombinedOutput()
 		if err != nil {
-			return fmt.Errorf("SSO login failed: %v\nOutput: %s", err, out)
+			return false, fmt.Errorf("SSO session expired and login failed: %v\nOutput: %s", err, out)
 		}
-		*output = append(*output, "SSO login successful")
+		return true, nil
 	}
-	return nil
+	return false, nil
+}
+
+func main() {
+	os.Exit(run())
 }
 
-// fetchLambdaLogs fetches recent logs using AWS CLI
-func fetchLambdaLogs(profile, functionName string) (string, error) {
-	// This is a simplified version, we're using the logs returned by the Lambda invocation
-	// If you need more detailed logs, you would use the AWS CLI or CloudWatch Logs API here
-	cmd := exec.Command("aws", "logs", "tail", fmt.Sprintf("/aws/lambda/%s", functionName), "--profile", profile)
-	out, err := cmd.CombinedOutput()
+// run is the whole program, returning its exit code so that the deferred
+// cleanup, the session record in particular, happens on every path out
+func run() int {
+	args, err := parseGlobalFlags(os.Args[1:])
 	if err != nil {
-		return "", fmt.Errorf("failed to fetch logs: %v", err)
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		return exitUsage
+	}
+	// A bug report bundles the last debug log rather than starting a new one
+	if debugMode && (len(args) == 0 || args[0] != "bugreport") {
+		closeLog, err := startDebugLog()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Error:", err)
+			return exitFailure
+		}
+		defer closeLog()
+	}
+	if len(args) > 0 {
+		return runCLI(args)
 	}
-	return strings.TrimSpace(string(out)), nil
-}
 
-func main() {
-	p := tea.NewProgram(initialModel(), tea.WithAltScreen())
+	if err := setupConfig(); err != nil {
+		if plainMode {
+			fmt.Println("Error loading config:", err)
+			return exitFailure
+		}
+		if !runConfigErrorScreen(err) {
+			return exitFailure
+		}
+		if err := setupBuiltInConfig(); err != nil {
+			fmt.Println("Error:", err)
+			return exitFailure
+		}
+	}
+	if plainMode {
+		return runPlain(os.Stdin, os.Stdout)
+	}
 
-	if _, err := p.Run(); err != nil {
+	if serveAddr != "" {
+		stop, err := startDashboard(serveAddr)
+		if err != nil {
+			fmt.Println("Error:", err)
+			return exitFailure
+		}
+		defer stop()
+	}
+
+	options := []tea.ProgramOption{tea.WithAltScreen()}
+	if highLatency {
+		options = append(options, tea.WithFPS(highLatencyFPS))
+	}
+	p := tea.NewProgram(crashGuard{initialModel()}, options...)
+	defer removeLogFiles()
+	defer recordSession()()
+
+	_, err = p.Run()
+	if crashPath != "" {
+		fmt.Printf("A crash report was written to %s, run playtools bugreport to bundle it for an issue.\n", crashPath)
+		return exitFailure
+	}
+	if err != nil {
 		fmt.Println("Error running program:", err)
-		os.Exit(1)
+		return exitFailure
+	}
+	if exitOutput != "" {
+		fmt.Print(exitOutput)
 	}
+	return 0
 }
@@ -0,0 +1,363 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/revrost/playtools/pkg/playtools"
+)
+
+// harness drives the model with scripted messages the way the program
+// would, without running the commands Update returns, so a test decides
+// the order results, progress, ticks and resizes arrive in
+type harness struct {
+	t *testing.T
+	m model
+}
+
+func newHarness(t *testing.T) *harness {
+	t.Helper()
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	if err := setupConfig(); err != nil {
+		t.Fatal(err)
+	}
+	m := initialModel()
+	m.selectedEnv = devEnv
+	m.currentScreen = ActionScreen
+	h := &harness{t: t, m: m}
+	h.send(tea.WindowSizeMsg{Width: 100, Height: 40})
+	return h
+}
+
+// send feeds msgs to Update one at a time, rendering after each so a panic
+// in either is reported with the message that caused it
+func (h *harness) send(msgs ...tea.Msg) {
+	h.t.Helper()
+	for _, msg := range msgs {
+		func() {
+			defer func() {
+				if r := recover(); r != nil {
+					h.t.Fatalf("panic on %T %+v: %v", msg, msg, r)
+				}
+			}()
+			next, _ := h.m.Update(msg)
+			h.m = next.(model)
+			h.m.View()
+		}()
+	}
+}
+
+// start begins an invocation of payload, returning the generation its
+// result and progress carry
+func (h *harness) start(payload EventPayload) int {
+	h.t.Helper()
+	next, _ := h.m.startInvocationWith(payload, approvalGrant{})
+	h.m = next.(model)
+	if h.m.currentScreen != LoadingScreen {
+		h.t.Fatalf("started on screen %d, want the loading screen", h.m.currentScreen)
+	}
+	return h.m.resultGen
+}
+
+func (h *harness) tick() tea.Msg {
+	return h.m.spinner.Tick()
+}
+
+func (h *harness) wantScreen(want Screen) {
+	h.t.Helper()
+	if h.m.currentScreen != want {
+		h.t.Fatalf("on screen %d, want %d", h.m.currentScreen, want)
+	}
+}
+
+// result is the lambdaResult of a successful invocation of quest
+func result(gen, quest int, requestID string) lambdaResult {
+	payload := playtools.NewPayload(ActionProcess, quest)
+	inv := &invocation{Env: devEnv, Payload: payload, RequestID: requestID, Body: []byte(`{"processed":1}`)}
+	return lambdaResult{output: []string{"Request ID: " + requestID}, logs: "START RequestId: " + requestID, inv: inv, gen: gen}
+}
+
+func TestLoadingToOutput(t *testing.T) {
+	h := newHarness(t)
+	gen := h.start(playtools.NewPayload(ActionProcess, 42))
+	h.send(
+		h.tick(),
+		progressMsg{done: 3, total: 10, unit: "entries", gen: gen},
+		tea.WindowSizeMsg{Width: 60, Height: 20},
+		h.tick(),
+		progressMsg{status: "running", gen: gen},
+		result(gen, 42, "req-1"),
+	)
+	h.wantScreen(OutputScreen)
+	if h.m.width != 60 || h.m.lastInvocation == nil || h.m.lastInvocation.RequestID != "req-1" {
+		t.Errorf("width %d, last invocation %+v", h.m.width, h.m.lastInvocation)
+	}
+	if h.m.awaitingResult {
+		t.Error("still awaiting a result")
+	}
+}
+
+// The result can arrive anywhere among the other messages, down to a
+// terminal too small to show anything
+func TestResultInterleavings(t *testing.T) {
+	for at := 0; at <= 5; at++ {
+		t.Run(fmt.Sprint(at), func(t *testing.T) {
+			h := newHarness(t)
+			gen := h.start(playtools.NewPayload(ActionProcess, 7))
+			script := []tea.Msg{
+				tea.WindowSizeMsg{Width: 120, Height: 50},
+				h.tick(),
+				progressMsg{done: 1, total: 4, unit: "quests", gen: gen},
+				tea.WindowSizeMsg{Width: 10, Height: 3},
+				h.tick(),
+			}
+			script = append(script[:at], append([]tea.Msg{result(gen, 7, "req-7")}, script[at:]...)...)
+			h.send(script...)
+			h.wantScreen(OutputScreen)
+			if h.m.width != 10 || h.m.height != 3 {
+				t.Errorf("size %dx%d, want the last resize", h.m.width, h.m.height)
+			}
+		})
+	}
+}
+
+func TestFailedResultShowsError(t *testing.T) {
+	h := newHarness(t)
+	gen := h.start(playtools.NewPayload(ActionComplete, 9))
+	res := result(gen, 9, "")
+	res.err = errors.New("failed to invoke Lambda: boom")
+	h.send(h.tick(), tea.WindowSizeMsg{Width: 80, Height: 24}, res)
+	h.wantScreen(ErrorScreen)
+	if view := h.m.View(); !strings.Contains(view, "boom") {
+		t.Errorf("error screen doesn't show the error:\n%s", view)
+	}
+}
+
+// A result or progress of an abandoned invocation must not replace whatever
+// is on screen by the time it comes in
+func TestStaleResultIgnored(t *testing.T) {
+	h := newHarness(t)
+	first := h.start(playtools.NewPayload(ActionProcess, 1))
+	h.send(h.tick(), tea.KeyMsg{Type: tea.KeyEsc})
+	h.wantScreen(ActionScreen)
+
+	h.send(progressMsg{done: 5, total: 5, gen: first}, result(first, 1, "req-old"))
+	h.wantScreen(ActionScreen)
+	if h.m.lastInvocation != nil || h.m.progress != nil {
+		t.Fatalf("abandoned invocation reached the model: %+v, %+v", h.m.lastInvocation, h.m.progress)
+	}
+
+	second := h.start(playtools.NewPayload(ActionProcess, 2))
+	if second == first {
+		t.Fatal("the second invocation has the first one's generation")
+	}
+	h.send(h.tick(), result(first, 1, "req-old"), tea.WindowSizeMsg{Width: 90, Height: 30})
+	h.wantScreen(LoadingScreen)
+
+	h.send(result(second, 2, "req-new"), result(first, 1, "req-old"))
+	h.wantScreen(OutputScreen)
+	if got := h.m.lastInvocation.RequestID; got != "req-new" {
+		t.Errorf("output shows %s, want req-new", got)
+	}
+	if out := strings.Join(h.m.lambdaOutput, "\n"); strings.Contains(out, "req-old") {
+		t.Errorf("stale output on screen: %s", out)
+	}
+}
+
+// Spinner ticks stop once nothing shows the spinner, rather than repainting
+// the output screen forever
+func TestTicksStopAfterResult(t *testing.T) {
+	h := newHarness(t)
+	gen := h.start(playtools.NewPayload(ActionProcess, 3))
+	h.send(result(gen, 3, "req-3"))
+	if _, cmd := h.m.Update(h.tick()); cmd != nil {
+		t.Error("a tick on the output screen scheduled another")
+	}
+}
+
+// typeText sends text a key at a time, failing if any key quits
+func (h *harness) typeText(text string) {
+	h.t.Helper()
+	for _, r := range text {
+		msg := tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{r}}
+		next, cmd := h.m.Update(msg)
+		h.m = next.(model)
+		if isQuit(cmd) {
+			h.t.Fatalf("typing %q quit at %q", text, r)
+		}
+	}
+}
+
+// isQuit reports whether cmd is tea.Quit
+func isQuit(cmd tea.Cmd) bool {
+	return cmd != nil && reflect.ValueOf(cmd).Pointer() == reflect.ValueOf(tea.Quit).Pointer()
+}
+
+// A list filter takes every printable key, the quit and back bindings
+// included, and only gives them up once the filter is closed
+func TestFilterTakesKeys(t *testing.T) {
+	for name, screen := range map[string]Screen{"environments": EnvironmentScreen, "actions": ActionScreen} {
+		t.Run(name, func(t *testing.T) {
+			h := newHarness(t)
+			h.m.currentScreen = screen
+			list := func() string {
+				if screen == EnvironmentScreen {
+					return h.m.envList.FilterValue()
+				}
+				return h.m.actionList.FilterValue()
+			}
+
+			h.typeText("/prod")
+			h.typeText("qb")
+			h.wantScreen(screen)
+			if got := list(); got != "prodqb" {
+				t.Errorf("filter is %q, want prodqb", got)
+			}
+
+			h.send(tea.KeyMsg{Type: tea.KeyEsc})
+			if h.m.capturesInput() {
+				t.Fatal("the filter still has the keyboard after Esc")
+			}
+			if _, cmd := h.m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'q'}}); !isQuit(cmd) {
+				t.Error("q doesn't quit once the filter is closed")
+			}
+		})
+	}
+}
+
+func TestPromptTakesKeys(t *testing.T) {
+	h := newHarness(t)
+	h.m.openPrompt(string(ActionProcess))
+	h.m.promptForm.SetValue(0, "")
+	h.typeText("prod qb")
+	h.wantScreen(PromptScreen)
+	if got := h.m.promptForm.Value(0); got != "prod qb" {
+		t.Errorf("prompt is %q, want what was typed", got)
+	}
+}
+
+func (h *harness) press(keys ...tea.KeyType) {
+	h.t.Helper()
+	for _, k := range keys {
+		h.send(tea.KeyMsg{Type: k})
+	}
+}
+
+// selectedAction is the action of the action list's cursor
+func (h *harness) selectedAction() string {
+	it, _ := h.m.actionList.SelectedItem().(item)
+	return it.action
+}
+
+// Going back from the output keeps the list cursor on the action that ran
+// and its value in the prompt, and pins one repeat entry above the actions
+func TestBackKeepsNavigation(t *testing.T) {
+	h := newHarness(t)
+	h.press(tea.KeyDown)
+	if h.selectedAction() != string(ActionProcess) {
+		t.Fatalf("cursor on %s, want process", h.selectedAction())
+	}
+	h.press(tea.KeyEnter)
+	h.wantScreen(PromptScreen)
+	h.m.promptForm.SetValue(0, "")
+	h.typeText("42")
+	// Past the optional batch size
+	h.press(tea.KeyEnter, tea.KeyEnter)
+	h.wantScreen(ConfirmScreen)
+
+	for run := 1; run <= 2; run++ {
+		gen := h.start(h.m.pendingPayload)
+		h.send(result(gen, 42, fmt.Sprintf("req-%d", run)))
+		h.wantScreen(OutputScreen)
+		h.typeText("b")
+		h.wantScreen(ActionScreen)
+
+		if h.m.selectedEnv != devEnv || h.selectedAction() != string(ActionProcess) {
+			t.Fatalf("run %d: back on %s/%s, want dev/process", run, h.m.selectedEnv, h.selectedAction())
+		}
+		repeats := 0
+		for _, li := range h.m.actionList.Items() {
+			if li.(item).action == repeatAction {
+				repeats++
+			}
+		}
+		if first := h.m.actionList.Items()[0].(item); first.action != repeatAction || repeats != 1 {
+			t.Fatalf("run %d: %d repeat entries, first is %s", run, repeats, first.action)
+		}
+
+		h.press(tea.KeyEnter)
+		h.wantScreen(PromptScreen)
+		if got := h.m.promptForm.Value(0); got != "42" {
+			t.Errorf("run %d: prompt has %q, want the last value", run, got)
+		}
+		h.press(tea.KeyEnter, tea.KeyEnter)
+		h.wantScreen(ConfirmScreen)
+	}
+
+	// The repeat entry goes straight to the confirmation of the last run
+	h.typeText("b")
+	h.wantScreen(PromptScreen)
+	h.press(tea.KeyEsc)
+	h.wantScreen(ActionScreen)
+	h.press(tea.KeyUp, tea.KeyUp)
+	if h.selectedAction() != repeatAction {
+		t.Fatalf("cursor on %s, want the repeat entry", h.selectedAction())
+	}
+	h.press(tea.KeyEnter)
+	h.wantScreen(ConfirmScreen)
+	if p := h.m.pendingPayload; p.Action != ActionProcess || p.SweepstakeQuestID == nil || *p.SweepstakeQuestID != 42 {
+		t.Errorf("repeat confirms %+v", p)
+	}
+}
+
+func TestParseDurationMinutes(t *testing.T) {
+	tests := []struct {
+		in   string
+		want int
+		err  string
+	}{
+		{"90", 90, ""},
+		{" 45 ", 45, ""},
+		{"48h", 48 * 60, ""},
+		{"2h30m", 150, ""},
+		{"7d", 7 * 24 * 60, ""},
+		{"1d12h", 36 * 60, ""},
+		{"365d", 365 * 24 * 60, ""},
+		{"0", 0, "Enter a valid positive number"},
+		{"-5", 0, "Enter a valid positive number"},
+		{"0d", 0, "Enter minutes or a duration like 48h, 2h30m or 7d"},
+		{"0h", 0, "Enter minutes or a duration like 48h, 2h30m or 7d"},
+		{"-2h", 0, "Enter minutes or a duration like 48h, 2h30m or 7d"},
+		{"-1d", 0, "Enter minutes or a duration like 48h, 2h30m or 7d"},
+		{"1d-1h", 0, "Enter minutes or a duration like 48h, 2h30m or 7d"},
+		{"soon", 0, "Enter minutes or a duration like 48h, 2h30m or 7d"},
+		{"90s", 0, "1m30s is not a whole number of minutes"},
+		{"1.5m", 0, "1m30s is not a whole number of minutes"},
+		// Past the cap, or far enough to overflow a time.Duration
+		{"366d", 0, "A sweepstake can run for at most 365 days"},
+		{"365d1m", 0, "A sweepstake can run for at most 365 days"},
+		{"9000h", 0, "A sweepstake can run for at most 365 days"},
+		{"300000d", 0, "A sweepstake can run for at most 365 days"},
+		{"99999999999999d", 0, "A sweepstake can run for at most 365 days"},
+		{"600000", 0, "A sweepstake can run for at most 365 days"},
+		{"3000000h", 0, "Enter minutes or a duration like 48h, 2h30m or 7d"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := parseDurationMinutes(tt.in)
+			if tt.err != "" {
+				if err == nil || err.Error() != tt.err {
+					t.Errorf("got %d, %v, want the error %q", got, err, tt.err)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Errorf("got %d, %v, want %d", got, err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,617 @@
+package main
+
+import "fmt"
+
+// msgKey identifies a piece of UI copy. Copy lives in the catalogs below
+// rather than in the views so the wording can be reviewed in one place and
+// swapped per locale.
+type msgKey string
+
+const (
+	msgEnvListTitle       msgKey = "env_list.title"
+	msgActionListTitle    msgKey = "action_list.title"
+	msgLogStreamListTitle msgKey = "log_stream_list.title"
+	msgRollbackListTitle  msgKey = "rollback_list.title"
+	msgContextListTitle   msgKey = "context_list.title"
+	msgPresetsListTitle   msgKey = "presets_list.title"
+	msgToolListTitle      msgKey = "tool_list.title"
+
+	msgPromptQuest       msgKey = "prompt.quest"
+	msgPromptQuestList   msgKey = "prompt.quest_list"
+	msgPromptDuration    msgKey = "prompt.duration"
+	msgPromptBatch       msgKey = "prompt.batch"
+	msgPromptRollback    msgKey = "prompt.rollback"
+	msgPromptTimeline    msgKey = "prompt.timeline"
+	msgPromptCompare     msgKey = "prompt.compare"
+	msgPromptInvestigate msgKey = "prompt.investigate"
+
+	msgLabelValue         msgKey = "label.value"
+	msgLabelQuestID       msgKey = "label.quest_id"
+	msgLabelDuration      msgKey = "label.duration"
+	msgLabelBatchFile     msgKey = "label.batch_file"
+	msgLabelJustification msgKey = "label.justification"
+	msgLabelSnapshotID    msgKey = "label.snapshot_id"
+	msgLabelBatchSize     msgKey = "label.batch_size"
+
+	msgFormSubmit     msgKey = "form.submit"
+	msgFormHelpButton msgKey = "form.help_button"
+	msgFormHelpField  msgKey = "form.help_field"
+	msgFormNextField  msgKey = "form.next_field"
+	msgFormContinue   msgKey = "form.continue"
+
+	msgFieldHelpKey      msgKey = "field_help.key"
+	msgFieldExampleKey   msgKey = "field_help.example_key"
+	msgFieldHelpNone     msgKey = "field_help.none"
+	msgFieldHelpExamples msgKey = "field_help.examples"
+	msgFieldHelpSchema   msgKey = "field_help.schema_error"
+
+	msgEnvSwitchTitle  msgKey = "env_switch.title"
+	msgEnvSwitchHelp   msgKey = "env_switch.help"
+	msgEnvSwitchAck    msgKey = "env_switch.ack"
+	msgEnvSwitchPinned msgKey = "env_switch.pinned"
+	msgEnvSwitchActive msgKey = "env_switch.current"
+
+	msgAckMismatch msgKey = "ack.mismatch"
+
+	msgDiscardDraft  msgKey = "draft.discard"
+	msgDraftRestored msgKey = "draft.restored"
+	msgDraftSaveErr  msgKey = "draft.save_error"
+	msgDraftsTitle   msgKey = "drafts.title"
+	msgDraftsEmpty   msgKey = "drafts.empty"
+	msgDraftsHelp    msgKey = "drafts.help"
+
+	msgTemplatesTitle msgKey = "templates.title"
+	msgTemplatesEmpty msgKey = "templates.empty"
+	msgTemplatesHelp  msgKey = "templates.help"
+	msgTemplateLoaded msgKey = "templates.loaded"
+
+	msgOverridesTitle       msgKey = "overrides.title"
+	msgOverridesHelp        msgKey = "overrides.help"
+	msgOverridesSchema      msgKey = "overrides.schema"
+	msgOverridesPlaceholder msgKey = "overrides.placeholder"
+
+	msgExtraFieldsTitle       msgKey = "extra_fields.title"
+	msgExtraFieldsHelp        msgKey = "extra_fields.help"
+	msgExtraFieldsPlaceholder msgKey = "extra_fields.placeholder"
+
+	msgEnvDevTitle        msgKey = "env.dev.title"
+	msgEnvDevDesc         msgKey = "env.dev.desc"
+	msgEnvNonProdTitle    msgKey = "env.nonprod.title"
+	msgEnvNonProdDesc     msgKey = "env.nonprod.desc"
+	msgEnvProdTitle       msgKey = "env.prod.title"
+	msgEnvProdDesc        msgKey = "env.prod.desc"
+	msgEnvConfiguredDesc  msgKey = "env.configured.desc"
+	msgEnvPinnedDesc      msgKey = "env.pinned.desc"
+	msgEnvProtectedSuffix msgKey = "env.protected"
+
+	msgActionStartTitle       msgKey = "action.start.title"
+	msgActionStartDesc        msgKey = "action.start.desc"
+	msgActionProcessTitle     msgKey = "action.process.title"
+	msgActionProcessDesc      msgKey = "action.process.desc"
+	msgActionCompleteTitle    msgKey = "action.complete.title"
+	msgActionCompleteDesc     msgKey = "action.complete.desc"
+	msgActionBatchTitle       msgKey = "action.batch.title"
+	msgActionBatchDesc        msgKey = "action.batch.desc"
+	msgActionInvestigateTitle msgKey = "action.investigate.title"
+	msgActionInvestigateDesc  msgKey = "action.investigate.desc"
+	msgActionCompareTitle     msgKey = "action.compare.title"
+	msgActionCompareDesc      msgKey = "action.compare.desc"
+	msgActionTimelineTitle    msgKey = "action.timeline.title"
+	msgActionTimelineDesc     msgKey = "action.timeline.desc"
+	msgActionRollbackTitle    msgKey = "action.rollback.title"
+	msgActionRollbackDesc     msgKey = "action.rollback.desc"
+	msgActionPresetsTitle     msgKey = "action.presets.title"
+	msgActionPresetsDesc      msgKey = "action.presets.desc"
+	msgActionTemplatesTitle   msgKey = "action.templates.title"
+	msgActionTemplatesDesc    msgKey = "action.templates.desc"
+	msgActionRepeatTitle      msgKey = "action.repeat.title"
+	msgActionRepeatDesc       msgKey = "action.repeat.desc"
+	msgActionRepeatDryRun     msgKey = "action.repeat.dry_run"
+
+	msgStatusContext      msgKey = "status.context"
+	msgStatusPinned       msgKey = "status.pinned"
+	msgStatusHighLatency  msgKey = "status.high_latency"
+	msgStatusServing      msgKey = "status.serving"
+	msgStatusServeStopped msgKey = "status.serve_stopped"
+	msgStatusOutdated     msgKey = "status.outdated"
+	msgStatusVersion      msgKey = "status.version"
+
+	msgHelpBackQuit msgKey = "help.back_quit"
+	msgLoading      msgKey = "loading"
+	msgLogsPending  msgKey = "logs.pending"
+
+	msgResumeTitle   msgKey = "resume.title"
+	msgResumeAction  msgKey = "resume.action"
+	msgResumeEnv     msgKey = "resume.env"
+	msgResumeStarted msgKey = "resume.started"
+	msgResumePhase   msgKey = "resume.phase"
+	msgResumeHelp    msgKey = "resume.help"
+
+	msgConfirmTitle        msgKey = "confirm.title"
+	msgConfirmEnv          msgKey = "confirm.env"
+	msgConfirmFunction     msgKey = "confirm.function"
+	msgConfirmDuration     msgKey = "confirm.duration"
+	msgConfirmPayload      msgKey = "confirm.payload"
+	msgConfirmDryRun       msgKey = "confirm.dry_run"
+	msgConfirmHelp         msgKey = "confirm.help"
+	msgConfirmHelpDryRun   msgKey = "confirm.help_dry_run"
+	msgConfirmHelpShadow   msgKey = "confirm.help_shadow"
+	msgConfirmSavePreset   msgKey = "confirm.save_preset"
+	msgConfirmSaveTemplate msgKey = "confirm.save_template"
+	msgConfirmExtraFields  msgKey = "confirm.extra_fields"
+	msgConfirmPrint        msgKey = "confirm.print"
+	msgConfirmMode         msgKey = "confirm.mode"
+	msgConfirmRestriction  msgKey = "confirm.restriction"
+
+	msgErrorHint          msgKey = "error.hint"
+	msgErrorPartialOutput msgKey = "error.partial_output"
+	msgErrorPartialLogs   msgKey = "error.partial_logs"
+	msgErrorHelp          msgKey = "error.help"
+	msgErrorHelpRetry     msgKey = "error.help_retry"
+
+	msgStatsTitle    msgKey = "stats.title"
+	msgToolsTitle    msgKey = "tools.title"
+	msgToolsHelp     msgKey = "tools.help"
+	msgDoctorTitle   msgKey = "doctor.title"
+	msgDoctorRunning msgKey = "doctor.running"
+
+	msgLoadingTitle    msgKey = "loading.title"
+	msgLoadingWait     msgKey = "loading.wait"
+	msgLoadingAsync    msgKey = "loading.async"
+	msgLoadingStop     msgKey = "loading.stop"
+	msgLoadingBatchEsc msgKey = "loading.batch_stop"
+	msgLoadingStopping msgKey = "loading.batch_stopping"
+	msgLoadingAbandon  msgKey = "loading.abandoned"
+
+	msgOutputError       msgKey = "output.error"
+	msgOutputSummary     msgKey = "output.summary"
+	msgOutputInvokedWith msgKey = "output.invoked_with"
+	msgOutputVerifying   msgKey = "output.verifying"
+	msgOutputLogs        msgKey = "output.logs"
+	msgOutputHelp        msgKey = "output.help"
+	msgOutputHelpLogs    msgKey = "output.help_logs"
+	msgOutputHelpWinners msgKey = "output.help_winners"
+	msgOutputHelpExpand  msgKey = "output.help_expand"
+
+	msgInvalidPositive    msgKey = "invalid.positive"
+	msgInvalidDuration    msgKey = "invalid.duration"
+	msgDurationTooLong    msgKey = "invalid.duration_too_long"
+	msgDurationMinutes    msgKey = "invalid.duration_minutes"
+	msgSnapshotRequired   msgKey = "invalid.snapshot_required"
+	msgPresetNameRequired msgKey = "invalid.preset_name_required"
+
+	msgApprovalAsking     msgKey = "approval.asking"
+	msgApprovalWaiting    msgKey = "approval.waiting"
+	msgApprovalFailed     msgKey = "approval.failed"
+	msgApprovalNotInvoked msgKey = "approval.not_invoked"
+	msgApprovalBreakGlass msgKey = "approval.break_glass"
+	msgApprovalRequired   msgKey = "approval.required"
+
+	msgConfigErrorTitle    msgKey = "config_error.title"
+	msgConfigErrorProblems msgKey = "config_error.problems"
+	msgConfigErrorFix      msgKey = "config_error.fix"
+	msgConfigErrorHelp     msgKey = "config_error.help"
+
+	msgToolConfirmHelp msgKey = "tool.confirm_help"
+	msgToolOutputHelp  msgKey = "tool.output_help"
+
+	msgHistoryTitle         msgKey = "history.title"
+	msgHistoryReading       msgKey = "history.reading"
+	msgHistorySkipped       msgKey = "history.skipped"
+	msgHistoryEmpty         msgKey = "history.empty"
+	msgHistoryHelp          msgKey = "history.help"
+	msgHistoryLoadingMore   msgKey = "history.loading_more"
+	msgHistoryReadErr       msgKey = "history.read_error"
+	msgHistoryRetryComplete msgKey = "history.retry_complete"
+	msgHistoryRetryNoKey    msgKey = "history.retry_no_key"
+	msgHistoryRetryEnv      msgKey = "history.retry_env"
+
+	msgIdempotencyKey     msgKey = "idempotency.key"
+	msgIdempotencyRetried msgKey = "idempotency.retried"
+	msgIdempotencyFresh   msgKey = "idempotency.fresh"
+
+	msgInvestigateTitle   msgKey = "investigate.title"
+	msgInvestigateDryRun  msgKey = "investigate.dry_run"
+	msgInvestigateCompare msgKey = "investigate.compare"
+	msgInvestigateHelp    msgKey = "investigate.help"
+
+	msgLifecycleChecking    msgKey = "lifecycle.checking"
+	msgLifecycleCheckErr    msgKey = "lifecycle.check_error"
+	msgLifecycleUnavailable msgKey = "lifecycle.unavailable"
+	msgLifecycleState       msgKey = "lifecycle.state"
+	msgLifecycleStateCached msgKey = "lifecycle.state_cached"
+
+	msgLogViewHelp     msgKey = "log_view.help"
+	msgLogViewJSONTail msgKey = "log_view.json_tail"
+
+	msgBudgetExceeded msgKey = "budget.exceeded"
+
+	msgEnvInfoIdentity        msgKey = "env_info.identity"
+	msgEnvInfoIdentityUnknown msgKey = "env_info.identity_unknown"
+	msgEnvInfoFunction        msgKey = "env_info.function"
+	msgEnvInfoFunctionUnknown msgKey = "env_info.function_unknown"
+	msgEnvInfoConfirming      msgKey = "env_info.confirming"
+	msgEnvInfoConfirmErr      msgKey = "env_info.confirm_error"
+	msgEnvInfoFetching        msgKey = "env_info.fetching"
+	msgEnvInfoFetchErr        msgKey = "env_info.fetch_error"
+	msgEnvInfoRefreshing      msgKey = "env_info.refreshing"
+	msgEnvInfoRefreshErr      msgKey = "env_info.refresh_error"
+	msgEnvInfoCached          msgKey = "env_info.cached"
+
+	msgBatchTitle         msgKey = "batch.title"
+	msgBatchQuests        msgKey = "batch.quests"
+	msgBatchFile          msgKey = "batch.file"
+	msgBatchRunningAll    msgKey = "batch.running_all"
+	msgBatchRunningFailed msgKey = "batch.running_failed"
+	msgBatchFailFast      msgKey = "batch.fail_fast"
+	msgBatchFailFastOn    msgKey = "batch.fail_fast_on"
+	msgBatchFailFastOff   msgKey = "batch.fail_fast_off"
+	msgBatchMore          msgKey = "batch.more"
+	msgBatchHelp          msgKey = "batch.help"
+	msgBatchHelpDryRun    msgKey = "batch.help_dry_run"
+	msgBatchWriteErr      msgKey = "batch.write_error"
+
+	msgRecentErrorsTitle    msgKey = "recent_errors.title"
+	msgRecentErrorsFetching msgKey = "recent_errors.fetching"
+	msgRecentErrorsFetchErr msgKey = "recent_errors.fetch_error"
+	msgRecentErrorsNone     msgKey = "recent_errors.none"
+
+	msgLogsFetchingStreams msgKey = "logs.fetching_streams"
+	msgLogsStreamsErr      msgKey = "logs.streams_error"
+	msgLogsFetchingStream  msgKey = "logs.fetching_stream"
+	msgLogsStreamErr       msgKey = "logs.stream_error"
+	msgLogsStreamFull      msgKey = "logs.stream_full"
+	msgLogsStreamTail      msgKey = "logs.stream_tail"
+	msgLogsFetchingRecent  msgKey = "logs.fetching_recent"
+	msgLogsFetchErr        msgKey = "logs.fetch_error"
+	msgLogsFetched         msgKey = "logs.fetched"
+	msgLogsFetchedTail     msgKey = "logs.fetched_tail"
+
+	msgPresetsHelp        msgKey = "presets.help"
+	msgPresetsConflict    msgKey = "presets.conflict"
+	msgPresetsOverwrote   msgKey = "presets.overwrote"
+	msgPresetsReloaded    msgKey = "presets.reloaded"
+	msgPresetsBookmarkEnv msgKey = "presets.bookmark_env"
+	msgNamingHelp         msgKey = "naming.help"
+
+	msgCompareUnpinned msgKey = "compare.pinned"
+	msgCompareMismatch msgKey = "compare.mismatch"
+
+	msgShadowRunning msgKey = "shadow.running"
+	msgShadowErr     msgKey = "shadow.error"
+	msgShadowTitle   msgKey = "shadow.title"
+
+	msgTemplatesSave msgKey = "templates.save"
+
+	msgTimelineTitle     msgKey = "timeline.title"
+	msgTimelineSearching msgKey = "timeline.searching"
+	msgTimelineHelp      msgKey = "timeline.help"
+
+	msgTransformSentAs msgKey = "transform.sent_as"
+	msgTransformBroken msgKey = "transform.broken"
+
+	msgWinnersEmpty           msgKey = "winners.empty"
+	msgWinnersRows            msgKey = "winners.rows"
+	msgWinnersHelp            msgKey = "winners.help"
+	msgWinnersExporting       msgKey = "winners.exporting"
+	msgWinnersExported        msgKey = "winners.exported"
+	msgWinnersExportErr       msgKey = "winners.export_error"
+	msgWinnersExportCancelled msgKey = "winners.export_cancelled"
+)
+
+// defaultLocale is the catalog used for keys a locale doesn't translate
+const defaultLocale = "en"
+
+// uiLocale picks the catalog the UI copy comes from
+var uiLocale = defaultLocale
+
+// catalogs holds the UI copy per locale, in sentence case. Values are
+// fmt formats when the copy has arguments.
+var catalogs = map[string]map[msgKey]string{
+	defaultLocale: {
+		msgEnvListTitle:       "Select environment",
+		msgActionListTitle:    "Rewards tools",
+		msgLogStreamListTitle: "Select log stream",
+		msgRollbackListTitle:  "Select a complete to roll back",
+		msgContextListTitle:   "Switch context",
+		msgPresetsListTitle:   "Presets & bookmarks",
+		msgToolListTitle:      "Select tool",
+
+		msgPromptQuest:       "Enter the sweepstake quest ID to %s:",
+		msgPromptQuestList:   "Enter the sweepstake quest ID to process, or a list like 101,102,110-115 to process one after another:",
+		msgPromptDuration:    "Enter the sweepstake duration to %s, in minutes or like 48h, 2h30m or 7d:",
+		msgPromptBatch:       "Enter the CSV file of the quests to process:",
+		msgPromptRollback:    "Explain why quest %d needs a rollback:",
+		msgPromptTimeline:    "Enter the quest ID to see its timeline:",
+		msgPromptCompare:     "Enter the quest ID to compare against %s:",
+		msgPromptInvestigate: "Enter the quest and snapshot to investigate:",
+
+		msgLabelValue:         "Value",
+		msgLabelQuestID:       "Quest ID",
+		msgLabelDuration:      "Duration",
+		msgLabelBatchFile:     "CSV file",
+		msgLabelJustification: "Justification",
+		msgLabelSnapshotID:    "Snapshot ID",
+		msgLabelBatchSize:     "Batch size (optional)",
+
+		msgFormSubmit:     "Continue",
+		msgFormHelpButton: "Enter to %s, Shift+Tab to go back to the fields, Esc to go back",
+		msgFormHelpField:  "Enter to %s, Tab/Shift+Tab to move between fields, Esc to go back",
+		msgFormNextField:  "move to the next field",
+		msgFormContinue:   "continue",
+
+		msgFieldHelpKey:      "F1 for field help",
+		msgFieldExampleKey:   "Ctrl+Y to insert an example",
+		msgFieldHelpNone:     "No help for this field.",
+		msgFieldHelpExamples: "Examples:",
+		msgFieldHelpSchema:   "Couldn't read the schema: %v",
+
+		msgEnvSwitchTitle:  "Switch environment",
+		msgEnvSwitchHelp:   "Enter switches, Esc cancels",
+		msgEnvSwitchAck:    "Type %q to switch:",
+		msgEnvSwitchPinned: "The session is pinned to %s",
+		msgEnvSwitchActive: "(current)",
+
+		msgAckMismatch: "Type %q exactly or press Esc to cancel",
+
+		msgDiscardDraft:  "Discard your %s draft? y/N, or 's' to save it for next time",
+		msgDraftRestored: "Restored your draft from %s ago",
+		msgDraftSaveErr:  "Couldn't save the draft: %v",
+		msgDraftsTitle:   "Drafts",
+		msgDraftsEmpty:   "No drafts. Leaving a prompt you've typed into offers to save one.",
+		msgDraftsHelp:    "'x' deletes the selected draft, %s goes back or %s quits",
+
+		msgTemplatesTitle: "Payload templates",
+		msgTemplatesEmpty: "No templates. Press 'n' on a confirmation to save its payload as one.",
+		msgTemplatesHelp:  "%s fills in the prompt, 'x' deletes the selected template, %s goes back or %s quits",
+		msgTemplateLoaded: "Loaded template %s, its other fields are kept",
+
+		msgOverridesTitle:       "Sweepstake overrides for the start in %s (optional)",
+		msgOverridesHelp:        "Paste or type the overrides JSON, or leave it empty to send none. Ctrl+S continues, Esc goes back to the duration",
+		msgOverridesSchema:      "They're checked against the sweepstake_overrides of the tool's schema",
+		msgOverridesPlaceholder: `{"prize_tiers": [{"count": 1, "amount": "100"}]}`,
+
+		msgExtraFieldsTitle:       "Extra fields of the %s payload",
+		msgExtraFieldsHelp:        "A JSON object merged into the payload, for fields the lambda takes that the prompts don't ask for yet. Fields the prompts set can't be replaced. Ctrl+S applies it, leaving it empty removes them, Esc keeps them as they were",
+		msgExtraFieldsPlaceholder: `{"new_field": true}`,
+
+		msgEnvDevTitle:        "Development",
+		msgEnvDevDesc:         "Use development environment",
+		msgEnvNonProdTitle:    "Non-Production",
+		msgEnvNonProdDesc:     "Use non-production environment",
+		msgEnvProdTitle:       "Production",
+		msgEnvProdDesc:        "Use production environment",
+		msgEnvConfiguredDesc:  "Use the %s environment (profile %s)",
+		msgEnvPinnedDesc:      "Use the pinned environment",
+		msgEnvProtectedSuffix: "(protected)",
+
+		msgActionStartTitle:       "Create Sweepstake",
+		msgActionStartDesc:        "Create new sweepstake, overriding existing ones",
+		msgActionProcessTitle:     "Process Sweepstake",
+		msgActionProcessDesc:      "Process sweepstake calculation without distributing rewards",
+		msgActionCompleteTitle:    "Complete Sweepstake",
+		msgActionCompleteDesc:     "Complete sweepstake calculation and distribute rewards",
+		msgActionBatchTitle:       "Process Batch File",
+		msgActionBatchDesc:        "Process every quest of a CSV file (quest_id, batch_size, dry_run)",
+		msgActionInvestigateTitle: "Investigate Snapshot",
+		msgActionInvestigateDesc:  "Dry run a process against a historical snapshot and compare it with the live calculation",
+		msgActionCompareTitle:     "Compare Environments",
+		msgActionCompareDesc:      "Check a quest's status agrees with prod before promoting its configuration",
+		msgActionTimelineTitle:    "Quest Timeline",
+		msgActionTimelineDesc:     "Everything done to a quest, from the history and the function's logs",
+		msgActionRollbackTitle:    "Rollback Distribution",
+		msgActionRollbackDesc:     "Reverse the distribution of a recent complete",
+		msgActionPresetsTitle:     "Presets & Bookmarks",
+		msgActionPresetsDesc:      "Use, rename, reorder or delete saved form values",
+		msgActionTemplatesTitle:   "Payload Templates",
+		msgActionTemplatesDesc:    "Fill in a prompt from a saved payload, overrides and batch size included",
+		msgActionRepeatTitle:      "Repeat Last Run",
+		msgActionRepeatDesc:       "%s %s in %s",
+		msgActionRepeatDryRun:     "(dry run)",
+
+		msgStatusContext:      "context: %s",
+		msgStatusPinned:       "pinned: %s",
+		msgStatusHighLatency:  "high latency mode",
+		msgStatusServing:      "serving %s",
+		msgStatusServeStopped: "dashboard stopped: %v",
+		msgStatusOutdated:     "outdated build: read-only",
+		msgStatusVersion:      "playtools %s",
+
+		msgHelpBackQuit: "Press %s to go back or %s to quit",
+		msgLoading:      "Loading...",
+		msgLogsPending:  "Waiting for log delivery...",
+
+		msgResumeTitle:   "An invocation did not finish last time:",
+		msgResumeAction:  "Action: %s",
+		msgResumeEnv:     "Environment: %s",
+		msgResumeStarted: "Started: %s",
+		msgResumePhase:   "Last phase: %s",
+		msgResumeHelp:    "Press 'y' to look up its outcome in CloudWatch logs or 'n' to discard it",
+
+		msgConfirmTitle:        "Confirm invocation",
+		msgConfirmEnv:          "Environment: %s (profile %s)",
+		msgConfirmFunction:     "Function: %s",
+		msgConfirmDuration:     "Duration: %s",
+		msgConfirmPayload:      "Payload:",
+		msgConfirmDryRun:       "Dry run: %s  %s",
+		msgConfirmHelp:         "Press %s to invoke, %s'e' for recent errors or %s to go back",
+		msgConfirmHelpDryRun:   "'d' to toggle dry run, ",
+		msgConfirmHelpShadow:   "'s' for a shadow run, ",
+		msgConfirmSavePreset:   "'p' saves these values as a preset, 'm' bookmarks them for this environment",
+		msgConfirmSaveTemplate: "'n' saves the whole payload as a template",
+		msgConfirmExtraFields:  "'x' adds fields this version doesn't know to the payload",
+		msgConfirmPrint:        "'P' prints the payload and exits without invoking",
+		msgConfirmMode:         "Mode: %s (%s), 'a' to invoke %s",
+		msgConfirmRestriction:  "Your role %s likely can't run this, it %s. AWS decides, %s still invokes.",
+
+		msgErrorHint:          "Hint:",
+		msgErrorPartialOutput: "Output collected before the failure:",
+		msgErrorPartialLogs:   "Partial logs are available, press 'l' to view them",
+		msgErrorHelp:          "Press 'l' to view output and logs, 'd' to run doctor checks, %s to go back or %s to quit",
+		msgErrorHelpRetry:     "Press 'r' to retry, 'e' to edit the payload, 'l' to view output and logs,\n'd' to run doctor checks, %s to go back or %s to quit",
+
+		msgStatsTitle:    "Usage statistics",
+		msgToolsTitle:    "Discovered tools in %s",
+		msgToolsHelp:     "Press 'r' to discover again, %s to go back or %s to quit",
+		msgDoctorTitle:   "Doctor checks for %s",
+		msgDoctorRunning: "Running checks...",
+
+		msgLoadingTitle:    "Invoking Lambda in %s environment with action %s...",
+		msgLoadingWait:     "Please wait, this may take a few moments...",
+		msgLoadingAsync:    "Async invocation: %s",
+		msgLoadingStop:     "Press Esc to stop waiting for the result",
+		msgLoadingBatchEsc: "Press Esc to stop after the current quest",
+		msgLoadingStopping: "Stopping after the current quest...",
+		msgLoadingAbandon:  "Stopped waiting, the invocation may still complete; check the history for its outcome",
+
+		msgOutputError:       "Error: %v",
+		msgOutputSummary:     "Lambda Execution Summary:",
+		msgOutputInvokedWith: "Invoked %s with profile %s",
+		msgOutputVerifying:   "Verifying %s/%s (up to %s)...",
+		msgOutputLogs:        "--- Lambda Logs ---",
+		msgOutputHelp:        "Press 'r' to retry with the same idempotency key, 'x' to export a bundle, %s to copy the aws CLI command, 'S' to pick a log stream, 'L' for the last %s of logs, 't' for the quest timeline, %s to go back or %s to quit",
+		msgOutputHelpLogs:    "Press %s to view the logs.",
+		msgOutputHelpWinners: "Press 'w' to browse and export the winners.",
+		msgOutputHelpExpand:  "Press 'e' to expand or collapse the results.",
+
+		msgInvalidPositive:    "Enter a valid positive number",
+		msgInvalidDuration:    "Enter minutes or a duration like 48h, 2h30m or 7d",
+		msgDurationTooLong:    "A sweepstake can run for at most %d days",
+		msgDurationMinutes:    "%s is not a whole number of minutes",
+		msgSnapshotRequired:   "Enter the snapshot ID to investigate",
+		msgPresetNameRequired: "Enter a name",
+
+		msgApprovalAsking:     "Asking %s for approval...",
+		msgApprovalWaiting:    "Waiting for approval of request %s, up to %s. %s cancels.",
+		msgApprovalFailed:     "Approval failed: %v",
+		msgApprovalNotInvoked: "Not invoked, approval %s",
+		msgApprovalBreakGlass: "Approval skipped with --break-glass, this is recorded: %s",
+		msgApprovalRequired:   "%s asks for approval first, and invokes once it's approved",
+
+		msgConfigErrorTitle:    "Couldn't load the config file",
+		msgConfigErrorProblems: "The config file has %d problems",
+		msgConfigErrorFix:      "Fix the file and start playtools again, or carry on with the built-in\nenvironments and settings, leaving the whole file out.",
+		msgConfigErrorHelp:     "Press 'c' to continue with the built-in defaults or 'q' to quit",
+
+		msgToolConfirmHelp: "Press %s to invoke, %s to go back or %s to quit",
+		msgToolOutputHelp:  "Press 'r' to run it again, %s to go back to the actions or %s to quit",
+
+		msgHistoryTitle:         "History",
+		msgHistoryReading:       "Reading the history...",
+		msgHistorySkipped:       "(%d unreadable lines skipped)",
+		msgHistoryEmpty:         "No records match.",
+		msgHistoryHelp:          "%d of %d loaded. '/' filters, 'r' retries a complete with its key, %s goes back",
+		msgHistoryLoadingMore:   "Loading more...",
+		msgHistoryReadErr:       "Failed to read history: %v",
+		msgHistoryRetryComplete: "Only completes can be retried from the history",
+		msgHistoryRetryNoKey:    "This complete was recorded without its idempotency key",
+		msgHistoryRetryEnv:      "This complete was in %s, select that environment first",
+
+		msgIdempotencyKey:     "Idempotency key: %s (a retry reuses it)",
+		msgIdempotencyRetried: "Idempotency key: %s, reused from the attempt being retried ('k' for a fresh one to distribute again)",
+		msgIdempotencyFresh:   "Idempotency key: %s, fresh so the rewards are distributed again ('k' goes back to %s)",
+
+		msgInvestigateTitle:   "Confirm snapshot investigation",
+		msgInvestigateDryRun:  "Dry run: ON (always, for snapshot investigations)",
+		msgInvestigateCompare: "The live calculation is fetched with the status action afterwards to compare.",
+		msgInvestigateHelp:    "Press %s to investigate, 'e' for recent errors or %s to go back",
+
+		msgLifecycleChecking:    "Checking quest %s's state...",
+		msgLifecycleCheckErr:    "Couldn't check quest %s's state: %v",
+		msgLifecycleUnavailable: "%s unavailable for quest %s: %s. Type %q to invoke anyway.",
+		msgLifecycleState:       "Quest state: %s",
+		msgLifecycleStateCached: "Quest state: %s (as of %s ago, 'r' refreshes)",
+
+		msgLogViewHelp:     "'#' line numbers, 'w' wrap, 'J' expand JSON events, '/' search, 'n'/'N' next/previous match, ':' go to line, %s back",
+		msgLogViewJSONTail: "JSON events are only grouped in the invocation's log tail",
+
+		msgBudgetExceeded: "This goes over the daily prod budget (%d of %d used today)",
+
+		msgEnvInfoIdentity:        "Identity: %s (account %s)",
+		msgEnvInfoIdentityUnknown: "Identity: unknown",
+		msgEnvInfoFunction:        "Function: %s (%s, timeout %ds, modified %s)",
+		msgEnvInfoFunctionUnknown: "Function: unknown",
+		msgEnvInfoConfirming:      "confirming your identity...",
+		msgEnvInfoConfirmErr:      "failed to confirm your identity, select the environment again to retry: %v",
+		msgEnvInfoFetching:        "fetching identity...",
+		msgEnvInfoFetchErr:        "failed to fetch identity: %v",
+		msgEnvInfoRefreshing:      "cached %s ago, refreshing...",
+		msgEnvInfoRefreshErr:      "refresh failed, showing data from %s ago: %v",
+		msgEnvInfoCached:          "cached %s ago",
+
+		msgBatchTitle:         "Confirm batch",
+		msgBatchQuests:        "Quests: %s (%d quests)",
+		msgBatchFile:          "File: %s (%d quests)",
+		msgBatchRunningAll:    "Running all %d quests, 'r' to run only the %d that failed or weren't attempted in %s",
+		msgBatchRunningFailed: "Running only the %d of %d quests that failed or weren't attempted in %s, 'r' to run all",
+		msgBatchFailFast:      "Fail-fast: %s",
+		msgBatchFailFastOn:    "the first failed quest stops the rest",
+		msgBatchFailFastOff:   "a failed quest doesn't stop the rest",
+		msgBatchMore:          "... and %d more",
+		msgBatchHelp:          "Press %s to run, 'f' to toggle fail-fast, 'e' for recent errors or %s to go back",
+		msgBatchHelpDryRun:    "Press %s to run, 'd' to toggle dry run, 'f' to toggle fail-fast, 'e' for recent errors or %s to go back",
+		msgBatchWriteErr:      "Failed to write results: %v",
+
+		msgRecentErrorsTitle:    "Recent errors (last %d minutes):",
+		msgRecentErrorsFetching: "Fetching recent errors...",
+		msgRecentErrorsFetchErr: "could not fetch recent errors: %v",
+		msgRecentErrorsNone:     "No ERROR lines in the last %d minutes",
+
+		msgLogsFetchingStreams: "Fetching log streams...",
+		msgLogsStreamsErr:      "Failed to list log streams: %v",
+		msgLogsFetchingStream:  "Fetching logs from %s...",
+		msgLogsStreamErr:       "Failed to fetch logs from %s: %v",
+		msgLogsStreamFull:      "Showing full logs from %s",
+		msgLogsStreamTail:      "Showing the last %d of %d lines from %s, %s views them all",
+		msgLogsFetchingRecent:  "Fetching the last %s of logs...",
+		msgLogsFetchErr:        "Failed to fetch logs: %v",
+		msgLogsFetched:         "Fetched %s",
+		msgLogsFetchedTail:     "Fetched %s, showing the last %d, %s views them all",
+
+		msgPresetsHelp:        "%s use, 'r' rename, 'K'/'J' move up/down, 'x' delete, %s back",
+		msgPresetsConflict:    "The %ss in the %s changed on disk since they were loaded. Overwrite them? (y/n)",
+		msgPresetsOverwrote:   "Overwrote the changes on disk",
+		msgPresetsReloaded:    "Reloaded from disk, your change was discarded",
+		msgPresetsBookmarkEnv: "This bookmark is for %s, select that environment first",
+		msgNamingHelp:         "(enter to save, esc to cancel)",
+
+		msgCompareUnpinned: "Comparing with %s isn't possible while the session is pinned to %s",
+		msgCompareMismatch: "Quest %d's status in %s didn't match %s on %s. Type %q to start anyway.",
+
+		msgShadowRunning: "Shadow run against %s as a dry run...",
+		msgShadowErr:     "Shadow run: %v",
+		msgShadowTitle:   "Shadow run (dry run, request %s):",
+
+		msgTemplatesSave: "Save the %s payload as a template",
+
+		msgTimelineTitle:     "Quest %d timeline in %s: this tool's history and the runs logged in the last %d days",
+		msgTimelineSearching: "Searching the history and logs...",
+		msgTimelineHelp:      "'x' exports the timeline to Markdown",
+
+		msgTransformSentAs: "Sent as (%s):",
+		msgTransformBroken: "The payload can't be sent until the transform is fixed in the config file.",
+
+		msgWinnersEmpty:           "The winners list is empty.",
+		msgWinnersRows:            "Rows %s-%s of %s, page %s of %s",
+		msgWinnersHelp:            "'n'/'p' page, 'x' exports to CSV, %s goes back",
+		msgWinnersExporting:       "Exporting %s, Esc cancels",
+		msgWinnersExported:        "Exported %s winners to %s (%s)",
+		msgWinnersExportErr:       "Export failed: %v",
+		msgWinnersExportCancelled: "Export cancelled, the partial file was removed",
+	},
+}
+
+// tr is the UI copy of key in the current locale, falling back to the
+// default one, formatted with args when given
+func tr(key msgKey, args ...interface{}) string {
+	s, ok := catalogs[uiLocale][key]
+	if !ok {
+		if s, ok = catalogs[defaultLocale][key]; !ok {
+			s = string(key)
+		}
+	}
+	if len(args) > 0 {
+		return fmt.Sprintf(s, args...)
+	}
+	return s
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// viewFuncs are the functions besides the views and key handlers whose
+// copy belongs in the catalog
+var viewFuncs = map[string]bool{
+	"initialModel":        true,
+	"statusBar":           true,
+	"setRepeatItem":       true,
+	"abandonInvocation":   true,
+	"openRollback":        true,
+	"retryRecord":         true,
+	"finishWinnersExport": true,
+	"startComparison":     true,
+	"loadStatsCmd":        true,
+}
+
+// isViewFunc reports whether a function's copy belongs in the catalog: the
+// views, Update and the key handlers, and viewFuncs
+func isViewFunc(name string) bool {
+	return viewFuncs[name] || strings.HasSuffix(name, "View") || strings.HasPrefix(strings.ToLower(name), "update")
+}
+
+// maxViewLiteral is the most text a view's string literal may hold once its
+// verbs and spacing are dropped, room enough for a separator but not for a
+// sentence
+const maxViewLiteral = 20
+
+// formatVerb matches the fmt verbs of a layout format
+var formatVerb = regexp.MustCompile(`%[-+# 0-9.]*[a-zA-Z%]`)
+
+func TestNoCopyInViews(t *testing.T) {
+	fset := token.NewFileSet()
+	paths, err := filepath.Glob("*.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	found := map[string]bool{}
+	for _, path := range paths {
+		if strings.HasSuffix(path, "_test.go") {
+			continue
+		}
+		file, err := parser.ParseFile(fset, path, nil, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, decl := range file.Decls {
+			fn, ok := decl.(*ast.FuncDecl)
+			if !ok || fn.Body == nil || !isViewFunc(fn.Name.Name) {
+				continue
+			}
+			found[fn.Name.Name] = true
+			ast.Inspect(fn.Body, func(n ast.Node) bool {
+				lit, ok := n.(*ast.BasicLit)
+				if !ok || lit.Kind != token.STRING {
+					return true
+				}
+				s, err := strconv.Unquote(lit.Value)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if text := strings.Join(strings.Fields(formatVerb.ReplaceAllString(s, "")), " "); len(text) > maxViewLiteral {
+					t.Errorf("%s: %s has copy %s, move it to the message catalog", fset.Position(lit.Pos()), fn.Name.Name, lit.Value)
+				}
+				return true
+			})
+		}
+	}
+	for name := range viewFuncs {
+		if !found[name] {
+			t.Errorf("%s is no longer in the package, update viewFuncs", name)
+		}
+	}
+}
+
+func TestCatalogComplete(t *testing.T) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "messages.go", nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	seen := map[string]msgKey{}
+	for _, decl := range file.Decls {
+		gen, ok := decl.(*ast.GenDecl)
+		if !ok || gen.Tok != token.CONST {
+			continue
+		}
+		for _, spec := range gen.Specs {
+			vs := spec.(*ast.ValueSpec)
+			if ident, ok := vs.Type.(*ast.Ident); !ok || ident.Name != "msgKey" {
+				continue
+			}
+			for i, name := range vs.Names {
+				value, _ := strconv.Unquote(vs.Values[i].(*ast.BasicLit).Value)
+				key := msgKey(value)
+				if other, dup := seen[value]; dup {
+					t.Errorf("%s and %s share the key %q", other, name.Name, value)
+				}
+				seen[value] = msgKey(name.Name)
+				if _, ok := catalogs[defaultLocale][key]; !ok {
+					t.Errorf("%s has no %s copy", name.Name, defaultLocale)
+				}
+			}
+		}
+	}
+	if len(seen) != len(catalogs[defaultLocale]) {
+		t.Errorf("%d keys but %d %s entries", len(seen), len(catalogs[defaultLocale]), defaultLocale)
+	}
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbles/textarea"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// jsonEditorChrome is the rows of an editor screen around the editor
+const jsonEditorChrome = 10
+
+// jsonEditor is where JSON is pasted or typed over several lines: the
+// sweepstake overrides of a start, between the duration prompt and the
+// confirmation, and the extra fields of a payload. Its text is kept for the
+// next time it opens in the session.
+type jsonEditor struct {
+	area    textarea.Model
+	message string
+	// clean is the text it opened with, which leaving goes back to
+	clean string
+	// restored is the text of a restored draft, shown by the next open
+	// instead of the payload's
+	restored string
+}
+
+func newJSONEditor(placeholder string) jsonEditor {
+	area := textarea.New()
+	// Pasted overrides run to hundreds of lines, so neither is limited
+	area.CharLimit = 0
+	area.MaxHeight = 0
+	area.Placeholder = placeholder
+	if glyphs != unicodeGlyphs {
+		area.Prompt = glyphs.Rule + " "
+	}
+	return jsonEditor{area: area}
+}
+
+// SetSize fits the editor in the screen
+func (e *jsonEditor) SetSize(width, height int) {
+	e.area.SetWidth(max(20, width-4))
+	e.area.SetHeight(max(3, height-jsonEditorChrome))
+}
+
+// open shows text, or the restored draft when there's one, as the text
+// leaving goes back to. A restored draft counts as edited.
+func (e *jsonEditor) open(text string) {
+	e.area.SetValue(text)
+	e.clean = text
+	if e.restored != "" {
+		e.area.SetValue(e.restored)
+		e.restored = ""
+	}
+	e.message = ""
+}
+
+// revert drops what was typed since the editor opened
+func (e *jsonEditor) revert() {
+	e.area.Blur()
+	e.area.SetValue(e.clean)
+	e.message = ""
+}
+
+// Dirty reports whether the text was edited to something other than blank
+// since it opened, the input leaving would lose
+func (e jsonEditor) Dirty() bool {
+	v := strings.TrimSpace(e.area.Value())
+	return v != "" && v != strings.TrimSpace(e.clean)
+}
+
+// editorView renders an editor with its problems, indented like the rest of
+// the screen
+func (e jsonEditor) editorView() string {
+	var sb strings.Builder
+	sb.WriteString("  " + strings.ReplaceAll(e.area.View(), "\n", "\n  ") + "\n")
+	if e.message != "" {
+		sb.WriteString("\n")
+		for _, line := range strings.Split(e.message, "\n") {
+			sb.WriteString("  " + errorStyle.Render(glyphs.Cross+" "+line) + "\n")
+		}
+	}
+	return sb.String()
+}
+
+// indentedJSON is raw JSON as the editor shows it, indented when it's valid
+func indentedJSON(raw []byte) string {
+	var buf bytes.Buffer
+	if json.Indent(&buf, raw, "", "  ") != nil {
+		return string(raw)
+	}
+	return buf.String()
+}
+
+// parseOverrides reads the overrides typed in the editor, nil when it's left
+// empty, checking them against schema when there is one. They're kept as
+// typed, newlines included.
+func parseOverrides(text string, schema *jsonSchema) (*json.RawMessage, error) {
+	if strings.TrimSpace(text) == "" {
+		return nil, nil
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(text), &fields); err != nil {
+		var syntaxErr *json.SyntaxError
+		if errors.As(err, &syntaxErr) {
+			return nil, syntaxPosition(text, syntaxErr)
+		}
+		return nil, errors.New("the overrides must be a JSON object")
+	}
+	if schema != nil {
+		if err := checkOverrides(text, schema); err != nil {
+			return nil, err
+		}
+	}
+	raw := json.RawMessage(strings.TrimSpace(text))
+	return &raw, nil
+}
+
+// openOverrides opens the editor for the pending start, showing the
+// overrides a template brought along
+func (m model) openOverrides() (tea.Model, tea.Cmd) {
+	text := m.overrides.area.Value()
+	if o := m.pendingPayload.SweepstakeOverrides; o != nil {
+		text = indentedJSON(*o)
+	}
+	m.overrides.open(text)
+	m.currentScreen = OverridesScreen
+	return m, m.overrides.area.Focus()
+}
+
+// updateOverrides handles the keys of the overrides editor. Enter is a new
+// line, so Ctrl+S continues. Leaving edited overrides asks first.
+func (m model) updateOverrides(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "ctrl+c":
+		return m.leavePrompt(true)
+	case "esc":
+		return m.leavePrompt(false)
+	case "ctrl+s":
+		overrides, err := parseOverrides(m.overrides.area.Value(), m.overridesSchema)
+		if err != nil {
+			m.overrides.message = err.Error()
+			return m, nil
+		}
+		m.overrides.area.Blur()
+		m.pendingPayload.SweepstakeOverrides = overrides
+		return m, tea.Batch(m.showConfirm(), m.checkQuestState())
+	}
+	var cmd tea.Cmd
+	m.overrides.area, cmd = m.overrides.area.Update(msg)
+	return m, cmd
+}
+
+// overridesView renders the overrides editor
+func (m model) overridesView() string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("\n\n  %s\n\n", tr(msgOverridesTitle, m.selectedEnv)))
+	if d := m.pendingPayload.DurationMinutes; d != nil {
+		sb.WriteString("  " + tr(msgConfirmDuration, durationEcho(*d, time.Now())) + "\n\n")
+	}
+	sb.WriteString(m.overrides.editorView())
+	if m.discarding {
+		sb.WriteString("\n  " + budgetWarnStyle.Render(tr(msgDiscardDraft, m.selectedAction)) + "\n")
+		return sb.String()
+	}
+	help := tr(msgOverridesHelp)
+	if m.overridesSchema != nil {
+		help += ". " + tr(msgOverridesSchema)
+	}
+	sb.WriteString("\n  " + dimStyle.Render(help) + "\n")
+	return sb.String()
+}
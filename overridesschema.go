@@ -0,0 +1,303 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// jsonSchema is the part of a JSON Schema the overrides are checked against:
+// type, enum, properties, required, additionalProperties, items and local
+// $refs
+type jsonSchema struct {
+	Ref        string                 `json:"$ref"`
+	Type       schemaTypes            `json:"type"`
+	Enum       []json.RawMessage      `json:"enum"`
+	Properties map[string]*jsonSchema `json:"properties"`
+	Required   []string               `json:"required"`
+	// Additional is false, or the schema of the properties not listed
+	Additional  json.RawMessage        `json:"additionalProperties"`
+	Items       *jsonSchema            `json:"items"`
+	Defs        map[string]*jsonSchema `json:"$defs"`
+	Definitions map[string]*jsonSchema `json:"definitions"`
+
+	// root is the schema the $refs of the overrides schema point into
+	root *jsonSchema
+}
+
+// schemaTypes is a schema's type, a name or a list of them
+type schemaTypes []string
+
+func (t *schemaTypes) UnmarshalJSON(data []byte) error {
+	var name string
+	if json.Unmarshal(data, &name) == nil {
+		*t = schemaTypes{name}
+		return nil
+	}
+	var names []string
+	if err := json.Unmarshal(data, &names); err != nil {
+		return errors.New("a type is a name or a list of names")
+	}
+	*t = names
+	return nil
+}
+
+// parseOverridesSchema finds the sweepstake_overrides property of the
+// payload's schema, nil when the schema doesn't describe it
+func parseOverridesSchema(body []byte) (*jsonSchema, error) {
+	var root jsonSchema
+	if err := json.Unmarshal(body, &root); err != nil {
+		return nil, fmt.Errorf("the schema isn't JSON: %v", err)
+	}
+	overrides := root.Properties["sweepstake_overrides"]
+	if overrides == nil {
+		return nil, nil
+	}
+	overrides.root = &root
+	return overrides, nil
+}
+
+// resolve follows a local $ref like #/$defs/tier
+func (s *jsonSchema) resolve(root *jsonSchema) (*jsonSchema, error) {
+	for seen := 0; s.Ref != ""; seen++ {
+		if seen > 32 {
+			return nil, fmt.Errorf("$ref %s loops", s.Ref)
+		}
+		var defs map[string]*jsonSchema
+		name, ok := strings.CutPrefix(s.Ref, "#/$defs/")
+		if ok {
+			defs = root.Defs
+		} else if name, ok = strings.CutPrefix(s.Ref, "#/definitions/"); ok {
+			defs = root.Definitions
+		}
+		next := defs[name]
+		if next == nil {
+			return nil, fmt.Errorf("$ref %s isn't in the schema", s.Ref)
+		}
+		s = next
+	}
+	return s, nil
+}
+
+// check reports where value doesn't match the schema, each problem with the
+// path of the value
+func (s *jsonSchema) check(value any, path string, root *jsonSchema, report func(path, msg string)) {
+	s, err := s.resolve(root)
+	if err != nil {
+		report(path, err.Error())
+		return
+	}
+	if len(s.Type) > 0 && !s.Type.match(value) {
+		report(path, fmt.Sprintf("expected %s, got %s", strings.Join(s.Type, " or "), jsonType(value)))
+		return
+	}
+	if len(s.Enum) > 0 && !s.enumHas(value) {
+		allowed := make([]string, len(s.Enum))
+		for i, e := range s.Enum {
+			allowed[i] = string(compactJSON(e))
+		}
+		report(path, fmt.Sprintf("expected one of %s", strings.Join(allowed, ", ")))
+		return
+	}
+	switch v := value.(type) {
+	case map[string]any:
+		for _, name := range s.Required {
+			if _, ok := v[name]; !ok {
+				report(path, fmt.Sprintf("missing %s", name))
+			}
+		}
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			if prop := s.Properties[k]; prop != nil {
+				prop.check(v[k], joinConfigPath(path, k), root, report)
+				continue
+			}
+			switch extra := bytes.TrimSpace(s.Additional); {
+			case string(extra) == "false":
+				report(joinConfigPath(path, k), "not a known override")
+			case len(extra) > 0 && extra[0] == '{':
+				var schema jsonSchema
+				if json.Unmarshal(extra, &schema) == nil {
+					schema.check(v[k], joinConfigPath(path, k), root, report)
+				}
+			}
+		}
+	case []any:
+		if s.Items != nil {
+			for i, item := range v {
+				s.Items.check(item, fmt.Sprintf("%s[%d]", path, i), root, report)
+			}
+		}
+	}
+}
+
+// match reports whether value is of one of the types
+func (t schemaTypes) match(value any) bool {
+	for _, name := range t {
+		got := jsonType(value)
+		if name == got || name == "number" && got == "integer" {
+			return true
+		}
+	}
+	return false
+}
+
+// jsonType names the type of a value decoded with UseNumber
+func jsonType(value any) string {
+	switch v := value.(type) {
+	case map[string]any:
+		return "object"
+	case []any:
+		return "array"
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	case json.Number:
+		if strings.ContainsAny(v.String(), ".eE") {
+			return "number"
+		}
+		return "integer"
+	}
+	return "null"
+}
+
+func (s *jsonSchema) enumHas(value any) bool {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return false
+	}
+	for _, e := range s.Enum {
+		if bytes.Equal(compactJSON(e), data) {
+			return true
+		}
+	}
+	return false
+}
+
+func compactJSON(raw json.RawMessage) []byte {
+	var buf bytes.Buffer
+	if json.Compact(&buf, raw) != nil {
+		return raw
+	}
+	return buf.Bytes()
+}
+
+// checkOverrides checks overrides that parse against the schema, each
+// problem with the line of the value it's about
+func checkOverrides(text string, schema *jsonSchema) error {
+	dec := json.NewDecoder(strings.NewReader(text))
+	dec.UseNumber()
+	var value any
+	if err := dec.Decode(&value); err != nil {
+		return err
+	}
+	lines := valueLines(text)
+	type problem struct {
+		line int
+		err  error
+	}
+	var problems []problem
+	schema.check(value, "", schema.root, func(path, msg string) {
+		name := path
+		if name == "" {
+			name = "the overrides"
+		}
+		// A missing key is reported at the object it's missing from
+		line, ok := lines[path]
+		for !ok && path != "" {
+			path = parentPath(path)
+			line, ok = lines[path]
+		}
+		problems = append(problems, problem{line, fmt.Errorf("line %d: %s: %s", line, name, msg)})
+	})
+	sort.SliceStable(problems, func(i, j int) bool { return problems[i].line < problems[j].line })
+	errs := make([]error, len(problems))
+	for i, p := range problems {
+		errs[i] = p.err
+	}
+	return errors.Join(errs...)
+}
+
+// parentPath is the path of the object or list holding path
+func parentPath(path string) string {
+	if i := strings.LastIndexAny(path, ".["); i >= 0 {
+		return path[:i]
+	}
+	return ""
+}
+
+// valueLines maps the path of each value of a JSON text, like
+// prize_tiers[0].amount, to the line it starts on
+func valueLines(text string) map[string]int {
+	lines := map[string]int{}
+	dec := json.NewDecoder(strings.NewReader(text))
+	lineAt := func(offset int64) int {
+		return 1 + strings.Count(text[:offset], "\n")
+	}
+	var walk func(path string) error
+	walk = func(path string) error {
+		tok, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		lines[path] = lineAt(dec.InputOffset())
+		delim, ok := tok.(json.Delim)
+		if !ok {
+			return nil
+		}
+		for i := 0; dec.More(); i++ {
+			if delim == '[' {
+				if err := walk(fmt.Sprintf("%s[%d]", path, i)); err != nil {
+					return err
+				}
+				continue
+			}
+			key, err := dec.Token()
+			if err != nil {
+				return err
+			}
+			keyPath := joinConfigPath(path, fmt.Sprint(key))
+			keyLine := lineAt(dec.InputOffset())
+			if err := walk(keyPath); err != nil {
+				return err
+			}
+			// A member is where its key is
+			lines[keyPath] = keyLine
+		}
+		_, err = dec.Token()
+		return err
+	}
+	walk("")
+	return lines
+}
+
+// syntaxPosition describes where a JSON syntax error is, by line and column,
+// calling out the trailing comma JSON doesn't allow
+func syntaxPosition(text string, err *json.SyntaxError) error {
+	offset := min(int(err.Offset), len(text))
+	at := max(0, offset-1)
+	if at < len(text) && (text[at] == '}' || text[at] == ']') {
+		before := strings.TrimRight(text[:at], " \t\r\n")
+		if strings.HasSuffix(before, ",") {
+			line, col := lineColumn(text, len(before)-1)
+			return fmt.Errorf("line %d, column %d: trailing comma before '%c'", line, col, text[at])
+		}
+	}
+	line, col := lineColumn(text, at)
+	return fmt.Errorf("line %d, column %d: %v", line, col, err)
+}
+
+// lineColumn is the line and column of the byte at offset, counting from 1
+func lineColumn(text string, offset int) (int, int) {
+	start := strings.LastIndex(text[:offset], "\n") + 1
+	return 1 + strings.Count(text[:offset], "\n"), 1 + utf8.RuneCountInString(text[start:offset])
+}
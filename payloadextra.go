@@ -0,0 +1,96 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/revrost/playtools/pkg/playtools"
+)
+
+// samePayload reports whether two payloads are the same, which the extra
+// fields keep == from telling
+func samePayload(a, b EventPayload) bool {
+	return reflect.DeepEqual(a, b)
+}
+
+// parseExtraFields reads the extra fields typed in the editor, nil when it's
+// left empty
+func parseExtraFields(text string) (map[string]json.RawMessage, error) {
+	if strings.TrimSpace(text) == "" {
+		return nil, nil
+	}
+	var extra map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(text), &extra); err != nil {
+		var syntaxErr *json.SyntaxError
+		if errors.As(err, &syntaxErr) {
+			return nil, syntaxPosition(text, syntaxErr)
+		}
+		return nil, errors.New("the extra fields must be a JSON object")
+	}
+	if err := playtools.CheckExtraFields(extra); err != nil {
+		return nil, err
+	}
+	if len(extra) == 0 {
+		return nil, nil
+	}
+	return extra, nil
+}
+
+// canEditExtraFields reports whether the confirmed payload takes extra
+// fields, a single invocation built from the prompts
+func (m model) canEditExtraFields() bool {
+	return m.batchRows == nil && m.selectedAction != investigateAction
+}
+
+// openExtraFields opens the editor on the pending payload's extra fields
+func (m model) openExtraFields() (tea.Model, tea.Cmd) {
+	text := ""
+	if len(m.pendingPayload.Extra) > 0 {
+		data, _ := json.Marshal(m.pendingPayload.Extra)
+		text = indentedJSON(data)
+	}
+	m.extraFields.open(text)
+	m.currentScreen = ExtraFieldsScreen
+	return m, m.extraFields.area.Focus()
+}
+
+// updateExtraFields handles the keys of the extra fields editor, going back
+// to the confirmation either way. Leaving edited fields asks first.
+func (m model) updateExtraFields(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "ctrl+c":
+		return m.leavePrompt(true)
+	case "esc":
+		return m.leavePrompt(false)
+	case "ctrl+s":
+		extra, err := parseExtraFields(m.extraFields.area.Value())
+		if err != nil {
+			m.extraFields.message = err.Error()
+			return m, nil
+		}
+		m.extraFields.area.Blur()
+		m.pendingPayload.Extra = extra
+		m.currentScreen = ConfirmScreen
+		return m, nil
+	}
+	var cmd tea.Cmd
+	m.extraFields.area, cmd = m.extraFields.area.Update(msg)
+	return m, cmd
+}
+
+// extraFieldsView renders the extra fields editor
+func (m model) extraFieldsView() string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("\n\n  %s\n\n", tr(msgExtraFieldsTitle, m.pendingPayload.Action)))
+	sb.WriteString(m.extraFields.editorView())
+	if m.discarding {
+		sb.WriteString("\n  " + budgetWarnStyle.Render(tr(msgDiscardDraft, m.selectedAction)) + "\n")
+		return sb.String()
+	}
+	sb.WriteString("\n  " + dimStyle.Render(tr(msgExtraFieldsHelp)) + "\n")
+	return sb.String()
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+)
+
+// readPayloadFile reads a hand-written EventPayload from a JSON file, or
+// from in for "-". Unknown keys are rejected so a typo like "dryrun" isn't
+// silently dropped.
+func readPayloadFile(path string, in io.Reader) (EventPayload, error) {
+	return readPayload(path, in, false)
+}
+
+// readPayload reads a payload file, with keys EventPayload doesn't have kept
+// as its extra fields when extra is set and rejected otherwise
+func readPayload(path string, in io.Reader, extra bool) (EventPayload, error) {
+	var payload EventPayload
+	var data []byte
+	var err error
+	if path == "-" {
+		data, err = io.ReadAll(in)
+	} else {
+		data, err = os.ReadFile(path)
+	}
+	if err != nil {
+		return payload, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	if err := dec.Decode(&payload); err != nil {
+		return payload, fmt.Errorf("invalid payload: %v", err)
+	}
+	if dec.More() {
+		return payload, errors.New("invalid payload: expected a single JSON object")
+	}
+	// The payload decodes itself, so unknown keys are rejected here rather
+	// than by the decoder
+	if len(payload.Extra) > 0 && !extra {
+		keys := make([]string, 0, len(payload.Extra))
+		for k := range payload.Extra {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		return payload, fmt.Errorf("invalid payload: json: unknown field %q", keys[0])
+	}
+	return payload, validatePayload(payload)
+}
+
+// validatePayload checks a payload has a known action and the fields it needs
+func validatePayload(payload EventPayload) error {
+	switch payload.Action {
+	case ActionProcess, ActionComplete, ActionStatus:
+		if payload.SweepstakeQuestID == nil || *payload.SweepstakeQuestID <= 0 {
+			return fmt.Errorf("%s needs a positive sweepstake_quest_id", payload.Action)
+		}
+	case ActionStart:
+		if payload.DurationMinutes == nil || *payload.DurationMinutes <= 0 {
+			return fmt.Errorf("start needs a positive duration_minutes")
+		}
+	case ActionRollback:
+		if payload.OriginalRequestID == "" || payload.Justification == "" {
+			return fmt.Errorf("rollback needs original_request_id and justification")
+		}
+	case "":
+		return errors.New("the payload has no action")
+	default:
+		return fmt.Errorf("unknown action %q, expected %s, %s, %s, %s or %s",
+			payload.Action, ActionProcess, ActionComplete, ActionStart, ActionRollback, ActionStatus)
+	}
+	if payload.DryRun && !dryRunApplies(payload.Action) {
+		return fmt.Errorf("dry_run is not supported by %s", payload.Action)
+	}
+	if payload.BatchSize != nil && *payload.BatchSize <= 0 {
+		return errors.New("batch_size must be positive")
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+package playtools
+
+import (
+	"context"
+	"errors"
+
+	"github.com/aws/smithy-go"
+)
+
+// ErrorKind is the kind of failure an invocation hit, named the way the
+// playtools error screen titles it
+type ErrorKind string
+
+const (
+	// KindNone is a successful invocation
+	KindNone ErrorKind = ""
+	// KindFunctionError is a function that ran but failed
+	KindFunctionError ErrorKind = "Function error"
+	// KindFunctionNotFound is a function missing from the account or region
+	KindFunctionNotFound ErrorKind = "Function not found"
+	// KindAccessDenied is a role not allowed to invoke the function
+	KindAccessDenied ErrorKind = "Access denied"
+	// KindCredentialsRejected is an expired or invalid session
+	KindCredentialsRejected ErrorKind = "Credentials rejected"
+	// KindThrottled is a function at its concurrency limit
+	KindThrottled ErrorKind = "Throttled"
+	// KindInvalidPayload is a payload the Lambda API refused
+	KindInvalidPayload ErrorKind = "Invalid payload"
+	// KindTimedOut is an invocation that outlasted its context; the
+	// function may still be running
+	KindTimedOut ErrorKind = "Timed out"
+	// KindErrorResponse is an envelope status code of 400 or more
+	KindErrorResponse ErrorKind = "Error response"
+	// KindFailed is any other failure
+	KindFailed ErrorKind = "Invocation failed"
+)
+
+// Classify works out what kind of failure an invocation hit from what Invoke
+// returned. A nil err with a FunctionError on res is a function error. Only
+// the error's type and API error code are looked at, never its text.
+func Classify(res *InvocationResult, err error) ErrorKind {
+	if err == nil {
+		if res != nil && res.FunctionError != "" {
+			return KindFunctionError
+		}
+		return KindNone
+	}
+	var apiErr smithy.APIError
+	var statusErr *StatusError
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return KindTimedOut
+	case errors.As(err, &statusErr):
+		return KindErrorResponse
+	case errors.As(err, &apiErr):
+		switch apiErr.ErrorCode() {
+		case "ResourceNotFoundException":
+			return KindFunctionNotFound
+		case "AccessDeniedException", "AccessDenied":
+			return KindAccessDenied
+		case "ExpiredTokenException", "UnrecognizedClientException", "InvalidSignatureException":
+			return KindCredentialsRejected
+		case "TooManyRequestsException":
+			return KindThrottled
+		case "InvalidRequestContentException", "RequestTooLargeException":
+			return KindInvalidPayload
+		}
+	}
+	return KindFailed
+}
+
+// Retryable reports whether the same payload can be sent again as is: the
+// request was turned away before the function ran
+func (k ErrorKind) Retryable() bool {
+	return k == KindThrottled
+}
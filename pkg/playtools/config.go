@@ -0,0 +1,130 @@
+package playtools
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultFunctionTemplate names the function of an environment the config
+// file gives no function, the environment filling its %s
+const DefaultFunctionTemplate = "imx-rewards-%s-sweepstake-rewards-calculator"
+
+// CheckFunctionTemplate reports whether t renders a function name, with a
+// single %s for the environment
+func CheckFunctionTemplate(t string) error {
+	// %% is a literal percent sign, anything else is a verb
+	if strings.Count(t, "%s") != 1 || strings.Count(strings.ReplaceAll(t, "%%", ""), "%") != 1 {
+		return fmt.Errorf("expected exactly one %%s for the environment, got %q", t)
+	}
+	return nil
+}
+
+// DefaultProfiles returns the built-in environments and the AWS profile each
+// is invoked with, which the config file adds to or replaces
+func DefaultProfiles() map[string]string {
+	return map[string]string{
+		"dev":     "platform-dev-engineer",
+		"nonprod": "platform-nonprod-engineer",
+		"prod":    "platform-prod-engineer",
+	}
+}
+
+// Config is the part of the playtools config file an invocation needs: the
+// environments and the template their function names are rendered from. The
+// rest of the file, the TUI's settings and policies, is left to the command.
+type Config struct {
+	// The top level is the default context
+	ContextConfig `yaml:",inline"`
+	// Contexts are separate deployments, each with its own environments
+	Contexts map[string]ContextConfig `yaml:"contexts"`
+}
+
+// ContextConfig is the environments of one deployment
+type ContextConfig struct {
+	// FunctionTemplate replaces DefaultFunctionTemplate
+	FunctionTemplate string               `yaml:"function_template"`
+	Environments     map[string]EnvConfig `yaml:"environments"`
+}
+
+// EnvConfig configures a single environment
+type EnvConfig struct {
+	Profile string `yaml:"profile"`
+	// Region replaces the region of the profile
+	Region string `yaml:"region"`
+	// Function is the full name of the environment's function, instead of
+	// the one the template renders
+	Function string `yaml:"function"`
+}
+
+// DefaultConfigPath is where playtools reads its config file from:
+// playtools/config.yaml under $XDG_CONFIG_HOME, or ~/.config
+func DefaultConfigPath() (string, error) {
+	dir := os.Getenv("XDG_CONFIG_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		dir = filepath.Join(home, ".config")
+	}
+	return filepath.Join(dir, "playtools", "config.yaml"), nil
+}
+
+// LoadConfig reads the config file at path, DefaultConfigPath when empty. A
+// missing file is an empty config, leaving the built-in environments.
+func LoadConfig(path string) (*Config, error) {
+	if path == "" {
+		var err error
+		if path, err = DefaultConfigPath(); err != nil {
+			return nil, err
+		}
+	}
+	var cfg Config
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return &cfg, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return &cfg, nil
+}
+
+// Env resolves the environment name of a context, the empty one being the
+// default, over the built-in environments
+func (c *Config) Env(context, name string) (Env, error) {
+	ctx := c.ContextConfig
+	if context != "" {
+		var ok bool
+		if ctx, ok = c.Contexts[context]; !ok {
+			return Env{}, fmt.Errorf("unknown context %q", context)
+		}
+	}
+	envCfg := ctx.Environments[name]
+	env := Env{Name: name, Profile: envCfg.Profile, Region: envCfg.Region, FunctionName: envCfg.Function}
+	if env.Profile == "" {
+		env.Profile = DefaultProfiles()[name]
+	}
+	if env.Profile == "" {
+		return Env{}, fmt.Errorf("unknown environment %q", name)
+	}
+	if env.FunctionName == "" {
+		template := ctx.FunctionTemplate
+		if template == "" {
+			template = DefaultFunctionTemplate
+		}
+		if err := CheckFunctionTemplate(template); err != nil {
+			return Env{}, err
+		}
+		env.FunctionName = fmt.Sprintf(template, name)
+	}
+	return env, nil
+}
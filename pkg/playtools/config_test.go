@@ -0,0 +1,89 @@
+package playtools
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeConfig(t *testing.T, data string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadConfigEnv(t *testing.T) {
+	path := writeConfig(t, `
+function_template: team-%s-calculator
+environments:
+  prod:
+    profile: prod-admin
+  qa:
+    profile: qa-engineer
+    region: eu-west-1
+    function: qa-calculator-v2
+contexts:
+  rewards:
+    environments:
+      dev:
+        profile: rewards-dev
+# The TUI's settings are left to the command
+keymap:
+  quit: [q]
+`)
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct {
+		context, name string
+		want          Env
+	}{
+		{"", "dev", Env{Name: "dev", Profile: "platform-dev-engineer", FunctionName: "team-dev-calculator"}},
+		{"", "prod", Env{Name: "prod", Profile: "prod-admin", FunctionName: "team-prod-calculator"}},
+		{"", "qa", Env{Name: "qa", Profile: "qa-engineer", Region: "eu-west-1", FunctionName: "qa-calculator-v2"}},
+		{"rewards", "dev", Env{Name: "dev", Profile: "rewards-dev", FunctionName: "imx-rewards-dev-sweepstake-rewards-calculator"}},
+	} {
+		env, err := cfg.Env(tt.context, tt.name)
+		if err != nil || env != tt.want {
+			t.Errorf("%s/%s: got %+v, %v, want %+v", tt.context, tt.name, env, err, tt.want)
+		}
+	}
+	for _, tt := range []struct{ context, name, err string }{
+		{"", "staging", `unknown environment "staging"`},
+		{"billing", "dev", `unknown context "billing"`},
+	} {
+		if _, err := cfg.Env(tt.context, tt.name); err == nil || err.Error() != tt.err {
+			t.Errorf("%s/%s: got %v, want %q", tt.context, tt.name, err, tt.err)
+		}
+	}
+}
+
+func TestLoadConfigMissingFile(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	cfg, err := LoadConfig("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	env, err := cfg.Env("", "prod")
+	if err != nil || env.Profile != "platform-prod-engineer" || env.FunctionName != "imx-rewards-prod-sweepstake-rewards-calculator" {
+		t.Errorf("got %+v, %v", env, err)
+	}
+}
+
+func TestLoadConfigProblems(t *testing.T) {
+	if _, err := LoadConfig(writeConfig(t, "environments: [dev\n")); err == nil || !strings.Contains(err.Error(), "config.yaml") {
+		t.Errorf("got %v, want an error naming the file", err)
+	}
+	cfg, err := LoadConfig(writeConfig(t, "function_template: calculator-%s-%d\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cfg.Env("", "dev"); err == nil || !strings.Contains(err.Error(), "expected exactly one %s") {
+		t.Errorf("got %v, want the template refused", err)
+	}
+}
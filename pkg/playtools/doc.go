@@ -0,0 +1,23 @@
+// Package playtools invokes the sweepstake lambda without the TUI: it reads
+// the environments of the playtools config file, builds and encodes the
+// payload, loads the AWS config of an SSO profile, invokes the function
+// synchronously or asynchronously and classifies what went wrong.
+//
+// The playtools command is one consumer of this package. Its exported
+// identifiers follow semantic versioning: they are only removed or changed
+// incompatibly in a new major version.
+//
+// A minimal invocation:
+//
+//	env := playtools.Env{Name: "dev", Profile: "dev", Region: "us-east-1", FunctionName: "sweepstake-dev"}
+//	res, err := playtools.Invoke(ctx, env, playtools.NewPayload(playtools.ActionProcess, 42))
+//	if kind := playtools.Classify(res, err); kind != playtools.KindNone {
+//		log.Fatalf("%s: %v", kind, err)
+//	}
+//
+// LoadConfig reads the environments the playtools command is configured
+// with, so the same names resolve to the same profiles and functions:
+//
+//	cfg, err := playtools.LoadConfig("")
+//	env, err := cfg.Env("", "prod")
+package playtools
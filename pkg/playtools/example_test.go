@@ -0,0 +1,60 @@
+package playtools_test
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/revrost/playtools/pkg/playtools"
+)
+
+func ExampleInvoke() {
+	env := playtools.Env{Name: "dev", Profile: "dev", Region: "us-east-1", FunctionName: "sweepstake-dev"}
+	payload := playtools.NewPayload(playtools.ActionProcess, 42)
+	payload.DryRun = true
+
+	res, err := playtools.Invoke(context.Background(), env, payload, playtools.WithTimeout(2*time.Minute))
+	switch kind := playtools.Classify(res, err); kind {
+	case playtools.KindNone:
+		fmt.Printf("%s: %s\n", res.RequestID, res.Body)
+	case playtools.KindFunctionError:
+		log.Fatalf("%s: %s", kind, playtools.FunctionErrorMessage(res.Body, res.FunctionError))
+	default:
+		log.Fatalf("%s: %v", kind, err)
+	}
+}
+
+func ExampleLoadConfig() {
+	cfg, err := playtools.LoadConfig("")
+	if err != nil {
+		log.Fatal(err)
+	}
+	env, err := cfg.Env("", "prod")
+	if err != nil {
+		log.Fatal(err)
+	}
+	res, err := playtools.Invoke(context.Background(), env, playtools.NewPayload(playtools.ActionStatus, 42))
+	if kind := playtools.Classify(res, err); kind != playtools.KindNone {
+		log.Fatalf("%s: %v", kind, err)
+	}
+	fmt.Printf("%s: %s\n", res.RequestID, res.Body)
+}
+
+func ExampleWithAsync() {
+	env := playtools.Env{Name: "prod", Profile: "prod", FunctionName: "sweepstake-prod"}
+	payload := playtools.NewPayload(playtools.ActionComplete, 42)
+	payload.IdempotencyKey = "5f0c2a52-8a7e-4d0b-9b3e-6c1f0a4d2e91"
+
+	res, err := playtools.Invoke(context.Background(), env, payload, playtools.WithAsync(), playtools.WithQualifier("live"))
+	if err != nil {
+		log.Fatalf("%s: %v", playtools.Classify(res, err), err)
+	}
+	fmt.Println("queued as", res.RequestID)
+}
+
+func ExampleUnwrapEnvelope() {
+	body, status, ok := playtools.UnwrapEnvelope([]byte(`{"statusCode":201,"body":"{\"started\":true}"}`))
+	fmt.Println(string(body), status, ok)
+	// Output: {"started":true} 201 true
+}
@@ -0,0 +1,229 @@
+package playtools
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsmiddleware "github.com/aws/aws-sdk-go-v2/aws/middleware"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/lambda"
+	"github.com/aws/aws-sdk-go-v2/service/lambda/types"
+)
+
+// Env is where a payload is invoked: the AWS profile and region to load the
+// config of, and the function to call
+type Env struct {
+	Name         string
+	Profile      string
+	Region       string // empty for the profile's region
+	FunctionName string
+}
+
+// InvocationResult is what came back from an invocation
+type InvocationResult struct {
+	RequestID string
+	// Response is the raw payload the function returned, and Body the body
+	// of an API Gateway style {"statusCode": ..., "body": "..."} response, or
+	// the raw payload when it isn't one
+	Response []byte
+	Body     []byte
+	// StatusCode is the envelope's status code, 0 for a plain response
+	StatusCode    int
+	FunctionError string
+	// Logs is the decoded tail of the function's logs, and LogsErr why it
+	// couldn't be decoded
+	Logs    string
+	LogsErr error
+	// Async marks an invocation that was only queued, which has no response
+	Async    bool
+	Duration time.Duration
+}
+
+// StatusError is an envelope status code of 400 or more
+type StatusError struct {
+	StatusCode int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("lambda responded with status code %d", e.StatusCode)
+}
+
+// Option adjusts an invocation
+type Option func(*options)
+
+type options struct {
+	qualifier    string
+	timeout      time.Duration
+	async        bool
+	awsConfig    *aws.Config
+	sessionCheck func(ctx context.Context, profile string) error
+}
+
+// WithQualifier invokes a version or alias of the function
+func WithQualifier(qualifier string) Option {
+	return func(o *options) { o.qualifier = qualifier }
+}
+
+// WithTimeout gives up on the invocation after d
+func WithTimeout(d time.Duration) Option {
+	return func(o *options) { o.timeout = d }
+}
+
+// WithAsync queues the invocation instead of waiting for the response
+func WithAsync() Option {
+	return func(o *options) { o.async = true }
+}
+
+// WithAWSConfig invokes with cfg instead of loading the config of the
+// environment's profile
+func WithAWSConfig(cfg aws.Config) Option {
+	return func(o *options) { o.awsConfig = &cfg }
+}
+
+// WithSessionCheck runs check before the profile's config is loaded, to log
+// in again when its SSO session expired
+func WithSessionCheck(check func(ctx context.Context, profile string) error) Option {
+	return func(o *options) { o.sessionCheck = check }
+}
+
+// Invoke sends payload to the function of env. A function error is reported
+// in the result's FunctionError, not as an error; an envelope status code of
+// 400 or more is returned as a *StatusError along with the result.
+func Invoke(ctx context.Context, env Env, payload EventPayload, opts ...Option) (*InvocationResult, error) {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if env.FunctionName == "" {
+		return nil, fmt.Errorf("no function name for %s", env.Name)
+	}
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+	if o.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, o.timeout)
+		defer cancel()
+	}
+	cfg, err := loadConfig(ctx, env, o)
+	if err != nil {
+		return nil, err
+	}
+	res, err := InvokeFunction(ctx, lambda.NewFromConfig(cfg), env.FunctionName, data, opts...)
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode >= 400 {
+		return res, &StatusError{StatusCode: res.StatusCode}
+	}
+	return res, nil
+}
+
+// loadConfig loads the AWS config of env's profile, resolving its SSO
+// session from the cache the AWS CLI logs in to
+func loadConfig(ctx context.Context, env Env, o options) (aws.Config, error) {
+	if o.awsConfig != nil {
+		return *o.awsConfig, nil
+	}
+	if o.sessionCheck != nil {
+		if err := o.sessionCheck(ctx, env.Profile); err != nil {
+			return aws.Config{}, err
+		}
+	}
+	loadOpts := []func(*config.LoadOptions) error{config.WithSharedConfigProfile(env.Profile)}
+	if env.Region != "" {
+		loadOpts = append(loadOpts, config.WithRegion(env.Region))
+	}
+	cfg, err := config.LoadDefaultConfig(ctx, loadOpts...)
+	if err != nil {
+		return aws.Config{}, fmt.Errorf("failed to load AWS config: %w", err)
+	}
+	return cfg, nil
+}
+
+// InvokeFunction invokes a function with an encoded payload on a client the
+// caller set up, with the tail of its logs when it waits for the response.
+// Unlike Invoke it leaves the envelope's status code to the caller.
+func InvokeFunction(ctx context.Context, client *lambda.Client, functionName string, payload []byte, opts ...Option) (*InvocationResult, error) {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+	input := &lambda.InvokeInput{
+		FunctionName: aws.String(functionName),
+		Payload:      payload,
+		LogType:      types.LogTypeTail, // the last 4KB of logs
+	}
+	if o.qualifier != "" {
+		input.Qualifier = aws.String(o.qualifier)
+	}
+	if o.async {
+		input.InvocationType = types.InvocationTypeEvent
+		input.LogType = types.LogTypeNone
+	}
+	started := time.Now()
+	out, err := client.Invoke(ctx, input)
+	if err != nil {
+		return nil, err
+	}
+	res := &InvocationResult{Async: o.async, Duration: time.Since(started)}
+	res.RequestID, _ = awsmiddleware.GetRequestIDMetadata(out.ResultMetadata)
+	if o.async {
+		return res, nil
+	}
+	res.Response, res.Body = out.Payload, out.Payload
+	if body, statusCode, ok := UnwrapEnvelope(out.Payload); ok {
+		res.Body, res.StatusCode = body, statusCode
+	}
+	if out.FunctionError != nil {
+		res.FunctionError = *out.FunctionError
+	}
+	if out.LogResult != nil {
+		logs, err := base64.StdEncoding.DecodeString(*out.LogResult)
+		if err != nil {
+			res.LogsErr = fmt.Errorf("failed to decode base64: %v", err)
+		} else {
+			res.Logs = string(logs)
+		}
+	}
+	return res, nil
+}
+
+// UnwrapEnvelope detects an API Gateway style {"statusCode": ..., "body": "..."}
+// response and returns its body and status code
+func UnwrapEnvelope(payload []byte) ([]byte, int, bool) {
+	var envelope map[string]json.RawMessage
+	if err := json.Unmarshal(payload, &envelope); err != nil {
+		return nil, 0, false
+	}
+	var statusCode int
+	var body string
+	if err := json.Unmarshal(envelope["statusCode"], &statusCode); err != nil {
+		return nil, 0, false
+	}
+	if err := json.Unmarshal(envelope["body"], &body); err != nil {
+		return nil, 0, false
+	}
+	return []byte(body), statusCode, true
+}
+
+// FunctionErrorMessage extracts errorType/errorMessage from the body of a
+// function error, falling back to the function error itself
+func FunctionErrorMessage(body []byte, functionError string) string {
+	var resp struct {
+		ErrorType    string `json:"errorType"`
+		ErrorMessage string `json:"errorMessage"`
+	}
+	if err := json.Unmarshal(body, &resp); err != nil || resp.ErrorMessage == "" {
+		return functionError
+	}
+	if resp.ErrorType != "" {
+		return fmt.Sprintf("%s: %s", resp.ErrorType, resp.ErrorMessage)
+	}
+	return resp.ErrorMessage
+}
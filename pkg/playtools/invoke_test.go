@@ -0,0 +1,221 @@
+package playtools
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+// lambdaCall is a request the fake Lambda API received
+type lambdaCall struct {
+	path           string
+	qualifier      string
+	invocationType string
+	logType        string
+	body           []byte
+}
+
+// fakeLambda serves the Lambda Invoke API with respond, recording the calls
+func fakeLambda(t *testing.T, respond func(w http.ResponseWriter, call lambdaCall)) (aws.Config, *[]lambdaCall) {
+	t.Helper()
+	var calls []lambdaCall
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		call := lambdaCall{
+			path:           r.URL.Path,
+			qualifier:      r.URL.Query().Get("Qualifier"),
+			invocationType: r.Header.Get("X-Amz-Invocation-Type"),
+			logType:        r.Header.Get("X-Amz-Log-Type"),
+			body:           body,
+		}
+		calls = append(calls, call)
+		w.Header().Set("X-Amzn-Requestid", "req-1")
+		respond(w, call)
+	}))
+	t.Cleanup(srv.Close)
+	cfg := aws.Config{
+		Region:           "us-east-1",
+		BaseEndpoint:     aws.String(srv.URL),
+		RetryMaxAttempts: 1,
+		Credentials: aws.CredentialsProviderFunc(func(context.Context) (aws.Credentials, error) {
+			return aws.Credentials{AccessKeyID: "AKID", SecretAccessKey: "secret"}, nil
+		}),
+	}
+	return cfg, &calls
+}
+
+var devEnv = Env{Name: "dev", FunctionName: "sweepstake-dev"}
+
+func TestInvokeSync(t *testing.T) {
+	cfg, calls := fakeLambda(t, func(w http.ResponseWriter, call lambdaCall) {
+		w.Header().Set("X-Amz-Log-Result", base64.StdEncoding.EncodeToString([]byte("START RequestId: req-1\n")))
+		w.Write([]byte(`{"processed":3}`))
+	})
+	payload := NewPayload(ActionProcess, 42)
+	payload.DryRun = true
+
+	res, err := Invoke(context.Background(), devEnv, payload, WithAWSConfig(cfg), WithQualifier("live"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.RequestID != "req-1" || string(res.Body) != `{"processed":3}` || res.Logs != "START RequestId: req-1\n" || res.Async {
+		t.Errorf("unexpected result %+v", res)
+	}
+	call := (*calls)[0]
+	if call.path != "/2015-03-31/functions/sweepstake-dev/invocations" || call.qualifier != "live" || call.logType != "Tail" {
+		t.Errorf("unexpected call %+v", call)
+	}
+	if want := `{"action":"process","dry_run":true,"sweepstake_quest_id":42}`; string(call.body) != want {
+		t.Errorf("sent %s, want %s", call.body, want)
+	}
+}
+
+func TestInvokeAsync(t *testing.T) {
+	cfg, calls := fakeLambda(t, func(w http.ResponseWriter, call lambdaCall) {
+		w.WriteHeader(http.StatusAccepted)
+	})
+	res, err := Invoke(context.Background(), devEnv, NewPayload(ActionComplete, 7), WithAWSConfig(cfg), WithAsync())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !res.Async || res.RequestID != "req-1" || res.Body != nil {
+		t.Errorf("unexpected result %+v", res)
+	}
+	if call := (*calls)[0]; call.invocationType != "Event" || call.logType == "Tail" {
+		t.Errorf("unexpected call %+v", call)
+	}
+}
+
+func TestInvokeEnvelope(t *testing.T) {
+	cfg, _ := fakeLambda(t, func(w http.ResponseWriter, call lambdaCall) {
+		w.Write([]byte(`{"statusCode":422,"body":"{\"errors\":[\"bad tier\"]}"}`))
+	})
+	res, err := Invoke(context.Background(), devEnv, NewPayload(ActionStart, 60), WithAWSConfig(cfg))
+	var statusErr *StatusError
+	if !errors.As(err, &statusErr) || statusErr.StatusCode != 422 {
+		t.Fatalf("got %v, want a status error", err)
+	}
+	if res == nil || string(res.Body) != `{"errors":["bad tier"]}` || res.StatusCode != 422 {
+		t.Errorf("unexpected result %+v", res)
+	}
+	if kind := Classify(res, err); kind != KindErrorResponse {
+		t.Errorf("classified as %q", kind)
+	}
+}
+
+func TestInvokeFunctionError(t *testing.T) {
+	cfg, _ := fakeLambda(t, func(w http.ResponseWriter, call lambdaCall) {
+		w.Header().Set("X-Amz-Function-Error", "Unhandled")
+		w.Write([]byte(`{"errorType":"KeyError","errorMessage":"'tiers'"}`))
+	})
+	res, err := Invoke(context.Background(), devEnv, NewPayload(ActionProcess, 1), WithAWSConfig(cfg))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if kind := Classify(res, err); kind != KindFunctionError {
+		t.Errorf("classified as %q", kind)
+	}
+	if msg := FunctionErrorMessage(res.Body, res.FunctionError); msg != "KeyError: 'tiers'" {
+		t.Errorf("message %q", msg)
+	}
+}
+
+func TestInvokeAPIErrors(t *testing.T) {
+	tests := []struct {
+		status int
+		code   string
+		want   ErrorKind
+	}{
+		{http.StatusNotFound, "ResourceNotFoundException", KindFunctionNotFound},
+		{http.StatusForbidden, "AccessDeniedException", KindAccessDenied},
+		{http.StatusForbidden, "ExpiredTokenException", KindCredentialsRejected},
+		{http.StatusTooManyRequests, "TooManyRequestsException", KindThrottled},
+		{http.StatusBadRequest, "InvalidRequestContentException", KindInvalidPayload},
+		{http.StatusInternalServerError, "ServiceException", KindFailed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.code, func(t *testing.T) {
+			cfg, _ := fakeLambda(t, func(w http.ResponseWriter, call lambdaCall) {
+				w.Header().Set("X-Amzn-Errortype", tt.code)
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(tt.status)
+				json.NewEncoder(w).Encode(map[string]string{"message": "nope"})
+			})
+			res, err := Invoke(context.Background(), devEnv, NewPayload(ActionProcess, 1), WithAWSConfig(cfg))
+			if err == nil || res != nil {
+				t.Fatalf("got %+v, %v, want an error", res, err)
+			}
+			if kind := Classify(res, err); kind != tt.want {
+				t.Errorf("classified as %q, want %q", kind, tt.want)
+			}
+			if retryable := Classify(res, err).Retryable(); retryable != (tt.want == KindThrottled) {
+				t.Errorf("retryable %v", retryable)
+			}
+		})
+	}
+}
+
+func TestInvokeTimeout(t *testing.T) {
+	cfg, _ := fakeLambda(t, func(w http.ResponseWriter, call lambdaCall) {
+		time.Sleep(200 * time.Millisecond)
+	})
+	_, err := Invoke(context.Background(), devEnv, NewPayload(ActionProcess, 1), WithAWSConfig(cfg), WithTimeout(20*time.Millisecond))
+	if kind := Classify(nil, err); kind != KindTimedOut {
+		t.Errorf("classified %v as %q", err, kind)
+	}
+}
+
+func TestInvokeSessionCheck(t *testing.T) {
+	expired := errors.New("SSO session expired and login failed")
+	var checked string
+	_, err := Invoke(context.Background(), Env{Name: "prod", Profile: "prod-sso", FunctionName: "sweepstake-prod"}, NewPayload(ActionProcess, 1),
+		WithSessionCheck(func(ctx context.Context, profile string) error {
+			checked = profile
+			return expired
+		}))
+	if !errors.Is(err, expired) || checked != "prod-sso" {
+		t.Errorf("got %v after checking %q", err, checked)
+	}
+}
+
+func TestInvokeNeedsFunctionName(t *testing.T) {
+	_, err := Invoke(context.Background(), Env{Name: "dev"}, NewPayload(ActionProcess, 1))
+	if err == nil || !strings.Contains(err.Error(), "no function name for dev") {
+		t.Errorf("got %v", err)
+	}
+}
+
+func TestClassifySuccess(t *testing.T) {
+	if kind := Classify(&InvocationResult{Body: []byte(`{}`)}, nil); kind != KindNone {
+		t.Errorf("classified as %q", kind)
+	}
+	if kind := Classify(nil, nil); kind != KindNone {
+		t.Errorf("classified as %q", kind)
+	}
+}
+
+// The text of an error doesn't decide its kind, only its type does
+func TestClassifyTypedOnly(t *testing.T) {
+	for _, err := range []error{
+		errors.New("endpoint responded with status code 502"),
+		fmt.Errorf("invoke: %s", "AccessDeniedException: not allowed"),
+	} {
+		if kind := Classify(nil, err); kind != KindFailed {
+			t.Errorf("classified %q as %q", err, kind)
+		}
+	}
+	wrapped := fmt.Errorf("retrying: %w", &StatusError{StatusCode: 503})
+	if kind := Classify(nil, wrapped); kind != KindErrorResponse {
+		t.Errorf("classified %q as %q", wrapped, kind)
+	}
+}
@@ -0,0 +1,169 @@
+package playtools
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Action is what the sweepstake lambda is asked to do
+type Action string
+
+const (
+	// ActionProcess processes the calculation sweepstake quest but not the distribution of rewards
+	ActionProcess Action = "process"
+	// ActionComplete completes a sweepstake quest and distributes rewards
+	ActionComplete Action = "complete"
+	// ActionStart starts a new sweepstake quest
+	ActionStart Action = "start"
+	// ActionRollback reverses the distribution of an earlier complete
+	ActionRollback Action = "rollback"
+	// ActionStatus reports the live calculation of a sweepstake quest without
+	// changing anything
+	ActionStatus Action = "status"
+)
+
+// EventPayload is the payload request for the lambda function
+type EventPayload struct {
+	Action Action `json:"action"`
+
+	// DryRun is only applicable for process and start actions. For start it
+	// validates the duration and overrides without creating the sweepstake.
+	DryRun            bool `json:"dry_run,omitempty"`
+	SweepstakeQuestID *int `json:"sweepstake_quest_id"`
+	BatchSize         *int `json:"batch_size,omitempty"`
+
+	// DurationMinutes Optional fields for action = start
+	DurationMinutes     *int             `json:"duration_minutes,omitempty"`
+	SweepstakeOverrides *json.RawMessage `json:"sweepstake_overrides,omitempty"`
+
+	// OriginalRequestID and Justification are required for action = rollback
+	OriginalRequestID string `json:"original_request_id,omitempty"`
+	Justification     string `json:"justification,omitempty"`
+
+	// IdempotencyKey lets the backend ignore a retried complete.
+	// IdempotencyOverride marks a key replaced for a deliberate
+	// re-distribution; it isn't sent.
+	IdempotencyKey      string `json:"idempotency_key,omitempty"`
+	IdempotencyOverride bool   `json:"-"`
+
+	// Extra holds fields the lambda takes that this struct doesn't know yet,
+	// merged into the JSON after the others
+	Extra map[string]json.RawMessage `json:"-"`
+}
+
+// NewPayload creates the lambda payload for an action. The value is the
+// sweepstake quest ID for process/complete and the duration in minutes for start.
+func NewPayload(action Action, value int) EventPayload {
+	payload := EventPayload{Action: action}
+	if action == ActionStart {
+		payload.DurationMinutes = &value
+	} else {
+		payload.SweepstakeQuestID = &value
+	}
+	return payload
+}
+
+// payloadFields are the JSON keys of EventPayload's own fields, which an
+// extra field can't take
+var payloadFields = sync.OnceValue(func() map[string]bool {
+	fields := map[string]bool{}
+	t := reflect.TypeOf(EventPayload{})
+	for i := 0; i < t.NumField(); i++ {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+		if name != "" && name != "-" {
+			fields[name] = true
+		}
+	}
+	return fields
+})
+
+// isPayloadField reports whether key names one of EventPayload's own fields.
+// encoding/json matches keys to fields ignoring case, so "Action" is the
+// action as much as "action" is.
+func isPayloadField(key string) bool {
+	for name := range payloadFields() {
+		if strings.EqualFold(key, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckExtraFields rejects extra fields that collide with the payload's own,
+// whatever their case
+func CheckExtraFields(extra map[string]json.RawMessage) error {
+	var taken []string
+	for k := range extra {
+		if isPayloadField(k) {
+			taken = append(taken, k)
+		}
+	}
+	sort.Strings(taken)
+	switch len(taken) {
+	case 0:
+		return nil
+	case 1:
+		return fmt.Errorf("%s is a payload field already, set by the prompts", taken[0])
+	}
+	return fmt.Errorf("%s are payload fields already, set by the prompts", strings.Join(taken, ", "))
+}
+
+// MarshalJSON writes the payload's own fields in their usual order, then
+// its extra fields by key
+func (p EventPayload) MarshalJSON() ([]byte, error) {
+	type payload EventPayload
+	data, err := json.Marshal(payload(p))
+	if err != nil || len(p.Extra) == 0 {
+		return data, err
+	}
+	if err := CheckExtraFields(p.Extra); err != nil {
+		return nil, err
+	}
+	keys := make([]string, 0, len(p.Extra))
+	for k := range p.Extra {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var buf bytes.Buffer
+	buf.Write(data[:len(data)-1])
+	for _, k := range keys {
+		name, _ := json.Marshal(k)
+		buf.WriteByte(',')
+		buf.Write(name)
+		buf.WriteByte(':')
+		if err := json.Compact(&buf, p.Extra[k]); err != nil {
+			return nil, fmt.Errorf("extra field %s: %v", k, err)
+		}
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// UnmarshalJSON reads the payload's own fields, keeping any other key in
+// Extra so a payload round-trips through the history and templates
+func (p *EventPayload) UnmarshalJSON(data []byte) error {
+	type payload EventPayload
+	var fields payload
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+	var all map[string]json.RawMessage
+	if err := json.Unmarshal(data, &all); err != nil {
+		return err
+	}
+	*p = EventPayload(fields)
+	for k, v := range all {
+		if !isPayloadField(k) {
+			if p.Extra == nil {
+				p.Extra = map[string]json.RawMessage{}
+			}
+			p.Extra[k] = v
+		}
+	}
+	return nil
+}
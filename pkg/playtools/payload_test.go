@@ -0,0 +1,92 @@
+package playtools
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestNewPayload(t *testing.T) {
+	for _, tt := range []struct {
+		action Action
+		want   string
+	}{
+		{ActionProcess, `{"action":"process","sweepstake_quest_id":42}`},
+		{ActionComplete, `{"action":"complete","sweepstake_quest_id":42}`},
+		{ActionStart, `{"action":"start","sweepstake_quest_id":null,"duration_minutes":42}`},
+	} {
+		data, err := json.Marshal(NewPayload(tt.action, 42))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != tt.want {
+			t.Errorf("%s: got %s, want %s", tt.action, data, tt.want)
+		}
+	}
+}
+
+func TestPayloadExtraRoundTrip(t *testing.T) {
+	payload := NewPayload(ActionProcess, 7)
+	payload.Extra = map[string]json.RawMessage{"region_hint": json.RawMessage(`"eu"`), "audit": json.RawMessage(`{ "by": "ops" }`)}
+	data, err := json.Marshal(payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"action":"process","sweepstake_quest_id":7,"audit":{"by":"ops"},"region_hint":"eu"}`; string(data) != want {
+		t.Errorf("got %s, want %s", data, want)
+	}
+	var back EventPayload
+	if err := json.Unmarshal(data, &back); err != nil {
+		t.Fatal(err)
+	}
+	payload.Extra["audit"] = json.RawMessage(`{"by":"ops"}`)
+	if !reflect.DeepEqual(back, payload) {
+		t.Errorf("round trip gave %+v, want %+v", back, payload)
+	}
+}
+
+func TestCheckExtraFields(t *testing.T) {
+	if err := CheckExtraFields(map[string]json.RawMessage{"region_hint": nil}); err != nil {
+		t.Errorf("unexpected %v", err)
+	}
+	err := CheckExtraFields(map[string]json.RawMessage{"dry_run": nil, "action": nil})
+	if err == nil || err.Error() != "action, dry_run are payload fields already, set by the prompts" {
+		t.Errorf("got %v", err)
+	}
+	if _, err := json.Marshal(EventPayload{Action: ActionProcess, Extra: map[string]json.RawMessage{"action": json.RawMessage(`"complete"`)}}); err == nil {
+		t.Error("marshalled an extra field that overwrites the action")
+	}
+}
+
+// The JSON decoder matches keys to fields ignoring case, so a key differing
+// only in case is the payload's own field too
+func TestCheckExtraFieldsIgnoresCase(t *testing.T) {
+	for _, key := range []string{"Action", "DRY_RUN", "Sweepstake_Quest_ID", "idempotency_KEY"} {
+		if err := CheckExtraFields(map[string]json.RawMessage{key: json.RawMessage(`true`)}); err == nil {
+			t.Errorf("%s was taken as an extra field", key)
+		}
+	}
+	if _, err := json.Marshal(EventPayload{Action: ActionProcess, Extra: map[string]json.RawMessage{"Action": json.RawMessage(`"complete"`)}}); err == nil {
+		t.Error("marshalled an extra field that a case-insensitive reader takes for the action")
+	}
+}
+
+func TestUnmarshalKeepsCaseVariantsOutOfExtra(t *testing.T) {
+	var payload EventPayload
+	if err := json.Unmarshal([]byte(`{"Action":"process","Sweepstake_Quest_Id":7,"DRY_RUN":true,"region_hint":"eu"}`), &payload); err != nil {
+		t.Fatal(err)
+	}
+	if payload.Action != ActionProcess || payload.SweepstakeQuestID == nil || *payload.SweepstakeQuestID != 7 || !payload.DryRun {
+		t.Errorf("own fields not read: %+v", payload)
+	}
+	if want := map[string]json.RawMessage{"region_hint": json.RawMessage(`"eu"`)}; !reflect.DeepEqual(payload.Extra, want) {
+		t.Errorf("extra %s, want only region_hint", payload.Extra)
+	}
+	data, err := json.Marshal(payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"action":"process","dry_run":true,"sweepstake_quest_id":7,"region_hint":"eu"}`; string(data) != want {
+		t.Errorf("re-marshalled as %s, want %s", data, want)
+	}
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// plainMode replaces the TUI with line prompts and streamed progress, for
+// terminals where the alternate screen gets in the way, set with --plain
+var plainMode bool
+
+// promptChoice asks to pick one of options by number or name
+func promptChoice(in *bufio.Reader, out io.Writer, question string, options []string) (string, error) {
+	for {
+		fmt.Fprintf(out, "%s\n", question)
+		for i, o := range options {
+			fmt.Fprintf(out, "  %d) %s\n", i+1, o)
+		}
+		fmt.Fprint(out, "> ")
+		line, err := in.ReadString('\n')
+		answer := strings.TrimSpace(line)
+		if n, convErr := strconv.Atoi(answer); convErr == nil && n >= 1 && n <= len(options) {
+			return options[n-1], nil
+		}
+		if slices.Contains(options, answer) {
+			return answer, nil
+		}
+		if err != nil {
+			return "", errors.New("nothing chosen")
+		}
+		fmt.Fprintf(out, "Enter a number from 1 to %d\n", len(options))
+	}
+}
+
+// runPlain is the session --plain runs instead of the TUI: it asks for the
+// environment and the action, then goes through the action command's
+// prompts, confirmation and streamed invocation
+func runPlain(stdin io.Reader, stdout io.Writer) int {
+	in := bufio.NewReader(stdin)
+	env := pinnedEnv
+	if env == "" {
+		envs := make([]string, 0, len(profileMap))
+		for e := range profileMap {
+			envs = append(envs, e)
+		}
+		sort.Strings(envs)
+		var err error
+		if env, err = promptChoice(in, stdout, "Environment:", envs); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return exitUsage
+		}
+	}
+	actions := []string{string(ActionProcess), string(ActionComplete), string(ActionStart)}
+	action, err := promptChoice(in, stdout, "Action:", actions)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitUsage
+	}
+	return runActionCommand(Action(action), []string{"--env", env}, in, stdout)
+}
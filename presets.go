@@ -0,0 +1,510 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbles/list"
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/revrost/playtools/pkg/playtools"
+	"gopkg.in/yaml.v3"
+)
+
+// presetsAction is the action list entry that manages presets and bookmarks
+const presetsAction = "presets"
+
+// presetsFooterHeight is the number of lines below the presets list
+const presetsFooterHeight = 3
+
+// Preset is a named set of form values. Presets live in the config file so
+// they can be shared; bookmarks are the same values tied to an environment,
+// kept in the state file.
+type Preset struct {
+	Name   string `yaml:"name" json:"name"`
+	Action Action `yaml:"action" json:"action"`
+	// Value is the quest ID, or the duration in minutes for start
+	Value  int  `yaml:"value" json:"value"`
+	DryRun bool `yaml:"dry_run,omitempty" json:"dry_run,omitempty"`
+}
+
+// Bookmark is a preset for one environment
+type Bookmark struct {
+	Preset
+	Env string `json:"env"`
+}
+
+// errChangedOnDisk is returned when presets or bookmarks were edited outside
+// this session since they were loaded
+var errChangedOnDisk = errors.New("changed on disk")
+
+func presetFromPayload(name string, payload EventPayload) Preset {
+	var value int
+	fmt.Sscan(payloadValue(payload), &value)
+	return Preset{Name: name, Action: payload.Action, Value: value, DryRun: payload.DryRun}
+}
+
+func (p Preset) payload() EventPayload {
+	payload := playtools.NewPayload(p.Action, p.Value)
+	payload.DryRun = p.DryRun && dryRunApplies(p.Action)
+	return payload
+}
+
+func (p Preset) summary() string {
+	s := fmt.Sprintf("%s %d", p.Action, p.Value)
+	if p.DryRun && dryRunApplies(p.Action) {
+		s += ", dry run"
+	}
+	return s
+}
+
+// configModTimeOf returns the modtime of the config file, zero when it doesn't exist
+func configModTimeOf(path string) (time.Time, error) {
+	info, err := os.Stat(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return time.Time{}, nil
+	}
+	if err != nil {
+		return time.Time{}, err
+	}
+	return info.ModTime(), nil
+}
+
+// savePresets writes the presets to the config file, keeping everything else
+// in it including comments. Unless force is set it fails with
+// errChangedOnDisk when the file was modified since loadedAt.
+func savePresets(presets []Preset, loadedAt time.Time, force bool) (time.Time, error) {
+	path, err := configFilePath()
+	if err != nil {
+		return time.Time{}, err
+	}
+	var modTime time.Time
+	err = withFileLock(path+".lock", func() error {
+		current, err := configModTimeOf(path)
+		if err != nil {
+			return err
+		}
+		if !force && !current.Equal(loadedAt) {
+			return errChangedOnDisk
+		}
+
+		data, err := os.ReadFile(path)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		updated, err := setConfigPresets(path, data, presets)
+		if err != nil {
+			return err
+		}
+		if err := writeFileAtomic(path, updated, 0o644); err != nil {
+			return err
+		}
+		modTime, err = configModTimeOf(path)
+		return err
+	})
+	return modTime, err
+}
+
+// setConfigPresets replaces the presets key of the active context in a
+// config file
+func setConfigPresets(path string, data []byte, presets []Preset) ([]byte, error) {
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return nil, newConfigError(path, data, &root, err)
+	}
+	if root.Kind == 0 {
+		root = yaml.Node{Kind: yaml.DocumentNode}
+	}
+	if len(root.Content) == 0 {
+		root.Content = []*yaml.Node{{Kind: yaml.MappingNode, Tag: "!!map"}}
+	}
+	doc := root.Content[0]
+	if doc.Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("%s: expected a mapping at the top level", path)
+	}
+	if activeContext != "" {
+		if doc = mappingValue(doc, "contexts"); doc != nil {
+			doc = mappingValue(doc, activeContext)
+		}
+		if doc == nil || doc.Kind != yaml.MappingNode {
+			return nil, fmt.Errorf("%s: expected a mapping at contexts.%s", path, activeContext)
+		}
+	}
+
+	var value yaml.Node
+	if err := value.Encode(presets); err != nil {
+		return nil, err
+	}
+	found := false
+	for i := 0; i < len(doc.Content); i += 2 {
+		if doc.Content[i].Value != "presets" {
+			continue
+		}
+		if len(presets) == 0 {
+			doc.Content = slices.Delete(doc.Content, i, i+2)
+		} else {
+			doc.Content[i+1] = &value
+		}
+		found = true
+		break
+	}
+	if !found && len(presets) > 0 {
+		doc.Content = append(doc.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: "presets"}, &value)
+	}
+
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(&root); err != nil {
+		return nil, err
+	}
+	if err := enc.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// mappingValue returns the value of key in a mapping node, nil when missing
+func mappingValue(n *yaml.Node, key string) *yaml.Node {
+	if n.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		if n.Content[i].Value == key {
+			return n.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// reloadPresets reads the presets from the config file again
+func reloadPresets() ([]Preset, time.Time, error) {
+	path, err := configFilePath()
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	modTime, err := configModTimeOf(path)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	cfg, err := loadConfig()
+	if err != nil {
+		return nil, modTime, err
+	}
+	ctx, err := activeContextConfig(cfg)
+	return ctx.Presets, modTime, err
+}
+
+// saveBookmarks writes the bookmarks to the state file. The state file is
+// rewritten all the time by this and other sessions, so instead of its
+// modtime the bookmarks on disk are compared with the loaded ones.
+func saveBookmarks(bookmarks, loaded []Bookmark, force bool) error {
+	changed := false
+	err := updateState(func(s *appState) {
+		if !force && !reflect.DeepEqual(s.Bookmarks, loaded) {
+			changed = true
+			return
+		}
+		s.Bookmarks = bookmarks
+	})
+	if err == nil && changed {
+		err = errChangedOnDisk
+	}
+	return err
+}
+
+// presetItem is a preset or bookmark in the management list
+type presetItem struct {
+	preset   Preset
+	bookmark bool
+	env      string
+	index    int
+}
+
+func (i presetItem) Title() string { return i.preset.Name }
+
+func (i presetItem) Description() string {
+	if i.bookmark {
+		return fmt.Sprintf("bookmark in %s: %s", i.env, i.preset.summary())
+	}
+	return "preset: " + i.preset.summary()
+}
+
+func (i presetItem) FilterValue() string { return i.preset.Name }
+
+// presetsScreen is the state of the preset and bookmark management screen
+type presetsScreen struct {
+	list      list.Model
+	presets   []Preset
+	loadedAt  time.Time
+	bookmarks []Bookmark
+	// loadedBookmarks is the bookmarks as last read from or written to disk
+	loadedBookmarks []Bookmark
+
+	// naming is "preset" or "bookmark" while saving the pending payload under
+	// a new name, "rename" while renaming the selected item
+	naming  string
+	input   textinput.Model
+	message string
+	// conflict is "preset" or "bookmark" while asking whether to overwrite
+	// changes made on disk
+	conflict string
+}
+
+func newPresetsScreen() presetsScreen {
+	l := list.New(nil, newListDelegate(), 0, 0)
+	l.Title = tr(msgPresetsListTitle)
+	l.SetFilteringEnabled(false)
+	applyListKeys(&l)
+	applyListGlyphs(&l)
+	ti := textinput.New()
+	ti.CharLimit = 60
+	ti.Width = 40
+	return presetsScreen{list: l, input: ti}
+}
+
+// refresh rebuilds the list, presets first, keeping the selection
+func (p *presetsScreen) refresh() {
+	items := make([]list.Item, 0, len(p.presets)+len(p.bookmarks))
+	for i, preset := range p.presets {
+		items = append(items, presetItem{preset: preset, index: i})
+	}
+	for i, b := range p.bookmarks {
+		items = append(items, presetItem{preset: b.Preset, bookmark: true, env: b.Env, index: i})
+	}
+	p.list.SetItems(items)
+}
+
+// selectItem moves the selection to a preset or bookmark by index
+func (p *presetsScreen) selectItem(bookmark bool, index int) {
+	if bookmark {
+		index += len(p.presets)
+	}
+	p.list.Select(index)
+}
+
+// openPresets loads the presets and bookmarks from disk and shows the
+// management screen
+func (m *model) openPresets() {
+	p := &m.presets
+	p.naming, p.conflict, p.message = "", "", ""
+	presets, modTime, err := reloadPresets()
+	if err != nil {
+		p.message = fmt.Sprintf("Failed to read the config file: %v", err)
+	}
+	p.presets, p.loadedAt = presets, modTime
+	state, err := loadState()
+	if err != nil {
+		p.message = fmt.Sprintf("Failed to read bookmarks: %v", err)
+	}
+	p.bookmarks, p.loadedBookmarks = slices.Clone(state.Bookmarks), state.Bookmarks
+	p.refresh()
+	p.list.Select(0)
+	m.currentScreen = PresetsScreen
+}
+
+// canSavePreset reports whether the confirmed payload can be saved as a
+// preset or bookmark. Batches and rollbacks depend on more than form values.
+func (m model) canSavePreset() bool {
+	// A snapshot investigation's overrides and forced dry run don't fit a preset
+	if m.batchRows != nil || m.selectedAction == investigateAction {
+		return false
+	}
+	switch m.pendingPayload.Action {
+	case ActionProcess, ActionComplete, ActionStart:
+		return true
+	}
+	return false
+}
+
+// startNaming opens the name input, saving the pending payload as a preset
+// or bookmark or renaming the selected item
+func (m *model) startNaming(naming, value string) tea.Cmd {
+	m.presets.naming = naming
+	m.presets.message = ""
+	m.presets.input.Prompt = "Name: "
+	m.presets.input.SetValue(value)
+	m.presets.input.CursorEnd()
+	return m.presets.input.Focus()
+}
+
+// savePresetsChange persists the presets or bookmarks after a change,
+// asking before overwriting changes made on disk
+func (m *model) savePresetsChange(kind string, force bool, done string) {
+	p := &m.presets
+	var err error
+	if kind == "preset" {
+		var modTime time.Time
+		modTime, err = savePresets(p.presets, p.loadedAt, force)
+		if err == nil {
+			p.loadedAt = modTime
+		}
+	} else {
+		err = saveBookmarks(p.bookmarks, p.loadedBookmarks, force)
+		if err == nil {
+			p.loadedBookmarks = slices.Clone(p.bookmarks)
+		}
+	}
+	p.refresh()
+	switch {
+	case errors.Is(err, errChangedOnDisk):
+		p.conflict = kind
+		p.message = ""
+	case err != nil:
+		p.message = fmt.Sprintf("Failed to save: %v", err)
+	default:
+		p.conflict = ""
+		p.message = done
+	}
+}
+
+// updatePresets handles the keys of the management screen
+func (m model) updatePresets(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	p := &m.presets
+
+	if p.conflict != "" {
+		switch keys.name(msg) {
+		case "y":
+			m.savePresetsChange(p.conflict, true, tr(msgPresetsOverwrote))
+		case "n", "esc":
+			m.openPresets()
+			p.message = tr(msgPresetsReloaded)
+		}
+		return m, nil
+	}
+
+	if p.naming != "" {
+		switch msg.Type {
+		case tea.KeyEsc:
+			p.naming = ""
+			p.input.Blur()
+			return m, nil
+		case tea.KeyEnter:
+		default:
+			var cmd tea.Cmd
+			p.input, cmd = p.input.Update(msg)
+			return m, cmd
+		}
+		name := strings.TrimSpace(p.input.Value())
+		if name == "" {
+			p.message = tr(msgPresetNameRequired)
+			return m, nil
+		}
+		naming := p.naming
+		p.naming = ""
+		p.input.Blur()
+		switch naming {
+		case "preset":
+			p.presets = append(p.presets, presetFromPayload(name, m.pendingPayload))
+			m.savePresetsChange("preset", false, fmt.Sprintf("Saved preset %q", name))
+			p.selectItem(false, len(p.presets)-1)
+		case "bookmark":
+			p.bookmarks = append(p.bookmarks, Bookmark{Preset: presetFromPayload(name, m.pendingPayload), Env: m.selectedEnv})
+			m.savePresetsChange("bookmark", false, fmt.Sprintf("Saved bookmark %q", name))
+			p.selectItem(true, len(p.bookmarks)-1)
+		case "rename":
+			i, ok := p.list.SelectedItem().(presetItem)
+			if !ok {
+				return m, nil
+			}
+			if i.bookmark {
+				p.bookmarks[i.index].Name = name
+				m.savePresetsChange("bookmark", false, "Renamed to "+name)
+			} else {
+				p.presets[i.index].Name = name
+				m.savePresetsChange("preset", false, "Renamed to "+name)
+			}
+		}
+		return m, nil
+	}
+
+	i, selected := p.list.SelectedItem().(presetItem)
+	switch keys.name(msg) {
+	case "ctrl+c", "q":
+		return m, tea.Quit
+	case "b", "esc":
+		m.currentScreen = ActionScreen
+		return m, nil
+	case "enter":
+		if !selected {
+			return m, nil
+		}
+		if i.bookmark && i.env != m.selectedEnv {
+			p.message = tr(msgPresetsBookmarkEnv, i.env)
+			return m, nil
+		}
+		m.openPrompt(string(i.preset.Action))
+		m.promptForm.SetValue(0, fmt.Sprint(i.preset.Value))
+		m.pendingPayload = i.preset.payload()
+		m.pendingPayload.DryRun = m.pendingPayload.DryRun || promptDryRun(m.selectedEnv, m.pendingPayload.Action)
+		if err := assignIdempotencyKey(&m.pendingPayload); err != nil {
+			p.message = err.Error()
+			return m, nil
+		}
+		return m, tea.Batch(m.showConfirm(), m.checkQuestState())
+	case "r":
+		if selected {
+			return m, m.startNaming("rename", i.preset.Name)
+		}
+		return m, nil
+	case "x":
+		if !selected {
+			return m, nil
+		}
+		if i.bookmark {
+			p.bookmarks = slices.Delete(p.bookmarks, i.index, i.index+1)
+			m.savePresetsChange("bookmark", false, fmt.Sprintf("Deleted bookmark %q", i.preset.Name))
+		} else {
+			p.presets = slices.Delete(p.presets, i.index, i.index+1)
+			m.savePresetsChange("preset", false, fmt.Sprintf("Deleted preset %q", i.preset.Name))
+		}
+		return m, nil
+	case "K", "J":
+		if !selected {
+			return m, nil
+		}
+		to := i.index - 1
+		if keys.name(msg) == "J" {
+			to = i.index + 1
+		}
+		if i.bookmark && to >= 0 && to < len(p.bookmarks) {
+			p.bookmarks[i.index], p.bookmarks[to] = p.bookmarks[to], p.bookmarks[i.index]
+			m.savePresetsChange("bookmark", false, "")
+			p.selectItem(true, to)
+		} else if !i.bookmark && to >= 0 && to < len(p.presets) {
+			p.presets[i.index], p.presets[to] = p.presets[to], p.presets[i.index]
+			m.savePresetsChange("preset", false, "")
+			p.selectItem(false, to)
+		}
+		return m, nil
+	}
+
+	var cmd tea.Cmd
+	p.list, cmd = p.list.Update(msg)
+	return m, cmd
+}
+
+func (m model) presetsView() string {
+	p := m.presets
+	footer := "  " + tr(msgPresetsHelp, keys.help(keyConfirm), keys.help(keyBack))
+	switch {
+	case p.conflict != "":
+		file := "config file"
+		if p.conflict == "bookmark" {
+			file = "state file"
+		}
+		footer = errorStyle.Render("  " + tr(msgPresetsConflict, p.conflict, file))
+	case p.naming != "":
+		footer = "  " + p.input.View() + "  " + tr(msgNamingHelp)
+	}
+	if p.message != "" {
+		footer = "  " + p.message + "\n" + footer
+	}
+	return p.list.View() + "\n" + footer
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+)
+
+// printPayload prints what would be sent instead of invoking, set with
+// --print-payload. Nothing leaves the machine: no SSO check, no quest state
+// fetch and no invoke.
+var printPayload bool
+
+// exitOutput is printed once the TUI exits, set by the confirmation screen
+// to print the pending payload
+var exitOutput string
+
+// payloadPreview is the --print-payload --output json document
+type payloadPreview struct {
+	Env          string `json:"env"`
+	FunctionName string `json:"function_name,omitempty"`
+	URL          string `json:"url,omitempty"`
+	Profile      string `json:"profile"`
+	// Payload is the payload as it would be sent, after any transform
+	Payload json.RawMessage `json:"payload"`
+}
+
+func newPayloadPreview(env string, payload EventPayload) (payloadPreview, error) {
+	data, err := encodePayload(env, payload)
+	if err != nil {
+		return payloadPreview{}, err
+	}
+	p := payloadPreview{Env: env, Profile: profileMap[env], Payload: data}
+	if tool := toolRegistry[sweepstakeTool]; tool.Transport == transportHTTP {
+		p.URL = tool.URLs[env]
+	} else {
+		p.FunctionName = sweepstakeFunction(env)
+	}
+	return p, nil
+}
+
+// String lays the preview out for reading, the payload indented last
+func (p payloadPreview) String() string {
+	var indented bytes.Buffer
+	_ = json.Indent(&indented, p.Payload, "", "  ")
+	target := "Function: " + p.FunctionName
+	if p.URL != "" {
+		target = "URL:      " + p.URL
+	}
+	return fmt.Sprintf("Env:      %s\n%s\nProfile:  %s\nPayload:\n%s\n", p.Env, target, p.Profile, indented.String())
+}
+
+// printPayloadPreview prints the payload that would be sent to env and
+// returns the exit code, in place of the confirmation and the invocation
+func printPayloadPreview(stdout io.Writer, env string, payload EventPayload, jsonOutput bool) int {
+	p, err := newPayloadPreview(env, payload)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitUsage
+	}
+	if !jsonOutput {
+		fmt.Fprint(stdout, p.String())
+		return 0
+	}
+	enc := json.NewEncoder(stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(p); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitFailure
+	}
+	return 0
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// progressPollInterval is how often the log group is polled for progress lines
+const progressPollInterval = 2 * time.Second
+
+// progressMsg is the latest progress reported by a running invocation
+type progressMsg struct {
+	done, total int
+	unit        string
+	// status is the status of a pending async invocation, sent instead of
+	// the counts
+	status string
+	// gen is the result generation of the invocation it's from
+	gen int
+}
+
+// parseProgress extracts the entry counts from a progress line
+func parseProgress(re *regexp.Regexp, line string) (progressMsg, bool) {
+	m := re.FindStringSubmatch(line)
+	if m == nil {
+		return progressMsg{}, false
+	}
+	done, err := strconv.Atoi(m[re.SubexpIndex("done")])
+	if err != nil {
+		return progressMsg{}, false
+	}
+	total, err := strconv.Atoi(m[re.SubexpIndex("total")])
+	if err != nil || total <= 0 {
+		return progressMsg{}, false
+	}
+	return progressMsg{done: done, total: total, unit: "entries"}, true
+}
+
+// compileProgressPattern checks a configured pattern has the groups parseProgress needs
+func compileProgressPattern(pattern string) (*regexp.Regexp, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	if re.SubexpIndex("done") < 0 || re.SubexpIndex("total") < 0 {
+		return nil, fmt.Errorf("pattern %q needs (?P<done>...) and (?P<total>...) groups", pattern)
+	}
+	return re, nil
+}
+
+// tailProgress polls the function's logs from since until ctx is done,
+// sending every progress line it finds. Fetch errors are ignored: without
+// progress lines the loading screen simply keeps its spinner.
+func tailProgress(ctx context.Context, env, functionName string, since time.Time, re *regexp.Regexp, ch chan<- progressMsg) {
+	ticker := time.NewTicker(progressPollInterval)
+	defer ticker.Stop()
+
+	start := since.UnixMilli()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		var resp struct {
+			Events []logEvent `json:"events"`
+		}
+		if awsLogs(profileMap[env], &resp, "filter-log-events",
+			"--log-group-name", logGroupName(functionName),
+			"--start-time", strconv.FormatInt(start, 10),
+		) != nil {
+			continue
+		}
+		for _, e := range resp.Events {
+			start = max(start, e.Timestamp+1)
+			if p, ok := parseProgress(re, e.Message); ok {
+				select {
+				case ch <- p:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}
+}
+
+// waitForProgress delivers the next progress update of the invocation of
+// result generation gen, or nothing once it has finished and the channel is
+// closed
+func waitForProgress(gen int, ch <-chan progressMsg) tea.Cmd {
+	return func() tea.Msg {
+		p, ok := <-ch
+		if !ok {
+			return nil
+		}
+		p.gen = gen
+		return p
+	}
+}
+
+// progressBar renders a progress update as a bar with entry counts
+func progressBar(p progressMsg, width int) string {
+	done := min(p.done, p.total)
+	filled := done * width / p.total
+	return fmt.Sprintf("%s%s %d/%d %s (%d%%)",
+		strings.Repeat(glyphs.Bar, filled), strings.Repeat(glyphs.BarEmpty, width-filled),
+		p.done, p.total, p.unit, done*100/p.total)
+}
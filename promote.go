@@ -0,0 +1,253 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// compareAction is the action list entry that checks a quest's status
+// agrees between an environment and prod before promoting its configuration
+const compareAction = "compare"
+
+// promotionAckPhrase has to be typed to start a sweepstake in prod after a
+// failed comparison in the session
+const promotionAckPhrase = "override checklist"
+
+// defaultMayDiffer are the status fields expected to differ between
+// environments when the config doesn't name them
+var defaultMayDiffer = []string{"id", "request_id", "created_at", "updated_at"}
+
+// promotionFields names the status response paths that must match between
+// environments and the ones expected to differ. Paths are dotted, with
+// numbers indexing arrays, like prize_tiers.0.amount.
+type promotionFields struct {
+	// MustMatch is every top-level field but MayDiffer when empty
+	MustMatch []string
+	MayDiffer []string
+}
+
+// checkItem is a line of the promotion checklist
+type checkItem struct {
+	Path     string
+	From, To string
+	Pass     bool
+	// Expected marks a field that may differ, listed but not checked
+	Expected bool
+}
+
+// promotionChecklist is the outcome of comparing a quest's status between
+// two environments
+type promotionChecklist struct {
+	From, To string
+	Quest    int
+	Items    []checkItem
+}
+
+// failed returns the checks that didn't pass
+func (c promotionChecklist) failed() []checkItem {
+	var failed []checkItem
+	for _, it := range c.Items {
+		if !it.Expected && !it.Pass {
+			failed = append(failed, it)
+		}
+	}
+	return failed
+}
+
+// promotionMsg carries a comparison, the checklist nil when a status
+// couldn't be fetched
+type promotionMsg struct {
+	checklist *promotionChecklist
+	output    []string
+	err       error
+}
+
+// promotionSource is the environment compared with prod, dev when prod
+// itself is selected
+func promotionSource(selected string) string {
+	if selected == prodEnv {
+		return devEnv
+	}
+	return selected
+}
+
+// startComparison fetches the quest's status in the selected environment
+// and prod
+func (m model) startComparison(quest int) (tea.Model, tea.Cmd) {
+	if pinnedEnv != "" {
+		m.promptMessage = tr(msgCompareUnpinned, prodEnv, pinnedEnv)
+		return m, m.promptForm.Focus(0)
+	}
+	m.currentScreen = LoadingScreen
+	m.progress = nil
+	return m, tea.Batch(m.spinner.Tick, compareEnvironmentsCmd(promotionSource(m.selectedEnv), prodEnv, quest))
+}
+
+func compareEnvironmentsCmd(from, to string, quest int) tea.Cmd {
+	return func() tea.Msg {
+		bodies := map[string][]byte{}
+		for _, env := range []string{from, to} {
+			inv, err := fetchStatus(env, quest)
+			if err != nil {
+				return promotionMsg{err: fmt.Errorf("failed to fetch the status in %s: %v", env, err)}
+			}
+			bodies[env] = inv.Body
+		}
+		checklist, err := comparePromotion(from, to, quest, bodies[from], bodies[to], toolRegistry[sweepstakeTool].Promotion)
+		if err != nil {
+			return promotionMsg{err: err}
+		}
+		return promotionMsg{checklist: &checklist, output: checklistLines(checklist)}
+	}
+}
+
+// fetchStatus invokes the read-only status action of a quest, recording it
+// in the history
+func fetchStatus(env string, quest int) (*invocation, error) {
+	inv := &invocation{Env: env, Payload: EventPayload{Action: ActionStatus, SweepstakeQuestID: &quest}, Mode: modeSync}
+	err := invokeLambda(context.Background(), inv, nil, nil)
+	finishInvocation(inv, err)
+	if err == nil && inv.FunctionError != "" {
+		err = fmt.Errorf("%s", functionErrorMessage(inv))
+	}
+	return inv, err
+}
+
+// comparePromotion checks the status responses of two environments field by
+// field
+func comparePromotion(from, to string, quest int, fromBody, toBody []byte, fields promotionFields) (promotionChecklist, error) {
+	c := promotionChecklist{From: from, To: to, Quest: quest}
+	var a, b interface{}
+	if json.Unmarshal(fromBody, &a) != nil || json.Unmarshal(toBody, &b) != nil {
+		return c, fmt.Errorf("the status responses aren't JSON and can't be compared")
+	}
+
+	mustMatch := fields.MustMatch
+	if len(mustMatch) == 0 {
+		keys, _ := objectKeys(fromBody)
+		toKeys, _ := objectKeys(toBody)
+		for _, k := range toKeys {
+			if !slices.Contains(keys, k) {
+				keys = append(keys, k)
+			}
+		}
+		for _, k := range keys {
+			if !slices.Contains(fields.MayDiffer, k) {
+				mustMatch = append(mustMatch, k)
+			}
+		}
+	}
+	for _, path := range mustMatch {
+		va, okA := lookupPath(a, path)
+		vb, okB := lookupPath(b, path)
+		c.Items = append(c.Items, checkItem{
+			Path: path,
+			From: checkValue(va, okA),
+			To:   checkValue(vb, okB),
+			Pass: okA && okB && reflect.DeepEqual(va, vb),
+		})
+	}
+	for _, path := range fields.MayDiffer {
+		va, okA := lookupPath(a, path)
+		vb, okB := lookupPath(b, path)
+		if okA || okB {
+			c.Items = append(c.Items, checkItem{Path: path, From: checkValue(va, okA), To: checkValue(vb, okB), Expected: true})
+		}
+	}
+	return c, nil
+}
+
+// lookupPath follows a dotted path through decoded JSON
+func lookupPath(v interface{}, path string) (interface{}, bool) {
+	for _, part := range strings.Split(path, ".") {
+		switch node := v.(type) {
+		case map[string]interface{}:
+			var ok bool
+			if v, ok = node[part]; !ok {
+				return nil, false
+			}
+		case []interface{}:
+			i, err := strconv.Atoi(part)
+			if err != nil || i < 0 || i >= len(node) {
+				return nil, false
+			}
+			v = node[i]
+		default:
+			return nil, false
+		}
+	}
+	return v, true
+}
+
+// checkValue renders a checked value like the snapshot comparison does
+func checkValue(v interface{}, ok bool) string {
+	if !ok {
+		return "missing"
+	}
+	raw, _ := json.Marshal(v)
+	return comparisonValue(raw)
+}
+
+// checklistLines renders the checklist for the output screen
+func checklistLines(c promotionChecklist) []string {
+	lines := []string{fmt.Sprintf("Quest %d status in %s checked against %s:", c.Quest, c.From, c.To), ""}
+	width := [2]int{}
+	for _, it := range c.Items {
+		width[0] = max(width[0], len(it.Path))
+		width[1] = max(width[1], len(it.From))
+	}
+	checks := 0
+	for _, it := range c.Items {
+		mark := glyphs.Check
+		switch {
+		case it.Expected:
+			mark = "~"
+		case !it.Pass:
+			mark = glyphs.Cross
+		}
+		line := fmt.Sprintf("%s %-*s  %-*s  %s", mark, width[0], it.Path, width[1], it.From, it.To)
+		if it.Expected {
+			line += "  (expected to differ)"
+		} else {
+			checks++
+		}
+		lines = append(lines, line)
+	}
+	lines = append(lines, "")
+	failed := c.failed()
+	if len(failed) == 0 {
+		return append(lines, fmt.Sprintf("%s All %d checks passed, %s agrees with %s", glyphs.Check, checks, c.From, c.To))
+	}
+	return append(lines, fmt.Sprintf("%s %d of %d checks failed. Starting a sweepstake in %s this session needs %q typed.",
+		glyphs.Cross, len(failed), checks, c.To, promotionAckPhrase))
+}
+
+// promotionBlocks reports whether a failed comparison in the session holds
+// back the pending invocation, a prod start that isn't a dry run
+func (m model) promotionBlocks() bool {
+	_, ok := warning(m.checkResults(), "promotion")
+	return ok
+}
+
+// promotionView warns on the confirmation screen about a failed comparison
+func (m model) promotionView() string {
+	if !m.promotionBlocks() {
+		return ""
+	}
+	c := m.promotion
+	var paths []string
+	for _, it := range c.failed() {
+		paths = append(paths, it.Path)
+	}
+	sort.Strings(paths)
+	return fmt.Sprintf("  %s %s\n\n", glyphs.Warn,
+		tr(msgCompareMismatch, c.Quest, c.From, c.To, strings.Join(paths, ", "), promotionAckPhrase))
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"encoding/json"
+	"strconv"
+	"time"
+)
+
+// defaultQuestCacheTTL is how long a quest's status is reused before it's
+// fetched again
+const defaultQuestCacheTTL = 5 * time.Minute
+
+// questCacheTTL is set with cache.quest_ttl in the config file, zero to
+// always fetch
+var questCacheTTL = defaultQuestCacheTTL
+
+// cachedQuest is a quest's status response kept in the state file
+type cachedQuest struct {
+	Status    json.RawMessage `json:"status"`
+	FetchedAt time.Time       `json:"fetched_at"`
+}
+
+// applyCacheConfig validates and applies the cache section of the config
+func applyCacheConfig(cfg CacheConfig) error {
+	questCacheTTL = defaultQuestCacheTTL
+	if cfg.QuestTTL == "" {
+		return nil
+	}
+	d, err := time.ParseDuration(cfg.QuestTTL)
+	if err != nil || d < 0 {
+		return configErrorf([]string{"cache", "quest_ttl"}, "expected a duration like 5m, or 0 to always fetch, got %q", cfg.QuestTTL)
+	}
+	questCacheTTL = d
+	return nil
+}
+
+// cachedQuestStatus is the cached status of a quest when it's still fresh
+func cachedQuestStatus(env string, quest int) (cachedQuest, bool) {
+	if questCacheTTL == 0 {
+		return cachedQuest{}, false
+	}
+	state, err := loadState()
+	if err != nil {
+		return cachedQuest{}, false
+	}
+	c, ok := state.Quests[env][strconv.Itoa(quest)]
+	if !ok || time.Since(c.FetchedAt) > questCacheTTL {
+		return cachedQuest{}, false
+	}
+	return c, true
+}
+
+// saveQuestStatus caches a quest's status response
+func saveQuestStatus(env string, quest int, c cachedQuest) {
+	_ = updateState(func(state *appState) {
+		if state.Quests == nil {
+			state.Quests = map[string]map[string]cachedQuest{}
+		}
+		if state.Quests[env] == nil {
+			state.Quests[env] = map[string]cachedQuest{}
+		}
+		state.Quests[env][strconv.Itoa(quest)] = c
+	})
+}
+
+// invalidateQuest drops a quest's cached status, done whenever it's invoked
+// with a mutating action
+func invalidateQuest(env string, quest int) {
+	_ = updateState(func(state *appState) {
+		delete(state.Quests[env], strconv.Itoa(quest))
+	})
+}
+
+// fetchQuestStatus is a quest's status response, from the cache unless it's
+// stale or refresh is set. cached reports whether it came from the cache.
+func fetchQuestStatus(env string, quest int, refresh bool) (c cachedQuest, cached bool, err error) {
+	if !refresh {
+		if c, ok := cachedQuestStatus(env, quest); ok {
+			return c, true, nil
+		}
+	}
+	inv, err := fetchStatus(env, quest)
+	if err != nil {
+		return c, false, err
+	}
+	c = cachedQuest{Status: inv.Body, FetchedAt: time.Now()}
+	saveQuestStatus(env, quest, c)
+	return c, false, nil
+}
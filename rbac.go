@@ -0,0 +1,167 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/list"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// roleCapabilities maps role names to the capability labels they grant,
+// from the roles section of the config file. The hints they drive are
+// advisory only, AWS still decides what the caller may invoke.
+var roleCapabilities map[string][]string
+
+// callerRole extracts the role name from an assumed-role STS ARN like
+// arn:aws:sts::123456789012:assumed-role/AWSReservedSSO_platform-prod-engineer_0123abcd/jane
+func callerRole(arn string) string {
+	_, rest, ok := strings.Cut(arn, ":assumed-role/")
+	if !ok {
+		return ""
+	}
+	role, _, _ := strings.Cut(rest, "/")
+	return role
+}
+
+// roleMatches reports whether a configured role name refers to role, either
+// exactly or as the permission set of an SSO role
+func roleMatches(name, role string) bool {
+	if name == role {
+		return true
+	}
+	set, ok := strings.CutPrefix(role, "AWSReservedSSO_")
+	if !ok {
+		return false
+	}
+	if i := strings.LastIndex(set, "_"); i >= 0 {
+		set = set[:i]
+	}
+	return name == set
+}
+
+// grantedCapabilities collects the capabilities of every configured role
+// matching role, reporting whether any did
+func grantedCapabilities(role string) (map[string]bool, bool) {
+	granted := map[string]bool{}
+	known := false
+	for name, caps := range roleCapabilities {
+		if roleMatches(name, role) {
+			known = true
+			for _, c := range caps {
+				granted[c] = true
+			}
+		}
+	}
+	return granted, known
+}
+
+// listActionInvokes is the lambda action an action list entry invokes
+func listActionInvokes(listAction string) (Action, bool) {
+	switch listAction {
+	case string(ActionProcess), string(ActionComplete), string(ActionStart), string(ActionRollback):
+		return Action(listAction), true
+	case batchAction, investigateAction:
+		return ActionProcess, true
+	}
+	return "", false
+}
+
+// restriction is the note for an action list entry the caller's role likely
+// can't run, naming the roles that can. It is empty when the role can, or
+// when the role isn't in the config and nothing is known about it.
+func restriction(arn, listAction string) string {
+	action, ok := listActionInvokes(listAction)
+	if !ok {
+		return ""
+	}
+	required := toolRegistry[sweepstakeTool].Requires[action]
+	role := callerRole(arn)
+	if len(required) == 0 || role == "" {
+		return ""
+	}
+	granted, known := grantedCapabilities(role)
+	if !known {
+		return ""
+	}
+	var missing []string
+	for _, c := range required {
+		if !granted[c] {
+			missing = append(missing, c)
+		}
+	}
+	if len(missing) == 0 {
+		return ""
+	}
+
+	var roles []string
+	for name, caps := range roleCapabilities {
+		if !slices.ContainsFunc(required, func(c string) bool { return !slices.Contains(caps, c) }) {
+			roles = append(roles, name)
+		}
+	}
+	if len(roles) == 0 {
+		return "requires " + strings.Join(missing, ", ")
+	}
+	sort.Strings(roles)
+	return "requires " + strings.Join(roles, " or ")
+}
+
+// applyRestrictions marks the action list entries the caller's role in the
+// selected environment likely can't run
+func (m *model) applyRestrictions() {
+	arn := m.envInfo[m.selectedEnv].Arn
+	for i, li := range m.actionList.Items() {
+		it, ok := li.(item)
+		if !ok {
+			continue
+		}
+		if note := restriction(arn, it.action); note != it.requires {
+			it.requires = note
+			m.actionList.SetItem(i, it)
+		}
+	}
+}
+
+// restrictionView warns on the confirmation screen that the action will
+// likely be denied
+func (m model) restrictionView() string {
+	arn := m.envInfo[m.selectedEnv].Arn
+	note := restriction(arn, m.selectedAction)
+	if note == "" {
+		return ""
+	}
+	return fmt.Sprintf("  %s %s\n\n", glyphs.Warn, tr(msgConfirmRestriction, callerRole(arn), note, keys.help(keyConfirm)))
+}
+
+// actionDelegate renders the action list, dimming the entries the caller's
+// role likely can't run and the ones the quest's state doesn't allow
+type actionDelegate struct {
+	list.DefaultDelegate
+	restricted list.DefaultDelegate
+}
+
+func newActionDelegate() actionDelegate {
+	d := actionDelegate{DefaultDelegate: newListDelegate(), restricted: newListDelegate()}
+	s := &d.restricted.Styles
+	s.NormalTitle = s.NormalTitle.Foreground(colors.Dim)
+	s.NormalDesc = s.NormalDesc.Foreground(colors.Dim)
+	s.SelectedTitle = s.SelectedTitle.Foreground(colors.Dim).BorderForeground(colors.Dim)
+	s.SelectedDesc = s.SelectedDesc.Foreground(colors.Dim).BorderForeground(colors.Dim)
+	return d
+}
+
+func (d actionDelegate) Render(w io.Writer, m list.Model, index int, li list.Item) {
+	if it, ok := li.(item); ok && (it.requires != "" || it.unavailable != "") {
+		d.restricted.Render(w, m, index, li)
+		return
+	}
+	d.DefaultDelegate.Render(w, m, index, li)
+}
+
+func (d actionDelegate) Update(msg tea.Msg, m *list.Model) tea.Cmd {
+	return d.DefaultDelegate.Update(msg, m)
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+const (
+	engineerArn = "arn:aws:sts::123456789012:assumed-role/AWSReservedSSO_platform-prod-engineer_0123abcd/jane"
+	supportArn  = "arn:aws:sts::123456789012:assumed-role/AWSReservedSSO_support_4567ef01/sam"
+)
+
+// withRoles configures the role mapping and the capabilities the sweepstake
+// actions require for the test
+func withRoles(t *testing.T, roles map[string][]string, requires map[Action][]string) {
+	spec := toolRegistry[sweepstakeTool]
+	savedRoles, savedRequires := roleCapabilities, spec.Requires
+	roleCapabilities, spec.Requires = roles, requires
+	t.Cleanup(func() { roleCapabilities, spec.Requires = savedRoles, savedRequires })
+}
+
+func TestCallerRole(t *testing.T) {
+	for arn, want := range map[string]string{
+		engineerArn: "AWSReservedSSO_platform-prod-engineer_0123abcd",
+		"arn:aws:sts::123456789012:assumed-role/deployer/ci-run-7": "deployer",
+		"arn:aws:sts::123456789012:assumed-role/deployer":          "deployer",
+		"arn:aws:iam::123456789012:user/jane":                      "",
+		"":                                                         "",
+	} {
+		if got := callerRole(arn); got != want {
+			t.Errorf("callerRole(%q) = %q, want %q", arn, got, want)
+		}
+	}
+}
+
+func TestRoleMatches(t *testing.T) {
+	for _, tt := range []struct {
+		name, role string
+		want       bool
+	}{
+		{"deployer", "deployer", true},
+		{"platform-prod-engineer", "AWSReservedSSO_platform-prod-engineer_0123abcd", true},
+		{"AWSReservedSSO_platform-prod-engineer_0123abcd", "AWSReservedSSO_platform-prod-engineer_0123abcd", true},
+		// Only the suffix after the last underscore is the SSO hash
+		{"platform_prod", "AWSReservedSSO_platform_prod_0123abcd", true},
+		{"platform", "AWSReservedSSO_platform_prod_0123abcd", false},
+		{"platform-prod", "AWSReservedSSO_platform-prod-engineer_0123abcd", false},
+		{"deployer", "deployer-readonly", false},
+		{"platform-prod-engineer", "platform-prod-engineer_0123abcd", false},
+	} {
+		if got := roleMatches(tt.name, tt.role); got != tt.want {
+			t.Errorf("roleMatches(%q, %q) = %v, want %v", tt.name, tt.role, got, tt.want)
+		}
+	}
+}
+
+func TestGrantedCapabilities(t *testing.T) {
+	withRoles(t, map[string][]string{
+		"platform-prod-engineer":                         {"distribute"},
+		"AWSReservedSSO_platform-prod-engineer_0123abcd": {"process"},
+		"support": {"read"},
+	}, nil)
+
+	// Every configured name of the role adds its capabilities
+	granted, known := grantedCapabilities("AWSReservedSSO_platform-prod-engineer_0123abcd")
+	if want := map[string]bool{"distribute": true, "process": true}; !known || !reflect.DeepEqual(granted, want) {
+		t.Errorf("granted %v (known %v), want %v", granted, known, want)
+	}
+	if granted, known := grantedCapabilities("auditor"); known || len(granted) != 0 {
+		t.Errorf("an unconfigured role granted %v (known %v)", granted, known)
+	}
+}
+
+func TestRestriction(t *testing.T) {
+	requires := map[Action][]string{
+		ActionComplete: {"distribute"},
+		ActionProcess:  {"process"},
+	}
+	tests := []struct {
+		name   string
+		roles  map[string][]string
+		arn    string
+		action string
+		want   string
+	}{
+		{
+			name:   "granted",
+			roles:  map[string][]string{"platform-prod-engineer": {"distribute", "process"}},
+			arn:    engineerArn,
+			action: string(ActionComplete),
+		},
+		{
+			name:   "names the roles that can",
+			roles:  map[string][]string{"platform-prod-engineer": {"distribute"}, "release-manager": {"distribute", "read"}, "support": {"read"}},
+			arn:    supportArn,
+			action: string(ActionComplete),
+			want:   "requires platform-prod-engineer or release-manager",
+		},
+		{
+			name:   "names the capability when no role has it",
+			roles:  map[string][]string{"support": {"read"}},
+			arn:    supportArn,
+			action: string(ActionComplete),
+			want:   "requires distribute",
+		},
+		{
+			name:   "batches need what process needs",
+			roles:  map[string][]string{"platform-prod-engineer": {"process"}, "support": {"read"}},
+			arn:    supportArn,
+			action: batchAction,
+			want:   "requires platform-prod-engineer",
+		},
+		{
+			name:   "actions without requirements",
+			roles:  map[string][]string{"support": {"read"}},
+			arn:    supportArn,
+			action: string(ActionStart),
+		},
+		{
+			name:   "actions that don't invoke",
+			roles:  map[string][]string{"support": {"read"}},
+			arn:    supportArn,
+			action: presetsAction,
+		},
+		{
+			name:   "a role that isn't configured",
+			roles:  map[string][]string{"platform-prod-engineer": {"distribute"}},
+			arn:    supportArn,
+			action: string(ActionComplete),
+		},
+		{
+			name:   "a caller that isn't a role",
+			roles:  map[string][]string{"platform-prod-engineer": {"distribute"}},
+			arn:    "arn:aws:iam::123456789012:user/jane",
+			action: string(ActionComplete),
+		},
+		{
+			name:   "no identity yet",
+			roles:  map[string][]string{"platform-prod-engineer": {"distribute"}},
+			action: string(ActionComplete),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			withRoles(t, tt.roles, requires)
+			if got := restriction(tt.arn, tt.action); got != tt.want {
+				t.Errorf("restriction = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"regexp"
+
+	"github.com/aws/aws-sdk-go-v2/config"
+)
+
+// regionMap holds the regions the config sets per environment. An
+// environment without one uses the region of its profile.
+var regionMap = map[string]string{}
+
+var regionPattern = regexp.MustCompile(`^[a-z]{2}(-[a-z]+)+-\d+$`)
+
+// envRegion loads an environment's AWS config in its configured region, or
+// leaves the region to the profile
+func envRegion(env string) config.LoadOptionsFunc {
+	return config.WithRegion(regionMap[env])
+}
+
+// regionArgs are the aws CLI arguments selecting the configured region of
+// the environment using profile, none when it has no region
+func regionArgs(profile string) []string {
+	for env, p := range profileMap {
+		if p == profile && regionMap[env] != "" {
+			return []string{"--region", regionMap[env]}
+		}
+	}
+	return nil
+}
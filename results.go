@@ -0,0 +1,154 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// responseElements splits a response holding a top-level JSON array, which
+// the calculator returns with one object per processed batch when the batch
+// size is small. ok is false for any other response.
+func responseElements(body []byte) ([]json.RawMessage, bool) {
+	trimmed := bytes.TrimSpace(body)
+	if len(trimmed) == 0 || trimmed[0] != '[' {
+		return nil, false
+	}
+	var elems []json.RawMessage
+	if err := json.Unmarshal(trimmed, &elems); err != nil {
+		return nil, false
+	}
+	return elems, true
+}
+
+// resultErrors counts the errors a batch result reports, as an errors count
+// or list, or as a single error message
+func resultErrors(elem []byte) int {
+	if n, ok := objectCount(elem, "errors"); ok {
+		return n
+	}
+	var resp map[string]interface{}
+	if json.Unmarshal(elem, &resp) != nil {
+		return 0
+	}
+	for _, key := range []string{"error", "errorMessage"} {
+		if s, ok := resp[key].(string); ok && s != "" {
+			return 1
+		}
+	}
+	return 0
+}
+
+// resultsSummary sums the numeric fields of an array response across its
+// results, then lists each result on a line. Elements that aren't objects
+// are listed and counted as malformed rather than hiding the others.
+func resultsSummary(elems []json.RawMessage) []string {
+	totals := map[string]float64{}
+	var order []string
+	var errors, malformed int
+	items := make([]string, 0, len(elems))
+	for i, elem := range elems {
+		var resp map[string]interface{}
+		keys, err := objectKeys(elem)
+		if err != nil || json.Unmarshal(elem, &resp) != nil {
+			malformed++
+			items = append(items, fmt.Sprintf("  [%d] not a JSON object: %s", i+1, string(elem)))
+			continue
+		}
+		errors += resultErrors(elem)
+		var fields []string
+		if s, ok := resp["status"].(string); ok {
+			fields = append(fields, "status: "+s)
+		}
+		for _, k := range summaryKeys(keys) {
+			v, ok := resp[k].(float64)
+			if !ok {
+				continue
+			}
+			if _, seen := totals[k]; !seen {
+				order = append(order, k)
+			}
+			totals[k] += v
+			fields = append(fields, fmt.Sprintf("%s: %s", k, formatDecimal(v, displayLocale)))
+		}
+		for _, key := range []string{"error", "errorMessage"} {
+			if msg, ok := resp[key].(string); ok && msg != "" {
+				fields = append(fields, "error: "+msg)
+			}
+		}
+		if len(fields) == 0 {
+			fields = append(fields, "no numeric fields")
+		}
+		items = append(items, fmt.Sprintf("  [%d] %s", i+1, strings.Join(fields, ", ")))
+	}
+
+	lines := []string{fmt.Sprintf("Summary of %d results:", len(elems))}
+	for _, k := range summaryKeys(order) {
+		// IDs and durations don't add up, and errors are counted below
+		if k == "sweepstake_quest_id" || k == "duration_minutes" || k == "errors" {
+			continue
+		}
+		lines = append(lines, fmt.Sprintf("  %s: %s", k, formatDecimal(totals[k], displayLocale)))
+	}
+	lines = append(lines, fmt.Sprintf("  errors: %s", formatNumber(int64(errors), displayLocale)))
+	if malformed > 0 {
+		lines = append(lines, fmt.Sprintf("  malformed results: %d", malformed))
+	}
+	lines = append(lines, "Results:")
+	return append(lines, items...)
+}
+
+// summaryKeys orders keys the way summaries list them: the known fields
+// first, then the others in their original order
+func summaryKeys(order []string) []string {
+	keys := make([]string, 0, len(order))
+	for _, k := range summaryFieldOrder {
+		if slices.Contains(order, k) {
+			keys = append(keys, k)
+		}
+	}
+	for _, k := range order {
+		if !slices.Contains(keys, k) {
+			keys = append(keys, k)
+		}
+	}
+	return keys
+}
+
+// responseLine is the full response as invocationLines shows it
+func responseLine(body []byte) string {
+	formatted, err := indentJSON(body)
+	if err != nil {
+		return fmt.Sprintf("Raw response: %s", string(body))
+	}
+	return fmt.Sprintf("Response: %s", string(formatted))
+}
+
+// visibleOutput is the output screen's lines with the full JSON of an array
+// response collapsed to a line, as the per-result lines above it already
+// summarize it. 'e' expands it.
+func (m model) visibleOutput() []string {
+	inv := m.lastInvocation
+	if m.resultsExpanded || inv == nil {
+		return m.lambdaOutput
+	}
+	elems, ok := responseElements(inv.Body)
+	if !ok && m.winners.table == nil {
+		return m.lambdaOutput
+	}
+	collapsed := fmt.Sprintf("Response: %d results, press 'e' to expand", len(elems))
+	if t := m.winners.table; t != nil {
+		collapsed = fmt.Sprintf("Response: %s winners, press 'w' to browse them or 'e' to expand", formatNumber(int64(len(t.rows)), displayLocale))
+	}
+	full := responseLine(inv.Body)
+	lines := make([]string, 0, len(m.lambdaOutput))
+	for _, line := range m.lambdaOutput {
+		if line == full {
+			line = collapsed
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
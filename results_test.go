@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/revrost/playtools/pkg/playtools"
+)
+
+func TestResultsFixtures(t *testing.T) {
+	tests := []struct {
+		fixture string
+		// results is the number of results of an array response, -1 when
+		// the response isn't one
+		results int
+		summary []string
+		entries int
+		// csv is the winners export, empty when there's no winners list
+		csv string
+	}{
+		{
+			fixture: "object.json",
+			results: -1,
+			summary: []string{"Summary:", "  sweepstake_quest_id: 42", "  entries: 1,500"},
+			entries: 1500,
+			csv:     "user_id,prize\nu1,gold\n",
+		},
+		{
+			fixture: "array.json",
+			results: 3,
+			summary: []string{
+				"Summary of 3 results:",
+				"  entries: 1,000",
+				"  skipped: 3",
+				"  errors: 2",
+				"Results:",
+				"  [1] status: PROCESSED, entries: 500, skipped: 3, errors: 0",
+				"  [2] status: PROCESSED, entries: 450, skipped: 0",
+				"  [3] status: PARTIAL, entries: 50, error: timed out after 50 entries",
+			},
+			entries: 1000,
+			csv:     "user_id,prize,note\nu1,gold,\nu2,silver,\nu3,bronze,late\n",
+		},
+		{
+			fixture: "malformed.json",
+			results: 5,
+			summary: []string{
+				"Summary of 5 results:",
+				"  entries: 300",
+				"  errors: 1",
+				"  malformed results: 3",
+				"Results:",
+				"  [1] status: PROCESSED, entries: 200",
+				`  [2] not a JSON object: "batch 2 failed"`,
+				"  [3] not a JSON object: null",
+				"  [4] not a JSON object: [1,2]",
+				"  [5] status: PROCESSED, entries: 100, error: partial",
+			},
+			entries: 300,
+			csv:     "user_id,prize\nu9,gold\n",
+		},
+		{
+			fixture: "truncated.json",
+			results: -1,
+		},
+	}
+	now := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	for _, tt := range tests {
+		t.Run(tt.fixture, func(t *testing.T) {
+			body := readFixture(t, filepath.Join("results", tt.fixture))
+			results := -1
+			if elems, ok := responseElements(body); ok {
+				results = len(elems)
+			}
+			if results != tt.results {
+				t.Errorf("%d results, want %d", results, tt.results)
+			}
+			if got := responseSummary(body, now); !reflect.DeepEqual(got, tt.summary) {
+				t.Errorf("summary\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(tt.summary, "\n"))
+			}
+			if n, _ := responseCount(body, "entries"); n != tt.entries {
+				t.Errorf("%d entries, want %d", n, tt.entries)
+			}
+
+			table := responseWinners(body, "winners")
+			if (table != nil) != (tt.csv != "") {
+				t.Fatalf("winners table %v", table)
+			}
+			if table == nil {
+				return
+			}
+			path := filepath.Join(t.TempDir(), "winners.csv")
+			if _, err := writeWinnersCSV(context.Background(), table, path, func(int) {}); err != nil {
+				t.Fatal(err)
+			}
+			if csv, _ := os.ReadFile(path); string(csv) != tt.csv {
+				t.Errorf("exported\n%s\nwant\n%s", csv, tt.csv)
+			}
+		})
+	}
+}
+
+// A response that isn't valid JSON is shown raw rather than dropped
+func TestTruncatedResponseShownRaw(t *testing.T) {
+	body := readFixture(t, "results/truncated.json")
+	inv := &invocation{Env: devEnv, Payload: playtools.NewPayload(ActionProcess, 42), RequestID: "req-1"}
+	inv.setResponse(body, 200)
+	lines := invocationLines(inv, nil)
+	if last := lines[len(lines)-1]; last != "Raw response: "+string(body) {
+		t.Errorf("last line %q", last)
+	}
+}
+
+// The output screen collapses the JSON of an array response to a line,
+// which 'e' expands again
+func TestArrayResponseCollapsed(t *testing.T) {
+	h := newHarness(t)
+	inv := &invocation{Env: devEnv, Payload: playtools.NewPayload(ActionProcess, 42), RequestID: "req-1"}
+	inv.setResponse(readFixture(t, "results/malformed.json"), 200)
+	h.m.lastInvocation = inv
+	h.m.lambdaOutput = invocationLines(inv, nil)
+
+	full := responseLine(inv.Body)
+	lines := h.m.visibleOutput()
+	if len(lines) != len(h.m.lambdaOutput) || lines[len(lines)-1] != "Response: 5 results, press 'e' to expand" {
+		t.Errorf("collapsed to\n%s", strings.Join(lines, "\n"))
+	}
+	if !strings.Contains(strings.Join(lines, "\n"), "  [4] not a JSON object: [1,2]") {
+		t.Error("the results aren't listed above the collapsed response")
+	}
+	h.m.resultsExpanded = true
+	if lines := h.m.visibleOutput(); lines[len(lines)-1] != full {
+		t.Errorf("expanded to %q", lines[len(lines)-1])
+	}
+}
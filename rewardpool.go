@@ -0,0 +1,224 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"sort"
+	"strings"
+)
+
+// rewardPool finds the prize tiers in a payload's overrides to add up the
+// rewards they imply. Amounts are token base units or whole tokens, never
+// fractions, so everything is exact integer math.
+type rewardPool struct {
+	// Tiers is the overrides path of the tiers, an array or an object keyed
+	// by tier name. Count and Amount are paths within a tier, a tier without
+	// a count path paying a single winner.
+	Tiers  string
+	Count  string
+	Amount string
+	// Unit labels the amounts, like IMX
+	Unit string
+	// Ceilings are the per environment totals to warn above
+	Ceilings map[string]*big.Int
+}
+
+// rewardPoolEstimate is what the overrides imply. Total only adds up the
+// tiers in Computed, Problems saying what's wrong with the others.
+type rewardPoolEstimate struct {
+	Total    *big.Int
+	Tiers    int
+	Computed int
+	Problems []string
+}
+
+// newRewardPool validates a reward pool config
+func newRewardPool(cfg RewardPoolConfig) (*rewardPool, error) {
+	if cfg.Tiers == "" {
+		return nil, fmt.Errorf("tiers is required")
+	}
+	if cfg.Amount == "" {
+		return nil, fmt.Errorf("amount is required")
+	}
+	p := &rewardPool{Tiers: cfg.Tiers, Count: cfg.Count, Amount: cfg.Amount, Unit: cfg.Unit, Ceilings: map[string]*big.Int{}}
+	for env, ceiling := range cfg.Ceilings {
+		n, ok := new(big.Int).SetString(strings.TrimSpace(ceiling), 10)
+		if !ok || n.Sign() < 0 {
+			return nil, fmt.Errorf("ceilings.%s: expected a whole non-negative amount, got %q", env, ceiling)
+		}
+		p.Ceilings[env] = n
+	}
+	return p, nil
+}
+
+// wholeAmount reads a count or amount, a JSON number or a string of digits
+func wholeAmount(v interface{}, ok bool) (*big.Int, string) {
+	if !ok || v == nil {
+		return nil, "missing"
+	}
+	var s string
+	switch v := v.(type) {
+	case json.Number:
+		s = v.String()
+	case string:
+		s = strings.TrimSpace(v)
+	default:
+		return nil, "not a number"
+	}
+	n, valid := new(big.Int).SetString(s, 10)
+	switch {
+	case !valid:
+		return nil, fmt.Sprintf("%q is not a whole number", s)
+	case n.Sign() < 0:
+		return nil, fmt.Sprintf("%s is negative", s)
+	}
+	return n, ""
+}
+
+// estimate adds up the tiers of a payload's overrides, returning nil when
+// the overrides define no tiers
+func (p *rewardPool) estimate(overrides *json.RawMessage) *rewardPoolEstimate {
+	if p == nil || overrides == nil {
+		return nil
+	}
+	dec := json.NewDecoder(bytes.NewReader(*overrides))
+	dec.UseNumber()
+	var doc interface{}
+	if err := dec.Decode(&doc); err != nil {
+		return nil
+	}
+	tiers, ok := lookupPath(doc, p.Tiers)
+	if !ok {
+		return nil
+	}
+
+	type tier struct {
+		name  string
+		value interface{}
+	}
+	var list []tier
+	switch t := tiers.(type) {
+	case []interface{}:
+		for i, v := range t {
+			list = append(list, tier{fmt.Sprintf("tier %d", i+1), v})
+		}
+	case map[string]interface{}:
+		names := make([]string, 0, len(t))
+		for name := range t {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			list = append(list, tier{"tier " + name, t[name]})
+		}
+	default:
+		return &rewardPoolEstimate{Total: new(big.Int), Problems: []string{fmt.Sprintf("%s is not a list of tiers", p.Tiers)}}
+	}
+
+	e := &rewardPoolEstimate{Total: new(big.Int), Tiers: len(list)}
+	for _, t := range list {
+		count := big.NewInt(1)
+		var problems []string
+		if p.Count != "" {
+			n, problem := wholeAmount(lookupPath(t.value, p.Count))
+			if problem != "" {
+				problems = append(problems, fmt.Sprintf("%s %s", p.Count, problem))
+			}
+			count = n
+		}
+		amount, problem := wholeAmount(lookupPath(t.value, p.Amount))
+		if problem != "" {
+			problems = append(problems, fmt.Sprintf("%s %s", p.Amount, problem))
+		}
+		if len(problems) > 0 {
+			e.Problems = append(e.Problems, fmt.Sprintf("%s: %s", t.name, strings.Join(problems, ", ")))
+			continue
+		}
+		e.Total.Add(e.Total, new(big.Int).Mul(count, amount))
+		e.Computed++
+	}
+	return e
+}
+
+// formatPoolAmount formats an amount with its unit
+func (p *rewardPool) formatPoolAmount(n *big.Int) string {
+	s := groupDigits(n.String(), displayLocale)
+	if p.Unit != "" {
+		s += " " + p.Unit
+	}
+	return s
+}
+
+// lines renders the estimate, ending with the problems of the tiers that
+// couldn't be computed
+func (p *rewardPool) lines(e *rewardPoolEstimate) []string {
+	var lines []string
+	switch {
+	case e.Tiers == 0 && len(e.Problems) == 0:
+		lines = append(lines, fmt.Sprintf("Total pool: none, %s has no tiers", p.Tiers))
+	case e.Computed == e.Tiers:
+		lines = append(lines, fmt.Sprintf("Total pool: %s across %d tier(s)", p.formatPoolAmount(e.Total), e.Tiers))
+	case e.Computed == 0:
+		lines = append(lines, "Total pool: couldn't be computed")
+	default:
+		lines = append(lines, fmt.Sprintf("Total pool: at least %s from %d of %d tiers, the others couldn't be computed", p.formatPoolAmount(e.Total), e.Computed, e.Tiers))
+	}
+	for _, problem := range e.Problems {
+		lines = append(lines, "  "+problem)
+	}
+	return lines
+}
+
+// overCeiling returns the environment's ceiling when the estimate goes over it
+func (p *rewardPool) overCeiling(env string, e *rewardPoolEstimate) *big.Int {
+	ceiling, ok := p.Ceilings[env]
+	if !ok || e.Total.Cmp(ceiling) <= 0 {
+		return nil
+	}
+	return ceiling
+}
+
+// ceilingWarning is the warning of an estimate over the environment's ceiling
+func (p *rewardPool) ceilingWarning(env string, e *rewardPoolEstimate) string {
+	ceiling := p.overCeiling(env, e)
+	if ceiling == nil {
+		return ""
+	}
+	return fmt.Sprintf("%s The pool goes over the %s ceiling of %s", glyphs.Warn, env, p.formatPoolAmount(ceiling))
+}
+
+// rewardPoolView renders the pool the pending payload's overrides imply
+func (m model) rewardPoolView() string {
+	pool := toolRegistry[sweepstakeTool].RewardPool
+	e := pool.estimate(m.pendingPayload.SweepstakeOverrides)
+	if e == nil {
+		return ""
+	}
+	var sb strings.Builder
+	for _, line := range pool.lines(e) {
+		sb.WriteString("  " + line + "\n")
+	}
+	if warning := pool.ceilingWarning(m.selectedEnv, e); warning != "" {
+		sb.WriteString("  " + errorStyle.Render(warning) + "\n")
+	}
+	return sb.String() + "\n"
+}
+
+// printRewardPool prints the pool a payload's overrides imply before the
+// command line confirmation
+func printRewardPool(out io.Writer, env string, payload EventPayload) {
+	pool := toolRegistry[sweepstakeTool].RewardPool
+	e := pool.estimate(payload.SweepstakeOverrides)
+	if e == nil {
+		return
+	}
+	for _, line := range pool.lines(e) {
+		fmt.Fprintln(out, line)
+	}
+	if warning := pool.ceilingWarning(env, e); warning != "" {
+		fmt.Fprintln(out, warning)
+	}
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/revrost/playtools/pkg/playtools"
+)
+
+// rollbackLookback is how far back the history is searched for completes
+const rollbackLookback = 30 * 24 * time.Hour
+
+// rollbackMaxCandidates limits the completes offered for rollback
+const rollbackMaxCandidates = 20
+
+// minJustificationLength keeps rollback justifications from being a single word
+const minJustificationLength = 10
+
+// rollbackCandidatesMsg carries the completes of env that can be rolled back
+type rollbackCandidatesMsg struct {
+	env   string
+	items []rollbackItem
+	err   error
+}
+
+// loadRollbackCandidatesCmd searches the history of the lookback for the
+// completes of env, and the rollbacks linking to them
+func loadRollbackCandidatesCmd(env string) tea.Cmd {
+	return func() tea.Msg {
+		now := time.Now()
+		records, err := filteredHistory(historyFilter{since: now.Add(-rollbackLookback)})
+		return rollbackCandidatesMsg{env: env, items: rollbackCandidates(records, env, now), err: err}
+	}
+}
+
+type rollbackItem struct {
+	rec        historyRecord
+	rolledBack bool
+}
+
+func (i rollbackItem) Title() string {
+	title := fmt.Sprintf("Quest %d", derefInt(i.rec.QuestID))
+	if i.rolledBack {
+		title += " (already rolled back)"
+	}
+	return title
+}
+
+func (i rollbackItem) Description() string {
+	operator := i.rec.Operator
+	if operator == "" {
+		operator = "unknown"
+	}
+	return fmt.Sprintf("completed %s by %s, request %s", formatTime(i.rec.Time, time.Now()), operator, i.rec.RequestID)
+}
+
+func (i rollbackItem) FilterValue() string {
+	return strconv.Itoa(derefInt(i.rec.QuestID)) + " " + i.rec.RequestID
+}
+
+// rollbackCandidates lists the recent successful completes in env that can
+// be rolled back, newest first, marking the ones a rollback already links to
+func rollbackCandidates(records []historyRecord, env string, now time.Time) []rollbackItem {
+	rolledBack := map[string]bool{}
+	for _, rec := range records {
+		if rec.RollbackOf != "" && !rec.failed() {
+			rolledBack[rec.RollbackOf] = true
+		}
+	}
+
+	var items []rollbackItem
+	for _, rec := range records {
+		if rec.Env != env || rec.Action != ActionComplete || rec.failed() || rec.RequestID == "" || rec.QuestID == nil {
+			continue
+		}
+		if rec.Time.Before(now.Add(-rollbackLookback)) {
+			continue
+		}
+		items = append(items, rollbackItem{rec: rec, rolledBack: rolledBack[rec.RequestID]})
+	}
+	sort.SliceStable(items, func(i, j int) bool {
+		return items[i].rec.Time.After(items[j].rec.Time)
+	})
+	if len(items) > rollbackMaxCandidates {
+		items = items[:rollbackMaxCandidates]
+	}
+	return items
+}
+
+// buildRollbackPayload builds the runbook's rollback payload for a complete
+// validateJustification checks a rollback justification says enough
+func validateJustification(justification string) error {
+	if len(justification) < minJustificationLength {
+		return fmt.Errorf("Please explain why in at least %d characters", minJustificationLength)
+	}
+	return nil
+}
+
+func buildRollbackPayload(rec historyRecord, justification string) EventPayload {
+	payload := playtools.NewPayload(ActionRollback, derefInt(rec.QuestID))
+	payload.OriginalRequestID = rec.RequestID
+	payload.Justification = justification
+	return payload
+}
+
+// rollbackAckPhrase has to be typed to confirm a rollback in prod
+func rollbackAckPhrase(payload EventPayload) string {
+	return "rollback " + payloadValue(payload)
+}
+
+// rollbackSummary reports how many distributions a rollback reversed
+func rollbackSummary(inv *invocation) []string {
+	if inv.Payload.Action != ActionRollback {
+		return nil
+	}
+	lines := []string{fmt.Sprintf("Rollback of request %s", inv.Payload.OriginalRequestID)}
+	if n, ok := reversedCount(inv.Body); ok {
+		lines = append(lines, fmt.Sprintf("  %s distributions reversed", formatNumber(int64(n), displayLocale)))
+	} else if inv.FunctionError == "" {
+		lines = append(lines, "  The response didn't report how many distributions were reversed")
+	}
+	return append(lines, "")
+}
+
+// reversedCount reads the reversed distributions from a rollback response,
+// either as a count or as the list of reversed distributions
+func reversedCount(body []byte) (int, bool) {
+	for _, key := range []string{"reversed_count", "reversed_distributions", "reversed"} {
+		if n, ok := responseCount(body, key); ok {
+			return n, true
+		}
+	}
+	return 0, false
+}
+
+// responseCount reads a count from a response field holding either a number
+// or a list. An array response counts the field across its results.
+func responseCount(body []byte, key string) (int, bool) {
+	elems, ok := responseElements(body)
+	if !ok {
+		return objectCount(body, key)
+	}
+	var total int
+	var found bool
+	for _, elem := range elems {
+		if n, ok := objectCount(elem, key); ok {
+			total += n
+			found = true
+		}
+	}
+	return total, found
+}
+
+// objectCount reads a count from a field of a JSON object
+func objectCount(body []byte, key string) (int, bool) {
+	var resp map[string]json.RawMessage
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return 0, false
+	}
+	var n int
+	if err := json.Unmarshal(resp[key], &n); err == nil {
+		return n, true
+	}
+	var items []json.RawMessage
+	if err := json.Unmarshal(resp[key], &items); err == nil && items != nil {
+		return len(items), true
+	}
+	return 0, false
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+// runRunCommand handles `playtools run <name> [flags]`, running a preset or
+// bookmark by name. A bookmark brings its environment, a preset runs in
+// --env. The run goes through the action command with the template's values
+// as flags, so its confirmations, prod rules and history are the same, and
+// any further flags are passed on to it.
+func runRunCommand(args []string, stdin io.Reader, stdout io.Writer) int {
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		fmt.Fprintf(os.Stderr, "Error: expected playtools run <name> [flags]\n\n%s", usageText)
+		return exitUsage
+	}
+	name, rest := args[0], args[1:]
+	presets, _, err := reloadPresets()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading config: %v\n", err)
+		return exitFailure
+	}
+	state, err := loadState()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitFailure
+	}
+	envFlag, envSet := argFlagValue(rest, "env")
+	if !envSet && pinnedEnv != "" {
+		envFlag, envSet = pinnedEnv, true
+	}
+
+	var template Preset
+	env := devEnv
+	var bookmarkEnvs []string
+	for _, b := range state.Bookmarks {
+		if b.Name == name {
+			bookmarkEnvs = append(bookmarkEnvs, b.Env)
+			if !envSet || b.Env == envFlag {
+				template, env = b.Preset, b.Env
+			}
+		}
+	}
+	switch {
+	case len(bookmarkEnvs) > 1 && !envSet:
+		fmt.Fprintf(os.Stderr, "Error: %q is bookmarked in %s, pick one with --env\n", name, strings.Join(bookmarkEnvs, ", "))
+		return exitUsage
+	case template.Name == "":
+		for _, p := range presets {
+			if p.Name == name {
+				template = p
+			}
+		}
+		if envSet {
+			env = envFlag
+		}
+	}
+	switch {
+	case template.Name == "" && len(bookmarkEnvs) > 0:
+		fmt.Fprintf(os.Stderr, "Error: %q is bookmarked in %s, not in %s\n", name, strings.Join(bookmarkEnvs, ", "), envFlag)
+		return exitUsage
+	case template.Name == "":
+		fmt.Fprintf(os.Stderr, "Error: unknown template %q\n%s", name, templateList(presets, state.Bookmarks))
+		return exitUsage
+	}
+
+	switch template.Action {
+	case ActionProcess, ActionComplete, ActionStart:
+	default:
+		fmt.Fprintf(os.Stderr, "Error: %q is a %s, which only runs in the TUI\n", name, template.Action)
+		return exitUsage
+	}
+	valueFlag := "--quest-id"
+	if template.Action == ActionStart {
+		valueFlag = "--duration"
+	}
+	// The template's values come first so that flags given to run win
+	forwarded := []string{"--env=" + env, fmt.Sprintf("%s=%d", valueFlag, template.Value)}
+	if template.DryRun && dryRunApplies(template.Action) {
+		forwarded = append(forwarded, "--dry-run")
+	}
+	fmt.Fprintf(stdout, "Running %s: %s in %s.\n", name, template.summary(), env)
+	return runActionCommand(template.Action, append(forwarded, rest...), stdin, stdout)
+}
+
+// argFlagValue finds a flag's value in unparsed arguments, written -name or
+// --name with the value after = or as the next argument
+func argFlagValue(args []string, name string) (string, bool) {
+	value, found := "", false
+	for i := 0; i < len(args); i++ {
+		arg := strings.TrimPrefix(strings.TrimPrefix(args[i], "-"), "-")
+		if arg == args[i] {
+			continue
+		}
+		if k, v, ok := strings.Cut(arg, "="); ok && k == name {
+			value, found = v, true
+		} else if arg == name && i+1 < len(args) {
+			value, found = args[i+1], true
+			i++
+		}
+	}
+	return value, found
+}
+
+// templateList lists the presets and bookmarks run takes
+func templateList(presets []Preset, bookmarks []Bookmark) string {
+	if len(presets) == 0 && len(bookmarks) == 0 {
+		return "There are no presets or bookmarks, save one with 'p' or 'm' on the confirmation screen.\n"
+	}
+	var sb strings.Builder
+	sb.WriteString("Available templates:\n")
+	for _, p := range presets {
+		sb.WriteString(fmt.Sprintf("  %-24s %s\n", p.Name, p.summary()))
+	}
+	sorted := append([]Bookmark(nil), bookmarks...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Name < sorted[j].Name })
+	for _, b := range sorted {
+		sb.WriteString(fmt.Sprintf("  %-24s %s in %s (bookmark)\n", b.Name, b.summary(), b.Env))
+	}
+	return sb.String()
+}
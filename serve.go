@@ -0,0 +1,235 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// serveAddr is where --serve exposes the session to dashboards, empty when
+// it isn't served
+var serveAddr string
+
+// sessionDashboard collects what the dashboard server exposes, nil unless
+// serving
+var sessionDashboard *dashboard
+
+// dashboard is the session state shared between the TUI and the server
+type dashboard struct {
+	mu      sync.Mutex
+	token   string
+	url     string
+	history []historyRecord
+	last    *dashboardResult
+	// serveErr is why the server stopped before the session ended
+	serveErr error
+}
+
+// dashboardResult is the latest invocation result as /api/last serves it
+type dashboardResult struct {
+	Env           string          `json:"env"`
+	Action        Action          `json:"action"`
+	Payload       EventPayload    `json:"payload"`
+	RequestID     string          `json:"request_id,omitempty"`
+	StartedAt     time.Time       `json:"started_at"`
+	DurationMS    int64           `json:"duration_ms"`
+	Error         string          `json:"error,omitempty"`
+	FunctionError string          `json:"function_error,omitempty"`
+	Response      json.RawMessage `json:"response,omitempty"`
+	Output        []string        `json:"output"`
+}
+
+// record adds a history record of this session
+func (d *dashboard) record(rec historyRecord) {
+	if d == nil {
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.history = append(d.history, rec)
+}
+
+// publish makes a finished invocation the latest result
+func (d *dashboard) publish(msg lambdaResult) {
+	if d == nil || msg.inv == nil {
+		return
+	}
+	inv := msg.inv
+	res := &dashboardResult{
+		Env:           inv.Env,
+		Action:        inv.Payload.Action,
+		Payload:       inv.Payload,
+		RequestID:     inv.RequestID,
+		StartedAt:     inv.StartedAt,
+		DurationMS:    inv.Duration.Milliseconds(),
+		FunctionError: inv.FunctionError,
+		Output:        msg.output,
+	}
+	if msg.err != nil {
+		res.Error = msg.err.Error()
+	}
+	if json.Valid(inv.Body) {
+		res.Response = inv.Body
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.last = res
+}
+
+// startDashboard listens on addr, localhost when no host is given, and
+// serves the session in the background until the returned stop is called
+func startDashboard(addr string) (stop func(), err error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, fmt.Errorf("--serve: expected an address like :8099, got %q", addr)
+	}
+	if host == "" {
+		host = "127.0.0.1"
+	}
+	ln, err := net.Listen("tcp", net.JoinHostPort(host, port))
+	if err != nil {
+		return nil, fmt.Errorf("--serve: %v", err)
+	}
+
+	token := make([]byte, 16)
+	if _, err := rand.Read(token); err != nil {
+		ln.Close()
+		return nil, err
+	}
+	d := &dashboard{token: hex.EncodeToString(token)}
+	d.url = fmt.Sprintf("http://%s/?token=%s", ln.Addr(), d.token)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", d.authorized(d.servePage))
+	mux.HandleFunc("/api/history", d.authorized(d.serveHistory))
+	mux.HandleFunc("/api/last", d.authorized(d.serveLast))
+	srv := &http.Server{Handler: mux, ReadHeaderTimeout: 5 * time.Second}
+	go func() {
+		if err := srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			// The TUI owns the terminal, so the status bar reports it instead
+			debugf("dashboard server stopped: %v", err)
+			d.mu.Lock()
+			d.serveErr = err
+			d.mu.Unlock()
+		}
+	}()
+
+	sessionDashboard = d
+	return func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+		_ = srv.Shutdown(ctx)
+	}, nil
+}
+
+// stopped is why the server stopped serving, nil while it serves
+func (d *dashboard) stopped() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.serveErr
+}
+
+// authorized rejects requests without the session token
+func (d *dashboard) authorized(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if subtle.ConstantTimeCompare([]byte(r.URL.Query().Get("token")), []byte(d.token)) != 1 {
+			http.Error(w, "missing or wrong token", http.StatusUnauthorized)
+			return
+		}
+		if r.Method != http.MethodGet {
+			http.Error(w, "read only", http.StatusMethodNotAllowed)
+			return
+		}
+		h(w, r)
+	}
+}
+
+func (d *dashboard) serveHistory(w http.ResponseWriter, r *http.Request) {
+	d.mu.Lock()
+	history := append([]historyRecord{}, d.history...)
+	d.mu.Unlock()
+	writeJSON(w, history)
+}
+
+func (d *dashboard) serveLast(w http.ResponseWriter, r *http.Request) {
+	d.mu.Lock()
+	last := d.last
+	d.mu.Unlock()
+	if last == nil {
+		http.Error(w, "nothing invoked yet", http.StatusNotFound)
+		return
+	}
+	writeJSON(w, last)
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	_ = enc.Encode(v)
+}
+
+var dashboardPage = template.Must(template.New("dashboard").Parse(`<!doctype html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta http-equiv="refresh" content="5">
+<title>playtools</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+pre { background: #f4f4f4; padding: 1em; overflow-x: auto; }
+table { border-collapse: collapse; }
+td, th { padding: 0.2em 1em; text-align: left; border-bottom: 1px solid #ddd; }
+.error { color: #b00; }
+</style>
+</head>
+<body>
+<h1>playtools session</h1>
+<h2>Latest result</h2>
+{{with .Last}}
+<p>{{.Action}} in {{.Env}}, started {{.StartedAt.Format "15:04:05"}}, took {{.DurationMS}} ms{{with .RequestID}}, request {{.}}{{end}}</p>
+{{with .Error}}<p class="error">Error: {{.}}</p>{{end}}
+{{with .FunctionError}}<p class="error">Function error: {{.}}</p>{{end}}
+<pre>{{range .Output}}{{.}}
+{{end}}</pre>
+{{else}}
+<p>Nothing invoked yet.</p>
+{{end}}
+<h2>History</h2>
+<table>
+<tr><th>Time</th><th>Env</th><th>Action</th><th>Quest</th><th>Dry run</th><th>Request</th><th>Error</th></tr>
+{{range .History}}
+<tr><td>{{.Time.Format "15:04:05"}}</td><td>{{.Env}}</td><td>{{.Action}}</td><td>{{with .QuestID}}{{.}}{{end}}</td><td>{{.DryRun}}</td><td>{{.RequestID}}</td><td class="error">{{.Error}}</td></tr>
+{{end}}
+</table>
+</body>
+</html>
+`))
+
+func (d *dashboard) servePage(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+	d.mu.Lock()
+	data := struct {
+		Last    *dashboardResult
+		History []historyRecord
+	}{d.last, make([]historyRecord, 0, len(d.history))}
+	// Newest first
+	for i := len(d.history) - 1; i >= 0; i-- {
+		data.History = append(data.History, d.history[i])
+	}
+	d.mu.Unlock()
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	_ = dashboardPage.Execute(w, data)
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// shadowRunTimeout bounds how long the confirmation waits for a shadow run.
+// A slower run is left to finish on its own, it's a dry run either way.
+const shadowRunTimeout = 2 * time.Minute
+
+// shadowRunMsg carries the outcome of a shadow run of the pending payload
+type shadowRunMsg struct {
+	env string
+	inv *invocation
+	err error
+}
+
+// shadowPayload is payload as a shadow run sends it, forced to a dry run,
+// or an error when the action has no dry run to force
+func shadowPayload(payload EventPayload) (EventPayload, error) {
+	if !dryRunApplies(payload.Action) {
+		return payload, fmt.Errorf("%s has no dry run, so it can't be shadow run", payload.Action)
+	}
+	payload.DryRun = true
+	return payload, nil
+}
+
+// shadowRunCmd invokes the pending prod payload as a dry run, synchronously
+// so its response can be shown under the confirmation
+func shadowRunCmd(env string, payload EventPayload) tea.Cmd {
+	return func() tea.Msg {
+		payload, err := shadowPayload(payload)
+		if err != nil {
+			return shadowRunMsg{env: env, err: err}
+		}
+		if mutates(payload) {
+			return shadowRunMsg{env: env, err: fmt.Errorf("refusing a shadow run that would mutate")}
+		}
+		inv := &invocation{Env: env, Payload: payload, Mode: modeSync, ModeReason: "shadow run", Shadow: true}
+		done := make(chan error, 1)
+		go func() {
+			err := invokeLambda(context.Background(), inv, nil, nil)
+			finishInvocation(inv, err)
+			done <- err
+		}()
+		select {
+		case err := <-done:
+			return shadowRunMsg{env: env, inv: inv, err: err}
+		case <-time.After(shadowRunTimeout):
+			return shadowRunMsg{env: env, err: fmt.Errorf("the shadow run didn't finish within %s", formatTimeout(shadowRunTimeout))}
+		}
+	}
+}
+
+// startShadowRun shadow runs the pending payload from the prod confirmation
+func (m *model) startShadowRun() tea.Cmd {
+	m.shadow = nil
+	if _, err := shadowPayload(m.pendingPayload); err != nil {
+		m.shadow = &shadowRunMsg{env: m.selectedEnv, err: err}
+		return nil
+	}
+	m.shadowRunning = true
+	return tea.Batch(m.spinner.Tick, shadowRunCmd(m.selectedEnv, m.pendingPayload))
+}
+
+// canShadowRun reports whether the confirmation offers a shadow run, which
+// is only for single prod invocations
+func (m model) canShadowRun() bool {
+	return isProtected(m.selectedEnv) && m.batchRows == nil && m.selectedAction != investigateAction
+}
+
+// shadowView renders the latest shadow run under the confirmation
+func (m model) shadowView() string {
+	if m.shadowRunning {
+		return fmt.Sprintf("  %s %s\n\n", m.spinner.View(), tr(msgShadowRunning, m.selectedEnv))
+	}
+	s := m.shadow
+	if s == nil {
+		return ""
+	}
+	if s.inv == nil {
+		return fmt.Sprintf("  %s %s\n\n", glyphs.Warn, tr(msgShadowErr, s.err))
+	}
+	lines := []string{tr(msgShadowTitle, s.inv.RequestID)}
+	if class := classifyError(s.err, s.inv); class.Title != "" {
+		lines = append(lines, fmt.Sprintf("%s %s: %s", glyphs.Cross, class.Title, class.Message))
+	} else {
+		lines = append(lines, dryRunSummary(s.inv)...)
+		if summary := responseSummary(s.inv.Body, time.Now()); summary != nil {
+			lines = append(lines, summary...)
+		} else {
+			lines = append(lines, "Response: "+strings.TrimSpace(string(s.inv.Body)))
+		}
+	}
+	var sb strings.Builder
+	for _, line := range lines {
+		if line != "" {
+			sb.WriteString("  " + wrapText(line, m.width-6) + "\n")
+		}
+	}
+	return sb.String() + "\n"
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/smithy-go"
+	"github.com/aws/smithy-go/middleware"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+)
+
+// maxClockSkew is how far off the local clock may be before AWS rejects
+// SigV4 signatures
+const maxClockSkew = 5 * time.Minute
+
+// clockSyncHint is how to fix a skewed clock
+const clockSyncHint = "Sync your clock with NTP: `sudo timedatectl set-ntp true` on Linux, `sudo sntp -sS time.apple.com` on macOS, or \"Sync now\" in the Windows date and time settings. On a VM, check its time sync with the host."
+
+var (
+	clockSkewMu  sync.Mutex
+	clockSkew    time.Duration
+	skewMeasured bool
+)
+
+// recordServerDate measures the clock skew from the Date header of an AWS
+// response received at the given local time. Positive means the local clock
+// is ahead.
+func recordServerDate(date string, received time.Time) {
+	serverTime, err := http.ParseTime(date)
+	if err != nil {
+		return
+	}
+	clockSkewMu.Lock()
+	defer clockSkewMu.Unlock()
+	// The header has a resolution of a second
+	clockSkew = received.Sub(serverTime).Truncate(time.Second)
+	skewMeasured = true
+}
+
+// measuredClockSkew is the skew measured from the latest AWS response
+func measuredClockSkew() (time.Duration, bool) {
+	clockSkewMu.Lock()
+	defer clockSkewMu.Unlock()
+	return clockSkew, skewMeasured
+}
+
+// withSkewRecording records the Date header of every response of the
+// clients built from the config, failed ones included
+func withSkewRecording() func(*config.LoadOptions) error {
+	return config.WithAPIOptions([]func(*middleware.Stack) error{
+		func(stack *middleware.Stack) error {
+			// Innermost, so the raw response is seen before any error is deserialized
+			return stack.Deserialize.Add(middleware.DeserializeMiddlewareFunc("RecordServerDate",
+				func(ctx context.Context, in middleware.DeserializeInput, next middleware.DeserializeHandler) (middleware.DeserializeOutput, middleware.Metadata, error) {
+					out, md, err := next.HandleDeserialize(ctx, in)
+					if resp, ok := out.RawResponse.(*smithyhttp.Response); ok {
+						recordServerDate(resp.Header.Get("Date"), time.Now())
+					}
+					return out, md, err
+				}), middleware.After)
+		},
+	})
+}
+
+// signatureRejected reports whether err is AWS refusing a request's
+// signature or timestamp, which a skewed clock causes
+func signatureRejected(err error) bool {
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) {
+		switch apiErr.ErrorCode() {
+		case "RequestTimeTooSkewed", "RequestExpired", "InvalidSignatureException", "SignatureDoesNotMatch":
+			return true
+		}
+	}
+	var statusErr *httpStatusError
+	return errors.As(err, &statusErr) && statusErr.StatusCode == http.StatusForbidden
+}
+
+// skewedClock returns the measured skew when it is large enough for AWS to
+// reject signatures
+func skewedClock() (time.Duration, bool) {
+	skew, ok := measuredClockSkew()
+	return skew, ok && skew.Abs() > maxClockSkew
+}
+
+// describeSkew says how far and which way the local clock is off, like
+// "7m2s behind"
+func describeSkew(skew time.Duration) string {
+	if skew < 0 {
+		return fmt.Sprintf("%s behind", (-skew).String())
+	}
+	return fmt.Sprintf("%s ahead of", skew.String())
+}
+
+// clockCheck is the doctor check of the skew measured by the calls made so
+// far, or nothing when none got a response
+func clockCheck() []doctorCheck {
+	skew, ok := measuredClockSkew()
+	if !ok {
+		return nil
+	}
+	check := doctorCheck{Name: "local clock in sync with AWS", Hint: clockSyncHint}
+	if skew.Abs() > maxClockSkew {
+		check.Err = fmt.Errorf("your clock is %s AWS, more than the %s SigV4 allows", describeSkew(skew), formatTimeout(maxClockSkew))
+	}
+	return []doctorCheck{check}
+}
@@ -0,0 +1,245 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// Checkpoint phases for an invocation that is in flight
+const (
+	phasePending   = "pending"   // payload built, SSO check not done yet
+	phaseInvoking  = "invoking"  // Invoke call sent to AWS
+	phaseCompleted = "completed" // Invoke returned, result not yet shown
+)
+
+// appState is persisted between runs in the state file
+type appState struct {
+	// Checkpoints are the invocations in flight, by environment and quest,
+	// so playtools processes sharing the state file keep theirs apart
+	Checkpoints map[string]invocationCheckpoint `json:"checkpoints,omitempty"`
+	// Checkpoint is the single checkpoint older versions kept, moved into
+	// Checkpoints when the state is loaded
+	Checkpoint *invocationCheckpoint `json:"checkpoint,omitempty"`
+	EnvInfo    map[string]envInfo    `json:"env_info,omitempty"`
+	// ThemeNote is the last terminal note shown, so it's only shown once
+	ThemeNote string     `json:"theme_note,omitempty"`
+	Bookmarks []Bookmark `json:"bookmarks,omitempty"`
+	// Quests caches status responses by environment and quest ID
+	Quests map[string]map[string]cachedQuest `json:"quests,omitempty"`
+	// Discovered caches the discovered tools by environment
+	Discovered map[string]discovery `json:"discovered,omitempty"`
+	// Drafts are the prompts left with unsubmitted input
+	Drafts []draft `json:"drafts,omitempty"`
+}
+
+// invocationCheckpoint records an invocation so its outcome can be looked up
+// if the TUI dies before the result is shown
+type invocationCheckpoint struct {
+	Env          string       `json:"env"`
+	FunctionName string       `json:"function_name"`
+	Payload      EventPayload `json:"payload"`
+	Phase        string       `json:"phase"`
+	RequestID    string       `json:"request_id,omitempty"`
+	StartedAt    time.Time    `json:"started_at"`
+	UpdatedAt    time.Time    `json:"updated_at"`
+	// Owner is the process that wrote the checkpoint, empty for one of an
+	// older version
+	Owner string `json:"owner,omitempty"`
+}
+
+// checkpointOwner marks the checkpoints this process writes, telling them
+// from those of other playtools processes
+var checkpointOwner = fmt.Sprintf("%d-%d", os.Getpid(), time.Now().UnixNano())
+
+// key files a checkpoint under its environment and quest, or the action and
+// the request it rolls back when there is no quest
+func (cp invocationCheckpoint) key() string {
+	target := string(cp.Payload.Action)
+	if id := cp.Payload.SweepstakeQuestID; id != nil {
+		target = strconv.Itoa(*id)
+	} else if cp.Payload.OriginalRequestID != "" {
+		target += " " + cp.Payload.OriginalRequestID
+	}
+	return cp.Env + "/" + target
+}
+
+// resumableCheckpoint is the most recently updated checkpoint of an
+// environment the session may use, nil when there is none
+func (s appState) resumableCheckpoint() *invocationCheckpoint {
+	var latest *invocationCheckpoint
+	for _, cp := range s.Checkpoints {
+		if pinnedEnv != "" && cp.Env != pinnedEnv {
+			continue
+		}
+		if latest == nil || cp.UpdatedAt.After(latest.UpdatedAt) {
+			latest = &cp
+		}
+	}
+	return latest
+}
+
+// configDir returns the directory holding playtools config and state
+func configDir() (string, error) {
+	if dir := os.Getenv("XDG_CONFIG_HOME"); dir != "" {
+		return filepath.Join(dir, "playtools"), nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".config", "playtools"), nil
+}
+
+func stateFilePath() (string, error) {
+	dir, err := dataDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "state.json"), nil
+}
+
+func loadState() (appState, error) {
+	var state appState
+	path, err := stateFilePath()
+	if err != nil {
+		return state, err
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return state, nil
+	}
+	if err != nil {
+		return state, err
+	}
+	if err := json.Unmarshal(data, &state); err != nil {
+		return state, fmt.Errorf("failed to parse state file %s: %v", path, err)
+	}
+	if cp := state.Checkpoint; cp != nil {
+		if state.Checkpoints == nil {
+			state.Checkpoints = map[string]invocationCheckpoint{}
+		}
+		state.Checkpoints[cp.key()] = *cp
+		state.Checkpoint = nil
+	}
+	return state, nil
+}
+
+// updateState applies fn to the current state and atomically writes it back.
+// Updates are serialized within the process and locked across processes.
+func updateState(fn func(*appState)) error {
+	path, err := stateFilePath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	return serializeWrite(path, func() error {
+		return withFileLock(path+".lock", func() error {
+			state, err := loadState()
+			if err != nil {
+				return err
+			}
+			fn(&state)
+			data, err := json.MarshalIndent(state, "", "  ")
+			if err != nil {
+				return err
+			}
+			return writeFileAtomic(path, data, 0o600)
+		})
+	})
+}
+
+// saveCheckpoint writes the checkpoint to the state file as this process's,
+// keeping the original start time when updating a checkpoint of its own
+func saveCheckpoint(cp invocationCheckpoint) {
+	if cp.Owner == "" {
+		cp.Owner = checkpointOwner
+	}
+	_ = updateState(func(state *appState) {
+		now := time.Now()
+		if cp.StartedAt.IsZero() {
+			cp.StartedAt = now
+			if prev, ok := state.Checkpoints[cp.key()]; ok && prev.Owner == cp.Owner {
+				cp.StartedAt = prev.StartedAt
+			}
+		}
+		cp.UpdatedAt = now
+		if state.Checkpoints == nil {
+			state.Checkpoints = map[string]invocationCheckpoint{}
+		}
+		state.Checkpoints[cp.key()] = cp
+	})
+}
+
+// clearCheckpoint removes a resolved or declined checkpoint, unless another
+// process has since written its own under the same environment and quest
+func clearCheckpoint(cp invocationCheckpoint) {
+	_ = updateState(func(state *appState) {
+		if prev, ok := state.Checkpoints[cp.key()]; ok && prev.Owner == cp.Owner {
+			delete(state.Checkpoints, cp.key())
+		}
+	})
+}
+
+// resumeCheckpointCmd reconstructs the outcome of an interrupted invocation
+// from its CloudWatch logs
+func resumeCheckpointCmd(gen int, cp invocationCheckpoint) tea.Cmd {
+	return func() tea.Msg {
+		output := []string{}
+		logs, err := resumeCheckpoint(cp, &output)
+		return lambdaResult{output: output, logs: logs, err: err, gen: gen}
+	}
+}
+
+func resumeCheckpoint(cp invocationCheckpoint, output *[]string) (string, error) {
+	*output = append(*output, fmt.Sprintf("Resumed invocation started %s (last phase: %s)", formatTime(cp.StartedAt, time.Now()), cp.Phase))
+	*output = append(*output, fmt.Sprintf("Environment: %s", cp.Env))
+	jsonPayload, _ := json.MarshalIndent(cp.Payload, "", "  ")
+	*output = append(*output, fmt.Sprintf("Payload: %s", jsonPayload))
+
+	if cp.Phase == phasePending {
+		*output = append(*output, "The invocation was interrupted before it was sent to AWS.")
+		return "", nil
+	}
+
+	profile := profileMap[cp.Env]
+	loggedIn, err := checkSSOSession(profile, nil)
+	if err != nil {
+		return "", err
+	}
+	if loggedIn {
+		*output = append(*output, ssoLoginLines...)
+	}
+
+	logs, err := fetchCheckpointLogs(profile, cp)
+	if err != nil {
+		return "", err
+	}
+
+	switch {
+	case cp.RequestID != "" && strings.Contains(logs, "REPORT RequestId: "+cp.RequestID):
+		*output = append(*output, fmt.Sprintf("Invocation %s finished.", cp.RequestID))
+	case cp.RequestID != "":
+		*output = append(*output, fmt.Sprintf("No completion recorded yet for invocation %s, it may still be running.", cp.RequestID))
+	case strings.Contains(logs, "REPORT RequestId:"):
+		*output = append(*output, "Request ID unknown, showing invocations of the function around the checkpoint time.")
+	default:
+		*output = append(*output, "No invocation of the function was logged around the checkpoint time.")
+	}
+	return logs, nil
+}
+
+// fetchCheckpointLogs fetches the log events for the checkpointed request ID,
+// or every event in the window after the checkpoint when the ID is unknown
+func fetchCheckpointLogs(profile string, cp invocationCheckpoint) (string, error) {
+	return fetchRequestLogs(profile, cp.FunctionName, cp.RequestID, cp.StartedAt)
+}
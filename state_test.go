@@ -0,0 +1,108 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/revrost/playtools/pkg/playtools"
+)
+
+// asProcess runs fn with the checkpoints written as another process's
+func asProcess(owner string, fn func()) {
+	saved := checkpointOwner
+	checkpointOwner = owner
+	defer func() { checkpointOwner = saved }()
+	fn()
+}
+
+func loadCheckpoints(t *testing.T) map[string]invocationCheckpoint {
+	t.Helper()
+	state, err := loadState()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return state.Checkpoints
+}
+
+// A process that finishes cleanly leaves the checkpoint of an interrupted
+// one alone
+func TestCheckpointsPerProcess(t *testing.T) {
+	h := newHarness(t)
+	asProcess("interrupted", func() {
+		saveCheckpoint(invocationCheckpoint{Env: prodEnv, Payload: playtools.NewPayload(ActionComplete, 42), Phase: phaseInvoking})
+	})
+
+	gen := h.start(playtools.NewPayload(ActionProcess, 7))
+	if cps := loadCheckpoints(t); len(cps) != 2 || cps["dev/7"].Owner != checkpointOwner {
+		t.Fatalf("checkpoints %+v", cps)
+	}
+	h.send(result(gen, 7, "req-7"))
+	cps := loadCheckpoints(t)
+	if len(cps) != 1 || cps["prod/42"].Owner != "interrupted" {
+		t.Fatalf("after the result, checkpoints %+v", cps)
+	}
+
+	// Nor one another process wrote since for the same quest
+	gen = h.start(playtools.NewPayload(ActionProcess, 7))
+	asProcess("other", func() {
+		saveCheckpoint(invocationCheckpoint{Env: devEnv, Payload: playtools.NewPayload(ActionProcess, 7), Phase: phaseInvoking})
+	})
+	h.send(result(gen, 7, "req-8"))
+	if cps := loadCheckpoints(t); cps["dev/7"].Owner != "other" {
+		t.Errorf("cleared the other process's checkpoint: %+v", cps)
+	}
+
+	// The next session offers the interrupted one, and resolving it clears it
+	m := initialModel()
+	if m.currentScreen != ResumeScreen || m.checkpoint == nil {
+		t.Fatalf("on screen %d with %+v, want to resume", m.currentScreen, m.checkpoint)
+	}
+	clearCheckpoint(*m.checkpoint)
+	if cps := loadCheckpoints(t); len(cps) != 1 {
+		t.Errorf("left %+v", cps)
+	}
+}
+
+func TestCheckpointKeys(t *testing.T) {
+	rollback := EventPayload{Action: ActionRollback, OriginalRequestID: "req-42"}
+	for _, tt := range []struct {
+		cp   invocationCheckpoint
+		want string
+	}{
+		{invocationCheckpoint{Env: devEnv, Payload: playtools.NewPayload(ActionProcess, 42)}, "dev/42"},
+		{invocationCheckpoint{Env: prodEnv, Payload: playtools.NewPayload(ActionComplete, 42)}, "prod/42"},
+		{invocationCheckpoint{Env: prodEnv, Payload: playtools.NewPayload(ActionStart, 60)}, "prod/start"},
+		{invocationCheckpoint{Env: prodEnv, Payload: rollback}, "prod/rollback req-42"},
+	} {
+		if got := tt.cp.key(); got != tt.want {
+			t.Errorf("%+v: key %q, want %q", tt.cp.Payload, got, tt.want)
+		}
+	}
+}
+
+// The single checkpoint of an older version is kept
+func TestCheckpointMigrated(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", dir)
+	path := filepath.Join(dir, "playtools", "state.json")
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	old := `{"checkpoint":{"env":"prod","payload":{"action":"complete","sweepstake_quest_id":42},"phase":"invoking"}}`
+	if err := os.WriteFile(path, []byte(old), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	state, err := loadState()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cp := state.resumableCheckpoint()
+	if state.Checkpoint != nil || cp == nil || cp.key() != "prod/42" {
+		t.Fatalf("loaded %+v", state)
+	}
+	clearCheckpoint(*cp)
+	if cps := loadCheckpoints(t); len(cps) != 0 {
+		t.Errorf("left %+v", cps)
+	}
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// statsRow is the usage of one action in one environment over a window
+type statsRow struct {
+	Env      string
+	Action   Action
+	Count    int
+	Failures int
+	// AvgDuration only covers records that have a duration
+	AvgDuration time.Duration
+}
+
+// FailureRate is the fraction of failed invocations
+func (r statsRow) FailureRate() float64 {
+	if r.Count == 0 {
+		return 0
+	}
+	return float64(r.Failures) / float64(r.Count)
+}
+
+// statsWindow is the usage over the last Days days
+type statsWindow struct {
+	Days int
+	Rows []statsRow
+}
+
+// usageStats is the usage summary shown on the stats screen
+type usageStats struct {
+	Windows          []statsWindow
+	LastProdComplete *historyRecord
+}
+
+// statsMsg carries the rendered stats screen
+type statsMsg struct {
+	text string
+}
+
+// loadStatsCmd reads the history and renders its stats
+func loadStatsCmd() tea.Cmd {
+	return func() tea.Msg {
+		records, warnings, err := loadHistory()
+		var text string
+		if err != nil {
+			text = "  " + tr(msgHistoryReadErr, err) + "\n"
+		} else {
+			now := time.Now()
+			text = renderStats(computeStats(records, now), now)
+		}
+		for _, w := range warnings {
+			text += fmt.Sprintf("  %s %s\n", glyphs.Warn, w)
+		}
+		return statsMsg{text: text}
+	}
+}
+
+// computeStats summarizes history records over the last 7 and 30 days
+func computeStats(records []historyRecord, now time.Time) usageStats {
+	var stats usageStats
+	for _, days := range []int{7, 30} {
+		stats.Windows = append(stats.Windows, computeWindow(records, now, days))
+	}
+
+	for i := range records {
+		rec := records[i]
+		if rec.Env != prodEnv || rec.Action != ActionComplete || rec.DryRun || rec.failed() {
+			continue
+		}
+		if stats.LastProdComplete == nil || rec.Time.After(stats.LastProdComplete.Time) {
+			stats.LastProdComplete = &rec
+		}
+	}
+	return stats
+}
+
+func computeWindow(records []historyRecord, now time.Time, days int) statsWindow {
+	since := now.AddDate(0, 0, -days)
+
+	type key struct {
+		env    string
+		action Action
+	}
+	type acc struct {
+		statsRow
+		total time.Duration
+		timed int
+	}
+	accs := map[key]*acc{}
+	for _, rec := range records {
+		if rec.Time.Before(since) || rec.Time.After(now) || rec.Env == "" || rec.Action == "" {
+			continue
+		}
+		k := key{rec.Env, rec.Action}
+		a, ok := accs[k]
+		if !ok {
+			a = &acc{statsRow: statsRow{Env: rec.Env, Action: rec.Action}}
+			accs[k] = a
+		}
+		a.Count++
+		if rec.failed() {
+			a.Failures++
+		}
+		if rec.DurationMS > 0 {
+			a.total += time.Duration(rec.DurationMS) * time.Millisecond
+			a.timed++
+		}
+	}
+
+	window := statsWindow{Days: days}
+	for _, a := range accs {
+		if a.timed > 0 {
+			a.AvgDuration = a.total / time.Duration(a.timed)
+		}
+		window.Rows = append(window.Rows, a.statsRow)
+	}
+	sort.Slice(window.Rows, func(i, j int) bool {
+		if window.Rows[i].Env != window.Rows[j].Env {
+			return window.Rows[i].Env < window.Rows[j].Env
+		}
+		return window.Rows[i].Action < window.Rows[j].Action
+	})
+	return window
+}
+
+// renderStats renders the usage stats as tables with simple bars
+func renderStats(stats usageStats, now time.Time) string {
+	var sb strings.Builder
+	for _, w := range stats.Windows {
+		sb.WriteString(fmt.Sprintf("  Last %d days\n\n", w.Days))
+		if len(w.Rows) == 0 {
+			sb.WriteString("  No invocations\n\n")
+			continue
+		}
+
+		maxCount := 0
+		for _, r := range w.Rows {
+			maxCount = max(maxCount, r.Count)
+		}
+		sb.WriteString(fmt.Sprintf("  %-8s %-9s %7s %-20s %7s %9s\n", "ENV", "ACTION", "COUNT", "", "FAILED", "AVG TIME"))
+		for _, r := range w.Rows {
+			bar := strings.Repeat(glyphs.Bar, max(1, r.Count*20/maxCount))
+			avg := "-"
+			if r.AvgDuration > 0 {
+				avg = r.AvgDuration.Round(100 * time.Millisecond).String()
+			}
+			sb.WriteString(fmt.Sprintf("  %-8s %-9s %7s %-20s %6.0f%% %9s\n",
+				r.Env, r.Action, formatNumber(int64(r.Count), displayLocale), bar, r.FailureRate()*100, avg))
+		}
+		sb.WriteString("\n")
+	}
+
+	if rec := stats.LastProdComplete; rec != nil {
+		operator := rec.Operator
+		if operator == "" {
+			operator = "unknown"
+		}
+		sb.WriteString(fmt.Sprintf("  Last prod complete: %s by %s\n", formatTime(rec.Time, now), operator))
+	} else {
+		sb.WriteString("  No prod complete recorded\n")
+	}
+	return sb.String()
+}
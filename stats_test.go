@@ -0,0 +1,83 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// statsHistory is a history as written over several versions: the oldest
+// lines have no duration, operator or error fields
+const statsHistory = `
+{"time":"2024-04-05T09:00:00Z","env":"prod","action":"complete","quest_id":1}
+{"time":"2024-04-20T09:00:00Z","env":"prod","action":"complete","quest_id":2,"operator":"ana","duration_ms":4000}
+{"time":"2024-04-25T09:00:00Z","env":"dev","action":"process","quest_id":3,"duration_ms":1000}
+{"time":"2024-04-28T09:00:00Z","env":"dev","action":"process","quest_id":3,"duration_ms":3000,"error":"boom"}
+{"time":"2024-04-29T09:00:00Z","env":"dev","action":"process","quest_id":3}
+{"time":"2024-04-30T09:00:00Z","env":"prod","action":"complete","quest_id":4,"operator":"bo","dry_run":true}
+{"time":"2024-04-30T10:00:00Z","env":"prod","action":"complete","quest_id":5,"operator":"cy","error":"throttled"}
+{"time":"2024-04-30T11:00:00Z","action":"process"}
+`
+
+func parseHistory(t *testing.T, jsonl string) []historyRecord {
+	t.Helper()
+	var records []historyRecord
+	for _, line := range strings.Split(strings.TrimSpace(jsonl), "\n") {
+		var rec historyRecord
+		if err := json.Unmarshal([]byte(line), &rec); err != nil {
+			t.Fatal(err)
+		}
+		records = append(records, rec)
+	}
+	return records
+}
+
+func TestComputeStats(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	stats := computeStats(parseHistory(t, statsHistory), now)
+
+	want := []statsWindow{
+		{Days: 7, Rows: []statsRow{
+			{Env: devEnv, Action: ActionProcess, Count: 3, Failures: 1, AvgDuration: 2 * time.Second},
+			{Env: prodEnv, Action: ActionComplete, Count: 2, Failures: 1},
+		}},
+		{Days: 30, Rows: []statsRow{
+			{Env: devEnv, Action: ActionProcess, Count: 3, Failures: 1, AvgDuration: 2 * time.Second},
+			{Env: prodEnv, Action: ActionComplete, Count: 4, Failures: 1, AvgDuration: 4 * time.Second},
+		}},
+	}
+	if !reflect.DeepEqual(stats.Windows, want) {
+		t.Errorf("windows\n%+v\nwant\n%+v", stats.Windows, want)
+	}
+	if rate := stats.Windows[1].Rows[1].FailureRate(); rate != 0.25 {
+		t.Errorf("failure rate %v, want 0.25", rate)
+	}
+
+	// Neither the later dry run nor the later failure count
+	last := stats.LastProdComplete
+	if last == nil || *last.QuestID != 2 || last.Operator != "ana" {
+		t.Fatalf("last prod complete %+v, want quest 2", last)
+	}
+}
+
+func TestComputeStatsEmpty(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	stats := computeStats(nil, now)
+	if len(stats.Windows) != 2 || stats.Windows[0].Rows != nil || stats.LastProdComplete != nil {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+	if out := renderStats(stats, now); !strings.Contains(out, "No invocations") || !strings.Contains(out, "No prod complete recorded") {
+		t.Errorf("rendered\n%s", out)
+	}
+}
+
+// The oldest records have no operator to show
+func TestRenderStatsUnknownOperator(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	records := parseHistory(t, statsHistory)[:1]
+	if out := renderStats(computeStats(records, now), now); !strings.Contains(out, "by unknown") {
+		t.Errorf("rendered\n%s", out)
+	}
+}
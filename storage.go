@@ -0,0 +1,113 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// fileWriter runs every write to one file on a single goroutine so writers
+// from the TUI and background commands never interleave
+type fileWriter struct {
+	requests chan func()
+}
+
+var (
+	fileWritersMu sync.Mutex
+	fileWriters   = map[string]*fileWriter{}
+)
+
+func (w *fileWriter) run() {
+	for req := range w.requests {
+		req()
+	}
+}
+
+// serializeWrite runs fn on the writer goroutine for path and waits for it
+func serializeWrite(path string, fn func() error) error {
+	fileWritersMu.Lock()
+	w, ok := fileWriters[path]
+	if !ok {
+		w = &fileWriter{requests: make(chan func())}
+		fileWriters[path] = w
+		go w.run()
+	}
+	fileWritersMu.Unlock()
+
+	done := make(chan error, 1)
+	w.requests <- func() { done <- fn() }
+	return <-done
+}
+
+// withFileLock holds an advisory lock on lockPath while fn runs, guarding
+// against other playtools processes
+func withFileLock(lockPath string, fn func() error) error {
+	f, err := os.OpenFile(lockPath, os.O_CREATE|os.O_RDWR, 0o600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if err := lockFile(f); err != nil {
+		return err
+	}
+	defer unlockFile(f)
+	return fn()
+}
+
+// writeFileAtomic replaces path with data by writing a temp file in the same
+// directory and renaming it over the original
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(dir, filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), perm); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// appendLine appends a line to an append-only file under an advisory lock
+func appendLine(path string, line []byte) error {
+	return serializeWrite(path, func() error {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			return err
+		}
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_RDWR, 0o600)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		if err := lockFile(f); err != nil {
+			return err
+		}
+		defer unlockFile(f)
+
+		// Start on a fresh line if a previous write was cut short
+		if info, err := f.Stat(); err == nil && info.Size() > 0 {
+			last := make([]byte, 1)
+			if _, err := f.ReadAt(last, info.Size()-1); err == nil && last[0] != '\n' {
+				line = append([]byte{'\n'}, line...)
+			}
+		}
+		_, err = f.Write(append(line, '\n'))
+		return err
+	})
+}
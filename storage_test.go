@@ -0,0 +1,36 @@
+package main
+
+import (
+	"os"
+	"testing"
+)
+
+// A line cut short by a crash is skipped on load, and the next append
+// starts on a line of its own
+func TestHistoryTruncatedLine(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	if err := appendHistory(historyRecord{Env: devEnv, Action: ActionProcess, RequestID: "before"}); err != nil {
+		t.Fatal(err)
+	}
+	path, _ := historyFilePath()
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString(`{"env":"dev","action":"comp`)
+	f.Close()
+	if err := appendHistory(historyRecord{Env: devEnv, Action: ActionProcess, RequestID: "after"}); err != nil {
+		t.Fatal(err)
+	}
+
+	records, warnings, err := loadHistory()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 || records[0].RequestID != "before" || records[1].RequestID != "after" {
+		t.Errorf("loaded %+v", records)
+	}
+	if len(warnings) != 1 {
+		t.Errorf("warnings %v, want one for the truncated line", warnings)
+	}
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// envTitles are how the summary names the built-in environments, anything
+// else is upper cased
+var envTitles = map[string]string{devEnv: "DEV", nonProdEnv: "NONPROD", prodEnv: "PRODUCTION"}
+
+// invocationSummary says in plain words what invoking payload in env will
+// do, for reading under pressure rather than parsing JSON. It's shown on the
+// confirmation and sent with approval requests.
+func invocationSummary(env string, payload EventPayload) string {
+	title, ok := envTitles[env]
+	if !ok {
+		title = strings.ToUpper(env)
+	}
+	// The function ignores dry_run on the actions it doesn't apply to
+	dryRun := payload.DryRun && dryRunApplies(payload.Action)
+	verb := strings.ToUpper(string(payload.Action))
+	if dryRun {
+		verb = "DRY RUN " + string(payload.Action)
+	}
+
+	var target string
+	switch {
+	case payload.Action == ActionRollback:
+		verb = "ROLL BACK"
+		target = "request " + payload.OriginalRequestID
+	case payload.Action == ActionStart && payload.DurationMinutes != nil:
+		target = fmt.Sprintf("a %d minute sweepstake", *payload.DurationMinutes)
+	case payload.SweepstakeQuestID != nil:
+		target = fmt.Sprintf("sweepstake quest %d", *payload.SweepstakeQuestID)
+	default:
+		target = "a sweepstake"
+	}
+	sentences := []string{fmt.Sprintf("You are about to %s %s in %s using profile %s.", verb, target, title, profileMap[env])}
+
+	switch payload.Action {
+	case ActionProcess:
+		if dryRun {
+			sentences = append(sentences, "Nothing is changed, the quest is only processed as a trial.")
+		} else {
+			sentences = append(sentences, "The quest's entries WILL be processed.")
+		}
+	case ActionComplete:
+		sentences = append(sentences, "Rewards WILL be distributed.")
+		if payload.IdempotencyOverride {
+			sentences = append(sentences, "The idempotency key is overridden, so rewards already distributed WILL be distributed again.")
+		}
+	case ActionStart:
+		if dryRun {
+			sentences = append(sentences, "Only the duration and overrides are validated, no sweepstake is created.")
+		} else {
+			sentences = append(sentences, "A sweepstake WILL be created.")
+		}
+		if payload.SweepstakeOverrides != nil {
+			sentences = append(sentences, "Sweepstake overrides are set.")
+		}
+	case ActionRollback:
+		sentences = append(sentences, "Its distributions WILL be reversed.")
+		if payload.Justification != "" {
+			sentences = append(sentences, fmt.Sprintf("Justification: %s.", strings.TrimSuffix(payload.Justification, ".")))
+		}
+	case ActionStatus:
+		sentences = append(sentences, "Nothing is changed.")
+	}
+	if payload.BatchSize != nil {
+		sentences = append(sentences, fmt.Sprintf("Batch size %d.", *payload.BatchSize))
+	}
+	if approvalRequired(env, payload) {
+		sentences = append(sentences, "It needs an approval first.")
+	}
+	switch {
+	case dryRun, payload.Action == ActionStatus:
+	case payload.Action == ActionComplete:
+		sentences = append(sentences, "This can only be undone with a rollback.")
+	case payload.Action == ActionStart, payload.Action == ActionRollback:
+		sentences = append(sentences, "This cannot be undone.")
+	}
+	return strings.Join(sentences, " ")
+}
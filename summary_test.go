@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/revrost/playtools/pkg/playtools"
+)
+
+func TestInvocationSummary(t *testing.T) {
+	savedRequire, savedProfiles := approvalRequire, profileMap
+	t.Cleanup(func() { approvalRequire, profileMap = savedRequire, savedProfiles })
+	approvalRequire = map[string][]Action{prodEnv: {ActionComplete, ActionStart}}
+	profileMap = map[string]string{
+		devEnv:     "platform-dev-engineer",
+		nonProdEnv: "platform-nonprod-engineer",
+		prodEnv:    "platform-prod-engineer",
+		"qa.eu":    "qa-engineer",
+	}
+
+	with := func(payload EventPayload, edit func(p *EventPayload)) EventPayload {
+		edit(&payload)
+		return payload
+	}
+	batch := 500
+	overrides := json.RawMessage(`{"max_winners":3}`)
+
+	tests := []struct {
+		name    string
+		env     string
+		payload EventPayload
+		want    string
+	}{
+		{"complete", prodEnv, with(playtools.NewPayload(ActionComplete, 42), func(p *EventPayload) { p.BatchSize = &batch }),
+			"You are about to COMPLETE sweepstake quest 42 in PRODUCTION using profile platform-prod-engineer. " +
+				"Rewards WILL be distributed. Batch size 500. It needs an approval first. This can only be undone with a rollback."},
+		{"complete again", devEnv, with(playtools.NewPayload(ActionComplete, 42), func(p *EventPayload) {
+			p.IdempotencyKey, p.IdempotencyOverride = "retry-2", true
+		}),
+			"You are about to COMPLETE sweepstake quest 42 in DEV using profile platform-dev-engineer. " +
+				"Rewards WILL be distributed. The idempotency key is overridden, so rewards already distributed WILL be distributed again. " +
+				"This can only be undone with a rollback."},
+		// Complete has no dry run, the function distributes anyway
+		{"complete with dry_run", devEnv, with(playtools.NewPayload(ActionComplete, 42), func(p *EventPayload) { p.DryRun = true }),
+			"You are about to COMPLETE sweepstake quest 42 in DEV using profile platform-dev-engineer. " +
+				"Rewards WILL be distributed. This can only be undone with a rollback."},
+		{"process", nonProdEnv, playtools.NewPayload(ActionProcess, 7),
+			"You are about to PROCESS sweepstake quest 7 in NONPROD using profile platform-nonprod-engineer. " +
+				"The quest's entries WILL be processed."},
+		{"process dry run", prodEnv, with(playtools.NewPayload(ActionProcess, 7), func(p *EventPayload) { p.DryRun = true }),
+			"You are about to DRY RUN process sweepstake quest 7 in PRODUCTION using profile platform-prod-engineer. " +
+				"Nothing is changed, the quest is only processed as a trial."},
+		{"start", prodEnv, playtools.NewPayload(ActionStart, 60),
+			"You are about to START a 60 minute sweepstake in PRODUCTION using profile platform-prod-engineer. " +
+				"A sweepstake WILL be created. It needs an approval first. This cannot be undone."},
+		{"start dry run with overrides", prodEnv, with(playtools.NewPayload(ActionStart, 60), func(p *EventPayload) {
+			p.DryRun, p.SweepstakeOverrides = true, &overrides
+		}),
+			"You are about to DRY RUN start a 60 minute sweepstake in PRODUCTION using profile platform-prod-engineer. " +
+				"Only the duration and overrides are validated, no sweepstake is created. Sweepstake overrides are set."},
+		{"start without a duration", devEnv, EventPayload{Action: ActionStart},
+			"You are about to START a sweepstake in DEV using profile platform-dev-engineer. " +
+				"A sweepstake WILL be created. This cannot be undone."},
+		{"status", "qa.eu", playtools.NewPayload(ActionStatus, 42),
+			"You are about to STATUS sweepstake quest 42 in QA.EU using profile qa-engineer. Nothing is changed."},
+		{"rollback", prodEnv, EventPayload{Action: ActionRollback, OriginalRequestID: "req-42", Justification: "Paid the wrong tier."},
+			"You are about to ROLL BACK request req-42 in PRODUCTION using profile platform-prod-engineer. " +
+				"Its distributions WILL be reversed. Justification: Paid the wrong tier. This cannot be undone."},
+		{"rollback without a justification", devEnv, EventPayload{Action: ActionRollback, OriginalRequestID: "req-42"},
+			"You are about to ROLL BACK request req-42 in DEV using profile platform-dev-engineer. " +
+				"Its distributions WILL be reversed. This cannot be undone."},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := invocationSummary(tt.env, tt.payload); got != tt.want {
+				t.Errorf("summary\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+// Approval requests carry the summary the confirmation shows
+func TestApprovalRequestSummary(t *testing.T) {
+	var sent approvalRequest
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&sent); err != nil {
+			t.Error(err)
+		}
+		w.Write([]byte(`{"id":"apr-1","status":"pending"}`))
+	}))
+	defer srv.Close()
+	t.Cleanup(func() { applyApprovalConfig(ApprovalConfig{}) })
+	if err := applyApprovalConfig(ApprovalConfig{URL: srv.URL, Require: map[string][]Action{prodEnv: {ActionComplete}}}); err != nil {
+		t.Fatal(err)
+	}
+
+	payload := playtools.NewPayload(ActionComplete, 42)
+	if _, err := submitApproval(context.Background(), prodEnv, payload); err != nil {
+		t.Fatal(err)
+	}
+	if want := invocationSummary(prodEnv, payload); sent.Summary != want {
+		t.Errorf("the request's summary is %q, want %q", sent.Summary, want)
+	}
+}
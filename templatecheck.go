@@ -0,0 +1,162 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/lambda"
+	"github.com/aws/aws-sdk-go-v2/service/lambda/types"
+	"github.com/revrost/playtools/pkg/playtools"
+)
+
+// functionNameRe is what Lambda accepts as a function name
+var functionNameRe = regexp.MustCompile(`^[a-zA-Z0-9_-]{1,64}$`)
+
+// functionNameProblem explains why a rendered function name won't be
+// accepted by Lambda, empty when it will
+func functionNameProblem(name string) string {
+	switch {
+	case strings.Contains(name, "%!"):
+		return "the name template doesn't take exactly one environment"
+	case len(name) > 64:
+		return fmt.Sprintf("%d characters, Lambda allows 64", len(name))
+	case !functionNameRe.MatchString(name):
+		return "only letters, digits, hyphens and underscores are allowed"
+	}
+	return ""
+}
+
+// checkedFunctions holds the functions found to exist this session, by
+// environment and name
+var checkedFunctions sync.Map
+
+// checkFunctionExists looks the function up before its first invocation of
+// the session, so a mistyped name or template is reported as such rather
+// than as a failed invocation. Only a missing function fails, a caller
+// allowed to invoke but not to get the function isn't held up.
+func checkFunctionExists(ctx context.Context, client *lambda.Client, env, name string) error {
+	key := env + "/" + name
+	if _, ok := checkedFunctions.Load(key); ok {
+		return nil
+	}
+	_, err := client.GetFunction(ctx, &lambda.GetFunctionInput{FunctionName: aws.String(name)})
+	var notFound *types.ResourceNotFoundException
+	if errors.As(err, &notFound) {
+		return fmt.Errorf("function %s doesn't exist in %s (profile %s), check function_template or environments.%s.function in the config", name, env, profileMap[env], env)
+	}
+	if err == nil {
+		checkedFunctions.Store(key, true)
+	}
+	return nil
+}
+
+// validateTemplates renders every templated string of the active context
+// for each environment, so a broken one is reported at startup rather than
+// when invoking. Every problem is reported, with the environment and what
+// the template rendered.
+func validateTemplates() error {
+	envs := make([]string, 0, len(profileMap))
+	for env := range profileMap {
+		envs = append(envs, env)
+	}
+	sort.Strings(envs)
+	tool := toolRegistry[sweepstakeTool]
+	sample := playtools.NewPayload(ActionProcess, 1)
+
+	var problems configProblems
+	// failures maps a template failure to the environments it happened in,
+	// usually every one of them, and failed keeps them in order
+	type failure struct{ field, msg string }
+	var failed []failure
+	failures := map[failure][]string{}
+	fail := func(field string, env string, err error) {
+		key := failure{field, err.Error()}
+		if failures[key] == nil {
+			failed = append(failed, key)
+		}
+		failures[key] = append(failures[key], env)
+	}
+	for _, env := range envs {
+		name := sweepstakeFunction(env)
+		if problem := functionNameProblem(name); problem != "" {
+			problems = append(problems, configErrorf([]string{"environments", env}, "function name %q: %s", name, problem))
+		}
+		for _, configured := range configuredTools() {
+			name := toolRegistry[configured].function(env)
+			if problem := functionNameProblem(name); problem != "" {
+				problems = append(problems, configErrorf([]string{"tools", configured, "function_template"}, "function name %q in %s: %s", name, env, problem))
+			}
+		}
+		if tool.PayloadTransform != nil {
+			if _, err := tool.PayloadTransform.apply(env, sample); err != nil {
+				fail("payload_transform", env, err)
+			}
+		}
+		if tool.VerifyMetric != nil {
+			if _, err := tool.VerifyMetric.dimensions(&invocation{Env: env, Payload: sample}); err != nil {
+				fail("verify_metric", env, err)
+			}
+		}
+	}
+	for _, key := range failed {
+		problems = append(problems, configErrorf([]string{"tools", sweepstakeTool, key.field}, "in %s: %s", strings.Join(failures[key], ", "), key.msg))
+	}
+	if tool.SnapshotOverrides != nil {
+		var buf bytes.Buffer
+		if err := tool.SnapshotOverrides.Execute(&buf, snapshotData{QuestID: 1, SnapshotID: "snap-1"}); err != nil {
+			problems = append(problems, configErrorf([]string{"tools", sweepstakeTool, "snapshot_overrides"}, "%v", err))
+		} else if !json.Valid(buf.Bytes()) {
+			problems = append(problems, configErrorf([]string{"tools", sweepstakeTool, "snapshot_overrides"}, "produced invalid JSON: %s", buf.String()))
+		}
+	}
+	if len(problems) > 0 {
+		return problems
+	}
+	return nil
+}
+
+// runConfigValidateCommand handles `playtools config validate`, applying the
+// top level and every named context as the TUI would
+func runConfigValidateCommand(args []string, stdout io.Writer) int {
+	if len(args) > 0 {
+		fmt.Fprintf(os.Stderr, "Error: config validate takes no arguments\n")
+		return exitUsage
+	}
+	cfg, err := loadConfig()
+	contexts := []string{""}
+	for name := range cfg.Contexts {
+		contexts = append(contexts, name)
+	}
+	sort.Strings(contexts)
+	failed := false
+	// A problem outside the contexts comes up in every one of them
+	reported := map[string]bool{}
+	for _, name := range contexts {
+		activeContext = name
+		for _, p := range configFileProblems(cfg, err) {
+			if !reported[p.Error()] {
+				reported[p.Error()] = true
+				fmt.Fprintf(os.Stderr, "Error: %v\n", p)
+			}
+			failed = true
+		}
+	}
+	if failed {
+		return exitFailure
+	}
+	for _, w := range themeWarnings {
+		fmt.Fprintf(stdout, "Warning: %s\n", w)
+	}
+	fmt.Fprintf(stdout, "Config is valid (%d context(s))\n", len(contexts))
+	return 0
+}
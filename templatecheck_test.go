@@ -0,0 +1,168 @@
+package main
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFunctionNameProblem(t *testing.T) {
+	tests := []struct {
+		name    string
+		problem string
+	}{
+		{"imx-rewards-prod-sweepstake", ""},
+		{"calc_v2-dev", ""},
+		{strings.Repeat("a", 64), ""},
+		{strings.Repeat("a", 65), "65 characters, Lambda allows 64"},
+		{"imx-rewards-dev-sweepstake-%!s(MISSING)", "the name template doesn't take exactly one environment"},
+		{"imx-rewards-%!(EXTRA string=dev)", "the name template doesn't take exactly one environment"},
+		{"", "only letters, digits, hyphens and underscores are allowed"},
+		{"sweepstake.dev", "only letters, digits, hyphens and underscores are allowed"},
+		{"sweepstake dev", "only letters, digits, hyphens and underscores are allowed"},
+		{"sweepstake-dév", "only letters, digits, hyphens and underscores are allowed"},
+		{"sweepstake-dev%", "only letters, digits, hyphens and underscores are allowed"},
+	}
+	for _, tt := range tests {
+		if got := functionNameProblem(tt.name); got != tt.problem {
+			t.Errorf("functionNameProblem(%q) = %q, want %q", tt.name, got, tt.problem)
+		}
+	}
+}
+
+func TestCheckFunctionTemplate(t *testing.T) {
+	for tmpl, ok := range map[string]bool{
+		"imx-rewards-%s-sweepstake":    true,
+		"%s":                           true,
+		"rewards-%%-%s":                true,
+		"imx-rewards-%s-sweepstake-%s": false,
+		"imx-rewards-sweepstake":       false,
+		"imx-rewards-%d":               false,
+		"imx-rewards-%s-%d":            false,
+		"imx-rewards-%5s":              false,
+		"imx-rewards-%s-%":             false,
+	} {
+		if err := checkFunctionTemplate(tmpl); (err == nil) != ok {
+			t.Errorf("checkFunctionTemplate(%q) = %v", tmpl, err)
+		}
+	}
+}
+
+// withConfig applies cfg for the test, restoring the built-in defaults after
+func withConfig(t *testing.T, cfg Config) error {
+	t.Cleanup(func() {
+		activeContext = ""
+		if err := applyConfig(Config{}); err != nil {
+			t.Fatal(err)
+		}
+	})
+	return applyConfig(cfg)
+}
+
+// Every broken template is reported at once, with the environments and
+// the names it rendered
+func TestValidateTemplates(t *testing.T) {
+	t.Cleanup(func() {
+		if err := applyConfig(Config{}); err != nil {
+			t.Fatal(err)
+		}
+	})
+	cfg := Config{ContextConfig: ContextConfig{
+		FunctionTemplate: "imx-rewards-%s-sweepstake",
+		Environments: map[string]EnvironmentConfig{
+			"qa.eu": {Profile: "qa"},
+			"load-testing-for-the-yearly-sweepstake-campaign": {Profile: "load"},
+		},
+		Tools: map[string]ToolConfig{
+			"rewards": {FunctionTemplate: "imx-rewards-%s-sweepstake-%s", Actions: []ToolActionConfig{{Name: "grant"}}},
+			sweepstakeTool: {
+				PayloadTransform:  PayloadTransformConfig{Template: `{{if eq .Env "prod"}}{{.Missing}}{{else}}{{.JSON}}{{end}}`},
+				SnapshotOverrides: `{"quest": {{.QuestID}},}`,
+			},
+		},
+	}}
+	err := collectConfigProblems(cfg)
+	if err == nil {
+		t.Fatal("the broken templates were accepted")
+	}
+	problems := strings.Split(err.Error(), "\n")
+	for _, want := range []string{
+		`environments.qa.eu: function name "imx-rewards-qa.eu-sweepstake": only letters, digits, hyphens and underscores are allowed`,
+		`environments.load-testing-for-the-yearly-sweepstake-campaign: function name "imx-rewards-load-testing-for-the-yearly-sweepstake-campaign-sweepstake": 70 characters, Lambda allows 64`,
+		`tools.rewards.function_template: expected exactly one %s for the environment, got "imx-rewards-%s-sweepstake-%s"`,
+		`tools.sweepstake.payload_transform: in prod: `,
+		`tools.sweepstake.snapshot_overrides: produced invalid JSON: {"quest": 1,}`,
+	} {
+		found := false
+		for _, p := range problems {
+			found = found || strings.HasPrefix(p, want)
+		}
+		if !found {
+			t.Errorf("no problem %q in\n%s", want, err)
+		}
+	}
+	// The payload transform only fails in prod
+	for _, p := range problems {
+		if strings.HasPrefix(p, "tools.sweepstake.payload_transform: in ") && !strings.HasPrefix(p, "tools.sweepstake.payload_transform: in prod: ") {
+			t.Errorf("payload transform failed elsewhere: %s", p)
+		}
+	}
+}
+
+func TestValidateTemplatesValid(t *testing.T) {
+	cfg := Config{ContextConfig: ContextConfig{
+		FunctionTemplate: "imx-rewards-%s-sweepstake",
+		Environments:     map[string]EnvironmentConfig{"qa": {Profile: "qa", Function: "imx-rewards-qa-sweepstake-v2"}},
+		Tools:            map[string]ToolConfig{"rewards": {FunctionTemplate: "imx-rewards-%s-tools", Actions: []ToolActionConfig{{Name: "grant"}}}},
+	}}
+	if err := withConfig(t, cfg); err != nil {
+		t.Fatal(err)
+	}
+	if got := sweepstakeFunction("qa"); got != "imx-rewards-qa-sweepstake-v2" {
+		t.Errorf("qa invokes %s", got)
+	}
+}
+
+// config validate checks the templates of every context
+func TestConfigValidateTemplates(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", dir)
+	t.Cleanup(func() {
+		activeContext = ""
+		applyConfig(Config{})
+	})
+	path, err := configFilePath()
+	if err != nil {
+		t.Fatal(err)
+	}
+	write := func(yaml string) {
+		t.Helper()
+		if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(yaml), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	write("contexts:\n  rewards:\n    function_template: imx-rewards-%s-sweepstake\n")
+	if code := runConfigValidateCommand(nil, io.Discard); code != 0 {
+		t.Errorf("a valid config exited %d", code)
+	}
+
+	write("contexts:\n  rewards:\n    tools:\n      payouts:\n        function_template: imx-payouts-%s-%s\n        actions:\n          - name: pay\n")
+	if code := runConfigValidateCommand(nil, io.Discard); code != exitFailure {
+		t.Errorf("a broken template in a context exited %d", code)
+	}
+	cfg, err := loadConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	activeContext = "rewards"
+	problems := collectConfigProblems(cfg)
+	if len(problems) == 0 || !strings.Contains(problems.Error(), `contexts.rewards.tools.payouts.function_template: expected exactly one %s for the environment`) {
+		t.Errorf("problems %v", problems)
+	}
+}
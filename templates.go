@@ -0,0 +1,289 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// templatesAction is the action list entry that lists the payload templates
+const templatesAction = "templates"
+
+// templateNamePattern keeps a template name usable as its file name
+var templateNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// payloadTemplate is a whole payload saved under a name, overrides and batch
+// size included, unlike a preset which only holds the form values. Each is a
+// JSON file in the templates directory, so it can be edited by hand or passed
+// to --payload.
+type payloadTemplate struct {
+	Name    string
+	Payload EventPayload
+	SavedAt time.Time
+}
+
+func templatesDir() (string, error) {
+	dir, err := configDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "templates"), nil
+}
+
+// loadTemplates reads the templates sorted by name. A file that isn't a valid
+// payload is reported as a problem rather than failing the rest.
+func loadTemplates() ([]payloadTemplate, []string, error) {
+	dir, err := templatesDir()
+	if err != nil {
+		return nil, nil, err
+	}
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, nil, err
+	}
+	sort.Strings(paths)
+	var templates []payloadTemplate
+	var problems []string
+	for _, path := range paths {
+		name := strings.TrimSuffix(filepath.Base(path), ".json")
+		payload, err := readPayload(path, nil, true)
+		if err == nil && !templateAction(payload.Action) {
+			err = fmt.Errorf("a %s payload can't be a template", payload.Action)
+		}
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %v", name, err))
+			continue
+		}
+		t := payloadTemplate{Name: name, Payload: payload}
+		if info, err := os.Stat(path); err == nil {
+			t.SavedAt = info.ModTime()
+		}
+		templates = append(templates, t)
+	}
+	return templates, problems, nil
+}
+
+// templateAction reports whether an action's payloads can be templates, the
+// ones whose form is a single quest ID or duration
+func templateAction(action Action) bool {
+	switch action {
+	case ActionProcess, ActionComplete, ActionStart:
+		return true
+	}
+	return false
+}
+
+// saveTemplate writes payload as the template name, replacing any of that
+// name. The idempotency key belongs to one complete, so it isn't kept.
+func saveTemplate(name string, payload EventPayload) error {
+	if !templateNamePattern.MatchString(name) {
+		return errors.New("a template name is letters, digits, '.', '_' and '-'")
+	}
+	dir, err := templatesDir()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	payload.IdempotencyKey, payload.IdempotencyOverride = "", false
+	data, err := json.MarshalIndent(payload, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, name+".json"), append(data, '\n'), 0o644)
+}
+
+func deleteTemplate(name string) error {
+	dir, err := templatesDir()
+	if err != nil {
+		return err
+	}
+	return os.Remove(filepath.Join(dir, name+".json"))
+}
+
+// canSaveTemplate reports whether the confirmed payload can be saved as a
+// template
+func (m model) canSaveTemplate() bool {
+	return m.batchRows == nil && m.selectedAction != investigateAction && templateAction(m.pendingPayload.Action)
+}
+
+// summary describes what a template sets besides its action
+func (t payloadTemplate) summary() string {
+	parts := []string{fmt.Sprintf("%s %s", t.Payload.Action, payloadValue(t.Payload))}
+	if t.Payload.BatchSize != nil {
+		parts = append(parts, fmt.Sprintf("batch size %d", *t.Payload.BatchSize))
+	}
+	if t.Payload.SweepstakeOverrides != nil {
+		parts = append(parts, "overrides")
+	}
+	if len(t.Payload.Extra) > 0 {
+		parts = append(parts, "extra fields")
+	}
+	if t.Payload.DryRun && dryRunApplies(t.Payload.Action) {
+		parts = append(parts, "dry run")
+	}
+	return strings.Join(parts, ", ")
+}
+
+// templatesScreen lists the payload templates
+type templatesScreen struct {
+	list     []payloadTemplate
+	problems []string
+	cursor   int
+	// naming is set while typing the name to save the pending payload as
+	naming  bool
+	input   textinput.Model
+	back    Screen
+	message string
+	err     error
+}
+
+// openTemplates shows the saved templates. naming starts by asking for the
+// name to save the pending payload under.
+func (m model) openTemplates(naming bool) (tea.Model, tea.Cmd) {
+	templates, problems, err := loadTemplates()
+	input := textinput.New()
+	input.Prompt = "Name: "
+	input.CharLimit = 64
+	m.templates = templatesScreen{list: templates, problems: problems, back: m.currentScreen, err: err, input: input, naming: naming}
+	m.currentScreen = TemplatesScreen
+	if naming {
+		return m, m.templates.input.Focus()
+	}
+	return m, nil
+}
+
+// updateTemplates handles the keys of the templates screen
+func (m model) updateTemplates(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	ts := &m.templates
+	if ts.naming {
+		switch msg.Type {
+		case tea.KeyEsc:
+			m.currentScreen = ts.back
+			return m, nil
+		case tea.KeyEnter:
+		default:
+			var cmd tea.Cmd
+			ts.input, cmd = ts.input.Update(msg)
+			return m, cmd
+		}
+		name := strings.TrimSpace(ts.input.Value())
+		if err := saveTemplate(name, m.pendingPayload); err != nil {
+			ts.message = fmt.Sprintf("Failed to save: %v", err)
+			return m, nil
+		}
+		// Back to the confirmation the payload was saved from
+		m.currentScreen = ts.back
+		m.confirmNote = fmt.Sprintf("Saved template %q", name)
+		return m, nil
+	}
+
+	switch keys.name(msg) {
+	case "ctrl+c", "q":
+		return m, tea.Quit
+	case "esc", "b":
+		m.currentScreen = ActionScreen
+	case "up", "k":
+		ts.cursor = max(0, ts.cursor-1)
+	case "down", "j":
+		ts.cursor = max(0, min(ts.cursor+1, len(ts.list)-1))
+	case "enter":
+		if len(ts.list) == 0 {
+			return m, nil
+		}
+		m.loadTemplate(ts.list[ts.cursor])
+		return m, textinput.Blink
+	case "x", "delete":
+		if len(ts.list) == 0 {
+			return m, nil
+		}
+		t := ts.list[ts.cursor]
+		if ts.err = deleteTemplate(t.Name); ts.err == nil {
+			ts.list = append(ts.list[:ts.cursor:ts.cursor], ts.list[ts.cursor+1:]...)
+			ts.cursor = max(0, min(ts.cursor, len(ts.list)-1))
+			ts.message = fmt.Sprintf("Deleted template %q", t.Name)
+		}
+	}
+	return m, nil
+}
+
+// loadTemplate opens the prompt of a template's action filled in with its
+// quest ID or duration, and batch size where the prompt asks for one. The
+// rest of its payload is kept for when the prompt is submitted.
+func (m *model) loadTemplate(t payloadTemplate) {
+	m.preparePrompt(string(t.Payload.Action))
+	m.promptForm.SetValue(0, payloadValue(t.Payload))
+	if t.Payload.BatchSize != nil && len(m.promptForm.fields) > 1 {
+		m.promptForm.SetValue(1, strconv.Itoa(*t.Payload.BatchSize))
+	}
+	m.promptForm.MarkClean()
+	m.loadedTemplate = &t
+	m.promptMessage = tr(msgTemplateLoaded, t.Name)
+}
+
+// applyTemplate carries the loaded template's fields other than the prompt's
+// value over to a payload built from the prompt
+func (m model) applyTemplate(payload *EventPayload) {
+	t := m.loadedTemplate
+	if t == nil || t.Payload.Action != payload.Action {
+		return
+	}
+	payload.BatchSize = t.Payload.BatchSize
+	payload.SweepstakeOverrides = t.Payload.SweepstakeOverrides
+	payload.Extra = t.Payload.Extra
+	// A template can turn dry run on, but not off where it's the default
+	if dryRunApplies(payload.Action) {
+		payload.DryRun = payload.DryRun || t.Payload.DryRun
+	}
+}
+
+// templatesView renders the templates screen
+func (m model) templatesView() string {
+	ts := m.templates
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("\n\n  %s\n\n", tr(msgTemplatesTitle)))
+	if ts.naming {
+		sb.WriteString("  " + tr(msgTemplatesSave, m.pendingPayload.Action) + "\n\n")
+		sb.WriteString("  " + ts.input.View() + "  " + tr(msgNamingHelp) + "\n")
+		if ts.message != "" {
+			sb.WriteString("\n  " + errorStyle.Render(ts.message) + "\n")
+		}
+		return sb.String()
+	}
+	if ts.err != nil {
+		sb.WriteString(errorStyle.Render(fmt.Sprintf("  Error: %v", ts.err)) + "\n\n")
+	}
+	if len(ts.list) == 0 {
+		sb.WriteString("  " + tr(msgTemplatesEmpty) + "\n")
+	}
+	for i, t := range ts.list {
+		line := fmt.Sprintf("%-24s %s", t.Name, t.summary())
+		if !t.SavedAt.IsZero() {
+			line += "  " + dimStyle.Render(fmt.Sprintf("(saved %s ago)", formatAge(time.Since(t.SavedAt))))
+		}
+		if i == ts.cursor {
+			sb.WriteString(formFocusStyle.Render(glyphs.Pointer+" ") + line + "\n")
+		} else {
+			sb.WriteString("  " + line + "\n")
+		}
+	}
+	for _, p := range ts.problems {
+		sb.WriteString(fmt.Sprintf("  %s %s\n", glyphs.Warn, p))
+	}
+	if ts.message != "" {
+		sb.WriteString("\n  " + ts.message + "\n")
+	}
+	sb.WriteString("\n  " + dimStyle.Render(tr(msgTemplatesHelp, keys.help(keyConfirm), keys.help(keyBack), keys.help(keyQuit))) + "\n")
+	return sb.String()
+}
@@ -0,0 +1,269 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/list"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/muesli/termenv"
+)
+
+// palette holds the colors every style is built from
+type palette struct {
+	Dim, Accent, Error, Warn, OnWarn, OnError lipgloss.Color
+}
+
+// fullPalette uses the 256 color palette, basicPalette sticks to the 16 ANSI
+// colors terminals behind SSH hops still render faithfully
+var (
+	fullPalette  = palette{Dim: "241", Accent: "205", Error: "196", Warn: "214", OnWarn: "0", OnError: "15"}
+	basicPalette = palette{Dim: "8", Accent: "5", Error: "1", Warn: "3", OnWarn: "0", OnError: "15"}
+)
+
+// glyphSet holds the symbols drawn in the UI. Every glyph is a single column.
+type glyphSet struct {
+	Check, Cross, Warn, Bar, BarEmpty, Rule, Ellipsis, Dash, Pointer string
+}
+
+var (
+	unicodeGlyphs = glyphSet{Check: "✓", Cross: "✗", Warn: "⚠", Bar: "█", BarEmpty: "░", Rule: "│", Ellipsis: "…", Dash: "—", Pointer: "›"}
+	asciiGlyphs   = glyphSet{Check: "+", Cross: "x", Warn: "!", Bar: "#", BarEmpty: ".", Rule: "|", Ellipsis: ">", Dash: "-", Pointer: ">"}
+)
+
+// The active theme, set by applyTheme
+var (
+	colors palette
+	glyphs glyphSet
+
+	dimStyle            lipgloss.Style
+	errorStyle          lipgloss.Style
+	budgetStyle         lipgloss.Style
+	budgetWarnStyle     lipgloss.Style
+	budgetExceededStyle lipgloss.Style
+	pinnedStyle         lipgloss.Style
+	overlayStyle        lipgloss.Style
+	logGutterStyle      lipgloss.Style
+	logMatchStyle       lipgloss.Style
+
+	formFocusStyle       lipgloss.Style
+	formButtonStyle      lipgloss.Style
+	formButtonFocusStyle lipgloss.Style
+)
+
+// Styles are the layout and accent the model renders its screens with,
+// built from the theme section of the config
+type Styles struct {
+	// Doc frames every screen with the theme's margin
+	Doc lipgloss.Style
+	// Accent colors the spinner. ProdAccent replaces it, and the action list
+	// title, in the protected environments when the theme asks for it.
+	Accent     lipgloss.Color
+	ProdAccent lipgloss.Color
+}
+
+// accent is the accent color of env
+func (s Styles) accent(env string) lipgloss.Color {
+	if s.ProdAccent != "" && isProtected(env) {
+		return s.ProdAccent
+	}
+	return s.Accent
+}
+
+// themeStyles are the styles of the active theme, which the model is given
+var themeStyles Styles
+
+// themeNote explains a degraded theme picked for the terminal, or a theme
+// color that couldn't be used, shown once on the first screen
+var themeNote string
+
+// themeWarnings are the theme settings that fell back to their defaults
+var themeWarnings []string
+
+var colorPattern = regexp.MustCompile(`^(#[0-9a-fA-F]{6}|#[0-9a-fA-F]{3}|[0-9]{1,3})$`)
+
+func init() {
+	applyTheme(fullPalette, unicodeGlyphs, ThemeConfig{})
+}
+
+// applyTheme rebuilds every style from a palette, glyph set and the layout of
+// the theme config
+func applyTheme(p palette, g glyphSet, t ThemeConfig) {
+	colors, glyphs = p, g
+
+	margin := t.Margin
+	if len(margin) == 0 {
+		margin = []int{1, 2}
+	}
+	themeStyles = Styles{Doc: lipgloss.NewStyle().Margin(margin...), Accent: p.Accent}
+	if t.ProdAccent {
+		themeStyles.ProdAccent = p.Error
+	}
+	dimStyle = lipgloss.NewStyle().Foreground(p.Dim)
+	errorStyle = lipgloss.NewStyle().Foreground(p.Error).Bold(true)
+	budgetStyle = lipgloss.NewStyle().Foreground(p.Dim).Padding(0, 1)
+	budgetWarnStyle = lipgloss.NewStyle().Foreground(p.OnWarn).Background(p.Warn).Padding(0, 1)
+	budgetExceededStyle = lipgloss.NewStyle().Foreground(p.OnError).Background(p.Error).Padding(0, 1)
+	pinnedStyle = lipgloss.NewStyle().Foreground(p.OnWarn).Background(p.Warn).Padding(0, 1)
+	border := lipgloss.RoundedBorder()
+	if g == asciiGlyphs {
+		border = asciiBorder
+	}
+	overlayStyle = lipgloss.NewStyle().Border(border).BorderForeground(p.Accent).Padding(0, 1)
+	logGutterStyle = lipgloss.NewStyle().Foreground(p.Dim)
+	logMatchStyle = lipgloss.NewStyle().Reverse(true)
+	formFocusStyle = lipgloss.NewStyle().Foreground(p.Accent).Bold(true)
+	formButtonStyle = lipgloss.NewStyle().Foreground(p.Dim)
+	formButtonFocusStyle = lipgloss.NewStyle().Foreground(p.Accent).Bold(true).Reverse(true)
+}
+
+// asciiBorder draws boxes where box drawing characters don't line up
+var asciiBorder = lipgloss.Border{Top: "-", Bottom: "-", Left: "|", Right: "|", TopLeft: "+", TopRight: "+", BottomLeft: "+", BottomRight: "+"}
+
+// newListDelegate is the default list delegate, marking the selected item
+// with an ASCII bar when the theme draws ASCII
+func newListDelegate() list.DefaultDelegate {
+	d := list.NewDefaultDelegate()
+	if glyphs != unicodeGlyphs {
+		d.Styles.SelectedTitle = d.Styles.SelectedTitle.BorderStyle(asciiBorder)
+		d.Styles.SelectedDesc = d.Styles.SelectedDesc.BorderStyle(asciiBorder)
+	}
+	return d
+}
+
+// applyListGlyphs replaces the symbols of a list's pagination and help with
+// ASCII ones when the theme draws ASCII
+func applyListGlyphs(l *list.Model) {
+	if glyphs == unicodeGlyphs {
+		return
+	}
+	l.Styles.ActivePaginationDot = l.Styles.ActivePaginationDot.SetString("*")
+	l.Styles.InactivePaginationDot = l.Styles.InactivePaginationDot.SetString(".")
+	l.Styles.DividerDot = l.Styles.DividerDot.SetString(" - ")
+	l.Paginator.ActiveDot = l.Styles.ActivePaginationDot.String()
+	l.Paginator.InactiveDot = l.Styles.InactivePaginationDot.String()
+	l.Help.ShortSeparator = " - "
+	l.Help.Ellipsis = glyphs.Ellipsis
+	l.KeyMap.CursorUp.SetHelp("up/k", "up")
+	l.KeyMap.CursorDown.SetHelp("down/j", "down")
+	l.KeyMap.PrevPage.SetHelp("left/h/pgup", "prev page")
+	l.KeyMap.NextPage.SetHelp("right/l/pgdn", "next page")
+}
+
+// unicodeLocale reports whether the locale promises UTF-8 output
+func unicodeLocale() bool {
+	for _, name := range []string{"LC_ALL", "LC_CTYPE", "LANG"} {
+		if v := os.Getenv(name); v != "" {
+			v = strings.ToLower(v)
+			return strings.Contains(v, "utf-8") || strings.Contains(v, "utf8")
+		}
+	}
+	return false
+}
+
+// parseColorSetting maps the display.colors setting to a color profile
+func parseColorSetting(s string) (termenv.Profile, bool, error) {
+	switch s {
+	case "", "auto":
+		return 0, false, nil
+	case "truecolor":
+		return termenv.TrueColor, true, nil
+	case "256":
+		return termenv.ANSI256, true, nil
+	case "16":
+		return termenv.ANSI, true, nil
+	case "none":
+		return termenv.Ascii, true, nil
+	}
+	return 0, false, configErrorf([]string{"display", "colors"}, "expected auto, truecolor, 256, 16 or none, got %q", s)
+}
+
+// themeColor checks a color of the theme config, keeping the palette's when
+// it isn't set or isn't a color
+func themeColor(field, value string, fallback lipgloss.Color) lipgloss.Color {
+	if value == "" {
+		return fallback
+	}
+	if n, err := strconv.Atoi(value); !colorPattern.MatchString(value) || (err == nil && n > 255) {
+		themeWarnings = append(themeWarnings, fmt.Sprintf("theme.%s: %q isn't an ANSI color number or hex color, using the default", field, value))
+		return fallback
+	}
+	return lipgloss.Color(value)
+}
+
+// setupTheme picks the theme for the terminal's color profile and locale,
+// unless overridden by the display config, and explains any downgrade
+func setupTheme(cfg DisplayConfig, theme ThemeConfig) error {
+	profile, colorsSet, err := parseColorSetting(cfg.Colors)
+	if err != nil {
+		return err
+	}
+	if !colorsSet {
+		profile = termenv.NewOutput(os.Stdout).EnvColorProfile()
+	}
+
+	unicode := unicodeLocale()
+	unicodeSet := true
+	switch cfg.Unicode {
+	case "", "auto":
+		unicodeSet = false
+	case "on":
+		unicode = true
+	case "off":
+		unicode = false
+	default:
+		return configErrorf([]string{"display", "unicode"}, "expected auto, on or off, got %q", cfg.Unicode)
+	}
+
+	p, g := fullPalette, unicodeGlyphs
+	var detected []string
+	if profile > termenv.ANSI256 {
+		p = basicPalette
+		lipgloss.SetColorProfile(profile)
+		if !colorsSet {
+			detected = append(detected, "a terminal with 16 colors or fewer")
+		}
+	}
+	if !unicode {
+		g = asciiGlyphs
+		if !unicodeSet {
+			detected = append(detected, "a locale without UTF-8")
+		}
+	}
+	switch len(theme.Margin) {
+	case 0, 1, 2:
+	default:
+		return configErrorf([]string{"theme", "margin"}, "expected one or two values, got %d", len(theme.Margin))
+	}
+	for _, v := range theme.Margin {
+		if v < 0 || v > 10 {
+			return configErrorf([]string{"theme", "margin"}, "expected 0 to 10, got %d", v)
+		}
+	}
+	themeWarnings = nil
+	p.Accent = themeColor("accent", theme.Accent, p.Accent)
+	p.Error = themeColor("error", theme.Error, p.Error)
+	applyTheme(p, g, theme)
+
+	var notes []string
+	if len(detected) > 0 {
+		notes = append(notes, fmt.Sprintf("Detected %s, using a simplified style. Set display.colors and display.unicode in the config file to override.",
+			strings.Join(detected, " and ")))
+	}
+	themeNote = strings.Join(append(notes, themeWarnings...), " ")
+	return nil
+}
+
+// unseenThemeNote returns the theme note unless it was already shown for
+// the same terminal, recording it as shown
+func unseenThemeNote() string {
+	if state, err := loadState(); err == nil && state.ThemeNote == themeNote {
+		return ""
+	}
+	note := themeNote
+	_ = updateState(func(s *appState) { s.ThemeNote = note })
+	return note
+}
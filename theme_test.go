@@ -0,0 +1,132 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/muesli/termenv"
+)
+
+var ansiPattern = regexp.MustCompile(`\x1b\[[0-9;?]*[a-zA-Z]`)
+
+func stripANSI(s string) string {
+	return ansiPattern.ReplaceAllString(s, "")
+}
+
+// withColorProfile renders with profile as if the terminal reported it,
+// restoring the default theme afterwards
+func withColorProfile(t *testing.T, profile termenv.Profile) {
+	saved := lipgloss.ColorProfile()
+	lipgloss.SetColorProfile(profile)
+	t.Cleanup(func() {
+		lipgloss.SetColorProfile(saved)
+		applyTheme(fullPalette, unicodeGlyphs, ThemeConfig{})
+		themeNote, themeWarnings = "", nil
+	})
+}
+
+func TestThemeProfiles(t *testing.T) {
+	tests := []struct {
+		colors, unicode string
+		profile         termenv.Profile
+		// errorSeq is how the error color is written, empty for no color
+		errorSeq string
+		ascii    bool
+	}{
+		{"truecolor", "on", termenv.TrueColor, "38;5;196", false},
+		{"256", "on", termenv.ANSI256, "38;5;196", false},
+		{"16", "on", termenv.ANSI, "31", false},
+		{"16", "off", termenv.ANSI, "31", true},
+		{"none", "off", termenv.Ascii, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.colors+"/"+tt.unicode, func(t *testing.T) {
+			h := newHarness(t)
+			withColorProfile(t, tt.profile)
+			if err := setupTheme(DisplayConfig{Colors: tt.colors, Unicode: tt.unicode}, ThemeConfig{}); err != nil {
+				t.Fatal(err)
+			}
+			// The lists pick their symbols when they're built
+			h.m = initialModel()
+			h.m.selectedEnv = devEnv
+			h.m.currentScreen = ActionScreen
+			h.send(tea.WindowSizeMsg{Width: 100, Height: 40})
+			if themeNote != "" {
+				t.Errorf("a configured profile got the note %q", themeNote)
+			}
+
+			errText := errorStyle.Render("failed")
+			switch {
+			case tt.errorSeq == "" && strings.Contains(errText, "\x1b["):
+				t.Errorf("colored without colors: %q", errText)
+			case tt.errorSeq != "" && !strings.Contains(errText, "\x1b["+tt.errorSeq+"m") && !strings.Contains(errText, ";"+tt.errorSeq+"m"):
+				t.Errorf("error rendered as %q, want the %s color", errText, tt.errorSeq)
+			}
+			if tt.profile == termenv.ANSI && strings.Contains(errText, "38;5;") {
+				t.Errorf("a 16 color terminal got a 256 color: %q", errText)
+			}
+
+			// The border of an overlay lines up whatever it's drawn with
+			box := overlayStyle.Render("Quest 42\n" + glyphs.Check + " processed")
+			lines := strings.Split(box, "\n")
+			for _, line := range lines {
+				if w := lipgloss.Width(line); w != lipgloss.Width(lines[0]) {
+					t.Errorf("overlay line %q is %d wide, want %d\n%s", line, w, lipgloss.Width(lines[0]), box)
+				}
+			}
+			if tt.ascii {
+				if !strings.HasPrefix(stripANSI(lines[0]), "+-") {
+					t.Errorf("overlay drawn with %q", lines[0])
+				}
+				view := stripANSI(h.m.View())
+				for i, r := range view {
+					if r >= utf8.RuneSelf {
+						t.Fatalf("%q at %d of the ASCII view\n%s", r, i, view)
+					}
+				}
+			}
+		})
+	}
+}
+
+// A degraded terminal gets a note saying what was detected and how to
+// override it
+func TestThemeDetectionNote(t *testing.T) {
+	withColorProfile(t, termenv.Ascii)
+	t.Setenv("LC_ALL", "")
+	t.Setenv("LC_CTYPE", "")
+	t.Setenv("LANG", "C")
+	// Test output isn't a terminal, so it's detected as having no colors
+	if err := setupTheme(DisplayConfig{}, ThemeConfig{}); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"16 colors or fewer", "a locale without UTF-8", "display.colors", "display.unicode"} {
+		if !strings.Contains(themeNote, want) {
+			t.Errorf("note %q doesn't mention %q", themeNote, want)
+		}
+	}
+	if glyphs != asciiGlyphs || colors != basicPalette {
+		t.Errorf("degraded to %+v, %+v", glyphs, colors)
+	}
+
+	t.Setenv("LANG", "en_US.UTF-8")
+	if err := setupTheme(DisplayConfig{Colors: "256"}, ThemeConfig{}); err != nil {
+		t.Fatal(err)
+	}
+	if themeNote != "" || glyphs != unicodeGlyphs || colors != fullPalette {
+		t.Errorf("note %q with %+v, %+v", themeNote, glyphs, colors)
+	}
+}
+
+func TestThemeSettingsRejected(t *testing.T) {
+	withColorProfile(t, termenv.Ascii)
+	for _, cfg := range []DisplayConfig{{Colors: "88"}, {Unicode: "yes"}} {
+		if err := setupTheme(cfg, ThemeConfig{}); err == nil {
+			t.Errorf("%+v accepted", cfg)
+		}
+	}
+}
@@ -0,0 +1,327 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// timelineAction is the action list entry that shows everything done to a quest
+const timelineAction = "timeline"
+
+// timelineWindow is how far back the function's logs are searched for runs
+// of a quest
+const timelineWindow = 30 * 24 * time.Hour
+
+// Sources of timeline entries
+const (
+	sourceManual    = "manual"
+	sourceScheduled = "scheduled"
+)
+
+// timelineEntry is one operation on a quest, from this tool's history or
+// found in the function's logs
+type timelineEntry struct {
+	Time      time.Time
+	Source    string
+	Action    string
+	Outcome   string
+	Duration  time.Duration
+	RequestID string
+	// Detail is who ran it and how, for manual entries
+	Detail string
+}
+
+// timelineScreen is a quest's timeline being fetched or viewed
+type timelineScreen struct {
+	env     string
+	quest   int
+	entries []timelineEntry
+	loading bool
+	warning string
+	message string
+	view    logView
+}
+
+// timelineMsg carries a fetched timeline. warning is set when the logs
+// couldn't be searched and only the history is shown.
+type timelineMsg struct {
+	env     string
+	quest   int
+	entries []timelineEntry
+	warning string
+	err     error
+}
+
+func fetchTimelineCmd(env string, quest int) tea.Cmd {
+	return func() tea.Msg {
+		msg := timelineMsg{env: env, quest: quest}
+		records, err := filteredHistory(historyFilter{envs: []string{env}, quests: []int{quest}})
+		if err != nil {
+			msg.err = fmt.Errorf("failed to read history: %v", err)
+			return msg
+		}
+		runs, err := scheduledRuns(env, quest, time.Now())
+		if err != nil {
+			msg.warning = fmt.Sprintf("Scheduled runs are missing, the logs couldn't be searched: %v", err)
+		}
+		msg.entries = mergeTimeline(records, runs, env, quest)
+		return msg
+	}
+}
+
+// logRun is a request of the function that logged a quest ID
+type logRun struct {
+	RequestID string
+	First     time.Time
+	Last      time.Time
+	Errors    int
+}
+
+// scheduledRuns finds the requests that logged the quest ID with a Logs
+// Insights query, whoever invoked them
+func scheduledRuns(env string, quest int, now time.Time) ([]logRun, error) {
+	query := fmt.Sprintf(`fields @timestamp, @requestId, @message
+| filter @message like /quest_id\W+%d\b/
+| stats min(@timestamp) as first, max(@timestamp) as last, sum(strcontains(@message, "ERROR")) as errors by @requestId
+| sort first asc
+| limit 500`, quest)
+	rows, err := insightsQuery(profileMap[env], logGroupName(sweepstakeFunction(env)), query, now.Add(-timelineWindow), now)
+	if err != nil {
+		return nil, err
+	}
+	const insightsTime = "2006-01-02 15:04:05.000"
+	var runs []logRun
+	for _, row := range rows {
+		run := logRun{RequestID: row["@requestId"]}
+		run.First, _ = time.ParseInLocation(insightsTime, row["first"], time.UTC)
+		run.Last, _ = time.ParseInLocation(insightsTime, row["last"], time.UTC)
+		run.Errors, _ = strconv.Atoi(row["errors"])
+		if run.RequestID != "" {
+			runs = append(runs, run)
+		}
+	}
+	return runs, nil
+}
+
+// insightsQueryTimeout bounds the wait for a Logs Insights query
+const insightsQueryTimeout = time.Minute
+
+// insightsQuery runs a Logs Insights query over a log group and waits for
+// its results, one field map per row
+func insightsQuery(profile, logGroup, query string, start, end time.Time) ([]map[string]string, error) {
+	var started struct {
+		QueryID string `json:"queryId"`
+	}
+	err := awsLogs(profile, &started, "start-query",
+		"--log-group-name", logGroup,
+		"--start-time", strconv.FormatInt(start.Unix(), 10),
+		"--end-time", strconv.FormatInt(end.Unix(), 10),
+		"--query-string", query,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	deadline := time.Now().Add(insightsQueryTimeout)
+	for {
+		var resp struct {
+			Status  string `json:"status"`
+			Results [][]struct {
+				Field string `json:"field"`
+				Value string `json:"value"`
+			} `json:"results"`
+		}
+		if err := awsLogs(profile, &resp, "get-query-results", "--query-id", started.QueryID); err != nil {
+			return nil, err
+		}
+		switch resp.Status {
+		case "Complete":
+			rows := make([]map[string]string, 0, len(resp.Results))
+			for _, result := range resp.Results {
+				row := map[string]string{}
+				for _, f := range result {
+					row[f.Field] = f.Value
+				}
+				rows = append(rows, row)
+			}
+			return rows, nil
+		case "Failed", "Cancelled", "Timeout":
+			return nil, fmt.Errorf("logs insights query %s", strings.ToLower(resp.Status))
+		}
+		if time.Now().After(deadline) {
+			return nil, errors.New("logs insights query didn't finish in time")
+		}
+		time.Sleep(time.Second)
+	}
+}
+
+// mergeTimeline orders the quest's history records in env and the runs
+// found in the logs into one timeline. Runs with the request ID of a history
+// record were invoked by this tool and are only listed once, as manual.
+func mergeTimeline(records []historyRecord, runs []logRun, env string, quest int) []timelineEntry {
+	var entries []timelineEntry
+	manual := map[string]bool{}
+	for _, rec := range records {
+		if rec.Env != env || rec.QuestID == nil || *rec.QuestID != quest {
+			continue
+		}
+		outcome := "ok"
+		if rec.failed() {
+			outcome = rec.Error
+		}
+		detail := "by " + rec.Operator
+		if rec.DryRun {
+			detail += ", dry run"
+		}
+		if rec.Mode == modeAsync {
+			detail += ", async"
+		}
+		entries = append(entries, timelineEntry{
+			Time:      rec.Time,
+			Source:    sourceManual,
+			Action:    string(rec.Action),
+			Outcome:   outcome,
+			Duration:  time.Duration(rec.DurationMS) * time.Millisecond,
+			RequestID: rec.RequestID,
+			Detail:    detail,
+		})
+		if rec.RequestID != "" {
+			manual[rec.RequestID] = true
+		}
+	}
+	for _, run := range runs {
+		if manual[run.RequestID] {
+			continue
+		}
+		outcome := "ok"
+		if run.Errors > 0 {
+			outcome = fmt.Sprintf("%d error line(s) logged", run.Errors)
+		}
+		entries = append(entries, timelineEntry{
+			Time:      run.First,
+			Source:    sourceScheduled,
+			Action:    "run",
+			Outcome:   outcome,
+			Duration:  run.Last.Sub(run.First),
+			RequestID: run.RequestID,
+		})
+	}
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].Time.Before(entries[j].Time) })
+	return entries
+}
+
+// timelineText renders the timeline for the scrollable view
+func timelineText(entries []timelineEntry) string {
+	if len(entries) == 0 {
+		return "Nothing found for this quest."
+	}
+	var sb strings.Builder
+	for _, e := range entries {
+		// Padded before styling, the escape codes would throw the width off
+		badge := fmt.Sprintf("%-11s", "["+e.Source+"]")
+		if e.Source == sourceScheduled {
+			badge = dimStyle.Render(badge)
+		}
+		outcome := e.Outcome
+		if outcome != "ok" {
+			outcome = errorStyle.Render(outcome)
+		}
+		sb.WriteString(fmt.Sprintf("%s  %s  %-9s  %8s  %s", e.Time.Local().Format("2006-01-02 15:04:05"), badge, e.Action, e.Duration.Round(100*time.Millisecond), outcome))
+		if e.Detail != "" {
+			sb.WriteString("  " + e.Detail)
+		}
+		if e.RequestID != "" {
+			sb.WriteString("  " + dimStyle.Render(e.RequestID))
+		}
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
+
+// timelineMarkdown renders the timeline as a Markdown table for incident docs
+func timelineMarkdown(env string, quest int, entries []timelineEntry, now time.Time) string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("# Quest %d timeline (%s)\n\n", quest, env))
+	sb.WriteString(fmt.Sprintf("Exported %s. Times are UTC.\n\n", now.UTC().Format(time.RFC3339)))
+	sb.WriteString("| Time | Source | Action | Duration | Outcome | Detail | Request ID |\n")
+	sb.WriteString("|---|---|---|---|---|---|---|\n")
+	cell := func(s string) string { return strings.ReplaceAll(s, "|", `\|`) }
+	for _, e := range entries {
+		sb.WriteString(fmt.Sprintf("| %s | %s | %s | %s | %s | %s | %s |\n",
+			e.Time.UTC().Format("2006-01-02 15:04:05"), e.Source, e.Action, e.Duration.Round(100*time.Millisecond),
+			cell(e.Outcome), cell(e.Detail), e.RequestID))
+	}
+	return sb.String()
+}
+
+// export writes the timeline as Markdown into the working directory
+func (t timelineScreen) export(now time.Time) string {
+	path := fmt.Sprintf("playtools-timeline-%s-%d-%s.md", t.env, t.quest, now.UTC().Format("20060102T150405Z"))
+	if err := os.WriteFile(path, []byte(timelineMarkdown(t.env, t.quest, t.entries, now)), 0o644); err != nil {
+		return fmt.Sprintf("Export failed: %v", err)
+	}
+	return fmt.Sprintf("Exported timeline to %s", path)
+}
+
+// openTimeline starts fetching the timeline of a quest in the selected
+// environment
+func (m model) openTimeline(quest int) (tea.Model, tea.Cmd) {
+	m.timeline.env, m.timeline.quest = m.selectedEnv, quest
+	m.timeline.entries, m.timeline.warning, m.timeline.message = nil, "", ""
+	m.timeline.loading = true
+	m.timeline.view.SetContent("")
+	m.timelineReturn = m.currentScreen
+	if m.timelineReturn == PromptScreen {
+		m.timelineReturn = ActionScreen
+	}
+	m.currentScreen = TimelineScreen
+	return m, tea.Batch(m.spinner.Tick, fetchTimelineCmd(m.selectedEnv, quest))
+}
+
+// updateTimeline handles the keys of the timeline screen
+func (m model) updateTimeline(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	if !m.timeline.view.inputting() {
+		switch keys.name(msg) {
+		case "b", "esc":
+			m.currentScreen = m.timelineReturn
+			return m, nil
+		case "x":
+			if !m.timeline.loading {
+				m.timeline.message = m.timeline.export(time.Now())
+			}
+			return m, nil
+		case "ctrl+c", "q":
+			return m, tea.Quit
+		}
+	}
+	var cmd tea.Cmd
+	m.timeline.view, cmd = m.timeline.view.Update(msg)
+	return m, cmd
+}
+
+// timelineView renders the timeline screen
+func (m model) timelineView() string {
+	t := m.timeline
+	header := tr(msgTimelineTitle, t.quest, t.env, int(timelineWindow.Hours()/24))
+	if t.loading {
+		return fmt.Sprintf("\n  %s\n\n  %s %s\n", header, m.spinner.View(), tr(msgTimelineSearching))
+	}
+	footer := tr(msgTimelineHelp)
+	switch {
+	case t.message != "":
+		footer = t.message
+	case t.warning != "":
+		footer = glyphs.Warn + " " + t.warning
+	}
+	return header + "\n" + t.view.View() + "\n" + dimStyle.Render(footer)
+}
+
+// timelineChrome is the number of lines the timeline screen adds around its view
+const timelineChrome = 2
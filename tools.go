@@ -0,0 +1,79 @@
+package main
+
+import (
+	"regexp"
+	"text/template"
+	"time"
+)
+
+// sweepstakeTool names the sweepstake calculator in per-tool config
+const sweepstakeTool = "sweepstake"
+
+// Transports a tool can be invoked over
+const (
+	transportLambda = "lambda"
+	transportHTTP   = "http"
+)
+
+// toolSpec is how a tool is invoked and observed
+type toolSpec struct {
+	// Transport is transportLambda to invoke the function directly, or
+	// transportHTTP to POST the payload to the environment's URL signed with
+	// SigV4
+	Transport string
+	URLs      map[string]string
+	// ProgressPattern matches the progress lines the tool logs while running.
+	// The done and total named groups hold the entry counts.
+	ProgressPattern *regexp.Regexp
+	// AsyncThreshold is the function timeout above which long running actions
+	// are invoked async
+	AsyncThreshold time.Duration
+	// PayloadTransform wraps the payload before it is sent, nil to send it as is
+	PayloadTransform *payloadTransform
+	// VerifyMetric cross-checks completes against a metric, nil to skip it
+	VerifyMetric *metricCheck
+	// Requires lists the capability labels each action needs, matched
+	// against the roles section of the config for advisory hints
+	Requires map[Action][]string
+	// SnapshotOverrides builds the overrides of a snapshot investigation
+	SnapshotOverrides *template.Template
+	// Promotion is what comparing a quest's status with prod checks
+	Promotion promotionFields
+	// Lifecycle holds back actions the quest's state doesn't allow, nil to
+	// allow every action
+	Lifecycle *questLifecycle
+	// Fields is the help and examples of the prompt fields from the config,
+	// and Schema a JSON Schema file or URL describing the payload
+	Fields map[string]fieldHelp
+	Schema string
+	// RewardPool adds up the prize tiers of the overrides, nil to skip it
+	RewardPool *rewardPool
+	// MaxBatchSize is the largest batch size a process or complete takes
+	MaxBatchSize int
+	// Discovered maps each environment a tool was discovered in to its
+	// function, nil for the built-in and configured tools
+	Discovered  map[string]string
+	Description string
+	// FunctionTemplate names the function of a tool of its own from the
+	// config, and Actions are what it offers. Empty for the sweepstake
+	// calculator and discovered tools.
+	FunctionTemplate string
+	Actions          []toolAction
+}
+
+// toolRegistry holds every known tool, adjusted by the tools section of the
+// config file
+var toolRegistry = map[string]*toolSpec{
+	sweepstakeTool: {
+		Transport:         transportLambda,
+		ProgressPattern:   regexp.MustCompile(`progress: (?P<done>\d+)/(?P<total>\d+) entries`),
+		AsyncThreshold:    5 * time.Minute,
+		SnapshotOverrides: template.Must(newSnapshotTemplate(defaultSnapshotOverrides)),
+		Promotion:         promotionFields{MayDiffer: defaultMayDiffer},
+		MaxBatchSize:      defaultMaxBatchSize,
+	},
+}
+
+// defaultMaxBatchSize is well above any batch the lambda handles in time, so
+// it only catches a typo
+const defaultMaxBatchSize = 10000
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/revrost/playtools/pkg/playtools"
+)
+
+// toolTestFields are the form fields `tool test --set` accepts
+var toolTestFields = []string{"action", "quest_id", "duration_minutes", "dry_run", "batch_size", "snapshot_id", "original_request_id", "justification", "idempotency_key"}
+
+// setFlags collects repeated --set field=value flags
+type setFlags map[string]string
+
+func (s setFlags) String() string { return "" }
+
+func (s setFlags) Set(v string) error {
+	field, value, ok := strings.Cut(v, "=")
+	if !ok {
+		return fmt.Errorf("expected field=value, got %q", v)
+	}
+	field = strings.TrimSpace(field)
+	if !slices.Contains(toolTestFields, field) {
+		return fmt.Errorf("unknown field %q, expected one of %s", field, strings.Join(toolTestFields, ", "))
+	}
+	s[field] = strings.TrimSpace(value)
+	return nil
+}
+
+// runToolCommand handles the `playtools tool` subcommands
+func runToolCommand(args []string, stdout io.Writer) int {
+	if len(args) == 0 || args[0] != "test" {
+		fmt.Fprintf(os.Stderr, "Error: expected a tool subcommand\n\n%s", usageText)
+		return exitUsage
+	}
+	fs := flag.NewFlagSet("tool test", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	env := fs.String("env", devEnv, "")
+	sets := setFlags{}
+	fs.Var(sets, "set", "")
+	positionals, err := parseArgs(fs, args[1:])
+	if err == nil && len(positionals) != 1 {
+		err = errors.New("expected a single tool name")
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n\n%s", err, usageText)
+		return exitUsage
+	}
+
+	if err := setupConfig(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading config: %v\n", err)
+		return exitFailure
+	}
+	name := positionals[0]
+	tool, ok := toolRegistry[name]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "Error: unknown tool %q\n", name)
+		return exitUsage
+	}
+	if pinnedEnv != "" {
+		*env = pinnedEnv
+	}
+	if _, ok := profileMap[*env]; !ok {
+		fmt.Fprintf(os.Stderr, "Error: unknown environment %q\n", *env)
+		return exitUsage
+	}
+
+	payload, err := toolTestPayload(*env, sets)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitFailure
+	}
+	sent, transformErr := encodePayload(*env, payload)
+
+	profile := profileMap[*env]
+	// Only the local AWS config files are read, nothing goes over the network
+	region := "unknown"
+	if r := regionMap[*env]; r != "" {
+		region = r + " (config)"
+	} else if shared, err := config.LoadSharedConfigProfile(context.Background(), profile); err != nil {
+		region += fmt.Sprintf(" (%v)", err)
+	} else if shared.Region != "" {
+		region = shared.Region
+	}
+
+	fmt.Fprintf(stdout, "Tool: %s\n", name)
+	fmt.Fprintf(stdout, "Environment: %s\n", *env)
+	fmt.Fprintf(stdout, "Profile: %s\n", profile)
+	fmt.Fprintf(stdout, "Region: %s\n", region)
+	fmt.Fprintf(stdout, "Transport: %s\n", tool.Transport)
+	if tool.Transport == transportHTTP {
+		fmt.Fprintf(stdout, "URL: %s\n", tool.URLs[*env])
+	} else {
+		fmt.Fprintf(stdout, "Function: %s\n", sweepstakeFunction(*env))
+	}
+	jsonPayload, _ := json.MarshalIndent(payload, "", "  ")
+	fmt.Fprintf(stdout, "Payload:\n%s\n", jsonPayload)
+	if transformErr != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", transformErr)
+		return exitFailure
+	}
+	if tool.PayloadTransform != nil {
+		indented, _ := indentJSON(sent)
+		fmt.Fprintf(stdout, "Sent as (%s):\n%s\n", tool.PayloadTransform.Name, indented)
+	}
+	return 0
+}
+
+// toolTestPayload builds a payload from --set fields the way the TUI's
+// forms do, with the same validation messages
+func toolTestPayload(env string, sets setFlags) (EventPayload, error) {
+	action, ok := sets["action"]
+	if !ok {
+		return EventPayload{}, errors.New("--set action=... is required")
+	}
+	// Reject fields the action's form doesn't have instead of ignoring them
+	allowed := map[string][]string{
+		string(ActionProcess):  {"quest_id", "dry_run", "batch_size"},
+		string(ActionComplete): {"quest_id", "idempotency_key"},
+		string(ActionStart):    {"duration_minutes", "dry_run"},
+		string(ActionRollback): {"quest_id", "original_request_id", "justification"},
+		investigateAction:      {"quest_id", "snapshot_id"},
+	}
+	fields, ok := allowed[action]
+	if !ok {
+		actions := make([]string, 0, len(allowed))
+		for a := range allowed {
+			actions = append(actions, a)
+		}
+		sort.Strings(actions)
+		return EventPayload{}, fmt.Errorf("action: unknown action %q, expected one of %s", action, strings.Join(actions, ", "))
+	}
+	for field := range sets {
+		if field != "action" && !slices.Contains(fields, field) {
+			return EventPayload{}, fmt.Errorf("%s is not a field of %s", field, action)
+		}
+	}
+
+	valueField := "quest_id"
+	if action == string(ActionStart) {
+		valueField = "duration_minutes"
+	}
+	value, err := parsePositive(sets[valueField])
+	if err != nil {
+		return EventPayload{}, fmt.Errorf("%s: %v", valueField, err)
+	}
+
+	var payload EventPayload
+	switch action {
+	case investigateAction:
+		if payload, err = buildSnapshotPayload(value, sets["snapshot_id"]); err != nil {
+			return payload, fmt.Errorf("snapshot_id: %v", err)
+		}
+		return payload, nil
+	case string(ActionRollback):
+		if sets["original_request_id"] == "" {
+			return payload, errors.New("original_request_id: the request ID of the complete to roll back is required")
+		}
+		if err := validateJustification(sets["justification"]); err != nil {
+			return payload, fmt.Errorf("justification: %v", err)
+		}
+		return buildRollbackPayload(historyRecord{QuestID: &value, RequestID: sets["original_request_id"]}, sets["justification"]), nil
+	}
+
+	payload = playtools.NewPayload(Action(action), value)
+	payload.DryRun = defaultDryRun(env, payload.Action)
+	if s, ok := sets["dry_run"]; ok {
+		if payload.DryRun, err = strconv.ParseBool(s); err != nil {
+			return payload, fmt.Errorf("dry_run: %q is not true or false", s)
+		}
+	}
+	if s, ok := sets["batch_size"]; ok {
+		n, err := parsePositive(s)
+		if err != nil {
+			return payload, fmt.Errorf("batch_size: %v", err)
+		}
+		payload.BatchSize = &n
+	}
+	payload.IdempotencyKey = sets["idempotency_key"]
+	if err := assignIdempotencyKey(&payload); err != nil {
+		return payload, err
+	}
+	return payload, nil
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"text/template"
+)
+
+// payloadTransform rewrites the marshalled payload right before it is sent,
+// for functions that expect it wrapped in an envelope of their own
+type payloadTransform struct {
+	// Name describes the transform on the confirmation screen
+	Name string
+	// Source fills the source field of the built-in wrappers
+	Source   string
+	template *template.Template
+}
+
+// payloadWrappers are the built-in transforms, usable by name. Their source
+// field is filled from the config.
+var payloadWrappers = map[string]string{
+	// detail wraps the payload as an EventBridge style event
+	"detail": `{"detail": {{.JSON}}, "source": {{json .Source}}}`,
+}
+
+// transformData is what a payload template is executed with
+type transformData struct {
+	Payload EventPayload
+	// JSON is the marshalled payload
+	JSON   string
+	Env    string
+	Source string
+}
+
+var transformFuncs = template.FuncMap{
+	"json": func(v interface{}) (string, error) {
+		data, err := json.Marshal(v)
+		return string(data), err
+	},
+}
+
+// newPayloadTransform builds a transform from either a built-in wrapper name
+// or a Go template. source is the source field of the built-in wrappers.
+func newPayloadTransform(wrapper, text, source string) (*payloadTransform, error) {
+	name := "template"
+	switch {
+	case wrapper != "" && text != "":
+		return nil, fmt.Errorf("set either wrapper or template, not both")
+	case wrapper != "":
+		builtin, ok := payloadWrappers[wrapper]
+		if !ok {
+			names := make([]string, 0, len(payloadWrappers))
+			for n := range payloadWrappers {
+				names = append(names, n)
+			}
+			sort.Strings(names)
+			return nil, fmt.Errorf("unknown wrapper %q, expected one of %s", wrapper, strings.Join(names, ", "))
+		}
+		name, text = wrapper+" wrapper", builtin
+	case text == "":
+		return nil, nil
+	}
+	if source == "" {
+		source = "playtools"
+	}
+	tmpl, err := template.New(name).Funcs(transformFuncs).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	return &payloadTransform{Name: name, Source: source, template: tmpl}, nil
+}
+
+// apply runs the transform on a payload for env, checking that it produced
+// valid JSON
+func (t *payloadTransform) apply(env string, payload EventPayload) ([]byte, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := t.template.Execute(&buf, transformData{Payload: payload, JSON: string(data), Env: env, Source: t.Source}); err != nil {
+		return nil, err
+	}
+	if !json.Valid(buf.Bytes()) {
+		return nil, fmt.Errorf("%s produced invalid JSON: %s", t.Name, buf.String())
+	}
+	return buf.Bytes(), nil
+}
+
+// transformView shows the payload as it will be sent on the confirmation
+// screen, or why it can't be
+func (m model) transformView() string {
+	transform := toolRegistry[sweepstakeTool].PayloadTransform
+	if transform == nil {
+		return ""
+	}
+	data, err := encodePayload(m.selectedEnv, m.pendingPayload)
+	if err != nil {
+		return "  " + errorStyle.Render(err.Error()) + "\n  " + tr(msgTransformBroken) + "\n\n"
+	}
+	var indented bytes.Buffer
+	_ = json.Indent(&indented, data, "  ", "  ")
+	return fmt.Sprintf("  %s\n  %s\n\n", tr(msgTransformSentAs, transform.Name), indented.String())
+}
+
+// encodePayload marshals a payload the way it is sent to the tool, through
+// the tool's transform when it has one
+func encodePayload(env string, payload EventPayload) ([]byte, error) {
+	transform := toolRegistry[sweepstakeTool].PayloadTransform
+	if transform == nil {
+		return json.Marshal(payload)
+	}
+	data, err := transform.apply(env, payload)
+	if err != nil {
+		return nil, fmt.Errorf("payload transform failed: %w", err)
+	}
+	return data, nil
+}
@@ -0,0 +1,239 @@
+package main
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os/exec"
+	"sort"
+	"text/template"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// metricCheck cross-checks a complete against a custom metric the function
+// emits while distributing, such as RewardsDistributed
+type metricCheck struct {
+	Namespace string
+	Name      string
+	// Dimensions are templates executed with .QuestID and .Env
+	Dimensions map[string]*template.Template
+	// WinnersField is the response field holding the winners, a count or a list
+	WinnersField string
+	// Tolerance is how far the metric sum may be off the winners count
+	Tolerance float64
+	// Window is how long after the invocation the metric is summed over
+	Window time.Duration
+	// Timeout bounds the wait for the metric to catch up
+	Timeout time.Duration
+}
+
+// newMetricCheck validates a verify_metric config
+func newMetricCheck(cfg VerifyMetricConfig) (*metricCheck, error) {
+	if cfg.Namespace == "" || cfg.Name == "" {
+		return nil, fmt.Errorf("namespace and name are required")
+	}
+	if cfg.Tolerance < 0 {
+		return nil, fmt.Errorf("tolerance must not be negative")
+	}
+	check := &metricCheck{
+		Namespace:    cfg.Namespace,
+		Name:         cfg.Name,
+		Dimensions:   map[string]*template.Template{},
+		WinnersField: cmp.Or(cfg.WinnersField, "winners"),
+		Tolerance:    cfg.Tolerance,
+	}
+	for name, text := range cfg.Dimensions {
+		tmpl, err := template.New(name).Option("missingkey=error").Parse(text)
+		if err != nil {
+			return nil, fmt.Errorf("dimensions.%s: %v", name, err)
+		}
+		check.Dimensions[name] = tmpl
+	}
+	var err error
+	if check.Window, err = time.ParseDuration(cmp.Or(cfg.Window, "10m")); err != nil || check.Window <= 0 {
+		return nil, fmt.Errorf("window: expected a positive duration like 10m, got %q", cfg.Window)
+	}
+	if check.Timeout, err = time.ParseDuration(cmp.Or(cfg.Timeout, "2m")); err != nil || check.Timeout <= 0 {
+		return nil, fmt.Errorf("timeout: expected a positive duration like 2m, got %q", cfg.Timeout)
+	}
+	return check, nil
+}
+
+// metricPollInterval is the wait between metric queries while it doesn't match
+var metricPollInterval = 30 * time.Second
+
+// verification is the outcome of a metric check
+type verification struct {
+	Metric   string
+	Sum      float64
+	Expected int
+	Matched  bool
+	// TimedOut is set when the metric never matched before the timeout
+	TimedOut bool
+	Err      error
+}
+
+// verificationMsg carries a finished metric check for invocation gen
+type verificationMsg struct {
+	gen int
+	verification
+}
+
+// verifyApplies reports whether a finished invocation gets a metric check
+func verifyApplies(inv *invocation, err error) bool {
+	return toolRegistry[sweepstakeTool].VerifyMetric != nil && err == nil && inv != nil &&
+		inv.Payload.Action == ActionComplete && inv.FunctionError == ""
+}
+
+func verifyDistributionCmd(gen int, inv *invocation) tea.Cmd {
+	return func() tea.Msg {
+		return verificationMsg{gen: gen, verification: verifyDistribution(inv)}
+	}
+}
+
+// verifyDistribution sums the metric over the window following the invocation
+// and compares it with the winners count of the response, polling until they
+// match or the check times out
+func verifyDistribution(inv *invocation) verification {
+	check := toolRegistry[sweepstakeTool].VerifyMetric
+	v := verification{Metric: check.Namespace + "/" + check.Name}
+	expected, ok := responseCount(inv.Body, check.WinnersField)
+	if !ok {
+		v.Err = fmt.Errorf("the response has no %s count to compare with", check.WinnersField)
+		return v
+	}
+	v.Expected = expected
+
+	dimensions, err := check.dimensions(inv)
+	if err != nil {
+		v.Err = err
+		return v
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), check.Timeout)
+	defer cancel()
+	for {
+		sum, err := queryMetricSum(ctx, profileMap[inv.Env], check, dimensions, inv.StartedAt.Add(-time.Minute), inv.StartedAt.Add(check.Window))
+		switch {
+		case err != nil && ctx.Err() == nil:
+			v.Err = err
+			return v
+		case err == nil:
+			v.Sum = sum
+			if math.Abs(sum-float64(expected)) <= check.Tolerance {
+				v.Matched = true
+				return v
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			v.TimedOut = true
+			return v
+		case <-time.After(metricPollInterval):
+		}
+	}
+}
+
+// dimensions renders the dimension templates for an invocation
+func (c *metricCheck) dimensions(inv *invocation) (map[string]string, error) {
+	data := struct {
+		QuestID int
+		Env     string
+	}{derefInt(inv.Payload.SweepstakeQuestID), inv.Env}
+	dims := map[string]string{}
+	for name, tmpl := range c.Dimensions {
+		var buf bytes.Buffer
+		if err := tmpl.Execute(&buf, data); err != nil {
+			return nil, fmt.Errorf("dimension %s: %v", name, err)
+		}
+		dims[name] = buf.String()
+	}
+	return dims, nil
+}
+
+// queryMetricSum sums a metric between start and end with `aws cloudwatch
+// get-metric-data`
+func queryMetricSum(ctx context.Context, profile string, check *metricCheck, dimensions map[string]string, start, end time.Time) (float64, error) {
+	type dimension struct {
+		Name  string
+		Value string
+	}
+	dims := make([]dimension, 0, len(dimensions))
+	for name, value := range dimensions {
+		dims = append(dims, dimension{name, value})
+	}
+	sort.Slice(dims, func(i, j int) bool { return dims[i].Name < dims[j].Name })
+
+	query := []map[string]interface{}{{
+		"Id": "distributed",
+		"MetricStat": map[string]interface{}{
+			"Metric": map[string]interface{}{
+				"Namespace":  check.Namespace,
+				"MetricName": check.Name,
+				"Dimensions": dims,
+			},
+			"Period": int(math.Ceil(end.Sub(start).Seconds()/60)) * 60,
+			"Stat":   "Sum",
+		},
+	}}
+	queryJSON, err := json.Marshal(query)
+	if err != nil {
+		return 0, err
+	}
+
+	args := append([]string{"cloudwatch", "get-metric-data",
+		"--metric-data-queries", string(queryJSON),
+		"--start-time", start.UTC().Format(time.RFC3339),
+		"--end-time", end.UTC().Format(time.RFC3339),
+		"--profile", profile, "--output", "json",
+	}, regionArgs(profile)...)
+	data, err := exec.CommandContext(ctx, "aws", args...).Output()
+	if err != nil {
+		return 0, fmt.Errorf("failed to query %s: %v", check.Name, err)
+	}
+	var resp struct {
+		MetricDataResults []struct {
+			Values []float64
+		}
+	}
+	if err := json.Unmarshal(data, &resp); err != nil {
+		return 0, fmt.Errorf("failed to parse metric data: %v", err)
+	}
+	var sum float64
+	for _, r := range resp.MetricDataResults {
+		for _, v := range r.Values {
+			sum += v
+		}
+	}
+	return sum, nil
+}
+
+// mismatch reports whether the metric disagrees with the response, as
+// opposed to the check not getting an answer
+func (v verification) mismatch() bool {
+	return v.Err == nil && !v.Matched && !(v.TimedOut && v.Sum == 0)
+}
+
+// lines renders the outcome of the check, with a mismatch standing out
+func (v verification) lines() []string {
+	sum := formatDecimal(v.Sum, displayLocale)
+	expected := formatNumber(int64(v.Expected), displayLocale)
+	switch {
+	case v.Err != nil:
+		return []string{fmt.Sprintf("%s Could not verify %s: %v", glyphs.Warn, v.Metric, v.Err)}
+	case v.Matched:
+		return []string{fmt.Sprintf("%s %s sum %s matches the %s winners reported", glyphs.Check, v.Metric, sum, expected)}
+	case !v.mismatch():
+		return []string{fmt.Sprintf("%s No %s data arrived in time to verify the %s winners reported", glyphs.Warn, v.Metric, expected)}
+	}
+	return []string{
+		errorStyle.Render(fmt.Sprintf("%s DISTRIBUTION MISMATCH: %s sum %s, the response reported %s winners", glyphs.Cross, v.Metric, sum, expected)),
+		"  Check the logs and the rewards ledger before retrying or rolling back.",
+	}
+}
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"regexp"
+	"runtime"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// version is the build version, set with -ldflags "-X main.version=v1.2.3"
+// by make build. Without it the module version go install recorded is used.
+var version = ""
+
+// commit and buildDate are set alongside version, like
+// -X main.commit=abc1234 -X main.buildDate=2024-06-01T10:30:00Z. Without
+// them the VCS stamp go build recorded is used.
+var (
+	commit    = ""
+	buildDate = ""
+)
+
+// awsSDKModule is the module whose version the version command reports
+const awsSDKModule = "github.com/aws/aws-sdk-go-v2"
+
+// updateCommand is how an outdated binary is updated
+const updateCommand = "go install github.com/revrost/playtools@latest"
+
+// minVersion is the oldest build the config allows to run mutating actions,
+// empty for any
+var minVersion string
+
+// buildVersion is the version of the running binary, "dev" for a local
+// build without one
+func buildVersion() string {
+	if version != "" {
+		return version
+	}
+	if info, ok := debug.ReadBuildInfo(); ok && info.Main.Version != "" && info.Main.Version != "(devel)" {
+		return info.Main.Version
+	}
+	return "dev"
+}
+
+// buildMetadata describes the running binary for bug reports and the
+// results it saves
+type buildMetadata struct {
+	Version string `json:"version"`
+	Commit  string `json:"commit"`
+	Date    string `json:"date"`
+	// Modified marks a build from a tree with uncommitted changes
+	Modified bool   `json:"modified,omitempty"`
+	Go       string `json:"go"`
+	AWSSDK   string `json:"aws_sdk"`
+}
+
+// readBuildMetadata is the metadata of the running binary, read once
+var readBuildMetadata = sync.OnceValue(loadBuildMetadata)
+
+// loadBuildMetadata collects the ldflags values, falling back to what the
+// Go toolchain recorded and then to "devel"
+func loadBuildMetadata() buildMetadata {
+	b := buildMetadata{Version: buildVersion(), Commit: commit, Date: buildDate, Go: runtime.Version()}
+	if info, ok := debug.ReadBuildInfo(); ok {
+		for _, s := range info.Settings {
+			switch s.Key {
+			case "vcs.revision":
+				if b.Commit == "" {
+					b.Commit = s.Value
+				}
+			case "vcs.time":
+				if b.Date == "" {
+					b.Date = s.Value
+				}
+			case "vcs.modified":
+				b.Modified = s.Value == "true"
+			}
+		}
+		for _, dep := range info.Deps {
+			if dep.Path == awsSDKModule {
+				b.AWSSDK = dep.Version
+			}
+		}
+	}
+	if len(b.Commit) > 12 {
+		b.Commit = b.Commit[:12]
+	}
+	b.Commit = cmp.Or(b.Commit, "devel")
+	b.Date = cmp.Or(b.Date, "devel")
+	b.AWSSDK = cmp.Or(b.AWSSDK, "devel")
+	return b
+}
+
+// String is the one-line form of the footer, like "v1.2.3 (abc1234)"
+func (b buildMetadata) String() string {
+	rev := b.Commit
+	if b.Modified {
+		rev += ", modified"
+	}
+	return fmt.Sprintf("%s (%s)", b.Version, rev)
+}
+
+// semver is a parsed version like v1.2.3-rc.1
+type semver struct {
+	major, minor, patch int
+	pre                 []string
+}
+
+// describeSuffixRe matches what git describe adds to the latest tag for the
+// commits after it, like -3-gabc1234-dirty
+var describeSuffixRe = regexp.MustCompile(`-\d+-g[0-9a-f]+(-dirty)?$|-dirty$`)
+
+// parseSemver reads a version like v1.2.3, 1.2.3-rc.1 or a Go pseudo-version.
+// Build metadata is ignored, and a git describe version counts as the tag
+// it builds on, as it's a build of the commits after it.
+func parseSemver(s string) (semver, error) {
+	var v semver
+	rest := strings.TrimPrefix(strings.TrimSpace(s), "v")
+	rest, _, _ = strings.Cut(rest, "+")
+	rest = describeSuffixRe.ReplaceAllString(rest, "")
+	core, pre, hasPre := strings.Cut(rest, "-")
+	parts := strings.Split(core, ".")
+	if len(parts) != 3 {
+		return v, fmt.Errorf("expected a version like v1.2.3, got %q", s)
+	}
+	nums := make([]int, 3)
+	for i, p := range parts {
+		n, err := strconv.Atoi(p)
+		if err != nil || n < 0 {
+			return v, fmt.Errorf("expected a version like v1.2.3, got %q", s)
+		}
+		nums[i] = n
+	}
+	v.major, v.minor, v.patch = nums[0], nums[1], nums[2]
+	if hasPre {
+		if pre == "" {
+			return v, fmt.Errorf("expected a pre-release after the dash in %q", s)
+		}
+		v.pre = strings.Split(pre, ".")
+	}
+	return v, nil
+}
+
+// compareSemver orders versions like semver does: a pre-release comes
+// before its release, and pre-release identifiers compare numerically when
+// they're numbers
+func compareSemver(a, b semver) int {
+	if c := cmp.Or(cmp.Compare(a.major, b.major), cmp.Compare(a.minor, b.minor), cmp.Compare(a.patch, b.patch)); c != 0 {
+		return c
+	}
+	switch {
+	case len(a.pre) == 0 && len(b.pre) == 0:
+		return 0
+	case len(a.pre) == 0:
+		return 1
+	case len(b.pre) == 0:
+		return -1
+	}
+	for i := 0; i < len(a.pre) && i < len(b.pre); i++ {
+		x, errX := strconv.Atoi(a.pre[i])
+		y, errY := strconv.Atoi(b.pre[i])
+		switch {
+		case errX == nil && errY == nil:
+			if x != y {
+				return cmp.Compare(x, y)
+			}
+		case errX == nil:
+			return -1
+		case errY == nil:
+			return 1
+		default:
+			if c := strings.Compare(a.pre[i], b.pre[i]); c != 0 {
+				return c
+			}
+		}
+	}
+	return cmp.Compare(len(a.pre), len(b.pre))
+}
+
+// outdatedReason explains why the binary is too old for the config's
+// min_version, empty when it isn't. Local dev builds have no version to
+// compare and are let through.
+func outdatedReason(build, required string) string {
+	if required == "" || build == "dev" {
+		return ""
+	}
+	want, err := parseSemver(required)
+	if err != nil {
+		return ""
+	}
+	have, err := parseSemver(build)
+	if err != nil {
+		return fmt.Sprintf("build version %q can't be compared with the config's min_version %s", build, required)
+	}
+	if compareSemver(have, want) >= 0 {
+		return ""
+	}
+	return fmt.Sprintf("playtools %s is older than the config's min_version %s", build, required)
+}
+
+// versionBlock explains why mutating actions are refused, empty when the
+// binary is recent enough
+func versionBlock() string {
+	reason := outdatedReason(buildVersion(), minVersion)
+	if reason == "" {
+		return ""
+	}
+	return fmt.Sprintf("%s, only read-only actions and dry runs are allowed. Update with: %s", reason, updateCommand)
+}
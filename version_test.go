@@ -0,0 +1,120 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseSemver(t *testing.T) {
+	for _, s := range []string{"1.2", "v1.2.x", "v1.-2.3", "v1.2.3-", "", "dev", "latest"} {
+		if _, err := parseSemver(s); err == nil {
+			t.Errorf("parsed %q", s)
+		}
+	}
+	v, err := parseSemver(" v1.2.3-rc.1+linux.amd64 ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v.major != 1 || v.minor != 2 || v.patch != 3 || strings.Join(v.pre, ".") != "rc.1" {
+		t.Errorf("parsed %+v", v)
+	}
+}
+
+func TestCompareSemver(t *testing.T) {
+	// Each version is older than the next
+	ordered := []string{
+		"v0.9.12",
+		"v1.0.0-0.20240501120000-abcdef123456",
+		"v1.0.0-alpha",
+		"v1.0.0-alpha.1",
+		"v1.0.0-alpha.beta",
+		"v1.0.0-beta",
+		"v1.0.0-beta.2",
+		"v1.0.0-beta.11",
+		"v1.0.0-rc.1",
+		"v1.0.0",
+		"v1.0.1",
+		"v1.2.0",
+		"v1.10.0",
+		"v2.0.0",
+	}
+	for i := range ordered {
+		for j := range ordered {
+			a, err := parseSemver(ordered[i])
+			if err != nil {
+				t.Fatal(err)
+			}
+			b, _ := parseSemver(ordered[j])
+			want := 0
+			switch {
+			case i < j:
+				want = -1
+			case i > j:
+				want = 1
+			}
+			if got := compareSemver(a, b); got != want {
+				t.Errorf("compareSemver(%s, %s) = %d, want %d", ordered[i], ordered[j], got, want)
+			}
+		}
+	}
+
+	// Spellings of the same version
+	for _, pair := range [][2]string{
+		{"v1.4.0", "1.4.0"},
+		{"v1.4.0", "v1.4.0+build.7"},
+		{"v1.4.0", "v1.4.0-3-gabc1234"},
+		{"v1.4.0", "v1.4.0-3-gabc1234-dirty"},
+		{"v1.4.0", "v1.4.0-dirty"},
+		{"v1.4.0-rc.2", "v1.4.0-rc.2-1-g0f0f0f0"},
+	} {
+		a, _ := parseSemver(pair[0])
+		b, err := parseSemver(pair[1])
+		if err != nil || compareSemver(a, b) != 0 {
+			t.Errorf("%s and %s differ (%v)", pair[0], pair[1], err)
+		}
+	}
+}
+
+func TestOutdatedReason(t *testing.T) {
+	tests := []struct {
+		build, required string
+		outdated        bool
+	}{
+		{"v1.4.0", "", false},
+		{"v1.4.0", "v1.4.0", false},
+		{"v1.5.0", "v1.4.0", false},
+		{"v1.3.9", "v1.4.0", true},
+		// A release candidate isn't the release yet
+		{"v1.4.0-rc.1", "v1.4.0", true},
+		{"v1.4.0", "v1.4.0-rc.1", false},
+		// Commits after a tag are at least that tag
+		{"v1.4.0-12-g0f0f0f0-dirty", "v1.4.0", false},
+		{"v1.3.0-12-g0f0f0f0", "v1.4.0", true},
+		// A module pseudo-version is older than the release it leads to
+		{"v1.4.0-0.20240501120000-abcdef123456", "v1.4.0", true},
+		{"v1.4.1-0.20240501120000-abcdef123456", "v1.4.0", false},
+		// Local builds have no version, and are let through
+		{"dev", "v9.0.0", false},
+		{"custom-build", "v1.4.0", true},
+	}
+	for _, tt := range tests {
+		reason := outdatedReason(tt.build, tt.required)
+		if (reason != "") != tt.outdated {
+			t.Errorf("outdatedReason(%s, %s) = %q, want outdated %v", tt.build, tt.required, reason, tt.outdated)
+		}
+	}
+}
+
+func TestVersionBlock(t *testing.T) {
+	savedVersion, savedMin := version, minVersion
+	t.Cleanup(func() { version, minVersion = savedVersion, savedMin })
+
+	version, minVersion = "v1.3.0", "v1.4.0"
+	if block := versionBlock(); !strings.Contains(block, "older than the config's min_version v1.4.0") || !strings.Contains(block, updateCommand) {
+		t.Errorf("block %q", block)
+	}
+	version = "v1.4.0"
+	if block := versionBlock(); block != "" {
+		t.Errorf("an up to date build is blocked: %q", block)
+	}
+}
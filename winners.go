@@ -0,0 +1,373 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// winnersChrome is the number of lines the winners screen adds around its rows
+const winnersChrome = 9
+
+// winnersColumnWidth caps a column of the winners screen, the export keeps
+// every value whole
+const winnersColumnWidth = 24
+
+// winnersExportProgressRows is how often the export reports its progress
+const winnersExportProgressRows = 1000
+
+// winnersTable is the winners list of a response. Rows stay raw JSON and
+// are only decoded when they're shown or exported, so a quest with tens of
+// thousands of winners costs one decode of the response.
+type winnersTable struct {
+	// columns are the keys of the winner objects in the order first seen,
+	// or a single "winner" column when the winners aren't objects
+	columns []string
+	rows    []json.RawMessage
+	// whole marks a list with rows that aren't objects, where every row is
+	// shown whole in the single column, objects included
+	whole bool
+}
+
+// winnersField is the response field holding the winners
+func winnersField() string {
+	if check := toolRegistry[sweepstakeTool].VerifyMetric; check != nil {
+		return check.WinnersField
+	}
+	return "winners"
+}
+
+// responseWinners reads the winners list of a response, joining the lists of
+// a multi-result response. It's nil when the response has no list, like a
+// response reporting only a count.
+func responseWinners(body []byte, field string) *winnersTable {
+	bodies := []json.RawMessage{body}
+	if elems, ok := responseElements(body); ok {
+		bodies = elems
+	}
+	var t winnersTable
+	found := false
+	for _, b := range bodies {
+		var resp map[string]json.RawMessage
+		if json.Unmarshal(b, &resp) != nil {
+			continue
+		}
+		var rows []json.RawMessage
+		if json.Unmarshal(resp[field], &rows) != nil || rows == nil {
+			continue
+		}
+		found = true
+		t.rows = append(t.rows, rows...)
+	}
+	if !found {
+		return nil
+	}
+	seen := map[string]bool{}
+	for _, row := range t.rows {
+		keys, err := objectKeys(row)
+		if err != nil {
+			t.columns, t.whole = []string{"winner"}, true
+			return &t
+		}
+		for _, k := range keys {
+			if !seen[k] {
+				seen[k] = true
+				t.columns = append(t.columns, k)
+			}
+		}
+	}
+	return &t
+}
+
+// record is a row's values in column order, strings unquoted and anything
+// else as its JSON
+func (t *winnersTable) record(i int) []string {
+	values := make([]string, len(t.columns))
+	var obj map[string]json.RawMessage
+	if t.whole || json.Unmarshal(t.rows[i], &obj) != nil {
+		values[0] = cellValue(t.rows[i])
+		return values
+	}
+	for c, col := range t.columns {
+		if raw, ok := obj[col]; ok {
+			values[c] = cellValue(raw)
+		}
+	}
+	return values
+}
+
+func cellValue(raw json.RawMessage) string {
+	var s string
+	if json.Unmarshal(raw, &s) == nil {
+		return s
+	}
+	return string(raw)
+}
+
+// winnersScreen pages through the winners of the last response and exports
+// them to CSV in the background
+type winnersScreen struct {
+	table  *winnersTable
+	top    int
+	height int
+	// export is the progress of a running export, nil when none is
+	export   *winnersProgressMsg
+	exportCh chan winnersProgressMsg
+	cancel   context.CancelFunc
+	// gen tells a cancelled export's messages apart from a later one's
+	gen     int
+	message string
+}
+
+// winnersProgressMsg is how far an export got
+type winnersProgressMsg struct {
+	gen         int
+	done, total int
+}
+
+// winnersExportMsg is the outcome of an export
+type winnersExportMsg struct {
+	gen  int
+	path string
+	rows int
+	size int64
+	err  error
+}
+
+// rows is how many winners fit on the screen
+func (w winnersScreen) rows() int {
+	return max(1, w.height-winnersChrome)
+}
+
+// writeWinnersCSV streams the winners to a CSV file a row at a time,
+// reporting progress every winnersExportProgressRows rows. A cancelled or
+// failed export removes the partial file.
+func writeWinnersCSV(ctx context.Context, t *winnersTable, path string, progress func(done int)) (size int64, err error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		if err != nil {
+			f.Close()
+			os.Remove(path)
+		}
+	}()
+	buf := bufio.NewWriter(f)
+	w := csv.NewWriter(buf)
+	if err := w.Write(t.columns); err != nil {
+		return 0, err
+	}
+	for i := range t.rows {
+		if i%winnersExportProgressRows == 0 {
+			if err := ctx.Err(); err != nil {
+				return 0, err
+			}
+			progress(i)
+		}
+		if err := w.Write(t.record(i)); err != nil {
+			return 0, err
+		}
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return 0, err
+	}
+	if err := buf.Flush(); err != nil {
+		return 0, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		return 0, err
+	}
+	return info.Size(), f.Close()
+}
+
+// exportWinnersCmd writes the export, sending its progress on ch and
+// closing it once done
+func exportWinnersCmd(ctx context.Context, gen int, t *winnersTable, path string, ch chan<- winnersProgressMsg) tea.Cmd {
+	return func() tea.Msg {
+		defer close(ch)
+		size, err := writeWinnersCSV(ctx, t, path, func(done int) {
+			// Progress is dropped rather than holding up the export while
+			// the screen catches up
+			select {
+			case ch <- winnersProgressMsg{gen: gen, done: done, total: len(t.rows)}:
+			default:
+			}
+		})
+		return winnersExportMsg{gen: gen, path: path, rows: len(t.rows), size: size, err: err}
+	}
+}
+
+// waitForWinnersProgress delivers the next progress of an export, or
+// nothing once it's finished
+func waitForWinnersProgress(ch <-chan winnersProgressMsg) tea.Cmd {
+	return func() tea.Msg {
+		p, ok := <-ch
+		if !ok {
+			return nil
+		}
+		return p
+	}
+}
+
+// openWinners shows the winners of the last response
+func (m model) openWinners() (tea.Model, tea.Cmd) {
+	m.winners.top = 0
+	m.currentScreen = WinnersScreen
+	return m, nil
+}
+
+// startWinnersExport exports the winners to a CSV file in the working
+// directory
+func (m model) startWinnersExport() (tea.Model, tea.Cmd) {
+	w := &m.winners
+	inv := m.lastInvocation
+	quest := "none"
+	if inv.Payload.SweepstakeQuestID != nil {
+		quest = fmt.Sprint(*inv.Payload.SweepstakeQuestID)
+	}
+	path := fmt.Sprintf("playtools-winners-%s-%s-%s.csv", inv.Env, quest, time.Now().Format("20060102-150405"))
+	ctx, cancel := context.WithCancel(context.Background())
+	w.gen++
+	w.cancel = cancel
+	w.exportCh = make(chan winnersProgressMsg)
+	w.export = &winnersProgressMsg{gen: w.gen, total: len(w.table.rows)}
+	w.message = ""
+	return m, tea.Batch(exportWinnersCmd(ctx, w.gen, w.table, path, w.exportCh), waitForWinnersProgress(w.exportCh))
+}
+
+// finishWinnersExport reports the outcome of an export
+func (m *model) finishWinnersExport(msg winnersExportMsg) {
+	w := &m.winners
+	if msg.gen != w.gen {
+		return
+	}
+	w.cancel()
+	w.export, w.cancel = nil, nil
+	switch {
+	case errors.Is(msg.err, context.Canceled):
+		w.message = tr(msgWinnersExportCancelled)
+	case msg.err != nil:
+		w.message = tr(msgWinnersExportErr, msg.err)
+	default:
+		w.message = tr(msgWinnersExported, formatNumber(int64(msg.rows), displayLocale), msg.path, formatSize(msg.size))
+	}
+	if m.currentScreen != WinnersScreen {
+		m.outputMessage = w.message
+	}
+}
+
+// updateWinners handles the keys of the winners screen
+func (m model) updateWinners(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	w := &m.winners
+	switch keys.name(msg) {
+	case "esc":
+		if w.cancel != nil {
+			w.cancel()
+			return m, nil
+		}
+		m.currentScreen = OutputScreen
+		return m, nil
+	case "b":
+		m.currentScreen = OutputScreen
+		return m, nil
+	case "ctrl+c", "q":
+		return m, tea.Quit
+	case "x":
+		if w.export == nil {
+			return m.startWinnersExport()
+		}
+		return m, nil
+	case "up", "k":
+		w.top--
+	case "down", "j":
+		w.top++
+	case "pgup", "p":
+		w.top -= w.rows()
+	case "pgdown", " ", "n":
+		w.top += w.rows()
+	case "home", "g":
+		w.top = 0
+	case "end", "G":
+		w.top = len(w.table.rows)
+	default:
+		return m, nil
+	}
+	w.top = max(0, min(w.top, len(w.table.rows)-w.rows()))
+	return m, nil
+}
+
+// winnersView renders the winners screen. Only the visible rows are
+// decoded, whatever the size of the list.
+func (m model) winnersView() string {
+	w := m.winners
+	t := w.table
+	total := len(t.rows)
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("\n  Winners (%s)\n\n", formatNumber(int64(total), displayLocale)))
+
+	end := min(w.top+w.rows(), total)
+	records := make([][]string, 0, end-w.top)
+	widths := make([]int, len(t.columns))
+	for c, col := range t.columns {
+		widths[c] = min(len(col), winnersColumnWidth)
+	}
+	for i := w.top; i < end; i++ {
+		rec := t.record(i)
+		for c, v := range rec {
+			widths[c] = max(widths[c], min(utf8.RuneCountInString(v), winnersColumnWidth))
+		}
+		records = append(records, rec)
+	}
+	row := func(values []string) string {
+		cells := make([]string, len(values))
+		for c, v := range values {
+			v = truncateCell(v, widths[c])
+			cells[c] = v + strings.Repeat(" ", widths[c]-utf8.RuneCountInString(v))
+		}
+		return strings.TrimRight(strings.Join(cells, "  "), " ")
+	}
+	sb.WriteString("  " + dimStyle.Render(row(t.columns)) + "\n")
+	for _, rec := range records {
+		sb.WriteString("  " + row(rec) + "\n")
+	}
+	if total == 0 {
+		sb.WriteString("  " + tr(msgWinnersEmpty) + "\n")
+	}
+
+	pages := max(1, (total+w.rows()-1)/w.rows())
+	footer := tr(msgWinnersRows, formatNumber(int64(min(w.top+1, total)), displayLocale),
+		formatNumber(int64(end), displayLocale), formatNumber(int64(total), displayLocale),
+		formatNumber(int64(w.top/w.rows()+1), displayLocale), formatNumber(int64(pages), displayLocale))
+	sb.WriteString("\n  " + dimStyle.Render(footer) + "\n")
+	switch {
+	case w.export != nil:
+		sb.WriteString("  " + tr(msgWinnersExporting, progressBar(progressMsg{done: w.export.done, total: max(1, w.export.total), unit: "rows"}, 30)) + "\n")
+	case w.message != "":
+		sb.WriteString("  " + w.message + "\n")
+	default:
+		sb.WriteString("\n")
+	}
+	sb.WriteString("  " + dimStyle.Render(tr(msgWinnersHelp, keys.help(keyBack))))
+	return sb.String()
+}
+
+// truncateCell shortens a value to width, marking the cut
+func truncateCell(s string, width int) string {
+	runes := []rune(s)
+	if len(runes) <= width {
+		return s
+	}
+	return string(runes[:width-1]) + glyphs.Ellipsis
+}
@@ -0,0 +1,268 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/revrost/playtools/pkg/playtools"
+)
+
+// syntheticWinners is a response with n winners. Every 1000th has a note
+// with a comma, quotes and a newline, and every 7th a tier the others lack.
+func syntheticWinners(n int) []byte {
+	var sb strings.Builder
+	sb.WriteString(`{"processed":` + fmt.Sprint(n) + `,"winners":[`)
+	for i := range n {
+		if i > 0 {
+			sb.WriteByte(',')
+		}
+		fmt.Fprintf(&sb, `{"wallet":"0x%040x","prize":%d,"amount":"%d.50"`, i, i%5+1, i%250)
+		if i%7 == 0 {
+			fmt.Fprintf(&sb, `,"tier":{"level":%d}`, i%3)
+		}
+		if i%1000 == 0 {
+			fmt.Fprintf(&sb, `,"note":"row %d, \"grand\"\nprize"`, i)
+		}
+		sb.WriteByte('}')
+	}
+	sb.WriteString(`]}`)
+	return []byte(sb.String())
+}
+
+// wantWinner is the CSV record of winner i of syntheticWinners
+func wantWinner(i int) []string {
+	tier, note := "", ""
+	if i%7 == 0 {
+		tier = fmt.Sprintf(`{"level":%d}`, i%3)
+	}
+	if i%1000 == 0 {
+		note = fmt.Sprintf("row %d, \"grand\"\nprize", i)
+	}
+	return []string{fmt.Sprintf("0x%040x", i), fmt.Sprint(i%5 + 1), fmt.Sprintf("%d.50", i%250), tier, note}
+}
+
+func readWinnersCSV(t *testing.T, path string) [][]string {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	records, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return records
+}
+
+func TestExportWinners100k(t *testing.T) {
+	const n = 100_000
+	table := responseWinners(syntheticWinners(n), "winners")
+	if table == nil || len(table.rows) != n {
+		t.Fatalf("read %v winners, want %d", table, n)
+	}
+	if want := []string{"wallet", "prize", "amount", "tier", "note"}; !reflect.DeepEqual(table.columns, want) {
+		t.Errorf("columns %v, want %v", table.columns, want)
+	}
+
+	path := filepath.Join(t.TempDir(), "winners.csv")
+	var progress []int
+	size, err := writeWinnersCSV(context.Background(), table, path, func(done int) { progress = append(progress, done) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info, err := os.Stat(path); err != nil || info.Size() != size {
+		t.Errorf("reported %d bytes, the file has %v (%v)", size, info, err)
+	}
+	if len(progress) != n/winnersExportProgressRows || progress[0] != 0 || progress[len(progress)-1] != n-winnersExportProgressRows {
+		t.Errorf("progress reported %d times, from %v to %v", len(progress), progress[0], progress[len(progress)-1])
+	}
+
+	records := readWinnersCSV(t, path)
+	if len(records) != n+1 {
+		t.Fatalf("the export has %d records, want %d", len(records), n+1)
+	}
+	if !reflect.DeepEqual(records[0], table.columns) {
+		t.Errorf("header %v", records[0])
+	}
+	for i, rec := range records[1:] {
+		if want := wantWinner(i); !reflect.DeepEqual(rec, want) {
+			t.Fatalf("row %d is %q, want %q", i, rec, want)
+		}
+	}
+}
+
+func TestExportWinnersCancelled(t *testing.T) {
+	table := responseWinners(syntheticWinners(10_000), "winners")
+	path := filepath.Join(t.TempDir(), "winners.csv")
+	ctx, cancel := context.WithCancel(context.Background())
+	_, err := writeWinnersCSV(ctx, table, path, func(done int) {
+		if done == 5000 {
+			cancel()
+		}
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("err %v, want it cancelled", err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("the partial file was kept (%v)", err)
+	}
+}
+
+// A list mixing objects with other values shows every row whole
+func TestResponseWinnersMixed(t *testing.T) {
+	table := responseWinners([]byte(`{"winners":["0xabc",{"wallet":"0xdef","prize":2},7]}`), "winners")
+	var got [][]string
+	for i := range table.rows {
+		got = append(got, table.record(i))
+	}
+	if want := [][]string{{"0xabc"}, {`{"wallet":"0xdef","prize":2}`}, {"7"}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("records %q, want %q", got, want)
+	}
+	for body, want := range map[string]bool{
+		`{"winners":[]}`:        true,
+		`{"winners":null}`:      false,
+		`{"winners":3}`:         false,
+		`{"processed":3}`:       false,
+		`[{"winners":[1]},{}]`:  true,
+		`not a response at all`: false,
+	} {
+		if got := responseWinners([]byte(body), "winners") != nil; got != want {
+			t.Errorf("%s: has a list %v, want %v", body, got, want)
+		}
+	}
+}
+
+// winnersHarness is on the winners screen of a 100k winner response, with
+// the working directory in a temporary one the exports go to
+func winnersHarness(t *testing.T) (*harness, string) {
+	h := newHarness(t)
+	dir := t.TempDir()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+
+	gen := h.start(playtools.NewPayload(ActionComplete, 42))
+	res := result(gen, 42, "req-1")
+	res.inv.Payload = playtools.NewPayload(ActionComplete, 42)
+	res.inv.Body = syntheticWinners(100_000)
+	h.send(res)
+	h.wantScreen(OutputScreen)
+	h.send(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'w'}})
+	h.wantScreen(WinnersScreen)
+	return h, dir
+}
+
+// exportWinners presses x and runs the export's commands the way the
+// program would, feeding the model every progress and the outcome
+func exportWinners(h *harness, beforeRun func()) {
+	h.t.Helper()
+	next, cmd := h.m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'x'}})
+	h.m = next.(model)
+	if h.m.winners.export == nil {
+		h.t.Fatal("x didn't start an export")
+	}
+	batch, ok := cmd().(tea.BatchMsg)
+	if !ok || len(batch) != 2 {
+		h.t.Fatalf("x returned %T", cmd())
+	}
+	if beforeRun != nil {
+		beforeRun()
+	}
+	outcome := make(chan tea.Msg)
+	go func() { outcome <- batch[0]() }()
+	wait := batch[1]
+	for wait != nil {
+		msg := wait()
+		if msg == nil {
+			break
+		}
+		next, wait = h.m.Update(msg)
+		h.m = next.(model)
+		if !strings.Contains(h.m.View(), "Exporting") {
+			h.t.Errorf("no progress shown during the export\n%s", h.m.View())
+		}
+	}
+	h.send(<-outcome)
+}
+
+func TestWinnersScreen(t *testing.T) {
+	h, dir := winnersHarness(t)
+	rows := h.m.winners.rows()
+	pages := (100_000 + rows - 1) / rows
+
+	view := h.m.View()
+	for _, want := range []string{
+		"Winners (100,000)",
+		"wallet",
+		fmt.Sprintf("0x%040x", 0)[:winnersColumnWidth-1] + glyphs.Ellipsis,
+		fmt.Sprintf("Rows 1-%d of 100,000, page 1 of %s", rows, formatNumber(int64(pages), displayLocale)),
+	} {
+		if !strings.Contains(view, want) {
+			t.Errorf("the view has no %q\n%s", want, view)
+		}
+	}
+	if lines := strings.Count(view, "\n") + 1; lines > 40 {
+		t.Errorf("the view has %d lines for a 40 line terminal", lines)
+	}
+
+	h.send(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'n'}})
+	if view := h.m.View(); !strings.Contains(view, fmt.Sprintf("Rows %d-%d of 100,000, page 2 of", rows+1, 2*rows)) {
+		t.Errorf("after n\n%s", view)
+	}
+	h.send(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'G'}})
+	if view := h.m.View(); !strings.Contains(view, fmt.Sprintf("Rows %s-100,000 of 100,000", formatNumber(int64(100_000-rows+1), displayLocale))) {
+		t.Errorf("after G\n%s", view)
+	}
+
+	exportWinners(h, nil)
+	if h.m.winners.export != nil || h.m.winners.cancel != nil {
+		t.Error("the export is still running")
+	}
+	matches, _ := filepath.Glob(filepath.Join(dir, "playtools-winners-dev-42-*.csv"))
+	if len(matches) != 1 {
+		t.Fatalf("exported %v", matches)
+	}
+	info, err := os.Stat(matches[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := fmt.Sprintf("Exported 100,000 winners to %s (%s)", filepath.Base(matches[0]), formatSize(info.Size()))
+	if view := h.m.View(); !strings.Contains(view, want) {
+		t.Errorf("the view has no %q\n%s", want, view)
+	}
+	if records := readWinnersCSV(t, matches[0]); len(records) != 100_001 {
+		t.Errorf("the export has %d records", len(records))
+	}
+}
+
+func TestWinnersExportCancelled(t *testing.T) {
+	h, dir := winnersHarness(t)
+	exportWinners(h, func() {
+		h.send(tea.KeyMsg{Type: tea.KeyEsc})
+		h.wantScreen(WinnersScreen)
+	})
+	if view := h.m.View(); !strings.Contains(view, "Export cancelled, the partial file was removed") {
+		t.Errorf("after Esc\n%s", view)
+	}
+	if matches, _ := filepath.Glob(filepath.Join(dir, "*.csv")); len(matches) != 0 {
+		t.Errorf("kept %v", matches)
+	}
+
+	// Esc goes back once nothing is running
+	h.send(tea.KeyMsg{Type: tea.KeyEsc})
+	h.wantScreen(OutputScreen)
+}
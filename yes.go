@@ -0,0 +1,41 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+)
+
+// assumeYes answers the command line's confirmation prompts, set with --yes
+// or --force. Only the commands take it, so the TUI always shows its
+// confirmation screen.
+var assumeYes bool
+
+// iKnowWhatImDoing lets --yes confirm a complete in prod
+var iKnowWhatImDoing bool
+
+// yesFlags adds --yes, its --force alias and --i-know-what-im-doing to a
+// command's flags
+func yesFlags(fs *flag.FlagSet) {
+	fs.BoolVar(&assumeYes, "yes", assumeYes, "")
+	fs.BoolVar(&assumeYes, "force", assumeYes, "")
+	fs.BoolVar(&iKnowWhatImDoing, "i-know-what-im-doing", iKnowWhatImDoing, "")
+}
+
+// yesProblem refuses --yes on a payload too dangerous to confirm without a
+// human, empty when it's allowed
+func yesProblem(env string, payload EventPayload) string {
+	if assumeYes && !iKnowWhatImDoing && isProtected(env) && payload.Action == ActionComplete {
+		return fmt.Sprintf("--yes won't confirm a %s in %s on its own, add --i-know-what-im-doing", payload.Action, env)
+	}
+	return ""
+}
+
+// checksInput is where the checks read a typed phrase from, nowhere with
+// --yes so that a warning refuses rather than waiting on nobody
+func checksInput(in *bufio.Reader) *bufio.Reader {
+	if assumeYes {
+		return nil
+	}
+	return in
+}